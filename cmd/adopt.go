@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+	"sigs.k8s.io/yaml"
+)
+
+// provisionedByAnnotation lets the external-provisioner delete the volume of an adopted PV
+// with reclaim policy Delete
+const provisionedByAnnotation = "pv.kubernetes.io/provisioned-by"
+
+// runAdopt prints static PVs of the volumes volumeIDs, all volumes are looked up before
+// anything is printed so that the output is never partial
+func runAdopt(ctx context.Context, o *volumesOptions, volumeIDs []string, out io.Writer) error {
+	if len(volumeIDs) == 0 {
+		return errors.New("adopt requires the IDs of the volumes")
+	}
+	if o.output == "" {
+		o.output = "yaml"
+	}
+	if o.output != "yaml" && o.output != "json" {
+		return fmt.Errorf("invalid output %q: expected yaml or json", o.output)
+	}
+	mode := v1.PersistentVolumeMode(o.volumeMode)
+	if mode != v1.PersistentVolumeFilesystem && mode != v1.PersistentVolumeBlock {
+		return fmt.Errorf("invalid volume mode %q: expected %s or %s", o.volumeMode, v1.PersistentVolumeFilesystem, v1.PersistentVolumeBlock)
+	}
+	policy := v1.PersistentVolumeReclaimPolicy(o.reclaimPolicy)
+	if policy != v1.PersistentVolumeReclaimRetain && policy != v1.PersistentVolumeReclaimDelete {
+		return fmt.Errorf("invalid reclaim policy %q: expected %s or %s", o.reclaimPolicy, v1.PersistentVolumeReclaimRetain, v1.PersistentVolumeReclaimDelete)
+	}
+
+	c, err := o.newCloud()
+	if err != nil {
+		return err
+	}
+	var pvs []*v1.PersistentVolume
+	for _, volumeID := range volumeIDs {
+		disk, err := c.GetDiskByID(ctx, strings.ToLower(volumeID))
+		if err != nil {
+			return fmt.Errorf("could not get volume %s: %v", volumeID, err)
+		}
+		if disk.State == cloud.VolumeErrorState {
+			return fmt.Errorf("volume %s is in state %s", volumeID, disk.State)
+		}
+		pvs = append(pvs, adoptedPV(disk, o))
+	}
+
+	for i, pv := range pvs {
+		if o.output == "json" {
+			if err := printJSON(out, pv); err != nil {
+				return err
+			}
+			continue
+		}
+		b, err := yaml.Marshal(pv)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(out, "---")
+		}
+		if _, err := out.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adoptedPV returns the static PV of disk
+func adoptedPV(disk *cloud.Disk, o *volumesOptions) *v1.PersistentVolume {
+	mode := v1.PersistentVolumeMode(o.volumeMode)
+	attributes := driver.StaticVolumeAttributes(disk)
+	var fsType string
+	if mode == v1.PersistentVolumeFilesystem {
+		fsType = o.fsType
+		if o.preFormatted {
+			attributes[driver.PreFormattedKey] = strconv.FormatBool(true)
+		}
+	}
+
+	handle := disk.VolumeID
+	var affinity *v1.VolumeNodeAffinity
+	if o.multiWorkspace {
+		handle = cloud.JoinVolumeHandle(o.cloudInstanceID, disk.VolumeID)
+		// like the topology of the dynamically provisioned volumes of the workspace
+		affinity = &v1.VolumeNodeAffinity{Required: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{{
+			MatchExpressions: []v1.NodeSelectorRequirement{{
+				Key:      driver.WorkspaceTopologyKey,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{o.cloudInstanceID},
+			}},
+		}}}}
+	}
+
+	// the PowerVS name of volumes provisioned by the driver is the name of their PV
+	name := strings.ToLower(disk.Name)
+	if len(validation.IsDNS1123Subdomain(name)) > 0 {
+		name = "powervs-" + disk.VolumeID
+	}
+
+	return &v1.PersistentVolume{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolume"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{provisionedByAnnotation: driver.DriverName},
+		},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity:    v1.ResourceList{v1.ResourceStorage: *resource.NewQuantity(util.GiBToBytes(disk.CapacityGiB), resource.BinarySI)},
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			PersistentVolumeSource: v1.PersistentVolumeSource{CSI: &v1.CSIPersistentVolumeSource{
+				Driver:           driver.DriverName,
+				VolumeHandle:     handle,
+				FSType:           fsType,
+				VolumeAttributes: attributes,
+			}},
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimPolicy(o.reclaimPolicy),
+			StorageClassName:              o.storageClass,
+			VolumeMode:                    &mode,
+			NodeAffinity:                  affinity,
+		},
+	}
+}
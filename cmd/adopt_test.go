@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	mocks "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/mocks"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver"
+	"sigs.k8s.io/yaml"
+)
+
+func TestAdoptedPV(t *testing.T) {
+	disk := &cloud.Disk{VolumeID: "vol-1", Name: "data_disk", DiskType: "Tier1", CapacityGiB: 20}
+
+	t.Run("filesystem", func(t *testing.T) {
+		pv := adoptedPV(disk, &volumesOptions{cloudInstanceID: "ws", fsType: "xfs", volumeMode: "Filesystem", reclaimPolicy: "Retain", preFormatted: true, storageClass: "powervs"})
+		if pv.Name != "powervs-vol-1" {
+			t.Fatalf("expected name powervs-vol-1 of an invalid volume name, got %q", pv.Name)
+		}
+		csi := pv.Spec.CSI
+		if csi.VolumeHandle != "vol-1" || csi.FSType != "xfs" || pv.Spec.NodeAffinity != nil {
+			t.Fatalf("unexpected CSI source %+v, node affinity %v", csi, pv.Spec.NodeAffinity)
+		}
+		if csi.VolumeAttributes[driver.TierKey] != "tier1" || csi.VolumeAttributes[driver.ShareableKey] != "false" || csi.VolumeAttributes[driver.PreFormattedKey] != "true" {
+			t.Fatalf("unexpected volume attributes %v", csi.VolumeAttributes)
+		}
+		capacity := pv.Spec.Capacity[v1.ResourceStorage]
+		if capacity.String() != "20Gi" || pv.Spec.StorageClassName != "powervs" {
+			t.Fatalf("unexpected capacity %s or StorageClass %q", capacity.String(), pv.Spec.StorageClassName)
+		}
+	})
+
+	t.Run("block in a workspace", func(t *testing.T) {
+		pv := adoptedPV(&cloud.Disk{VolumeID: "vol-1", Name: "pvc-1", CapacityGiB: 20}, &volumesOptions{cloudInstanceID: "ws", fsType: "ext4", volumeMode: "Block", reclaimPolicy: "Delete", preFormatted: true, multiWorkspace: true})
+		if pv.Name != "pvc-1" || pv.Spec.CSI.VolumeHandle != "ws/vol-1" || pv.Spec.CSI.FSType != "" {
+			t.Fatalf("unexpected PV %s with CSI source %+v", pv.Name, pv.Spec.CSI)
+		}
+		if _, ok := pv.Spec.CSI.VolumeAttributes[driver.PreFormattedKey]; ok {
+			t.Fatalf("expected no %s of a block volume", driver.PreFormattedKey)
+		}
+		if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required.NodeSelectorTerms[0].MatchExpressions[0].Key != driver.WorkspaceTopologyKey {
+			t.Fatalf("expected node affinity on %s, got %+v", driver.WorkspaceTopologyKey, pv.Spec.NodeAffinity)
+		}
+	})
+}
+
+func TestRunAdopt(t *testing.T) {
+	testCases := []struct {
+		name      string
+		args      []string
+		disks     map[string]*cloud.Disk
+		expectErr bool
+		expected  []string
+	}{
+		{
+			name: "volumes",
+			args: []string{"adopt", "--cloud-instance-id=ws", "vol-1", "VOL-2"},
+			disks: map[string]*cloud.Disk{
+				"vol-1": {VolumeID: "vol-1", Name: "pvc-1", DiskType: "tier1", CapacityGiB: 10},
+				"vol-2": {VolumeID: "vol-2", Name: "pvc-2", DiskType: "tier3", CapacityGiB: 20},
+			},
+			expected: []string{"pvc-1", "pvc-2"},
+		},
+		{
+			name: "failed volume",
+			args: []string{"adopt", "--cloud-instance-id=ws", "vol-1"},
+			disks: map[string]*cloud.Disk{
+				"vol-1": {VolumeID: "vol-1", Name: "pvc-1", CapacityGiB: 10, State: cloud.VolumeErrorState},
+			},
+			expectErr: true,
+		},
+		{
+			name:      "unknown volume",
+			args:      []string{"adopt", "--cloud-instance-id=ws", "vol-1"},
+			disks:     map[string]*cloud.Disk{},
+			expectErr: true,
+		},
+		{
+			name:      "without volumes",
+			args:      []string{"adopt", "--cloud-instance-id=ws"},
+			expectErr: true,
+		},
+		{
+			name:      "invalid volume mode",
+			args:      []string{"adopt", "--cloud-instance-id=ws", "--volume-mode=Raw", "vol-1"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, volumeID string) (*cloud.Disk, error) {
+				if disk, ok := tc.disks[volumeID]; ok {
+					return disk, nil
+				}
+				return nil, cloud.ErrNotFound
+			}).AnyTimes()
+			oldNewVolumesCloud := newVolumesCloud
+			defer func() { newVolumesCloud = oldNewVolumesCloud }()
+			newVolumesCloud = func(cloudInstanceID string, debug bool, options ...func(*cloud.Options)) (cloud.Cloud, error) {
+				return mockCloud, nil
+			}
+
+			var out bytes.Buffer
+			err := runVolumes(context.Background(), tc.args, nil, &out)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.expectErr, err)
+			}
+			if err != nil {
+				if out.Len() > 0 {
+					t.Fatalf("expected no output on error, got:\n%s", out.String())
+				}
+				return
+			}
+			docs := strings.Split(out.String(), "---\n")
+			if len(docs) != len(tc.expected) {
+				t.Fatalf("expected %d PVs, got:\n%s", len(tc.expected), out.String())
+			}
+			for i, doc := range docs {
+				var pv v1.PersistentVolume
+				if err := yaml.Unmarshal([]byte(doc), &pv); err != nil {
+					t.Fatalf("could not parse PV: %v", err)
+				}
+				if pv.Kind != "PersistentVolume" || pv.Name != tc.expected[i] {
+					t.Fatalf("expected PV %s, got %s %s", tc.expected[i], pv.Kind, pv.Name)
+				}
+			}
+		})
+	}
+}
@@ -19,30 +19,116 @@ package main
 //DONE
 
 import (
+	"context"
 	"flag"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/cmd/options"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver"
 
 	"k8s.io/klog/v2"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == volumesCommand {
+		if err := runVolumes(context.Background(), os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			klog.Fatalln(err)
+		}
+		return
+	}
+
 	fs := flag.NewFlagSet("ibm-powervs-block-csi-driver", flag.ExitOnError)
 	options := GetOptions(fs)
 
 	drv, err := driver.NewDriver(
 		driver.WithEndpoint(options.ServerOptions.Endpoint),
-		//driver.WithExtraTags(options.ControllerOptions.ExtraTags),
+		driver.WithTLS(options.ServerOptions.TLSCertFile, options.ServerOptions.TLSKeyFile, options.ServerOptions.TLSClientCAFile),
+		driver.WithHTTPEndpoint(options.ServerOptions.HTTPEndpoint),
+		driver.WithSocketOptions(options.ServerOptions.Socket),
+		driver.WithGRPCServerOptions(options.ServerOptions.GRPCServer),
+		driver.WithFeatureGates(options.ServerOptions.FeatureGates),
+		driver.WithExtraTags(options.ControllerOptions.ExtraTags),
 		//river.WithExtraVolumeTags(options.ControllerOptions.ExtraVolumeTags),
 		driver.WithMode(options.DriverMode),
 		driver.WithDebug(options.ServerOptions.Debug),
+		driver.WithRequestLogLevel(options.ServerOptions.RequestLogLevel),
+		driver.WithSlowOperationThreshold(options.ServerOptions.SlowOperationThreshold),
+		driver.WithShutdownTimeout(options.ServerOptions.ShutdownTimeout),
+		driver.WithVolumeLockTimeout(options.ServerOptions.VolumeLockTimeout),
+		driver.WithTracing(options.ServerOptions.EnableTracing),
+		driver.WithAPIEndpoints(options.ServerOptions.APIEndpoints),
+		driver.WithServiceEndpoints(cloud.ServiceEndpoints{
+			IAM:                options.ServerOptions.IAMEndpoint,
+			ResourceController: options.ServerOptions.ResourceControllerEndpoint,
+			GlobalTagging:      options.ServerOptions.GlobalTaggingEndpoint,
+		}),
+		driver.WithAPIKeyFile(options.ServerOptions.APIKeyFile),
+		driver.WithAuthType(options.ServerOptions.AuthType),
+		driver.WithTrustedProfile(options.ServerOptions.TrustedProfileID, options.ServerOptions.TrustedProfileName, options.ServerOptions.CRTokenFile),
+		driver.WithVolumeStateTimeout(options.ServerOptions.VolumeStateTimeout),
+		driver.WithVolumeStatePollInterval(options.ServerOptions.VolumeStatePollInterval),
+		driver.WithOperationTimeouts(options.ServerOptions.OperationTimeouts()),
+		driver.WithAPIRetryBackoff(options.ServerOptions.APIRetryInitialDelay, options.ServerOptions.APIRetrySteps),
+		driver.WithAPICallTimeout(options.ServerOptions.CloudAPITimeout),
+		driver.WithCloudProvider(options.ServerOptions.CloudProvider, options.ServerOptions.FakeCloudLatency),
 		driver.WithVolumeAttachLimit(options.NodeOptions.VolumeAttachLimit),
-		//driver.WithKubernetesClusterID(options.ControllerOptions.KubernetesClusterID),
+		driver.WithVolumeStats(options.NodeOptions.VolumeStats),
+		driver.WithVolumeStatsCacheTTL(options.NodeOptions.VolumeStatsCacheTTL),
+		driver.WithFsckPolicy(options.NodeOptions.FsckPolicy),
+		driver.WithExt4FormatOptions(options.NodeOptions.Ext4FormatOptions),
+		driver.WithStoragePool(options.NodeOptions.StoragePool),
+		driver.WithStaleDeviceCleanup(options.NodeOptions.StaleDeviceCleanup),
+		driver.WithKubernetesClusterID(options.ControllerOptions.KubernetesClusterID),
+		driver.WithTierMigrationInterval(options.ControllerOptions.TierMigrationInterval),
+		driver.WithTierMigrationTimeout(options.ControllerOptions.TierMigrationTimeout),
+		driver.WithTagReconcileInterval(options.ControllerOptions.TagReconcileInterval),
+		driver.WithLeaderElection(options.ControllerOptions.LeaderElection, options.ControllerOptions.LeaderElectionNamespace),
+		driver.WithLegacyVolumeHandles(options.ControllerOptions.LegacyVolumeHandles),
+		driver.WithEvents(options.ControllerOptions.Events),
+		driver.WithPollWorkers(options.ControllerOptions.PollWorkers, options.ControllerOptions.PollQueueSize),
+		driver.WithTierAttachLimits(options.ControllerOptions.TierAttachLimits),
+		driver.WithAttachBatchWindow(options.ControllerOptions.AttachBatchWindow),
+		driver.WithDetachBatchWindow(options.ControllerOptions.DetachBatchWindow),
+		driver.WithForceDetachTimeout(options.ControllerOptions.ForceDetachTimeout),
+		driver.WithCloudInstanceIDs(options.ControllerOptions.CloudInstanceIDs),
 	)
 	if err != nil {
 		klog.Fatalln(err)
 	}
+	if options.Config != nil {
+		err := options.Config.Watch(func() {
+			reconfigure(drv, options.ServerOptions)
+		})
+		if err != nil {
+			klog.Fatalln(err)
+		}
+	}
+	// stop gracefully on SIGTERM, Run returns once the in-flight requests are drained
+	terminate := make(chan os.Signal, 1)
+	signal.Notify(terminate, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		<-terminate
+		drv.Stop()
+	}()
 	if err := drv.Run(); err != nil {
 		klog.Fatalln(err)
 	}
 }
+
+// reconfigure applies the reloadable server options to the running driver
+func reconfigure(drv *driver.Driver, o *options.ServerOptions) {
+	err := drv.Reconfigure(
+		driver.WithRequestLogLevel(o.RequestLogLevel),
+		driver.WithSlowOperationThreshold(o.SlowOperationThreshold),
+		driver.WithVolumeStateTimeout(o.VolumeStateTimeout),
+		driver.WithVolumeStatePollInterval(o.VolumeStatePollInterval),
+		driver.WithOperationTimeouts(o.OperationTimeouts()),
+		driver.WithAPIRetryBackoff(o.APIRetryInitialDelay, o.APIRetrySteps),
+	)
+	if err != nil {
+		klog.Errorf("could not apply the reloaded config file: %v", err)
+	}
+}
@@ -34,8 +34,11 @@ type Options struct {
 	DriverMode driver.Mode
 
 	*options.ServerOptions
-	//*options.ControllerOptions
+	*options.ControllerOptions
 	*options.NodeOptions
+
+	// Config is the config file set by the config flag, nil without one
+	Config *options.ConfigFile
 }
 
 // used for testing
@@ -50,9 +53,9 @@ func GetOptions(fs *flag.FlagSet) *Options {
 		args = os.Args[1:]
 		mode = driver.AllMode
 
-		serverOptions = options.ServerOptions{}
-		//controllerOptions = options.ControllerOptions{}
-		nodeOptions = options.NodeOptions{}
+		serverOptions     = options.ServerOptions{}
+		controllerOptions = options.ControllerOptions{}
+		nodeOptions       = options.NodeOptions{}
 	)
 
 	serverOptions.AddFlags(fs)
@@ -63,7 +66,7 @@ func GetOptions(fs *flag.FlagSet) *Options {
 
 		switch {
 		case cmd == string(driver.ControllerMode):
-			//controllerOptions.AddFlags(fs)
+			controllerOptions.AddFlags(fs)
 			args = os.Args[2:]
 			mode = driver.ControllerMode
 
@@ -73,17 +76,17 @@ func GetOptions(fs *flag.FlagSet) *Options {
 			mode = driver.NodeMode
 
 		case cmd == string(driver.AllMode):
-			//controllerOptions.AddFlags(fs)
+			controllerOptions.AddFlags(fs)
 			nodeOptions.AddFlags(fs)
 			args = os.Args[2:]
 
 		case strings.HasPrefix(cmd, "-"):
-			//controllerOptions.AddFlags(fs)
+			controllerOptions.AddFlags(fs)
 			nodeOptions.AddFlags(fs)
 			args = os.Args[1:]
 
 		default:
-			klog.Errorf("unknown command: %s: expected %q, %q or %q", cmd, driver.ControllerMode, driver.NodeMode, driver.AllMode)
+			klog.Errorf("unknown command: %s: expected %q, %q, %q or %q", cmd, driver.ControllerMode, driver.NodeMode, driver.AllMode, volumesCommand)
 			os.Exit(1)
 		}
 	}
@@ -92,6 +95,14 @@ func GetOptions(fs *flag.FlagSet) *Options {
 		panic(err)
 	}
 
+	var config *options.ConfigFile
+	if serverOptions.ConfigFile != "" {
+		config = options.NewConfigFile(serverOptions.ConfigFile, fs, modeFlags(fs))
+		if err := config.Load(); err != nil {
+			klog.Fatalln(err)
+		}
+	}
+
 	if *version {
 		info, err := driver.GetVersionJSON()
 		if err != nil {
@@ -104,8 +115,25 @@ func GetOptions(fs *flag.FlagSet) *Options {
 	return &Options{
 		DriverMode: mode,
 
-		ServerOptions: &serverOptions,
-		//ControllerOptions: &controllerOptions,
-		NodeOptions: &nodeOptions,
+		ServerOptions:     &serverOptions,
+		ControllerOptions: &controllerOptions,
+		NodeOptions:       &nodeOptions,
+
+		Config: config,
 	}
 }
+
+// modeFlags returns the flags of the controller and node options missing from fs, which are
+// the ones of the driver modes that aren't run
+func modeFlags(fs *flag.FlagSet) []string {
+	all := flag.NewFlagSet("", flag.ContinueOnError)
+	(&options.ControllerOptions{}).AddFlags(all)
+	(&options.NodeOptions{}).AddFlags(all)
+	var names []string
+	all.VisitAll(func(f *flag.Flag) {
+		if fs.Lookup(f.Name) == nil {
+			names = append(names, f.Name)
+		}
+	})
+	return names
+}
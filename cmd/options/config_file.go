@@ -0,0 +1,252 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// ReloadableFlags are the settings of the config file that are applied again on SIGHUP or
+// when the file changes, the other settings only take effect when the driver is restarted
+var ReloadableFlags = []string{
+	"v",
+	"request-log-level",
+	"slow-operation-threshold",
+	"volume-state-timeout",
+	"volume-state-poll-interval",
+	"create-timeout",
+	"delete-timeout",
+	"attach-timeout",
+	"detach-timeout",
+	"expand-timeout",
+	"api-retry-initial-delay",
+	"api-retry-steps",
+}
+
+// ConfigFile sets the flags of a FlagSet from a YAML or JSON file mapping flag names to
+// their values, e.g. "volume-state-timeout: 5m". Lists are set as comma separated values and
+// maps as comma separated key=value pairs. Flags given on the command line take precedence
+// over the file.
+type ConfigFile struct {
+	path string
+	fs   *flag.FlagSet
+	// explicit are the flags set on the command line
+	explicit map[string]bool
+	// ignored are the flags of the other driver modes, allowing a single file for all modes
+	ignored map[string]bool
+	// content is the content of the file when it was last applied
+	content []byte
+}
+
+// NewConfigFile returns the config file at path for fs, which must already be parsed.
+// Settings of the flags in ignored are skipped.
+func NewConfigFile(path string, fs *flag.FlagSet, ignored []string) *ConfigFile {
+	c := &ConfigFile{
+		path:     path,
+		fs:       fs,
+		explicit: map[string]bool{},
+		ignored:  map[string]bool{},
+	}
+	fs.Visit(func(f *flag.Flag) {
+		c.explicit[f.Name] = true
+	})
+	for _, name := range ignored {
+		c.ignored[name] = true
+	}
+	return c
+}
+
+// Load applies all settings of the file
+func (c *ConfigFile) Load() error {
+	values, err := c.read()
+	if err != nil {
+		return err
+	}
+	for name, value := range values {
+		if c.fs.Lookup(name) == nil || c.explicit[name] {
+			continue
+		}
+		if err := c.fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for %s in config file %s: %v", value, name, c.path, err)
+		}
+	}
+	return nil
+}
+
+// Reload applies the ReloadableFlags of the file, the ones missing from the file are reset to
+// their default. Nothing is changed if one of the values is invalid.
+func (c *ConfigFile) Reload() error {
+	values, err := c.read()
+	if err != nil {
+		return err
+	}
+	previous := map[string]string{}
+	for _, name := range ReloadableFlags {
+		f := c.fs.Lookup(name)
+		if f == nil || c.explicit[name] {
+			continue
+		}
+		value, ok := values[name]
+		if !ok {
+			value = f.DefValue
+		}
+		previous[name] = f.Value.String()
+		if err := c.fs.Set(name, value); err != nil {
+			for name, value := range previous {
+				_ = c.fs.Set(name, value)
+			}
+			return fmt.Errorf("invalid value %q for %s in config file %s: %v", value, name, c.path, err)
+		}
+	}
+	return nil
+}
+
+// Watch calls Reload and then onReload on SIGHUP and whenever the content of the file
+// changes. The parent directory is watched since Kubernetes updates mounted config maps by
+// swapping a symlink rather than writing the file.
+func (c *ConfigFile) Watch(onReload func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(c.path)); err != nil {
+		watcher.Close()
+		return err
+	}
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+					continue
+				}
+				c.reload(false, onReload)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Warningf("error watching config file %s: %v", c.path, err)
+			case <-hangup:
+				c.reload(true, onReload)
+			}
+		}
+	}()
+	return nil
+}
+
+// reload applies a changed file, or the file in any case when forced
+func (c *ConfigFile) reload(force bool, onReload func()) {
+	previous := c.content
+	if !force {
+		content, err := os.ReadFile(c.path)
+		if err != nil {
+			klog.V(4).Infof("ignoring config file event: %v", err)
+			return
+		}
+		if bytes.Equal(content, previous) {
+			return
+		}
+	}
+	if err := c.Reload(); err != nil {
+		klog.Errorf("could not reload the config file: %v", err)
+		return
+	}
+	klog.Infof("reloaded the config file %s", c.path)
+	onReload()
+}
+
+// read returns the settings of the file as flag values
+func (c *ConfigFile) read() (map[string]string, error) {
+	content, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %v", err)
+	}
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(content, &settings); err != nil {
+		return nil, fmt.Errorf("could not parse config file %s: %v", c.path, err)
+	}
+	values := map[string]string{}
+	for name, setting := range settings {
+		if c.ignored[name] {
+			continue
+		}
+		if c.fs.Lookup(name) == nil || name == "config" {
+			return nil, fmt.Errorf("unknown option %q in config file %s", name, c.path)
+		}
+		value, err := flagValue(setting)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s in config file %s: %v", name, c.path, err)
+		}
+		values[name] = value
+	}
+	c.content = content
+	return values, nil
+}
+
+// flagValue formats a setting of the config file as flag value
+func flagValue(setting interface{}) (string, error) {
+	switch v := setting.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			value, err := flagValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, value)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		pairs := make([]string, 0, len(v))
+		for key, item := range v {
+			value, err := flagValue(item)
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, key+"="+value)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", setting)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, file, content string) {
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigFileLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, file, `
+endpoint: tcp://0.0.0.0:10000
+debug: true
+api-retry-steps: 3
+volume-state-timeout: 5m
+api-endpoints:
+- us-south.power-iaas.cloud.ibm.com
+- us-east.power-iaas.cloud.ibm.com
+extra-tags:
+  team: storage
+  env: prod
+volume-attach-limit: 10
+`)
+
+	fs := flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+	server := &ServerOptions{}
+	server.AddFlags(fs)
+	controller := &ControllerOptions{}
+	controller.AddFlags(fs)
+	if err := fs.Parse([]string{"--api-retry-steps=7"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewConfigFile(file, fs, []string{"volume-attach-limit"}).Load(); err != nil {
+		t.Fatal(err)
+	}
+	if server.Endpoint != "tcp://0.0.0.0:10000" || !server.Debug || server.VolumeStateTimeout != 5*time.Minute {
+		t.Fatalf("expected the settings of the file, got endpoint %q, debug %v and volume state timeout %v", server.Endpoint, server.Debug, server.VolumeStateTimeout)
+	}
+	if server.APIRetrySteps != 7 {
+		t.Fatalf("expected the flag to take precedence, got %d retry steps", server.APIRetrySteps)
+	}
+	if expected := []string{"us-south.power-iaas.cloud.ibm.com", "us-east.power-iaas.cloud.ibm.com"}; !reflect.DeepEqual(server.APIEndpoints, expected) {
+		t.Fatalf("expected API endpoints %v, got %v", expected, server.APIEndpoints)
+	}
+	if expected := map[string]string{"team": "storage", "env": "prod"}; !reflect.DeepEqual(controller.ExtraTags, expected) {
+		t.Fatalf("expected extra tags %v, got %v", expected, controller.ExtraTags)
+	}
+
+	fs = flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+	(&ServerOptions{}).AddFlags(fs)
+	writeConfigFile(t, file, `{"volume-state-timeot": "5m"}`)
+	if err := NewConfigFile(file, fs, nil).Load(); err == nil {
+		t.Fatalf("expected error for an unknown option")
+	}
+	writeConfigFile(t, file, `{"volume-state-timeout": "5 minutes"}`)
+	if err := NewConfigFile(file, fs, nil).Load(); err == nil {
+		t.Fatalf("expected error for an invalid value")
+	}
+}
+
+func TestConfigFileReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, file, "endpoint: tcp://0.0.0.0:10000\nvolume-state-timeout: 5m\napi-retry-steps: 3\n")
+
+	fs := flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+	server := &ServerOptions{}
+	server.AddFlags(fs)
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	config := NewConfigFile(file, fs, nil)
+	if err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	writeConfigFile(t, file, "endpoint: tcp://0.0.0.0:20000\nvolume-state-timeout: 10m\n")
+	if err := config.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if server.VolumeStateTimeout != 10*time.Minute {
+		t.Fatalf("expected the reloaded volume state timeout, got %v", server.VolumeStateTimeout)
+	}
+	if server.APIRetrySteps != 5 {
+		t.Fatalf("expected retry steps to be reset to the default, got %d", server.APIRetrySteps)
+	}
+	if server.Endpoint != "tcp://0.0.0.0:10000" {
+		t.Fatalf("expected the endpoint not to be reloaded, got %q", server.Endpoint)
+	}
+
+	writeConfigFile(t, file, "volume-state-timeout: 15m\napi-retry-steps: many\n")
+	if err := config.Reload(); err == nil {
+		t.Fatalf("expected error for an invalid value")
+	}
+	if server.VolumeStateTimeout != 10*time.Minute {
+		t.Fatalf("expected the previous volume state timeout to be kept, got %v", server.VolumeStateTimeout)
+	}
+}
+
+func TestConfigFileWatch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, file, "volume-state-timeout: 5m\n")
+
+	fs := flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+	server := &ServerOptions{}
+	server.AddFlags(fs)
+	config := NewConfigFile(file, fs, nil)
+	if err := config.Load(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := make(chan time.Duration, 10)
+	if err := config.Watch(func() {
+		reloaded <- server.VolumeStateTimeout
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeConfigFile(t, file, "volume-state-timeout: 10m\n")
+	select {
+	case timeout := <-reloaded:
+		if timeout != 10*time.Minute {
+			t.Fatalf("expected the reloaded volume state timeout, got %v", timeout)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the config file to be reloaded")
+	}
+}
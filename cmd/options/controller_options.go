@@ -18,21 +18,94 @@ package options
 
 //DONE
 
-//// ControllerOptions contains options and configuration settings for the controller service.
-//type ControllerOptions struct {
-//	// ExtraTags is a map of tags that will be attached to each dynamically provisioned
-//	// resource.
-//	ExtraTags map[string]string
-//	//// ExtraVolumeTags is a map of tags that will be attached to each dynamically provisioned
-//	//// volume.
-//	//// DEPRECATED: Use ExtraTags instead.
-//	//ExtraVolumeTags map[string]string
-//	// ID of the kubernetes cluster.
-//	KubernetesClusterID string
-//}
-
-//func (s *ControllerOptions) AddFlags(fs *flag.FlagSet) {
-//	//fs.Var(cliflag.NewMapStringString(&s.ExtraTags), "extra-tags", "Extra tags to attach to each dynamically provisioned resource. It is a comma separated list of key value pairs like '<key1>=<value1>,<key2>=<value2>'")
-//	//fs.Var(cliflag.NewMapStringString(&s.ExtraVolumeTags), "extra-volume-tags", "DEPRECATED: Please use --extra-tags instead. Extra volume tags to attach to each dynamically provisioned volume. It is a comma separated list of key value pairs like '<key1>=<value1>,<key2>=<value2>'")
-//	//fs.StringVar(&s.KubernetesClusterID, "k8s-tag-cluster-id", "", "ID of the Kubernetes cluster used for tagging provisioned EBS volumes (optional).")
-//}
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	cliflag "k8s.io/component-base/cli/flag"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver"
+)
+
+// ControllerOptions contains options and configuration settings for the controller service.
+type ControllerOptions struct {
+	// ExtraTags is a map of tags that will be attached to each dynamically provisioned
+	// resource.
+	ExtraTags map[string]string
+	//// ExtraVolumeTags is a map of tags that will be attached to each dynamically provisioned
+	//// volume.
+	//// DEPRECATED: Use ExtraTags instead.
+	//ExtraVolumeTags map[string]string
+	// ID of the kubernetes cluster.
+	KubernetesClusterID string
+	// TierMigrationInterval is the resync period of the tier migration reconciler.
+	TierMigrationInterval time.Duration
+	// TierMigrationTimeout is the time a tier migration may take before it fails.
+	TierMigrationTimeout time.Duration
+	// TagReconcileInterval is the resync period of the tag reconciler.
+	TagReconcileInterval time.Duration
+	// CloudInstanceIDs are the PowerVS workspaces volumes are managed in.
+	CloudInstanceIDs []string
+	// LeaderElection runs the background loops only on the replica holding the controller lease.
+	LeaderElection bool
+	// LeaderElectionNamespace is the namespace of the controller lease.
+	LeaderElectionNamespace string
+	// LegacyVolumeHandles accepts the volume handles of PVs created before the CSI driver.
+	LegacyVolumeHandles bool
+	// Events emits events on the PVCs and PVs of failing volumes.
+	Events bool
+	// PollWorkers is the number of workers polling long running PowerVS operations.
+	PollWorkers int
+	// PollQueueSize is the number of operations accepted by the poll workers at a time.
+	PollQueueSize int
+	// TierAttachLimits are the volumes of a tier attached to a node at most.
+	TierAttachLimits map[string]int64
+	// AttachBatchWindow is how long attachments to a node are collected to attach them at once.
+	AttachBatchWindow time.Duration
+	// DetachBatchWindow is how long detaches from a node are collected to detach them at once.
+	DetachBatchWindow time.Duration
+	// ForceDetachTimeout is how long detaches fail before volumes are force detached from nodes that are down.
+	ForceDetachTimeout time.Duration
+}
+
+func (s *ControllerOptions) AddFlags(fs *flag.FlagSet) {
+	fs.Var(cliflag.NewMapStringString(&s.ExtraTags), "extra-tags", "Extra tags to attach to each dynamically provisioned resource. It is a comma separated list of key value pairs like '<key1>=<value1>,<key2>=<value2>'")
+	//fs.Var(cliflag.NewMapStringString(&s.ExtraVolumeTags), "extra-volume-tags", "DEPRECATED: Please use --extra-tags instead. Extra volume tags to attach to each dynamically provisioned volume. It is a comma separated list of key value pairs like '<key1>=<value1>,<key2>=<value2>'")
+	fs.StringVar(&s.KubernetesClusterID, "k8s-tag-cluster-id", "", "ID of the Kubernetes cluster used for tagging provisioned PowerVS volumes (optional).")
+	fs.Func("cloud-instance-ids", "Comma separated cloud instance IDs of further PowerVS workspaces to manage volumes in, next to the workspace of the controller node. Volumes are placed by the "+driver.WorkspaceKey+" StorageClass parameter or the "+driver.WorkspaceTopologyKey+" topology", func(value string) error {
+		s.CloudInstanceIDs = append(s.CloudInstanceIDs, splitList(value)...)
+		return nil
+	})
+	fs.DurationVar(&s.TierMigrationInterval, "tier-migration-interval", 0, "Interval at which PVs annotated with powervs.csi.ibm.com/target-tier are reconciled to the requested storage tier. 0 disables the tier migration reconciler.")
+	fs.DurationVar(&s.TierMigrationTimeout, "tier-migration-timeout", driver.DefaultTierMigrationTimeout, "Time a tier migration may take before the PV is reported as Failed. 0 waits for the migration forever.")
+	fs.DurationVar(&s.TagReconcileInterval, "tag-reconcile-interval", 0, "Interval at which the volumes of the PVs of the driver are checked for the k8s-tag-cluster-id and extra-tags tags, missing tags are attached and tags of these keys with other values detached. Requires the "+string(driver.TagReconciliation)+" feature gate, 0 disables the tag reconciler.")
+	fs.BoolVar(&s.LeaderElection, "leader-election", false, "Run the background loops of the controller, like the tier migration reconciler, only on the replica holding the controller lease. Required when running more than one controller replica.")
+	fs.StringVar(&s.LeaderElectionNamespace, "leader-election-namespace", "", "Namespace of the controller lease, defaults to the namespace of the controller pod.")
+	fs.BoolVar(&s.LegacyVolumeHandles, "legacy-volume-handles", false, "Accept the ibmpowervs://<region>/<zone>/<cloud instance id>/<volume id> volume handles of PVs created before the CSI driver, next to the PowerVS volume IDs.")
+	fs.BoolVar(&s.Events, "events", false, "Emit warning events on the PVCs and PVs of volumes exceeding the attach limits of a node, throttled by PowerVS or in a failed state.")
+	fs.IntVar(&s.PollWorkers, "poll-workers", cloud.DefaultPollWorkers, "Number of workers polling long running PowerVS operations, like volume detaches, which bounds the concurrent API calls spent on polling.")
+	fs.IntVar(&s.PollQueueSize, "poll-queue-size", cloud.DefaultPollQueueSize, "Number of operations the poll workers accept at a time, further operations fail and are retried by the CO.")
+	fs.DurationVar(&s.AttachBatchWindow, "attach-batch-window", 0, "Time the controller collects the volumes published to a node before attaching them with a single PowerVS operation, e.g. 200ms for pods with many volumes. 0 attaches every volume on its own.")
+	fs.DurationVar(&s.DetachBatchWindow, "detach-batch-window", 0, "Time the controller collects the volumes unpublished from a node before detaching them together, e.g. 200ms to drain nodes with many volumes faster. 0 detaches every volume on its own.")
+	fs.DurationVar(&s.ForceDetachTimeout, "force-detach-timeout", 0, "Time the detaches of a volume from a node fail before the controller force detaches it, if the PowerVS instance of the node is powered off or in error. Instances in error are reset first. Lets stateful pods of a dead node fail over, 0 disables force detaching.")
+	fs.Func("tier-attach-limits", "Comma separated maximum numbers of volumes of a tier attached to a node, like 'tier0=16,tier1=64'. Attaching further volumes of the tier fails with ResourceExhausted.", func(value string) error {
+		if s.TierAttachLimits == nil {
+			s.TierAttachLimits = make(map[string]int64)
+		}
+		for _, item := range splitList(value) {
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("invalid tier attach limit %q, expected <tier>=<limit>", item)
+			}
+			limit, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid limit of tier %s: %v", kv[0], err)
+			}
+			s.TierAttachLimits[strings.ToLower(strings.TrimSpace(kv[0]))] = limit
+		}
+		return nil
+	})
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestControllerOptions(t *testing.T) {
+	testCases := []struct {
+		name  string
+		flag  string
+		found bool
+	}{
+		{
+			name:  "lookup desired flag",
+			flag:  "tier-migration-interval",
+			found: true,
+		},
+		{
+			name:  "lookup tier migration timeout flag",
+			flag:  "tier-migration-timeout",
+			found: true,
+		},
+		{
+			name:  "lookup force detach timeout flag",
+			flag:  "force-detach-timeout",
+			found: true,
+		},
+		{
+			name:  "lookup tag reconcile interval flag",
+			flag:  "tag-reconcile-interval",
+			found: true,
+		},
+		{
+			name:  "lookup leader election flag",
+			flag:  "leader-election",
+			found: true,
+		},
+		{
+			name:  "lookup legacy volume handles flag",
+			flag:  "legacy-volume-handles",
+			found: true,
+		},
+		{
+			name:  "lookup events flag",
+			flag:  "events",
+			found: true,
+		},
+		{
+			name:  "lookup poll workers flag",
+			flag:  "poll-workers",
+			found: true,
+		},
+		{
+			name:  "lookup attach batch window flag",
+			flag:  "attach-batch-window",
+			found: true,
+		},
+		{
+			name:  "lookup detach batch window flag",
+			flag:  "detach-batch-window",
+			found: true,
+		},
+		{
+			name:  "fail for non-desired flag",
+			flag:  "some-flag",
+			found: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		flagSet := flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+		controllerOptions := &ControllerOptions{}
+
+		t.Run(tc.name, func(t *testing.T) {
+			controllerOptions.AddFlags(flagSet)
+			flag := flagSet.Lookup(tc.flag)
+			found := flag != nil
+			if found != tc.found {
+				t.Fatalf("result not equal\ngot:\n%v\nexpected:\n%v", found, tc.found)
+			}
+		})
+	}
+}
+
+func TestControllerOptionsTierAttachLimits(t *testing.T) {
+	testCases := []struct {
+		name      string
+		args      []string
+		expLimits map[string]int64
+		expErr    bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:      "limits",
+			args:      []string{"--tier-attach-limits=tier0=16, Tier1=64"},
+			expLimits: map[string]int64{"tier0": 16, "tier1": 64},
+		},
+		{
+			name:   "missing limit",
+			args:   []string{"--tier-attach-limits=tier0"},
+			expErr: true,
+		},
+		{
+			name:   "invalid limit",
+			args:   []string{"--tier-attach-limits=tier0=many"},
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			flagSet := flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+			controllerOptions := &ControllerOptions{}
+			controllerOptions.AddFlags(flagSet)
+			err := flagSet.Parse(tc.args)
+			if (err != nil) != tc.expErr {
+				t.Fatalf("expected error %v, got %v", tc.expErr, err)
+			}
+			if !tc.expErr && !reflect.DeepEqual(controllerOptions.TierAttachLimits, tc.expLimits) {
+				t.Fatalf("expected limits %v, got %v", tc.expLimits, controllerOptions.TierAttachLimits)
+			}
+		})
+	}
+}
@@ -20,13 +20,39 @@ package options
 
 import (
 	"flag"
+	"time"
+
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver"
 )
 
 // NodeOptions contains options and configuration settings for the node service.
 type NodeOptions struct {
 	VolumeAttachLimit int64
+	// VolumeStats enables NodeGetVolumeStats.
+	VolumeStats bool
+	// VolumeStatsCacheTTL is how long the stats of a volume are cached.
+	VolumeStatsCacheTTL time.Duration
+	// FsckPolicy is how filesystems are checked before they are mounted.
+	FsckPolicy driver.FsckPolicy
+	// Ext4FormatOptions are the mkfs options of ext filesystems.
+	Ext4FormatOptions string
+	// StoragePool is the storage pool the node reports in its topology.
+	StoragePool string
+	// StaleDeviceCleanup removes the devices of detached volumes at startup.
+	StaleDeviceCleanup bool
 }
 
 func (o *NodeOptions) AddFlags(fs *flag.FlagSet) {
-	fs.Int64Var(&o.VolumeAttachLimit, "volume-attach-limit", -1, "Value for the maximum number of volumes attachable per node. If specified, the limit applies to all nodes. If not specified, the value is approximated from the instance type.")
+	fs.Int64Var(&o.VolumeAttachLimit, "volume-attach-limit", -1, "Value for the maximum number of volumes attachable per node. If specified, the limit applies to all nodes. If not specified, the value is derived from the storage adapter of the node, NPIV or vSCSI.")
+	fs.BoolVar(&o.VolumeStats, "volume-stats", true, "Report the capacity and usage of published volumes with NodeGetVolumeStats. When false the node doesn't advertise the GET_VOLUME_STATS capability and kubelet doesn't poll the volumes.")
+	fs.DurationVar(&o.VolumeStatsCacheTTL, "volume-stats-cache-ttl", 30*time.Second, "How long the stats of a volume returned by NodeGetVolumeStats are cached, so that kubelet doesn't statfs every volume of the node on each poll. 0 disables the cache.")
+	fs.StringVar(&o.Ext4FormatOptions, "ext4-format-options", driver.DefaultExt4FormatOptions, "Space separated mkfs options ext2, ext3 and ext4 filesystems are formatted with, unless the volume sets the "+driver.FormatOptionsKey+" StorageClass parameter. The defaults let the first mount of large volumes return before their inode tables are initialized. Empty formats with the defaults of mkfs.")
+	fs.StringVar(&o.StoragePool, "storage-pool", "", "PowerVS storage pool the node reports in its topology with the "+string(driver.StoragePoolTopology)+" feature gate, so that the volumes of the pool are only scheduled to the nodes with affinity to it. Empty reports no pool.")
+	fs.BoolVar(&o.StaleDeviceCleanup, "stale-device-cleanup", false, "Unmount the volumes no longer attached to the node and remove their multipath devices when the node starts, requires the "+string(driver.Multipath)+" feature gate. They are left behind when volumes are detached while the node plugin is down.")
+	o.FsckPolicy = driver.FsckPolicyNone
+	fs.Func("fsck-policy", "How the existing filesystem of a volume is checked before it is mounted, unless the volume sets the "+driver.FsckPolicyKey+" StorageClass parameter: none mounts it without check, warn checks it read-only and mounts it even with errors, fail doesn't mount it with errors and repair repairs it, failing if it can't. (default none)", func(value string) error {
+		policy, err := driver.ParseFsckPolicy(value)
+		o.FsckPolicy = policy
+		return err
+	})
 }
@@ -29,6 +29,36 @@ func TestNodeOptions(t *testing.T) {
 			flag:  "volume-attach-limit",
 			found: true,
 		},
+		{
+			name:  "lookup volume stats flag",
+			flag:  "volume-stats",
+			found: true,
+		},
+		{
+			name:  "lookup volume stats cache ttl flag",
+			flag:  "volume-stats-cache-ttl",
+			found: true,
+		},
+		{
+			name:  "lookup fsck policy flag",
+			flag:  "fsck-policy",
+			found: true,
+		},
+		{
+			name:  "lookup ext4 format options flag",
+			flag:  "ext4-format-options",
+			found: true,
+		},
+		{
+			name:  "lookup storage pool flag",
+			flag:  "storage-pool",
+			found: true,
+		},
+		{
+			name:  "lookup stale device cleanup flag",
+			flag:  "stale-device-cleanup",
+			found: true,
+		},
 		{
 			name:  "fail for non-desired flag",
 			flag:  "some-flag",
@@ -20,19 +20,178 @@ package options
 
 import (
 	"flag"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver"
 )
 
 // ServerOptions contains options and configuration settings for the driver server.
 type ServerOptions struct {
+	// ConfigFile is a YAML or JSON file with further options, see ConfigFile.
+	ConfigFile string
 	// Endpoint is the endpoint that the driver server should listen on.
 	Endpoint string
+	// TLSCertFile and TLSKeyFile enable TLS on a tcp endpoint, TLSClientCAFile requires
+	// client certificates signed by one of its CAs.
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+	// HTTPEndpoint is the TCP address the metrics and health probes are served on, empty disables it.
+	HTTPEndpoint string
+	// Socket sets the permissions and ownership of a unix endpoint.
+	Socket driver.SocketOptions
+	// GRPCServer tunes the keepalive, concurrency and connection limits of the gRPC server.
+	GRPCServer driver.GRPCServerOptions
+	// EnableTracing exports OpenTelemetry spans to the OTLP collector set in the environment.
+	EnableTracing bool
+	// RequestLogLevel is the log verbosity of the CSI request and response log lines.
+	RequestLogLevel int
+	// ShutdownTimeout is how long in-flight CSI requests may take to complete on SIGTERM.
+	ShutdownTimeout time.Duration
+	// SlowOperationThreshold is the duration above which CSI requests and cloud calls are logged as slow.
+	SlowOperationThreshold time.Duration
+	// VolumeLockTimeout is how long a CSI request waits for the operation in progress on its volume.
+	VolumeLockTimeout time.Duration
 	// Debug
 	Debug bool
+	// APIEndpoints are the PowerVS API endpoints the cloud client fails over between.
+	APIEndpoints []string
+	// IAMEndpoint, ResourceControllerEndpoint and GlobalTaggingEndpoint override the public
+	// endpoints of these IBM Cloud services, e.g. with private endpoints.
+	IAMEndpoint                string
+	ResourceControllerEndpoint string
+	GlobalTaggingEndpoint      string
+	// APIKeyFile is a file holding the IBM Cloud API key, reloaded when it changes.
+	APIKeyFile string
+	// AuthType is how the driver authenticates with IBM Cloud.
+	AuthType string
+	// TrustedProfileID and TrustedProfileName select the trusted profile to authenticate with.
+	TrustedProfileID   string
+	TrustedProfileName string
+	// CRTokenFile is the compute resource token exchanged for a trusted profile token.
+	CRTokenFile string
+	// VolumeStateTimeout is how long to wait for a volume to become available or in-use.
+	VolumeStateTimeout time.Duration
+	// VolumeStatePollInterval is the interval at which volume states are polled.
+	VolumeStatePollInterval time.Duration
+	// CreateTimeout, DeleteTimeout, AttachTimeout, DetachTimeout and ExpandTimeout bound the
+	// PowerVS jobs of these operations, 0 keeps the volume state timeout.
+	CreateTimeout time.Duration
+	DeleteTimeout time.Duration
+	AttachTimeout time.Duration
+	DetachTimeout time.Duration
+	ExpandTimeout time.Duration
+	// APIRetryInitialDelay is the delay before the first retry of a failed PowerVS API call.
+	APIRetryInitialDelay time.Duration
+	// APIRetrySteps is the maximum number of attempts of a failed PowerVS API call.
+	APIRetrySteps int
+	// CloudAPITimeout bounds every PowerVS and IAM HTTP request, 0 keeps the defaults for reads and changes.
+	CloudAPITimeout time.Duration
+	// CloudProvider is the cloud the volumes are managed in, "fake" for in-memory workspaces.
+	CloudProvider string
+	// FakeCloudLatency is the duration of every call of the fake cloud.
+	FakeCloudLatency time.Duration
+	// FeatureGates enable or disable features of the driver.
+	FeatureGates driver.FeatureGates
+}
+
+// OperationTimeouts returns the timeouts of the volume operations
+func (s *ServerOptions) OperationTimeouts() map[cloud.Operation]time.Duration {
+	return map[cloud.Operation]time.Duration{
+		cloud.OperationCreate: s.CreateTimeout,
+		cloud.OperationDelete: s.DeleteTimeout,
+		cloud.OperationAttach: s.AttachTimeout,
+		cloud.OperationDetach: s.DetachTimeout,
+		cloud.OperationExpand: s.ExpandTimeout,
+	}
 }
 
 func (s *ServerOptions) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.ConfigFile, "config", "", "YAML or JSON file mapping option names to their values, options given as flags take precedence. The "+strings.Join(ReloadableFlags, ", ")+" options are reloaded on SIGHUP or when the file changes")
 	fs.StringVar(&s.Endpoint, "endpoint", driver.DefaultCSIEndpoint, "Endpoint for the CSI driver server")
-	fs.BoolVar(&s.Debug, "debug", false, "Debug option PowerVS client(Prints API requests and replies)")
+	fs.StringVar(&s.TLSCertFile, "tls-cert-file", "", "Server certificate of the gRPC endpoint, enables TLS on a tcp:// endpoint. The file is reloaded when it changes")
+	fs.StringVar(&s.TLSKeyFile, "tls-key-file", "", "Private key of the tls-cert-file")
+	fs.StringVar(&s.TLSClientCAFile, "tls-client-ca-file", "", "CA bundle verifying client certificates, enables mutual TLS")
+	fs.StringVar(&s.HTTPEndpoint, "http-endpoint", "", "The TCP network address where the HTTP server for metrics and the /healthz and /readyz probes will listen (example: `:8080`). The default is empty string, which means the server is disabled.")
+	fs.Func("socket-mode", "Octal permission bits of the unix socket of the endpoint (example: `0660`), the umask applies when unset", func(value string) error {
+		mode, err := strconv.ParseUint(value, 8, 32)
+		s.Socket.Mode = os.FileMode(mode)
+		return err
+	})
+	fs.StringVar(&s.Socket.User, "socket-user", "", "Name or ID of the user owning the unix socket of the endpoint")
+	fs.StringVar(&s.Socket.Group, "socket-group", "", "Name or ID of the group owning the unix socket of the endpoint, for sidecars connecting with a supplemental group")
+	fs.Func("grpc-max-concurrent-streams", "Maximum number of concurrent RPCs of a client connection, 0 keeps the gRPC default", func(value string) error {
+		n, err := strconv.ParseUint(value, 10, 32)
+		s.GRPCServer.MaxConcurrentStreams = uint32(n)
+		return err
+	})
+	fs.DurationVar(&s.GRPCServer.KeepaliveTime, "grpc-keepalive-time", 0, "Idle time after which the gRPC server pings a client to check the connection, 0 keeps the gRPC default of 2h")
+	fs.DurationVar(&s.GRPCServer.KeepaliveTimeout, "grpc-keepalive-timeout", 0, "Time the gRPC server waits for the ack of a keepalive ping before closing the connection, 0 keeps the gRPC default of 20s")
+	fs.DurationVar(&s.GRPCServer.KeepaliveMinTime, "grpc-keepalive-min-time", 0, "Minimum interval between the keepalive pings of a client, clients pinging more often are disconnected. 0 keeps the gRPC default of 5m")
+	fs.BoolVar(&s.GRPCServer.KeepalivePermitWithoutStream, "grpc-keepalive-permit-without-stream", false, "Allow keepalive pings of client connections without RPCs in progress")
+	fs.DurationVar(&s.GRPCServer.MaxConnectionIdle, "grpc-max-connection-idle", 0, "Close client connections without RPCs for that long, 0 keeps them open")
+	fs.DurationVar(&s.GRPCServer.ConnectionTimeout, "grpc-connection-timeout", 0, "Timeout of the handshake of new client connections, 0 keeps the gRPC default of 120s")
+	fs.BoolVar(&s.EnableTracing, "enable-tracing", false, "Export OpenTelemetry spans of CSI requests, PowerVS API calls and node mount steps over OTLP/gRPC to the collector configured by the standard OTEL_EXPORTER_OTLP_* environment variables")
+	fs.IntVar(&s.RequestLogLevel, "request-log-level", driver.DefaultRequestLogLevel, "Log verbosity (-v) at which CSI requests and responses are logged with their request ID, failed requests are always logged")
+	fs.DurationVar(&s.ShutdownTimeout, "shutdown-timeout", driver.DefaultShutdownTimeout, "Time in-flight CSI requests get to complete on SIGTERM before they are canceled, new requests are refused meanwhile. Keep it below the termination grace period of the pod")
+	fs.DurationVar(&s.SlowOperationThreshold, "slow-operation-threshold", driver.DefaultSlowOperationThreshold, "CSI requests and PowerVS calls taking longer are logged with the time spent per phase and counted in the powervs_csi_slow_operations_total metric, 0 disables it")
+	fs.DurationVar(&s.VolumeLockTimeout, "volume-lock-timeout", driver.DefaultVolumeLockTimeout, "Time a CSI request waits for the operation in progress on its volume before it's aborted with a retry hint, 0 aborts it right away")
+	fs.BoolVar(&s.Debug, "debug", false, "Log every PowerVS API request and reply with its status, duration and redacted bodies")
+	s.APIEndpoints = splitList(os.Getenv(cloud.PowerVSEndpointEnv))
+	fromEnv := len(s.APIEndpoints) > 0
+	fs.Func("api-endpoints", "Comma separated list of PowerVS API endpoints to fail over between, in order of preference. Defaults to $"+cloud.PowerVSEndpointEnv+" or the regional endpoint of the cloud instance", func(value string) error {
+		if fromEnv {
+			s.APIEndpoints, fromEnv = nil, false
+		}
+		s.APIEndpoints = append(s.APIEndpoints, splitList(value)...)
+		return nil
+	})
+	fs.StringVar(&s.IAMEndpoint, "iam-endpoint", os.Getenv(cloud.IAMEndpointEnv), "IAM endpoint, e.g. https://private.iam.cloud.ibm.com. Defaults to $"+cloud.IAMEndpointEnv+" or "+cloud.DefaultIAMEndpoint)
+	fs.StringVar(&s.ResourceControllerEndpoint, "resource-controller-endpoint", os.Getenv(cloud.ResourceControllerEndpointEnv), "Resource controller endpoint, e.g. https://private.resource-controller.cloud.ibm.com. Defaults to $"+cloud.ResourceControllerEndpointEnv+" or the public endpoint")
+	fs.StringVar(&s.GlobalTaggingEndpoint, "global-tagging-endpoint", os.Getenv(cloud.GlobalTaggingEndpointEnv), "Global tagging endpoint, e.g. https://tags.private.global-search-tagging.cloud.ibm.com. Defaults to $"+cloud.GlobalTaggingEndpointEnv+" or the public endpoint")
+	fs.StringVar(&s.APIKeyFile, "api-key-file", "", "File holding the IBM Cloud API key, e.g. a mounted secret. The file is watched and the key reloaded when it's rotated. Defaults to the "+cloud.APIKeyEnv+" environment variable")
+	fs.StringVar(&s.AuthType, "auth-type", cloud.AuthTypeAPIKey, "Authentication with IBM Cloud, one of: "+strings.Join(cloud.AuthTypes, ", "))
+	fs.StringVar(&s.TrustedProfileID, "trusted-profile-id", "", "ID of the trusted profile to authenticate with when auth-type is "+cloud.AuthTypeTrustedProfile)
+	fs.StringVar(&s.TrustedProfileName, "trusted-profile-name", "", "Name of the trusted profile to authenticate with when auth-type is "+cloud.AuthTypeTrustedProfile)
+	fs.StringVar(&s.CRTokenFile, "cr-token-file", "", "Compute resource token of the pod exchanged for a trusted profile token. Defaults to /var/run/secrets/tokens/vault-token")
+	fs.DurationVar(&s.VolumeStateTimeout, "volume-state-timeout", cloud.PollTimeout, "Timeout waiting for a volume to reach the expected state after create, attach and detach")
+	fs.DurationVar(&s.VolumeStatePollInterval, "volume-state-poll-interval", cloud.PollInterval, "Interval at which volume states are polled while waiting")
+	fs.DurationVar(&s.CreateTimeout, "create-timeout", 0, "Timeout of creating a volume, including the retries of the PowerVS calls and waiting for it to become available. 0 waits for the volume-state-timeout")
+	fs.DurationVar(&s.DeleteTimeout, "delete-timeout", 0, "Timeout of deleting a volume, including the retries of the PowerVS calls. 0 retries as long as the api-retry-steps allow")
+	fs.DurationVar(&s.AttachTimeout, "attach-timeout", 0, "Timeout of attaching volumes, including the retries of the PowerVS calls and waiting for them to be in-use. 0 waits for the volume-state-timeout")
+	fs.DurationVar(&s.DetachTimeout, "detach-timeout", 0, "Timeout of detaching volumes, including the retries of the PowerVS calls and waiting for their detach. 0 waits for the volume-state-timeout")
+	fs.DurationVar(&s.ExpandTimeout, "expand-timeout", 0, "Timeout of expanding a volume, including the retries of the PowerVS calls and waiting for the resize. 0 waits for the volume-state-timeout")
+	fs.DurationVar(&s.APIRetryInitialDelay, "api-retry-initial-delay", cloud.DefaultBackoff.Duration, "Delay before retrying a throttled or failed PowerVS API call, doubled after every attempt")
+	fs.IntVar(&s.APIRetrySteps, "api-retry-steps", cloud.DefaultBackoff.Steps, "Maximum number of attempts of a throttled or failed PowerVS API call")
+	fs.DurationVar(&s.CloudAPITimeout, "cloud-api-timeout", 0, "Timeout of a single PowerVS or IAM HTTP request, a timed out request is retried like a connection error. Defaults to "+cloud.DefaultFastCallTimeout.String()+" for reads and IAM tokens and "+cloud.DefaultSlowCallTimeout.String()+" for requests changing resources, like creating or attaching volumes")
+	fs.StringVar(&s.CloudProvider, "cloud-provider", driver.CloudProviderPowerVS, "Cloud the volumes are managed in, one of: "+strings.Join(driver.CloudProviders, ", ")+". "+driver.CloudProviderFake+" keeps volumes in memory without PowerVS credentials, to try out manifests, sidecars and scheduling in dev clusters. The node plugin can't stage fake volumes")
+	fs.Func("feature-gates", "Comma separated <feature>=<true|false> pairs enabling or disabling features of the driver. Known features:\n"+strings.Join(driver.KnownFeatures(), "\n"), func(value string) error {
+		gates, err := driver.ParseFeatureGates(value)
+		if err != nil {
+			return err
+		}
+		if s.FeatureGates == nil {
+			s.FeatureGates = driver.FeatureGates{}
+		}
+		for feature, enabled := range gates {
+			s.FeatureGates[feature] = enabled
+		}
+		return nil
+	})
+	fs.DurationVar(&s.FakeCloudLatency, "fake-cloud-latency", 0, "Duration of every call of the "+driver.CloudProviderFake+" cloud provider, to simulate the latency of PowerVS")
+}
+
+// splitList splits a comma separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
@@ -15,7 +15,13 @@ package options
 
 import (
 	"flag"
+	"io/ioutil"
+	"reflect"
 	"testing"
+	"time"
+
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver"
 )
 
 func TestServerOptions(t *testing.T) {
@@ -29,6 +35,56 @@ func TestServerOptions(t *testing.T) {
 			flag:  "endpoint",
 			found: true,
 		},
+		{
+			name:  "lookup create-timeout",
+			flag:  "create-timeout",
+			found: true,
+		},
+		{
+			name:  "lookup expand-timeout",
+			flag:  "expand-timeout",
+			found: true,
+		},
+		{
+			name:  "lookup cloud-api-timeout",
+			flag:  "cloud-api-timeout",
+			found: true,
+		},
+		{
+			name:  "lookup cloud-provider",
+			flag:  "cloud-provider",
+			found: true,
+		},
+		{
+			name:  "lookup fake-cloud-latency",
+			flag:  "fake-cloud-latency",
+			found: true,
+		},
+		{
+			name:  "lookup grpc-max-concurrent-streams",
+			flag:  "grpc-max-concurrent-streams",
+			found: true,
+		},
+		{
+			name:  "lookup grpc-keepalive-min-time",
+			flag:  "grpc-keepalive-min-time",
+			found: true,
+		},
+		{
+			name:  "lookup feature-gates",
+			flag:  "feature-gates",
+			found: true,
+		},
+		{
+			name:  "lookup desired socket-mode",
+			flag:  "socket-mode",
+			found: true,
+		},
+		{
+			name:  "lookup desired socket-group",
+			flag:  "socket-group",
+			found: true,
+		},
 		{
 			name:  "fail for non-desired flag",
 			flag:  "some-other-flag",
@@ -51,3 +107,141 @@ func TestServerOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestServerOptionsEndpoints(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		env                  map[string]string
+		args                 []string
+		expectedAPIEndpoints []string
+		expectedIAMEndpoint  string
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name: "from env",
+			env: map[string]string{
+				cloud.PowerVSEndpointEnv: "private.us-south.power-iaas.cloud.ibm.com",
+				cloud.IAMEndpointEnv:     "https://private.iam.cloud.ibm.com",
+			},
+			expectedAPIEndpoints: []string{"private.us-south.power-iaas.cloud.ibm.com"},
+			expectedIAMEndpoint:  "https://private.iam.cloud.ibm.com",
+		},
+		{
+			name: "flags override env",
+			env: map[string]string{
+				cloud.PowerVSEndpointEnv: "private.us-south.power-iaas.cloud.ibm.com",
+				cloud.IAMEndpointEnv:     "https://private.iam.cloud.ibm.com",
+			},
+			args:                 []string{"--api-endpoints=private.dal.power-iaas.cloud.ibm.com, private.wdc.power-iaas.cloud.ibm.com", "--iam-endpoint=https://iam.private.example.com"},
+			expectedAPIEndpoints: []string{"private.dal.power-iaas.cloud.ibm.com", "private.wdc.power-iaas.cloud.ibm.com"},
+			expectedIAMEndpoint:  "https://iam.private.example.com",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, env := range []string{cloud.PowerVSEndpointEnv, cloud.IAMEndpointEnv} {
+				t.Setenv(env, tc.env[env])
+			}
+			flagSet := flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+			serverOptions := &ServerOptions{}
+			serverOptions.AddFlags(flagSet)
+			if err := flagSet.Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(serverOptions.APIEndpoints, tc.expectedAPIEndpoints) {
+				t.Fatalf("expected API endpoints %v, got %v", tc.expectedAPIEndpoints, serverOptions.APIEndpoints)
+			}
+			if serverOptions.IAMEndpoint != tc.expectedIAMEndpoint {
+				t.Fatalf("expected IAM endpoint %q, got %q", tc.expectedIAMEndpoint, serverOptions.IAMEndpoint)
+			}
+		})
+	}
+}
+
+func TestServerOptionsSocket(t *testing.T) {
+	flagSet := flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+	serverOptions := &ServerOptions{}
+	serverOptions.AddFlags(flagSet)
+	if err := flagSet.Parse([]string{"--socket-mode=0660", "--socket-group=csi"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := driver.SocketOptions{Mode: 0660, Group: "csi"}
+	if serverOptions.Socket != expected {
+		t.Fatalf("expected socket options %+v, got %+v", expected, serverOptions.Socket)
+	}
+
+	flagSet = flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+	flagSet.SetOutput(ioutil.Discard)
+	(&ServerOptions{}).AddFlags(flagSet)
+	if err := flagSet.Parse([]string{"--socket-mode=rw"}); err == nil {
+		t.Fatalf("expected an error for a socket mode that isn't octal")
+	}
+}
+
+func TestServerOptionsOperationTimeouts(t *testing.T) {
+	flagSet := flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+	serverOptions := &ServerOptions{}
+	serverOptions.AddFlags(flagSet)
+	if err := flagSet.Parse([]string{"--create-timeout=10m", "--detach-timeout=3m"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[cloud.Operation]time.Duration{
+		cloud.OperationCreate: 10 * time.Minute,
+		cloud.OperationDelete: 0,
+		cloud.OperationAttach: 0,
+		cloud.OperationDetach: 3 * time.Minute,
+		cloud.OperationExpand: 0,
+	}
+	if timeouts := serverOptions.OperationTimeouts(); !reflect.DeepEqual(timeouts, expected) {
+		t.Fatalf("expected operation timeouts %v, got %v", expected, timeouts)
+	}
+}
+
+func TestServerOptionsGRPCServer(t *testing.T) {
+	flagSet := flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+	serverOptions := &ServerOptions{}
+	serverOptions.AddFlags(flagSet)
+	args := []string{"--grpc-max-concurrent-streams=200", "--grpc-keepalive-time=1m", "--grpc-keepalive-min-time=10s", "--grpc-keepalive-permit-without-stream"}
+	if err := flagSet.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	expected := driver.GRPCServerOptions{
+		MaxConcurrentStreams:         200,
+		KeepaliveTime:                time.Minute,
+		KeepaliveMinTime:             10 * time.Second,
+		KeepalivePermitWithoutStream: true,
+	}
+	if serverOptions.GRPCServer != expected {
+		t.Fatalf("expected gRPC server options %+v, got %+v", expected, serverOptions.GRPCServer)
+	}
+
+	flagSet = flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+	flagSet.SetOutput(ioutil.Discard)
+	(&ServerOptions{}).AddFlags(flagSet)
+	if err := flagSet.Parse([]string{"--grpc-max-concurrent-streams=4294967296"}); err == nil {
+		t.Fatalf("expected an error for a number of streams above uint32")
+	}
+}
+
+func TestServerOptionsFeatureGates(t *testing.T) {
+	flagSet := flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+	serverOptions := &ServerOptions{}
+	serverOptions.AddFlags(flagSet)
+	if err := flagSet.Parse([]string{"--feature-gates=Multipath=false", "--feature-gates=TierMigration=false,Multipath=true"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := driver.FeatureGates{driver.Multipath: true, driver.TierMigration: false}
+	if !reflect.DeepEqual(serverOptions.FeatureGates, expected) {
+		t.Fatalf("expected feature gates %v, got %v", expected, serverOptions.FeatureGates)
+	}
+
+	flagSet = flag.NewFlagSet("test-flagset", flag.ContinueOnError)
+	flagSet.SetOutput(ioutil.Discard)
+	(&ServerOptions{}).AddFlags(flagSet)
+	if err := flagSet.Parse([]string{"--feature-gates=Snapshots=true"}); err == nil {
+		t.Fatalf("expected an error for an unknown feature gate")
+	}
+}
@@ -0,0 +1,412 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+// volumesCommand is the subcommand auditing the PowerVS volumes of a cluster
+const volumesCommand = "volumes"
+
+// problems of the volumes reported by the volumes subcommand
+const (
+	// problemOrphaned is a volume of the cluster no PV references
+	problemOrphaned = "orphaned"
+	// problemMissing is a PV whose volume doesn't exist
+	problemMissing = "missing"
+	// problemCapacity is a volume whose size differs from the capacity of its PV
+	problemCapacity = "capacity-mismatch"
+	// problemFailed is a volume PowerVS reports in the error state
+	problemFailed = "failed"
+)
+
+// newVolumesCloud creates the PowerVS client of the volumes subcommand, replaced in tests
+var newVolumesCloud = cloud.NewPowerVSCloud
+
+// volumesOptions are the flags of the volumes subcommand
+type volumesOptions struct {
+	cloudInstanceID string
+	clusterID       string
+	pvFiles         []string
+	output          string
+	apiKeyFile      string
+	debug           bool
+
+	// options of the PVs generated by adopt
+	storageClass   string
+	fsType         string
+	volumeMode     string
+	reclaimPolicy  string
+	multiWorkspace bool
+	preFormatted   bool
+}
+
+// volumeReport is a volume listed by the volumes subcommand, the volume ID of a PV whose
+// volume is missing is the one of its handle
+type volumeReport struct {
+	VolumeID    string   `json:"volumeID"`
+	Name        string   `json:"name,omitempty"`
+	Tier        string   `json:"tier,omitempty"`
+	CapacityGiB int64    `json:"capacityGiB"`
+	State       string   `json:"state,omitempty"`
+	AttachedTo  []string `json:"attachedTo,omitempty"`
+	PV          string   `json:"pv,omitempty"`
+	Claim       string   `json:"claim,omitempty"`
+	Problem     string   `json:"problem,omitempty"`
+}
+
+// tierUsage is the storage used by the volumes of a tier
+type tierUsage struct {
+	Tier            string `json:"tier"`
+	Volumes         int    `json:"volumes"`
+	CapacityGiB     int64  `json:"capacityGiB"`
+	OrphanedVolumes int    `json:"orphanedVolumes"`
+	OrphanedGiB     int64  `json:"orphanedGiB"`
+}
+
+const volumesUsage = `Usage: %[1]s volumes list|orphans|usage [flags]
+       %[1]s volumes adopt [flags] <volume id>...
+
+  list     lists the volumes of the cluster and the PVs referencing them
+  orphans  lists the volumes no PV references, the PVs whose volume is missing and the
+           volumes mismatching their PV, it requires --pvs
+  usage    sums up the capacity of the volumes per tier
+  adopt    prints static PVs of existing volumes
+
+PV lists are the output of 'kubectl get pv -o json'.
+
+`
+
+// runVolumes runs the volumes subcommand with args, the arguments following it
+func runVolumes(ctx context.Context, args []string, stdin io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet(volumesCommand, flag.ContinueOnError)
+	o := volumesOptions{}
+	fs.StringVar(&o.cloudInstanceID, "cloud-instance-id", "", "Cloud instance ID of the PowerVS workspace of the volumes.")
+	fs.StringVar(&o.clusterID, "cluster-id", "", "Only audit the volumes tagged with the --k8s-tag-cluster-id of the cluster, next to the ones referenced by PVs.")
+	fs.Func("pvs", "File with the PV list of a cluster, - reads it from stdin. Repeat the flag for the PVs of several clusters.", func(value string) error {
+		o.pvFiles = append(o.pvFiles, value)
+		return nil
+	})
+	fs.StringVar(&o.output, "output", "", "Output format, table or json, yaml or json for adopt. Defaults to table, yaml for adopt.")
+	fs.StringVar(&o.apiKeyFile, "api-key-file", "", "File holding the IBM Cloud API key, defaults to the "+cloud.APIKeyEnv+" environment variable.")
+	fs.BoolVar(&o.debug, "debug", false, "Log the PowerVS API calls.")
+	fs.StringVar(&o.storageClass, "storage-class", "", "StorageClass of the adopted PVs, PVCs bind them by it.")
+	fs.StringVar(&o.fsType, "fs-type", "ext4", "Filesystem of the adopted volumes of volume mode Filesystem.")
+	fs.StringVar(&o.volumeMode, "volume-mode", string(v1.PersistentVolumeFilesystem), "Volume mode of the adopted PVs, Filesystem or Block.")
+	fs.StringVar(&o.reclaimPolicy, "reclaim-policy", string(v1.PersistentVolumeReclaimRetain), "Reclaim policy of the adopted PVs, Retain or Delete. Delete deletes the volume with its PV.")
+	fs.BoolVar(&o.multiWorkspace, "multi-workspace", false, "Generate the volume handles and workspace topology of a controller managing several workspaces with --cloud-instance-ids.")
+	fs.BoolVar(&o.preFormatted, "pre-formatted", true, "Mark the filesystems of the adopted volumes as pre-formatted, they are never formatted and must match --fs-type.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), volumesUsage, os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fs.Usage()
+		return errors.New("no volumes command given")
+	}
+	command := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if command != "list" && command != "orphans" && command != "usage" && command != "adopt" {
+		return fmt.Errorf("unknown volumes command %q: expected list, orphans, usage or adopt", command)
+	}
+	if o.cloudInstanceID == "" {
+		return errors.New("--cloud-instance-id is required")
+	}
+	if command == "adopt" {
+		return runAdopt(ctx, &o, fs.Args(), out)
+	}
+	if o.output == "" {
+		o.output = "table"
+	}
+	if o.output != "table" && o.output != "json" {
+		return fmt.Errorf("invalid output %q: expected table or json", o.output)
+	}
+	if command == "orphans" && len(o.pvFiles) == 0 {
+		return errors.New("orphans requires the PVs of the cluster, see --pvs")
+	}
+
+	var pvs []v1.PersistentVolume
+	for _, file := range o.pvFiles {
+		list, err := readPVs(file, stdin)
+		if err != nil {
+			return err
+		}
+		pvs = append(pvs, list...)
+	}
+
+	c, err := o.newCloud()
+	if err != nil {
+		return err
+	}
+	reports, err := auditVolumes(ctx, c, o.cloudInstanceID, o.clusterID, pvs)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "orphans":
+		var problems []volumeReport
+		for _, r := range reports {
+			if r.Problem != "" {
+				problems = append(problems, r)
+			}
+		}
+		return printReports(out, o.output, problems)
+	case "usage":
+		return printUsage(out, o.output, volumeUsage(reports))
+	default:
+		return printReports(out, o.output, reports)
+	}
+}
+
+// newCloud returns the PowerVS client of the workspace of the options
+func (o *volumesOptions) newCloud() (cloud.Cloud, error) {
+	var cloudOptions []func(*cloud.Options)
+	if o.apiKeyFile != "" {
+		cloudOptions = append(cloudOptions, cloud.WithAPIKeyFile(o.apiKeyFile))
+	}
+	c, err := newVolumesCloud(o.cloudInstanceID, o.debug, cloudOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create PowerVS client: %v", err)
+	}
+	return c, nil
+}
+
+// readPVs reads the PV list file, - reads stdin
+func readPVs(file string, stdin io.Reader) ([]v1.PersistentVolume, error) {
+	r := stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("could not read PVs: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	var list v1.PersistentVolumeList
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return nil, fmt.Errorf("could not parse PVs of %s: %v", file, err)
+	}
+	return list.Items, nil
+}
+
+// pvVolumeID returns the PowerVS volume ID of the handle of a PV of the driver, false if the
+// handle is of another workspace than cloudInstanceID
+func pvVolumeID(handle, cloudInstanceID string) (string, bool) {
+	var id, volumeID string
+	if strings.HasPrefix(handle, cloud.ProviderIDPrefix) {
+		// ibmpowervs://<region>/<zone>/<cloud instance id>/<volume id> of pre-CSI PVs
+		parts := strings.Split(strings.TrimPrefix(handle, cloud.ProviderIDPrefix), "/")
+		if len(parts) != 4 {
+			return "", false
+		}
+		id, volumeID = parts[2], strings.ToLower(parts[3])
+	} else {
+		id, volumeID = cloud.SplitVolumeHandle(handle)
+	}
+	if id != "" && id != cloudInstanceID {
+		return "", false
+	}
+	return volumeID, true
+}
+
+// auditVolumes returns the volumes of the workspace cloudInstanceID tagged with clusterID, or all
+// of them without clusterID, and the volumes referenced by pvs. Without pvs no problems but
+// failed volumes are reported.
+func auditVolumes(ctx context.Context, c cloud.Cloud, cloudInstanceID, clusterID string, pvs []v1.PersistentVolume) ([]volumeReport, error) {
+	disks, err := c.ListDisks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list volumes: %v", err)
+	}
+
+	referenced := map[string]*v1.PersistentVolume{}
+	for i := range pvs {
+		pv := &pvs[i]
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != driver.DriverName {
+			continue
+		}
+		if volumeID, ok := pvVolumeID(pv.Spec.CSI.VolumeHandle, cloudInstanceID); ok {
+			referenced[volumeID] = pv
+		}
+	}
+
+	var reports []volumeReport
+	found := map[string]bool{}
+	for _, disk := range disks {
+		found[disk.VolumeID] = true
+		pv := referenced[disk.VolumeID]
+		if pv == nil && clusterID != "" {
+			tagged, err := hasClusterTag(ctx, c, disk.VolumeID, clusterID)
+			if err != nil {
+				return nil, err
+			}
+			if !tagged {
+				continue
+			}
+		}
+		r := volumeReport{
+			VolumeID:    disk.VolumeID,
+			Name:        disk.Name,
+			Tier:        disk.DiskType,
+			CapacityGiB: disk.CapacityGiB,
+			State:       disk.State,
+			AttachedTo:  disk.AttachedTo,
+		}
+		switch {
+		case disk.State == cloud.VolumeErrorState:
+			r.Problem = problemFailed
+		case pv == nil && len(pvs) > 0:
+			r.Problem = problemOrphaned
+		case pv != nil && pvCapacityGiB(pv) != disk.CapacityGiB:
+			r.Problem = problemCapacity
+		}
+		if pv != nil {
+			r.PV, r.Claim = pv.Name, pvClaim(pv)
+		}
+		reports = append(reports, r)
+	}
+
+	for volumeID, pv := range referenced {
+		if !found[volumeID] {
+			reports = append(reports, volumeReport{
+				VolumeID:    volumeID,
+				CapacityGiB: pvCapacityGiB(pv),
+				PV:          pv.Name,
+				Claim:       pvClaim(pv),
+				Problem:     problemMissing,
+			})
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Name != reports[j].Name {
+			return reports[i].Name < reports[j].Name
+		}
+		return reports[i].VolumeID < reports[j].VolumeID
+	})
+	return reports, nil
+}
+
+// hasClusterTag returns true if the volume is tagged with the cluster ID, IBM Cloud stores
+// tags lower cased
+func hasClusterTag(ctx context.Context, c cloud.Cloud, volumeID, clusterID string) (bool, error) {
+	lister, ok := c.(cloud.TagLister)
+	if !ok {
+		return false, errors.New("--cluster-id requires a client listing the tags of volumes")
+	}
+	tags, err := lister.GetDiskTags(ctx, volumeID)
+	if err != nil {
+		return false, fmt.Errorf("could not get tags of volume %s: %v", volumeID, err)
+	}
+	for _, tag := range tags {
+		if strings.EqualFold(tag, driver.ClusterIDTagKey+":"+clusterID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func pvCapacityGiB(pv *v1.PersistentVolume) int64 {
+	capacity := pv.Spec.Capacity[v1.ResourceStorage]
+	return util.BytesToGiB(capacity.Value())
+}
+
+func pvClaim(pv *v1.PersistentVolume) string {
+	if ref := pv.Spec.ClaimRef; ref != nil {
+		return ref.Namespace + "/" + ref.Name
+	}
+	return ""
+}
+
+// volumeUsage sums up the volumes of reports per tier, PVs with missing volumes use no storage
+func volumeUsage(reports []volumeReport) []tierUsage {
+	byTier := map[string]*tierUsage{}
+	var usage []tierUsage
+	for _, r := range reports {
+		if r.Problem == problemMissing {
+			continue
+		}
+		u, ok := byTier[r.Tier]
+		if !ok {
+			usage = append(usage, tierUsage{Tier: r.Tier})
+			u = &usage[len(usage)-1]
+			byTier[r.Tier] = u
+		}
+		u.Volumes++
+		u.CapacityGiB += r.CapacityGiB
+		if r.Problem == problemOrphaned {
+			u.OrphanedVolumes++
+			u.OrphanedGiB += r.CapacityGiB
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Tier < usage[j].Tier })
+	return usage
+}
+
+func printReports(out io.Writer, output string, reports []volumeReport) error {
+	if output == "json" {
+		if reports == nil {
+			reports = []volumeReport{}
+		}
+		return printJSON(out, reports)
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VOLUME ID\tNAME\tTIER\tSIZE (GiB)\tSTATE\tATTACHED TO\tPV\tCLAIM\tPROBLEM")
+	for _, r := range reports {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", r.VolumeID, r.Name, r.Tier, r.CapacityGiB, r.State, strings.Join(r.AttachedTo, ","), r.PV, r.Claim, r.Problem)
+	}
+	return w.Flush()
+}
+
+func printUsage(out io.Writer, output string, usage []tierUsage) error {
+	if output == "json" {
+		if usage == nil {
+			usage = []tierUsage{}
+		}
+		return printJSON(out, usage)
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIER\tVOLUMES\tSIZE (GiB)\tORPHANED\tORPHANED SIZE (GiB)")
+	for _, u := range usage {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n", u.Tier, u.Volumes, u.CapacityGiB, u.OrphanedVolumes, u.OrphanedGiB)
+	}
+	return w.Flush()
+}
+
+func printJSON(out io.Writer, v interface{}) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
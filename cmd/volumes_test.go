@@ -0,0 +1,219 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	mocks "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/mocks"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver"
+)
+
+// taggedCloud lists the tags of volumes of a mock cloud
+type taggedCloud struct {
+	*mocks.MockCloud
+	tags map[string][]string
+}
+
+func (c *taggedCloud) GetDiskTags(ctx context.Context, volumeID string) ([]string, error) {
+	return c.tags[volumeID], nil
+}
+
+func newPV(name, handle, size string) v1.PersistentVolume {
+	return v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity:               v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			PersistentVolumeSource: v1.PersistentVolumeSource{CSI: &v1.CSIPersistentVolumeSource{Driver: driver.DriverName, VolumeHandle: handle}},
+			ClaimRef:               &v1.ObjectReference{Name: "claim-" + name, Namespace: "ns"},
+		},
+	}
+}
+
+func TestAuditVolumes(t *testing.T) {
+	disks := []*cloud.Disk{
+		{VolumeID: "vol-1", Name: "pvc-1", DiskType: "tier1", CapacityGiB: 10, State: cloud.VolumeAvailableState},
+		{VolumeID: "vol-2", Name: "pvc-2", DiskType: "tier1", CapacityGiB: 20, State: cloud.VolumeAvailableState},
+		{VolumeID: "vol-3", Name: "pvc-3", DiskType: "tier3", CapacityGiB: 30, State: cloud.VolumeErrorState},
+		{VolumeID: "vol-4", Name: "other", DiskType: "tier3", CapacityGiB: 40, State: cloud.VolumeAvailableState},
+	}
+	tags := map[string][]string{
+		"vol-1": {"kubernetes-cluster-id:cluster"},
+		"vol-2": {"kubernetes-cluster-id:cluster"},
+		"vol-3": {"kubernetes-cluster-id:cluster"},
+		"vol-4": {"kubernetes-cluster-id:other-cluster"},
+	}
+	pvs := []v1.PersistentVolume{
+		newPV("pv-1", "vol-1", "10Gi"),
+		newPV("pv-2", "ws/vol-2", "15Gi"),
+		newPV("pv-5", "ibmpowervs://region/zone/ws/VOL-5", "5Gi"),
+		newPV("pv-6", "other-ws/vol-4", "40Gi"),
+	}
+
+	testCases := []struct {
+		name      string
+		clusterID string
+		pvs       []v1.PersistentVolume
+		expected  map[string]string
+	}{
+		{
+			name:     "without PVs",
+			expected: map[string]string{"vol-1": "", "vol-2": "", "vol-3": "failed", "vol-4": ""},
+		},
+		{
+			name:     "with PVs",
+			pvs:      pvs,
+			expected: map[string]string{"vol-1": "", "vol-2": "capacity-mismatch", "vol-3": "failed", "vol-4": "orphaned", "vol-5": "missing"},
+		},
+		{
+			name:      "volumes of the cluster",
+			clusterID: "Cluster",
+			pvs:       pvs[:1],
+			expected:  map[string]string{"vol-1": "", "vol-2": "orphaned", "vol-3": "failed"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			mockCloud.EXPECT().ListDisks(gomock.Any()).Return(disks, nil)
+
+			reports, err := auditVolumes(context.Background(), &taggedCloud{MockCloud: mockCloud, tags: tags}, "ws", tc.clusterID, tc.pvs)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			problems := map[string]string{}
+			for _, r := range reports {
+				problems[r.VolumeID] = r.Problem
+			}
+			if !reflect.DeepEqual(problems, tc.expected) {
+				t.Fatalf("expected problems %v, got %v", tc.expected, problems)
+			}
+		})
+	}
+}
+
+func TestVolumeUsage(t *testing.T) {
+	usage := volumeUsage([]volumeReport{
+		{VolumeID: "vol-1", Tier: "tier3", CapacityGiB: 10},
+		{VolumeID: "vol-2", Tier: "tier1", CapacityGiB: 20, Problem: problemOrphaned},
+		{VolumeID: "vol-3", Tier: "tier1", CapacityGiB: 30},
+		{VolumeID: "vol-4", CapacityGiB: 40, Problem: problemMissing},
+	})
+	expected := []tierUsage{
+		{Tier: "tier1", Volumes: 2, CapacityGiB: 50, OrphanedVolumes: 1, OrphanedGiB: 20},
+		{Tier: "tier3", Volumes: 1, CapacityGiB: 10},
+	}
+	if !reflect.DeepEqual(usage, expected) {
+		t.Fatalf("expected usage %+v, got %+v", expected, usage)
+	}
+}
+
+func TestRunVolumes(t *testing.T) {
+	pvList, err := json.Marshal(v1.PersistentVolumeList{Items: []v1.PersistentVolume{newPV("pv-1", "vol-1", "10Gi")}})
+	if err != nil {
+		t.Fatalf("could not marshal PVs: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		args        []string
+		listDisks   bool
+		expectErr   bool
+		expectedOut []string
+	}{
+		{
+			name:        "list table",
+			args:        []string{"list", "--cloud-instance-id=ws"},
+			listDisks:   true,
+			expectedOut: []string{"VOLUME ID", "vol-1", "vol-2"},
+		},
+		{
+			name:        "orphans json",
+			args:        []string{"orphans", "--cloud-instance-id=ws", "--pvs=-", "--output=json"},
+			listDisks:   true,
+			expectedOut: []string{`"volumeID": "vol-2"`, `"problem": "orphaned"`},
+		},
+		{
+			name:        "usage",
+			args:        []string{"usage", "--cloud-instance-id=ws", "--pvs=-"},
+			listDisks:   true,
+			expectedOut: []string{"tier1", "30"},
+		},
+		{
+			name:      "orphans without PVs",
+			args:      []string{"orphans", "--cloud-instance-id=ws"},
+			expectErr: true,
+		},
+		{
+			name:      "without cloud instance ID",
+			args:      []string{"list"},
+			expectErr: true,
+		},
+		{
+			name:      "unknown command",
+			args:      []string{"delete", "--cloud-instance-id=ws"},
+			expectErr: true,
+		},
+		{
+			name:      "invalid output",
+			args:      []string{"list", "--cloud-instance-id=ws", "--output=yaml"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			if tc.listDisks {
+				mockCloud.EXPECT().ListDisks(gomock.Any()).Return([]*cloud.Disk{
+					{VolumeID: "vol-1", Name: "pvc-1", DiskType: "tier1", CapacityGiB: 10},
+					{VolumeID: "vol-2", Name: "pvc-2", DiskType: "tier1", CapacityGiB: 20},
+				}, nil)
+			}
+			oldNewVolumesCloud := newVolumesCloud
+			defer func() { newVolumesCloud = oldNewVolumesCloud }()
+			newVolumesCloud = func(cloudInstanceID string, debug bool, options ...func(*cloud.Options)) (cloud.Cloud, error) {
+				return mockCloud, nil
+			}
+
+			var out bytes.Buffer
+			err := runVolumes(context.Background(), tc.args, bytes.NewReader(pvList), &out)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.expectErr, err)
+			}
+			for _, s := range tc.expectedOut {
+				if !strings.Contains(out.String(), s) {
+					t.Fatalf("expected %q in output:\n%s", s, out.String())
+				}
+			}
+		})
+	}
+}
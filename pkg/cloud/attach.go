@@ -0,0 +1,62 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/IBM-Cloud/power-go-client/ibmpisession"
+	"github.com/IBM-Cloud/power-go-client/power/client/p_cloud_volumes"
+	"github.com/IBM-Cloud/power-go-client/power/models"
+)
+
+// BulkAttacher is implemented by clouds attaching several volumes to an instance with a single
+// request, which PowerVS runs as one instance operation instead of one per volume
+type BulkAttacher interface {
+	AttachDisks(ctx context.Context, volumeIDs []string, nodeID string) error
+}
+
+var _ BulkAttacher = &powerVSCloud{}
+
+// AttachDisks attaches the volumes volumeIDs to the pvm instance nodeID and waits until they
+// are in-use. ErrAlreadyExists is returned when one of them is already attached, to the
+// instance or another one, none of the volumes is attached then.
+func (p *powerVSCloud) AttachDisks(ctx context.Context, volumeIDs []string, nodeID string) error {
+	ctx, cancel := p.operationContext(ctx, OperationAttach)
+	defer cancel()
+	params := p_cloud_volumes.NewPcloudV2PvminstancesVolumesPostParamsWithTimeout(TIMEOUT).WithContext(ctx).
+		WithCloudInstanceID(p.cloudInstanceID).WithPvmInstanceID(nodeID).
+		WithBody(&models.VolumesAttach{VolumeIds: volumeIDs})
+	err := p.call(ctx, "AttachVolumes", IsRetryableError, func() error {
+		_, err := p.piSession.Power.PCloudVolumes.PcloudV2PvminstancesVolumesPost(params, ibmpisession.NewAuth(p.piSession, p.cloudInstanceID))
+		return err
+	})
+	if err != nil {
+		if HTTPStatusCode(err) == http.StatusConflict {
+			return fmt.Errorf("%w: %v", ErrAlreadyExists, err)
+		}
+		return err
+	}
+	for _, volumeID := range volumeIDs {
+		if err := p.waitForVolumeState(ctx, OperationAttach, volumeID, VolumeInUseState); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/metrics"
+)
+
+// ErrCircuitOpen is returned without calling PowerVS while the API is considered down
+var ErrCircuitOpen = errors.New("PowerVS API is unavailable, circuit breaker is open")
+
+const (
+	// DefaultBreakerThreshold is the number of consecutive failed calls opening the breaker
+	DefaultBreakerThreshold = 5
+	// DefaultBreakerProbeInterval is the interval at which an open breaker probes the API
+	DefaultBreakerProbeInterval = 30 * time.Second
+)
+
+// circuitBreaker fails calls fast after threshold consecutive outage errors, and probes the
+// API every probeInterval in the background until it recovers or the breaker is stopped
+type circuitBreaker struct {
+	// cloudInstanceID labels the breaker metric of the workspace
+	cloudInstanceID string
+	threshold       int
+	probeInterval   time.Duration
+	// probe is a cheap API call used to detect recovery
+	probe func() error
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func newCircuitBreaker(cloudInstanceID string, threshold int, probeInterval time.Duration, probe func() error) *circuitBreaker {
+	return &circuitBreaker{
+		cloudInstanceID: cloudInstanceID,
+		threshold:       threshold,
+		probeInterval:   probeInterval,
+		probe:           probe,
+		stopped:         make(chan struct{}),
+	}
+}
+
+// stop ends the probes of the breaker and removes its metric, for clients no longer used
+func (b *circuitBreaker) stop() {
+	b.stopOnce.Do(func() {
+		close(b.stopped)
+		metrics.CloudCircuitBreakerOpen.DeleteLabelValues(b.cloudInstanceID)
+	})
+}
+
+// allow returns ErrCircuitOpen while the breaker is open
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of a call
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !isOutageError(err) {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if !b.open && b.failures >= b.threshold {
+		klog.Errorf("PowerVS API failed %d consecutive times, opening circuit breaker: %v", b.failures, err)
+		b.open = true
+		metrics.CloudCircuitBreakerOpen.WithLabelValues(b.cloudInstanceID).Set(1)
+		go b.probeUntilRecovered()
+	}
+}
+
+func (b *circuitBreaker) probeUntilRecovered() {
+	for {
+		select {
+		case <-b.stopped:
+			return
+		case <-time.After(b.probeInterval):
+		}
+		err := b.probe()
+		if !isOutageError(err) {
+			b.mu.Lock()
+			b.open = false
+			b.failures = 0
+			b.mu.Unlock()
+			metrics.CloudCircuitBreakerOpen.WithLabelValues(b.cloudInstanceID).Set(0)
+			klog.Infof("PowerVS API recovered, closing circuit breaker")
+			return
+		}
+		klog.V(4).Infof("PowerVS API still unavailable: %v", err)
+	}
+}
+
+// isOutageError returns true for errors indicating PowerVS is down rather than rejecting
+// the call, throttling means the API is up
+func isOutageError(err error) bool {
+	return IsRetryableError(err) && !IsThrottlingError(err)
+}
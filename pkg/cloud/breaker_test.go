@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/runtime"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/metrics"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy int32
+	b := newCircuitBreaker("ws-1", 3, 5*time.Millisecond, func() error {
+		if atomic.LoadInt32(&healthy) == 1 {
+			return nil
+		}
+		return runtime.NewAPIError("op", nil, 503)
+	})
+
+	// throttling and client errors don't count as outage
+	b.record(runtime.NewAPIError("op", nil, 429))
+	b.record(runtime.NewAPIError("op", nil, 400))
+	b.record(errors.New("invalid volume"))
+	if err := b.allow(); err != nil {
+		t.Fatalf("expected breaker to be closed, got %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		b.record(runtime.NewAPIError("op", nil, 502))
+	}
+	if err := b.allow(); err != ErrCircuitOpen {
+		t.Fatalf("expected %v, got %v", ErrCircuitOpen, err)
+	}
+	if v := testutil.ToFloat64(metrics.CloudCircuitBreakerOpen.WithLabelValues("ws-1")); v != 1 {
+		t.Fatalf("expected breaker metric to be 1, got %v", v)
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	deadline := time.Now().Add(time.Second)
+	for b.allow() != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected breaker to close after the API recovered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if v := testutil.ToFloat64(metrics.CloudCircuitBreakerOpen.WithLabelValues("ws-1")); v != 0 {
+		t.Fatalf("expected breaker metric to be 0, got %v", v)
+	}
+}
+
+func TestCircuitBreakerStop(t *testing.T) {
+	var probes int32
+	b := newCircuitBreaker("ws-2", 1, time.Millisecond, func() error {
+		atomic.AddInt32(&probes, 1)
+		return runtime.NewAPIError("op", nil, 503)
+	})
+	b.record(runtime.NewAPIError("op", nil, 502))
+	if err := b.allow(); err != ErrCircuitOpen {
+		t.Fatalf("expected %v, got %v", ErrCircuitOpen, err)
+	}
+	if v := testutil.ToFloat64(metrics.CloudCircuitBreakerOpen.WithLabelValues("ws-2")); v != 1 {
+		t.Fatalf("expected breaker metric to be 1, got %v", v)
+	}
+
+	b.stop()
+	b.stop()
+	time.Sleep(5 * time.Millisecond)
+	stopped := atomic.LoadInt32(&probes)
+	time.Sleep(20 * time.Millisecond)
+	if p := atomic.LoadInt32(&probes); p != stopped {
+		t.Fatalf("expected no probes after the breaker stopped, got %d more", p-stopped)
+	}
+	if metrics.CloudCircuitBreakerOpen.DeleteLabelValues("ws-2") {
+		t.Fatal("expected the metric of the stopped breaker to be removed")
+	}
+}
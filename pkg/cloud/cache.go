@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long instance and image lookups are served from the cache
+var DefaultCacheTTL = 5 * time.Minute
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// ttlCache is a small thread safe key/value cache whose entries expire after ttl
+type ttlCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]cacheEntry
+}
+
+func newTTLCache(ttl time.Duration) *ttlCache {
+	return &ttlCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// Get returns the cached value for key, if present and not expired
+func (c *ttlCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+// Set caches value for key, a ttl <= 0 disables caching
+func (c *ttlCache) Set(key string, value interface{}) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{value: value, expires: c.now().Add(c.ttl)}
+}
+
+// Invalidate drops the entry for key
+func (c *ttlCache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// Flush drops all entries
+func (c *ttlCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cacheEntry)
+}
+
+// CacheInvalidator is implemented by Cloud providers that cache instance and image lookups,
+// callers that know a cached object changed use it to force a fresh lookup
+type CacheInvalidator interface {
+	InvalidatePVMInstance(instanceID string)
+	InvalidateImage(imageID string)
+}
+
+var _ CacheInvalidator = &powerVSCloud{}
+
+func (p *powerVSCloud) InvalidatePVMInstance(instanceID string) {
+	p.instanceCache.Invalidate(instanceID)
+}
+
+func (p *powerVSCloud) InvalidateImage(imageID string) {
+	p.imageCache.Invalidate(imageID)
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLCache(t *testing.T) {
+	now := time.Now()
+	c := newTTLCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("instance-1", PVMInstance{ID: "instance-1"})
+	if v, ok := c.Get("instance-1"); !ok || v.(PVMInstance).ID != "instance-1" {
+		t.Fatalf("expected cached instance, got %v, %v", v, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("instance-1"); ok {
+		t.Fatalf("expected entry to be expired")
+	}
+
+	c.Set("instance-2", PVMInstance{ID: "instance-2"})
+	c.Invalidate("instance-2")
+	if _, ok := c.Get("instance-2"); ok {
+		t.Fatalf("expected entry to be invalidated")
+	}
+
+	c.Set("instance-3", PVMInstance{ID: "instance-3"})
+	c.Flush()
+	if _, ok := c.Get("instance-3"); ok {
+		t.Fatalf("expected cache to be flushed")
+	}
+}
+
+func TestTTLCacheDisabled(t *testing.T) {
+	c := newTTLCache(0)
+	c.Set("image-1", PVMImage{ID: "image-1"})
+	if _, ok := c.Get("image-1"); ok {
+		t.Fatalf("expected caching to be disabled")
+	}
+}
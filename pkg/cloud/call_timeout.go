@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	gohttp "net/http"
+	"time"
+)
+
+// default timeouts of a single PowerVS or IAM HTTP request
+const (
+	// DefaultFastCallTimeout applies to reads and IAM token requests
+	DefaultFastCallTimeout = 30 * time.Second
+	// DefaultSlowCallTimeout applies to requests changing resources, like creating or attaching volumes
+	DefaultSlowCallTimeout = 2 * time.Minute
+)
+
+// callTimeouts are the deadlines of single HTTP requests, fast for reads and slow for changes
+type callTimeouts struct {
+	fast time.Duration
+	slow time.Duration
+}
+
+// newCallTimeouts returns timeout for all requests, or the default timeouts if timeout is 0
+func newCallTimeouts(timeout time.Duration) callTimeouts {
+	if timeout > 0 {
+		return callTimeouts{fast: timeout, slow: timeout}
+	}
+	return callTimeouts{fast: DefaultFastCallTimeout, slow: DefaultSlowCallTimeout}
+}
+
+func (t callTimeouts) forRequest(req *gohttp.Request) time.Duration {
+	switch req.Method {
+	case gohttp.MethodGet, gohttp.MethodHead:
+		return t.fast
+	default:
+		return t.slow
+	}
+}
+
+// iamClient returns the HTTP client of IAM token requests
+func (t callTimeouts) iamClient() *gohttp.Client {
+	return &gohttp.Client{Timeout: t.fast}
+}
+
+// serviceClient returns the HTTP client of the IBM Cloud services other than PowerVS
+func (t callTimeouts) serviceClient() *gohttp.Client {
+	return &gohttp.Client{Transport: newCallTimeoutTransport(nil, t)}
+}
+
+// callTimeoutTransport is a http.RoundTripper bounding every request by the timeout of its
+// method, so that a hung connection fails the call instead of stalling the CSI request
+// until the sidecar gives up. The deadline covers reading the response body.
+type callTimeoutTransport struct {
+	next     gohttp.RoundTripper
+	timeouts callTimeouts
+}
+
+func newCallTimeoutTransport(next gohttp.RoundTripper, timeouts callTimeouts) *callTimeoutTransport {
+	if next == nil {
+		next = gohttp.DefaultTransport
+	}
+	return &callTimeoutTransport{next: next, timeouts: timeouts}
+}
+
+func (t *callTimeoutTransport) RoundTrip(req *gohttp.Request) (*gohttp.Response, error) {
+	timeout := t.timeouts.forRequest(req)
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && req.Context().Err() == nil {
+			return nil, fmt.Errorf("%s %s%s timed out after %v: %w", req.Method, req.URL.Host, req.URL.Path, timeout, err)
+		}
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases the deadline of a request once its response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	gohttp "net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCallTimeoutTransport(t *testing.T) {
+	timeouts := callTimeouts{fast: time.Second, slow: time.Minute}
+	testCases := []struct {
+		method   string
+		expected time.Duration
+	}{
+		{method: gohttp.MethodGet, expected: time.Second},
+		{method: gohttp.MethodPost, expected: time.Minute},
+		{method: gohttp.MethodPut, expected: time.Minute},
+		{method: gohttp.MethodDelete, expected: time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.method, func(t *testing.T) {
+			var deadline time.Time
+			next := roundTripFunc(func(r *gohttp.Request) (*gohttp.Response, error) {
+				deadline, _ = r.Context().Deadline()
+				return &gohttp.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+			})
+			req, _ := gohttp.NewRequest(tc.method, "https://us-south.power-iaas.cloud.ibm.com/pcloud/v1/volumes", nil)
+			start := time.Now()
+			resp, err := newCallTimeoutTransport(next, timeouts).RoundTrip(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp.Body.Close()
+			if got := deadline.Sub(start); got < tc.expected || got > tc.expected+time.Second/10 {
+				t.Fatalf("expected a deadline in %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestCallTimeoutTransportHungRequest(t *testing.T) {
+	next := roundTripFunc(func(r *gohttp.Request) (*gohttp.Response, error) {
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	})
+	req, _ := gohttp.NewRequest(gohttp.MethodGet, "https://us-south.power-iaas.cloud.ibm.com/pcloud/v1/volumes", nil)
+	_, err := newCallTimeoutTransport(next, callTimeouts{fast: 10 * time.Millisecond, slow: time.Minute}).RoundTrip(req)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "timed out after 10ms") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if !IsRetryableError(err) {
+		t.Fatalf("expected the timeout to be retryable")
+	}
+}
+
+func TestNewCallTimeouts(t *testing.T) {
+	if got := newCallTimeouts(0); got.fast != DefaultFastCallTimeout || got.slow != DefaultSlowCallTimeout {
+		t.Fatalf("expected the default timeouts, got %+v", got)
+	}
+	if got := newCallTimeouts(time.Minute); got.fast != time.Minute || got.slow != time.Minute {
+		t.Fatalf("expected 1m for all calls, got %+v", got)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/IBM-Cloud/power-go-client/power/models"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+// states of PowerVS clone tasks
+const (
+	cloneTaskCompleted = "completed"
+	cloneTaskFailed    = "failed"
+)
+
+// ErrCloneFailed is returned when PowerVS fails to clone a volume.
+var ErrCloneFailed = errors.New("volume clone failed")
+
+// Cloner is implemented by clouds that create volumes as clones of other volumes. PowerVS
+// clones a volume in the storage pool and tier of its source, the clone API has no target
+// pool.
+type Cloner interface {
+	// CloneDisk creates the volume volumeName as a clone of sourceVolumeID, grown to the
+	// capacity of diskOptions if it is larger than the source
+	CloneDisk(ctx context.Context, sourceVolumeID, volumeName string, diskOptions *DiskOptions) (*Disk, error)
+}
+
+var _ Cloner = &powerVSCloud{}
+
+// CloneDisk clones the volume sourceVolumeID with a clone task of PowerVS. PowerVS names the
+// clone clone-<volumeName>-<number>, it's renamed to volumeName once the task completes. A
+// clone left with the name of PowerVS by an interrupted call is picked up instead of cloning
+// the source again.
+func (p *powerVSCloud) CloneDisk(ctx context.Context, sourceVolumeID, volumeName string, diskOptions *DiskOptions) (*Disk, error) {
+	ctx, cancel := p.operationContext(ctx, OperationCreate)
+	defer cancel()
+
+	var vols *models.Volumes
+	err := p.call(ctx, "GetVolumes", IsRetryableError, func() (err error) {
+		vols, err = p.volClient(ctx).GetAll()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	cloneID, err := findClone(vols.Volumes, volumeName)
+	if errors.Is(err, ErrNotFound) {
+		cloneID, err = p.runCloneTask(ctx, sourceVolumeID, volumeName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := p.waitForVolumeState(ctx, OperationCreate, cloneID, VolumeAvailableState); err != nil {
+		return nil, err
+	}
+
+	clone, err := p.GetDiskByID(ctx, cloneID)
+	if err != nil {
+		return nil, err
+	}
+	dataVolume := &models.UpdateVolume{
+		Name:      &volumeName,
+		Shareable: &diskOptions.Shareable,
+	}
+	if capacityGiB := util.BytesToGiB(diskOptions.CapacityBytes); capacityGiB > clone.CapacityGiB {
+		dataVolume.Size = float64(capacityGiB)
+	}
+	var v *models.Volume
+	err = p.call(ctx, "UpdateVolume", IsRetryableError, func() (err error) {
+		v, err = p.volClient(ctx).UpdateVolume(cloneID, dataVolume)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not rename clone %s of volume %s: %w", cloneID, sourceVolumeID, err)
+	}
+	if err := p.waitForVolumeState(ctx, OperationCreate, cloneID, VolumeAvailableState); err != nil {
+		return nil, err
+	}
+
+	// tagging failures don't fail the provisioning, the volume itself is usable
+	if err := p.attachTags(ctx, cloneID, diskOptions.Tags); err != nil {
+		klog.Warningf("failed to tag volume %s with %v: %v", cloneID, diskOptions.Tags, err)
+	}
+
+	return &Disk{
+		Name:        volumeName,
+		VolumeID:    cloneID,
+		DiskType:    v.DiskType,
+		WWN:         strings.ToLower(v.Wwn),
+		Shareable:   diskOptions.Shareable,
+		CapacityGiB: int64(*v.Size),
+		StoragePool: v.VolumePool,
+	}, nil
+}
+
+// runCloneTask starts the clone task of sourceVolumeID and waits until it completes, it
+// returns the ID of the clone
+func (p *powerVSCloud) runCloneTask(ctx context.Context, sourceVolumeID, volumeName string) (string, error) {
+	// only throttled requests are retried, a server side failure may already have started the task
+	var task *models.CloneTaskReference
+	err := p.call(ctx, "CloneVolumes", IsThrottlingError, func() (err error) {
+		task, err = p.cloneClient(ctx).Create(&models.VolumesCloneAsyncRequest{
+			Name:      &volumeName,
+			VolumeIds: []string{sourceVolumeID},
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	taskID := *task.CloneTaskID
+	klog.V(4).Infof("Cloning volume %s to %s in clone task %s", sourceVolumeID, volumeName, taskID)
+
+	ctx, cancel := context.WithTimeout(ctx, p.stateTimeout(ctx, OperationCreate))
+	defer cancel()
+	defer util.StartPhase(ctx, util.PhaseVolumeWait)()
+	var cloneID string
+	err = p.pollPool.poll(ctx, p.tuning.pollInterval, func() (bool, error) {
+		s, err := p.cloneClient(ctx).Get(taskID)
+		if err != nil {
+			// keep polling, the task is checked again once the API answers again
+			klog.V(5).Infof("Could not get clone task %s of volume %s: %v", taskID, sourceVolumeID, err)
+			return false, nil
+		}
+		if s.Status == nil {
+			return false, nil
+		}
+		switch *s.Status {
+		case cloneTaskCompleted:
+			for _, c := range s.ClonedVolumes {
+				if c != nil && c.SourceVolumeID == sourceVolumeID {
+					cloneID = c.ClonedVolumeID
+					return true, nil
+				}
+			}
+			return false, fmt.Errorf("%w: clone task %s completed without a clone of volume %s", ErrCloneFailed, taskID, sourceVolumeID)
+		case cloneTaskFailed:
+			return false, fmt.Errorf("%w: clone task %s of volume %s: %s", ErrCloneFailed, taskID, sourceVolumeID, s.FailedReason)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return cloneID, nil
+}
+
+// findClone returns the ID of the volume PowerVS named clone-<name>-<number> when cloning
+// a volume to name, ErrNotFound if there is none and ErrDuplicateName if there are several
+func findClone(volumes []*models.VolumeReference, name string) (string, error) {
+	pattern := regexp.MustCompile("^clone-" + regexp.QuoteMeta(name) + "-[0-9]+$")
+	var found []string
+	for _, v := range volumes {
+		if v.Name != nil && v.VolumeID != nil && pattern.MatchString(*v.Name) {
+			found = append(found, *v.VolumeID)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", ErrNotFound
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("%w %q: clones %s", ErrDuplicateName, name, strings.Join(found, ", "))
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IBM-Cloud/power-go-client/power/models"
+)
+
+func TestFindClone(t *testing.T) {
+	volume := func(id, name string) *models.VolumeReference {
+		return &models.VolumeReference{VolumeID: &id, Name: &name}
+	}
+	volumes := []*models.VolumeReference{
+		{VolumeID: stringPtr("unnamed")},
+		volume("vol-1", "pvc-1"),
+		volume("vol-2", "clone-pvc-1-10293"),
+		volume("vol-3", "clone-pvc-10-48271"),
+		volume("vol-4", "clone-pvc.2-11111"),
+		volume("vol-5", "clone-pvc-3-12345"),
+		volume("vol-6", "clone-pvc-3-54321"),
+	}
+
+	id, err := findClone(volumes, "pvc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "vol-2" {
+		t.Fatalf("expected clone vol-2, got %s", id)
+	}
+	if _, err := findClone(volumes, "pvc-2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a name matching a pattern, got: %v", err)
+	}
+	if _, err := findClone(volumes, "pvc"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a prefix of a name, got: %v", err)
+	}
+	if _, err := findClone(volumes, "pvc-3"); !errors.Is(err, ErrDuplicateName) {
+		t.Fatalf("expected ErrDuplicateName, got: %v", err)
+	}
+}
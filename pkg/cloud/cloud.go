@@ -22,19 +22,49 @@ import (
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
 )
 
-// PowerVS volume types
+// PowerVS volume types, the IOPS of tier0, tier1 and tier3 scale with the size of the volume
+// while tier5k volumes have a fixed 5000 IOPS
 const (
-	VolumeTypeTier1 = "tier1"
-	VolumeTypeTier3 = "tier3"
+	VolumeTypeTier0  = "tier0"
+	VolumeTypeTier1  = "tier1"
+	VolumeTypeTier3  = "tier3"
+	VolumeTypeTier5k = "tier5k"
 )
 
 var (
 	ValidVolumeTypes = []string{
+		VolumeTypeTier0,
 		VolumeTypeTier1,
 		VolumeTypeTier3,
+		VolumeTypeTier5k,
 	}
 )
 
+// iopsPerGiB are the IOPS of the tiers scaling with the size of the volume
+var iopsPerGiB = map[string]int64{
+	VolumeTypeTier0: 25,
+	VolumeTypeTier1: 10,
+	VolumeTypeTier3: 3,
+}
+
+// Tier5kIOPS are the IOPS of tier5k volumes, independent of their size
+const Tier5kIOPS int64 = 5000
+
+// ProvisionedIOPS returns the IOPS of a volume of volumeType and capacityGiB, 0 for an unknown
+// type. PowerVS has no custom IOPS, they are given by the tier.
+func ProvisionedIOPS(volumeType string, capacityGiB int64) int64 {
+	if volumeType == VolumeTypeTier5k {
+		return Tier5kIOPS
+	}
+	return iopsPerGiB[volumeType] * capacityGiB
+}
+
+// sizes of volumes accepted by the PowerVS API
+const (
+	MinVolumeSize int64 = 1 * util.GiB
+	MaxVolumeSize int64 = 2048 * util.GiB
+)
+
 // Defaults
 const (
 	// DefaultVolumeSize represents the default volume size.
@@ -49,6 +79,15 @@ var (
 
 	// ErrAlreadyExists is returned when a resource is already existent.
 	ErrAlreadyExists = errors.New("resource already exists")
+
+	// ErrDuplicateName is returned when a lookup by name matches several resources.
+	ErrDuplicateName = errors.New("several resources have the name")
+
+	// ErrVolumeBusy is returned when a volume can't be changed in its current state.
+	ErrVolumeBusy = errors.New("volume is busy")
+
+	// ErrVolumeFailed is returned when a volume waited for is in the error state.
+	ErrVolumeFailed = errors.New("volume is in state error")
 )
 
 // Disk represents a PowerVS volume
@@ -59,6 +98,12 @@ type Disk struct {
 	Name        string
 	Shareable   bool
 	CapacityGiB int64
+	// State is the PowerVS state of the volume, e.g. VolumeAvailableState
+	State string
+	// AttachedTo are the IDs of the pvm instances the volume is attached to
+	AttachedTo []string
+	// StoragePool is the PowerVS storage pool the volume is placed in
+	StoragePool string
 }
 
 // DiskOptions represents parameters to create an PowerVS volume
@@ -68,4 +113,20 @@ type DiskOptions struct {
 	//CapacityGigaBytes float64
 	CapacityBytes int64
 	VolumeType    string
+	// ReplicationEnabled creates the volume with Global Replication Service (GRS) replication
+	// to the paired site of the workspace
+	ReplicationEnabled bool
+	// StoragePool places the volume in a storage pool of its tier, PowerVS picks one when empty
+	StoragePool string
+	// Tags are attached to the volume once it is created
+	Tags []string
+}
+
+// StorageCapacity is the storage of a volume type still available in the region of the
+// cloud instance
+type StorageCapacity struct {
+	// AvailableGiB is the sum of the free storage of the pools of the volume type
+	AvailableGiB int64
+	// MaximumVolumeGiB is the size of the largest volume that can be created
+	MaximumVolumeGiB int64
 }
@@ -16,17 +16,23 @@ limitations under the License.
 
 package cloud
 
+import "context"
+
 type Cloud interface {
-	CreateDisk(volumeName string, diskOptions *DiskOptions) (disk *Disk, err error)
-	DeleteDisk(volumeID string) (success bool, err error)
-	AttachDisk(volumeID string, nodeID string) (err error)
-	DetachDisk(volumeID string, nodeID string) (err error)
-	ResizeDisk(volumeID string, reqSize int64) (newSize int64, err error)
-	WaitForVolumeState(volumeID, state string) error
-	GetDiskByName(name string) (disk *Disk, err error)
-	GetDiskByID(volumeID string) (disk *Disk, err error)
-	GetPVMInstanceByName(instanceName string) (instance *PVMInstance, err error)
-	GetPVMInstanceByID(instanceID string) (instance *PVMInstance, err error)
-	GetImageByID(imageID string) (image *PVMImage, err error)
-	IsAttached(volumeID string, nodeID string) (attached bool, err error)
+	CreateDisk(ctx context.Context, volumeName string, diskOptions *DiskOptions) (disk *Disk, err error)
+	DeleteDisk(ctx context.Context, volumeID string) (success bool, err error)
+	AttachDisk(ctx context.Context, volumeID string, nodeID string) (err error)
+	DetachDisk(ctx context.Context, volumeID string, nodeID string) (err error)
+	ResizeDisk(ctx context.Context, volumeID string, reqSize int64) (newSize int64, err error)
+	UpdateDiskTier(ctx context.Context, volumeID string, tier string) (err error)
+	WaitForVolumeState(ctx context.Context, volumeID, state string) error
+	GetDiskByName(ctx context.Context, name string) (disk *Disk, err error)
+	GetDiskByID(ctx context.Context, volumeID string) (disk *Disk, err error)
+	ListDisks(ctx context.Context) (disks []*Disk, err error)
+	GetPVMInstanceByName(ctx context.Context, instanceName string) (instance *PVMInstance, err error)
+	GetPVMInstanceByID(ctx context.Context, instanceID string) (instance *PVMInstance, err error)
+	GetImageByID(ctx context.Context, imageID string) (image *PVMImage, err error)
+	IsAttached(ctx context.Context, volumeID string, nodeID string) (attached bool, err error)
+	GetStorageCapacity(ctx context.Context, volumeType string) (capacity *StorageCapacity, err error)
+	GetStoragePoolCapacity(ctx context.Context, pool string) (capacity *StorageCapacity, err error)
 }
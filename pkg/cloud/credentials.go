@@ -0,0 +1,219 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"fmt"
+	gohttp "net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/IBM-Cloud/bluemix-go"
+	bxsession "github.com/IBM-Cloud/bluemix-go/session"
+	"github.com/IBM/go-sdk-core/v5/core"
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+)
+
+// APIKeyEnv is the environment variable the IBM Cloud API key is read from when no key
+// file is configured
+const APIKeyEnv = "IBMCLOUD_API_KEY"
+
+// authentication types of the PowerVS cloud client
+const (
+	// AuthTypeAPIKey authenticates with a long-lived IBM Cloud API key
+	AuthTypeAPIKey = "apikey"
+	// AuthTypeTrustedProfile exchanges the compute resource token of the pod for an IAM
+	// token of a trusted profile
+	AuthTypeTrustedProfile = "trusted-profile"
+)
+
+// AuthTypes are the supported authentication types
+var AuthTypes = []string{AuthTypeAPIKey, AuthTypeTrustedProfile}
+
+// apiKeyAuthenticator authenticates PowerVS API requests with an IAM token of the current
+// API key, the key can be swapped while requests are in flight
+type apiKeyAuthenticator struct {
+	// iamEndpoint is the IAM token endpoint, the public one is used when empty
+	iamEndpoint string
+	// client sends the IAM token requests
+	client *gohttp.Client
+
+	mu    sync.RWMutex
+	inner core.Authenticator
+}
+
+func newAPIKeyAuthenticator(apikey, iamEndpoint string, client *gohttp.Client) (*apiKeyAuthenticator, error) {
+	a := &apiKeyAuthenticator{iamEndpoint: iamEndpoint, client: client}
+	if err := a.update(apikey); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// update replaces the API key, the IAM token of the previous key is dropped
+func (a *apiKeyAuthenticator) update(apikey string) error {
+	inner, err := core.NewIamAuthenticator(apikey, a.iamEndpoint, "", "", false, nil)
+	if err != nil {
+		return err
+	}
+	inner.Client = a.client
+	a.mu.Lock()
+	a.inner = inner
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *apiKeyAuthenticator) current() core.Authenticator {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.inner
+}
+
+func (a *apiKeyAuthenticator) AuthenticationType() string {
+	return core.AUTHTYPE_IAM
+}
+
+func (a *apiKeyAuthenticator) Authenticate(request *gohttp.Request) error {
+	return a.current().Authenticate(request)
+}
+
+func (a *apiKeyAuthenticator) Validate() error {
+	return a.current().Validate()
+}
+
+// newTrustedProfileAuthenticator returns an authenticator for the trusted profile with the
+// given ID or name, crTokenFile defaults to the token path of the IBM Cloud SDK and
+// iamEndpoint to the public IAM endpoint when empty
+func newTrustedProfileAuthenticator(profileID, profileName, crTokenFile, iamEndpoint string, client *gohttp.Client) (*core.ContainerAuthenticator, error) {
+	return core.NewContainerAuthenticatorBuilder().
+		SetIAMProfileID(profileID).
+		SetIAMProfileName(profileName).
+		SetCRTokenFilename(crTokenFile).
+		SetURL(iamEndpoint).
+		SetClient(client).
+		Build()
+}
+
+// newTrustedProfileSession returns an IBM Cloud session with the current IAM token of the
+// trusted profile, the session can't refresh the token on its own
+func newTrustedProfileSession(auth *core.ContainerAuthenticator, client *gohttp.Client) (*bxsession.Session, error) {
+	token, err := auth.GetToken()
+	if err != nil {
+		return nil, fmt.Errorf("could not get trusted profile token: %v", err)
+	}
+	config := &bluemix.Config{IAMAccessToken: "Bearer " + token, HTTPClient: client}
+	if auth.URL != "" {
+		config.TokenProviderEndpoint = &auth.URL
+	}
+	return bxsession.New(config)
+}
+
+// readAPIKey returns the API key stored in file, or the one in APIKeyEnv when file is empty
+func readAPIKey(file string) (string, error) {
+	if file == "" {
+		return os.Getenv(APIKeyEnv), nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("could not read API key file: %v", err)
+	}
+	apikey := strings.TrimSpace(string(b))
+	if apikey == "" {
+		return "", fmt.Errorf("API key file %s is empty", file)
+	}
+	return apikey, nil
+}
+
+// apiKeyWatcher calls onChange with the new key whenever the content of the API key file
+// changes. The parent directory is watched since Kubernetes updates mounted secrets by
+// swapping a symlink rather than writing the file.
+type apiKeyWatcher struct {
+	file     string
+	current  string
+	onChange func(apikey string) error
+	watcher  *fsnotify.Watcher
+	done     chan struct{}
+}
+
+func newAPIKeyWatcher(file, current string, onChange func(apikey string) error) (*apiKeyWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	w := &apiKeyWatcher{
+		file:     file,
+		current:  current,
+		onChange: onChange,
+		watcher:  watcher,
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *apiKeyWatcher) run() {
+	defer close(w.done)
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			w.reload()
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			klog.Warningf("error watching API key file %s: %v", w.file, err)
+		}
+	}
+}
+
+// reload re-reads the key file and hands a changed key to onChange, a file that is missing
+// or empty in the middle of an update is ignored until the next event
+func (w *apiKeyWatcher) reload() {
+	apikey, err := readAPIKey(w.file)
+	if err != nil {
+		klog.V(4).Infof("ignoring API key file event: %v", err)
+		return
+	}
+	if apikey == w.current {
+		return
+	}
+	if err := w.onChange(apikey); err != nil {
+		klog.Errorf("could not rotate the IBM Cloud API key: %v", err)
+		return
+	}
+	w.current = apikey
+	klog.Infof("reloaded the IBM Cloud API key from %s", w.file)
+}
+
+func (w *apiKeyWatcher) stop() error {
+	err := w.watcher.Close()
+	<-w.done
+	return err
+}
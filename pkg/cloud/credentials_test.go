@@ -0,0 +1,162 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadAPIKey(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(APIKeyEnv, "env-key")
+
+	testCases := []struct {
+		name      string
+		content   *string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "env",
+			expected: "env-key",
+		},
+		{
+			name:     "file with trailing newline",
+			content:  stringPtr("file-key\n"),
+			expected: "file-key",
+		},
+		{
+			name:      "empty file",
+			content:   stringPtr("  \n"),
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := ""
+			if tc.content != nil {
+				file = filepath.Join(dir, tc.name)
+				if err := os.WriteFile(file, []byte(*tc.content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			apikey, err := readAPIKey(file)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.expectErr, err)
+			}
+			if apikey != tc.expected {
+				t.Fatalf("expected key %q, got %q", tc.expected, apikey)
+			}
+		})
+	}
+
+	if _, err := readAPIKey(filepath.Join(dir, "missing")); err == nil {
+		t.Fatalf("expected error for a missing file")
+	}
+}
+
+func TestAPIKeyAuthenticatorUpdate(t *testing.T) {
+	a, err := newAPIKeyAuthenticator("key-1", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := a.current()
+	if err := a.update("key-2"); err != nil {
+		t.Fatal(err)
+	}
+	if a.current() == before {
+		t.Fatalf("expected the authenticator to be replaced")
+	}
+	if err := a.update(""); err == nil {
+		t.Fatalf("expected error for an empty key")
+	}
+	if err := a.Validate(); err != nil {
+		t.Fatalf("expected previous key to be kept, got: %v", err)
+	}
+}
+
+func TestNewTrustedProfileAuthenticator(t *testing.T) {
+	auth, err := newTrustedProfileAuthenticator("", "powervs-csi", "/var/run/secrets/tokens/powervs-csi", "https://private.iam.cloud.ibm.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.IAMProfileName != "powervs-csi" || auth.CRTokenFilename != "/var/run/secrets/tokens/powervs-csi" || auth.URL != "https://private.iam.cloud.ibm.com" {
+		t.Fatalf("unexpected authenticator %+v", auth)
+	}
+	if _, err := newTrustedProfileAuthenticator("", "", "", "", nil); err == nil {
+		t.Fatalf("expected error without a profile ID or name")
+	}
+}
+
+// TestAPIKeyWatcher rotates the key the way the kubelet updates a mounted secret, by
+// swapping the ..data symlink to a new directory
+func TestAPIKeyWatcher(t *testing.T) {
+	dir := t.TempDir()
+	writeSecret := func(version, apikey string) {
+		data := filepath.Join(dir, version)
+		if err := os.Mkdir(data, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(data, "apikey"), []byte(apikey), 0600); err != nil {
+			t.Fatal(err)
+		}
+		tmp := filepath.Join(dir, "..data_tmp")
+		if err := os.Symlink(version, tmp); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeSecret("..v1", "key-1")
+	file := filepath.Join(dir, "apikey")
+	if err := os.Symlink(filepath.Join("..data", "apikey"), file); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := make(chan string, 10)
+	w, err := newAPIKeyWatcher(file, "key-1", func(apikey string) error {
+		rotated <- apikey
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.stop()
+
+	writeSecret("..v2", "key-2")
+	select {
+	case apikey := <-rotated:
+		if apikey != "key-2" {
+			t.Fatalf("expected rotated key %q, got %q", "key-2", apikey)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the key to be reloaded")
+	}
+
+	// the symlink swap emits several events, the unchanged key must not be reported again
+	select {
+	case apikey := <-rotated:
+		t.Fatalf("unexpected reload of key %q", apikey)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	gohttp "net/http"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// maxDebugBodyBytes caps the logged size of request and response bodies
+const maxDebugBodyBytes = 4096
+
+const redacted = "REDACTED"
+
+// sensitiveKeys are redacted from logged JSON bodies when a key contains one of them, matched
+// case insensitively
+var sensitiveKeys = []string{"token", "password", "secret", "apikey", "api_key", "authorization", "userdata", "sshkey"}
+
+// debugTransport is a http.RoundTripper logging every PowerVS API request with its method,
+// path, status and duration, and the bodies with credentials redacted. Headers are never
+// logged since they carry the IAM token.
+type debugTransport struct {
+	next gohttp.RoundTripper
+}
+
+func newDebugTransport(next gohttp.RoundTripper) *debugTransport {
+	if next == nil {
+		next = gohttp.DefaultTransport
+	}
+	return &debugTransport{next: next}
+}
+
+func (t *debugTransport) RoundTrip(req *gohttp.Request) (*gohttp.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	duration := time.Since(start)
+	if err != nil {
+		klog.Infof("PowerVS API %s %s%s failed after %v: %v, request: %s", req.Method, req.URL.Host, req.URL.Path, duration, err, redactBody(reqBody))
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	klog.Infof("PowerVS API %s %s%s: %s in %v, request: %s, response: %s", req.Method, req.URL.Host, req.URL.Path, resp.Status, duration, redactBody(reqBody), redactBody(respBody))
+	return resp, nil
+}
+
+// readBody reads and replaces *body so that it can be consumed again
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == gohttp.NoBody {
+		return nil, nil
+	}
+	b, err := ioutil.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+// redactBody returns the loggable form of a body, the values of sensitiveKeys are replaced
+// in JSON bodies and other bodies are only logged by size
+func redactBody(b []byte) string {
+	if len(b) == 0 {
+		return "<empty>"
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(b))
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return fmt.Sprintf("<%d bytes>", len(b))
+	}
+	if len(out) > maxDebugBodyBytes {
+		return fmt.Sprintf("%s...(%d bytes)", out[:maxDebugBodyBytes], len(out))
+	}
+	return string(out)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			if isSensitiveKey(k) {
+				v[k] = redacted
+			} else {
+				v[k] = redactValue(value)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	}
+	return v
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"io/ioutil"
+	gohttp "net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "empty",
+			expected: "<empty>",
+		},
+		{
+			name:     "nested credentials",
+			body:     `{"name":"pvc-1","auth":{"apiKey":"secret-key","access_token":"t"},"servers":[{"userData":"x","id":"s1"}]}`,
+			expected: `{"auth":{"access_token":"REDACTED","apiKey":"REDACTED"},"name":"pvc-1","servers":[{"id":"s1","userData":"REDACTED"}]}`,
+		},
+		{
+			name:     "not json",
+			body:     "grant_type=apikey&apikey=secret-key",
+			expected: "<35 bytes>",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := redactBody([]byte(tc.body)); got != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDebugTransportKeepsBodies(t *testing.T) {
+	next := roundTripFunc(func(r *gohttp.Request) (*gohttp.Response, error) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != `{"size":10}` {
+			t.Fatalf("expected request body to be forwarded, got %q", body)
+		}
+		return &gohttp.Response{StatusCode: 202, Status: "202 Accepted", Body: ioutil.NopCloser(strings.NewReader(`{"volumeID":"vol-1"}`))}, nil
+	})
+
+	req, _ := gohttp.NewRequest("POST", "https://us-south.power-iaas.cloud.ibm.com/pcloud/v1/volumes", strings.NewReader(`{"size":10}`))
+	resp, err := newDebugTransport(next).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != `{"volumeID":"vol-1"}` {
+		t.Fatalf("expected response body to be returned, got %q", body)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/IBM-Cloud/power-go-client/power/models"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+// BulkDetacher is implemented by clouds detaching several volumes from an instance at once.
+// PowerVS has no bulk detach, the detaches are requested one after the other and waited for
+// together, which is where most of the time of a detach goes.
+type BulkDetacher interface {
+	// DetachDisks returns the errors of the volumes of the same index
+	DetachDisks(ctx context.Context, volumeIDs []string, nodeID string) []error
+}
+
+var _ BulkDetacher = &powerVSCloud{}
+
+// DetachDisks detaches the volumes volumeIDs from the pvm instance nodeID, the errors are the
+// ones DetachDisk returns
+func (p *powerVSCloud) DetachDisks(ctx context.Context, volumeIDs []string, nodeID string) []error {
+	ctx, cancel := p.operationContext(ctx, OperationDetach)
+	defer cancel()
+	errs := make([]error, len(volumeIDs))
+	var wg sync.WaitGroup
+	for i, volumeID := range volumeIDs {
+		if errs[i] = p.requestDetach(ctx, volumeID, nodeID); errs[i] != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, volumeID string) {
+			defer wg.Done()
+			errs[i] = p.waitForDetach(ctx, volumeID, nodeID)
+		}(i, volumeID)
+	}
+	wg.Wait()
+	return errs
+}
+
+// waitForDetach waits until PowerVS no longer reports the volume attached to the pvm instance
+// nodeID. The detach request is accepted before the volume is detached, attaching it to
+// another instance in the meantime fails with the volume in use.
+func (p *powerVSCloud) waitForDetach(ctx context.Context, volumeID, nodeID string) error {
+	start := time.Now()
+	defer util.StartPhase(ctx, util.PhaseVolumeWait)()
+	defer p.observeSlowCall(ctx, "WaitForDetach", start, "volumeID", volumeID, "nodeID", nodeID)
+
+	ctx, cancel := context.WithTimeout(ctx, p.stateTimeout(ctx, OperationDetach))
+	defer cancel()
+	return p.pollPool.poll(ctx, p.tuning.pollInterval, func() (bool, error) {
+		v, err := p.volClient(ctx).Get(volumeID)
+		if err != nil {
+			if HTTPStatusCode(err) == http.StatusNotFound {
+				return true, nil
+			}
+			// keep polling, the detach is verified once the API answers again
+			klog.V(5).Infof("Could not get volume %s while waiting for its detach from %s: %v", volumeID, nodeID, err)
+			return false, nil
+		}
+		return isDetached(v, nodeID), nil
+	})
+}
+
+// isDetached returns true if v isn't attached to nodeID, a volume attached to no other
+// instance must also be available again
+func isDetached(v *models.Volume, nodeID string) bool {
+	if isAttachedTo(v, nodeID) {
+		return false
+	}
+	return len(v.PvmInstanceIds) > 0 || v.State == VolumeAvailableState
+}
+
+// isAttachedTo returns true if PowerVS reports v attached to nodeID
+func isAttachedTo(v *models.Volume, nodeID string) bool {
+	for _, id := range v.PvmInstanceIds {
+		if id == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDetach returns the retriable func of the detach of a volume from nodeID. PowerVS
+// rejects the detach with a conflict while an operation is in progress on the instance, it's
+// retried as long as get reports the volume attached to nodeID. settled is set when the
+// refreshed volume is no longer attached to nodeID or was deleted meanwhile.
+func retryDetach(get func() (*models.Volume, error), nodeID string, settled *bool) func(error) bool {
+	return func(err error) bool {
+		if HTTPStatusCode(err) != http.StatusConflict {
+			return IsRetryableError(err)
+		}
+		v, getErr := get()
+		switch {
+		case HTTPStatusCode(getErr) == http.StatusNotFound:
+			*settled = true
+		case getErr != nil:
+			// retry the detach, its next conflict refreshes the volume again
+			klog.V(5).Infof("Could not refresh volume after the detach from %s conflicted: %v", nodeID, getErr)
+		case !isAttachedTo(v, nodeID):
+			*settled = true
+		}
+		return !*settled
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IBM-Cloud/power-go-client/power/models"
+)
+
+func TestIsDetached(t *testing.T) {
+	testCases := []struct {
+		name     string
+		volume   *models.Volume
+		expected bool
+	}{
+		{name: "still attached", volume: &models.Volume{State: VolumeInUseState, PvmInstanceIds: []string{"node-1"}}, expected: false},
+		{name: "detached but not available yet", volume: &models.Volume{State: VolumeInUseState}, expected: false},
+		{name: "detached", volume: &models.Volume{State: VolumeAvailableState}, expected: true},
+		{name: "still attached to another node", volume: &models.Volume{State: VolumeInUseState, PvmInstanceIds: []string{"node-2"}}, expected: true},
+		{name: "attached to several nodes", volume: &models.Volume{State: VolumeInUseState, PvmInstanceIds: []string{"node-2", "node-1"}}, expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if detached := isDetached(tc.volume, "node-1"); detached != tc.expected {
+				t.Fatalf("expected detached %v, got %v", tc.expected, detached)
+			}
+		})
+	}
+}
+
+func TestRetryDetach(t *testing.T) {
+	conflict := errors.New("[PUT /pcloud/v1/cloud-instances/ws/pvm-instances/node-1/volumes/vol-1][409] conflict")
+	testCases := []struct {
+		name       string
+		err        error
+		volume     *models.Volume
+		getErr     error
+		expRetry   bool
+		expSettled bool
+	}{
+		{name: "retryable error", err: errors.New("[PUT /volumes][503] unavailable"), expRetry: true},
+		{name: "client error", err: errors.New("[PUT /volumes][400] bad request")},
+		{name: "conflict while attached", err: conflict, volume: &models.Volume{State: VolumeInUseState, PvmInstanceIds: []string{"node-1"}}, expRetry: true},
+		{name: "conflict after detach", err: conflict, volume: &models.Volume{State: VolumeInUseState, PvmInstanceIds: []string{"node-2"}}, expSettled: true},
+		{name: "conflict after delete", err: conflict, getErr: errors.New("[GET /volumes/vol-1][404] not found"), expSettled: true},
+		{name: "conflict without refresh", err: conflict, getErr: errors.New("connection reset"), expRetry: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var settled bool
+			get := func() (*models.Volume, error) {
+				return tc.volume, tc.getErr
+			}
+			if retry := retryDetach(get, "node-1", &settled)(tc.err); retry != tc.expRetry {
+				t.Fatalf("expected retry %v, got %v", tc.expRetry, retry)
+			}
+			if settled != tc.expSettled {
+				t.Fatalf("expected settled %v, got %v", tc.expSettled, settled)
+			}
+		})
+	}
+}
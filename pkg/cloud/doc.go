@@ -0,0 +1,25 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package cloud is the PowerVS client used by the driver.
+
+The Cloud interface, NewPowerVSCloud with its With* options, the Disk, DiskOptions,
+PVMInstance and PVMImage types, the error values and the metadata service are the stable
+API of this package and follow semantic versioning. New methods are only added to Cloud in
+a new major version, wrap an existing implementation to customize its behaviour.
+*/
+package cloud
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"fmt"
+	gohttp "net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// EndpointCooldown is how long a PowerVS API endpoint is skipped after it failed
+var EndpointCooldown = 1 * time.Minute
+
+// endpointFailover is a http.RoundTripper sending requests to the first healthy endpoint
+// of a list of regional PowerVS API endpoints. An endpoint that fails with a connection
+// error or a gateway error is marked unhealthy for EndpointCooldown and the request is
+// resent to the next endpoint.
+type endpointFailover struct {
+	next      gohttp.RoundTripper
+	endpoints []string
+	now       func() time.Time
+
+	mu        sync.Mutex
+	unhealthy map[string]time.Time
+}
+
+func newEndpointFailover(endpoints []string, next gohttp.RoundTripper) *endpointFailover {
+	if next == nil {
+		next = gohttp.DefaultTransport
+	}
+	hosts := make([]string, 0, len(endpoints))
+	for _, e := range endpoints {
+		hosts = append(hosts, endpointHost(e))
+	}
+	return &endpointFailover{
+		next:      next,
+		endpoints: hosts,
+		now:       time.Now,
+		unhealthy: make(map[string]time.Time),
+	}
+}
+
+// endpointHost strips the scheme and any trailing slash from an endpoint URL
+func endpointHost(endpoint string) string {
+	endpoint = strings.TrimPrefix(strings.TrimPrefix(endpoint, "https://"), "http://")
+	return strings.TrimSuffix(endpoint, "/")
+}
+
+func (f *endpointFailover) RoundTrip(req *gohttp.Request) (*gohttp.Response, error) {
+	var lastErr error
+	var lastResp *gohttp.Response
+	for i, host := range f.candidates() {
+		if i > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				break
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		r := req.Clone(req.Context())
+		r.URL.Host = host
+		r.Host = host
+
+		resp, err := f.next.RoundTrip(r)
+		if err == nil && !isGatewayError(resp.StatusCode) {
+			f.markHealthy(host)
+			return resp, nil
+		}
+		if err != nil {
+			lastErr = err
+		} else {
+			lastErr = fmt.Errorf("endpoint %s returned %s", host, resp.Status)
+		}
+		klog.Warningf("PowerVS API endpoint %s is unhealthy, failing over: %v", host, lastErr)
+		f.markUnhealthy(host)
+		if lastResp != nil {
+			lastResp.Body.Close()
+		}
+		lastResp = resp
+	}
+	if lastResp != nil {
+		return lastResp, nil
+	}
+	return nil, lastErr
+}
+
+// candidates returns the healthy endpoints in configured order followed by the unhealthy
+// ones, so a request is still attempted when every endpoint is cooling down
+func (f *endpointFailover) candidates() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var healthy, unhealthy []string
+	for _, host := range f.endpoints {
+		if since, ok := f.unhealthy[host]; ok && f.now().Sub(since) < EndpointCooldown {
+			unhealthy = append(unhealthy, host)
+		} else {
+			healthy = append(healthy, host)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (f *endpointFailover) markHealthy(host string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.unhealthy[host]; ok {
+		klog.Infof("PowerVS API endpoint %s is healthy again", host)
+		delete(f.unhealthy, host)
+	}
+}
+
+func (f *endpointFailover) markUnhealthy(host string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unhealthy[host] = f.now()
+}
+
+func isGatewayError(code int) bool {
+	return code == gohttp.StatusBadGateway || code == gohttp.StatusServiceUnavailable || code == gohttp.StatusGatewayTimeout
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"io/ioutil"
+	gohttp "net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*gohttp.Request) (*gohttp.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *gohttp.Request) (*gohttp.Response, error) {
+	return f(r)
+}
+
+func TestEndpointFailover(t *testing.T) {
+	var hosts []string
+	down := map[string]bool{"primary.example.com": true}
+	next := roundTripFunc(func(r *gohttp.Request) (*gohttp.Response, error) {
+		hosts = append(hosts, r.URL.Host)
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Fatalf("expected request body to be replayed, got %q", body)
+		}
+		if down[r.URL.Host] {
+			return nil, errors.New("connection refused")
+		}
+		return &gohttp.Response{StatusCode: 200, Status: "200 OK", Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	now := time.Now()
+	f := newEndpointFailover([]string{"https://primary.example.com", "secondary.example.com/"}, next)
+	f.now = func() time.Time { return now }
+
+	send := func() {
+		req, _ := gohttp.NewRequest("POST", "https://primary.example.com/pcloud/v1/path", strings.NewReader("payload"))
+		if _, err := f.RoundTrip(req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	send()
+	if strings.Join(hosts, ",") != "primary.example.com,secondary.example.com" {
+		t.Fatalf("expected failover to secondary endpoint, got %v", hosts)
+	}
+
+	hosts = nil
+	send()
+	if strings.Join(hosts, ",") != "secondary.example.com" {
+		t.Fatalf("expected unhealthy endpoint to be skipped, got %v", hosts)
+	}
+
+	hosts = nil
+	down["primary.example.com"] = false
+	now = now.Add(2 * EndpointCooldown)
+	send()
+	if strings.Join(hosts, ",") != "primary.example.com" {
+		t.Fatalf("expected primary endpoint after cooldown, got %v", hosts)
+	}
+}
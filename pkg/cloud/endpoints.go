@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	bluemix "github.com/IBM-Cloud/bluemix-go"
+	bxsession "github.com/IBM-Cloud/bluemix-go/session"
+	"k8s.io/klog/v2"
+)
+
+// environment variables overriding the IBM Cloud service endpoints, the names are shared
+// with the IBM Cloud CLI and terraform provider
+const (
+	PowerVSEndpointEnv            = "IBMCLOUD_POWER_API_ENDPOINT"
+	IAMEndpointEnv                = "IBMCLOUD_IAM_API_ENDPOINT"
+	ResourceControllerEndpointEnv = "IBMCLOUD_RESOURCE_CONTROLLER_API_ENDPOINT"
+	GlobalTaggingEndpointEnv      = "IBMCLOUD_GT_API_ENDPOINT"
+)
+
+// DefaultIAMEndpoint is the public IAM endpoint
+const DefaultIAMEndpoint = "https://iam.cloud.ibm.com"
+
+// ServiceEndpoints override the public endpoints of the IBM Cloud services used next to
+// the PowerVS API, e.g. with private endpoints in clusters without public egress. Empty
+// fields keep the public endpoint.
+type ServiceEndpoints struct {
+	// IAM is the IAM token endpoint, e.g. https://private.iam.cloud.ibm.com
+	IAM string
+	// ResourceController is used to look up the zone of the cloud instance
+	ResourceController string
+	// GlobalTagging is used to tag volumes
+	GlobalTagging string
+}
+
+// Validate returns an error if one of the endpoints isn't a https URL
+func (e ServiceEndpoints) Validate() error {
+	for _, endpoint := range []struct{ name, url string }{
+		{"IAM", e.IAM},
+		{"resource controller", e.ResourceController},
+		{"global tagging", e.GlobalTagging},
+	} {
+		if endpoint.url == "" {
+			continue
+		}
+		if err := validateServiceURL(endpoint.url); err != nil {
+			return fmt.Errorf("invalid %s endpoint: %v", endpoint.name, err)
+		}
+	}
+	return nil
+}
+
+func validateServiceURL(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%q is not a https URL", endpoint)
+	}
+	return nil
+}
+
+// ValidateAPIEndpoint returns an error if endpoint isn't a PowerVS API host, optionally
+// given as https URL
+func ValidateAPIEndpoint(endpoint string) error {
+	if strings.HasPrefix(endpoint, "http://") {
+		return fmt.Errorf("%q must use https", endpoint)
+	}
+	u, err := url.Parse("https://" + endpointHost(endpoint))
+	if err != nil {
+		return err
+	}
+	if u.Host == "" || u.Path != "" {
+		return fmt.Errorf("%q is not a host name", endpoint)
+	}
+	return nil
+}
+
+// withEndpoint returns a copy of sess sending the requests of a client to endpoint, or
+// sess itself if endpoint is empty
+func withEndpoint(sess *bxsession.Session, endpoint string) *bxsession.Session {
+	if endpoint == "" {
+		return sess
+	}
+	return &bxsession.Session{Config: sess.Config.Copy(&bluemix.Config{Endpoint: &endpoint})}
+}
+
+// logEndpoints logs the endpoints in effect after applying the overrides
+func logEndpoints(sess *bxsession.Session, apiHosts []string, endpoints ServiceEndpoints) {
+	iam := endpoints.IAM
+	if iam == "" {
+		iam = DefaultIAMEndpoint
+	}
+	resourceController := endpoints.ResourceController
+	if resourceController == "" {
+		resourceController, _ = sess.Config.EndpointLocator.ResourceControllerEndpoint()
+	}
+	globalTagging := endpoints.GlobalTagging
+	if globalTagging == "" {
+		globalTagging, _ = sess.Config.EndpointLocator.GlobalTaggingEndpoint()
+	}
+	klog.Infof("Using endpoints PowerVS: %s, IAM: %s, resource controller: %s, global tagging: %s", strings.Join(apiHosts, ","), iam, resourceController, globalTagging)
+}
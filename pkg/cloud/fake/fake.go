@@ -0,0 +1,693 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package fake is an in-memory cloud.Cloud of a single PowerVS workspace.
+
+It backs the fake cloud provider of the driver and the unit tests of the driver, and can be
+used by projects built on the cloud package instead of mocking every call. Besides the
+cloud.Cloud methods, Cloud injects failures and latency into the calls and counts them.
+*/
+package fake
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+const (
+	// CloudInstanceID is the workspace of nodes without PowerVS metadata when the driver runs
+	// with the fake cloud
+	CloudInstanceID = "fake-workspace"
+	// CapacityGiB is the storage of every volume type of a fake workspace
+	CapacityGiB int64 = 100 * 1024
+	// imageID is the image of the pvm instances of the fake cloud
+	imageID = "fake-image"
+	// creatingState is the state of the volumes stuck in creation, see SetStuckCreating
+	creatingState = "creating"
+)
+
+// Cloud is an in-memory cloud.Cloud of a single workspace, for trying out the driver, its
+// sidecars and the scheduling of its volumes without PowerVS. Volumes reach their target state
+// right away, every call takes latency. Any pvm instance exists, it's named like its ID.
+type Cloud struct {
+	latency time.Duration
+
+	// mu guards the state below, the fake is called concurrently by the gRPC server of the
+	// driver
+	mu     sync.Mutex
+	disks  map[string]*cloud.Disk
+	tags   map[string][]string
+	calls  map[string]int
+	faults []*fault
+	// stuckCreating makes new volumes stay in the creating state, see SetStuckCreating
+	stuckCreating bool
+	// instanceStates are the states of the pvm instances set by SetInstanceState, the other
+	// instances are active
+	instanceStates map[string]string
+}
+
+var (
+	_ cloud.Cloud         = &Cloud{}
+	_ cloud.BulkAttacher  = &Cloud{}
+	_ cloud.BulkDetacher  = &Cloud{}
+	_ cloud.Cloner        = &Cloud{}
+	_ cloud.ForceDetacher = &Cloud{}
+	_ cloud.TagLister     = &Cloud{}
+	_ cloud.TagUpdater    = &Cloud{}
+)
+
+// fault is a failure injected into the calls of a method of Cloud
+type fault struct {
+	// method is the name of the cloud.Cloud method, e.g. "CreateDisk"
+	method string
+	// call is the call of method that fails, counted from 1, every call fails when 0
+	call int
+	// err is returned by the failing call, instead of handling it
+	err error
+	// latency delays the calls before they are handled, or until the context is done
+	latency time.Duration
+}
+
+// NewCloud returns an empty fake workspace whose calls take latency
+func NewCloud(latency time.Duration) *Cloud {
+	return &Cloud{
+		latency:        latency,
+		disks:          map[string]*cloud.Disk{},
+		tags:           map[string][]string{},
+		calls:          map[string]int{},
+		instanceStates: map[string]string{},
+	}
+}
+
+// Metadata returns the metadata of node nodeName in the CloudInstanceID workspace, for nodes
+// without PowerVS labels or provider ID
+func Metadata(nodeName string) *cloud.Metadata {
+	return cloud.NewMetadata(CloudInstanceID, nodeName)
+}
+
+// FailOnCall makes the call-th call of method return err, or every call when call is 0
+func (c *Cloud) FailOnCall(method string, call int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = append(c.faults, &fault{method: method, call: call, err: err})
+}
+
+// AddLatency delays every call of method by latency, on top of the latency of the fake
+func (c *Cloud) AddLatency(method string, latency time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = append(c.faults, &fault{method: method, latency: latency})
+}
+
+// SetStuckCreating makes volumes created from now on stay in the creating state so that
+// waiting for them times out, false releases the stuck volumes
+func (c *Cloud) SetStuckCreating(stuck bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stuckCreating = stuck
+	if stuck {
+		return
+	}
+	for _, disk := range c.disks {
+		if disk.State == creatingState {
+			disk.State = cloud.VolumeAvailableState
+		}
+	}
+}
+
+// SetInstanceState sets the state of the pvm instance nodeID, e.g. cloud.InstanceShutoffState
+func (c *Cloud) SetInstanceState(nodeID, state string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.instanceStates[nodeID] = state
+}
+
+// CallCount returns how often method was called
+func (c *Cloud) CallCount(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[method]
+}
+
+// DiskCount returns the number of volumes of the workspace
+func (c *Cloud) DiskCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.disks)
+}
+
+// call counts a call of method and applies the latency and the faults set up for it, it
+// returns the error of ctx if it's done first
+func (c *Cloud) call(ctx context.Context, method string) error {
+	c.mu.Lock()
+	c.calls[method]++
+	call := c.calls[method]
+	latency := c.latency
+	var err error
+	for _, f := range c.faults {
+		if f.method != method {
+			continue
+		}
+		latency += f.latency
+		if f.err != nil && (f.call == 0 || f.call == call) && err == nil {
+			err = f.err
+		}
+	}
+	c.mu.Unlock()
+
+	if latency <= 0 {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Cloud) CreateDisk(ctx context.Context, volumeName string, diskOptions *cloud.DiskOptions) (*cloud.Disk, error) {
+	if err := c.call(ctx, "CreateDisk"); err != nil {
+		return nil, err
+	}
+	volumeType := diskOptions.VolumeType
+	if volumeType == "" {
+		volumeType = cloud.DefaultVolumeType
+	}
+	id, wwn, err := newVolumeID()
+	if err != nil {
+		return nil, err
+	}
+	pool := diskOptions.StoragePool
+	if pool == "" {
+		pool = "fake-" + volumeType
+	}
+	disk := &cloud.Disk{
+		VolumeID:    id,
+		DiskType:    volumeType,
+		WWN:         wwn,
+		Name:        volumeName,
+		Shareable:   diskOptions.Shareable,
+		CapacityGiB: util.BytesToGiB(diskOptions.CapacityBytes),
+		State:       cloud.VolumeAvailableState,
+		StoragePool: pool,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.disks[disk.VolumeID] = disk
+	c.tags[disk.VolumeID] = append([]string(nil), diskOptions.Tags...)
+	if c.stuckCreating {
+		disk.State = creatingState
+		// like the PowerVS client, which waits for new volumes to become available
+		return nil, wait.ErrWaitTimeout
+	}
+	return copyDisk(disk), nil
+}
+
+// CloneDisk creates volumeName as a copy of sourceVolumeID right away, in the storage pool
+// and tier of the source like PowerVS
+func (c *Cloud) CloneDisk(ctx context.Context, sourceVolumeID, volumeName string, diskOptions *cloud.DiskOptions) (*cloud.Disk, error) {
+	if err := c.call(ctx, "CloneDisk"); err != nil {
+		return nil, err
+	}
+	id, wwn, err := newVolumeID()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	source, ok := c.disks[sourceVolumeID]
+	if !ok {
+		return nil, cloud.ErrNotFound
+	}
+	capacityGiB := util.BytesToGiB(diskOptions.CapacityBytes)
+	if capacityGiB < source.CapacityGiB {
+		capacityGiB = source.CapacityGiB
+	}
+	disk := &cloud.Disk{
+		VolumeID:    id,
+		DiskType:    source.DiskType,
+		WWN:         wwn,
+		Name:        volumeName,
+		Shareable:   diskOptions.Shareable,
+		CapacityGiB: capacityGiB,
+		State:       cloud.VolumeAvailableState,
+		StoragePool: source.StoragePool,
+	}
+	c.disks[disk.VolumeID] = disk
+	c.tags[disk.VolumeID] = append([]string(nil), diskOptions.Tags...)
+	return copyDisk(disk), nil
+}
+
+func (c *Cloud) DeleteDisk(ctx context.Context, volumeID string) (bool, error) {
+	if err := c.call(ctx, "DeleteDisk"); err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	disk, ok := c.disks[volumeID]
+	if !ok {
+		return false, cloud.ErrNotFound
+	}
+	if len(disk.AttachedTo) > 0 {
+		return false, fmt.Errorf("%w: volume %s is attached to %v", cloud.ErrVolumeBusy, volumeID, disk.AttachedTo)
+	}
+	delete(c.disks, volumeID)
+	delete(c.tags, volumeID)
+	return true, nil
+}
+
+func (c *Cloud) AttachDisk(ctx context.Context, volumeID string, nodeID string) error {
+	if err := c.call(ctx, "AttachDisk"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	disk, ok := c.disks[volumeID]
+	if !ok {
+		return cloud.ErrNotFound
+	}
+	if attachedTo(disk, nodeID) {
+		return nil
+	}
+	if len(disk.AttachedTo) > 0 && !disk.Shareable {
+		return fmt.Errorf("%w: volume %s is attached to %v", cloud.ErrVolumeBusy, volumeID, disk.AttachedTo)
+	}
+	disk.AttachedTo = append(disk.AttachedTo, nodeID)
+	disk.State = cloud.VolumeInUseState
+	return nil
+}
+
+// AttachDisks attaches all volumes of volumeIDs to nodeID or none of them, like the bulk
+// attach of PowerVS
+func (c *Cloud) AttachDisks(ctx context.Context, volumeIDs []string, nodeID string) error {
+	if err := c.call(ctx, "AttachDisks"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var disks []*cloud.Disk
+	for _, volumeID := range volumeIDs {
+		disk, ok := c.disks[volumeID]
+		if !ok {
+			return cloud.ErrNotFound
+		}
+		if attachedTo(disk, nodeID) {
+			continue
+		}
+		if len(disk.AttachedTo) > 0 && !disk.Shareable {
+			return fmt.Errorf("%w: volume %s is attached to %v", cloud.ErrAlreadyExists, volumeID, disk.AttachedTo)
+		}
+		disks = append(disks, disk)
+	}
+	for _, disk := range disks {
+		disk.AttachedTo = append(disk.AttachedTo, nodeID)
+		disk.State = cloud.VolumeInUseState
+	}
+	return nil
+}
+
+// attachedTo returns true if disk is attached to nodeID
+func attachedTo(disk *cloud.Disk, nodeID string) bool {
+	for _, id := range disk.AttachedTo {
+		if id == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cloud) DetachDisk(ctx context.Context, volumeID string, nodeID string) error {
+	if err := c.call(ctx, "DetachDisk"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.detach(volumeID, nodeID)
+}
+
+// DetachDisks detaches the volumes of volumeIDs from nodeID with a single call
+func (c *Cloud) DetachDisks(ctx context.Context, volumeIDs []string, nodeID string) []error {
+	errs := make([]error, len(volumeIDs))
+	if err := c.call(ctx, "DetachDisks"); err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, volumeID := range volumeIDs {
+		errs[i] = c.detach(volumeID, nodeID)
+	}
+	return errs
+}
+
+// detach detaches the volume from nodeID, c.mu must be held
+func (c *Cloud) detach(volumeID string, nodeID string) error {
+	disk, ok := c.disks[volumeID]
+	if !ok {
+		return cloud.ErrNotFound
+	}
+	remaining := disk.AttachedTo[:0]
+	for _, id := range disk.AttachedTo {
+		if id != nodeID {
+			remaining = append(remaining, id)
+		}
+	}
+	disk.AttachedTo = remaining
+	if len(remaining) == 0 {
+		disk.State = cloud.VolumeAvailableState
+	}
+	return nil
+}
+
+func (c *Cloud) ResizeDisk(ctx context.Context, volumeID string, reqSize int64) (int64, error) {
+	if err := c.call(ctx, "ResizeDisk"); err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	disk, ok := c.disks[volumeID]
+	if !ok {
+		return 0, cloud.ErrNotFound
+	}
+	if capacityGiB := util.BytesToGiB(reqSize); capacityGiB > disk.CapacityGiB {
+		disk.CapacityGiB = capacityGiB
+	}
+	return disk.CapacityGiB, nil
+}
+
+func (c *Cloud) UpdateDiskTier(ctx context.Context, volumeID string, tier string) error {
+	if err := c.call(ctx, "UpdateDiskTier"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	disk, ok := c.disks[volumeID]
+	if !ok {
+		return cloud.ErrNotFound
+	}
+	disk.DiskType = tier
+	return nil
+}
+
+// GetDiskTags returns the tags of the volume, sorted
+func (c *Cloud) GetDiskTags(ctx context.Context, volumeID string) ([]string, error) {
+	if err := c.call(ctx, "GetDiskTags"); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.disks[volumeID]; !ok {
+		return nil, cloud.ErrNotFound
+	}
+	tags := append([]string(nil), c.tags[volumeID]...)
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// UpdateDiskTags detaches the tags detach from the volume and attaches the tags attach
+func (c *Cloud) UpdateDiskTags(ctx context.Context, volumeID string, attach, detach []string) error {
+	if err := c.call(ctx, "UpdateDiskTags"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.disks[volumeID]; !ok {
+		return cloud.ErrNotFound
+	}
+	detached := map[string]bool{}
+	for _, tag := range detach {
+		detached[tag] = true
+	}
+	var tags []string
+	for _, tag := range c.tags[volumeID] {
+		if !detached[tag] {
+			tags = append(tags, tag)
+		}
+	}
+	for _, tag := range attach {
+		if !containsString(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	c.tags[volumeID] = tags
+	return nil
+}
+
+// WaitForVolumeState returns right away as volumes don't change state on their own, it times
+// out like the PowerVS client if the volume isn't in state
+func (c *Cloud) WaitForVolumeState(ctx context.Context, volumeID, state string) error {
+	if err := c.call(ctx, "WaitForVolumeState"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	disk, ok := c.disks[volumeID]
+	if !ok {
+		return cloud.ErrNotFound
+	}
+	if disk.State == cloud.VolumeErrorState && state != cloud.VolumeErrorState {
+		return fmt.Errorf("%w: volume %s", cloud.ErrVolumeFailed, volumeID)
+	}
+	if disk.State != state {
+		return fmt.Errorf("volume %s is %s instead of %s: %w", volumeID, disk.State, state, wait.ErrWaitTimeout)
+	}
+	return nil
+}
+
+func (c *Cloud) GetDiskByName(ctx context.Context, name string) (*cloud.Disk, error) {
+	if err := c.call(ctx, "GetDiskByName"); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var found *cloud.Disk
+	for _, disk := range c.disks {
+		if disk.Name != name {
+			continue
+		}
+		if found != nil {
+			return nil, cloud.ErrDuplicateName
+		}
+		found = disk
+	}
+	if found == nil {
+		return nil, cloud.ErrNotFound
+	}
+	return copyDisk(found), nil
+}
+
+func (c *Cloud) GetDiskByID(ctx context.Context, volumeID string) (*cloud.Disk, error) {
+	if err := c.call(ctx, "GetDiskByID"); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	disk, ok := c.disks[volumeID]
+	if !ok {
+		return nil, cloud.ErrNotFound
+	}
+	return copyDisk(disk), nil
+}
+
+func (c *Cloud) ListDisks(ctx context.Context) ([]*cloud.Disk, error) {
+	if err := c.call(ctx, "ListDisks"); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	disks := make([]*cloud.Disk, 0, len(c.disks))
+	for _, disk := range c.disks {
+		disks = append(disks, copyDisk(disk))
+	}
+	sort.Slice(disks, func(i, j int) bool { return disks[i].VolumeID < disks[j].VolumeID })
+	return disks, nil
+}
+
+func (c *Cloud) GetPVMInstanceByName(ctx context.Context, instanceName string) (*cloud.PVMInstance, error) {
+	if err := c.call(ctx, "GetPVMInstanceByName"); err != nil {
+		return nil, err
+	}
+	return c.instance(instanceName), nil
+}
+
+func (c *Cloud) GetPVMInstanceByID(ctx context.Context, instanceID string) (*cloud.PVMInstance, error) {
+	if err := c.call(ctx, "GetPVMInstanceByID"); err != nil {
+		return nil, err
+	}
+	return c.instance(instanceID), nil
+}
+
+// instance returns the pvm instance id in the state set by SetInstanceState
+func (c *Cloud) instance(id string) *cloud.PVMInstance {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	instance := newPVMInstance(id)
+	if state, ok := c.instanceStates[id]; ok {
+		instance.Status = state
+	}
+	return instance
+}
+
+// ForceDetachDisk detaches the volume from nodeID if the instance is shut off or in error,
+// the state of an instance in error is reset to active
+func (c *Cloud) ForceDetachDisk(ctx context.Context, volumeID, nodeID string) error {
+	if err := c.call(ctx, "ForceDetachDisk"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state, ok := c.instanceStates[nodeID]
+	if !ok {
+		state = cloud.InstanceActiveState
+	}
+	switch state {
+	case cloud.InstanceShutoffState:
+	case cloud.InstanceErrorState:
+		delete(c.instanceStates, nodeID)
+	default:
+		return fmt.Errorf("%w: instance %s is %s", cloud.ErrInstanceNotDown, nodeID, state)
+	}
+	return c.detach(volumeID, nodeID)
+}
+
+func (c *Cloud) GetImageByID(ctx context.Context, imageID string) (*cloud.PVMImage, error) {
+	if err := c.call(ctx, "GetImageByID"); err != nil {
+		return nil, err
+	}
+	return &cloud.PVMImage{ID: imageID, Name: imageID, DiskType: cloud.DefaultVolumeType}, nil
+}
+
+func (c *Cloud) IsAttached(ctx context.Context, volumeID string, nodeID string) (bool, error) {
+	if err := c.call(ctx, "IsAttached"); err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	disk, ok := c.disks[volumeID]
+	if !ok {
+		return false, cloud.ErrNotFound
+	}
+	return attachedTo(disk, nodeID), nil
+}
+
+// GetStorageCapacity returns the CapacityGiB not taken by the volumes of volumeType
+func (c *Cloud) GetStorageCapacity(ctx context.Context, volumeType string) (*cloud.StorageCapacity, error) {
+	if err := c.call(ctx, "GetStorageCapacity"); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	available := CapacityGiB
+	for _, disk := range c.disks {
+		if disk.DiskType == volumeType {
+			available -= disk.CapacityGiB
+		}
+	}
+	if available < 0 {
+		available = 0
+	}
+	maximum := cloud.MaxVolumeSize / util.GiB
+	if available < maximum {
+		maximum = available
+	}
+	return &cloud.StorageCapacity{AvailableGiB: available, MaximumVolumeGiB: maximum}, nil
+}
+
+// GetStoragePoolCapacity returns the CapacityGiB not taken by the volumes of pool, any pool
+// exists. fake-<volume type> is the pool of the volumes created without pool.
+func (c *Cloud) GetStoragePoolCapacity(ctx context.Context, pool string) (*cloud.StorageCapacity, error) {
+	if err := c.call(ctx, "GetStoragePoolCapacity"); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	capacity := &cloud.StorageCapacity{AvailableGiB: CapacityGiB}
+	for _, disk := range c.disks {
+		if disk.StoragePool == pool {
+			capacity.AvailableGiB -= disk.CapacityGiB
+		}
+	}
+	if capacity.AvailableGiB < 0 {
+		capacity.AvailableGiB = 0
+	}
+	capacity.MaximumVolumeGiB = cloud.MaxVolumeSize / util.GiB
+	if capacity.AvailableGiB < capacity.MaximumVolumeGiB {
+		capacity.MaximumVolumeGiB = capacity.AvailableGiB
+	}
+	return capacity, nil
+}
+
+// newPVMInstance returns the active pvm instance id of the fake workspace
+func newPVMInstance(id string) *cloud.PVMInstance {
+	return &cloud.PVMInstance{ID: id, ImageID: imageID, Name: id, Status: cloud.InstanceActiveState}
+}
+
+// copyDisk returns a copy of disk the caller can't change the fake cloud through
+func copyDisk(disk *cloud.Disk) *cloud.Disk {
+	d := *disk
+	d.AttachedTo = append([]string(nil), disk.AttachedTo...)
+	return &d
+}
+
+// containsString returns true if items has s
+func containsString(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// newVolumeID returns a random volume ID in the UUID format of PowerVS and a random WWN
+func newVolumeID() (id, wwn string, err error) {
+	if id, err = randomHex(16); err != nil {
+		return "", "", err
+	}
+	if wwn, err = randomHex(16); err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%s-%s-%s-%s-%s", id[:8], id[8:12], id[12:16], id[16:20], id[20:]), wwn, nil
+}
+
+// randomHex returns n random bytes hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func TestCloud(t *testing.T) {
+	ctx := context.Background()
+	c := NewCloud(0)
+
+	disk, err := c.CreateDisk(ctx, "pvc-1", &cloud.DiskOptions{CapacityBytes: 10 * util.GiB, VolumeType: cloud.VolumeTypeTier3})
+	if err != nil {
+		t.Fatalf("could not create volume: %v", err)
+	}
+	if disk.CapacityGiB != 10 || disk.DiskType != cloud.VolumeTypeTier3 || len(disk.WWN) != 32 {
+		t.Fatalf("unexpected volume %+v", disk)
+	}
+	if found, err := c.GetDiskByName(ctx, "pvc-1"); err != nil || found.VolumeID != disk.VolumeID {
+		t.Fatalf("expected volume %s by name, got %+v, %v", disk.VolumeID, found, err)
+	}
+	if _, err := c.GetDiskByName(ctx, "pvc-2"); !errors.Is(err, cloud.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := c.AttachDisk(ctx, disk.VolumeID, "node-1"); err != nil {
+		t.Fatalf("could not attach volume: %v", err)
+	}
+	if err := c.WaitForVolumeState(ctx, disk.VolumeID, cloud.VolumeInUseState); err != nil {
+		t.Fatalf("expected volume in-use: %v", err)
+	}
+	if err := c.AttachDisk(ctx, disk.VolumeID, "node-2"); !errors.Is(err, cloud.ErrVolumeBusy) {
+		t.Fatalf("expected ErrVolumeBusy attaching to another node, got %v", err)
+	}
+	if attached, _ := c.IsAttached(ctx, disk.VolumeID, "node-1"); !attached {
+		t.Fatalf("expected volume attached to node-1")
+	}
+	if _, err := c.DeleteDisk(ctx, disk.VolumeID); !errors.Is(err, cloud.ErrVolumeBusy) {
+		t.Fatalf("expected ErrVolumeBusy deleting an attached volume, got %v", err)
+	}
+
+	if capacity, _ := c.GetStorageCapacity(ctx, cloud.VolumeTypeTier3); capacity.AvailableGiB != CapacityGiB-10 {
+		t.Fatalf("expected %d GiB available, got %+v", CapacityGiB-10, capacity)
+	}
+	if capacity, _ := c.GetStoragePoolCapacity(ctx, "fake-"+cloud.VolumeTypeTier3); capacity.AvailableGiB != CapacityGiB-10 {
+		t.Fatalf("expected %d GiB available in the pool, got %+v", CapacityGiB-10, capacity)
+	}
+	if size, err := c.ResizeDisk(ctx, disk.VolumeID, 20*util.GiB); err != nil || size != 20 {
+		t.Fatalf("expected volume resized to 20 GiB, got %d, %v", size, err)
+	}
+
+	if err := c.DetachDisk(ctx, disk.VolumeID, "node-1"); err != nil {
+		t.Fatalf("could not detach volume: %v", err)
+	}
+	if _, err := c.DeleteDisk(ctx, disk.VolumeID); err != nil {
+		t.Fatalf("could not delete volume: %v", err)
+	}
+	if _, err := c.GetDiskByID(ctx, disk.VolumeID); !errors.Is(err, cloud.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCloudClone(t *testing.T) {
+	ctx := context.Background()
+	c := NewCloud(0)
+
+	source, err := c.CreateDisk(ctx, "pvc-1", &cloud.DiskOptions{CapacityBytes: 10 * util.GiB, VolumeType: cloud.VolumeTypeTier3, StoragePool: "pool-1"})
+	if err != nil {
+		t.Fatalf("could not create volume: %v", err)
+	}
+	clone, err := c.CloneDisk(ctx, source.VolumeID, "pvc-2", &cloud.DiskOptions{CapacityBytes: util.GiB, VolumeType: cloud.VolumeTypeTier1})
+	if err != nil {
+		t.Fatalf("could not clone volume: %v", err)
+	}
+	if clone.VolumeID == source.VolumeID || clone.CapacityGiB != 10 || clone.DiskType != cloud.VolumeTypeTier3 || clone.StoragePool != "pool-1" {
+		t.Fatalf("expected a 10 GiB tier3 clone in pool-1, got %+v", clone)
+	}
+	if found, err := c.GetDiskByName(ctx, "pvc-2"); err != nil || found.VolumeID != clone.VolumeID {
+		t.Fatalf("expected clone %s by name, got %+v, %v", clone.VolumeID, found, err)
+	}
+	if clone, err := c.CloneDisk(ctx, source.VolumeID, "pvc-3", &cloud.DiskOptions{CapacityBytes: 20 * util.GiB}); err != nil || clone.CapacityGiB != 20 {
+		t.Fatalf("expected a 20 GiB clone, got %+v, %v", clone, err)
+	}
+	if _, err := c.CloneDisk(ctx, "vol-2", "pvc-4", &cloud.DiskOptions{}); !errors.Is(err, cloud.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCloudTags(t *testing.T) {
+	ctx := context.Background()
+	c := NewCloud(0)
+
+	disk, err := c.CreateDisk(ctx, "pvc-1", &cloud.DiskOptions{CapacityBytes: util.GiB, Tags: []string{"kubernetes-cluster-id:old", "team:a"}})
+	if err != nil {
+		t.Fatalf("could not create volume: %v", err)
+	}
+	if err := c.UpdateDiskTags(ctx, disk.VolumeID, []string{"kubernetes-cluster-id:new", "team:a"}, []string{"kubernetes-cluster-id:old"}); err != nil {
+		t.Fatalf("could not update tags: %v", err)
+	}
+	tags, err := c.GetDiskTags(ctx, disk.VolumeID)
+	if err != nil {
+		t.Fatalf("could not get tags: %v", err)
+	}
+	if !reflect.DeepEqual(tags, []string{"kubernetes-cluster-id:new", "team:a"}) {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	if _, err := c.GetDiskTags(ctx, "vol-2"); !errors.Is(err, cloud.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCloudLatency(t *testing.T) {
+	c := NewCloud(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.ListDisks(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the call to end with its context, got %v", err)
+	}
+}
+
+func TestCloudFaults(t *testing.T) {
+	ctx := context.Background()
+	c := NewCloud(0)
+	errFailed := errors.New("failed")
+	c.FailOnCall("CreateDisk", 1, errFailed)
+
+	options := &cloud.DiskOptions{CapacityBytes: util.GiB}
+	if _, err := c.CreateDisk(ctx, "pvc-1", options); !errors.Is(err, errFailed) {
+		t.Fatalf("expected the injected error, got %v", err)
+	}
+	if _, err := c.CreateDisk(ctx, "pvc-1", options); err != nil {
+		t.Fatalf("unexpected error on the second call: %v", err)
+	}
+	if n := c.CallCount("CreateDisk"); n != 2 {
+		t.Fatalf("expected 2 calls of CreateDisk, got %d", n)
+	}
+	if n := c.DiskCount(); n != 1 {
+		t.Fatalf("expected 1 volume, got %d", n)
+	}
+
+	c.SetStuckCreating(true)
+	if _, err := c.CreateDisk(ctx, "pvc-2", options); !errors.Is(err, wait.ErrWaitTimeout) {
+		t.Fatalf("expected a timeout creating a stuck volume, got %v", err)
+	}
+	disk, err := c.GetDiskByName(ctx, "pvc-2")
+	if err != nil {
+		t.Fatalf("expected the stuck volume, got %v", err)
+	}
+	if err := c.WaitForVolumeState(ctx, disk.VolumeID, cloud.VolumeAvailableState); !errors.Is(err, wait.ErrWaitTimeout) {
+		t.Fatalf("expected a timeout waiting for the stuck volume, got %v", err)
+	}
+	c.SetStuckCreating(false)
+	if err := c.WaitForVolumeState(ctx, disk.VolumeID, cloud.VolumeAvailableState); err != nil {
+		t.Fatalf("expected the released volume available, got %v", err)
+	}
+
+	c.AddLatency("ListDisks", time.Hour)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.ListDisks(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the slow call to end with its context, got %v", err)
+	}
+}
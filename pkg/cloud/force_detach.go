@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/IBM-Cloud/power-go-client/power/models"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+)
+
+// ErrInstanceNotDown is returned by ForceDetachDisk for instances that are neither powered
+// off nor in error
+var ErrInstanceNotDown = errors.New("instance is not powered off or in error")
+
+// ForceDetacher is implemented by clouds that detach volumes from instances which are powered
+// off or in error. PowerVS rejects the volume operations of instances in error, their state is
+// reset before the detach.
+type ForceDetacher interface {
+	ForceDetachDisk(ctx context.Context, volumeID, nodeID string) error
+}
+
+var _ ForceDetacher = &powerVSCloud{}
+
+// ForceDetachDisk detaches the volume from the pvm instance nodeID if the instance is powered
+// off or in error, instances in error are reset first. The state of the instance is read
+// from PowerVS, not from the instance cache.
+func (p *powerVSCloud) ForceDetachDisk(ctx context.Context, volumeID, nodeID string) error {
+	var in *models.PVMInstance
+	err := p.call(ctx, "GetPVMInstance", IsRetryableError, func() (err error) {
+		in, err = p.pvmInstancesClient(ctx).Get(nodeID)
+		return err
+	})
+	if err != nil {
+		if HTTPStatusCode(err) == http.StatusNotFound {
+			return fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
+		return err
+	}
+	p.instanceCache.Invalidate(nodeID)
+
+	var state string
+	if in.Status != nil {
+		state = strings.ToUpper(*in.Status)
+	}
+	switch state {
+	case InstanceShutoffState:
+	case InstanceErrorState:
+		klog.Warningf("Resetting the state of instance %s in error to force the detach of volume %s", nodeID, volumeID)
+		err := p.call(ctx, "ResetPVMInstanceState", IsRetryableError, func() error {
+			return p.pvmInstancesClient(ctx).Action(nodeID, &models.PVMInstanceAction{Action: pointer.StringPtr(models.PVMInstanceActionActionResetState)})
+		})
+		if err != nil {
+			return fmt.Errorf("could not reset the state of instance %s: %v", nodeID, err)
+		}
+	default:
+		return fmt.Errorf("%w: instance %s is %s", ErrInstanceNotDown, nodeID, state)
+	}
+	return p.DetachDisk(ctx, volumeID, nodeID)
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// DeviceTreePartitionName holds the name of the LPAR, which PowerVS sets to the server name
+var DeviceTreePartitionName = "/proc/device-tree/ibm,partition-name"
+
+// instanceByNameGetter is the subset of Cloud used to discover the pvm instance
+type instanceByNameGetter interface {
+	GetPVMInstanceByName(ctx context.Context, instanceName string) (instance *PVMInstance, err error)
+}
+
+// DiscoverPvmInstanceID finds the pvm instance the driver runs on by matching the LPAR
+// partition name, the node name and the hostname against the PowerVS server names
+func DiscoverPvmInstanceID(ctx context.Context, c instanceByNameGetter, nodeName string) (string, error) {
+	var candidates []string
+	if name, err := ioutil.ReadFile(DeviceTreePartitionName); err == nil {
+		candidates = append(candidates, strings.TrimRight(string(name), "\x00\n"))
+	} else {
+		klog.V(4).Infof("could not read partition name from %s: %v", DeviceTreePartitionName, err)
+	}
+	candidates = append(candidates, nodeName)
+	if hostname, err := os.Hostname(); err == nil {
+		candidates = append(candidates, hostname, strings.SplitN(hostname, ".", 2)[0])
+	}
+
+	tried := map[string]bool{}
+	for _, name := range candidates {
+		if name == "" || tried[name] {
+			continue
+		}
+		tried[name] = true
+		in, err := c.GetPVMInstanceByName(ctx, name)
+		if err == nil {
+			klog.Infof("discovered pvm instance %s from server name %q", in.ID, name)
+			return in.ID, nil
+		}
+		if err != ErrNotFound {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no PowerVS server is named after any of %v, set the %s node label", candidates, PvmInstanceIdLabel)
+}
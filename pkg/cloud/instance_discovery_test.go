@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeInstanceGetter map[string]string
+
+func (f fakeInstanceGetter) GetPVMInstanceByName(ctx context.Context, name string) (*PVMInstance, error) {
+	if id, ok := f[name]; ok {
+		return &PVMInstance{ID: id, Name: name}, nil
+	}
+	return nil, ErrNotFound
+}
+
+type failingInstanceGetter struct{}
+
+func (failingInstanceGetter) GetPVMInstanceByName(ctx context.Context, name string) (*PVMInstance, error) {
+	return nil, errors.New("unauthorized")
+}
+
+func TestDiscoverPvmInstanceID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "device-tree")
+	if err != nil {
+		t.Fatalf("error creating directory %v", err)
+	}
+	defer os.RemoveAll(dir)
+	partitionName := filepath.Join(dir, "ibm,partition-name")
+	if err := ioutil.WriteFile(partitionName, []byte("worker-lpar\x00"), 0644); err != nil {
+		t.Fatalf("error writing partition name %v", err)
+	}
+
+	oldPath := DeviceTreePartitionName
+	defer func() { DeviceTreePartitionName = oldPath }()
+
+	testCases := []struct {
+		name       string
+		deviceTree string
+		getter     instanceByNameGetter
+		nodeName   string
+		expectedID string
+		expectErr  bool
+	}{
+		{
+			name:       "partition name",
+			deviceTree: partitionName,
+			getter:     fakeInstanceGetter{"worker-lpar": "pvm-1"},
+			nodeName:   "worker-0",
+			expectedID: "pvm-1",
+		},
+		{
+			name:       "node name",
+			deviceTree: filepath.Join(dir, "missing"),
+			getter:     fakeInstanceGetter{"worker-0": "pvm-2"},
+			nodeName:   "worker-0",
+			expectedID: "pvm-2",
+		},
+		{
+			name:       "not found",
+			deviceTree: filepath.Join(dir, "missing"),
+			getter:     fakeInstanceGetter{},
+			nodeName:   "worker-0",
+			expectErr:  true,
+		},
+		{
+			name:       "cloud error",
+			deviceTree: partitionName,
+			getter:     failingInstanceGetter{},
+			nodeName:   "worker-0",
+			expectErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			DeviceTreePartitionName = tc.deviceTree
+			id, err := DiscoverPvmInstanceID(context.Background(), tc.getter, tc.nodeName)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.expectErr, err)
+			}
+			if id != tc.expectedID {
+				t.Fatalf("expected pvm instance %q, got %q", tc.expectedID, id)
+			}
+		})
+	}
+}
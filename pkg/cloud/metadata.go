@@ -38,6 +38,12 @@ func (m *Metadata) GetPvmInstanceId() string {
 	return m.pvmInstanceId
 }
 
+// NewMetadata returns the metadata of the pvm instance pvmInstanceID of the workspace
+// cloudInstanceID
+func NewMetadata(cloudInstanceID, pvmInstanceID string) *Metadata {
+	return &Metadata{cloudInstanceId: cloudInstanceID, pvmInstanceId: pvmInstanceID}
+}
+
 // Get New Metadata Service
 func NewMetadataService(k8sAPIClient KubernetesAPIClient) (MetadataService, error) {
 	klog.Infof("retrieving instance data from kubernetes api")
@@ -20,7 +20,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -29,6 +31,10 @@ import (
 const (
 	CloudInstanceIDLabel = "powervs.kubernetes.io/cloud-instance-id"
 	PvmInstanceIdLabel   = "powervs.kubernetes.io/pvm-instance-id"
+
+	// ProviderIDPrefix is the scheme of the node provider IDs set by the PowerVS cloud
+	// controller manager, ibmpowervs://<region>/<zone>/<cloud instance id>/<pvm instance id>
+	ProviderIDPrefix = "ibmpowervs://"
 )
 
 type KubernetesAPIClient func() (kubernetes.Interface, error)
@@ -60,22 +66,45 @@ func KubernetesAPIInstanceInfo(clientset kubernetes.Interface) (*Metadata, error
 		return nil, fmt.Errorf("error getting Node %s: %v", nodeName, err)
 	}
 
-	// Get node labels
+	// Get node labels, the provider ID is used for the ones which are not set
 	labels := node.GetLabels()
-	keysList := []string{CloudInstanceIDLabel, PvmInstanceIdLabel}
-	instanceInfo := Metadata{}
-	for _, key := range keysList {
-		if val, ok := labels[key]; ok {
-			switch key {
-			case CloudInstanceIDLabel:
-				instanceInfo.cloudInstanceId = val
-			case PvmInstanceIdLabel:
-				instanceInfo.pvmInstanceId = val
-			}
-		} else {
-			return nil, fmt.Errorf("error getting label %s for node Node %s", key, nodeName)
+	instanceInfo := Metadata{
+		cloudInstanceId: labels[CloudInstanceIDLabel],
+		pvmInstanceId:   labels[PvmInstanceIdLabel],
+	}
+	if cloudInstanceID, pvmInstanceID, ok := parseProviderID(node.Spec.ProviderID); ok {
+		if instanceInfo.cloudInstanceId == "" {
+			instanceInfo.cloudInstanceId = cloudInstanceID
+		}
+		if instanceInfo.pvmInstanceId == "" {
+			instanceInfo.pvmInstanceId = pvmInstanceID
 		}
 	}
+	if instanceInfo.cloudInstanceId == "" {
+		return nil, fmt.Errorf("error getting label %s for node Node %s", CloudInstanceIDLabel, nodeName)
+	}
+	// an empty pvm instance id is discovered later through the PowerVS API, see DiscoverPvmInstanceID
 
 	return &instanceInfo, nil
 }
+
+// NodePvmInstanceID returns the pvm instance id of node, from its label or else its provider ID
+func NodePvmInstanceID(node *v1.Node) string {
+	if id := node.Labels[PvmInstanceIdLabel]; id != "" {
+		return id
+	}
+	_, pvmInstanceID, _ := parseProviderID(node.Spec.ProviderID)
+	return pvmInstanceID
+}
+
+// parseProviderID returns the cloud instance and pvm instance ids of a PowerVS provider ID
+func parseProviderID(providerID string) (cloudInstanceID, pvmInstanceID string, ok bool) {
+	if !strings.HasPrefix(providerID, ProviderIDPrefix) {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(providerID, ProviderIDPrefix), "/")
+	if len(parts) != 4 || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[2], parts[3], true
+}
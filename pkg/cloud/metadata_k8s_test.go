@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKubernetesAPIInstanceInfo(t *testing.T) {
+	testCases := []struct {
+		name               string
+		labels             map[string]string
+		providerID         string
+		expectedCloudID    string
+		expectedInstanceID string
+		expectErr          bool
+	}{
+		{
+			name:               "labels",
+			labels:             map[string]string{CloudInstanceIDLabel: "cloud-1", PvmInstanceIdLabel: "pvm-1"},
+			expectedCloudID:    "cloud-1",
+			expectedInstanceID: "pvm-1",
+		},
+		{
+			name:               "provider id",
+			providerID:         "ibmpowervs://us-south/dal12/cloud-2/pvm-2",
+			expectedCloudID:    "cloud-2",
+			expectedInstanceID: "pvm-2",
+		},
+		{
+			name:            "pvm instance left for discovery",
+			labels:          map[string]string{CloudInstanceIDLabel: "cloud-1"},
+			expectedCloudID: "cloud-1",
+		},
+		{
+			name:      "missing cloud instance",
+			expectErr: true,
+		},
+	}
+
+	os.Setenv("CSI_NODE_NAME", "worker-0")
+	defer os.Unsetenv("CSI_NODE_NAME")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			node := &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Labels: tc.labels},
+				Spec:       v1.NodeSpec{ProviderID: tc.providerID},
+			}
+			if _, err := clientset.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+				t.Fatalf("could not create node: %v", err)
+			}
+
+			m, err := KubernetesAPIInstanceInfo(clientset)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if m.GetCloudInstanceId() != tc.expectedCloudID || m.GetPvmInstanceId() != tc.expectedInstanceID {
+				t.Fatalf("expected %s/%s, got %s/%s", tc.expectedCloudID, tc.expectedInstanceID, m.GetCloudInstanceId(), m.GetPvmInstanceId())
+			}
+		})
+	}
+}
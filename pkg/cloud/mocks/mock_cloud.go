@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -35,178 +36,237 @@ func (m *MockCloud) EXPECT() *MockCloudMockRecorder {
 }
 
 // AttachDisk mocks base method.
-func (m *MockCloud) AttachDisk(volumeID, nodeID string) error {
+func (m *MockCloud) AttachDisk(ctx context.Context, volumeID, nodeID string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AttachDisk", volumeID, nodeID)
+	ret := m.ctrl.Call(m, "AttachDisk", ctx, volumeID, nodeID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AttachDisk indicates an expected call of AttachDisk.
-func (mr *MockCloudMockRecorder) AttachDisk(volumeID, nodeID interface{}) *gomock.Call {
+func (mr *MockCloudMockRecorder) AttachDisk(ctx, volumeID, nodeID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AttachDisk", reflect.TypeOf((*MockCloud)(nil).AttachDisk), volumeID, nodeID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AttachDisk", reflect.TypeOf((*MockCloud)(nil).AttachDisk), ctx, volumeID, nodeID)
 }
 
 // CreateDisk mocks base method.
-func (m *MockCloud) CreateDisk(volumeName string, diskOptions *cloud.DiskOptions) (*cloud.Disk, error) {
+func (m *MockCloud) CreateDisk(ctx context.Context, volumeName string, diskOptions *cloud.DiskOptions) (*cloud.Disk, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateDisk", volumeName, diskOptions)
+	ret := m.ctrl.Call(m, "CreateDisk", ctx, volumeName, diskOptions)
 	ret0, _ := ret[0].(*cloud.Disk)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateDisk indicates an expected call of CreateDisk.
-func (mr *MockCloudMockRecorder) CreateDisk(volumeName, diskOptions interface{}) *gomock.Call {
+func (mr *MockCloudMockRecorder) CreateDisk(ctx, volumeName, diskOptions interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDisk", reflect.TypeOf((*MockCloud)(nil).CreateDisk), volumeName, diskOptions)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDisk", reflect.TypeOf((*MockCloud)(nil).CreateDisk), ctx, volumeName, diskOptions)
 }
 
 // DeleteDisk mocks base method.
-func (m *MockCloud) DeleteDisk(volumeID string) (bool, error) {
+func (m *MockCloud) DeleteDisk(ctx context.Context, volumeID string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteDisk", volumeID)
+	ret := m.ctrl.Call(m, "DeleteDisk", ctx, volumeID)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DeleteDisk indicates an expected call of DeleteDisk.
-func (mr *MockCloudMockRecorder) DeleteDisk(volumeID interface{}) *gomock.Call {
+func (mr *MockCloudMockRecorder) DeleteDisk(ctx, volumeID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDisk", reflect.TypeOf((*MockCloud)(nil).DeleteDisk), volumeID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDisk", reflect.TypeOf((*MockCloud)(nil).DeleteDisk), ctx, volumeID)
 }
 
 // DetachDisk mocks base method.
-func (m *MockCloud) DetachDisk(volumeID, nodeID string) error {
+func (m *MockCloud) DetachDisk(ctx context.Context, volumeID, nodeID string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DetachDisk", volumeID, nodeID)
+	ret := m.ctrl.Call(m, "DetachDisk", ctx, volumeID, nodeID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DetachDisk indicates an expected call of DetachDisk.
-func (mr *MockCloudMockRecorder) DetachDisk(volumeID, nodeID interface{}) *gomock.Call {
+func (mr *MockCloudMockRecorder) DetachDisk(ctx, volumeID, nodeID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DetachDisk", reflect.TypeOf((*MockCloud)(nil).DetachDisk), volumeID, nodeID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DetachDisk", reflect.TypeOf((*MockCloud)(nil).DetachDisk), ctx, volumeID, nodeID)
 }
 
 // GetDiskByID mocks base method.
-func (m *MockCloud) GetDiskByID(volumeID string) (*cloud.Disk, error) {
+func (m *MockCloud) GetDiskByID(ctx context.Context, volumeID string) (*cloud.Disk, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetDiskByID", volumeID)
+	ret := m.ctrl.Call(m, "GetDiskByID", ctx, volumeID)
 	ret0, _ := ret[0].(*cloud.Disk)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetDiskByID indicates an expected call of GetDiskByID.
-func (mr *MockCloudMockRecorder) GetDiskByID(volumeID interface{}) *gomock.Call {
+func (mr *MockCloudMockRecorder) GetDiskByID(ctx, volumeID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiskByID", reflect.TypeOf((*MockCloud)(nil).GetDiskByID), volumeID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiskByID", reflect.TypeOf((*MockCloud)(nil).GetDiskByID), ctx, volumeID)
 }
 
 // GetDiskByName mocks base method.
-func (m *MockCloud) GetDiskByName(name string) (*cloud.Disk, error) {
+func (m *MockCloud) GetDiskByName(ctx context.Context, name string) (*cloud.Disk, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetDiskByName", name)
+	ret := m.ctrl.Call(m, "GetDiskByName", ctx, name)
 	ret0, _ := ret[0].(*cloud.Disk)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetDiskByName indicates an expected call of GetDiskByName.
-func (mr *MockCloudMockRecorder) GetDiskByName(name interface{}) *gomock.Call {
+func (mr *MockCloudMockRecorder) GetDiskByName(ctx, name interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiskByName", reflect.TypeOf((*MockCloud)(nil).GetDiskByName), name)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiskByName", reflect.TypeOf((*MockCloud)(nil).GetDiskByName), ctx, name)
 }
 
 // GetImageByID mocks base method.
-func (m *MockCloud) GetImageByID(imageID string) (*cloud.PVMImage, error) {
+func (m *MockCloud) GetImageByID(ctx context.Context, imageID string) (*cloud.PVMImage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetImageByID", imageID)
+	ret := m.ctrl.Call(m, "GetImageByID", ctx, imageID)
 	ret0, _ := ret[0].(*cloud.PVMImage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetImageByID indicates an expected call of GetImageByID.
-func (mr *MockCloudMockRecorder) GetImageByID(imageID interface{}) *gomock.Call {
+func (mr *MockCloudMockRecorder) GetImageByID(ctx, imageID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageByID", reflect.TypeOf((*MockCloud)(nil).GetImageByID), imageID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageByID", reflect.TypeOf((*MockCloud)(nil).GetImageByID), ctx, imageID)
 }
 
 // GetPVMInstanceByID mocks base method.
-func (m *MockCloud) GetPVMInstanceByID(instanceID string) (*cloud.PVMInstance, error) {
+func (m *MockCloud) GetPVMInstanceByID(ctx context.Context, instanceID string) (*cloud.PVMInstance, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPVMInstanceByID", instanceID)
+	ret := m.ctrl.Call(m, "GetPVMInstanceByID", ctx, instanceID)
 	ret0, _ := ret[0].(*cloud.PVMInstance)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPVMInstanceByID indicates an expected call of GetPVMInstanceByID.
-func (mr *MockCloudMockRecorder) GetPVMInstanceByID(instanceID interface{}) *gomock.Call {
+func (mr *MockCloudMockRecorder) GetPVMInstanceByID(ctx, instanceID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPVMInstanceByID", reflect.TypeOf((*MockCloud)(nil).GetPVMInstanceByID), instanceID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPVMInstanceByID", reflect.TypeOf((*MockCloud)(nil).GetPVMInstanceByID), ctx, instanceID)
 }
 
 // GetPVMInstanceByName mocks base method.
-func (m *MockCloud) GetPVMInstanceByName(instanceName string) (*cloud.PVMInstance, error) {
+func (m *MockCloud) GetPVMInstanceByName(ctx context.Context, instanceName string) (*cloud.PVMInstance, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPVMInstanceByName", instanceName)
+	ret := m.ctrl.Call(m, "GetPVMInstanceByName", ctx, instanceName)
 	ret0, _ := ret[0].(*cloud.PVMInstance)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPVMInstanceByName indicates an expected call of GetPVMInstanceByName.
-func (mr *MockCloudMockRecorder) GetPVMInstanceByName(instanceName interface{}) *gomock.Call {
+func (mr *MockCloudMockRecorder) GetPVMInstanceByName(ctx, instanceName interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPVMInstanceByName", reflect.TypeOf((*MockCloud)(nil).GetPVMInstanceByName), instanceName)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPVMInstanceByName", reflect.TypeOf((*MockCloud)(nil).GetPVMInstanceByName), ctx, instanceName)
+}
+
+// GetStorageCapacity mocks base method.
+func (m *MockCloud) GetStorageCapacity(ctx context.Context, volumeType string) (*cloud.StorageCapacity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStorageCapacity", ctx, volumeType)
+	ret0, _ := ret[0].(*cloud.StorageCapacity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStorageCapacity indicates an expected call of GetStorageCapacity.
+func (mr *MockCloudMockRecorder) GetStorageCapacity(ctx, volumeType interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStorageCapacity", reflect.TypeOf((*MockCloud)(nil).GetStorageCapacity), ctx, volumeType)
+}
+
+// GetStoragePoolCapacity mocks base method.
+func (m *MockCloud) GetStoragePoolCapacity(ctx context.Context, pool string) (*cloud.StorageCapacity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStoragePoolCapacity", ctx, pool)
+	ret0, _ := ret[0].(*cloud.StorageCapacity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStoragePoolCapacity indicates an expected call of GetStoragePoolCapacity.
+func (mr *MockCloudMockRecorder) GetStoragePoolCapacity(ctx, pool interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStoragePoolCapacity", reflect.TypeOf((*MockCloud)(nil).GetStoragePoolCapacity), ctx, pool)
 }
 
 // IsAttached mocks base method.
-func (m *MockCloud) IsAttached(volumeID, nodeID string) (bool, error) {
+func (m *MockCloud) IsAttached(ctx context.Context, volumeID, nodeID string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IsAttached", volumeID, nodeID)
+	ret := m.ctrl.Call(m, "IsAttached", ctx, volumeID, nodeID)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // IsAttached indicates an expected call of IsAttached.
-func (mr *MockCloudMockRecorder) IsAttached(volumeID, nodeID interface{}) *gomock.Call {
+func (mr *MockCloudMockRecorder) IsAttached(ctx, volumeID, nodeID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsAttached", reflect.TypeOf((*MockCloud)(nil).IsAttached), volumeID, nodeID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsAttached", reflect.TypeOf((*MockCloud)(nil).IsAttached), ctx, volumeID, nodeID)
+}
+
+// ListDisks mocks base method.
+func (m *MockCloud) ListDisks(ctx context.Context) ([]*cloud.Disk, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDisks", ctx)
+	ret0, _ := ret[0].([]*cloud.Disk)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDisks indicates an expected call of ListDisks.
+func (mr *MockCloudMockRecorder) ListDisks(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDisks", reflect.TypeOf((*MockCloud)(nil).ListDisks), ctx)
 }
 
 // ResizeDisk mocks base method.
-func (m *MockCloud) ResizeDisk(volumeID string, reqSize int64) (int64, error) {
+func (m *MockCloud) ResizeDisk(ctx context.Context, volumeID string, reqSize int64) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ResizeDisk", volumeID, reqSize)
+	ret := m.ctrl.Call(m, "ResizeDisk", ctx, volumeID, reqSize)
 	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ResizeDisk indicates an expected call of ResizeDisk.
-func (mr *MockCloudMockRecorder) ResizeDisk(volumeID, reqSize interface{}) *gomock.Call {
+func (mr *MockCloudMockRecorder) ResizeDisk(ctx, volumeID, reqSize interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResizeDisk", reflect.TypeOf((*MockCloud)(nil).ResizeDisk), ctx, volumeID, reqSize)
+}
+
+// UpdateDiskTier mocks base method.
+func (m *MockCloud) UpdateDiskTier(ctx context.Context, volumeID, tier string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDiskTier", ctx, volumeID, tier)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDiskTier indicates an expected call of UpdateDiskTier.
+func (mr *MockCloudMockRecorder) UpdateDiskTier(ctx, volumeID, tier interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResizeDisk", reflect.TypeOf((*MockCloud)(nil).ResizeDisk), volumeID, reqSize)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDiskTier", reflect.TypeOf((*MockCloud)(nil).UpdateDiskTier), ctx, volumeID, tier)
 }
 
 // WaitForVolumeState mocks base method.
-func (m *MockCloud) WaitForVolumeState(volumeID, state string) error {
+func (m *MockCloud) WaitForVolumeState(ctx context.Context, volumeID, state string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "WaitForVolumeState", volumeID, state)
+	ret := m.ctrl.Call(m, "WaitForVolumeState", ctx, volumeID, state)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // WaitForVolumeState indicates an expected call of WaitForVolumeState.
-func (mr *MockCloudMockRecorder) WaitForVolumeState(volumeID, state interface{}) *gomock.Call {
+func (mr *MockCloudMockRecorder) WaitForVolumeState(ctx, volumeID, state interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WaitForVolumeState", reflect.TypeOf((*MockCloud)(nil).WaitForVolumeState), volumeID, state)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WaitForVolumeState", reflect.TypeOf((*MockCloud)(nil).WaitForVolumeState), ctx, volumeID, state)
 }
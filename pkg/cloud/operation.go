@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"io/ioutil"
+
+	"github.com/IBM-Cloud/power-go-client/clients/instance"
+	"github.com/IBM-Cloud/power-go-client/ibmpisession"
+	"github.com/go-openapi/runtime"
+	"github.com/go-openapi/strfmt"
+)
+
+// volClient returns the volume client of the cloud instance whose requests are canceled with ctx.
+// The power-go-client clients send their requests with the context they are created with, so
+// they are created for every call.
+func (p *powerVSCloud) volClient(ctx context.Context) *instance.IBMPIVolumeClient {
+	return instance.NewIBMPIVolumeClient(ctx, p.piSession, p.cloudInstanceID)
+}
+
+// pvmInstancesClient returns the pvm instance client of the cloud instance bound to ctx
+func (p *powerVSCloud) pvmInstancesClient(ctx context.Context) *instance.IBMPIInstanceClient {
+	return instance.NewIBMPIInstanceClient(ctx, p.piSession, p.cloudInstanceID)
+}
+
+// imageClient returns the image client of the cloud instance bound to ctx
+func (p *powerVSCloud) imageClient(ctx context.Context) *instance.IBMPIImageClient {
+	return instance.NewIBMPIImageClient(ctx, p.piSession, p.cloudInstanceID)
+}
+
+// cloudInstanceClient returns the cloud instance client bound to ctx
+func (p *powerVSCloud) cloudInstanceClient(ctx context.Context) *instance.IBMPICloudInstanceClient {
+	return instance.NewIBMPICloudInstanceClient(ctx, p.piSession, p.cloudInstanceID)
+}
+
+// capacityClient returns the storage capacity client of the cloud instance bound to ctx
+func (p *powerVSCloud) capacityClient(ctx context.Context) *instance.IBMPIStorageCapacityClient {
+	return instance.NewIBMPIStorageCapacityClient(ctx, p.piSession, p.cloudInstanceID)
+}
+
+// cloneClient returns the volume clone client of the cloud instance bound to ctx
+func (p *powerVSCloud) cloneClient(ctx context.Context) *instance.IBMPICloneVolumeClient {
+	return instance.NewIBMPICloneVolumeClient(ctx, p.piSession, p.cloudInstanceID)
+}
+
+// submitOperation sends a request to a PowerVS API which is not (yet) covered by the
+// generated power-go-client operations, reusing the session transport and authentication.
+// The request is canceled with ctx. If out is not nil, a successful JSON response is decoded
+// into it.
+func (p *powerVSCloud) submitOperation(ctx context.Context, id, method, path string, body, out interface{}) error {
+	_, err := p.piSession.Power.Transport.Submit(&runtime.ClientOperation{
+		ID:                 id,
+		Method:             method,
+		PathPattern:        path,
+		ProducesMediaTypes: []string{"application/json"},
+		ConsumesMediaTypes: []string{"application/json"},
+		Schemes:            []string{"https"},
+		Params: runtime.ClientRequestWriterFunc(func(r runtime.ClientRequest, _ strfmt.Registry) error {
+			if body == nil {
+				return nil
+			}
+			return r.SetBodyParam(body)
+		}),
+		Reader: runtime.ClientResponseReaderFunc(func(resp runtime.ClientResponse, consumer runtime.Consumer) (interface{}, error) {
+			if resp.Code() < 200 || resp.Code() > 299 {
+				payload, _ := ioutil.ReadAll(resp.Body())
+				return nil, runtime.NewAPIError(id, string(payload), resp.Code())
+			}
+			if out != nil {
+				if err := consumer.Consume(resp.Body(), out); err != nil {
+					return nil, err
+				}
+			}
+			return nil, nil
+		}),
+		AuthInfo: ibmpisession.NewAuth(p.piSession, p.cloudInstanceID),
+		Context:  ctx,
+	})
+	return err
+}
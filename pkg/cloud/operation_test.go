@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM-Cloud/power-go-client/ibmpisession"
+	"github.com/IBM-Cloud/power-go-client/power/client"
+	httptransport "github.com/go-openapi/runtime/client"
+)
+
+func TestUpdateDiskTier(t *testing.T) {
+	testCases := []struct {
+		name      string
+		status    int
+		expectErr bool
+	}{
+		{name: "accepted", status: http.StatusAccepted},
+		{name: "conflict", status: http.StatusConflict, expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var method, path, auth, body string
+			srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method, path, auth = r.Method, r.URL.Path, r.Header.Get("Authorization")
+				b, _ := ioutil.ReadAll(r.Body)
+				body = strings.TrimSpace(string(b))
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			transport := httptransport.New(strings.TrimPrefix(srv.URL, "https://"), "/", []string{"https"})
+			transport.Transport = srv.Client().Transport
+			p := &powerVSCloud{
+				cloudInstanceID: "ws-1",
+				piSession:       &ibmpisession.IBMPISession{IAMToken: "Bearer token", Power: client.New(transport, nil)},
+				tuning:          NewTuning(),
+				breaker:         newCircuitBreaker("ws-1", 5, time.Minute, func() error { return nil }),
+			}
+
+			err := p.UpdateDiskTier(context.Background(), "vol-1", "tier1")
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.expectErr, err)
+			}
+			if method != http.MethodPost {
+				t.Fatalf("expected method POST, got %s", method)
+			}
+			if expected := "/pcloud/v1/cloud-instances/ws-1/volumes/vol-1/action"; path != expected {
+				t.Fatalf("expected path %s, got %s", expected, path)
+			}
+			if auth != "Bearer token" {
+				t.Fatalf("expected the session token, got %q", auth)
+			}
+			if expected := `{"targetStorageTier":"tier1"}`; body != expected {
+				t.Fatalf("expected body %s, got %s", expected, body)
+			}
+		})
+	}
+}
+
+func TestCallCanceledWithContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+
+	transport := httptransport.New(strings.TrimPrefix(srv.URL, "https://"), "/", []string{"https"})
+	transport.Transport = srv.Client().Transport
+	p := &powerVSCloud{
+		cloudInstanceID: "ws-1",
+		piSession:       &ibmpisession.IBMPISession{IAMToken: "Bearer token", Power: client.New(transport, nil)},
+		tuning:          NewTuning(),
+		breaker:         newCircuitBreaker("ws-1", 5, time.Minute, func() error { return nil }),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.ListDisks(ctx); err == nil {
+		t.Fatal("expected the request to fail with the context")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the request to be canceled with the context, it took %v", elapsed)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"time"
+)
+
+// Operation is a volume operation whose PowerVS job can be given a timeout of its own, as
+// the job latencies differ widely between them
+type Operation string
+
+const (
+	OperationCreate Operation = "create"
+	OperationDelete Operation = "delete"
+	OperationAttach Operation = "attach"
+	OperationDetach Operation = "detach"
+	OperationExpand Operation = "expand"
+)
+
+// Operations are the operations with a timeout of their own
+var Operations = []Operation{OperationCreate, OperationDelete, OperationAttach, OperationDetach, OperationExpand}
+
+// WithOperationTimeouts bounds the operations of timeouts, including the retries of their API
+// calls and the wait for the volume state. Operations without a timeout retry their calls as
+// long as the backoff allows and wait for the volume state timeout.
+func WithOperationTimeouts(timeouts map[Operation]time.Duration) func(*Options) {
+	return func(o *Options) {
+		merged := make(map[Operation]time.Duration, len(o.operationTimeouts)+len(timeouts))
+		for op, timeout := range o.operationTimeouts {
+			merged[op] = timeout
+		}
+		for op, timeout := range timeouts {
+			if timeout > 0 {
+				merged[op] = timeout
+			}
+		}
+		o.operationTimeouts = merged
+	}
+}
+
+func (t *Tuning) operationTimeout(op Operation) time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.operationTimeouts[op]
+}
+
+// operationContext bounds ctx by the timeout of op, ctx is returned as is when op has none
+func (p *powerVSCloud) operationContext(ctx context.Context, op Operation) (context.Context, context.CancelFunc) {
+	if timeout := p.tuning.operationTimeout(op); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// stateTimeout returns how long op waits for a volume state, what is left of the operation
+// context ctx when op has a timeout or else the volume state timeout
+func (p *powerVSCloud) stateTimeout(ctx context.Context, op Operation) time.Duration {
+	if p.tuning.operationTimeout(op) > 0 {
+		if deadline, ok := ctx.Deadline(); ok {
+			return time.Until(deadline)
+		}
+	}
+	return p.tuning.stateTimeout()
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestOperationTimeouts(t *testing.T) {
+	p := &powerVSCloud{
+		tuning: NewTuning(WithVolumeStateTimeout(5*time.Minute), WithOperationTimeouts(map[Operation]time.Duration{
+			OperationCreate: 10 * time.Minute,
+			OperationDelete: 0,
+		})),
+	}
+	if timeout := p.tuning.operationTimeout(OperationCreate); timeout != 10*time.Minute {
+		t.Fatalf("expected create timeout 10m, got %v", timeout)
+	}
+	if _, ok := p.tuning.operationTimeouts[OperationDelete]; ok {
+		t.Fatalf("expected no delete timeout")
+	}
+
+	ctx, cancel := p.operationContext(context.Background(), OperationCreate)
+	defer cancel()
+	if timeout := p.stateTimeout(ctx, OperationCreate); timeout <= 9*time.Minute || timeout > 10*time.Minute {
+		t.Fatalf("expected the create to wait for the rest of its 10m timeout, got %v", timeout)
+	}
+	ctx, cancel = p.operationContext(context.Background(), OperationAttach)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatalf("expected no deadline for the attach without timeout")
+	}
+	// the deadlines of the CO don't shorten the wait of operations without timeout
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if timeout := p.stateTimeout(ctx, OperationAttach); timeout != 5*time.Minute {
+		t.Fatalf("expected the attach to wait for the volume state timeout, got %v", timeout)
+	}
+
+	p.tuning.Update(WithVolumeStateTimeout(5 * time.Minute))
+	if timeout := p.tuning.operationTimeout(OperationCreate); timeout != 0 {
+		t.Fatalf("expected the create timeout to be reset, got %v", timeout)
+	}
+}
+
+func TestCallStopsRetryingOnTimeout(t *testing.T) {
+	p := &powerVSCloud{
+		tuning:  NewTuning(WithRetryBackoff(time.Millisecond, 5)),
+		breaker: newCircuitBreaker("ws-1", 5, time.Minute, func() error { return nil }),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	err := p.call(ctx, "DeleteVolume", IsRetryableError, func() error {
+		attempts++
+		cancel()
+		return fmt.Errorf("read: %w", syscall.ECONNRESET)
+	})
+	if err == nil || attempts != 1 {
+		t.Fatalf("expected a single failed attempt, got %d attempts and error %v", attempts, err)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Options are the optional settings of the PowerVS cloud client
+type Options struct {
+	// apiEndpoints are the PowerVS API endpoints to fail over between, the regional
+	// endpoint of the cloud instance is used when empty
+	apiEndpoints []string
+	// volumeStateTimeout is how long WaitForVolumeState waits for a volume state
+	volumeStateTimeout time.Duration
+	// volumeStatePollInterval is the interval at which volume states are polled
+	volumeStatePollInterval time.Duration
+	// apiKeyFile is a file holding the IBM Cloud API key which is watched for rotation,
+	// the key is read from APIKeyEnv when empty
+	apiKeyFile string
+	// apiKey is the IBM Cloud API key, it takes precedence over apiKeyFile and a trusted profile
+	apiKey string
+	// trustedProfileID or trustedProfileName select a trusted profile to authenticate with
+	// instead of an API key, using the compute resource token in crTokenFile
+	trustedProfileID   string
+	trustedProfileName string
+	crTokenFile        string
+	// serviceEndpoints override the endpoints of the IBM Cloud services
+	serviceEndpoints ServiceEndpoints
+	// backoff is used to retry throttled and transient PowerVS API errors
+	backoff wait.Backoff
+	// slowCallThreshold is the duration above which cloud calls are logged as slow, 0 disables it
+	slowCallThreshold time.Duration
+	// tuning replaces the volume state, retry and slow call settings above with shared ones
+	tuning *Tuning
+	// pollPool polls long running operations, the client creates its own when nil
+	pollPool *PollPool
+	// apiCallTimeout bounds every PowerVS and IAM HTTP request, DefaultFastCallTimeout and
+	// DefaultSlowCallTimeout apply when 0
+	apiCallTimeout time.Duration
+	// operationTimeouts bound the operations with a timeout of their own, see WithOperationTimeouts
+	operationTimeouts map[Operation]time.Duration
+}
+
+func defaultOptions() Options {
+	return Options{
+		volumeStateTimeout:      PollTimeout,
+		volumeStatePollInterval: PollInterval,
+		backoff:                 DefaultBackoff,
+	}
+}
+
+// clientTuning returns the shared tuning of WithTuning, else a tuning of the volume state,
+// retry and slow call settings of o
+func (o *Options) clientTuning() *Tuning {
+	if o.tuning != nil {
+		return o.tuning
+	}
+	t := &Tuning{}
+	t.set(o)
+	return t
+}
+
+func WithAPIEndpoints(endpoints []string) func(*Options) {
+	return func(o *Options) {
+		o.apiEndpoints = endpoints
+	}
+}
+
+func WithAPIKeyFile(file string) func(*Options) {
+	return func(o *Options) {
+		o.apiKeyFile = file
+	}
+}
+
+// WithAPIKey authenticates with apikey instead of the key of the API key file or the
+// environment, or a trusted profile
+func WithAPIKey(apikey string) func(*Options) {
+	return func(o *Options) {
+		o.apiKey = apikey
+	}
+}
+
+// WithTrustedProfile authenticates with the trusted profile identified by profileID or
+// profileName instead of an API key
+func WithTrustedProfile(profileID, profileName, crTokenFile string) func(*Options) {
+	return func(o *Options) {
+		o.trustedProfileID = profileID
+		o.trustedProfileName = profileName
+		o.crTokenFile = crTokenFile
+	}
+}
+
+func WithServiceEndpoints(endpoints ServiceEndpoints) func(*Options) {
+	return func(o *Options) {
+		o.serviceEndpoints = endpoints
+	}
+}
+
+func WithVolumeStateTimeout(timeout time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.volumeStateTimeout = timeout
+	}
+}
+
+func WithVolumeStatePollInterval(interval time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.volumeStatePollInterval = interval
+	}
+}
+
+// WithRetryBackoff sets the initial delay and the number of attempts of PowerVS API retries,
+// the delay doubles after every attempt
+func WithRetryBackoff(initialDelay time.Duration, steps int) func(*Options) {
+	return func(o *Options) {
+		o.backoff.Duration = initialDelay
+		o.backoff.Steps = steps
+	}
+}
+
+// WithSlowCallThreshold logs and counts the cloud calls taking longer than threshold
+func WithSlowCallThreshold(threshold time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.slowCallThreshold = threshold
+	}
+}
+
+// WithTuning makes the client use the volume state, retry and slow call settings of tuning, including
+// later updates, instead of its own
+func WithTuning(tuning *Tuning) func(*Options) {
+	return func(o *Options) {
+		o.tuning = tuning
+	}
+}
+
+// WithPollPool makes the client poll long running operations on pool, shared with the other
+// clients using it
+func WithPollPool(pool *PollPool) func(*Options) {
+	return func(o *Options) {
+		o.pollPool = pool
+	}
+}
+
+// WithAPICallTimeout fails every PowerVS and IAM HTTP request not done within timeout, 0 keeps
+// DefaultFastCallTimeout for reads and DefaultSlowCallTimeout for changes
+func WithAPICallTimeout(timeout time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.apiCallTimeout = timeout
+	}
+}
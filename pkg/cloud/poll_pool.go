@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// defaults of the poll pool
+const (
+	DefaultPollWorkers   = 10
+	DefaultPollQueueSize = 500
+)
+
+// ErrPollQueueFull is returned without polling when the poll pool already holds as many
+// operations as its queue size
+var ErrPollQueueFull = errors.New("too many PowerVS operations are waiting, poll queue is full")
+
+// pollTask is a single operation polled by the pool
+type pollTask struct {
+	ctx       context.Context
+	interval  func() time.Duration
+	condition wait.ConditionFunc
+	done      chan error
+}
+
+// PollPool runs the polling of long running operations, e.g. waiting for a volume to be
+// detached, on a fixed number of workers. Every operation is checked once per interval by
+// the next free worker, which bounds the goroutines and concurrent PowerVS API calls no
+// matter how many operations are waiting. Clients created with the same PollPool share it.
+type PollPool struct {
+	queue chan *pollTask
+	// slots holds a token per operation in the pool, which guarantees that requeueing an
+	// operation never blocks
+	slots chan struct{}
+}
+
+// NewPollPool returns a pool polling with workers goroutines which accepts up to queueSize
+// operations at a time
+func NewPollPool(workers, queueSize int) *PollPool {
+	p := &PollPool{
+		queue: make(chan *pollTask, queueSize),
+		slots: make(chan struct{}, queueSize),
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// poll runs condition immediately and then every interval until it returns true or an error,
+// or ctx is done. The deadline of ctx is the deadline of the operation, including the time
+// spent in the queue.
+func (p *PollPool) poll(ctx context.Context, interval func() time.Duration, condition wait.ConditionFunc) error {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		return ErrPollQueueFull
+	}
+	t := &pollTask{ctx: ctx, interval: interval, condition: condition, done: make(chan error, 1)}
+	p.queue <- t
+
+	select {
+	case err := <-t.done:
+		return err
+	case <-ctx.Done():
+		// the slot is released by the worker or timer seeing the expired task
+		return wait.ErrWaitTimeout
+	}
+}
+
+func (p *PollPool) work() {
+	for t := range p.queue {
+		if t.ctx.Err() != nil {
+			p.finish(t, wait.ErrWaitTimeout)
+			continue
+		}
+		done, err := t.condition()
+		switch {
+		case err != nil:
+			p.finish(t, err)
+		case done:
+			p.finish(t, nil)
+		default:
+			p.requeue(t)
+		}
+	}
+}
+
+// requeue puts t back into the queue after its interval
+func (p *PollPool) requeue(t *pollTask) {
+	time.AfterFunc(t.interval(), func() {
+		if t.ctx.Err() != nil {
+			p.finish(t, wait.ErrWaitTimeout)
+			return
+		}
+		p.queue <- t
+	})
+}
+
+func (p *PollPool) finish(t *pollTask, err error) {
+	t.done <- err
+	<-p.slots
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestPollPoolBoundsConcurrentCalls(t *testing.T) {
+	pool := NewPollPool(2, 50)
+	var inFlight, maxInFlight int32
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var checks int
+			errs <- pool.poll(context.Background(), fixedInterval(time.Millisecond), func() (bool, error) {
+				n := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					max := atomic.LoadInt32(&maxInFlight)
+					if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				checks++
+				return checks == 3, nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max > 2 {
+		t.Fatalf("expected at most 2 concurrent checks, got %d", max)
+	}
+}
+
+func TestPollPoolErrors(t *testing.T) {
+	errCondition := errors.New("condition failed")
+	testCases := []struct {
+		name        string
+		condition   wait.ConditionFunc
+		expectedErr error
+	}{
+		{
+			name: "deadline",
+			condition: func() (bool, error) {
+				return false, nil
+			},
+			expectedErr: wait.ErrWaitTimeout,
+		},
+		{
+			name: "condition error is returned",
+			condition: func() (bool, error) {
+				return false, errCondition
+			},
+			expectedErr: errCondition,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := NewPollPool(1, 1)
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			err := pool.poll(ctx, fixedInterval(10*time.Millisecond), tc.condition)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestPollPoolQueueFull(t *testing.T) {
+	pool := NewPollPool(1, 1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_ = pool.poll(context.Background(), fixedInterval(time.Millisecond), func() (bool, error) {
+			close(started)
+			<-release
+			return true, nil
+		})
+	}()
+	<-started
+
+	err := pool.poll(context.Background(), fixedInterval(time.Millisecond), func() (bool, error) {
+		return true, nil
+	})
+	if !errors.Is(err, ErrPollQueueFull) {
+		t.Fatalf("expected error %v, got %v", ErrPollQueueFull, err)
+	}
+
+	// the slot is released once the running operation is done
+	close(release)
+	if err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+		return pool.poll(context.Background(), fixedInterval(time.Millisecond), func() (bool, error) {
+			return true, nil
+		}) == nil, nil
+	}); err != nil {
+		t.Fatalf("expected the slot to be released: %v", err)
+	}
+}
@@ -19,25 +19,29 @@ import (
 	"context"
 	"fmt"
 	gohttp "net/http"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/IBM-Cloud/bluemix-go"
+	"github.com/IBM-Cloud/bluemix-go/api/globaltagging/globaltaggingv3"
 	"github.com/IBM-Cloud/bluemix-go/api/resource/resourcev2/controllerv2"
 	"github.com/IBM-Cloud/bluemix-go/authentication"
 	"github.com/IBM-Cloud/bluemix-go/http"
 	"github.com/IBM-Cloud/bluemix-go/rest"
 	bxsession "github.com/IBM-Cloud/bluemix-go/session"
-	"github.com/IBM-Cloud/power-go-client/clients/instance"
 	"github.com/IBM-Cloud/power-go-client/errors"
 	"github.com/IBM-Cloud/power-go-client/ibmpisession"
 	"github.com/IBM-Cloud/power-go-client/power/client/p_cloud_volumes"
 	"github.com/IBM-Cloud/power-go-client/power/models"
-	"github.com/davecgh/go-spew/spew"
+	"github.com/IBM/go-sdk-core/v5/core"
+	httptransport "github.com/go-openapi/runtime/client"
 	"github.com/golang-jwt/jwt"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/klog/v2"
 	"k8s.io/utils/pointer"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/metrics"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/tracing"
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
 )
 
@@ -49,21 +53,40 @@ const (
 	TIMEOUT              = 60 * time.Minute
 	VolumeInUseState     = "in-use"
 	VolumeAvailableState = "available"
+	VolumeErrorState     = "error"
 )
 
 type PowerVSClient interface {
 }
 
 type powerVSCloud struct {
+	// sessMu guards bxSess and tagClient, which are rebuilt when the API key is rotated
+	sessMu    sync.RWMutex
 	bxSess    *bxsession.Session
 	piSession *ibmpisession.IBMPISession
+	auth      *apiKeyAuthenticator
+	// profileAuth is set instead of auth when authenticating with a trusted profile
+	profileAuth *core.ContainerAuthenticator
+	// serviceEndpoints and timeouts are used when the IBM Cloud session is rebuilt
+	serviceEndpoints ServiceEndpoints
+	timeouts         callTimeouts
 
 	cloudInstanceID string
+	zone            string
+	accountID       string
 
-	imageClient        *instance.IBMPIImageClient
-	pvmInstancesClient *instance.IBMPIInstanceClient
-	resourceClient     controllerv2.ResourceServiceInstanceRepository
-	volClient          *instance.IBMPIVolumeClient
+	resourceClient controllerv2.ResourceServiceInstanceRepository
+	tagClient      globaltaggingv3.Tags
+
+	instanceCache *ttlCache
+	imageCache    *ttlCache
+	volumePoller  *volumeStatePoller
+	pollPool      *PollPool
+
+	tuning  *Tuning
+	breaker *circuitBreaker
+	// keyWatcher reloads the API key file, nil if the API key isn't read from a file
+	keyWatcher *apiKeyWatcher
 }
 
 type User struct {
@@ -79,6 +102,26 @@ type PVMInstance struct {
 	ID      string
 	ImageID string
 	Name    string
+	// Status is the PowerVS state of the instance, e.g. InstanceActiveState
+	Status string
+}
+
+// states of pvm instances
+const (
+	InstanceActiveState  = "ACTIVE"
+	InstanceShutoffState = "SHUTOFF"
+	InstanceBuildState   = "BUILD"
+	InstanceErrorState   = "ERROR"
+)
+
+// IsAttachableInstanceState returns false for the states of pvm instances PowerVS attaches
+// no volumes to, unknown states are left to PowerVS
+func IsAttachableInstanceState(state string) bool {
+	switch strings.ToUpper(state) {
+	case InstanceShutoffState, InstanceBuildState, InstanceErrorState:
+		return false
+	}
+	return true
 }
 
 type PVMImage struct {
@@ -87,12 +130,13 @@ type PVMImage struct {
 	DiskType string
 }
 
-func authenticateAPIKey(sess *bxsession.Session) error {
+func authenticateAPIKey(sess *bxsession.Session, client *gohttp.Client) error {
 	config := sess.Config
 	tokenRefresher, err := authentication.NewIAMAuthRepository(config, &rest.Client{
 		DefaultHeader: gohttp.Header{
 			"User-Agent": []string{http.UserAgent()},
 		},
+		HTTPClient: client,
 	})
 	if err != nil {
 		return err
@@ -136,20 +180,48 @@ func fetchUserDetails(sess *bxsession.Session, generation int) (*User, error) {
 	return &user, nil
 }
 
-func NewPowerVSCloud(cloudInstanceID string, debug bool) (Cloud, error) {
-	return newPowerVSCloud(cloudInstanceID, debug)
-}
-
-func newPowerVSCloud(cloudInstanceID string, debug bool) (Cloud, error) {
-	apikey := os.Getenv("IBMCLOUD_API_KEY")
-	bxSess, err := bxsession.New(&bluemix.Config{BluemixAPIKey: apikey})
-	if err != nil {
-		return nil, err
+func NewPowerVSCloud(cloudInstanceID string, debug bool, options ...func(*Options)) (Cloud, error) {
+	cloudOptions := defaultOptions()
+	for _, option := range options {
+		option(&cloudOptions)
 	}
+	return newPowerVSCloud(cloudInstanceID, debug, &cloudOptions)
+}
 
-	err = authenticateAPIKey(bxSess)
-	if err != nil {
-		return nil, err
+func newPowerVSCloud(cloudInstanceID string, debug bool, options *Options) (Cloud, error) {
+	var (
+		apikey      string
+		bxSess      *bxsession.Session
+		auth        *apiKeyAuthenticator
+		profileAuth *core.ContainerAuthenticator
+		err         error
+	)
+	timeouts := newCallTimeouts(options.apiCallTimeout)
+	if options.apiKey == "" && (options.trustedProfileID != "" || options.trustedProfileName != "") {
+		profileAuth, err = newTrustedProfileAuthenticator(options.trustedProfileID, options.trustedProfileName, options.crTokenFile, options.serviceEndpoints.IAM, timeouts.iamClient())
+		if err != nil {
+			return nil, err
+		}
+		bxSess, err = newTrustedProfileSession(profileAuth, timeouts.serviceClient())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		apikey = options.apiKey
+		if apikey == "" {
+			apikey, err = readAPIKey(options.apiKeyFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		bxSess, err = newBluemixSession(apikey, options.serviceEndpoints.IAM, timeouts)
+		if err != nil {
+			return nil, err
+		}
+		auth, err = newAPIKeyAuthenticator(apikey, options.serviceEndpoints.IAM, timeouts.iamClient())
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	user, err := fetchUserDetails(bxSess, 2)
@@ -157,7 +229,7 @@ func newPowerVSCloud(cloudInstanceID string, debug bool) (Cloud, error) {
 		return nil, err
 	}
 
-	ctrlv2, err := controllerv2.New(bxSess)
+	ctrlv2, err := controllerv2.New(withEndpoint(bxSess, options.serviceEndpoints.ResourceController))
 	if err != nil {
 		return nil, err
 	}
@@ -173,29 +245,145 @@ func newPowerVSCloud(cloudInstanceID string, debug bool) (Cloud, error) {
 	if err != nil {
 		return nil, err
 	}
-	piSession, err := ibmpisession.New(bxSess.Config.IAMAccessToken, region, debug, user.Account, zone)
+	// the debug dumps of the client include the Authorization header, debug requests are
+	// logged redacted by debugTransport instead
+	piSession, err := ibmpisession.New(bxSess.Config.IAMAccessToken, region, false, user.Account, zone)
+	if err != nil {
+		return nil, err
+	}
+	if profileAuth != nil {
+		piSession.Authenticator = profileAuth
+	} else {
+		piSession.Authenticator = auth
+	}
+	rt, ok := piSession.Power.Transport.(*httptransport.Runtime)
+	if !ok {
+		return nil, fmt.Errorf("unexpected PowerVS client transport %T", piSession.Power.Transport)
+	}
+	rt.Transport = newCallTimeoutTransport(rt.Transport, timeouts)
+	if debug {
+		rt.Transport = newDebugTransport(rt.Transport)
+	}
+	if len(options.apiEndpoints) > 0 {
+		rt.Host = endpointHost(options.apiEndpoints[0])
+		rt.Transport = newEndpointFailover(options.apiEndpoints, rt.Transport)
+	}
+	apiHosts := []string{rt.Host}
+	if len(options.apiEndpoints) > 0 {
+		apiHosts = options.apiEndpoints
+	}
+	logEndpoints(bxSess, apiHosts, options.serviceEndpoints)
+
+	tagging, err := globaltaggingv3.New(withEndpoint(bxSess, options.serviceEndpoints.GlobalTagging))
 	if err != nil {
 		return nil, err
 	}
 
-	backgroundContext := context.Background()
-	volClient := instance.NewIBMPIVolumeClient(backgroundContext, piSession, cloudInstanceID)
-	pvmInstancesClient := instance.NewIBMPIInstanceClient(backgroundContext, piSession, cloudInstanceID)
-	imageClient := instance.NewIBMPIImageClient(backgroundContext, piSession, cloudInstanceID)
+	p := &powerVSCloud{
+		bxSess:           bxSess,
+		piSession:        piSession,
+		auth:             auth,
+		profileAuth:      profileAuth,
+		serviceEndpoints: options.serviceEndpoints,
+		timeouts:         timeouts,
+		cloudInstanceID:  cloudInstanceID,
+		zone:             zone,
+		accountID:        user.Account,
+		resourceClient:   resourceClient,
+		tagClient:        tagging.Tags(),
+		instanceCache:    newTTLCache(DefaultCacheTTL),
+		imageCache:       newTTLCache(DefaultCacheTTL),
+		tuning:           options.clientTuning(),
+		pollPool:         options.pollPool,
+	}
+	if p.pollPool == nil {
+		p.pollPool = NewPollPool(DefaultPollWorkers, DefaultPollQueueSize)
+	}
+	p.volumePoller = newVolumeStatePoller(p.tuning.pollInterval, p.listVolumeStates)
+	p.breaker = newCircuitBreaker(cloudInstanceID, DefaultBreakerThreshold, DefaultBreakerProbeInterval, func() error {
+		_, err := p.cloudInstanceClient(context.Background()).Get(cloudInstanceID)
+		return err
+	})
+	if profileAuth == nil && options.apiKey == "" && options.apiKeyFile != "" {
+		if p.keyWatcher, err = newAPIKeyWatcher(options.apiKeyFile, apikey, p.rotateAPIKey); err != nil {
+			p.breaker.stop()
+			return nil, fmt.Errorf("could not watch API key file: %v", err)
+		}
+	}
+	return p, nil
+}
 
-	return &powerVSCloud{
-		bxSess:             bxSess,
-		piSession:          piSession,
-		cloudInstanceID:    cloudInstanceID,
-		imageClient:        imageClient,
-		pvmInstancesClient: pvmInstancesClient,
-		resourceClient:     resourceClient,
-		volClient:          volClient,
-	}, nil
+func newBluemixSession(apikey, iamEndpoint string, timeouts callTimeouts) (*bxsession.Session, error) {
+	config := &bluemix.Config{BluemixAPIKey: apikey, HTTPClient: timeouts.serviceClient()}
+	if iamEndpoint != "" {
+		config.TokenProviderEndpoint = &iamEndpoint
+	}
+	bxSess, err := bxsession.New(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := authenticateAPIKey(bxSess, timeouts.iamClient()); err != nil {
+		return nil, err
+	}
+	return bxSess, nil
+}
+
+// Close stops the background work of the client, the probes of its circuit breaker and the
+// watch of its API key file. It implements io.Closer for clients that are discarded, the
+// client must not be used afterwards.
+func (p *powerVSCloud) Close() error {
+	p.breaker.stop()
+	if p.keyWatcher != nil {
+		return p.keyWatcher.stop()
+	}
+	return nil
+}
+
+// rotateAPIKey switches all clients to a new API key, the PowerVS clients pick it up
+// through the shared authenticator and the IBM Cloud session is rebuilt
+func (p *powerVSCloud) rotateAPIKey(apikey string) error {
+	bxSess, err := newBluemixSession(apikey, p.serviceEndpoints.IAM, p.timeouts)
+	if err != nil {
+		return err
+	}
+	tagging, err := globaltaggingv3.New(withEndpoint(bxSess, p.serviceEndpoints.GlobalTagging))
+	if err != nil {
+		return err
+	}
+	if err := p.auth.update(apikey); err != nil {
+		return err
+	}
+	p.sessMu.Lock()
+	defer p.sessMu.Unlock()
+	p.bxSess = bxSess
+	p.tagClient = tagging.Tags()
+	return nil
+}
+
+func (p *powerVSCloud) tags() (globaltaggingv3.Tags, error) {
+	if p.profileAuth != nil {
+		// the IBM Cloud session can't refresh trusted profile tokens, use a current one
+		bxSess, err := newTrustedProfileSession(p.profileAuth, p.timeouts.serviceClient())
+		if err != nil {
+			return nil, err
+		}
+		tagging, err := globaltaggingv3.New(withEndpoint(bxSess, p.serviceEndpoints.GlobalTagging))
+		if err != nil {
+			return nil, err
+		}
+		return tagging.Tags(), nil
+	}
+	p.sessMu.RLock()
+	defer p.sessMu.RUnlock()
+	return p.tagClient, nil
 }
 
-func (p *powerVSCloud) GetPVMInstanceByName(name string) (*PVMInstance, error) {
-	in, err := p.pvmInstancesClient.GetAll()
+func (p *powerVSCloud) GetPVMInstanceByName(ctx context.Context, name string) (*PVMInstance, error) {
+	var in *models.PVMInstances
+	err := p.call(ctx, "GetPVMInstances", IsRetryableError, func() (err error) {
+		in, err = p.pvmInstancesClient(ctx).GetAll()
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -211,37 +399,111 @@ func (p *powerVSCloud) GetPVMInstanceByName(name string) (*PVMInstance, error) {
 	return nil, ErrNotFound
 }
 
-func (p *powerVSCloud) GetPVMInstanceByID(instanceID string) (*PVMInstance, error) {
-	in, err := p.pvmInstancesClient.Get(instanceID)
+func (p *powerVSCloud) GetPVMInstanceByID(ctx context.Context, instanceID string) (*PVMInstance, error) {
+	if cached, ok := p.instanceCache.Get(instanceID); ok {
+		instance := cached.(PVMInstance)
+		return &instance, nil
+	}
+
+	var in *models.PVMInstance
+	err := p.call(ctx, "GetPVMInstance", IsRetryableError, func() (err error) {
+		in, err = p.pvmInstancesClient(ctx).Get(instanceID)
+		return err
+	})
 	if err != nil {
+		if HTTPStatusCode(err) == gohttp.StatusNotFound {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
 		return nil, err
 	}
 
-	return &PVMInstance{
+	instance := PVMInstance{
 		ID:      *in.PvmInstanceID,
 		ImageID: *in.ImageID,
 		Name:    *in.ServerName,
-	}, nil
+	}
+	if in.Status != nil {
+		instance.Status = *in.Status
+	}
+	p.instanceCache.Set(instanceID, instance)
+	return &instance, nil
 }
 
-func (p *powerVSCloud) GetImageByID(imageID string) (*PVMImage, error) {
-	image, err := p.imageClient.Get(imageID)
+func (p *powerVSCloud) GetImageByID(ctx context.Context, imageID string) (*PVMImage, error) {
+	if cached, ok := p.imageCache.Get(imageID); ok {
+		image := cached.(PVMImage)
+		return &image, nil
+	}
+
+	var image *models.Image
+	err := p.call(ctx, "GetImage", IsRetryableError, func() (err error) {
+		image, err = p.imageClient(ctx).Get(imageID)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	return &PVMImage{
+	pvmImage := PVMImage{
 		ID:       *image.ImageID,
 		Name:     *image.Name,
 		DiskType: *image.StorageType,
-	}, nil
+	}
+	p.imageCache.Set(imageID, pvmImage)
+	return &pvmImage, nil
+}
+
+// GetStorageCapacity returns the storage of volumeType available in the region, the pools
+// report their free storage as the largest volume they can allocate
+func (p *powerVSCloud) GetStorageCapacity(ctx context.Context, volumeType string) (*StorageCapacity, error) {
+	var c *models.StorageTypeCapacity
+	err := p.call(ctx, "GetStorageTypeCapacity", IsRetryableError, func() (err error) {
+		c, err = p.capacityClient(ctx).GetStorageTypeCapacity(volumeType)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	capacity := &StorageCapacity{}
+	for _, pool := range c.StoragePoolsCapacity {
+		if pool != nil && pool.MaxAllocationSize != nil {
+			capacity.AvailableGiB += *pool.MaxAllocationSize
+		}
+	}
+	if c.MaximumStorageAllocation != nil && c.MaximumStorageAllocation.MaxAllocationSize != nil {
+		capacity.MaximumVolumeGiB = *c.MaximumStorageAllocation.MaxAllocationSize
+	}
+	return capacity, nil
+}
+
+// GetStoragePoolCapacity returns the storage available in the storage pool pool of the
+// workspace, ErrNotFound if there is no such pool. Its free storage is the largest volume it
+// can allocate.
+func (p *powerVSCloud) GetStoragePoolCapacity(ctx context.Context, pool string) (*StorageCapacity, error) {
+	var c *models.StoragePoolCapacity
+	err := p.call(ctx, "GetStoragePoolCapacity", IsRetryableError, func() (err error) {
+		c, err = p.capacityClient(ctx).GetStoragePoolCapacity(pool)
+		return err
+	})
+	if err != nil {
+		if HTTPStatusCode(err) == gohttp.StatusNotFound {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
+		return nil, err
+	}
+	capacity := &StorageCapacity{}
+	if c.MaxAllocationSize != nil {
+		capacity.AvailableGiB = *c.MaxAllocationSize
+		capacity.MaximumVolumeGiB = *c.MaxAllocationSize
+	}
+	return capacity, nil
 }
 
-func (p *powerVSCloud) CreateDisk(volumeName string, diskOptions *DiskOptions) (disk *Disk, err error) {
+func (p *powerVSCloud) CreateDisk(ctx context.Context, volumeName string, diskOptions *DiskOptions) (disk *Disk, err error) {
 	var volumeType string
 	capacityGiB := util.BytesToGiB(diskOptions.CapacityBytes)
 
 	switch diskOptions.VolumeType {
-	case VolumeTypeTier1, VolumeTypeTier3:
+	case VolumeTypeTier0, VolumeTypeTier1, VolumeTypeTier3, VolumeTypeTier5k:
 		volumeType = diskOptions.VolumeType
 	case "":
 		volumeType = DefaultVolumeType
@@ -250,27 +512,47 @@ func (p *powerVSCloud) CreateDisk(volumeName string, diskOptions *DiskOptions) (
 	}
 
 	dataVolume := &models.CreateDataVolume{
-		Name:      &volumeName,
-		Size:      pointer.Float64Ptr(float64(capacityGiB)),
-		Shareable: &diskOptions.Shareable,
-		DiskType:  volumeType,
-	}
-
-	v, err := p.volClient.CreateVolume(dataVolume)
+		Name:       &volumeName,
+		Size:       pointer.Float64Ptr(float64(capacityGiB)),
+		Shareable:  &diskOptions.Shareable,
+		DiskType:   volumeType,
+		VolumePool: diskOptions.StoragePool,
+	}
+	if diskOptions.ReplicationEnabled {
+		dataVolume.ReplicationEnabled = pointer.BoolPtr(true)
+	}
+
+	ctx, cancel := p.operationContext(ctx, OperationCreate)
+	defer cancel()
+	// only throttled requests are retried, a server side failure may already have created the volume
+	var v *models.Volume
+	err = p.call(ctx, "CreateVolume", IsThrottlingError, func() (err error) {
+		v, err = p.volClient(ctx).CreateVolume(dataVolume)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	err = p.WaitForVolumeState(*v.VolumeID, VolumeAvailableState)
+	err = p.waitForVolumeState(ctx, OperationCreate, *v.VolumeID, VolumeAvailableState)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Disk{CapacityGiB: capacityGiB, VolumeID: *v.VolumeID, DiskType: v.DiskType, WWN: strings.ToLower(v.Wwn)}, nil
+	// tagging failures don't fail the provisioning, the volume itself is usable
+	if err := p.attachTags(ctx, *v.VolumeID, diskOptions.Tags); err != nil {
+		klog.Warningf("failed to tag volume %s with %v: %v", *v.VolumeID, diskOptions.Tags, err)
+	}
+
+	return &Disk{CapacityGiB: capacityGiB, VolumeID: *v.VolumeID, DiskType: v.DiskType, WWN: strings.ToLower(v.Wwn), StoragePool: v.VolumePool}, nil
 }
 
-func (p *powerVSCloud) DeleteDisk(volumeID string) (success bool, err error) {
-	err = p.volClient.DeleteVolume(volumeID)
+func (p *powerVSCloud) DeleteDisk(ctx context.Context, volumeID string) (success bool, err error) {
+	ctx, cancel := p.operationContext(ctx, OperationDelete)
+	defer cancel()
+	err = p.call(ctx, "DeleteVolume", IsRetryableError, func() error {
+		return p.volClient(ctx).DeleteVolume(volumeID)
+	})
 	if err != nil {
 		return false, err
 	}
@@ -278,46 +560,93 @@ func (p *powerVSCloud) DeleteDisk(volumeID string) (success bool, err error) {
 	return true, nil
 }
 
-func (p *powerVSCloud) AttachDisk(volumeID string, nodeID string) (err error) {
-	err = p.volClient.Attach(nodeID, volumeID)
+func (p *powerVSCloud) AttachDisk(ctx context.Context, volumeID string, nodeID string) (err error) {
+	ctx, cancel := p.operationContext(ctx, OperationAttach)
+	defer cancel()
+	err = p.call(ctx, "AttachVolume", IsRetryableError, func() error {
+		return p.volClient(ctx).Attach(nodeID, volumeID)
+	})
 	if err != nil {
+		if HTTPStatusCode(err) == gohttp.StatusConflict {
+			// the volume is already attached, to the instance or another one
+			return fmt.Errorf("%w: %v", ErrAlreadyExists, err)
+		}
 		return err
 	}
 
-	err = p.WaitForVolumeState(volumeID, VolumeInUseState)
+	err = p.waitForVolumeState(ctx, OperationAttach, volumeID, VolumeInUseState)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (p *powerVSCloud) DetachDisk(volumeID string, nodeID string) (err error) {
-	err = p.volClient.Detach(nodeID, volumeID)
-	if err != nil {
+func (p *powerVSCloud) DetachDisk(ctx context.Context, volumeID string, nodeID string) (err error) {
+	ctx, cancel := p.operationContext(ctx, OperationDetach)
+	defer cancel()
+	if err := p.requestDetach(ctx, volumeID, nodeID); err != nil {
 		return err
 	}
-	err = p.WaitForVolumeState(volumeID, VolumeAvailableState)
-	if err != nil {
+	return p.waitForDetach(ctx, volumeID, nodeID)
+}
+
+// requestDetach requests the detach of the volume from the pvm instance nodeID without waiting
+// for it
+func (p *powerVSCloud) requestDetach(ctx context.Context, volumeID string, nodeID string) (err error) {
+	var settled bool
+	refresh := func() (*models.Volume, error) {
+		return p.volClient(ctx).Get(volumeID)
+	}
+	err = p.call(ctx, "DetachVolume", retryDetach(refresh, nodeID, &settled), func() error {
+		return p.volClient(ctx).Detach(nodeID, volumeID)
+	})
+	if settled {
+		klog.V(4).Infof("Volume %s was detached from %s or deleted while its detach conflicted: %v", volumeID, nodeID, err)
+	} else if err != nil {
+		if HTTPStatusCode(err) == gohttp.StatusNotFound {
+			// the instance or the volume was deleted
+			return fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
 		return err
 	}
 	return nil
 }
 
-func (p *powerVSCloud) IsAttached(volumeID string, nodeID string) (attached bool, err error) {
-	_, err = p.volClient.CheckVolumeAttach(nodeID, volumeID)
+// IsAttached returns false without error if PowerVS reports that the volume isn't attached
+// to the instance, other errors are returned
+func (p *powerVSCloud) IsAttached(ctx context.Context, volumeID string, nodeID string) (attached bool, err error) {
+	err = p.call(ctx, "CheckVolumeAttach", IsRetryableError, func() error {
+		_, err := p.volClient(ctx).CheckVolumeAttach(nodeID, volumeID)
+		return err
+	})
 	if err != nil {
+		if HTTPStatusCode(err) == gohttp.StatusNotFound {
+			return false, nil
+		}
 		return false, err
 	}
 	return true, nil
 }
 
-func (p *powerVSCloud) ResizeDisk(volumeID string, reqSize int64) (newSize int64, err error) {
-	disk, err := p.GetDiskByID(volumeID)
+// ResizeDisk grows the volume to reqSize and returns its new size in GiB, attached volumes are
+// grown online
+func (p *powerVSCloud) ResizeDisk(ctx context.Context, volumeID string, reqSize int64) (newSize int64, err error) {
+	ctx, cancel := p.operationContext(ctx, OperationExpand)
+	defer cancel()
+	disk, err := p.GetDiskByID(ctx, volumeID)
 	if err != nil {
 		return 0, err
 	}
 
 	capacityGiB := util.BytesToGiB(reqSize)
+	if disk.CapacityGiB >= capacityGiB {
+		// PowerVS can't shrink volumes, the volume is already large enough
+		return disk.CapacityGiB, nil
+	}
+	// volumes are grown online, attached ones stay in-use meanwhile
+	if disk.State != VolumeAvailableState && disk.State != VolumeInUseState {
+		return 0, fmt.Errorf("%w: volume %s is %s", ErrVolumeBusy, volumeID, disk.State)
+	}
 
 	dataVolume := &models.UpdateVolume{
 		Name:      &disk.Name,
@@ -325,53 +654,172 @@ func (p *powerVSCloud) ResizeDisk(volumeID string, reqSize int64) (newSize int64
 		Shareable: &disk.Shareable,
 	}
 
-	v, err := p.volClient.UpdateVolume(volumeID, dataVolume)
+	var v *models.Volume
+	err = p.call(ctx, "UpdateVolume", IsRetryableError, func() (err error) {
+		v, err = p.volClient(ctx).UpdateVolume(volumeID, dataVolume)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
+	if err := p.waitForVolumeState(ctx, OperationExpand, volumeID, disk.State); err != nil {
+		return 0, err
+	}
 	return int64(*v.Size), nil
 }
 
-func (p *powerVSCloud) WaitForVolumeState(volumeID, state string) error {
-	err := wait.PollImmediate(PollInterval, PollTimeout, func() (bool, error) {
-		v, err := p.volClient.Get(volumeID)
-		if err != nil {
-			return false, err
-		}
-		spew.Dump(v)
-		return v.State == state, nil
+// volumeTierAction is the body of the PowerVS volume action which changes the storage tier,
+// see https://cloud.ibm.com/apidocs/power-cloud#pcloud-cloudinstances-volumes-action-post.
+// models.VolumeAction of power-go-client v1.0.88 only carries replicationEnabled.
+type volumeTierAction struct {
+	TargetStorageTier string `json:"targetStorageTier"`
+}
+
+// UpdateDiskTier requests PowerVS to move the volume to another storage tier with
+// POST /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes/{volume_id}/action, which
+// PowerVS accepts with 202. The migration runs asynchronously in PowerVS; callers observe
+// completion through the volume's disk type.
+func (p *powerVSCloud) UpdateDiskTier(ctx context.Context, volumeID string, tier string) (err error) {
+	path := fmt.Sprintf("/pcloud/v1/cloud-instances/%s/volumes/%s/action", p.cloudInstanceID, volumeID)
+	body := &volumeTierAction{TargetStorageTier: tier}
+	return p.call(ctx, "VolumeAction", IsRetryableError, func() error {
+		return p.submitOperation(ctx, "pcloud.cloudinstances.volumes.action.post", "POST", path, body, nil)
 	})
+}
+
+func (p *powerVSCloud) WaitForVolumeState(ctx context.Context, volumeID, state string) (err error) {
+	return p.waitForVolumeState(ctx, "", volumeID, state)
+}
+
+// waitForVolumeState waits for the volume state within the state timeout of op
+func (p *powerVSCloud) waitForVolumeState(ctx context.Context, op Operation, volumeID, state string) (err error) {
+	_, span := tracing.Start(ctx, "WaitForVolumeState", attribute.String("powervs.volume_id", volumeID), attribute.String("powervs.volume_state", state))
+	defer func() { tracing.End(span, err) }()
+	start := time.Now()
+	defer util.StartPhase(ctx, util.PhaseVolumeWait)()
+	defer p.observeSlowCall(ctx, "WaitForVolumeState", start, "volumeID", volumeID, "state", state)
+	return p.volumePoller.wait(ctx, volumeID, state, p.stateTimeout(ctx, op))
+}
+
+// listVolumeStates returns the state of every volume in the cloud instance keyed by ID
+func (p *powerVSCloud) listVolumeStates() (map[string]string, error) {
+	if err := p.breaker.allow(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	// the states are listed for every waiter at once, not on behalf of one of them
+	vols, err := p.volClient(context.Background()).GetAll()
+	metrics.ObserveCloudAPIRequest("GetVolumes", requestStatus(err), start)
+	p.breaker.record(err)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	states := make(map[string]string, len(vols.Volumes))
+	for _, v := range vols.Volumes {
+		if v.VolumeID != nil && v.State != nil {
+			states[*v.VolumeID] = *v.State
+		}
+	}
+	return states, nil
 }
 
-func (p *powerVSCloud) GetDiskByName(name string) (disk *Disk, err error) {
-	//TODO: remove capacityBytes
-	params := p_cloud_volumes.NewPcloudCloudinstancesVolumesGetallParamsWithTimeout(TIMEOUT).WithCloudInstanceID(p.cloudInstanceID)
-	resp, err := p.piSession.Power.PCloudVolumes.PcloudCloudinstancesVolumesGetall(params, ibmpisession.NewAuth(p.piSession, p.cloudInstanceID))
+// GetDiskByName returns the volume named exactly name in the cloud instance, ErrNotFound if
+// there is none and ErrDuplicateName if several volumes have the name
+func (p *powerVSCloud) GetDiskByName(ctx context.Context, name string) (disk *Disk, err error) {
+	params := p_cloud_volumes.NewPcloudCloudinstancesVolumesGetallParamsWithTimeout(TIMEOUT).WithContext(ctx).WithCloudInstanceID(p.cloudInstanceID)
+	var resp *p_cloud_volumes.PcloudCloudinstancesVolumesGetallOK
+	err = p.call(ctx, "GetVolumes", IsRetryableError, func() (err error) {
+		resp, err = p.piSession.Power.PCloudVolumes.PcloudCloudinstancesVolumesGetall(params, ibmpisession.NewAuth(p.piSession, p.cloudInstanceID))
+		return err
+	})
 	if err != nil {
 		return nil, errors.ToError(err)
 	}
-	for _, v := range resp.Payload.Volumes {
-		if name == *v.Name {
-			return &Disk{
-				Name:        *v.Name,
-				DiskType:    *v.DiskType,
-				VolumeID:    *v.VolumeID,
-				WWN:         strings.ToLower(*v.Wwn),
-				Shareable:   *v.Shareable,
-				CapacityGiB: int64(*v.Size),
-			}, nil
+	v, err := findVolumeByName(resp.Payload.Volumes, name)
+	if err != nil {
+		return nil, err
+	}
+	return diskFromReference(v), nil
+}
+
+// ListDisks returns the data volumes of the cloud instance, boot volumes of the pvm instances
+// are left out
+func (p *powerVSCloud) ListDisks(ctx context.Context) ([]*Disk, error) {
+	var vols *models.Volumes
+	err := p.call(ctx, "GetVolumes", IsRetryableError, func() (err error) {
+		vols, err = p.volClient(ctx).GetAll()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	disks := make([]*Disk, 0, len(vols.Volumes))
+	for _, v := range vols.Volumes {
+		if v.VolumeID == nil || (v.BootVolume != nil && *v.BootVolume) {
+			continue
 		}
+		disks = append(disks, diskFromReference(v))
 	}
+	return disks, nil
+}
 
-	return nil, ErrNotFound
+// diskFromReference returns the disk of a volume listed by the API, fields missing in the
+// listing are left empty
+func diskFromReference(v *models.VolumeReference) *Disk {
+	disk := &Disk{
+		VolumeID:   *v.VolumeID,
+		AttachedTo: v.PvmInstanceIds,
+	}
+	if v.Name != nil {
+		disk.Name = *v.Name
+	}
+	if v.DiskType != nil {
+		disk.DiskType = *v.DiskType
+	}
+	if v.Wwn != nil {
+		disk.WWN = strings.ToLower(*v.Wwn)
+	}
+	if v.Shareable != nil {
+		disk.Shareable = *v.Shareable
+	}
+	if v.Size != nil {
+		disk.CapacityGiB = int64(*v.Size)
+	}
+	if v.State != nil {
+		disk.State = *v.State
+	}
+	disk.StoragePool = v.VolumePool
+	return disk
 }
 
-func (p *powerVSCloud) GetDiskByID(volumeID string) (disk *Disk, err error) {
-	v, err := p.volClient.Get(volumeID)
+// findVolumeByName returns the only volume of volumes named exactly name. The API returns
+// all volumes of the cloud instance in one response, there are no pages to follow.
+func findVolumeByName(volumes []*models.VolumeReference, name string) (*models.VolumeReference, error) {
+	var found []*models.VolumeReference
+	for _, v := range volumes {
+		if v.Name != nil && *v.Name == name {
+			found = append(found, v)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+		return found[0], nil
+	}
+	ids := make([]string, 0, len(found))
+	for _, v := range found {
+		ids = append(ids, *v.VolumeID)
+	}
+	return nil, fmt.Errorf("%w %q: volumes %s", ErrDuplicateName, name, strings.Join(ids, ", "))
+}
+
+func (p *powerVSCloud) GetDiskByID(ctx context.Context, volumeID string) (disk *Disk, err error) {
+	var v *models.Volume
+	err = p.call(ctx, "GetVolume", IsRetryableError, func() (err error) {
+		v, err = p.volClient(ctx).Get(volumeID)
+		return err
+	})
 	if err != nil {
 		if strings.Contains(err.Error(), "Resource not found") {
 			return nil, ErrNotFound
@@ -385,6 +833,9 @@ func (p *powerVSCloud) GetDiskByID(volumeID string) (disk *Disk, err error) {
 		WWN:         strings.ToLower(v.Wwn),
 		Shareable:   *v.Shareable,
 		CapacityGiB: int64(*v.Size),
+		State:       v.State,
+		AttachedTo:  v.PvmInstanceIds,
+		StoragePool: v.VolumePool,
 	}, nil
 }
 
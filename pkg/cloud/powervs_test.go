@@ -0,0 +1,55 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IBM-Cloud/power-go-client/power/models"
+)
+
+func TestFindVolumeByName(t *testing.T) {
+	volume := func(id, name string) *models.VolumeReference {
+		return &models.VolumeReference{VolumeID: &id, Name: &name}
+	}
+	volumes := []*models.VolumeReference{
+		{VolumeID: stringPtr("unnamed")},
+		volume("vol-1", "pvc-1"),
+		volume("vol-10", "pvc-10"),
+		volume("vol-2", "pvc-2"),
+		volume("vol-3", "pvc-2"),
+	}
+
+	v, err := findVolumeByName(volumes, "pvc-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *v.VolumeID != "vol-1" {
+		t.Fatalf("expected volume vol-1, got %s", *v.VolumeID)
+	}
+
+	if _, err := findVolumeByName(volumes, "pvc"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a prefix of a name, got: %v", err)
+	}
+
+	_, err = findVolumeByName(volumes, "pvc-2")
+	if !errors.Is(err, ErrDuplicateName) {
+		t.Fatalf("expected ErrDuplicateName, got: %v", err)
+	}
+	want := `several resources have the name "pvc-2": volumes vol-2, vol-3`
+	if err.Error() != want {
+		t.Fatalf("expected error %q, got %q", want, err.Error())
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	gohttp "net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/IBM-Cloud/power-go-client/power/models"
+	"github.com/go-openapi/runtime"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/metrics"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/tracing"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+// DefaultBackoff is the backoff used to retry throttled and transient PowerVS API errors
+var DefaultBackoff = wait.Backoff{
+	Duration: 1 * time.Second,
+	Factor:   2,
+	Jitter:   0.2,
+	Steps:    5,
+	Cap:      30 * time.Second,
+}
+
+// the generated power-go-client errors carry the HTTP status as "[METHOD /path][code] ..."
+var statusCodeRegexp = regexp.MustCompile(`\]\[(\d{3})\]`)
+
+// HTTPStatusCode returns the HTTP status code of a failed PowerVS API call, or 0 if the
+// error didn't originate from an HTTP response
+func HTTPStatusCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var apiErr *runtime.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	if m := statusCodeRegexp.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return code
+	}
+	return 0
+}
+
+// APIErrorPayload returns the error body of a failed PowerVS API call, or nil if the error
+// didn't originate from a PowerVS API response with a body
+func APIErrorPayload(err error) *models.Error {
+	// the generated power-go-client errors are structs with a Payload field, without accessor
+	for ; err != nil; err = errors.Unwrap(err) {
+		v := reflect.ValueOf(err)
+		if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		if field := v.Elem().FieldByName("Payload"); field.IsValid() {
+			if payload, ok := field.Interface().(*models.Error); ok && payload != nil {
+				return payload
+			}
+		}
+	}
+	return nil
+}
+
+// IsThrottlingError returns true if PowerVS rejected the call because of rate limiting
+func IsThrottlingError(err error) bool {
+	return HTTPStatusCode(err) == gohttp.StatusTooManyRequests
+}
+
+// the messages of the PowerVS errors of exhausted limits, matched case insensitively against
+// the error and its PowerVS error body
+var (
+	attachLimitMessages = []string{"maximum volumes attached", "maximum number of volumes attached", "volume attach limit"}
+	quotaMessages       = []string{"quota exceeded", "exceeds the quota", "exceed the quota", "quota limit"}
+	capacityMessages    = []string{"no space in pool", "not enough space", "insufficient storage", "insufficient capacity"}
+)
+
+// IsAttachLimitError returns true if PowerVS rejected an attach because the pvm instance
+// already has the maximum number of volumes attached
+func IsAttachLimitError(err error) bool {
+	return errorMentions(err, attachLimitMessages)
+}
+
+// IsQuotaError returns true if PowerVS rejected the call because a quota of the account or
+// workspace is exhausted
+func IsQuotaError(err error) bool {
+	return errorMentions(err, quotaMessages)
+}
+
+// IsInsufficientCapacityError returns true if PowerVS rejected the call because the storage
+// pools have no space left for the volume
+func IsInsufficientCapacityError(err error) bool {
+	return errorMentions(err, capacityMessages)
+}
+
+// errorMentions returns true if the message or the PowerVS error body of err contains one of
+// the lower cased messages
+func errorMentions(err error, messages []string) bool {
+	if err == nil {
+		return false
+	}
+	texts := []string{err.Error()}
+	if payload := APIErrorPayload(err); payload != nil {
+		texts = append(texts, payload.Error, payload.Description, payload.Message)
+	}
+	for _, text := range texts {
+		text = strings.ToLower(text)
+		for _, message := range messages {
+			if strings.Contains(text, message) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsRetryableError returns true for throttling, server side and connection errors which are
+// expected to succeed on a later attempt. Exhausted limits aren't retried, even if PowerVS
+// reports them with a server error.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if IsAttachLimitError(err) || IsQuotaError(err) || IsInsufficientCapacityError(err) {
+		return false
+	}
+	if code := HTTPStatusCode(err); code != 0 {
+		return code == gohttp.StatusTooManyRequests || code >= gohttp.StatusInternalServerError
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "connection reset") || strings.Contains(msg, "connection refused") || strings.Contains(msg, "unexpected eof")
+}
+
+// withRetry calls fn until it succeeds, returns an error for which retriable is false or the
+// backoff is exhausted, in which case the last error is returned. The backoff is cut short with
+// the error of ctx once ctx is done.
+func withRetry(ctx context.Context, backoff wait.Backoff, retriable func(error) bool, fn func() error) error {
+	attempt := 0
+	var lastErr error
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func() (bool, error) {
+		err := fn()
+		if err == nil || !retriable(err) {
+			return err == nil, err
+		}
+		lastErr = err
+		attempt++
+		klog.V(4).Infof("retrying PowerVS API call after attempt %d failed: %v", attempt, err)
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout && lastErr != nil {
+		return lastErr
+	}
+	return err
+}
+
+// call runs fn against the PowerVS API, retrying the errors for which retriable is true and
+// failing fast with ErrCircuitOpen while the API is considered down. Every attempt is
+// recorded in the API request metrics of operation, and the call in a child span of ctx.
+func (p *powerVSCloud) call(ctx context.Context, operation string, retriable func(error) bool, fn func() error) (err error) {
+	_, span := tracing.Start(ctx, "PowerVS "+operation, attribute.String("powervs.cloud_instance_id", p.cloudInstanceID))
+	defer func() { tracing.End(span, err) }()
+
+	if err := p.breaker.allow(); err != nil {
+		return err
+	}
+	attempts := 0
+	start := time.Now()
+	defer util.StartPhase(ctx, util.PhaseAPI)()
+	defer func() { p.observeSlowCall(ctx, operation, start, "attempts", attempts) }()
+	// the retries stop once ctx is done, like when the operation timed out
+	retry := func(err error) bool {
+		return ctx.Err() == nil && retriable(err)
+	}
+	err = withRetry(ctx, p.tuning.retryBackoff(), retry, func() error {
+		attempts++
+		start := time.Now()
+		err := fn()
+		status := requestStatus(err)
+		metrics.ObserveCloudAPIRequest(operation, status, start)
+		if attempts > 1 || err != nil {
+			span.AddEvent("attempt", trace.WithAttributes(attribute.Int("attempt", attempts), attribute.String("status", status)))
+		}
+		return err
+	})
+	span.SetAttributes(attribute.Int("powervs.attempts", attempts))
+	p.breaker.record(err)
+	return err
+}
+
+// observeSlowCall logs and counts the cloud call of operation started at start if it took
+// longer than the slow call threshold
+func (p *powerVSCloud) observeSlowCall(ctx context.Context, operation string, start time.Time, keysAndValues ...interface{}) {
+	threshold := p.tuning.slowThreshold()
+	elapsed := time.Since(start)
+	if threshold == 0 || elapsed < threshold {
+		return
+	}
+	metrics.SlowOperations.WithLabelValues("cloud", operation).Inc()
+	klog.InfoS("Slow PowerVS call", append([]interface{}{"requestID", util.RequestID(ctx), "operation", operation, "cloudInstanceID", p.cloudInstanceID, "duration", elapsed, "threshold", threshold}, keysAndValues...)...)
+}
+
+// requestStatus returns the metrics status label of a PowerVS API request
+func requestStatus(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	if code := HTTPStatusCode(err); code != 0 {
+		return strconv.Itoa(code)
+	}
+	return "error"
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/IBM-Cloud/power-go-client/power/client/p_cloud_volumes"
+	"github.com/IBM-Cloud/power-go-client/power/models"
+	"github.com/go-openapi/runtime"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/metrics"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "throttled", err: runtime.NewAPIError("op", nil, 429), expected: true},
+		{name: "server error", err: fmt.Errorf("failed: %w", runtime.NewAPIError("op", nil, 502)), expected: true},
+		{name: "generated client server error", err: errors.New("[POST /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes][500] internal"), expected: true},
+		{name: "not found", err: errors.New("[GET /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes/{volume_id}][404] not found"), expected: false},
+		{name: "connection reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), expected: true},
+		{name: "permanent", err: ErrNotFound, expected: false},
+		{name: "quota exceeded server error", err: errors.New("[POST /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes][500] quota exceeded for storage"), expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if retryable := IsRetryableError(tc.err); retryable != tc.expected {
+				t.Fatalf("expected %v, got %v for %v", tc.expected, retryable, tc.err)
+			}
+		})
+	}
+}
+
+func TestLimitErrors(t *testing.T) {
+	attachLimit := p_cloud_volumes.NewPcloudPvminstancesVolumesPostBadRequest()
+	attachLimit.Payload = &models.Error{Description: "The instance has the Maximum volumes attached"}
+
+	testCases := []struct {
+		name        string
+		err         error
+		attachLimit bool
+		quota       bool
+		capacity    bool
+	}{
+		{name: "attach limit in payload", err: fmt.Errorf("attach failed: %w", attachLimit), attachLimit: true},
+		{name: "quota", err: errors.New("[POST /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes][403] Quota Exceeded"), quota: true},
+		{name: "no space in pool", err: errors.New("[POST /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes][400] no space in pool Tier1-Flash-1"), capacity: true},
+		{name: "other", err: runtime.NewAPIError("op", nil, 400)},
+		{name: "nil"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsAttachLimitError(tc.err); got != tc.attachLimit {
+				t.Fatalf("expected attach limit error %v, got %v", tc.attachLimit, got)
+			}
+			if got := IsQuotaError(tc.err); got != tc.quota {
+				t.Fatalf("expected quota error %v, got %v", tc.quota, got)
+			}
+			if got := IsInsufficientCapacityError(tc.err); got != tc.capacity {
+				t.Fatalf("expected insufficient capacity error %v, got %v", tc.capacity, got)
+			}
+		})
+	}
+}
+
+func TestRequestStatus(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected string
+	}{
+		{err: nil, expected: "ok"},
+		{err: runtime.NewAPIError("op", nil, 429), expected: "429"},
+		{err: errors.New("[GET /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes/{volume_id}][404] not found"), expected: "404"},
+		{err: fmt.Errorf("read: %w", syscall.ECONNRESET), expected: "error"},
+	}
+
+	for _, tc := range testCases {
+		if status := requestStatus(tc.err); status != tc.expected {
+			t.Fatalf("expected %q, got %q for %v", tc.expected, status, tc.err)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	backoff := wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 3}
+
+	testCases := []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+		expectErr     bool
+	}{
+		{
+			name:          "success after transient errors",
+			errs:          []error{runtime.NewAPIError("op", nil, 429), runtime.NewAPIError("op", nil, 503), nil},
+			expectedCalls: 3,
+		},
+		{
+			name:          "permanent error is not retried",
+			errs:          []error{runtime.NewAPIError("op", nil, 400)},
+			expectedCalls: 1,
+			expectErr:     true,
+		},
+		{
+			name:          "retries exhausted",
+			errs:          []error{runtime.NewAPIError("op", nil, 500), runtime.NewAPIError("op", nil, 500), runtime.NewAPIError("op", nil, 500)},
+			expectedCalls: 3,
+			expectErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			err := withRetry(context.Background(), backoff, IsRetryableError, func() error {
+				err := tc.errs[calls]
+				calls++
+				return err
+			})
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.expectErr, err)
+			}
+			if calls != tc.expectedCalls {
+				t.Fatalf("expected %d calls, got %d", tc.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestWithRetryContext(t *testing.T) {
+	backoff := wait.Backoff{Duration: time.Hour, Factor: 2, Steps: 3}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	err := withRetry(ctx, backoff, IsRetryableError, func() error {
+		calls++
+		return runtime.NewAPIError("op", nil, 503)
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the backoff to end with the context, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestCallSlowCalls(t *testing.T) {
+	p := &powerVSCloud{
+		cloudInstanceID: "ws-1",
+		tuning:          NewTuning(WithSlowCallThreshold(10 * time.Millisecond)),
+		breaker:         newCircuitBreaker("ws-1", 5, time.Minute, func() error { return nil }),
+	}
+	slow := testutil.ToFloat64(metrics.SlowOperations.WithLabelValues("cloud", "GetVolume"))
+	ctx, phases := util.WithPhases(context.Background())
+
+	if err := p.call(ctx, "GetVolume", IsRetryableError, func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if delta := testutil.ToFloat64(metrics.SlowOperations.WithLabelValues("cloud", "GetVolume")) - slow; delta != 0 {
+		t.Fatalf("expected no slow call, got %v", delta)
+	}
+	err := p.call(ctx, "GetVolume", IsRetryableError, func() error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delta := testutil.ToFloat64(metrics.SlowOperations.WithLabelValues("cloud", "GetVolume")) - slow; delta != 1 {
+		t.Fatalf("expected 1 slow call, got %v", delta)
+	}
+	if d := phases.Durations()[util.PhaseAPI]; d < 20*time.Millisecond {
+		t.Fatalf("expected at least 20ms in the api phase, got %v", d)
+	}
+}
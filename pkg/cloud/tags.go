@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/IBM-Cloud/bluemix-go/api/globaltagging/globaltaggingv3"
+)
+
+// volumeCRN returns the cloud resource name of a volume, used to tag it through IBM Cloud
+// global tagging
+func (p *powerVSCloud) volumeCRN(volumeID string) string {
+	return fmt.Sprintf("crn:v1:bluemix:public:power-iaas:%s:a/%s:%s:volume:%s", p.zone, p.accountID, p.cloudInstanceID, volumeID)
+}
+
+// TagLister is implemented by Cloud providers that tag volumes, it returns the tags of a volume
+type TagLister interface {
+	GetDiskTags(ctx context.Context, volumeID string) ([]string, error)
+}
+
+// TagUpdater is implemented by Cloud providers that tag volumes, it attaches and detaches tags
+// of a volume
+type TagUpdater interface {
+	UpdateDiskTags(ctx context.Context, volumeID string, attach, detach []string) error
+}
+
+var (
+	_ TagLister  = &powerVSCloud{}
+	_ TagUpdater = &powerVSCloud{}
+)
+
+// GetDiskTags returns the IBM Cloud tags of the volume
+func (p *powerVSCloud) GetDiskTags(ctx context.Context, volumeID string) ([]string, error) {
+	tagClient, err := p.tags()
+	if err != nil {
+		return nil, err
+	}
+	var res globaltaggingv3.TaggingResult
+	err = p.call(ctx, "GetTags", IsRetryableError, func() (err error) {
+		res, err = tagClient.GetTags(p.volumeCRN(volumeID))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0, len(res.Items))
+	for _, item := range res.Items {
+		tags = append(tags, item.Name)
+	}
+	return tags, nil
+}
+
+// UpdateDiskTags detaches the tags detach from the volume, then attaches the tags attach
+func (p *powerVSCloud) UpdateDiskTags(ctx context.Context, volumeID string, attach, detach []string) error {
+	if err := p.detachTags(ctx, volumeID, detach); err != nil {
+		return err
+	}
+	return p.attachTags(ctx, volumeID, attach)
+}
+
+// attachTags attaches tags to the volume
+func (p *powerVSCloud) attachTags(ctx context.Context, volumeID string, tags []string) error {
+	return p.updateTags(ctx, "AttachTags", volumeID, tags, func(tagClient globaltaggingv3.Tags) (globaltaggingv3.TagUpdateResult, error) {
+		return tagClient.AttachTags(p.volumeCRN(volumeID), tags)
+	})
+}
+
+// detachTags detaches tags from the volume
+func (p *powerVSCloud) detachTags(ctx context.Context, volumeID string, tags []string) error {
+	return p.updateTags(ctx, "DetachTags", volumeID, tags, func(tagClient globaltaggingv3.Tags) (globaltaggingv3.TagUpdateResult, error) {
+		return tagClient.DetachTags(p.volumeCRN(volumeID), tags)
+	})
+}
+
+// updateTags calls update with the tagging client unless there are no tags, the failed
+// results of the update are returned as an error
+func (p *powerVSCloud) updateTags(ctx context.Context, method, volumeID string, tags []string, update func(globaltaggingv3.Tags) (globaltaggingv3.TagUpdateResult, error)) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	tagClient, err := p.tags()
+	if err != nil {
+		return err
+	}
+	return p.call(ctx, method, IsRetryableError, func() error {
+		res, err := update(tagClient)
+		if err != nil {
+			return err
+		}
+		var msgs []string
+		for _, r := range res.Results {
+			if r.IsError == "true" {
+				msgs = append(msgs, r.Message)
+			}
+		}
+		if len(msgs) > 0 {
+			return fmt.Errorf("could not update tags of volume %s: %s", volumeID, strings.Join(msgs, "; "))
+		}
+		return nil
+	})
+}
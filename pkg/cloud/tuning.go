@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Tuning holds the volume state, operation timeout, retry and slow call settings of cloud
+// clients, which can be changed while the clients are in use. Clients created with the same
+// Tuning share it.
+type Tuning struct {
+	mu                      sync.RWMutex
+	volumeStateTimeout      time.Duration
+	volumeStatePollInterval time.Duration
+	backoff                 wait.Backoff
+	slowCallThreshold       time.Duration
+	operationTimeouts       map[Operation]time.Duration
+}
+
+// NewTuning returns the tuning of the volume state, retry and slow call settings of options
+func NewTuning(options ...func(*Options)) *Tuning {
+	t := &Tuning{}
+	t.Update(options...)
+	return t
+}
+
+// Update replaces the settings with the volume state, retry and slow call settings of options
+// on top of the defaults, the other options are ignored
+func (t *Tuning) Update(options ...func(*Options)) {
+	o := defaultOptions()
+	for _, option := range options {
+		option(&o)
+	}
+	t.set(&o)
+}
+
+// set replaces the settings with the volume state, retry and slow call settings of o
+func (t *Tuning) set(o *Options) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.volumeStateTimeout = o.volumeStateTimeout
+	t.volumeStatePollInterval = o.volumeStatePollInterval
+	t.backoff = o.backoff
+	t.slowCallThreshold = o.slowCallThreshold
+	t.operationTimeouts = o.operationTimeouts
+}
+
+func (t *Tuning) stateTimeout() time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.volumeStateTimeout
+}
+
+func (t *Tuning) pollInterval() time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.volumeStatePollInterval
+}
+
+func (t *Tuning) retryBackoff() wait.Backoff {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.backoff
+}
+
+func (t *Tuning) slowThreshold() time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.slowCallThreshold
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/metrics"
+)
+
+func TestTuning(t *testing.T) {
+	tuning := NewTuning(WithVolumeStateTimeout(5*time.Minute), WithAPIEndpoints([]string{"us-south.power-iaas.cloud.ibm.com"}))
+	if tuning.stateTimeout() != 5*time.Minute || tuning.pollInterval() != PollInterval || tuning.retryBackoff() != DefaultBackoff {
+		t.Fatalf("unexpected tuning %v, %v, %+v", tuning.stateTimeout(), tuning.pollInterval(), tuning.retryBackoff())
+	}
+
+	tuning.Update(WithVolumeStatePollInterval(10*time.Second), WithRetryBackoff(time.Second, 3), WithSlowCallThreshold(time.Minute))
+	if tuning.stateTimeout() != PollTimeout {
+		t.Fatalf("expected the volume state timeout to be reset to the default, got %v", tuning.stateTimeout())
+	}
+	if tuning.pollInterval() != 10*time.Second {
+		t.Fatalf("expected poll interval 10s, got %v", tuning.pollInterval())
+	}
+	if backoff := tuning.retryBackoff(); backoff.Duration != time.Second || backoff.Steps != 3 {
+		t.Fatalf("expected 3 retries starting after 1s, got %+v", backoff)
+	}
+	if tuning.slowThreshold() != time.Minute {
+		t.Fatalf("expected slow call threshold 1m, got %v", tuning.slowThreshold())
+	}
+}
+
+func TestClientTuningSlowCalls(t *testing.T) {
+	o := defaultOptions()
+	WithSlowCallThreshold(10 * time.Millisecond)(&o)
+	p := &powerVSCloud{
+		cloudInstanceID: "ws-1",
+		tuning:          o.clientTuning(),
+		breaker:         newCircuitBreaker("ws-1", 5, time.Minute, func() error { return nil }),
+	}
+	if p.tuning.stateTimeout() != PollTimeout || p.tuning.retryBackoff() != DefaultBackoff {
+		t.Fatalf("expected the default volume state and retry settings, got %v, %+v", p.tuning.stateTimeout(), p.tuning.retryBackoff())
+	}
+
+	slow := testutil.ToFloat64(metrics.SlowOperations.WithLabelValues("cloud", "GetVolumes"))
+	err := p.call(context.Background(), "GetVolumes", IsRetryableError, func() error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delta := testutil.ToFloat64(metrics.SlowOperations.WithLabelValues("cloud", "GetVolumes")) - slow; delta != 1 {
+		t.Fatalf("expected 1 slow call, got %v", delta)
+	}
+
+	shared := NewTuning()
+	o.tuning = shared
+	if o.clientTuning() != shared {
+		t.Fatalf("expected the tuning of WithTuning")
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// volumeStateWaiter is a single caller of volumeStatePoller.wait
+type volumeStateWaiter struct {
+	state string
+	done  chan error
+}
+
+// volumeStatePoller shares a single volume list call per interval between all callers waiting
+// for a volume to reach a state, instead of each of them polling its volume individually
+type volumeStatePoller struct {
+	// interval returns the current interval between two list calls
+	interval func() time.Duration
+	// list returns the state of all volumes of the cloud instance keyed by volume ID
+	list func() (map[string]string, error)
+
+	mu      sync.Mutex
+	running bool
+	waiters map[string][]*volumeStateWaiter
+}
+
+func newVolumeStatePoller(interval func() time.Duration, list func() (map[string]string, error)) *volumeStatePoller {
+	return &volumeStatePoller{
+		interval: interval,
+		list:     list,
+		waiters:  make(map[string][]*volumeStateWaiter),
+	}
+}
+
+// wait blocks until volumeID reports state, the list call fails with a non retryable error,
+// the volume is in the error state, timeout expires or ctx is done
+func (p *volumeStatePoller) wait(ctx context.Context, volumeID, state string, timeout time.Duration) error {
+	w := &volumeStateWaiter{state: state, done: make(chan error, 1)}
+
+	p.mu.Lock()
+	p.waiters[volumeID] = append(p.waiters[volumeID], w)
+	if !p.running {
+		p.running = true
+		go p.loop()
+	}
+	p.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-w.done:
+		return err
+	case <-timer.C:
+		p.remove(volumeID, w)
+		return wait.ErrWaitTimeout
+	case <-ctx.Done():
+		// e.g. the CO gave up on the request, which must not hold its volume meanwhile
+		p.remove(volumeID, w)
+		return ctx.Err()
+	}
+}
+
+func (p *volumeStatePoller) remove(volumeID string, w *volumeStateWaiter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	waiters := p.waiters[volumeID]
+	for i := range waiters {
+		if waiters[i] == w {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(p.waiters, volumeID)
+	} else {
+		p.waiters[volumeID] = waiters
+	}
+}
+
+// loop polls immediately and then every interval until there is nobody left waiting
+func (p *volumeStatePoller) loop() {
+	for {
+		p.poll()
+
+		p.mu.Lock()
+		if len(p.waiters) == 0 {
+			p.running = false
+			p.mu.Unlock()
+			return
+		}
+		p.mu.Unlock()
+		time.Sleep(p.interval())
+	}
+}
+
+func (p *volumeStatePoller) poll() {
+	states, err := p.list()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		if IsRetryableError(err) {
+			klog.V(4).Infof("transient error while polling volume states: %v", err)
+			return
+		}
+		for volumeID, waiters := range p.waiters {
+			for _, w := range waiters {
+				w.done <- err
+			}
+			delete(p.waiters, volumeID)
+		}
+		return
+	}
+
+	for volumeID, waiters := range p.waiters {
+		current := states[volumeID]
+		pending := waiters[:0]
+		for _, w := range waiters {
+			switch {
+			case current == w.state:
+				w.done <- nil
+			case current == VolumeErrorState:
+				// PowerVS doesn't recover volumes from the error state
+				w.done <- fmt.Errorf("%w: volume %s", ErrVolumeFailed, volumeID)
+			default:
+				pending = append(pending, w)
+			}
+		}
+		if len(pending) == 0 {
+			delete(p.waiters, volumeID)
+		} else {
+			p.waiters[volumeID] = pending
+		}
+		klog.V(5).Infof("volume %s is %q, %d waiter(s) pending", volumeID, current, len(pending))
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestVolumeStatePollerBatchesWaiters(t *testing.T) {
+	var calls int32
+	p := newVolumeStatePoller(fixedInterval(10*time.Millisecond), func() (map[string]string, error) {
+		n := atomic.AddInt32(&calls, 1)
+		states := map[string]string{}
+		for i := 0; i < 10; i++ {
+			state := VolumeAvailableState
+			if int(n) > i {
+				state = VolumeInUseState
+			}
+			states[fmt.Sprintf("vol-%d", i)] = state
+		}
+		return states, nil
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- p.wait(context.Background(), fmt.Sprintf("vol-%d", i), VolumeInUseState, 5*time.Second)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n > 12 {
+		t.Fatalf("expected waiters to share list calls, got %d calls", n)
+	}
+}
+
+func TestVolumeStatePollerErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		list        func() (map[string]string, error)
+		expectedErr error
+	}{
+		{
+			name: "timeout",
+			list: func() (map[string]string, error) {
+				return map[string]string{"vol-1": "creating"}, nil
+			},
+			expectedErr: wait.ErrWaitTimeout,
+		},
+		{
+			name: "transient errors are retried until timeout",
+			list: func() (map[string]string, error) {
+				return nil, runtime.NewAPIError("op", nil, 503)
+			},
+			expectedErr: wait.ErrWaitTimeout,
+		},
+		{
+			name: "volume in error state",
+			list: func() (map[string]string, error) {
+				return map[string]string{"vol-1": VolumeErrorState}, nil
+			},
+			expectedErr: ErrVolumeFailed,
+		},
+		{
+			name: "permanent error is returned",
+			list: func() (map[string]string, error) {
+				return nil, ErrNotFound
+			},
+			expectedErr: ErrNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newVolumeStatePoller(fixedInterval(10*time.Millisecond), tc.list)
+			err := p.wait(context.Background(), "vol-1", VolumeAvailableState, 50*time.Millisecond)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestVolumeStatePollerCanceled(t *testing.T) {
+	p := newVolumeStatePoller(fixedInterval(10*time.Millisecond), func() (map[string]string, error) {
+		return map[string]string{"vol-1": "creating"}, nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := p.wait(ctx, "vol-1", VolumeAvailableState, time.Minute)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected error %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("expected the wait to return with its context, returned after %v", elapsed)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.waiters) != 0 {
+		t.Fatalf("expected the canceled waiter to be removed, got %v", p.waiters)
+	}
+}
+
+func fixedInterval(interval time.Duration) func() time.Duration {
+	return func() time.Duration {
+		return interval
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownWorkspace is returned for a cloud instance ID that isn't one of the managed workspaces
+var ErrUnknownWorkspace = errors.New("unknown PowerVS workspace")
+
+// volumeHandleSeparator separates the cloud instance ID from the volume ID in the handles of
+// volumes outside of the default workspace
+const volumeHandleSeparator = "/"
+
+// Workspaces holds a client per PowerVS workspace (cloud instance) managed by the controller.
+// Volumes of the default workspace keep their plain PowerVS volume ID as handle, the handles
+// of the other volumes are prefixed with the cloud instance ID of their workspace.
+type Workspaces struct {
+	defaultID string
+	ids       []string
+	newCloud  func(cloudInstanceID string) (Cloud, error)
+
+	mu      sync.Mutex
+	clients map[string]Cloud
+}
+
+// NewWorkspaces returns the workspaces with the given cloud instance IDs, defaultCloud is the
+// client of defaultID and the clients of the other workspaces are created by newCloud on first use
+func NewWorkspaces(defaultID string, defaultCloud Cloud, ids []string, newCloud func(cloudInstanceID string) (Cloud, error)) *Workspaces {
+	w := &Workspaces{
+		defaultID: defaultID,
+		ids:       []string{defaultID},
+		newCloud:  newCloud,
+		clients:   map[string]Cloud{defaultID: defaultCloud},
+	}
+	for _, id := range ids {
+		if id != defaultID {
+			w.ids = append(w.ids, id)
+		}
+	}
+	return w
+}
+
+// DefaultID returns the cloud instance ID of the default workspace
+func (w *Workspaces) DefaultID() string {
+	return w.defaultID
+}
+
+// IDs returns the cloud instance IDs of all workspaces, starting with the default one
+func (w *Workspaces) IDs() []string {
+	return w.ids
+}
+
+// Has returns true if cloudInstanceID is one of the workspaces
+func (w *Workspaces) Has(cloudInstanceID string) bool {
+	for _, id := range w.ids {
+		if id == cloudInstanceID {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the client of the workspace, creating it if needed
+func (w *Workspaces) Get(cloudInstanceID string) (Cloud, error) {
+	if !w.Has(cloudInstanceID) {
+		return nil, fmt.Errorf("%w %q", ErrUnknownWorkspace, cloudInstanceID)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if c, ok := w.clients[cloudInstanceID]; ok {
+		return c, nil
+	}
+	c, err := w.newCloud(cloudInstanceID)
+	if err != nil {
+		return nil, fmt.Errorf("could not create client for workspace %q: %v", cloudInstanceID, err)
+	}
+	w.clients[cloudInstanceID] = c
+	return c, nil
+}
+
+// VolumeHandle returns the handle of a volume in the workspace
+func (w *Workspaces) VolumeHandle(cloudInstanceID, volumeID string) string {
+	if cloudInstanceID == w.defaultID {
+		return volumeID
+	}
+	return JoinVolumeHandle(cloudInstanceID, volumeID)
+}
+
+// ForVolume returns the client of the workspace of a volume handle and the PowerVS ID of the volume
+func (w *Workspaces) ForVolume(handle string) (Cloud, string, error) {
+	cloudInstanceID, volumeID := w.ParseVolumeHandle(handle)
+	c, err := w.Get(cloudInstanceID)
+	if err != nil {
+		return nil, "", err
+	}
+	return c, volumeID, nil
+}
+
+// ParseVolumeHandle returns the cloud instance ID and the PowerVS volume ID of a volume handle
+func (w *Workspaces) ParseVolumeHandle(handle string) (cloudInstanceID, volumeID string) {
+	if cloudInstanceID, volumeID = SplitVolumeHandle(handle); cloudInstanceID != "" {
+		return cloudInstanceID, volumeID
+	}
+	return w.defaultID, handle
+}
+
+// JoinVolumeHandle returns the handle of a volume prefixed with the cloud instance ID of its workspace
+func JoinVolumeHandle(cloudInstanceID, volumeID string) string {
+	return cloudInstanceID + volumeHandleSeparator + volumeID
+}
+
+// SplitVolumeHandle returns the cloud instance ID and the PowerVS volume ID of a volume handle,
+// the cloud instance ID is empty for handles without one
+func SplitVolumeHandle(handle string) (cloudInstanceID, volumeID string) {
+	if i := strings.Index(handle, volumeHandleSeparator); i >= 0 {
+		return handle[:i], handle[i+len(volumeHandleSeparator):]
+	}
+	return "", handle
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWorkspaces(t *testing.T) {
+	defaultCloud := &powerVSCloud{cloudInstanceID: "ws-1"}
+	created := 0
+	w := NewWorkspaces("ws-1", defaultCloud, []string{"ws-1", "ws-2"}, func(id string) (Cloud, error) {
+		created++
+		return &powerVSCloud{cloudInstanceID: id}, nil
+	})
+
+	if ids := w.IDs(); !reflect.DeepEqual(ids, []string{"ws-1", "ws-2"}) {
+		t.Fatalf("expected workspaces [ws-1 ws-2], got %v", ids)
+	}
+
+	testCases := []struct {
+		name              string
+		handle            string
+		expectedWorkspace string
+		expectedVolumeID  string
+		expectErr         error
+	}{
+		{
+			name:              "default workspace",
+			handle:            "vol-1",
+			expectedWorkspace: "ws-1",
+			expectedVolumeID:  "vol-1",
+		},
+		{
+			name:              "other workspace",
+			handle:            "ws-2/vol-1",
+			expectedWorkspace: "ws-2",
+			expectedVolumeID:  "vol-1",
+		},
+		{
+			name:      "unknown workspace",
+			handle:    "ws-3/vol-1",
+			expectErr: ErrUnknownWorkspace,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, volumeID, err := w.ForVolume(tc.handle)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected error %v, got: %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if id := c.(*powerVSCloud).cloudInstanceID; id != tc.expectedWorkspace {
+				t.Fatalf("expected client of workspace %s, got %s", tc.expectedWorkspace, id)
+			}
+			if volumeID != tc.expectedVolumeID {
+				t.Fatalf("expected volume ID %s, got %s", tc.expectedVolumeID, volumeID)
+			}
+			if handle := w.VolumeHandle(tc.expectedWorkspace, volumeID); handle != tc.handle {
+				t.Fatalf("expected handle %s, got %s", tc.handle, handle)
+			}
+		})
+	}
+
+	if _, err := w.Get("ws-2"); err != nil {
+		t.Fatal(err)
+	}
+	if created != 1 {
+		t.Fatalf("expected the client of ws-2 to be created once, got %d", created)
+	}
+}
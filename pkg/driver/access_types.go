@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"sync"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+// accessTypes records whether volumes were published as raw block volumes, for expansion
+// requests without volume capability. The records are lost when the controller restarts.
+type accessTypes struct {
+	mu    sync.Mutex
+	block map[string]bool
+}
+
+func newAccessTypes() *accessTypes {
+	return &accessTypes{block: map[string]bool{}}
+}
+
+// record records the access type of the capability volumeID is published with
+func (a *accessTypes) record(volumeID string, volCap *csi.VolumeCapability) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.block[volumeID] = volCap.GetBlock() != nil
+}
+
+// forget drops the access type of the unpublished volumeID
+func (a *accessTypes) forget(volumeID string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.block, volumeID)
+}
+
+// isBlock returns true if volumeID was published as raw block volume, known is false if it
+// wasn't published since the controller started
+func (a *accessTypes) isBlock(volumeID string) (block, known bool) {
+	if a == nil {
+		return false, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	block, known = a.block[volumeID]
+	return block, known
+}
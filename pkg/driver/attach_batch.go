@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// attachDisk attaches disk of ID diskID to the pvm instance nodeID, in a batch with the disks
+// of workspace attached to the node meanwhile when batching is enabled. Errors of the attach limits and the
+// node queue are status errors, cloud errors are returned as is.
+func (d *controllerService) attachDisk(ctx context.Context, c cloud.Cloud, workspace, diskID string, disk *cloud.Disk, nodeID string) error {
+	if d.attachBatches == nil {
+		return d.attachDisks(ctx, c, []string{diskID}, []*cloud.Disk{disk}, nodeID)[0]
+	}
+	result := d.attachBatches.add(nodeBatchKey{workspace: workspace, nodeID: nodeID}, diskID, disk, func(diskIDs []string, disks []*cloud.Disk) []error {
+		// the batch outlives the request that started it
+		return d.attachDisks(context.Background(), c, diskIDs, disks, nodeID)
+	})
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// attachDisks attaches disks of IDs diskIDs to the pvm instance nodeID in its node queue and
+// returns their errors, with a single bulk request if the cloud supports it
+func (d *controllerService) attachDisks(ctx context.Context, c cloud.Cloud, diskIDs []string, disks []*cloud.Disk, nodeID string) []error {
+	errs := make([]error, len(disks))
+	// the limits are checked in the queue so that concurrent attachments are counted
+	if err := d.nodeQueues.acquire(ctx, nodeID, nodeOperationAttach); err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	defer d.nodeQueues.release(nodeID)
+
+	var admitted []int
+	for i, err := range d.checkAttachLimits(ctx, c, disks, nodeID) {
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		admitted = append(admitted, i)
+	}
+
+	if bulk, ok := c.(cloud.BulkAttacher); ok && len(admitted) > 1 {
+		volumeIDs := make([]string, len(admitted))
+		for j, i := range admitted {
+			volumeIDs[j] = diskIDs[i]
+		}
+		klog.V(4).Infof("Attaching volumes %v to node %s", volumeIDs, nodeID)
+		err := bulk.AttachDisks(ctx, volumeIDs, nodeID)
+		if !errors.Is(err, cloud.ErrAlreadyExists) {
+			for _, i := range admitted {
+				errs[i] = err
+			}
+			return errs
+		}
+		// PowerVS attached none of them, one by one the volumes already attached are found
+		klog.V(4).Infof("Attaching volumes %v to node %s one by one, one of them is already attached: %v", volumeIDs, nodeID, err)
+	}
+	for _, i := range admitted {
+		errs[i] = c.AttachDisk(ctx, diskIDs[i], nodeID)
+	}
+	return errs
+}
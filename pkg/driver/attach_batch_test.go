@@ -0,0 +1,207 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func newBatchingControllerService(c cloud.Cloud, window time.Duration) *controllerService {
+	return &controllerService{
+		cloud:         c,
+		driverOptions: &Options{},
+		volumeLocks:   util.NewVolumeLocks(),
+		nodeQueues:    newNodeQueues(),
+		accessTypes:   newAccessTypes(),
+		attachBatches: newNodeBatches(window),
+	}
+}
+
+func createFakeDisks(t *testing.T, c *fake.Cloud, n int) []*cloud.Disk {
+	var disks []*cloud.Disk
+	for i := 0; i < n; i++ {
+		disk, err := c.CreateDisk(context.Background(), fmt.Sprintf("vol-%d", i), &cloud.DiskOptions{CapacityBytes: util.GiB, VolumeType: cloud.VolumeTypeTier3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		disks = append(disks, disk)
+	}
+	return disks
+}
+
+func TestControllerPublishVolumeBatched(t *testing.T) {
+	volCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+	fakeCloud := fake.NewCloud(0)
+	d := newBatchingControllerService(fakeCloud, 100*time.Millisecond)
+	disks := createFakeDisks(t, fakeCloud, 5)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(disks))
+	for i, disk := range disks {
+		wg.Add(1)
+		go func(i int, volumeID string) {
+			defer wg.Done()
+			_, errs[i] = d.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{VolumeId: volumeID, NodeId: expInstanceID, VolumeCapability: volCap})
+		}(i, disk.VolumeID)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Unexpected error publishing volume %d: %v", i, err)
+		}
+	}
+	if n := fakeCloud.CallCount("AttachDisks"); n != 1 {
+		t.Fatalf("Expected the volumes to be attached in 1 batch, got %d", n)
+	}
+	if n := fakeCloud.CallCount("AttachDisk"); n != 0 {
+		t.Fatalf("Expected no single attachment, got %d", n)
+	}
+	for _, disk := range disks {
+		if attached, _ := fakeCloud.IsAttached(context.Background(), disk.VolumeID, expInstanceID); !attached {
+			t.Fatalf("Expected volume %s to be attached", disk.VolumeID)
+		}
+	}
+}
+
+func TestAttachDisks(t *testing.T) {
+	testCases := []struct {
+		name              string
+		tierLimit         int64
+		attachedElsewhere bool
+		bulkErr           error
+		expErrs           []codes.Code
+		expBulk           int
+		expSingle         int
+	}{
+		{
+			name:    "bulk",
+			expErrs: []codes.Code{codes.OK, codes.OK, codes.OK},
+			expBulk: 1,
+		},
+		{
+			name:      "tier limit",
+			tierLimit: 2,
+			expErrs:   []codes.Code{codes.OK, codes.OK, codes.ResourceExhausted},
+			expBulk:   1,
+		},
+		{
+			name:      "single volume within tier limit",
+			tierLimit: 1,
+			expErrs:   []codes.Code{codes.OK, codes.ResourceExhausted, codes.ResourceExhausted},
+			expSingle: 1,
+		},
+		{
+			name:              "already attached elsewhere",
+			attachedElsewhere: true,
+			expErrs:           []codes.Code{codes.OK, codes.OK, codes.Aborted},
+			expBulk:           1,
+			expSingle:         3,
+		},
+		{
+			name:    "bulk failure",
+			bulkErr: errors.New("[POST /pcloud/v2/volumes][500] internal error"),
+			expErrs: []codes.Code{codes.Unavailable, codes.Unavailable, codes.Unavailable},
+			expBulk: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			fakeCloud := fake.NewCloud(0)
+			d := newBatchingControllerService(fakeCloud, 0)
+			if tc.tierLimit > 0 {
+				d.driverOptions.tierAttachLimits = map[string]int64{cloud.VolumeTypeTier3: tc.tierLimit}
+			}
+			disks := createFakeDisks(t, fakeCloud, 3)
+			var diskIDs []string
+			for _, disk := range disks {
+				diskIDs = append(diskIDs, disk.VolumeID)
+			}
+			if tc.attachedElsewhere {
+				if err := fakeCloud.AttachDisk(ctx, disks[2].VolumeID, "other-instance"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.bulkErr != nil {
+				fakeCloud.FailOnCall("AttachDisks", 1, tc.bulkErr)
+			}
+
+			errs := d.attachDisks(ctx, fakeCloud, diskIDs, disks, expInstanceID)
+			for i, err := range errs {
+				code := status.Code(err)
+				if _, ok := status.FromError(err); !ok {
+					code = cloudErrorCode(err)
+				}
+				if code != tc.expErrs[i] {
+					t.Fatalf("Expected code %v for volume %d, got: %v", tc.expErrs[i], i, err)
+				}
+			}
+			if n := fakeCloud.CallCount("AttachDisks"); n != tc.expBulk {
+				t.Fatalf("Expected %d bulk attachments, got %d", tc.expBulk, n)
+			}
+			expSingle := tc.expSingle
+			if tc.attachedElsewhere {
+				// the attachment to the other instance
+				expSingle++
+			}
+			if n := fakeCloud.CallCount("AttachDisk"); n != expSingle {
+				t.Fatalf("Expected %d single attachments, got %d", expSingle, n)
+			}
+		})
+	}
+}
+
+func TestBatchWorkspace(t *testing.T) {
+	d := &controllerService{cloudInstanceID: "ws-1", driverOptions: &Options{legacyVolumeHandles: true}}
+	secrets := func(apikey string) map[string]string {
+		return map[string]string{SecretAPIKeyKey: apikey, SecretCloudInstanceIDKey: "ws-3"}
+	}
+	testCases := []struct {
+		name     string
+		handle   string
+		secrets  map[string]string
+		expected string
+	}{
+		{name: "default workspace", handle: "vol-1", expected: "ws-1"},
+		{name: "other workspace", handle: "ws-2/vol-1", expected: "ws-2"},
+		{name: "legacy handle", handle: "ibmpowervs://us-south/dal12/ws-1/vol-1", expected: "ws-1"},
+		{name: "secrets", handle: "vol-1", secrets: secrets("key-1"), expected: secretCloudKey("key-1", "ws-3")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if workspace := d.batchWorkspace(tc.handle, tc.secrets); workspace != tc.expected {
+				t.Fatalf("expected workspace %q, got %q", tc.expected, workspace)
+			}
+		})
+	}
+	// the volumes of other credentials are never batched together
+	if d.batchWorkspace("vol-1", secrets("key-1")) == d.batchWorkspace("vol-1", secrets("key-2")) {
+		t.Fatalf("expected distinct workspaces for distinct API keys")
+	}
+}
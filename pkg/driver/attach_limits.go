@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// storage adapters the volumes of a pvm instance are attached through
+const (
+	adapterNPIV  = "npiv"
+	adapterVSCSI = "vscsi"
+)
+
+// LUN limits of the storage adapters, including the boot volume
+const (
+	npivVolumeLimit  = 127
+	vscsiVolumeLimit = 32
+)
+
+// adapterVolumeLimits are the volumes a pvm instance can have attached through its adapter
+var adapterVolumeLimits = map[string]int64{
+	adapterNPIV:  npivVolumeLimit,
+	adapterVSCSI: vscsiVolumeLimit,
+}
+
+// nodeVolumeLimits are the volume limits the nodes reported with NodeGetInfo, watched through
+// their CSINodes. Only the nodes know the storage adapter of their pvm instance, which limits
+// the volumes that can be attached to it.
+type nodeVolumeLimits struct {
+	csiNodes storagelisters.CSINodeLister
+	synced   cache.InformerSynced
+}
+
+// newNodeVolumeLimits registers the informer of the CSINodes in factory
+func newNodeVolumeLimits(factory informers.SharedInformerFactory) *nodeVolumeLimits {
+	csiNodes := factory.Storage().V1().CSINodes()
+	return &nodeVolumeLimits{csiNodes: csiNodes.Lister(), synced: csiNodes.Informer().HasSynced}
+}
+
+// limit returns the volume limit the node of the pvm instance nodeID reported, false if it
+// reported none or the CSINodes aren't synced yet
+func (l *nodeVolumeLimits) limit(nodeID string) (int64, bool) {
+	if l == nil || !l.synced() {
+		return 0, false
+	}
+	csiNodes, err := l.csiNodes.List(labels.Everything())
+	if err != nil {
+		klog.Warningf("Could not list the CSINodes to get the volume limit of node %s: %v", nodeID, err)
+		return 0, false
+	}
+	for _, csiNode := range csiNodes {
+		for _, driver := range csiNode.Spec.Drivers {
+			if driver.Name == DriverName && driver.NodeID == nodeID && driver.Allocatable != nil && driver.Allocatable.Count != nil {
+				return int64(*driver.Allocatable.Count), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// checkAttachLimits returns ResourceExhausted for the disks whose attachment to the pvm
+// instance nodeID would exceed the volume limit reported by the node, which depends on its
+// storage adapter, or the limit of their tier. Without a reported limit the NPIV limit of
+// PowerVS applies. The disks are counted in order, as if each of them was attached after the
+// previous ones that are within the limits. The scheduler only knows the limit of the node in
+// total, reported by NodeGetInfo.
+func (d *controllerService) checkAttachLimits(ctx context.Context, c cloud.Cloud, disks []*cloud.Disk, nodeID string) []error {
+	errs := make([]error, len(disks))
+	attachedDisks, err := c.ListDisks(ctx)
+	if err != nil {
+		for i := range errs {
+			errs[i] = cloudError(err, "Could not list the volumes attached to node %q: %v", nodeID, err)
+		}
+		return errs
+	}
+	batch := map[string]bool{}
+	for _, disk := range disks {
+		batch[disk.VolumeID] = true
+	}
+	limit := int64(defaultMaxVolumesPerInstance)
+	if reported, ok := d.nodeVolumeLimits.limit(nodeID); ok {
+		limit = reported
+	}
+	var attached int64
+	attachedOfTier := map[string]int64{}
+	for _, other := range attachedDisks {
+		if batch[other.VolumeID] || !attachedTo(other, nodeID) {
+			continue
+		}
+		attached++
+		attachedOfTier[strings.ToLower(other.DiskType)]++
+	}
+
+	for i, disk := range disks {
+		tier := strings.ToLower(disk.DiskType)
+		tierLimit, hasTierLimit := d.driverOptions.tierAttachLimits[tier]
+		if attached >= limit {
+			errs[i] = status.Errorf(codes.ResourceExhausted, "Node %q already has the maximum of %d volumes attached", nodeID, limit)
+			continue
+		}
+		if hasTierLimit && attachedOfTier[tier] >= tierLimit {
+			errs[i] = status.Errorf(codes.ResourceExhausted, "Node %q already has the maximum of %d %s volumes attached", nodeID, tierLimit, tier)
+			continue
+		}
+		attached++
+		attachedOfTier[tier]++
+	}
+	return errs
+}
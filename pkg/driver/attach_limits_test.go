@@ -0,0 +1,78 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func limitCSINode(name, driver, nodeID string, count *int32) *storagev1.CSINode {
+	d := storagev1.CSINodeDriver{Name: driver, NodeID: nodeID}
+	if count != nil {
+		d.Allocatable = &storagev1.VolumeNodeResources{Count: count}
+	}
+	return &storagev1.CSINode{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: storagev1.CSINodeSpec{Drivers: []storagev1.CSINodeDriver{d}}}
+}
+
+// newSyncedNodeVolumeLimits returns the volume limits of the CSINodes objects once their
+// informer synced
+func newSyncedNodeVolumeLimits(t *testing.T, objects ...runtime.Object) *nodeVolumeLimits {
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory := informers.NewSharedInformerFactory(kubefake.NewSimpleClientset(objects...), 0)
+	l := newNodeVolumeLimits(factory)
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, l.synced) {
+		t.Fatalf("CSINode informer did not sync")
+	}
+	return l
+}
+
+func TestNodeVolumeLimits(t *testing.T) {
+	vscsi, other := int32(vscsiVolumeLimit-1), int32(10)
+	l := newSyncedNodeVolumeLimits(t,
+		limitCSINode("worker-0", DriverName, "pvm-0", &vscsi),
+		limitCSINode("worker-1", DriverName, "pvm-1", nil),
+		limitCSINode("worker-2", "other.csi.driver", "pvm-2", &other),
+	)
+
+	testCases := []struct {
+		nodeID   string
+		expLimit int64
+		expOK    bool
+	}{
+		{nodeID: "pvm-0", expLimit: vscsiVolumeLimit - 1, expOK: true},
+		{nodeID: "pvm-1"},
+		{nodeID: "pvm-2"},
+		{nodeID: "pvm-3"},
+	}
+	for _, tc := range testCases {
+		limit, ok := l.limit(tc.nodeID)
+		if limit != tc.expLimit || ok != tc.expOK {
+			t.Fatalf("expected limit %d (%v) of node %s, got %d (%v)", tc.expLimit, tc.expOK, tc.nodeID, limit, ok)
+		}
+	}
+
+	var unset *nodeVolumeLimits
+	if _, ok := unset.limit("pvm-0"); ok {
+		t.Fatalf("expected no limit without the CSINodes")
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// volumeAttachments cross-checks the detaches of the CO with the VolumeAttachments of the
+// driver, watched by informers
+type volumeAttachments struct {
+	attachments storagelisters.VolumeAttachmentLister
+	pvs         corelisters.PersistentVolumeLister
+	nodes       corelisters.NodeLister
+	synced      []cache.InformerSynced
+}
+
+// newVolumeAttachments registers the informers of the VolumeAttachments, PVs and nodes in
+// factory
+func newVolumeAttachments(factory informers.SharedInformerFactory) *volumeAttachments {
+	attachments := factory.Storage().V1().VolumeAttachments()
+	pvs := factory.Core().V1().PersistentVolumes()
+	nodes := factory.Core().V1().Nodes()
+	a := &volumeAttachments{
+		attachments: attachments.Lister(),
+		pvs:         pvs.Lister(),
+		nodes:       nodes.Lister(),
+		synced:      []cache.InformerSynced{attachments.Informer().HasSynced, pvs.Informer().HasSynced, nodes.Informer().HasSynced},
+	}
+	return a
+}
+
+// referencingAttachment returns the name of a VolumeAttachment of the driver which still
+// wants volumeID attached to the pvm instance nodeID, the VolumeAttachment whose deletion
+// requested the detach is being deleted. Nothing is returned until the informers synced.
+func (a *volumeAttachments) referencingAttachment(volumeID, nodeID string) string {
+	if a == nil {
+		return ""
+	}
+	for _, synced := range a.synced {
+		if !synced() {
+			klog.V(4).Infof("VolumeAttachments not synced yet, not checking the detach of volume %s from node %s", volumeID, nodeID)
+			return ""
+		}
+	}
+	attachments, err := a.attachments.List(labels.Everything())
+	if err != nil {
+		klog.Warningf("Could not list the VolumeAttachments to check the detach of volume %s from node %s: %v", volumeID, nodeID, err)
+		return ""
+	}
+	for _, va := range attachments {
+		if va.Spec.Attacher != DriverName || va.DeletionTimestamp != nil {
+			continue
+		}
+		if a.attachmentVolumeID(va) != volumeID {
+			continue
+		}
+		node, err := a.nodes.Get(va.Spec.NodeName)
+		if err != nil || cloud.NodePvmInstanceID(node) != nodeID {
+			continue
+		}
+		return va.Name
+	}
+	return ""
+}
+
+// attachmentVolumeID returns the volume handle of the PV or inline volume of va
+func (a *volumeAttachments) attachmentVolumeID(va *storagev1.VolumeAttachment) string {
+	var source *v1.CSIPersistentVolumeSource
+	if name := va.Spec.Source.PersistentVolumeName; name != nil {
+		pv, err := a.pvs.Get(*name)
+		if err != nil {
+			return ""
+		}
+		source = pv.Spec.CSI
+	} else if spec := va.Spec.Source.InlineVolumeSpec; spec != nil {
+		source = spec.CSI
+	}
+	if source == nil {
+		return ""
+	}
+	return source.VolumeHandle
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
+)
+
+func volumeAttachment(name, attacher, pvName, nodeName string, deleting bool) *storagev1.VolumeAttachment {
+	va := &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: storagev1.VolumeAttachmentSpec{
+			Attacher: attacher,
+			Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+			NodeName: nodeName,
+		},
+	}
+	if deleting {
+		now := metav1.Now()
+		va.DeletionTimestamp = &now
+	}
+	return va
+}
+
+func attachmentPV(name, handle string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{CSI: &v1.CSIPersistentVolumeSource{Driver: DriverName, VolumeHandle: handle}},
+		},
+	}
+}
+
+func attachmentNode(name, instanceID string) *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{cloud.PvmInstanceIdLabel: instanceID}}}
+}
+
+// newSyncedVolumeAttachments returns the volume attachments of objects once their informers synced
+func newSyncedVolumeAttachments(t *testing.T, objects ...runtime.Object) *volumeAttachments {
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory := informers.NewSharedInformerFactory(kubefake.NewSimpleClientset(objects...), 0)
+	a := newVolumeAttachments(factory)
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, a.synced...) {
+		t.Fatalf("VolumeAttachment informers did not sync")
+	}
+	return a
+}
+
+func TestReferencingAttachment(t *testing.T) {
+	inline := volumeAttachment("inline", DriverName, "", "worker-0", false)
+	inline.Spec.Source = storagev1.VolumeAttachmentSource{InlineVolumeSpec: &v1.PersistentVolumeSpec{
+		PersistentVolumeSource: v1.PersistentVolumeSource{CSI: &v1.CSIPersistentVolumeSource{Driver: DriverName, VolumeHandle: "vol-inline"}},
+	}}
+	a := newSyncedVolumeAttachments(t,
+		attachmentNode("worker-0", "pvm-0"),
+		attachmentNode("worker-1", "pvm-1"),
+		attachmentPV("pv-1", "vol-1"),
+		attachmentPV("pv-2", "vol-2"),
+		attachmentPV("pv-3", "vol-3"),
+		volumeAttachment("va-1", DriverName, "pv-1", "worker-0", false),
+		volumeAttachment("va-2", DriverName, "pv-2", "worker-0", true),
+		volumeAttachment("va-3", "other.csi.driver", "pv-3", "worker-0", false),
+		inline,
+	)
+
+	testCases := []struct {
+		name     string
+		volumeID string
+		nodeID   string
+		expected string
+	}{
+		{name: "attachment of the node", volumeID: "vol-1", nodeID: "pvm-0", expected: "va-1"},
+		{name: "attachment of another node", volumeID: "vol-1", nodeID: "pvm-1"},
+		{name: "deleted attachment", volumeID: "vol-2", nodeID: "pvm-0"},
+		{name: "attachment of another driver", volumeID: "vol-3", nodeID: "pvm-0"},
+		{name: "inline volume", volumeID: "vol-inline", nodeID: "pvm-0", expected: "inline"},
+		{name: "unknown volume", volumeID: "vol-4", nodeID: "pvm-0"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if name := a.referencingAttachment(tc.volumeID, tc.nodeID); name != tc.expected {
+				t.Fatalf("expected VolumeAttachment %q, got %q", tc.expected, name)
+			}
+		})
+	}
+	if name := (*volumeAttachments)(nil).referencingAttachment("vol-1", "pvm-0"); name != "" {
+		t.Fatalf("expected no VolumeAttachments when disabled, got %q", name)
+	}
+}
+
+func TestControllerUnpublishVolumeAttachmentCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		deleting  bool
+		expRefuse bool
+	}{
+		{name: "deleted attachment", deleting: true},
+		{name: "live attachment", expRefuse: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeCloud := fake.NewCloud(0)
+			disk := attachFakeDisks(t, fakeCloud, 1)[0]
+
+			d := newBatchingControllerService(fakeCloud, 0)
+			d.volumeAttachments = newSyncedVolumeAttachments(t,
+				attachmentNode("worker-0", expInstanceID),
+				attachmentPV("pv-1", disk.VolumeID),
+				volumeAttachment("va-1", DriverName, "pv-1", "worker-0", tc.deleting),
+			)
+			req := &csi.ControllerUnpublishVolumeRequest{VolumeId: disk.VolumeID, NodeId: expInstanceID}
+			_, err := d.ControllerUnpublishVolume(context.Background(), req)
+			if tc.expRefuse {
+				if status.Code(err) != codes.FailedPrecondition {
+					t.Fatalf("Expected FailedPrecondition, got: %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			attached, _ := fakeCloud.IsAttached(context.Background(), disk.VolumeID, expInstanceID)
+			if attached != tc.expRefuse {
+				t.Fatalf("Expected volume %s attached %v, got %v", disk.VolumeID, tc.expRefuse, attached)
+			}
+		})
+	}
+}
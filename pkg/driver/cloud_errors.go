@@ -0,0 +1,213 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	gohttp "net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// CloudErrorDomain is the domain of the ErrorInfo details of cloud failures
+const CloudErrorDomain = "power-iaas.cloud.ibm.com"
+
+// Reasons of the ErrorInfo details of cloud failures
+const (
+	CloudErrorTimeout          = "TIMEOUT"
+	CloudErrorCanceled         = "CANCELED"
+	CloudErrorNotFound         = "NOT_FOUND"
+	CloudErrorAlreadyExists    = "ALREADY_EXISTS"
+	CloudErrorDuplicateName    = "DUPLICATE_NAME"
+	CloudErrorVolumeBusy       = "VOLUME_BUSY"
+	CloudErrorVolumeFailed     = "VOLUME_FAILED"
+	CloudErrorCloneFailed      = "CLONE_FAILED"
+	CloudErrorAPIUnavailable   = "API_UNAVAILABLE"
+	CloudErrorInvalidArgument  = "INVALID_ARGUMENT"
+	CloudErrorThrottled        = "THROTTLED"
+	CloudErrorUnauthenticated  = "UNAUTHENTICATED"
+	CloudErrorPermissionDenied = "PERMISSION_DENIED"
+	CloudErrorConflict         = "CONFLICT"
+	CloudErrorServerError      = "SERVER_ERROR"
+	CloudErrorConnection       = "CONNECTION_ERROR"
+	CloudErrorAttachLimit      = "ATTACH_LIMIT_EXCEEDED"
+	CloudErrorQuotaExceeded    = "QUOTA_EXCEEDED"
+	CloudErrorNoCapacity       = "INSUFFICIENT_CAPACITY"
+	CloudErrorUnknown          = "UNKNOWN"
+)
+
+// cloudError returns the status error of the cloud failure err with the message of format
+// and args. The ErrorInfo detail carries the reason of the failure, the HTTP status and the
+// PowerVS error code, failures worth retrying also get a RetryInfo detail.
+func cloudError(err error, format string, args ...interface{}) error {
+	st := status.Newf(cloudErrorCode(err), format, args...)
+	if detailed, derr := st.WithDetails(cloudErrorInfo(err)); derr == nil {
+		st = detailed
+	}
+	if delay := cloudRetryDelay(err); delay > 0 {
+		if detailed, derr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)}); derr == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
+
+// cloudErrorInfo returns the ErrorInfo detail of the cloud failure err
+func cloudErrorInfo(err error) *errdetails.ErrorInfo {
+	info := &errdetails.ErrorInfo{Reason: cloudErrorReason(err), Domain: CloudErrorDomain, Metadata: map[string]string{}}
+	if code := cloud.HTTPStatusCode(err); code != 0 {
+		info.Metadata["httpStatus"] = strconv.Itoa(code)
+	}
+	if payload := cloud.APIErrorPayload(err); payload != nil {
+		if payload.Code != 0 {
+			info.Metadata["powervsCode"] = strconv.FormatInt(payload.Code, 10)
+		}
+		if payload.Error != "" {
+			info.Metadata["powervsError"] = payload.Error
+		}
+	}
+	return info
+}
+
+// cloudErrorReason returns the ErrorInfo reason of the cloud failure err, following the
+// classification of cloudErrorCode
+func cloudErrorReason(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, wait.ErrWaitTimeout):
+		return CloudErrorTimeout
+	case errors.Is(err, context.Canceled):
+		return CloudErrorCanceled
+	case errors.Is(err, cloud.ErrNotFound):
+		return CloudErrorNotFound
+	case errors.Is(err, cloud.ErrAlreadyExists):
+		return CloudErrorAlreadyExists
+	case errors.Is(err, cloud.ErrDuplicateName):
+		return CloudErrorDuplicateName
+	case errors.Is(err, cloud.ErrVolumeBusy):
+		return CloudErrorVolumeBusy
+	case errors.Is(err, cloud.ErrVolumeFailed):
+		return CloudErrorVolumeFailed
+	case errors.Is(err, cloud.ErrCloneFailed):
+		return CloudErrorCloneFailed
+	case errors.Is(err, cloud.ErrCircuitOpen), errors.Is(err, cloud.ErrPollQueueFull):
+		return CloudErrorAPIUnavailable
+	case errors.Is(err, cloud.ErrUnknownWorkspace):
+		return CloudErrorInvalidArgument
+	case cloud.IsAttachLimitError(err):
+		return CloudErrorAttachLimit
+	case cloud.IsQuotaError(err):
+		return CloudErrorQuotaExceeded
+	case cloud.IsInsufficientCapacityError(err):
+		return CloudErrorNoCapacity
+	}
+
+	switch code := cloud.HTTPStatusCode(err); {
+	case code == gohttp.StatusTooManyRequests:
+		return CloudErrorThrottled
+	case code >= gohttp.StatusInternalServerError:
+		return CloudErrorServerError
+	case code == gohttp.StatusBadRequest, code == gohttp.StatusUnprocessableEntity:
+		return CloudErrorInvalidArgument
+	case code == gohttp.StatusUnauthorized:
+		return CloudErrorUnauthenticated
+	case code == gohttp.StatusForbidden:
+		return CloudErrorPermissionDenied
+	case code == gohttp.StatusNotFound:
+		return CloudErrorNotFound
+	case code == gohttp.StatusConflict:
+		return CloudErrorConflict
+	case code == 0 && cloud.IsRetryableError(err):
+		return CloudErrorConnection
+	}
+	return CloudErrorUnknown
+}
+
+// cloudRetryDelay returns the delay after which the CO should retry after the cloud failure
+// err, 0 for failures which won't go away by themselves
+func cloudRetryDelay(err error) time.Duration {
+	switch {
+	case errors.Is(err, cloud.ErrCircuitOpen):
+		// the API is probed again after that long
+		return cloud.DefaultBreakerProbeInterval
+	case errors.Is(err, cloud.ErrVolumeBusy), errors.Is(err, cloud.ErrPollQueueFull), cloud.HTTPStatusCode(err) == gohttp.StatusConflict:
+		return volumeLockRetryDelay
+	case cloud.IsRetryableError(err):
+		// the retries of the cloud layer were exhausted, waiting up to the backoff cap
+		return cloud.DefaultBackoff.Cap
+	}
+	return 0
+}
+
+// cloudErrorCode maps an error returned by the cloud provider to the gRPC code reported to
+// the CO, errors which could not be classified are reported as Internal
+func cloudErrorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, wait.ErrWaitTimeout):
+		return codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, cloud.ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, cloud.ErrAlreadyExists):
+		return codes.AlreadyExists
+	case errors.Is(err, cloud.ErrDuplicateName):
+		// the CO can't resolve it by retrying, one of the volumes has to be removed
+		return codes.FailedPrecondition
+	case errors.Is(err, cloud.ErrVolumeBusy):
+		// another operation is pending on the volume
+		return codes.Aborted
+	case errors.Is(err, cloud.ErrVolumeFailed):
+		// retrying won't help, the volume has to be deleted
+		return codes.FailedPrecondition
+	case errors.Is(err, cloud.ErrCircuitOpen), errors.Is(err, cloud.ErrPollQueueFull):
+		return codes.Unavailable
+	case errors.Is(err, cloud.ErrUnknownWorkspace):
+		return codes.InvalidArgument
+	case cloud.IsAttachLimitError(err), cloud.IsQuotaError(err):
+		// retrying won't help until volumes are detached or deleted, the scheduler and the
+		// provisioner back off
+		return codes.ResourceExhausted
+	case cloud.IsInsufficientCapacityError(err):
+		// like a size exceeding the largest allocation of the tier
+		return codes.OutOfRange
+	case cloud.IsRetryableError(err):
+		// the retries in the cloud layer were exhausted, let the CO retry later
+		return codes.Unavailable
+	}
+
+	switch cloud.HTTPStatusCode(err) {
+	case gohttp.StatusBadRequest, gohttp.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case gohttp.StatusUnauthorized:
+		return codes.Unauthenticated
+	case gohttp.StatusForbidden:
+		return codes.PermissionDenied
+	case gohttp.StatusNotFound:
+		return codes.NotFound
+	case gohttp.StatusConflict:
+		return codes.Aborted
+	}
+	return codes.Internal
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/IBM-Cloud/power-go-client/power/client/p_cloud_volumes"
+	"github.com/IBM-Cloud/power-go-client/power/models"
+	"github.com/go-openapi/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+func TestCloudErrorCode(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected codes.Code
+	}{
+		{
+			name:     "not found",
+			err:      fmt.Errorf("lookup failed: %w", cloud.ErrNotFound),
+			expected: codes.NotFound,
+		},
+		{
+			name:     "already exists",
+			err:      cloud.ErrAlreadyExists,
+			expected: codes.AlreadyExists,
+		},
+		{
+			name:     "duplicate name",
+			err:      fmt.Errorf("%w %q", cloud.ErrDuplicateName, "pvc-1"),
+			expected: codes.FailedPrecondition,
+		},
+		{
+			name:     "throttled",
+			err:      runtime.NewAPIError("op", nil, 429),
+			expected: codes.Unavailable,
+		},
+		{
+			name:     "server error from generated client",
+			err:      errors.New("[GET /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes/{volume_id}][503] unavailable"),
+			expected: codes.Unavailable,
+		},
+		{
+			name:     "circuit breaker open",
+			err:      cloud.ErrCircuitOpen,
+			expected: codes.Unavailable,
+		},
+		{
+			name:     "unknown workspace",
+			err:      fmt.Errorf("%w %q", cloud.ErrUnknownWorkspace, "ws-3"),
+			expected: codes.InvalidArgument,
+		},
+		{
+			name:     "bad request",
+			err:      runtime.NewAPIError("op", nil, 400),
+			expected: codes.InvalidArgument,
+		},
+		{
+			name:     "unauthorized",
+			err:      fmt.Errorf("failed: %w", runtime.NewAPIError("op", nil, 401)),
+			expected: codes.Unauthenticated,
+		},
+		{
+			name:     "forbidden",
+			err:      runtime.NewAPIError("op", nil, 403),
+			expected: codes.PermissionDenied,
+		},
+		{
+			name:     "conflict",
+			err:      runtime.NewAPIError("op", nil, 409),
+			expected: codes.Aborted,
+		},
+		{
+			name:     "deadline exceeded",
+			err:      fmt.Errorf("request failed: %w", context.DeadlineExceeded),
+			expected: codes.DeadlineExceeded,
+		},
+		{
+			name:     "volume state timeout",
+			err:      wait.ErrWaitTimeout,
+			expected: codes.DeadlineExceeded,
+		},
+		{
+			name:     "canceled",
+			err:      context.Canceled,
+			expected: codes.Canceled,
+		},
+		{
+			name:     "attach limit",
+			err:      errors.New("[POST /pcloud/v1/cloud-instances/{cloud_instance_id}/pvm-instances/{pvm_instance_id}/volumes/{volume_id}][400] maximum volumes attached"),
+			expected: codes.ResourceExhausted,
+		},
+		{
+			name:     "quota exceeded",
+			err:      errors.New("[POST /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes][500] quota exceeded"),
+			expected: codes.ResourceExhausted,
+		},
+		{
+			name:     "no space in pool",
+			err:      errors.New("[POST /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes][400] no space in pool"),
+			expected: codes.OutOfRange,
+		},
+		{
+			name:     "unknown",
+			err:      errors.New("something went wrong"),
+			expected: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := cloudErrorCode(tc.err); code != tc.expected {
+				t.Fatalf("expected code %v, got %v", tc.expected, code)
+			}
+		})
+	}
+}
+
+func TestCloudError(t *testing.T) {
+	badRequest := p_cloud_volumes.NewPcloudCloudinstancesVolumesPostBadRequest()
+	badRequest.Payload = &models.Error{Code: 4001, Error: "Bad Request", Description: "invalid disk type"}
+	unauthorized := p_cloud_volumes.NewPcloudPvminstancesVolumesPostUnauthorized()
+	unauthorized.Payload = &models.Error{Error: "Unauthorized"}
+
+	testCases := []struct {
+		name          string
+		err           error
+		expCode       codes.Code
+		expReason     string
+		expMetadata   map[string]string
+		expRetryDelay time.Duration
+	}{
+		{
+			name:          "throttled",
+			err:           runtime.NewAPIError("op", nil, 429),
+			expCode:       codes.Unavailable,
+			expReason:     CloudErrorThrottled,
+			expMetadata:   map[string]string{"httpStatus": "429"},
+			expRetryDelay: cloud.DefaultBackoff.Cap,
+		},
+		{
+			name:        "bad request with payload",
+			err:         fmt.Errorf("create failed: %w", badRequest),
+			expCode:     codes.InvalidArgument,
+			expReason:   CloudErrorInvalidArgument,
+			expMetadata: map[string]string{"httpStatus": "400", "powervsCode": "4001", "powervsError": "Bad Request"},
+		},
+		{
+			name:        "unauthorized",
+			err:         unauthorized,
+			expCode:     codes.Unauthenticated,
+			expReason:   CloudErrorUnauthenticated,
+			expMetadata: map[string]string{"httpStatus": "401", "powervsError": "Unauthorized"},
+		},
+		{
+			name:          "circuit breaker open",
+			err:           cloud.ErrCircuitOpen,
+			expCode:       codes.Unavailable,
+			expReason:     CloudErrorAPIUnavailable,
+			expRetryDelay: cloud.DefaultBreakerProbeInterval,
+		},
+		{
+			name:          "volume busy",
+			err:           fmt.Errorf("%w: volume vol-1 is resizing", cloud.ErrVolumeBusy),
+			expCode:       codes.Aborted,
+			expReason:     CloudErrorVolumeBusy,
+			expRetryDelay: volumeLockRetryDelay,
+		},
+		{
+			name:      "volume failed",
+			err:       fmt.Errorf("%w: volume vol-1", cloud.ErrVolumeFailed),
+			expCode:   codes.FailedPrecondition,
+			expReason: CloudErrorVolumeFailed,
+		},
+		{
+			name:      "clone failed",
+			err:       fmt.Errorf("%w: clone task task-1 of volume vol-1: out of space", cloud.ErrCloneFailed),
+			expCode:   codes.Internal,
+			expReason: CloudErrorCloneFailed,
+		},
+		{
+			name:          "connection reset",
+			err:           fmt.Errorf("read: %w", syscall.ECONNRESET),
+			expCode:       codes.Unavailable,
+			expReason:     CloudErrorConnection,
+			expRetryDelay: cloud.DefaultBackoff.Cap,
+		},
+		{
+			name:        "quota exceeded without retry",
+			err:         errors.New("[POST /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes][500] quota exceeded"),
+			expCode:     codes.ResourceExhausted,
+			expReason:   CloudErrorQuotaExceeded,
+			expMetadata: map[string]string{"httpStatus": "500"},
+		},
+		{
+			name:      "unknown",
+			err:       errors.New("something went wrong"),
+			expCode:   codes.Internal,
+			expReason: CloudErrorUnknown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st := status.Convert(cloudError(tc.err, "Could not create volume %q: %v", "pvc-1", tc.err))
+			if st.Code() != tc.expCode {
+				t.Fatalf("expected code %v, got %v", tc.expCode, st.Code())
+			}
+			var info *errdetails.ErrorInfo
+			var retryDelay time.Duration
+			for _, detail := range st.Details() {
+				switch d := detail.(type) {
+				case *errdetails.ErrorInfo:
+					info = d
+				case *errdetails.RetryInfo:
+					retryDelay = d.RetryDelay.AsDuration()
+				}
+			}
+			if info == nil {
+				t.Fatalf("expected an ErrorInfo detail, got %v", st.Details())
+			}
+			if info.Reason != tc.expReason || info.Domain != CloudErrorDomain || (len(info.Metadata) > 0 || len(tc.expMetadata) > 0) && !reflect.DeepEqual(info.Metadata, tc.expMetadata) {
+				t.Fatalf("expected reason %s and metadata %v, got %s/%s and %v", tc.expReason, tc.expMetadata, info.Domain, info.Reason, info.Metadata)
+			}
+			if retryDelay != tc.expRetryDelay {
+				t.Fatalf("expected retry delay %v, got %v", tc.expRetryDelay, retryDelay)
+			}
+		})
+	}
+}
@@ -18,18 +18,76 @@ package driver
 
 //DONE
 
-// constants of keys in PublishContext
+import (
+	"time"
+
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver/params"
+)
+
+// constants of keys in PublishContext, the node service only needs the WWN to find the device
 const (
 	WWNKey = "wwn"
+	// ShareableKey is "true" for volumes that can be attached to several nodes
+	ShareableKey = "shareable"
+	// TierKey is the storage tier of the volume, one of cloud.ValidVolumeTypes
+	TierKey = "tier"
 )
 
-// constants of keys in volume parameters
+// constants of keys in volume context
 const (
-	// VolumeTypeKey represents key for volume type
-	VolumeTypeKey = "type"
+	// PreFormattedKey marks a volume whose existing filesystem must be mounted as is, NodeStage
+	// then never formats the volume and only checks that its filesystem matches the fsType
+	PreFormattedKey = "preFormatted"
+
+	// IOPSKey holds the IOPS provisioned for the volume by its tier and size
+	IOPSKey = "iops"
+
+	// FsckPolicyKey is the FsckPolicy NodeStage checks the filesystem of the volume with before
+	// mounting it, instead of the --fsck-policy of the node. It's also a StorageClass parameter
+	// passed on in the volume context.
+	FsckPolicyKey = params.FsckPolicyKey
+
+	// FormatOptionsKey are the space separated mkfs options NodeStage formats the volume with,
+	// instead of the --ext4-format-options of the node. It's also a StorageClass parameter passed
+	// on in the volume context.
+	FormatOptionsKey = params.FormatOptionsKey
+)
+
+// constants of keys in volume parameters, see package params
+const (
+	VolumeTypeKey         = params.VolumeTypeKey
+	IOPSParameterKey      = params.IOPSKey
+	WorkspaceKey          = params.WorkspaceKey
+	ReplicationEnabledKey = params.ReplicationEnabledKey
+	StoragePoolKey        = params.StoragePoolKey
+	TagKeyPrefix          = params.TagKeyPrefix
+	PVCNameKey            = params.PVCNameKey
+	PVCNamespaceKey       = params.PVCNamespaceKey
+	PVNameKey             = params.PVNameKey
+)
+
+// constants of tag keys attached to provisioned volumes
+const (
+	// ClusterIDTagKey tags volumes with the --k8s-tag-cluster-id of the cluster that created them
+	ClusterIDTagKey = "kubernetes-cluster-id"
+	// PVCNameTagKey, PVCNamespaceTagKey and PVNameTagKey tag volumes with their Kubernetes objects
+	PVCNameTagKey      = params.PVCNameTagKey
+	PVCNamespaceTagKey = params.PVCNamespaceTagKey
+	PVNameTagKey       = params.PVNameTagKey
 )
 
 // constants for default command line flag values
 const (
+	// DefaultExt4FormatOptions let the kernel initialize the inode tables after the first mount
+	// and skip discarding the blocks of the new volume, which take minutes on multi-TB volumes
+	DefaultExt4FormatOptions = "-E lazy_itable_init=1,nodiscard"
+
 	DefaultCSIEndpoint = "unix://tmp/csi.sock"
+	// DefaultShutdownTimeout leaves a margin to the default termination grace period of pods
+	DefaultShutdownTimeout = 25 * time.Second
+	// DefaultVolumeLockTimeout is well below the RPC timeouts of the sidecars, which get
+	// Aborted rather than DeadlineExceeded for a busy volume
+	DefaultVolumeLockTimeout = 5 * time.Second
+	// DefaultSlowOperationThreshold is half of the default volume state timeout
+	DefaultSlowOperationThreshold = time.Minute
 )
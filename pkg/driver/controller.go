@@ -18,37 +18,111 @@ package driver
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	csi "github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"k8s.io/client-go/informers"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver/params"
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
 )
 
 var (
-	// TODO: explore multi-node attach
-	volumeCaps = []csi.VolumeCapability_AccessMode{
+	volumeCaps = []*csi.VolumeCapability_AccessMode{
 		{
 			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
 		},
 	}
 
-	// controllerCaps represents the capability of controller service
+	// shareableVolumeCaps are the access modes of shareable volumes only, which PowerVS
+	// attaches to several instances
+	shareableVolumeCaps = []*csi.VolumeCapability_AccessMode{
+		{
+			Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+		},
+		{
+			Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER,
+		},
+		{
+			Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+		},
+	}
+
+	// singleNodeVolumeCaps are the access modes of the SingleNodeMultiWriter feature, a
+	// single writer is enforced by the CO
+	singleNodeVolumeCaps = []*csi.VolumeCapability_AccessMode{
+		{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+		},
+		{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER,
+		},
+	}
+
+	// controllerCaps represents the capability of controller service, see controllerCapabilities
 	controllerCaps = []csi.ControllerServiceCapability_RPC_Type{
 		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+		csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
 		csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME,
 		csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
+		csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+		csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+		csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES,
+		csi.ControllerServiceCapability_RPC_GET_VOLUME,
+		csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+		csi.ControllerServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER,
+		csi.ControllerServiceCapability_RPC_MODIFY_VOLUME,
 	}
 )
 
 // controllerService represents the controller service of CSI driver
 type controllerService struct {
-	cloud         cloud.Cloud
+	// the RPCs of newer CSI specs fail with Unimplemented until they are implemented
+	csi.UnimplementedControllerServer
+	csi.UnimplementedGroupControllerServer
+
+	cloud cloud.Cloud
+	// cloudInstanceID is the workspace of cloud, reported in the topology of its volumes. It is
+	// empty when the workspace isn't known.
+	cloudInstanceID string
+	// workspaces holds the clients of all managed workspaces, it is nil when the controller
+	// only manages the workspace of cloud
+	workspaces *cloud.Workspaces
+	// secretClouds holds the clients of the workspaces of the secrets of requests
+	secretClouds  *secretClouds
 	driverOptions *Options
 	volumeLocks   *util.VolumeLocks
+	// nodeQueues serializes the attach and detach operations per node
+	nodeQueues *nodeQueues
+	// attachBatches batches the attachments to a node, nil when batching is disabled
+	attachBatches *nodeBatches
+	// detachBatches batches the detaches from a node, nil when batching is disabled
+	detachBatches *nodeBatches
+	// events emits events on the PVCs and PVs of failing volumes, it is nil when disabled
+	events *volumeEvents
+	// accessTypes records the access types volumes are published with
+	accessTypes *accessTypes
+	// failingDetaches records the failing detaches to force, nil when force detaching is disabled
+	failingDetaches *failingDetaches
+	// outOfServiceNodes looks up the out-of-service nodes, nil when NonGracefulNodeShutdown is disabled
+	outOfServiceNodes *outOfServiceNodes
+	// volumeAttachments cross-checks the detaches with the VolumeAttachments, nil when
+	// VolumeAttachmentCheck is disabled
+	volumeAttachments *volumeAttachments
+	// nodeVolumeLimits are the volume limits reported by the nodes, nil without access to the
+	// kubernetes API
+	nodeVolumeLimits *nodeVolumeLimits
+	// informers watch the kubernetes objects of outOfServiceNodes, volumeAttachments and
+	// nodeVolumeLimits from Run until Stop, nil without access to the kubernetes API
+	informers informers.SharedInformerFactory
 }
 
 var (
@@ -58,37 +132,119 @@ var (
 // newControllerService creates a new controller service
 // it panics if failed to create the service
 func newControllerService(driverOptions *Options) controllerService {
-	klog.V(4).Infof("retrieving node info from metadata service")
-	metadata, err := cloud.NewMetadataService(cloud.DefaultKubernetesAPIClient)
-	if err != nil {
-		panic(err)
+	c := driverOptions.cloud
+	var cloudInstanceID string
+	if c == nil {
+		klog.V(4).Infof("retrieving node info from metadata service")
+		metadata, err := driverOptions.instanceMetadata()
+		if err != nil {
+			panic(err)
+		}
+
+		cloudInstanceID = metadata.GetCloudInstanceId()
+		c, err = driverOptions.newCloud(cloudInstanceID, driverOptions.cloudOptions()...)
+		if err != nil {
+			panic(err)
+		}
+	} else if len(driverOptions.cloudInstanceIDs) > 0 {
+		// an injected cloud is the client of the first workspace
+		cloudInstanceID = driverOptions.cloudInstanceIDs[0]
 	}
 
-	c, err := NewPowerVSCloudFunc(metadata.GetCloudInstanceId(), driverOptions.debug)
-	if err != nil {
-		panic(err)
+	var workspaces *cloud.Workspaces
+	if len(driverOptions.cloudInstanceIDs) > 0 {
+		workspaces = cloud.NewWorkspaces(cloudInstanceID, c, driverOptions.cloudInstanceIDs, func(id string) (cloud.Cloud, error) {
+			return driverOptions.newCloud(id, driverOptions.cloudOptions()...)
+		})
+		klog.Infof("Managing volumes in PowerVS workspaces %v, default %s", workspaces.IDs(), cloudInstanceID)
+	}
+
+	secrets := newSecretClouds(func(apikey, cloudInstanceID string) (cloud.Cloud, error) {
+		return driverOptions.newCloud(cloudInstanceID, append(driverOptions.cloudOptions(), cloud.WithAPIKey(apikey))...)
+	})
+
+	var events *volumeEvents
+	if driverOptions.events {
+		client, err := cloud.DefaultKubernetesAPIClient()
+		if err != nil {
+			panic(err)
+		}
+		events = newVolumeEvents(client)
+	}
+
+	var attachBatches, detachBatches *nodeBatches
+	if driverOptions.attachBatchWindow > 0 {
+		attachBatches = newNodeBatches(driverOptions.attachBatchWindow)
+	}
+	if driverOptions.detachBatchWindow > 0 {
+		detachBatches = newNodeBatches(driverOptions.detachBatchWindow)
+	}
+	// the informers of the controller share a factory, which starts each kind of object once
+	var factory informers.SharedInformerFactory
+	var outOfService *outOfServiceNodes
+	var attachments *volumeAttachments
+	var nodeLimits *nodeVolumeLimits
+	if client, err := cloud.DefaultKubernetesAPIClient(); err != nil {
+		if driverOptions.enabled(NonGracefulNodeShutdown) || driverOptions.enabled(VolumeAttachmentCheck) {
+			panic(err)
+		}
+		klog.Warningf("Could not create kubernetes client to watch the volume limits of the nodes, attaching up to %d volumes to every node: %v", defaultMaxVolumesPerInstance, err)
+	} else {
+		factory = informers.NewSharedInformerFactory(client, 0)
+		if driverOptions.enabled(NonGracefulNodeShutdown) {
+			outOfService = newOutOfServiceNodes(factory)
+		}
+		if driverOptions.enabled(VolumeAttachmentCheck) {
+			attachments = newVolumeAttachments(factory)
+		}
+		nodeLimits = newNodeVolumeLimits(factory)
+	}
+
+	var failing *failingDetaches
+	if driverOptions.forceDetachTimeout > 0 {
+		failing = newFailingDetaches(driverOptions.forceDetachTimeout)
 	}
 
 	return controllerService{
-		cloud:         c,
-		driverOptions: driverOptions,
-		volumeLocks:   util.NewVolumeLocks(),
+		cloud:             c,
+		cloudInstanceID:   cloudInstanceID,
+		workspaces:        workspaces,
+		secretClouds:      secrets,
+		driverOptions:     driverOptions,
+		volumeLocks:       util.NewVolumeLocks(),
+		nodeQueues:        newNodeQueues(),
+		events:            events,
+		accessTypes:       newAccessTypes(),
+		attachBatches:     attachBatches,
+		detachBatches:     detachBatches,
+		failingDetaches:   failing,
+		outOfServiceNodes: outOfService,
+		volumeAttachments: attachments,
+		nodeVolumeLimits:  nodeLimits,
+		informers:         factory,
 	}
 }
 
 func (d *controllerService) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
-	klog.V(4).Infof("CreateVolume: called with args %+v", *req)
+	ctx, observe := observeVolumeOperation(ctx, volumeOperationProvision)
+	resp, err := d.createVolume(ctx, req)
+	observe(err)
+	return resp, err
+}
+
+func (d *controllerService) createVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+	klog.V(4).Infof("CreateVolume: called with args %s", summarizeRequest(req))
 	volName := req.GetName()
 	if len(volName) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume name not provided")
 	}
 
-	if acquired := d.volumeLocks.TryAcquire(volName); !acquired {
-		return nil, status.Errorf(codes.Aborted, util.VolumeOperationAlreadyExistsFmt, volName)
+	if err := acquireVolumeLock(ctx, d.volumeLocks, volName, d.driverOptions); err != nil {
+		return nil, err
 	}
 	defer d.volumeLocks.Release(volName)
 
-	volSizeBytes, err := getVolSizeBytes(req)
+	volSizeBytes, err := volumeSizeBytes(req.GetCapacityRange(), cloud.DefaultVolumeSize)
 	if err != nil {
 		return nil, err
 	}
@@ -98,88 +254,352 @@ func (d *controllerService) CreateVolume(ctx context.Context, req *csi.CreateVol
 		return nil, status.Error(codes.InvalidArgument, "Volume capabilities not provided")
 	}
 
-	if !isValidVolumeCapabilities(volCaps) {
+	if !isValidVolumeCapabilities(d.driverOptions.accessModes(), volCaps) {
 		modes := util.GetAccessModes(volCaps)
 		stringModes := strings.Join(*modes, ", ")
 		errString := "Volume capabilities " + stringModes + " not supported. Only AccessModes[ReadWriteOnce] supported."
 		return nil, status.Error(codes.InvalidArgument, errString)
 	}
 
-	var volumeType string
+	parameters, err := d.createParameters(req)
+	if err != nil {
+		return nil, err
+	}
+	volumeParams, err := parseVolumeParameters(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	// the volumes of a workload are placed in the storage pool of the node it is scheduled to
+	storagePool := volumeParams.StoragePool
+	if storagePool == "" && d.driverOptions.enabled(StoragePoolTopology) {
+		storagePool = topologyPool(req.GetAccessibilityRequirements())
+	}
 
-	for key, value := range req.GetParameters() {
-		switch strings.ToLower(key) {
-		case VolumeTypeKey:
-			volumeType = value
-		default:
-			return nil, status.Errorf(codes.InvalidArgument, "Invalid parameter key %s for CreateVolume", key)
-		}
+	clusterTags := map[string]string{}
+	if d.driverOptions.kubernetesClusterID != "" {
+		clusterTags[ClusterIDTagKey] = d.driverOptions.kubernetesClusterID
 	}
 
 	opts := &cloud.DiskOptions{
-		Shareable:     false,
-		CapacityBytes: volSizeBytes,
-		VolumeType:    volumeType,
+		Shareable:          false,
+		CapacityBytes:      volSizeBytes,
+		VolumeType:         volumeParams.VolumeType,
+		ReplicationEnabled: volumeParams.ReplicationEnabled,
+		StoragePool:        storagePool,
+		Tags:               mergeTags(clusterTags, volumeParams.MetadataTags, volumeParams.Tags, d.driverOptions.extraTags),
+	}
+
+	// volumes of StorageClasses with a provisioner secret are created in the workspace of the secret
+	c, cloudInstanceID, err := d.secretCloud(req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		c, cloudInstanceID, err = d.selectWorkspace(volumeParams.Workspace, req.GetAccessibilityRequirements())
+		if err != nil {
+			return nil, err
+		}
+	} else if volumeParams.Workspace != "" && volumeParams.Workspace != cloudInstanceID {
+		return nil, status.Errorf(codes.InvalidArgument, "Parameter %s %q is not the workspace %q of the provisioner secret", WorkspaceKey, volumeParams.Workspace, cloudInstanceID)
+	}
+
+	// PowerVS clones volumes in the storage pool and tier of their source
+	var source *cloud.Disk
+	if content := req.GetVolumeContentSource(); content != nil {
+		if source, err = d.cloneSource(ctx, c, content, req.GetSecrets()); err != nil {
+			return nil, err
+		}
+		if volumeParams.StoragePool != "" && volumeParams.StoragePool != source.StoragePool {
+			return nil, status.Errorf(codes.InvalidArgument, "Parameter %s %q is not the storage pool %q of the source volume, PowerVS clones volumes in the pool of their source", StoragePoolKey, volumeParams.StoragePool, source.StoragePool)
+		}
+		if volumeParams.VolumeType != "" && volumeParams.VolumeType != source.DiskType {
+			return nil, status.Errorf(codes.InvalidArgument, "Parameter %s %q is not the type %q of the source volume, PowerVS clones volumes in the tier of their source", VolumeTypeKey, volumeParams.VolumeType, source.DiskType)
+		}
+		if volumeParams.ReplicationEnabled {
+			return nil, status.Errorf(codes.InvalidArgument, "Parameter %s is not supported for clones", ReplicationEnabledKey)
+		}
+		sourceBytes := util.GiBToBytes(source.CapacityGiB)
+		if volSizeBytes, err = volumeSizeBytes(req.GetCapacityRange(), sourceBytes); err != nil {
+			return nil, err
+		}
+		if volSizeBytes < sourceBytes {
+			return nil, status.Errorf(codes.OutOfRange, "Volume size of %d GiB is smaller than the %d GiB of the source volume", util.BytesToGiB(volSizeBytes), source.CapacityGiB)
+		}
+		storagePool = source.StoragePool
+		opts.StoragePool = source.StoragePool
+		opts.VolumeType = source.DiskType
+		opts.CapacityBytes = volSizeBytes
+	}
+
+	if iops := volumeParams.IOPS; iops > 0 {
+		tier := opts.VolumeType
+		if tier == "" {
+			tier = cloud.DefaultVolumeType
+		}
+		capacityGiB := util.BytesToGiB(volSizeBytes)
+		if provisioned := cloud.ProvisionedIOPS(tier, capacityGiB); provisioned < iops {
+			return nil, status.Errorf(codes.InvalidArgument, "Volume type %s provides %d IOPS for %d GiB, less than the %d IOPS of parameter %s", tier, provisioned, capacityGiB, iops, IOPSParameterKey)
+		}
+	}
+	if workspace := d.topologyWorkspace(cloudInstanceID); !workspaceAccessible(req.GetAccessibilityRequirements(), workspace) {
+		return nil, status.Errorf(codes.ResourceExhausted, "Volume of workspace %q is not accessible from the requisite topologies %v", workspace, req.GetAccessibilityRequirements().GetRequisite())
+	}
+	if d.driverOptions.enabled(StoragePoolTopology) && storagePool != "" {
+		segments := map[string]string{WorkspaceTopologyKey: d.topologyWorkspace(cloudInstanceID), StoragePoolTopologyKey: storagePool}
+		if !topologyAccessible(req.GetAccessibilityRequirements(), segments) {
+			return nil, status.Errorf(codes.ResourceExhausted, "Volume of storage pool %q is not accessible from the requisite topologies %v", storagePool, req.GetAccessibilityRequirements().GetRequisite())
+		}
 	}
 
 	// check if disk exists
 	// disk exists only if previous createVolume request fails due to any network/tcp error
-	diskDetails, _ := d.cloud.GetDiskByName(volName)
+	diskDetails, err := c.GetDiskByName(ctx, volName)
+	if err != nil && !errors.Is(err, cloud.ErrNotFound) {
+		// creating the volume without knowing whether it exists could duplicate it
+		return nil, cloudError(err, "Could not look up volume %q: %v", volName, err)
+	}
 	if diskDetails != nil {
+		if diskDetails.State == cloud.VolumeErrorState {
+			d.events.claimWarning(volumeParams.PVCNamespace, volumeParams.PVCName, EventVolumeFailed, "PowerVS volume %s is in state %s, delete it to let it be recreated", diskDetails.VolumeID, diskDetails.State)
+		}
 		// wait for volume to be available as the volume already exists
 		err := verifyVolumeDetails(opts, diskDetails)
 		if err != nil {
 			return nil, err
 		}
-		err = d.cloud.WaitForVolumeState(diskDetails.VolumeID, cloud.VolumeAvailableState)
+		err = c.WaitForVolumeState(ctx, diskDetails.VolumeID, cloud.VolumeAvailableState)
 		if err != nil {
-			return nil, status.Errorf(codes.Internal, "Disk already exists and not in expected state")
+			return nil, cloudError(err, "Volume %q already exists but is not available: %v", volName, err)
+		}
+		resp := d.newCreateVolumeResponse(diskDetails, cloudInstanceID, volumeParams, req.GetAccessibilityRequirements())
+		resp.Volume.ContentSource = req.GetVolumeContentSource()
+		return resp, nil
+	}
+
+	var disk *cloud.Disk
+	if source != nil {
+		cloner, ok := c.(cloud.Cloner)
+		if !ok {
+			return nil, status.Error(codes.Unimplemented, "Volumes can't be cloned in the workspace")
+		}
+		disk, err = cloner.CloneDisk(ctx, source.VolumeID, volName, opts)
+	} else {
+		disk, err = c.CreateDisk(ctx, volName, opts)
+	}
+	if err != nil {
+		if reason, message := cloudFailureEvent(err); reason != "" {
+			d.events.claimWarning(volumeParams.PVCNamespace, volumeParams.PVCName, reason, "Creation of volume %s %s: %v", volName, message, err)
+		}
+		return nil, cloudError(err, "Could not create volume %q: %v", volName, err)
+	}
+	resp := d.newCreateVolumeResponse(disk, cloudInstanceID, volumeParams, req.GetAccessibilityRequirements())
+	resp.Volume.ContentSource = req.GetVolumeContentSource()
+	return resp, nil
+}
+
+// cloneSource returns the volume of the content source of a clone, which must be in the
+// workspace c the clone is created in
+func (d *controllerService) cloneSource(ctx context.Context, c cloud.Cloud, content *csi.VolumeContentSource, secrets map[string]string) (*cloud.Disk, error) {
+	if content.GetSnapshot() != nil {
+		return nil, status.Error(codes.InvalidArgument, "Volumes can't be created from snapshots")
+	}
+	handle := content.GetVolume().GetVolumeId()
+	if handle == "" {
+		return nil, status.Error(codes.InvalidArgument, "Source volume ID not provided")
+	}
+	sc, volumeID, err := d.cloudForVolume(handle, secrets)
+	if err != nil {
+		return nil, err
+	}
+	if sc != c {
+		return nil, status.Errorf(codes.InvalidArgument, "Source volume %q is not in the workspace of the volume, PowerVS clones volumes within a workspace", handle)
+	}
+	disk, err := c.GetDiskByID(ctx, volumeID)
+	if err != nil {
+		if errors.Is(err, cloud.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "Source volume %q not found", handle)
+		}
+		return nil, cloudError(err, "Could not get source volume %q: %v", handle, err)
+	}
+	return disk, nil
+}
+
+// selectWorkspace returns the client and the cloud instance ID of the workspace a volume is
+// created in: the one of the workspace parameter, else the first one of the preferred
+// and requisite topologies, else the default workspace
+func (d *controllerService) selectWorkspace(workspace string, requirements *csi.TopologyRequirement) (cloud.Cloud, string, error) {
+	if d.workspaces == nil {
+		if workspace != "" {
+			return nil, "", status.Errorf(codes.InvalidArgument, "Parameter %s requires the controller to manage several workspaces", WorkspaceKey)
+		}
+		return d.cloud, "", nil
+	}
+
+	if workspace == "" {
+		for _, t := range append(requirements.GetPreferred(), requirements.GetRequisite()...) {
+			if id := t.GetSegments()[WorkspaceTopologyKey]; d.workspaces.Has(id) {
+				workspace = id
+				break
+			}
+		}
+	}
+	if workspace == "" {
+		workspace = d.workspaces.DefaultID()
+	}
+
+	c, err := d.workspaces.Get(workspace)
+	if err != nil {
+		return nil, "", cloudError(err, "Could not select workspace: %v", err)
+	}
+	return c, workspace, nil
+}
+
+// topologyWorkspace returns the workspace reported in the topology of the volumes of the
+// workspace cloudInstanceID, which is empty for the workspace of d.cloud
+func (d *controllerService) topologyWorkspace(cloudInstanceID string) string {
+	if cloudInstanceID != "" {
+		return cloudInstanceID
+	}
+	return d.cloudInstanceID
+}
+
+// workspaceAccessible returns whether a volume of the workspace cloudInstanceID is accessible
+// from one of the requisite topologies, or of the preferred ones which should be requisite
+// too. Topologies without a workspace are accessible from every workspace.
+func workspaceAccessible(requirements *csi.TopologyRequirement, cloudInstanceID string) bool {
+	if len(requirements.GetRequisite()) == 0 || cloudInstanceID == "" {
+		return true
+	}
+	for _, t := range append(requirements.GetPreferred(), requirements.GetRequisite()...) {
+		if id, ok := t.GetSegments()[WorkspaceTopologyKey]; !ok || id == cloudInstanceID {
+			return true
 		}
-		return newCreateVolumeResponse(diskDetails), nil
 	}
+	return false
+}
+
+// topologyAccessible returns whether a volume with the topology segments is accessible from
+// one of the requisite or preferred topologies, topologies match the segments they don't
+// have. Empty segments match any topology.
+func topologyAccessible(requirements *csi.TopologyRequirement, segments map[string]string) bool {
+	if len(requirements.GetRequisite()) == 0 {
+		return true
+	}
+	for _, t := range append(requirements.GetPreferred(), requirements.GetRequisite()...) {
+		matches := true
+		for key, value := range segments {
+			if v, ok := t.GetSegments()[key]; ok && value != "" && v != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// topologyPool returns the storage pool of the first preferred, else requisite, topology with
+// one, like CreateVolume chooses the workspace
+func topologyPool(requirements *csi.TopologyRequirement) string {
+	for _, t := range append(requirements.GetPreferred(), requirements.GetRequisite()...) {
+		if pool := t.GetSegments()[StoragePoolTopologyKey]; pool != "" {
+			return pool
+		}
+	}
+	return ""
+}
+
+// hasPoolTopology returns whether the nodes of the topology requirements report a storage
+// pool, the volumes then only are accessible from the nodes of their pool
+func hasPoolTopology(requirements *csi.TopologyRequirement) bool {
+	for _, t := range append(requirements.GetPreferred(), requirements.GetRequisite()...) {
+		if _, ok := t.GetSegments()[StoragePoolTopologyKey]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// cloudForVolume returns the client of the workspace of a volume handle and the PowerVS
+// volume ID of the volume, the client of the workspace of secrets if they hold an API key
+func (d *controllerService) cloudForVolume(handle string, secrets map[string]string) (cloud.Cloud, string, error) {
+	if d.driverOptions.legacyVolumeHandles {
+		var err error
+		if handle, err = d.translateLegacyHandle(handle); err != nil {
+			return nil, "", err
+		}
+	}
+	c, cloudInstanceID, err := d.secretCloud(secrets)
+	if err != nil {
+		return nil, "", err
+	}
+	if c != nil {
+		return secretVolumeCloud(c, cloudInstanceID, handle)
+	}
+	return volumeCloud(d.cloud, d.workspaces, handle)
+}
 
-	disk, err := d.cloud.CreateDisk(volName, opts)
+func volumeCloud(c cloud.Cloud, workspaces *cloud.Workspaces, handle string) (cloud.Cloud, string, error) {
+	if workspaces == nil {
+		return c, handle, nil
+	}
+	wc, volumeID, err := workspaces.ForVolume(handle)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Could not create volume %q: %v", volName, err)
+		return nil, "", cloudError(err, "Could not get workspace of volume %q: %v", handle, err)
 	}
-	return newCreateVolumeResponse(disk), nil
+	return wc, volumeID, nil
 }
 
 func (d *controllerService) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
-	klog.V(4).Infof("DeleteVolume: called with args: %+v", *req)
+	klog.V(4).Infof("DeleteVolume: called with args %s", summarizeRequest(req))
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
-	if acquired := d.volumeLocks.TryAcquire(volumeID); !acquired {
-		return nil, status.Errorf(codes.Aborted, util.VolumeOperationAlreadyExistsFmt, volumeID)
+	if err := acquireVolumeLock(ctx, d.volumeLocks, volumeID, d.driverOptions); err != nil {
+		return nil, err
 	}
 	defer d.volumeLocks.Release(volumeID)
 
-	if _, err := d.cloud.GetDiskByID(volumeID); err != nil {
+	c, diskID, err := d.cloudForVolume(volumeID, req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.GetDiskByID(ctx, diskID); err != nil {
 		if err == cloud.ErrNotFound {
 			klog.V(4).Info("DeleteVolume: volume not found, returning with success")
 			return &csi.DeleteVolumeResponse{}, nil
 		}
 	}
 
-	if _, err := d.cloud.DeleteDisk(volumeID); err != nil {
-		return nil, status.Errorf(codes.Internal, "Could not delete volume ID %q: %v", volumeID, err)
+	if _, err := c.DeleteDisk(ctx, diskID); err != nil {
+		return nil, cloudError(err, "Could not delete volume ID %q: %v", volumeID, err)
 	}
 
 	return &csi.DeleteVolumeResponse{}, nil
 }
 
 func (d *controllerService) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (*csi.ControllerPublishVolumeResponse, error) {
-	klog.V(4).Infof("ControllerPublishVolume: called with args %+v", *req)
+	ctx, observe := observeVolumeOperation(ctx, volumeOperationAttach)
+	resp, err := d.publishVolume(ctx, req)
+	observe(err)
+	return resp, err
+}
+
+func (d *controllerService) publishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (*csi.ControllerPublishVolumeResponse, error) {
+	klog.V(4).Infof("ControllerPublishVolume: called with args %s", summarizeRequest(req))
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
-	if acquired := d.volumeLocks.TryAcquire(volumeID); !acquired {
-		return nil, status.Errorf(codes.Aborted, util.VolumeOperationAlreadyExistsFmt, volumeID)
+	if err := acquireVolumeLock(ctx, d.volumeLocks, volumeID, d.driverOptions); err != nil {
+		return nil, err
 	}
 	defer d.volumeLocks.Release(volumeID)
 
@@ -194,55 +614,140 @@ func (d *controllerService) ControllerPublishVolume(ctx context.Context, req *cs
 	}
 
 	caps := []*csi.VolumeCapability{volCap}
-	if !isValidVolumeCapabilities(caps) {
+	if !isValidVolumeCapabilities(d.driverOptions.accessModes(), caps) && !hasAccessMode(shareableVolumeCaps, volCap.GetAccessMode().GetMode()) {
 		modes := util.GetAccessModes(caps)
 		stringModes := strings.Join(*modes, ", ")
-		errString := "Volume capabilities " + stringModes + " not supported. Only AccessModes[ReadWriteOnce] supported."
+		errString := "Volume capabilities " + stringModes + " not supported. Only AccessModes[ReadWriteOnce] supported, and the multi node ones for shareable volumes."
 		return nil, status.Error(codes.InvalidArgument, errString)
 	}
 
-	if _, err := d.cloud.GetPVMInstanceByID(nodeID); err != nil {
-		return nil, status.Errorf(codes.NotFound, "Instance %q not found, err: %v", nodeID, err)
+	c, diskID, err := d.cloudForVolume(volumeID, req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyInstanceState(ctx, c, nodeID); err != nil {
+		return nil, err
 	}
 
-	disk, err := d.cloud.GetDiskByID(volumeID)
+	disk, err := c.GetDiskByID(ctx, diskID)
 
 	if err != nil {
 		if err == cloud.ErrNotFound {
 			return nil, status.Error(codes.NotFound, "Volume not found")
 		}
-		return nil, status.Errorf(codes.Internal, "Could not get volume with ID %q: %v", volumeID, err)
+		return nil, cloudError(err, "Could not get volume with ID %q: %v", volumeID, err)
+	}
+	if reason := diskMismatch(disk, req.GetVolumeContext(), nil); reason != "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "Volume %q is incompatible with its PV: %s", volumeID, reason)
+	}
+	if reason := d.capabilityMismatch(volumeID, disk, volCap); reason != "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "Volume %q can't be published with the capability: %s", volumeID, reason)
+	}
+	d.accessTypes.record(volumeID, volCap)
+	if disk.WWN == "" {
+		// the node finds the device of the volume by its WWN
+		return nil, status.Errorf(codes.FailedPrecondition, "Volume %q has no WWN", volumeID)
 	}
 
-	pvInfo := map[string]string{WWNKey: disk.WWN}
+	pvInfo := publishContext(disk)
 
-	attached, err := d.cloud.IsAttached(volumeID, nodeID)
+	attached, err := c.IsAttached(ctx, diskID, nodeID)
 	if attached {
 		klog.V(5).Infof("ControllerPublishVolume: volume %s already attached to node %s, returning success", volumeID, nodeID)
 		return &csi.ControllerPublishVolumeResponse{PublishContext: pvInfo}, nil
 	}
 
-	err = d.cloud.AttachDisk(volumeID, nodeID)
+	err = d.attachDisk(ctx, c, d.batchWorkspace(volumeID, req.GetSecrets()), diskID, disk, nodeID)
+	if _, ok := status.FromError(err); ok && err != nil {
+		if status.Code(err) == codes.ResourceExhausted {
+			d.events.volumeWarning(volumeID, EventAttachLimitExceeded, "Volume can't be attached to node %s: %s", nodeID, status.Convert(err).Message())
+		}
+		return nil, err
+	}
 	if err != nil {
-		if err == cloud.ErrAlreadyExists {
-			return nil, status.Error(codes.AlreadyExists, err.Error())
+		if errors.Is(err, cloud.ErrAlreadyExists) {
+			if err := verifyAttachment(ctx, c, diskID, volumeID, nodeID); err != nil {
+				return nil, err
+			}
+			klog.V(5).Infof("ControllerPublishVolume: volume %s already attached to node %s, returning success", volumeID, nodeID)
+			return &csi.ControllerPublishVolumeResponse{PublishContext: pvInfo}, nil
 		}
-		return nil, status.Errorf(codes.Internal, "Could not attach volume %q to node %q: %v", volumeID, nodeID, err)
+		if reason, message := cloudFailureEvent(err); reason != "" {
+			d.events.volumeWarning(volumeID, reason, "Attachment to node %s %s: %v", nodeID, message, err)
+		}
+		return nil, cloudError(err, "Could not attach volume %q to node %q: %v", volumeID, nodeID, err)
 	}
 	klog.V(5).Infof("ControllerPublishVolume: volume %s attached to node %s", volumeID, nodeID)
 
 	return &csi.ControllerPublishVolumeResponse{PublishContext: pvInfo}, nil
 }
 
+// verifyInstanceState returns an error if the pvm instance nodeID doesn't exist or is in a
+// state PowerVS attaches no volumes in
+func verifyInstanceState(ctx context.Context, c cloud.Cloud, nodeID string) error {
+	var state string
+	instance, err := c.GetPVMInstanceByID(ctx, nodeID)
+	if err == nil && instance != nil {
+		state = instance.Status
+	}
+	if !cloud.IsAttachableInstanceState(state) {
+		// the instance may have been started since it was cached
+		if invalidator, ok := c.(cloud.CacheInvalidator); ok {
+			invalidator.InvalidatePVMInstance(nodeID)
+			if instance, err = c.GetPVMInstanceByID(ctx, nodeID); err == nil && instance != nil {
+				state = instance.Status
+			}
+		}
+	}
+	if errors.Is(err, cloud.ErrNotFound) {
+		return status.Errorf(codes.NotFound, "Instance %q not found, err: %v", nodeID, err)
+	}
+	if err != nil {
+		// e.g. throttled or unavailable, the CO retries instead of treating the node as gone
+		return cloudError(err, "Could not get instance %q: %v", nodeID, err)
+	}
+	if !cloud.IsAttachableInstanceState(state) {
+		return status.Errorf(codes.FailedPrecondition, "Instance %q is in state %s, volumes can't be attached to it", nodeID, state)
+	}
+	return nil
+}
+
+// verifyAttachment returns nil if PowerVS reports the volume attached to nodeID, after
+// attaching it failed because it is already attached, the nodes it is attached to otherwise
+func verifyAttachment(ctx context.Context, c cloud.Cloud, diskID, volumeID, nodeID string) error {
+	disk, err := c.GetDiskByID(ctx, diskID)
+	if err != nil {
+		return cloudError(err, "Could not verify attachment of volume %q to node %q: %v", volumeID, nodeID, err)
+	}
+	for _, id := range disk.AttachedTo {
+		if id == nodeID {
+			return nil
+		}
+	}
+	if len(disk.AttachedTo) > 0 {
+		return status.Errorf(codes.FailedPrecondition, "Volume %q is attached to node %s, not to node %q", volumeID, strings.Join(disk.AttachedTo, ", "), nodeID)
+	}
+	// e.g. a detach in progress, the CO retries
+	return status.Errorf(codes.Aborted, "Volume %q is reported as attached but is not attached to any node", volumeID)
+}
+
 func (d *controllerService) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
-	klog.V(4).Infof("ControllerUnpublishVolume: called with args %+v", *req)
+	ctx, observe := observeVolumeOperation(ctx, volumeOperationDetach)
+	resp, err := d.unpublishVolume(ctx, req)
+	observe(err)
+	return resp, err
+}
+
+func (d *controllerService) unpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
+	klog.V(4).Infof("ControllerUnpublishVolume: called with args %s", summarizeRequest(req))
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
-	if acquired := d.volumeLocks.TryAcquire(volumeID); !acquired {
-		return nil, status.Errorf(codes.Aborted, util.VolumeOperationAlreadyExistsFmt, volumeID)
+	if err := acquireVolumeLock(ctx, d.volumeLocks, volumeID, d.driverOptions); err != nil {
+		return nil, err
 	}
 	defer d.volumeLocks.Release(volumeID)
 
@@ -251,30 +756,71 @@ func (d *controllerService) ControllerUnpublishVolume(ctx context.Context, req *
 		return nil, status.Error(codes.InvalidArgument, "Node ID not provided")
 	}
 
-	if _, err := d.cloud.GetDiskByID(volumeID); err != nil {
+	c, diskID, err := d.cloudForVolume(volumeID, req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.GetDiskByID(ctx, diskID); err != nil {
 		if err == cloud.ErrNotFound {
 			klog.V(4).Info("ControllerUnpublishVolume: volume not found, returning with success")
 			return &csi.ControllerUnpublishVolumeResponse{}, nil
 		}
 	}
 
-	if attached, err := d.cloud.IsAttached(volumeID, nodeID); !attached {
-		klog.V(4).Infof("ControllerUnpublishVolume: volume %s is not attached to %s, err: %v, returning with success", volumeID, nodeID, err)
+	attached, err := c.IsAttached(ctx, diskID, nodeID)
+	if err != nil {
+		// reporting success without knowing would let the CO attach the volume elsewhere
+		return nil, cloudError(err, "Could not check attachment of volume %q to node %q: %v", volumeID, nodeID, err)
+	}
+	if !attached {
+		klog.V(4).Infof("ControllerUnpublishVolume: volume %s is not attached to %s, returning with success", volumeID, nodeID)
 		return &csi.ControllerUnpublishVolumeResponse{}, nil
 	}
 
-	if err := d.cloud.DetachDisk(volumeID, nodeID); err != nil {
-		return nil, status.Errorf(codes.Internal, "Could not detach volume %q from node %q: %v", volumeID, nodeID, err)
+	// a stale request, like one of a previous leader of the attacher during a failover, must
+	// not detach a volume the CO still wants on the node
+	if name := d.volumeAttachments.referencingAttachment(volumeID, nodeID); name != "" {
+		d.events.volumeWarning(volumeID, EventDetachRefused, "Detachment from node %s refused, VolumeAttachment %s still references the volume", nodeID, name)
+		return nil, status.Errorf(codes.FailedPrecondition, "Volume %q is still referenced by VolumeAttachment %s of node %q", volumeID, name, nodeID)
+	}
+
+	// the detach from an out-of-service node would only fail after waiting for the node
+	if d.detachOutOfService(ctx, c, volumeID, diskID, nodeID) {
+		d.failingDetaches.forget(volumeID, nodeID)
+		d.accessTypes.forget(volumeID)
+		return &csi.ControllerUnpublishVolumeResponse{}, nil
+	}
+
+	if err := d.detachDisk(ctx, c, d.batchWorkspace(volumeID, req.GetSecrets()), diskID, nodeID); err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		if errors.Is(err, cloud.ErrNotFound) {
+			klog.V(4).Infof("ControllerUnpublishVolume: node %s or volume %s no longer exists, returning with success", nodeID, volumeID)
+			return &csi.ControllerUnpublishVolumeResponse{}, nil
+		}
+		if d.forceDetach(ctx, c, volumeID, diskID, nodeID) {
+			d.failingDetaches.forget(volumeID, nodeID)
+			d.accessTypes.forget(volumeID)
+			return &csi.ControllerUnpublishVolumeResponse{}, nil
+		}
+		if reason, message := cloudFailureEvent(err); reason != "" {
+			d.events.volumeWarning(volumeID, reason, "Detachment from node %s %s: %v", nodeID, message, err)
+		}
+		return nil, cloudError(err, "Could not detach volume %q from node %q: %v", volumeID, nodeID, err)
 	}
 	klog.V(5).Infof("ControllerUnpublishVolume: volume %s detached from node %s", volumeID, nodeID)
+	d.failingDetaches.forget(volumeID, nodeID)
+	d.accessTypes.forget(volumeID)
 
 	return &csi.ControllerUnpublishVolumeResponse{}, nil
 }
 
 func (d *controllerService) ControllerGetCapabilities(ctx context.Context, req *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
-	klog.V(4).Infof("ControllerGetCapabilities: called with args %+v", *req)
+	klog.V(4).Infof("ControllerGetCapabilities: called with args %+v", req)
 	var caps []*csi.ControllerServiceCapability
-	for _, cap := range controllerCaps {
+	for _, cap := range d.controllerCapabilities() {
 		c := &csi.ControllerServiceCapability{
 			Type: &csi.ControllerServiceCapability_Rpc{
 				Rpc: &csi.ControllerServiceCapability_RPC{
@@ -287,18 +833,142 @@ func (d *controllerService) ControllerGetCapabilities(ctx context.Context, req *
 	return &csi.ControllerGetCapabilitiesResponse{Capabilities: caps}, nil
 }
 
+// controllerCapabilities returns the controllerCaps of the enabled features
+func (d *controllerService) controllerCapabilities() []csi.ControllerServiceCapability_RPC_Type {
+	caps := make([]csi.ControllerServiceCapability_RPC_Type, 0, len(controllerCaps))
+	for _, cap := range controllerCaps {
+		if cap == csi.ControllerServiceCapability_RPC_EXPAND_VOLUME && !d.driverOptions.enabled(VolumeExpansion) {
+			continue
+		}
+		if cap == csi.ControllerServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER && !d.driverOptions.enabled(SingleNodeMultiWriter) {
+			continue
+		}
+		if cap == csi.ControllerServiceCapability_RPC_MODIFY_VOLUME && !d.driverOptions.enabled(VolumeAttributesClass) {
+			continue
+		}
+		caps = append(caps, cap)
+	}
+	return caps
+}
+
+// GetCapacity returns the storage available for the volumes of a StorageClass in a topology
+// segment. The volume type is the type parameter, else the disk type of the segment, and the
+// workspace the one CreateVolume would choose for the segment. With the storagePool parameter,
+// else the pool of the segment, it is the storage available in that pool.
 func (d *controllerService) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
-	klog.V(4).Infof("GetCapacity: called with args %+v", *req)
-	return nil, status.Error(codes.Unimplemented, "")
+	klog.V(4).Infof("GetCapacity: called with args %+v", req)
+
+	volumeParams, err := parseVolumeParameters(req.GetParameters())
+	if err != nil {
+		return nil, err
+	}
+	volumeType := volumeParams.VolumeType
+	segments := req.GetAccessibleTopology().GetSegments()
+	if volumeType == "" {
+		volumeType = segments[DiskTypeKey]
+	}
+	if volumeType == "" {
+		volumeType = cloud.DefaultVolumeType
+	}
+	if !params.IsValidVolumeType(volumeType) {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid volume type %q, valid values: %s", volumeType, strings.Join(cloud.ValidVolumeTypes, ", "))
+	}
+
+	var requirements *csi.TopologyRequirement
+	if topology := req.GetAccessibleTopology(); topology != nil {
+		requirements = &csi.TopologyRequirement{Preferred: []*csi.Topology{topology}}
+	}
+	c, _, err := d.selectWorkspace(volumeParams.Workspace, requirements)
+	if err != nil {
+		return nil, err
+	}
+
+	// the pools of a tier fill independently, creating a volume in a full pool fails whatever
+	// storage the other pools have left
+	pool := volumeParams.StoragePool
+	if pool == "" {
+		pool = segments[StoragePoolTopologyKey]
+	}
+	if pool != "" {
+		capacity, err := c.GetStoragePoolCapacity(ctx, pool)
+		if err != nil {
+			return nil, cloudError(err, "Could not get capacity of storage pool %s: %v", pool, err)
+		}
+		return &csi.GetCapacityResponse{
+			AvailableCapacity: util.GiBToBytes(capacity.AvailableGiB),
+			MaximumVolumeSize: wrapperspb.Int64(util.GiBToBytes(capacity.MaximumVolumeGiB)),
+		}, nil
+	}
+
+	capacity, err := c.GetStorageCapacity(ctx, volumeType)
+	if err != nil {
+		return nil, cloudError(err, "Could not get capacity of volume type %s: %v", volumeType, err)
+	}
+	return &csi.GetCapacityResponse{
+		AvailableCapacity: util.GiBToBytes(capacity.AvailableGiB),
+		MaximumVolumeSize: wrapperspb.Int64(util.GiBToBytes(capacity.MaximumVolumeGiB)),
+	}, nil
 }
 
+// ListVolumes returns the data volumes of all managed workspaces sorted by handle, the
+// starting token is the index of the first entry of the page
 func (d *controllerService) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
-	klog.V(4).Infof("ListVolumes: called with args %+v", *req)
-	return nil, status.Error(codes.Unimplemented, "")
+	klog.V(4).Infof("ListVolumes: called with args %+v", req)
+	if req.GetMaxEntries() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid max entries %d", req.GetMaxEntries())
+	}
+	start := 0
+	if token := req.GetStartingToken(); token != "" {
+		var err error
+		if start, err = strconv.Atoi(token); err != nil || start < 0 {
+			return nil, status.Errorf(codes.Aborted, "Invalid starting token %q", token)
+		}
+	}
+
+	clouds := map[string]cloud.Cloud{"": d.cloud}
+	if d.workspaces != nil {
+		clouds = map[string]cloud.Cloud{}
+		for _, id := range d.workspaces.IDs() {
+			c, err := d.workspaces.Get(id)
+			if err != nil {
+				return nil, cloudError(err, "Could not list volumes: %v", err)
+			}
+			clouds[id] = c
+		}
+	}
+	var entries []*csi.ListVolumesResponse_Entry
+	for cloudInstanceID, c := range clouds {
+		disks, err := c.ListDisks(ctx)
+		if err != nil {
+			return nil, cloudError(err, "Could not list volumes: %v", err)
+		}
+		for _, disk := range disks {
+			volume := d.newVolume(disk, cloudInstanceID)
+			entries = append(entries, &csi.ListVolumesResponse_Entry{
+				Volume: volume,
+				Status: &csi.ListVolumesResponse_VolumeStatus{
+					PublishedNodeIds: disk.AttachedTo,
+					VolumeCondition:  volumeCondition(disk),
+				},
+			})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Volume.VolumeId < entries[j].Volume.VolumeId })
+
+	if start > len(entries) {
+		start = len(entries)
+	}
+	entries = entries[start:]
+	var nextToken string
+	if max := int(req.GetMaxEntries()); max > 0 && max < len(entries) {
+		entries = entries[:max]
+		nextToken = strconv.Itoa(start + max)
+	}
+	return &csi.ListVolumesResponse{Entries: entries, NextToken: nextToken}, nil
 }
 
 func (d *controllerService) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
-	klog.V(4).Infof("ValidateVolumeCapabilities: called with args %+v", *req)
+	klog.V(4).Infof("ValidateVolumeCapabilities: called with args %s", summarizeRequest(req))
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
@@ -309,31 +979,48 @@ func (d *controllerService) ValidateVolumeCapabilities(ctx context.Context, req
 		return nil, status.Error(codes.InvalidArgument, "Volume capabilities not provided")
 	}
 
-	if _, err := d.cloud.GetDiskByID(volumeID); err != nil {
+	c, diskID, err := d.cloudForVolume(volumeID, req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+
+	disk, err := c.GetDiskByID(ctx, diskID)
+	if err != nil {
 		if err == cloud.ErrNotFound {
 			return nil, status.Error(codes.NotFound, "Volume not found")
 		}
-		return nil, status.Errorf(codes.Internal, "Could not get volume with ID %q: %v", volumeID, err)
+		return nil, cloudError(err, "Could not get volume with ID %q: %v", volumeID, err)
 	}
 
-	var confirmed *csi.ValidateVolumeCapabilitiesResponse_Confirmed
-	if isValidVolumeCapabilities(volCaps) {
-		confirmed = &csi.ValidateVolumeCapabilitiesResponse_Confirmed{VolumeCapabilities: volCaps}
+	for _, volCap := range volCaps {
+		if reason := d.capabilityMismatch(volumeID, disk, volCap); reason != "" {
+			return &csi.ValidateVolumeCapabilitiesResponse{Message: reason}, nil
+		}
+	}
+	if reason := diskMismatch(disk, req.GetVolumeContext(), req.GetParameters()); reason != "" {
+		return &csi.ValidateVolumeCapabilitiesResponse{Message: reason}, nil
 	}
 	return &csi.ValidateVolumeCapabilitiesResponse{
-		Confirmed: confirmed,
+		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
+			VolumeContext:      diskVolumeContext(disk, req.GetVolumeContext()),
+			VolumeCapabilities: volCaps,
+			Parameters:         req.GetParameters(),
+		},
 	}, nil
 }
 
 func (d *controllerService) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
-	klog.V(4).Infof("ControllerExpandVolume: called with args %+v", *req)
+	klog.V(4).Infof("ControllerExpandVolume: called with args %s", summarizeRequest(req))
+	if !d.driverOptions.enabled(VolumeExpansion) {
+		return nil, status.Errorf(codes.Unimplemented, "ControllerExpandVolume is disabled by the %s feature gate", VolumeExpansion)
+	}
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
-	if acquired := d.volumeLocks.TryAcquire(volumeID); !acquired {
-		return nil, status.Errorf(codes.Aborted, util.VolumeOperationAlreadyExistsFmt, volumeID)
+	if err := acquireVolumeLock(ctx, d.volumeLocks, volumeID, d.driverOptions); err != nil {
+		return nil, err
 	}
 	defer d.volumeLocks.Release(volumeID)
 
@@ -342,31 +1029,167 @@ func (d *controllerService) ControllerExpandVolume(ctx context.Context, req *csi
 		return nil, status.Error(codes.InvalidArgument, "Capacity range not provided")
 	}
 
-	newSize := util.RoundUpBytes(capRange.GetRequiredBytes())
-	maxVolSize := capRange.GetLimitBytes()
-	if maxVolSize > 0 && maxVolSize < newSize {
-		return nil, status.Error(codes.InvalidArgument, "After round-up, volume size exceeds the limit specified")
+	newSize, err := volumeSizeBytes(capRange, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	c, diskID, err := d.cloudForVolume(volumeID, req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+
+	disk, err := c.GetDiskByID(ctx, diskID)
+	if err != nil {
+		if errors.Is(err, cloud.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "Volume %q not found", volumeID)
+		}
+		return nil, cloudError(err, "Could not get volume with ID %q: %v", volumeID, err)
+	}
+	if err := checkExpansion(ctx, c, disk, volumeID, newSize); err != nil {
+		return nil, err
 	}
 
-	actualSizeGiB, err := d.cloud.ResizeDisk(volumeID, newSize)
+	actualSizeGiB, err := c.ResizeDisk(ctx, diskID, newSize)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Could not resize volume %q: %v", volumeID, err)
+		return nil, cloudError(err, "Could not resize volume %q: %v", volumeID, err)
 	}
 
 	return &csi.ControllerExpandVolumeResponse{
 		CapacityBytes:         util.GiBToBytes(actualSizeGiB),
-		NodeExpansionRequired: true,
+		NodeExpansionRequired: d.nodeExpansionRequired(ctx, c, diskID, volumeID, req.GetVolumeCapability()),
 	}, nil
 }
 
+// checkExpansion returns the errors PowerVS would fail the resize of disk to size with, some
+// of them only after the volume state timeout
+func checkExpansion(ctx context.Context, c cloud.Cloud, disk *cloud.Disk, volumeID string, size int64) error {
+	sizeGiB := util.BytesToGiB(size)
+	if sizeGiB <= disk.CapacityGiB {
+		// PowerVS can't shrink volumes, ResizeDisk returns the current size
+		return nil
+	}
+	switch disk.State {
+	case cloud.VolumeAvailableState, cloud.VolumeInUseState:
+	case cloud.VolumeErrorState:
+		return status.Errorf(codes.FailedPrecondition, "Volume %q is in state %s and can't be expanded", volumeID, disk.State)
+	default:
+		// the operation in progress completes eventually, the CO retries
+		return status.Errorf(codes.Aborted, "Volume %q is %s, it can be expanded once it's %s or %s", volumeID, disk.State, cloud.VolumeAvailableState, cloud.VolumeInUseState)
+	}
+	for _, instanceID := range disk.AttachedTo {
+		// lookup errors are left to PowerVS
+		if instance, err := c.GetPVMInstanceByID(ctx, instanceID); err == nil && instance != nil && strings.EqualFold(instance.Status, cloud.InstanceErrorState) {
+			return status.Errorf(codes.FailedPrecondition, "Volume %q is attached to instance %q in state %s and can't be expanded", volumeID, instanceID, instance.Status)
+		}
+	}
+
+	if disk.DiskType == "" {
+		return nil
+	}
+	// the volume grows in its storage pool, bounded by the largest allocation of the tier
+	capacity, err := c.GetStorageCapacity(ctx, disk.DiskType)
+	if err != nil {
+		klog.V(4).Infof("ControllerExpandVolume: could not get capacity of volume type %s, leaving the size check to PowerVS: %v", disk.DiskType, err)
+		return nil
+	}
+	if growthGiB := sizeGiB - disk.CapacityGiB; capacity.MaximumVolumeGiB > 0 && growthGiB > capacity.MaximumVolumeGiB {
+		return status.Errorf(codes.OutOfRange, "Volume %q can't grow by %d GiB from %d to %d GiB, at most %d GiB can be allocated in the storage pools of tier %s", volumeID, growthGiB, disk.CapacityGiB, sizeGiB, capacity.MaximumVolumeGiB, disk.DiskType)
+	}
+	return nil
+}
+
+// nodeExpansionRequired returns true if the filesystem of a resized volume needs to be grown
+// on the nodes it's attached to. Raw block volumes have no filesystem, only the devices of
+// shareable ones, attached to several nodes, are rescanned by NodeExpandVolume. NodeStageVolume
+// grows the filesystem of volumes that are staged after the resize. The access type is the
+// one recorded at publish time when the request has no volume capability.
+func (d *controllerService) nodeExpansionRequired(ctx context.Context, c cloud.Cloud, diskID, volumeID string, volCap *csi.VolumeCapability) bool {
+	block := volCap.GetBlock() != nil
+	if volCap == nil {
+		block, _ = d.accessTypes.isBlock(volumeID)
+	}
+	// the attachment is checked after the resize, a volume attached since then sees the new size
+	disk, err := c.GetDiskByID(ctx, diskID)
+	if err != nil || disk == nil {
+		if block {
+			return false
+		}
+		klog.V(4).Infof("ControllerExpandVolume: could not check attachment of volume %s, requiring node expansion: %v", volumeID, err)
+		return true
+	}
+	if len(disk.AttachedTo) == 0 {
+		return false
+	}
+	// the devices of a shareable raw block volume are rescanned on every node it's published
+	// to, so that all consumers see the new size
+	if block && !disk.Shareable {
+		return false
+	}
+	if len(disk.AttachedTo) > 1 {
+		klog.Infof("ControllerExpandVolume: volume %s is attached to nodes %v, each of them expands it", volumeID, disk.AttachedTo)
+	}
+	return true
+}
+
+// ControllerGetVolume returns the nodes a volume is attached to according to PowerVS and its
+// condition, the external-health-monitor-controller reports abnormal volumes on their PVCs
 func (d *controllerService) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (*csi.ControllerGetVolumeResponse, error) {
-	klog.V(4).Infof("ControllerGetVolume: called with args %+v", *req)
-	return nil, status.Error(codes.Unimplemented, "")
+	klog.V(4).Infof("ControllerGetVolume: called with args %+v", req)
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+
+	c, diskID, err := d.cloudForVolume(volumeID, nil)
+	if err != nil {
+		return nil, err
+	}
+	disk, err := c.GetDiskByID(ctx, diskID)
+	if err != nil {
+		if errors.Is(err, cloud.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "Volume %q not found", volumeID)
+		}
+		return nil, cloudError(err, "Could not get volume with ID %q: %v", volumeID, err)
+	}
+
+	if disk.State == cloud.VolumeErrorState {
+		d.events.volumeWarning(volumeID, EventVolumeFailed, "PowerVS volume %s is in state %s", diskID, disk.State)
+	}
+
+	var cloudInstanceID string
+	if d.workspaces != nil {
+		cloudInstanceID, _ = d.workspaces.ParseVolumeHandle(volumeID)
+	}
+	return &csi.ControllerGetVolumeResponse{
+		Volume: d.newVolume(disk, cloudInstanceID),
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+			PublishedNodeIds: disk.AttachedTo,
+			VolumeCondition:  volumeCondition(disk),
+		},
+	}, nil
+}
+
+// volumeCondition returns the condition of a volume from its PowerVS state
+func volumeCondition(disk *cloud.Disk) *csi.VolumeCondition {
+	if disk.State == cloud.VolumeErrorState {
+		return &csi.VolumeCondition{Abnormal: true, Message: "PowerVS reports the volume in state " + disk.State}
+	}
+	return &csi.VolumeCondition{Message: "Volume is " + disk.State}
 }
 
-func isValidVolumeCapabilities(volCaps []*csi.VolumeCapability) bool {
+// accessModes returns the single node access modes of volumes, those of volumeCaps and, with
+// SingleNodeMultiWriter, of singleNodeVolumeCaps
+func (o *Options) accessModes() []*csi.VolumeCapability_AccessMode {
+	if !o.enabled(SingleNodeMultiWriter) {
+		return volumeCaps
+	}
+	return append(append([]*csi.VolumeCapability_AccessMode{}, volumeCaps...), singleNodeVolumeCaps...)
+}
+
+func isValidVolumeCapabilities(modes []*csi.VolumeCapability_AccessMode, volCaps []*csi.VolumeCapability) bool {
 	hasSupport := func(cap *csi.VolumeCapability) bool {
-		for _, c := range volumeCaps {
+		for _, c := range modes {
 			if c.GetMode() == cap.AccessMode.GetMode() {
 				return true
 			}
@@ -383,6 +1206,62 @@ func isValidVolumeCapabilities(volCaps []*csi.VolumeCapability) bool {
 	return foundAll
 }
 
+func hasAccessMode(caps []*csi.VolumeCapability_AccessMode, mode csi.VolumeCapability_AccessMode_Mode) bool {
+	for _, c := range caps {
+		if c.GetMode() == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// capabilityMismatch returns why disk can't be used with volCap, "" if it can. The multi node
+// access modes require a shareable disk and, but for reading, a block volume as filesystems
+// can't be written by several nodes. A published volume keeps its access type.
+func (d *controllerService) capabilityMismatch(volumeID string, disk *cloud.Disk, volCap *csi.VolumeCapability) string {
+	mode := volCap.GetAccessMode().GetMode()
+	switch {
+	case hasAccessMode(d.driverOptions.accessModes(), mode):
+	case hasAccessMode(shareableVolumeCaps, mode):
+		if !disk.Shareable {
+			return fmt.Sprintf("access mode %s requires a shareable volume, volume %s isn't shareable", mode, disk.VolumeID)
+		}
+		if mode != csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY && volCap.GetMount() != nil {
+			return fmt.Sprintf("access mode %s requires a block volume, filesystems can't be written by several nodes", mode)
+		}
+	default:
+		return fmt.Sprintf("access mode %s isn't supported", mode)
+	}
+
+	switch access := volCap.GetAccessType().(type) {
+	case *csi.VolumeCapability_Block:
+	case *csi.VolumeCapability_Mount:
+		if fsType := access.Mount.GetFsType(); fsType != "" && !isSupportedFSType(fsType) {
+			return fmt.Sprintf("filesystem %s isn't supported", fsType)
+		}
+	default:
+		return "the volume capability has no access type"
+	}
+
+	if block, known := d.accessTypes.isBlock(volumeID); known && block != (volCap.GetBlock() != nil) {
+		accessType := "mount"
+		if block {
+			accessType = "block"
+		}
+		return fmt.Sprintf("volume %s is published with access type %s", volumeID, accessType)
+	}
+	return ""
+}
+
+// isSupportedFSType returns true if the node formats and mounts volumes with filesystem fsType
+func isSupportedFSType(fsType string) bool {
+	switch fsType {
+	case FSTypeExt2, FSTypeExt3, FSTypeExt4, FSTypeXfs:
+		return true
+	}
+	return false
+}
+
 func (d *controllerService) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
 	klog.V(4).Infof("CreateSnapshot: called with args %+v", req)
 	return nil, status.Error(codes.Unimplemented, "")
@@ -398,44 +1277,117 @@ func (d *controllerService) ListSnapshots(ctx context.Context, req *csi.ListSnap
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
-func newCreateVolumeResponse(disk *cloud.Disk) *csi.CreateVolumeResponse {
+// publishContext returns the PublishContext of a volume, the PowerVS API reports no LUN of
+// the attachments, the node finds the device by its WWN
+func publishContext(disk *cloud.Disk) map[string]string {
+	pvInfo := map[string]string{
+		WWNKey:       disk.WWN,
+		ShareableKey: strconv.FormatBool(disk.Shareable),
+	}
+	if disk.DiskType != "" {
+		pvInfo[TierKey] = disk.DiskType
+	}
+	return pvInfo
+}
+
+func (d *controllerService) newCreateVolumeResponse(disk *cloud.Disk, cloudInstanceID string, volumeParams *params.Parameters, requirements *csi.TopologyRequirement) *csi.CreateVolumeResponse {
+	volume := d.newVolume(disk, cloudInstanceID)
+	for k, v := range volumeParams.VolumeContext {
+		volume.VolumeContext[k] = v
+	}
+	// nodes without a pool in their topology can't match the pool of a volume, it is only
+	// reported when the nodes report theirs
+	if d.driverOptions.enabled(StoragePoolTopology) && disk.StoragePool != "" && hasPoolTopology(requirements) {
+		if len(volume.AccessibleTopology) == 0 {
+			volume.AccessibleTopology = []*csi.Topology{{Segments: map[string]string{}}}
+		}
+		for _, t := range volume.AccessibleTopology {
+			t.Segments[StoragePoolTopologyKey] = disk.StoragePool
+		}
+	}
+	return &csi.CreateVolumeResponse{Volume: volume}
+}
+
+// newVolume returns the CSI volume of a disk in the workspace cloudInstanceID
+func (d *controllerService) newVolume(disk *cloud.Disk, cloudInstanceID string) *csi.Volume {
 	var src *csi.VolumeContentSource
 
-	return &csi.CreateVolumeResponse{
-		Volume: &csi.Volume{
-			VolumeId:      disk.VolumeID,
-			CapacityBytes: util.GiBToBytes(disk.CapacityGiB),
-			VolumeContext: map[string]string{},
-			ContentSource: src,
-		},
+	volumeID := disk.VolumeID
+	if cloudInstanceID != "" {
+		// the handle only has the workspace with several workspaces or a provisioner secret
+		volumeID = cloud.JoinVolumeHandle(cloudInstanceID, disk.VolumeID)
+		if d.workspaces != nil {
+			volumeID = d.workspaces.VolumeHandle(cloudInstanceID, disk.VolumeID)
+		}
+	}
+	// pods of the volume must not be scheduled to nodes of other workspaces, which can't attach it
+	var topology []*csi.Topology
+	if workspace := d.topologyWorkspace(cloudInstanceID); workspace != "" {
+		topology = []*csi.Topology{{Segments: map[string]string{WorkspaceTopologyKey: workspace}}}
+	}
+
+	volumeContext := map[string]string{}
+	if iops := cloud.ProvisionedIOPS(disk.DiskType, disk.CapacityGiB); iops > 0 {
+		volumeContext[IOPSKey] = strconv.FormatInt(iops, 10)
+	}
+
+	return &csi.Volume{
+		VolumeId:           volumeID,
+		CapacityBytes:      util.GiBToBytes(disk.CapacityGiB),
+		VolumeContext:      volumeContext,
+		ContentSource:      src,
+		AccessibleTopology: topology,
 	}
 }
 
-func getVolSizeBytes(req *csi.CreateVolumeRequest) (int64, error) {
-	var volSizeBytes int64
-	capRange := req.GetCapacityRange()
-	if capRange == nil {
-		volSizeBytes = cloud.DefaultVolumeSize
-	} else {
-		volSizeBytes = util.RoundUpBytes(capRange.GetRequiredBytes())
-		maxVolSize := capRange.GetLimitBytes()
-		if maxVolSize > 0 && maxVolSize < volSizeBytes {
-			return 0, status.Error(codes.InvalidArgument, "After round-up, volume size exceeds the limit specified")
+// volumeSizeBytes returns the size in whole GiB of a volume satisfying capRange and the
+// PowerVS size limits. Without a required size the volume gets defaultSize, capped by the
+// limit of capRange, a defaultSize of 0 makes the required size mandatory.
+func volumeSizeBytes(capRange *csi.CapacityRange, defaultSize int64) (int64, error) {
+	required, limit := capRange.GetRequiredBytes(), capRange.GetLimitBytes()
+	if required < 0 || limit < 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "Capacity range required %d, limit %d must not be negative", required, limit)
+	}
+
+	maxSize := cloud.MaxVolumeSize
+	if limit > 0 && limit < maxSize {
+		// the size is a whole number of GiB not exceeding the limit
+		maxSize = util.BytesToGiB(limit) * util.GiB
+	}
+	if maxSize < cloud.MinVolumeSize {
+		return 0, status.Errorf(codes.OutOfRange, "Capacity limit %d is below the minimum volume size of %d bytes", limit, cloud.MinVolumeSize)
+	}
+
+	size := util.RoundUpBytes(required)
+	if required == 0 {
+		if defaultSize == 0 {
+			return 0, status.Error(codes.InvalidArgument, "Required capacity not provided")
+		}
+		size = defaultSize
+		if size > maxSize {
+			size = maxSize
 		}
 	}
-	return volSizeBytes, nil
+	if size < cloud.MinVolumeSize {
+		size = cloud.MinVolumeSize
+	}
+	if size > maxSize {
+		return 0, status.Errorf(codes.OutOfRange, "Volume size %d rounded up to %d GiB is outside of the allowed range of %d to %d GiB", required, util.BytesToGiB(size), util.BytesToGiB(cloud.MinVolumeSize), util.BytesToGiB(maxSize))
+	}
+	return size, nil
 }
 
 func verifyVolumeDetails(payload *cloud.DiskOptions, diskDetails *cloud.Disk) error {
 	if payload.Shareable != diskDetails.Shareable {
-		return status.Errorf(codes.Internal, "shareable in payload and shareable in disk details don't match")
+		return status.Errorf(codes.AlreadyExists, "shareable in payload and shareable in disk details don't match")
 	}
-	if payload.VolumeType != diskDetails.DiskType {
-		return status.Errorf(codes.Internal, "TYPE in payload and disktype in disk details don't match")
+	// without a type PowerVS creates the volume with the default type of the workspace
+	if payload.VolumeType != "" && payload.VolumeType != diskDetails.DiskType {
+		return status.Errorf(codes.AlreadyExists, "TYPE in payload and disktype in disk details don't match")
 	}
 	capacityGIB := util.BytesToGiB(payload.CapacityBytes)
 	if capacityGIB != diskDetails.CapacityGiB {
-		return status.Errorf(codes.Internal, "capacityBytes in payload and capacityGIB in disk details don't match")
+		return status.Errorf(codes.AlreadyExists, "capacityBytes in payload and capacityGIB in disk details don't match")
 	}
 	return nil
 }
@@ -0,0 +1,53 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func FuzzVolumeSizeBytes(f *testing.F) {
+	f.Add(int64(util.GiB), int64(0), false)
+	f.Add(int64(1), int64(util.GiB), true)
+	f.Add(int64(0), int64(0), true)
+	f.Add(int64(-1), int64(-1), false)
+	f.Add(cloud.MaxVolumeSize, cloud.MaxVolumeSize-1, false)
+	f.Add(int64(1<<63-1), int64(0), false)
+	f.Fuzz(func(t *testing.T, required, limit int64, withDefault bool) {
+		var defaultSize int64
+		if withDefault {
+			defaultSize = 10 * util.GiB
+		}
+		size, err := volumeSizeBytes(&csi.CapacityRange{RequiredBytes: required, LimitBytes: limit}, defaultSize)
+		if err != nil {
+			return
+		}
+		if size < cloud.MinVolumeSize || size > cloud.MaxVolumeSize || size%util.GiB != 0 {
+			t.Fatalf("size %d of required %d, limit %d is not a valid volume size", size, required, limit)
+		}
+		if size < required {
+			t.Fatalf("size %d is smaller than the required %d bytes", size, required)
+		}
+		if limit > 0 && size > limit {
+			t.Fatalf("size %d exceeds the limit of %d bytes", size, limit)
+		}
+	})
+}
@@ -15,15 +15,20 @@ package driver
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	csi "github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/golang/mock/gomock"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
 	mocks "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/mocks"
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
 )
@@ -74,8 +79,8 @@ func TestCreateVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().GetDiskByName(gomock.Eq(req.Name)).Return(nil, nil)
-				mockCloud.EXPECT().CreateDisk(gomock.Eq(req.Name), gomock.Any()).Return(mockDisk, nil)
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Eq(req.Name)).Return(nil, nil)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Eq(req.Name), gomock.Any()).Return(mockDisk, nil)
 
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
@@ -162,8 +167,8 @@ func TestCreateVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().GetDiskByName(gomock.Eq(req.Name)).Return(nil, nil)
-				mockCloud.EXPECT().CreateDisk(gomock.Eq(req.Name), gomock.Any()).Return(mockDisk, nil)
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Eq(req.Name)).Return(nil, nil)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Eq(req.Name), gomock.Any()).Return(mockDisk, nil)
 
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
@@ -180,8 +185,8 @@ func TestCreateVolume(t *testing.T) {
 				}
 
 				// Subsequent call returns the created disk
-				mockCloud.EXPECT().GetDiskByName(gomock.Eq(req.Name)).Return(mockDisk, nil)
-				mockCloud.EXPECT().WaitForVolumeState(gomock.Any(), gomock.Any()).Return(nil)
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Eq(req.Name)).Return(mockDisk, nil)
+				mockCloud.EXPECT().WaitForVolumeState(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
 				resp, err := powervsDriver.CreateVolume(ctx, extraReq)
 				if err != nil {
 					srvErr, ok := status.FromError(err)
@@ -244,8 +249,8 @@ func TestCreateVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().GetDiskByName(gomock.Eq(req.Name)).Return(nil, nil)
-				mockCloud.EXPECT().CreateDisk(gomock.Eq(req.Name), gomock.Any()).Return(mockDisk, nil)
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Eq(req.Name)).Return(nil, nil)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Eq(req.Name), gomock.Any()).Return(mockDisk, nil)
 
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
@@ -305,8 +310,8 @@ func TestCreateVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().GetDiskByName(gomock.Eq(req.Name)).Return(nil, nil)
-				mockCloud.EXPECT().CreateDisk(gomock.Eq(req.Name), gomock.Any()).Return(mockDisk, nil)
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Eq(req.Name)).Return(nil, nil)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Eq(req.Name), gomock.Any()).Return(mockDisk, nil)
 
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
@@ -359,8 +364,8 @@ func TestCreateVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().GetDiskByName(gomock.Eq(req.Name)).Return(nil, nil)
-				mockCloud.EXPECT().CreateDisk(gomock.Eq(req.Name), gomock.Any()).Return(mockDisk, nil)
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Eq(req.Name)).Return(nil, nil)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Eq(req.Name), gomock.Any()).Return(mockDisk, nil)
 
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
@@ -403,8 +408,8 @@ func TestCreateVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().GetDiskByName(gomock.Eq(req.Name)).Return(nil, nil)
-				mockCloud.EXPECT().CreateDisk(gomock.Eq(req.Name), gomock.Any()).Return(mockDisk, nil)
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Eq(req.Name)).Return(nil, nil)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Eq(req.Name), gomock.Any()).Return(mockDisk, nil)
 
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
@@ -421,6 +426,129 @@ func TestCreateVolume(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "success with fixed IOPS volume type tier5k",
+			testFunc: func(t *testing.T) {
+				req := &csi.CreateVolumeRequest{
+					Name:               "vol-test",
+					CapacityRange:      stdCapRange,
+					VolumeCapabilities: stdVolCap,
+					Parameters: map[string]string{
+						VolumeTypeKey: cloud.VolumeTypeTier5k,
+					},
+				}
+
+				mockCtl := gomock.NewController(t)
+				defer mockCtl.Finish()
+
+				mockCloud := mocks.NewMockCloud(mockCtl)
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Eq(req.Name)).Return(nil, nil)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Eq(req.Name), gomock.Any()).DoAndReturn(func(ctx context.Context, name string, opts *cloud.DiskOptions) (*cloud.Disk, error) {
+					if opts.VolumeType != cloud.VolumeTypeTier5k {
+						t.Fatalf("expected volume type %s, got %s", cloud.VolumeTypeTier5k, opts.VolumeType)
+					}
+					return &cloud.Disk{VolumeID: req.Name, CapacityGiB: util.BytesToGiB(stdVolSize)}, nil
+				})
+
+				powervsDriver := controllerService{
+					cloud:         mockCloud,
+					driverOptions: &Options{},
+					volumeLocks:   util.NewVolumeLocks(),
+				}
+
+				if _, err := powervsDriver.CreateVolume(context.Background(), req); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			},
+		},
+		{
+			name: "fail with unknown volume type",
+			testFunc: func(t *testing.T) {
+				req := &csi.CreateVolumeRequest{
+					Name:               "vol-test",
+					CapacityRange:      stdCapRange,
+					VolumeCapabilities: stdVolCap,
+					Parameters: map[string]string{
+						VolumeTypeKey: "tier2",
+					},
+				}
+
+				mockCtl := gomock.NewController(t)
+				defer mockCtl.Finish()
+
+				powervsDriver := controllerService{
+					cloud:         mocks.NewMockCloud(mockCtl),
+					driverOptions: &Options{},
+					volumeLocks:   util.NewVolumeLocks(),
+				}
+
+				_, err := powervsDriver.CreateVolume(context.Background(), req)
+				if status.Code(err) != codes.InvalidArgument {
+					t.Fatalf("Expected InvalidArgument, got: %v", err)
+				}
+				if !strings.Contains(err.Error(), "tier0, tier1, tier3, tier5k") {
+					t.Fatalf("Expected the valid volume types in the error, got: %v", err)
+				}
+			},
+		},
+		{
+			name: "success with tags",
+			testFunc: func(t *testing.T) {
+				req := &csi.CreateVolumeRequest{
+					Name:               "vol-test",
+					CapacityRange:      stdCapRange,
+					VolumeCapabilities: stdVolCap,
+					Parameters: map[string]string{
+						TagKeyPrefix + "_1": "team=storage",
+						TagKeyPrefix + "_2": "env=test",
+						PVCNameKey:          "data",
+						PVCNamespaceKey:     "default",
+						PVNameKey:           "pvc-1",
+					},
+				}
+
+				ctx := context.Background()
+
+				mockDisk := &cloud.Disk{
+					VolumeID:    req.Name,
+					CapacityGiB: util.BytesToGiB(stdVolSize),
+				}
+				expectedTags := []string{
+					"kubernetes-cluster-id:cluster-1",
+					"kubernetes-pv-name:pvc-1",
+					"kubernetes-pvc-name:data",
+					"kubernetes-pvc-namespace:default",
+					"env:test",
+					"team:storage",
+					"owner:platform",
+				}
+
+				mockCtl := gomock.NewController(t)
+				defer mockCtl.Finish()
+
+				mockCloud := mocks.NewMockCloud(mockCtl)
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Eq(req.Name)).Return(nil, nil)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Eq(req.Name), gomock.Any()).DoAndReturn(func(ctx context.Context, name string, opts *cloud.DiskOptions) (*cloud.Disk, error) {
+					if !reflect.DeepEqual(opts.Tags, expectedTags) {
+						t.Fatalf("expected tags %v, got %v", expectedTags, opts.Tags)
+					}
+					return mockDisk, nil
+				})
+
+				powervsDriver := controllerService{
+					cloud: mockCloud,
+					driverOptions: &Options{
+						kubernetesClusterID: "cluster-1",
+						extraTags:           map[string]string{"team": "platform", "owner": "platform"},
+					},
+					volumeLocks: util.NewVolumeLocks(),
+				}
+
+				if _, err := powervsDriver.CreateVolume(ctx, req); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			},
+		},
 		{
 			name: "fail with invalid volume parameter",
 			testFunc: func(t *testing.T) {
@@ -486,8 +614,8 @@ func TestDeleteVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().DeleteDisk(gomock.Eq(req.VolumeId)).Return(true, nil)
-				mockCloud.EXPECT().GetDiskByID(gomock.Eq(req.VolumeId)).Return(nil, nil)
+				mockCloud.EXPECT().DeleteDisk(gomock.Any(), gomock.Eq(req.VolumeId)).Return(true, nil)
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(req.VolumeId)).Return(nil, nil)
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
 					driverOptions: &Options{},
@@ -519,7 +647,7 @@ func TestDeleteVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().GetDiskByID(gomock.Eq(req.VolumeId)).Return(nil, cloud.ErrNotFound)
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(req.VolumeId)).Return(nil, cloud.ErrNotFound)
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
 					driverOptions: &Options{},
@@ -550,8 +678,8 @@ func TestDeleteVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().DeleteDisk(gomock.Eq(req.VolumeId)).Return(false, fmt.Errorf("DeleteDisk could not delete volume"))
-				mockCloud.EXPECT().GetDiskByID(gomock.Eq(req.VolumeId)).Return(nil, nil)
+				mockCloud.EXPECT().DeleteDisk(gomock.Any(), gomock.Eq(req.VolumeId)).Return(false, fmt.Errorf("DeleteDisk could not delete volume"))
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(req.VolumeId)).Return(nil, nil)
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
 					driverOptions: &Options{},
@@ -608,7 +736,7 @@ func TestControllerPublishVolume(t *testing.T) {
 					VolumeId:         volumeName,
 				}
 				expResp := &csi.ControllerPublishVolumeResponse{
-					PublishContext: map[string]string{WWNKey: expDevicePath},
+					PublishContext: map[string]string{WWNKey: expDevicePath, ShareableKey: "false", TierKey: cloud.VolumeTypeTier3},
 				}
 
 				ctx := context.Background()
@@ -617,15 +745,17 @@ func TestControllerPublishVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().GetPVMInstanceByID(gomock.Eq(expInstanceID)).Return(nil, nil)
-				mockCloud.EXPECT().GetDiskByID(gomock.Eq(volumeName)).Return(&cloud.Disk{WWN: expDevicePath}, nil)
-				mockCloud.EXPECT().IsAttached(gomock.Eq(volumeName), gomock.Eq(expInstanceID)).Return(false, nil)
-				mockCloud.EXPECT().AttachDisk(gomock.Eq(volumeName), gomock.Eq(expInstanceID)).Return(nil)
+				mockCloud.EXPECT().GetPVMInstanceByID(gomock.Any(), gomock.Eq(expInstanceID)).Return(nil, nil)
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeName)).Return(&cloud.Disk{WWN: expDevicePath, DiskType: cloud.VolumeTypeTier3}, nil)
+				mockCloud.EXPECT().IsAttached(gomock.Any(), gomock.Eq(volumeName), gomock.Eq(expInstanceID)).Return(false, nil)
+				mockCloud.EXPECT().ListDisks(gomock.Any()).Return(nil, nil)
+				mockCloud.EXPECT().AttachDisk(gomock.Any(), gomock.Eq(volumeName), gomock.Eq(expInstanceID)).Return(nil)
 
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
 					driverOptions: &Options{},
 					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
 				}
 
 				resp, err := powervsDriver.ControllerPublishVolume(ctx, req)
@@ -639,6 +769,55 @@ func TestControllerPublishVolume(t *testing.T) {
 			},
 		},
 
+		{
+			name: "fail static volume incompatible with its PV",
+			testFunc: func(t *testing.T) {
+				for name, tc := range map[string]struct {
+					volumeContext map[string]string
+					volCap        *csi.VolumeCapability
+					disk          *cloud.Disk
+				}{
+					"shareable":  {volumeContext: map[string]string{ShareableKey: "true"}, disk: &cloud.Disk{WWN: expDevicePath}},
+					"tier":       {volumeContext: map[string]string{TierKey: cloud.VolumeTypeTier0}, disk: &cloud.Disk{WWN: expDevicePath, DiskType: cloud.VolumeTypeTier3}},
+					"no WWN yet": {disk: &cloud.Disk{DiskType: cloud.VolumeTypeTier3}},
+					"multi node access mode": {
+						volCap: &csi.VolumeCapability{
+							AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+							AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+						},
+						disk: &cloud.Disk{WWN: expDevicePath},
+					},
+				} {
+					t.Run(name, func(t *testing.T) {
+						mockCtl := gomock.NewController(t)
+						defer mockCtl.Finish()
+
+						mockCloud := mocks.NewMockCloud(mockCtl)
+						mockCloud.EXPECT().GetPVMInstanceByID(gomock.Any(), gomock.Eq(expInstanceID)).Return(nil, nil)
+						mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeName)).Return(tc.disk, nil)
+
+						powervsDriver := controllerService{
+							cloud:         mockCloud,
+							driverOptions: &Options{},
+							volumeLocks:   util.NewVolumeLocks(),
+							nodeQueues:    newNodeQueues(),
+						}
+						volCap := stdVolCap
+						if tc.volCap != nil {
+							volCap = tc.volCap
+						}
+						_, err := powervsDriver.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{
+							NodeId:           expInstanceID,
+							VolumeCapability: volCap,
+							VolumeId:         volumeName,
+							VolumeContext:    tc.volumeContext,
+						})
+						checkExpectedErrorCode(t, err, codes.FailedPrecondition)
+					})
+				}
+			},
+		},
+
 		{
 			name: "fail no VolumeId",
 			testFunc: func(t *testing.T) {
@@ -655,6 +834,7 @@ func TestControllerPublishVolume(t *testing.T) {
 					cloud:         mockCloud,
 					driverOptions: &Options{},
 					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
 				}
 
 				if _, err := powervsDriver.ControllerPublishVolume(ctx, req); err != nil {
@@ -689,6 +869,7 @@ func TestControllerPublishVolume(t *testing.T) {
 					cloud:         mockCloud,
 					driverOptions: &Options{},
 					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
 				}
 
 				if _, err := powervsDriver.ControllerPublishVolume(ctx, req); err != nil {
@@ -724,6 +905,7 @@ func TestControllerPublishVolume(t *testing.T) {
 					cloud:         mockCloud,
 					driverOptions: &Options{},
 					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
 				}
 
 				if _, err := powervsDriver.ControllerPublishVolume(ctx, req); err != nil {
@@ -764,6 +946,7 @@ func TestControllerPublishVolume(t *testing.T) {
 					cloud:         mockCloud,
 					driverOptions: &Options{},
 					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
 				}
 
 				if _, err := powervsDriver.ControllerPublishVolume(ctx, req); err != nil {
@@ -795,13 +978,14 @@ func TestControllerPublishVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().GetPVMInstanceByID(gomock.Eq("does-not-exist")).Return(nil, cloud.ErrNotFound)
+				mockCloud.EXPECT().GetPVMInstanceByID(gomock.Any(), gomock.Eq("does-not-exist")).Return(nil, cloud.ErrNotFound)
 				// mockCloud.EXPECT().IsExistInstance(gomock.Eq(ctx), gomock.Eq(req.NodeId)).Return(false)
 
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
 					driverOptions: &Options{},
 					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
 				}
 
 				if _, err := powervsDriver.ControllerPublishVolume(ctx, req); err != nil {
@@ -818,6 +1002,34 @@ func TestControllerPublishVolume(t *testing.T) {
 			},
 		},
 
+		{
+			name: "fail instance lookup unavailable",
+			testFunc: func(t *testing.T) {
+				req := &csi.ControllerPublishVolumeRequest{
+					NodeId:           expInstanceID,
+					VolumeId:         "vol-test",
+					VolumeCapability: stdVolCap,
+				}
+
+				mockCtl := gomock.NewController(t)
+				defer mockCtl.Finish()
+
+				mockCloud := mocks.NewMockCloud(mockCtl)
+				mockCloud.EXPECT().GetPVMInstanceByID(gomock.Any(), gomock.Eq(expInstanceID)).Return(nil, cloud.ErrCircuitOpen)
+
+				powervsDriver := controllerService{
+					cloud:         mockCloud,
+					driverOptions: &Options{},
+					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
+				}
+
+				// the node isn't gone, the CO has to retry
+				_, err := powervsDriver.ControllerPublishVolume(context.Background(), req)
+				expectErr(t, err, codes.Unavailable)
+			},
+		},
+
 		{
 			name: "fail volume not found",
 			testFunc: func(t *testing.T) {
@@ -833,13 +1045,14 @@ func TestControllerPublishVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().GetPVMInstanceByID(gomock.Eq(expInstanceID)).Return(nil, nil)
-				mockCloud.EXPECT().GetDiskByID(gomock.Eq("does-not-exist")).Return(&cloud.Disk{}, cloud.ErrNotFound)
+				mockCloud.EXPECT().GetPVMInstanceByID(gomock.Any(), gomock.Eq(expInstanceID)).Return(nil, nil)
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq("does-not-exist")).Return(&cloud.Disk{}, cloud.ErrNotFound)
 
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
 					driverOptions: &Options{},
 					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
 				}
 
 				if _, err := powervsDriver.ControllerPublishVolume(ctx, req); err != nil {
@@ -862,12 +1075,249 @@ func TestControllerPublishVolume(t *testing.T) {
 	}
 }
 
+func TestControllerPublishVolumeAttachLimits(t *testing.T) {
+	volCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+	attachedDisks := func(n int, tier string) []*cloud.Disk {
+		disks := []*cloud.Disk{}
+		for i := 0; i < n; i++ {
+			disks = append(disks, &cloud.Disk{VolumeID: fmt.Sprintf("vol-%d", i), DiskType: tier, AttachedTo: []string{expInstanceID}})
+		}
+		// volumes attached to other nodes don't count
+		return append(disks, &cloud.Disk{VolumeID: "vol-other", DiskType: tier, AttachedTo: []string{"other-node"}})
+	}
+
+	vscsiLimit := int32(vscsiVolumeLimit - 1)
+
+	testCases := []struct {
+		name       string
+		tierLimits map[string]int64
+		// nodeLimit is the volume limit reported by the node, the NPIV limit applies when nil
+		nodeLimit *int32
+		attached  []*cloud.Disk
+		expAttach bool
+	}{
+		{
+			name:      "below the limits",
+			attached:  attachedDisks(10, cloud.VolumeTypeTier1),
+			expAttach: true,
+		},
+		{
+			name:     "node has the maximum of volumes attached",
+			attached: attachedDisks(defaultMaxVolumesPerInstance, cloud.VolumeTypeTier3),
+		},
+		{
+			name:      "vSCSI node below its limit",
+			nodeLimit: &vscsiLimit,
+			attached:  attachedDisks(vscsiVolumeLimit-2, cloud.VolumeTypeTier3),
+			expAttach: true,
+		},
+		{
+			name:      "vSCSI node has the maximum of volumes attached",
+			nodeLimit: &vscsiLimit,
+			attached:  attachedDisks(vscsiVolumeLimit-1, cloud.VolumeTypeTier3),
+		},
+		{
+			name:       "tier limit reached",
+			tierLimits: map[string]int64{cloud.VolumeTypeTier0: 2},
+			attached:   attachedDisks(2, "Tier0"),
+		},
+		{
+			name:       "limit of another tier",
+			tierLimits: map[string]int64{cloud.VolumeTypeTier1: 2},
+			attached:   attachedDisks(2, cloud.VolumeTypeTier1),
+			expAttach:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			mockCloud.EXPECT().GetPVMInstanceByID(gomock.Any(), expInstanceID).Return(&cloud.PVMInstance{}, nil)
+			mockCloud.EXPECT().GetDiskByID(gomock.Any(), "vol-test").Return(&cloud.Disk{VolumeID: "vol-test", WWN: "600507681081018c9000000000000001", DiskType: cloud.VolumeTypeTier0}, nil)
+			mockCloud.EXPECT().IsAttached(gomock.Any(), "vol-test", expInstanceID).Return(false, nil)
+			mockCloud.EXPECT().ListDisks(gomock.Any()).Return(tc.attached, nil)
+			if tc.expAttach {
+				mockCloud.EXPECT().AttachDisk(gomock.Any(), "vol-test", expInstanceID).Return(nil)
+			}
+
+			d := controllerService{
+				cloud:         mockCloud,
+				driverOptions: &Options{tierAttachLimits: tc.tierLimits},
+				volumeLocks:   util.NewVolumeLocks(),
+				nodeQueues:    newNodeQueues(),
+			}
+			if tc.nodeLimit != nil {
+				d.nodeVolumeLimits = newSyncedNodeVolumeLimits(t, limitCSINode("worker-0", DriverName, expInstanceID, tc.nodeLimit))
+			}
+			_, err := d.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{VolumeId: "vol-test", NodeId: expInstanceID, VolumeCapability: volCap})
+			if tc.expAttach {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if status.Code(err) != codes.ResourceExhausted {
+				t.Fatalf("Expected error code %v, got %v", codes.ResourceExhausted, err)
+			}
+		})
+	}
+}
+
+func TestControllerPublishVolumeInstanceState(t *testing.T) {
+	volCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+	for _, state := range []string{cloud.InstanceShutoffState, cloud.InstanceBuildState, "error"} {
+		t.Run(state, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			mockCloud.EXPECT().GetPVMInstanceByID(gomock.Any(), expInstanceID).Return(&cloud.PVMInstance{ID: expInstanceID, Status: state}, nil)
+
+			d := controllerService{
+				cloud:         mockCloud,
+				driverOptions: &Options{},
+				volumeLocks:   util.NewVolumeLocks(),
+				nodeQueues:    newNodeQueues(),
+			}
+			_, err := d.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{VolumeId: "vol-test", NodeId: expInstanceID, VolumeCapability: volCap})
+			if status.Code(err) != codes.FailedPrecondition {
+				t.Fatalf("Expected error code %v, got %v", codes.FailedPrecondition, err)
+			}
+			if !strings.Contains(err.Error(), state) {
+				t.Fatalf("Expected the error to name the state, got %v", err)
+			}
+		})
+	}
+}
+
+func TestControllerPublishVolumeAlreadyAttached(t *testing.T) {
+	volCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+	testCases := []struct {
+		name       string
+		attachedTo []string
+		expCode    codes.Code
+	}{
+		{
+			name:       "attached to the node",
+			attachedTo: []string{expInstanceID},
+			expCode:    codes.OK,
+		},
+		{
+			name:       "attached to another node",
+			attachedTo: []string{"other-node"},
+			expCode:    codes.FailedPrecondition,
+		},
+		{
+			name:    "not attached anymore",
+			expCode: codes.Aborted,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+			disk := &cloud.Disk{VolumeID: "vol-test", WWN: "600507681081018c9000000000000001"}
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			mockCloud.EXPECT().GetPVMInstanceByID(gomock.Any(), expInstanceID).Return(&cloud.PVMInstance{}, nil)
+			mockCloud.EXPECT().GetDiskByID(gomock.Any(), "vol-test").Return(disk, nil)
+			mockCloud.EXPECT().IsAttached(gomock.Any(), "vol-test", expInstanceID).Return(false, nil)
+			mockCloud.EXPECT().ListDisks(gomock.Any()).Return(nil, nil)
+			mockCloud.EXPECT().AttachDisk(gomock.Any(), "vol-test", expInstanceID).Return(fmt.Errorf("%w: conflict", cloud.ErrAlreadyExists))
+			mockCloud.EXPECT().GetDiskByID(gomock.Any(), "vol-test").Return(&cloud.Disk{VolumeID: "vol-test", WWN: disk.WWN, AttachedTo: tc.attachedTo}, nil)
+
+			d := controllerService{
+				cloud:         mockCloud,
+				driverOptions: &Options{},
+				volumeLocks:   util.NewVolumeLocks(),
+				nodeQueues:    newNodeQueues(),
+			}
+			resp, err := d.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{VolumeId: "vol-test", NodeId: expInstanceID, VolumeCapability: volCap})
+			if status.Code(err) != tc.expCode {
+				t.Fatalf("Expected error code %v, got %v", tc.expCode, err)
+			}
+			if err == nil && resp.GetPublishContext()[WWNKey] != disk.WWN {
+				t.Fatalf("Expected publish context with WWN %s, got %v", disk.WWN, resp.GetPublishContext())
+			}
+			if tc.expCode == codes.FailedPrecondition && !strings.Contains(err.Error(), "other-node") {
+				t.Fatalf("Expected the error to name the node, got %v", err)
+			}
+		})
+	}
+}
+
 func TestControllerUnpublishVolume(t *testing.T) {
 	expDevicePath := "/dev/xvda"
 	testCases := []struct {
 		name     string
 		testFunc func(t *testing.T)
 	}{
+		{
+			name: "success instance deleted",
+			testFunc: func(t *testing.T) {
+				req := &csi.ControllerUnpublishVolumeRequest{
+					NodeId:   expInstanceID,
+					VolumeId: "vol-test",
+				}
+
+				mockCtl := gomock.NewController(t)
+				defer mockCtl.Finish()
+
+				mockCloud := mocks.NewMockCloud(mockCtl)
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq("vol-test")).Return(&cloud.Disk{WWN: expDevicePath}, nil)
+				mockCloud.EXPECT().IsAttached(gomock.Any(), gomock.Eq("vol-test"), gomock.Eq(expInstanceID)).Return(true, nil)
+				mockCloud.EXPECT().DetachDisk(gomock.Any(), gomock.Eq("vol-test"), gomock.Eq(expInstanceID)).Return(fmt.Errorf("%w: pvm instance not found", cloud.ErrNotFound))
+
+				powervsDriver := controllerService{
+					cloud:         mockCloud,
+					driverOptions: &Options{},
+					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
+				}
+
+				if _, err := powervsDriver.ControllerUnpublishVolume(context.Background(), req); err != nil {
+					t.Fatalf("Expected success for a deleted instance, got: %v", err)
+				}
+			},
+		},
+		{
+			name: "fail attachment unknown",
+			testFunc: func(t *testing.T) {
+				req := &csi.ControllerUnpublishVolumeRequest{
+					NodeId:   expInstanceID,
+					VolumeId: "vol-test",
+				}
+
+				mockCtl := gomock.NewController(t)
+				defer mockCtl.Finish()
+
+				mockCloud := mocks.NewMockCloud(mockCtl)
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq("vol-test")).Return(&cloud.Disk{WWN: expDevicePath}, nil)
+				mockCloud.EXPECT().IsAttached(gomock.Any(), gomock.Eq("vol-test"), gomock.Eq(expInstanceID)).Return(false, errors.New("[GET /pcloud/v1/cloud-instances/{cloud_instance_id}/pvm-instances/{pvm_instance_id}/volumes/{volume_id}][503] service unavailable"))
+				mockCloud.EXPECT().DetachDisk(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+				powervsDriver := controllerService{
+					cloud:         mockCloud,
+					driverOptions: &Options{},
+					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
+				}
+
+				if _, err := powervsDriver.ControllerUnpublishVolume(context.Background(), req); status.Code(err) != codes.Unavailable {
+					t.Fatalf("Expected Unavailable while the attachment is unknown, got: %v", err)
+				}
+			},
+		},
 		{
 			name: "success normal",
 			testFunc: func(t *testing.T) {
@@ -883,14 +1333,15 @@ func TestControllerUnpublishVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().GetDiskByID(gomock.Eq("vol-test")).Return(&cloud.Disk{WWN: expDevicePath}, nil)
-				mockCloud.EXPECT().IsAttached(gomock.Eq("vol-test"), gomock.Eq(expInstanceID)).Return(true, nil)
-				mockCloud.EXPECT().DetachDisk(req.VolumeId, req.NodeId).Return(nil)
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq("vol-test")).Return(&cloud.Disk{WWN: expDevicePath}, nil)
+				mockCloud.EXPECT().IsAttached(gomock.Any(), gomock.Eq("vol-test"), gomock.Eq(expInstanceID)).Return(true, nil)
+				mockCloud.EXPECT().DetachDisk(gomock.Any(), req.VolumeId, req.NodeId).Return(nil)
 
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
 					driverOptions: &Options{},
 					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
 				}
 
 				resp, err := powervsDriver.ControllerUnpublishVolume(ctx, req)
@@ -918,12 +1369,13 @@ func TestControllerUnpublishVolume(t *testing.T) {
 				defer mockCtl.Finish()
 
 				mockCloud := mocks.NewMockCloud(mockCtl)
-				mockCloud.EXPECT().GetDiskByID(gomock.Eq("vol-test")).Return(&cloud.Disk{WWN: expDevicePath}, cloud.ErrNotFound)
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq("vol-test")).Return(&cloud.Disk{WWN: expDevicePath}, cloud.ErrNotFound)
 
 				powervsDriver := controllerService{
 					cloud:         mockCloud,
 					driverOptions: &Options{},
 					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
 				}
 				resp, err := powervsDriver.ControllerUnpublishVolume(ctx, req)
 				if err != nil {
@@ -952,6 +1404,7 @@ func TestControllerUnpublishVolume(t *testing.T) {
 					cloud:         mockCloud,
 					driverOptions: &Options{},
 					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
 				}
 
 				if _, err := powervsDriver.ControllerUnpublishVolume(ctx, req); err != nil {
@@ -985,6 +1438,7 @@ func TestControllerUnpublishVolume(t *testing.T) {
 					cloud:         mockCloud,
 					driverOptions: &Options{},
 					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
 				}
 
 				if _, err := powervsDriver.ControllerUnpublishVolume(ctx, req); err != nil {
@@ -1059,7 +1513,8 @@ func TestControllerExpandVolume(t *testing.T) {
 			}
 
 			mockCloud := mocks.NewMockCloud(mockCtl)
-			mockCloud.EXPECT().ResizeDisk(gomock.Eq(tc.req.VolumeId), gomock.Any()).Return(retSizeGiB, nil).AnyTimes()
+			mockCloud.EXPECT().ResizeDisk(gomock.Any(), gomock.Eq(tc.req.VolumeId), gomock.Any()).Return(retSizeGiB, nil).AnyTimes()
+			mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(tc.req.VolumeId)).Return(&cloud.Disk{VolumeID: tc.req.VolumeId, State: cloud.VolumeAvailableState}, nil).AnyTimes()
 
 			powervsDriver := controllerService{
 				cloud:         mockCloud,
@@ -1091,16 +1546,1694 @@ func TestControllerExpandVolume(t *testing.T) {
 	}
 }
 
-func checkExpectedErrorCode(t *testing.T, err error, expectedCode codes.Code) {
-	if err == nil {
-		t.Fatalf("Expected operation to fail but got no error")
+func TestControllerExpandVolumeWhileAttached(t *testing.T) {
+	mountCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+	blockCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+	ctx := context.Background()
+	fakeCloud := fake.NewCloud(0)
+	d := &controllerService{
+		cloud:         fakeCloud,
+		driverOptions: &Options{},
+		volumeLocks:   util.NewVolumeLocks(),
+		nodeQueues:    newNodeQueues(),
+		accessTypes:   newAccessTypes(),
 	}
 
-	srvErr, ok := status.FromError(err)
-	if !ok {
-		t.Fatalf("Could not get error status code from error: %v", srvErr)
+	created, err := d.CreateVolume(ctx, &csi.CreateVolumeRequest{
+		Name:               "vol-test",
+		CapacityRange:      &csi.CapacityRange{RequiredBytes: 10 * util.GiB},
+		VolumeCapabilities: []*csi.VolumeCapability{mountCap},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error creating volume: %v", err)
 	}
-	if srvErr.Code() != expectedCode {
-		t.Fatalf("Expected Aborted but got: %s", srvErr.Code())
+	volumeID := created.Volume.VolumeId
+	if _, err := d.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{VolumeId: volumeID, NodeId: expInstanceID, VolumeCapability: mountCap}); err != nil {
+		t.Fatalf("Unexpected error publishing volume: %v", err)
+	}
+
+	testCases := []struct {
+		name             string
+		requiredGiB      int64
+		volCap           *csi.VolumeCapability
+		expSizeGiB       int64
+		expNodeExpansion bool
+	}{
+		{name: "mounted filesystem", requiredGiB: 20, volCap: mountCap, expSizeGiB: 20, expNodeExpansion: true},
+		{name: "raw block", requiredGiB: 30, volCap: blockCap, expSizeGiB: 30},
+		{name: "already large enough", requiredGiB: 25, volCap: mountCap, expSizeGiB: 30, expNodeExpansion: true},
 	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := d.ControllerExpandVolume(ctx, &csi.ControllerExpandVolumeRequest{
+				VolumeId:         volumeID,
+				CapacityRange:    &csi.CapacityRange{RequiredBytes: tc.requiredGiB * util.GiB},
+				VolumeCapability: tc.volCap,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if sizeGiB := util.BytesToGiB(resp.CapacityBytes); sizeGiB != tc.expSizeGiB {
+				t.Fatalf("Expected size %d GiB, got %d GiB", tc.expSizeGiB, sizeGiB)
+			}
+			if resp.NodeExpansionRequired != tc.expNodeExpansion {
+				t.Fatalf("Expected node expansion required %v, got %v", tc.expNodeExpansion, resp.NodeExpansionRequired)
+			}
+			if n := fakeCloud.CallCount("DetachDisk"); n != 0 {
+				t.Fatalf("Expected volume to stay attached, got %d calls of DetachDisk", n)
+			}
+		})
+	}
+}
+
+func TestControllerExpandVolumeNodeExpansion(t *testing.T) {
+	mountCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+	blockCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+	sharedBlockCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+	}
+	testCases := []struct {
+		name             string
+		shareable        bool
+		publishCap       *csi.VolumeCapability
+		nodes            []string
+		unpublish        bool
+		restart          bool
+		expandCap        *csi.VolumeCapability
+		expNodeExpansion bool
+	}{
+		{name: "detached filesystem", expandCap: mountCap},
+		{name: "shareable block attached to several nodes", shareable: true, publishCap: sharedBlockCap, nodes: []string{expInstanceID, "other-instance"}, expandCap: sharedBlockCap, expNodeExpansion: true},
+		{name: "detached shareable block", shareable: true, expandCap: sharedBlockCap},
+		{name: "published filesystem without capability", publishCap: mountCap, expNodeExpansion: true},
+		{name: "published block without capability", publishCap: blockCap},
+		{name: "unpublished filesystem", publishCap: mountCap, unpublish: true, expandCap: mountCap},
+		{name: "unknown access type of attached volume", publishCap: blockCap, restart: true, expNodeExpansion: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			d := &controllerService{
+				cloud:         fake.NewCloud(0),
+				driverOptions: &Options{},
+				volumeLocks:   util.NewVolumeLocks(),
+				nodeQueues:    newNodeQueues(),
+				accessTypes:   newAccessTypes(),
+			}
+			var volumeID string
+			if tc.shareable {
+				// shareable volumes are published by static PVs
+				disk, err := d.cloud.CreateDisk(ctx, "vol-test", &cloud.DiskOptions{Shareable: true, CapacityBytes: 10 * util.GiB})
+				if err != nil {
+					t.Fatalf("Unexpected error creating disk: %v", err)
+				}
+				volumeID = disk.VolumeID
+			} else {
+				created, err := d.CreateVolume(ctx, &csi.CreateVolumeRequest{
+					Name:               "vol-test",
+					CapacityRange:      &csi.CapacityRange{RequiredBytes: 10 * util.GiB},
+					VolumeCapabilities: []*csi.VolumeCapability{mountCap},
+				})
+				if err != nil {
+					t.Fatalf("Unexpected error creating volume: %v", err)
+				}
+				volumeID = created.Volume.VolumeId
+			}
+			nodes := tc.nodes
+			if tc.publishCap != nil && len(nodes) == 0 {
+				nodes = []string{expInstanceID}
+			}
+			for _, node := range nodes {
+				if _, err := d.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{VolumeId: volumeID, NodeId: node, VolumeCapability: tc.publishCap}); err != nil {
+					t.Fatalf("Unexpected error publishing volume to %s: %v", node, err)
+				}
+			}
+			if tc.unpublish {
+				if _, err := d.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{VolumeId: volumeID, NodeId: expInstanceID}); err != nil {
+					t.Fatalf("Unexpected error unpublishing volume: %v", err)
+				}
+			}
+			if tc.restart {
+				// the records of the access types are lost
+				d.accessTypes = newAccessTypes()
+			}
+
+			resp, err := d.ControllerExpandVolume(ctx, &csi.ControllerExpandVolumeRequest{
+				VolumeId:         volumeID,
+				CapacityRange:    &csi.CapacityRange{RequiredBytes: 20 * util.GiB},
+				VolumeCapability: tc.expandCap,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resp.NodeExpansionRequired != tc.expNodeExpansion {
+				t.Fatalf("Expected node expansion required %v, got %v", tc.expNodeExpansion, resp.NodeExpansionRequired)
+			}
+		})
+	}
+}
+
+func TestCheckExpansion(t *testing.T) {
+	testCases := []struct {
+		name        string
+		disk        cloud.Disk
+		sizeGiB     int64
+		instance    *cloud.PVMInstance
+		capacity    *cloud.StorageCapacity
+		capacityErr error
+		expCode     codes.Code
+	}{
+		{
+			name:    "already large enough",
+			disk:    cloud.Disk{CapacityGiB: 20, State: cloud.VolumeErrorState},
+			sizeGiB: 20,
+			expCode: codes.OK,
+		},
+		{
+			name:     "within tier maximum",
+			disk:     cloud.Disk{CapacityGiB: 10, DiskType: cloud.VolumeTypeTier1, State: cloud.VolumeInUseState, AttachedTo: []string{"instance-1"}},
+			sizeGiB:  100,
+			instance: &cloud.PVMInstance{ID: "instance-1", Status: cloud.InstanceShutoffState},
+			capacity: &cloud.StorageCapacity{MaximumVolumeGiB: 90},
+			expCode:  codes.OK,
+		},
+		{
+			name:     "above tier maximum",
+			disk:     cloud.Disk{CapacityGiB: 10, DiskType: cloud.VolumeTypeTier1, State: cloud.VolumeAvailableState},
+			sizeGiB:  101,
+			capacity: &cloud.StorageCapacity{MaximumVolumeGiB: 90},
+			expCode:  codes.OutOfRange,
+		},
+		{
+			name:        "capacity unknown",
+			disk:        cloud.Disk{CapacityGiB: 10, DiskType: cloud.VolumeTypeTier1, State: cloud.VolumeAvailableState},
+			sizeGiB:     2000,
+			capacityErr: errors.New("service unavailable"),
+			expCode:     codes.OK,
+		},
+		{
+			name:    "volume in error",
+			disk:    cloud.Disk{CapacityGiB: 10, State: cloud.VolumeErrorState},
+			sizeGiB: 20,
+			expCode: codes.FailedPrecondition,
+		},
+		{
+			name:    "volume resizing",
+			disk:    cloud.Disk{CapacityGiB: 10, State: "resizing"},
+			sizeGiB: 20,
+			expCode: codes.Aborted,
+		},
+		{
+			name:     "attached to instance in error",
+			disk:     cloud.Disk{CapacityGiB: 10, State: cloud.VolumeInUseState, AttachedTo: []string{"instance-1"}},
+			sizeGiB:  20,
+			instance: &cloud.PVMInstance{ID: "instance-1", Status: cloud.InstanceErrorState},
+			expCode:  codes.FailedPrecondition,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			if tc.instance != nil {
+				mockCloud.EXPECT().GetPVMInstanceByID(gomock.Any(), gomock.Eq(tc.instance.ID)).Return(tc.instance, nil)
+			}
+			if tc.capacity != nil || tc.capacityErr != nil {
+				mockCloud.EXPECT().GetStorageCapacity(gomock.Any(), gomock.Eq(tc.disk.DiskType)).Return(tc.capacity, tc.capacityErr)
+			}
+
+			err := checkExpansion(context.Background(), mockCloud, &tc.disk, "vol-test", tc.sizeGiB*util.GiB)
+			if status.Code(err) != tc.expCode {
+				t.Fatalf("Expected code %v, got: %v", tc.expCode, err)
+			}
+		})
+	}
+}
+
+func TestControllerExpandVolumeBusy(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockCloud := mocks.NewMockCloud(mockCtl)
+	mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq("vol-test")).Return(&cloud.Disk{VolumeID: "vol-test", CapacityGiB: 10, State: cloud.VolumeAvailableState}, nil)
+	mockCloud.EXPECT().ResizeDisk(gomock.Any(), gomock.Eq("vol-test"), gomock.Any()).Return(int64(0), fmt.Errorf("%w: volume vol-test is resizing", cloud.ErrVolumeBusy))
+
+	d := &controllerService{
+		cloud:         mockCloud,
+		driverOptions: &Options{},
+		volumeLocks:   util.NewVolumeLocks(),
+	}
+	_, err := d.ControllerExpandVolume(context.Background(), &csi.ControllerExpandVolumeRequest{
+		VolumeId:      "vol-test",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 20 * util.GiB},
+	})
+	checkExpectedErrorCode(t, err, codes.Aborted)
+}
+
+func checkExpectedErrorCode(t *testing.T, err error, expectedCode codes.Code) {
+	if err == nil {
+		t.Fatalf("Expected operation to fail but got no error")
+	}
+
+	srvErr, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("Could not get error status code from error: %v", srvErr)
+	}
+	if srvErr.Code() != expectedCode {
+		t.Fatalf("Expected Aborted but got: %s", srvErr.Code())
+	}
+}
+
+func TestCreateVolumeWorkspaces(t *testing.T) {
+	stdVolCap := []*csi.VolumeCapability{
+		{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{},
+			},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			},
+		},
+	}
+	workspaceTopology := func(id string) []*csi.Topology {
+		return []*csi.Topology{{Segments: map[string]string{WorkspaceTopologyKey: id}}}
+	}
+
+	testCases := []struct {
+		name             string
+		params           map[string]string
+		requirements     *csi.TopologyRequirement
+		singleWorkspace  bool
+		expectWorkspace  string
+		expectedVolumeID string
+		expectErr        codes.Code
+	}{
+		{
+			name:             "default workspace",
+			expectWorkspace:  "ws-1",
+			expectedVolumeID: "vol-1",
+		},
+		{
+			name:             "workspace parameter",
+			params:           map[string]string{WorkspaceKey: "ws-2"},
+			requirements:     &csi.TopologyRequirement{Preferred: workspaceTopology("ws-1")},
+			expectWorkspace:  "ws-2",
+			expectedVolumeID: "ws-2/vol-1",
+		},
+		{
+			name: "preferred topology",
+			requirements: &csi.TopologyRequirement{
+				Requisite: workspaceTopology("ws-1"),
+				Preferred: workspaceTopology("ws-2"),
+			},
+			expectWorkspace:  "ws-2",
+			expectedVolumeID: "ws-2/vol-1",
+		},
+		{
+			name:             "unmanaged workspace in topology",
+			requirements:     &csi.TopologyRequirement{Preferred: workspaceTopology("ws-3")},
+			expectWorkspace:  "ws-1",
+			expectedVolumeID: "vol-1",
+		},
+		{
+			name:      "fail unmanaged workspace parameter",
+			params:    map[string]string{WorkspaceKey: "ws-3"},
+			expectErr: codes.InvalidArgument,
+		},
+		{
+			name:            "fail workspace parameter with a single workspace",
+			params:          map[string]string{WorkspaceKey: "ws-2"},
+			singleWorkspace: true,
+			expectErr:       codes.InvalidArgument,
+		},
+		{
+			name:             "single workspace",
+			requirements:     &csi.TopologyRequirement{Requisite: workspaceTopology("ws-1")},
+			singleWorkspace:  true,
+			expectWorkspace:  "ws-1",
+			expectedVolumeID: "vol-1",
+		},
+		{
+			name:            "fail requisite topology of another workspace",
+			requirements:    &csi.TopologyRequirement{Requisite: workspaceTopology("ws-2")},
+			singleWorkspace: true,
+			expectErr:       codes.ResourceExhausted,
+		},
+		{
+			name: "fail requisite topology of unmanaged workspaces",
+			requirements: &csi.TopologyRequirement{
+				Requisite: workspaceTopology("ws-3"),
+				Preferred: workspaceTopology("ws-3"),
+			},
+			expectErr: codes.ResourceExhausted,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			clouds := map[string]*mocks.MockCloud{
+				"ws-1": mocks.NewMockCloud(mockCtl),
+				"ws-2": mocks.NewMockCloud(mockCtl),
+			}
+			if c, ok := clouds[tc.expectWorkspace]; ok {
+				c.EXPECT().GetDiskByName(gomock.Any(), gomock.Eq("random-vol-name")).Return(nil, nil)
+				c.EXPECT().CreateDisk(gomock.Any(), gomock.Eq("random-vol-name"), gomock.Any()).Return(&cloud.Disk{VolumeID: "vol-1", CapacityGiB: 1}, nil)
+			}
+
+			powervsDriver := controllerService{
+				cloud:           clouds["ws-1"],
+				cloudInstanceID: "ws-1",
+				driverOptions:   &Options{},
+				volumeLocks:     util.NewVolumeLocks(),
+			}
+			if !tc.singleWorkspace {
+				powervsDriver.workspaces = cloud.NewWorkspaces("ws-1", clouds["ws-1"], []string{"ws-2"}, func(id string) (cloud.Cloud, error) {
+					return clouds[id], nil
+				})
+			}
+
+			resp, err := powervsDriver.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name:                      "random-vol-name",
+				VolumeCapabilities:        stdVolCap,
+				Parameters:                tc.params,
+				AccessibilityRequirements: tc.requirements,
+			})
+			if tc.expectErr != codes.OK {
+				if status.Code(err) != tc.expectErr {
+					t.Fatalf("Expected error code %v, got: %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resp.Volume.VolumeId != tc.expectedVolumeID {
+				t.Fatalf("Expected volume ID %q, got %q", tc.expectedVolumeID, resp.Volume.VolumeId)
+			}
+			if !reflect.DeepEqual(resp.Volume.AccessibleTopology, workspaceTopology(tc.expectWorkspace)) {
+				t.Fatalf("Expected topology of workspace %s, got %v", tc.expectWorkspace, resp.Volume.AccessibleTopology)
+			}
+		})
+	}
+}
+
+func TestGetCapacity(t *testing.T) {
+	testCases := []struct {
+		name            string
+		params          map[string]string
+		segments        map[string]string
+		expectWorkspace string
+		expectType      string
+		cloudErr        error
+		expectErr       codes.Code
+	}{
+		{
+			name:            "default volume type",
+			expectWorkspace: "ws-1",
+			expectType:      cloud.DefaultVolumeType,
+		},
+		{
+			name:            "disk type of the segment",
+			segments:        map[string]string{DiskTypeKey: cloud.VolumeTypeTier3, WorkspaceTopologyKey: "ws-2"},
+			expectWorkspace: "ws-2",
+			expectType:      cloud.VolumeTypeTier3,
+		},
+		{
+			name:            "type parameter",
+			params:          map[string]string{VolumeTypeKey: cloud.VolumeTypeTier5k, TagKeyPrefix + "_1": "team=storage"},
+			segments:        map[string]string{DiskTypeKey: cloud.VolumeTypeTier3},
+			expectWorkspace: "ws-1",
+			expectType:      cloud.VolumeTypeTier5k,
+		},
+		{
+			name:      "fail unknown volume type",
+			params:    map[string]string{VolumeTypeKey: "tier2"},
+			expectErr: codes.InvalidArgument,
+		},
+		{
+			name:            "fail cloud error",
+			expectWorkspace: "ws-1",
+			expectType:      cloud.DefaultVolumeType,
+			cloudErr:        errors.New("[GET /pcloud/v1/cloud-instances/{cloud_instance_id}/storage-capacity/storage-types/{storage_type_name}][403] forbidden"),
+			expectErr:       codes.PermissionDenied,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			clouds := map[string]*mocks.MockCloud{
+				"ws-1": mocks.NewMockCloud(mockCtl),
+				"ws-2": mocks.NewMockCloud(mockCtl),
+			}
+			if c, ok := clouds[tc.expectWorkspace]; ok {
+				var capacity *cloud.StorageCapacity
+				if tc.cloudErr == nil {
+					capacity = &cloud.StorageCapacity{AvailableGiB: 500, MaximumVolumeGiB: 200}
+				}
+				c.EXPECT().GetStorageCapacity(gomock.Any(), gomock.Eq(tc.expectType)).Return(capacity, tc.cloudErr)
+			}
+			powervsDriver := controllerService{
+				cloud:         clouds["ws-1"],
+				driverOptions: &Options{},
+				workspaces: cloud.NewWorkspaces("ws-1", clouds["ws-1"], []string{"ws-2"}, func(id string) (cloud.Cloud, error) {
+					return clouds[id], nil
+				}),
+			}
+
+			req := &csi.GetCapacityRequest{Parameters: tc.params}
+			if tc.segments != nil {
+				req.AccessibleTopology = &csi.Topology{Segments: tc.segments}
+			}
+			resp, err := powervsDriver.GetCapacity(context.Background(), req)
+			if status.Code(err) != tc.expectErr {
+				t.Fatalf("Expected code %v, got: %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if resp.AvailableCapacity != 500*util.GiB || resp.MaximumVolumeSize.GetValue() != 200*util.GiB {
+				t.Fatalf("Unexpected capacity %+v", resp)
+			}
+		})
+	}
+}
+
+func TestGetCapacityStoragePool(t *testing.T) {
+	testCases := []struct {
+		name            string
+		params          map[string]string
+		segments        map[string]string
+		cloudErr        error
+		expectPool      string
+		expectErr       codes.Code
+		expectAvailable int64
+	}{
+		{
+			name:            "success pool parameter",
+			params:          map[string]string{StoragePoolKey: "Tier3-Flash-1"},
+			expectPool:      "Tier3-Flash-1",
+			expectAvailable: 300 * util.GiB,
+		},
+		{
+			name:            "success pool of the segment",
+			params:          map[string]string{VolumeTypeKey: cloud.VolumeTypeTier1},
+			segments:        map[string]string{StoragePoolTopologyKey: "Tier1-Flash-2"},
+			expectPool:      "Tier1-Flash-2",
+			expectAvailable: 300 * util.GiB,
+		},
+		{
+			name:            "success pool parameter over the segment",
+			params:          map[string]string{StoragePoolKey: "Tier3-Flash-1"},
+			segments:        map[string]string{StoragePoolTopologyKey: "Tier3-Flash-2"},
+			expectPool:      "Tier3-Flash-1",
+			expectAvailable: 300 * util.GiB,
+		},
+		{
+			name:       "fail unknown pool",
+			params:     map[string]string{StoragePoolKey: "Tier3-Flash-9"},
+			cloudErr:   cloud.ErrNotFound,
+			expectPool: "Tier3-Flash-9",
+			expectErr:  codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			var capacity *cloud.StorageCapacity
+			if tc.cloudErr == nil {
+				capacity = &cloud.StorageCapacity{AvailableGiB: 300, MaximumVolumeGiB: 300}
+			}
+			mockCloud.EXPECT().GetStoragePoolCapacity(gomock.Any(), gomock.Eq(tc.expectPool)).Return(capacity, tc.cloudErr)
+			powervsDriver := controllerService{
+				cloud:         mockCloud,
+				driverOptions: &Options{},
+			}
+
+			req := &csi.GetCapacityRequest{Parameters: tc.params}
+			if tc.segments != nil {
+				req.AccessibleTopology = &csi.Topology{Segments: tc.segments}
+			}
+			resp, err := powervsDriver.GetCapacity(context.Background(), req)
+			if status.Code(err) != tc.expectErr {
+				t.Fatalf("Expected code %v, got: %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if resp.AvailableCapacity != tc.expectAvailable || resp.MaximumVolumeSize.GetValue() != tc.expectAvailable {
+				t.Fatalf("Expected capacity %d, got %+v", tc.expectAvailable, resp)
+			}
+		})
+	}
+}
+
+func TestDeleteVolumeWorkspaces(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	defaultCloud := mocks.NewMockCloud(mockCtl)
+	otherCloud := mocks.NewMockCloud(mockCtl)
+	otherCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq("vol-1")).Return(&cloud.Disk{VolumeID: "vol-1"}, nil)
+	otherCloud.EXPECT().DeleteDisk(gomock.Any(), gomock.Eq("vol-1")).Return(true, nil)
+
+	powervsDriver := controllerService{
+		cloud: defaultCloud,
+		workspaces: cloud.NewWorkspaces("ws-1", defaultCloud, []string{"ws-2"}, func(id string) (cloud.Cloud, error) {
+			return otherCloud, nil
+		}),
+		driverOptions: &Options{},
+		volumeLocks:   util.NewVolumeLocks(),
+	}
+
+	if _, err := powervsDriver.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "ws-2/vol-1"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err := powervsDriver.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "ws-3/vol-1"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected error code %v for an unmanaged workspace, got: %v", codes.InvalidArgument, err)
+	}
+}
+
+func TestCreateVolumeReplication(t *testing.T) {
+	stdVolCap := []*csi.VolumeCapability{
+		{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{},
+			},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			},
+		},
+	}
+
+	testCases := []struct {
+		name      string
+		value     string
+		expected  bool
+		expectErr bool
+	}{
+		{
+			name:     "enabled",
+			value:    "true",
+			expected: true,
+		},
+		{
+			name:     "disabled",
+			value:    "false",
+			expected: false,
+		},
+		{
+			name:      "fail invalid value",
+			value:     "yes please",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			if !tc.expectErr {
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Any()).Return(nil, nil)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, name string, opts *cloud.DiskOptions) (*cloud.Disk, error) {
+					if opts.ReplicationEnabled != tc.expected {
+						t.Fatalf("expected ReplicationEnabled %v, got %v", tc.expected, opts.ReplicationEnabled)
+					}
+					return &cloud.Disk{VolumeID: "vol-1", CapacityGiB: 1}, nil
+				})
+			}
+
+			powervsDriver := controllerService{
+				cloud:         mockCloud,
+				driverOptions: &Options{},
+				volumeLocks:   util.NewVolumeLocks(),
+			}
+
+			_, err := powervsDriver.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name:               "random-vol-name",
+				VolumeCapabilities: stdVolCap,
+				Parameters:         map[string]string{ReplicationEnabledKey: tc.value},
+			})
+			if tc.expectErr {
+				if status.Code(err) != codes.InvalidArgument {
+					t.Fatalf("Expected error code %v, got: %v", codes.InvalidArgument, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateVolumeStoragePool(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	mockCloud := mocks.NewMockCloud(mockCtl)
+	mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Any()).Return(nil, cloud.ErrNotFound)
+	mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, name string, opts *cloud.DiskOptions) (*cloud.Disk, error) {
+		if opts.StoragePool != "Tier1-Flash-1" {
+			t.Fatalf("expected StoragePool %q, got %q", "Tier1-Flash-1", opts.StoragePool)
+		}
+		return &cloud.Disk{VolumeID: "vol-1", CapacityGiB: 1, StoragePool: opts.StoragePool}, nil
+	})
+
+	powervsDriver := controllerService{
+		cloud:         mockCloud,
+		driverOptions: &Options{},
+		volumeLocks:   util.NewVolumeLocks(),
+	}
+	_, err := powervsDriver.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name: "random-vol-name",
+		VolumeCapabilities: []*csi.VolumeCapability{{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		}},
+		Parameters: map[string]string{StoragePoolKey: "Tier1-Flash-1"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestCreateVolumeClone(t *testing.T) {
+	stdVolCap := []*csi.VolumeCapability{{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}}
+	testCases := []struct {
+		name        string
+		source      string
+		params      map[string]string
+		requiredGiB int64
+		expSizeGiB  int64
+		expectErr   codes.Code
+	}{
+		{name: "clone in the pool of the source", expSizeGiB: 10},
+		{name: "larger clone", requiredGiB: 20, expSizeGiB: 20},
+		{name: "storage pool of the source", params: map[string]string{StoragePoolKey: "pool-1"}, expSizeGiB: 10},
+		{name: "type of the source", params: map[string]string{VolumeTypeKey: cloud.VolumeTypeTier3}, expSizeGiB: 10},
+		{name: "fail other storage pool", params: map[string]string{StoragePoolKey: "pool-2"}, expectErr: codes.InvalidArgument},
+		{name: "fail other type", params: map[string]string{VolumeTypeKey: cloud.VolumeTypeTier1}, expectErr: codes.InvalidArgument},
+		{name: "fail smaller than the source", requiredGiB: 5, expectErr: codes.OutOfRange},
+		{name: "fail source not found", source: "vol-missing", expectErr: codes.NotFound},
+		{name: "fail source of another workspace", params: map[string]string{WorkspaceKey: "ws-2"}, expectErr: codes.InvalidArgument},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			clouds := map[string]*fake.Cloud{"ws-1": fake.NewCloud(0), "ws-2": fake.NewCloud(0)}
+			source, err := clouds["ws-1"].CreateDisk(ctx, "pvc-source", &cloud.DiskOptions{CapacityBytes: 10 * util.GiB, VolumeType: cloud.VolumeTypeTier3, StoragePool: "pool-1"})
+			if err != nil {
+				t.Fatalf("Unexpected error creating source volume: %v", err)
+			}
+			d := &controllerService{
+				cloud:           clouds["ws-1"],
+				cloudInstanceID: "ws-1",
+				workspaces: cloud.NewWorkspaces("ws-1", clouds["ws-1"], []string{"ws-2"}, func(id string) (cloud.Cloud, error) {
+					return clouds[id], nil
+				}),
+				driverOptions: &Options{},
+				volumeLocks:   util.NewVolumeLocks(),
+			}
+			sourceID := source.VolumeID
+			if tc.source != "" {
+				sourceID = tc.source
+			}
+			req := &csi.CreateVolumeRequest{
+				Name:               "pvc-clone",
+				VolumeCapabilities: stdVolCap,
+				Parameters:         tc.params,
+				VolumeContentSource: &csi.VolumeContentSource{
+					Type: &csi.VolumeContentSource_Volume{Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: sourceID}},
+				},
+			}
+			if tc.requiredGiB > 0 {
+				req.CapacityRange = &csi.CapacityRange{RequiredBytes: tc.requiredGiB * util.GiB}
+			}
+
+			resp, err := d.CreateVolume(ctx, req)
+			if tc.expectErr != codes.OK {
+				if status.Code(err) != tc.expectErr {
+					t.Fatalf("Expected error code %v, got: %v", tc.expectErr, err)
+				}
+				if n := clouds["ws-1"].CallCount("CloneDisk") + clouds["ws-2"].CallCount("CloneDisk"); n != 0 {
+					t.Fatalf("Expected no clone, got %d calls of CloneDisk", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(resp.Volume.ContentSource, req.VolumeContentSource) {
+				t.Fatalf("Expected content source %v, got %v", req.VolumeContentSource, resp.Volume.ContentSource)
+			}
+			if sizeGiB := util.BytesToGiB(resp.Volume.CapacityBytes); sizeGiB != tc.expSizeGiB {
+				t.Fatalf("Expected size %d GiB, got %d GiB", tc.expSizeGiB, sizeGiB)
+			}
+			clone, err := clouds["ws-1"].GetDiskByID(ctx, resp.Volume.VolumeId)
+			if err != nil {
+				t.Fatalf("Unexpected error getting clone: %v", err)
+			}
+			if clone.StoragePool != "pool-1" || clone.DiskType != cloud.VolumeTypeTier3 {
+				t.Fatalf("Expected a tier3 clone in pool-1, got %+v", clone)
+			}
+
+			retried, err := d.CreateVolume(ctx, req)
+			if err != nil {
+				t.Fatalf("Unexpected error retrying: %v", err)
+			}
+			if retried.Volume.VolumeId != resp.Volume.VolumeId || !reflect.DeepEqual(retried.Volume.ContentSource, req.VolumeContentSource) {
+				t.Fatalf("Expected retry to return clone %s, got %v", resp.Volume.VolumeId, retried.Volume)
+			}
+			if n := clouds["ws-1"].CallCount("CloneDisk"); n != 1 {
+				t.Fatalf("Expected 1 call of CloneDisk, got %d", n)
+			}
+		})
+	}
+}
+
+func TestCreateVolumeStoragePoolTopology(t *testing.T) {
+	poolTopology := func(pool string) *csi.Topology {
+		return &csi.Topology{Segments: map[string]string{StoragePoolTopologyKey: pool}}
+	}
+	requirements := &csi.TopologyRequirement{
+		Preferred: []*csi.Topology{poolTopology("Tier1-Flash-2")},
+		Requisite: []*csi.Topology{poolTopology("Tier1-Flash-1"), poolTopology("Tier1-Flash-2")},
+	}
+
+	testCases := []struct {
+		name           string
+		gates          FeatureGates
+		params         map[string]string
+		requirements   *csi.TopologyRequirement
+		expectPool     string
+		expectTopology []*csi.Topology
+		expectErr      codes.Code
+	}{
+		{
+			name:         "success without feature gate",
+			requirements: requirements,
+		},
+		{
+			name:           "success pool of the preferred topology",
+			gates:          FeatureGates{StoragePoolTopology: true},
+			requirements:   requirements,
+			expectPool:     "Tier1-Flash-2",
+			expectTopology: []*csi.Topology{poolTopology("Tier1-Flash-2")},
+		},
+		{
+			name:           "success pool parameter",
+			gates:          FeatureGates{StoragePoolTopology: true},
+			params:         map[string]string{StoragePoolKey: "Tier1-Flash-1"},
+			requirements:   requirements,
+			expectPool:     "Tier1-Flash-1",
+			expectTopology: []*csi.Topology{poolTopology("Tier1-Flash-1")},
+		},
+		{
+			name:       "success nodes without pool",
+			gates:      FeatureGates{StoragePoolTopology: true},
+			params:     map[string]string{StoragePoolKey: "Tier1-Flash-1"},
+			expectPool: "Tier1-Flash-1",
+		},
+		{
+			name:         "fail pool parameter not accessible",
+			gates:        FeatureGates{StoragePoolTopology: true},
+			params:       map[string]string{StoragePoolKey: "Tier1-Flash-3"},
+			requirements: requirements,
+			expectErr:    codes.ResourceExhausted,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			if tc.expectErr == codes.OK {
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Any()).Return(nil, cloud.ErrNotFound)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, name string, opts *cloud.DiskOptions) (*cloud.Disk, error) {
+					if opts.StoragePool != tc.expectPool {
+						t.Fatalf("Expected StoragePool %q, got %q", tc.expectPool, opts.StoragePool)
+					}
+					return &cloud.Disk{VolumeID: "vol-1", CapacityGiB: 1, StoragePool: opts.StoragePool}, nil
+				})
+			}
+
+			powervsDriver := controllerService{
+				cloud:         mockCloud,
+				driverOptions: &Options{featureGates: tc.gates},
+				volumeLocks:   util.NewVolumeLocks(),
+			}
+			resp, err := powervsDriver.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name:                      "random-vol-name",
+				VolumeCapabilities:        []*csi.VolumeCapability{mountCapability("")},
+				Parameters:                tc.params,
+				AccessibilityRequirements: tc.requirements,
+			})
+			if status.Code(err) != tc.expectErr {
+				t.Fatalf("Expected code %v, got: %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(resp.Volume.AccessibleTopology, tc.expectTopology) {
+				t.Fatalf("Expected topology %v, got %v", tc.expectTopology, resp.Volume.AccessibleTopology)
+			}
+		})
+	}
+}
+
+func TestControllerGetCapabilities(t *testing.T) {
+	// the capabilities of the alpha features are only advertised once they are enabled
+	defaultCaps := controllerCaps[:len(controllerCaps)-2]
+	testCases := []struct {
+		name    string
+		gates   FeatureGates
+		expCaps []csi.ControllerServiceCapability_RPC_Type
+	}{
+		{name: "default features", expCaps: defaultCaps},
+		{name: "alpha features", gates: FeatureGates{SingleNodeMultiWriter: true, VolumeAttributesClass: true}, expCaps: controllerCaps},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			powervsDriver := controllerService{driverOptions: &Options{featureGates: tc.gates}}
+			resp, err := powervsDriver.ControllerGetCapabilities(context.Background(), &csi.ControllerGetCapabilitiesRequest{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var types []csi.ControllerServiceCapability_RPC_Type
+			for _, c := range resp.GetCapabilities() {
+				types = append(types, c.GetRpc().GetType())
+			}
+			if !reflect.DeepEqual(types, tc.expCaps) {
+				t.Fatalf("Expected capabilities %v, got %v", tc.expCaps, types)
+			}
+		})
+	}
+}
+
+func TestValidateVolumeCapabilities(t *testing.T) {
+	singleWriter := []*csi.VolumeCapability{{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}}
+	multiWriter := []*csi.VolumeCapability{{
+		AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+	}}
+	multiWriterMount := []*csi.VolumeCapability{{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+	}}
+	multiReaderMount := []*csi.VolumeCapability{{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{FsType: FSTypeExt4}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY},
+	}}
+	unsupportedFS := []*csi.VolumeCapability{{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{FsType: "btrfs"}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}}
+	singleReader := []*csi.VolumeCapability{{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY},
+	}}
+
+	testCases := []struct {
+		name         string
+		req          *csi.ValidateVolumeCapabilitiesRequest
+		shareable    bool
+		publishedAs  *csi.VolumeCapability
+		diskErr      error
+		expectLookup bool
+		expCode      codes.Code
+		expConfirmed bool
+	}{
+		{
+			name:         "supported capabilities",
+			req:          &csi.ValidateVolumeCapabilitiesRequest{VolumeId: "vol-test", VolumeCapabilities: singleWriter},
+			expectLookup: true,
+			expCode:      codes.OK,
+			expConfirmed: true,
+		},
+		{
+			name:         "unsupported capabilities",
+			req:          &csi.ValidateVolumeCapabilitiesRequest{VolumeId: "vol-test", VolumeCapabilities: multiWriter},
+			expectLookup: true,
+			expCode:      codes.OK,
+		},
+		{
+			name:         "multi writer block volume of shareable volume",
+			req:          &csi.ValidateVolumeCapabilitiesRequest{VolumeId: "vol-test", VolumeCapabilities: multiWriter},
+			shareable:    true,
+			expectLookup: true,
+			expCode:      codes.OK,
+			expConfirmed: true,
+		},
+		{
+			name:         "multi writer mount volume of shareable volume",
+			req:          &csi.ValidateVolumeCapabilitiesRequest{VolumeId: "vol-test", VolumeCapabilities: multiWriterMount},
+			shareable:    true,
+			expectLookup: true,
+			expCode:      codes.OK,
+		},
+		{
+			name:         "multi reader mount volume of shareable volume",
+			req:          &csi.ValidateVolumeCapabilitiesRequest{VolumeId: "vol-test", VolumeCapabilities: multiReaderMount},
+			shareable:    true,
+			expectLookup: true,
+			expCode:      codes.OK,
+			expConfirmed: true,
+		},
+		{
+			name:         "unsupported access mode",
+			req:          &csi.ValidateVolumeCapabilitiesRequest{VolumeId: "vol-test", VolumeCapabilities: singleReader},
+			shareable:    true,
+			expectLookup: true,
+			expCode:      codes.OK,
+		},
+		{
+			name:         "unsupported filesystem",
+			req:          &csi.ValidateVolumeCapabilitiesRequest{VolumeId: "vol-test", VolumeCapabilities: unsupportedFS},
+			expectLookup: true,
+			expCode:      codes.OK,
+		},
+		{
+			name:         "mount volume published as block volume",
+			req:          &csi.ValidateVolumeCapabilitiesRequest{VolumeId: "vol-test", VolumeCapabilities: singleWriter},
+			publishedAs:  blockCapability(),
+			expectLookup: true,
+			expCode:      codes.OK,
+		},
+		{
+			name:         "volume published with the access type",
+			req:          &csi.ValidateVolumeCapabilitiesRequest{VolumeId: "vol-test", VolumeCapabilities: singleWriter},
+			publishedAs:  mountCapability(""),
+			expectLookup: true,
+			expCode:      codes.OK,
+			expConfirmed: true,
+		},
+		{
+			name: "static volume matching its PV",
+			req: &csi.ValidateVolumeCapabilitiesRequest{
+				VolumeId:           "vol-test",
+				VolumeCapabilities: singleWriter,
+				VolumeContext:      map[string]string{ShareableKey: "false", TierKey: cloud.VolumeTypeTier1},
+				Parameters:         map[string]string{VolumeTypeKey: cloud.VolumeTypeTier1},
+			},
+			expectLookup: true,
+			expCode:      codes.OK,
+			expConfirmed: true,
+		},
+		{
+			name: "static volume of another tier",
+			req: &csi.ValidateVolumeCapabilitiesRequest{
+				VolumeId:           "vol-test",
+				VolumeCapabilities: singleWriter,
+				Parameters:         map[string]string{VolumeTypeKey: cloud.VolumeTypeTier3},
+			},
+			expectLookup: true,
+			expCode:      codes.OK,
+		},
+		{
+			name: "static volume not shareable",
+			req: &csi.ValidateVolumeCapabilitiesRequest{
+				VolumeId:           "vol-test",
+				VolumeCapabilities: singleWriter,
+				VolumeContext:      map[string]string{ShareableKey: "true"},
+			},
+			expectLookup: true,
+			expCode:      codes.OK,
+		},
+		{
+			name:    "fail no VolumeId",
+			req:     &csi.ValidateVolumeCapabilitiesRequest{VolumeCapabilities: singleWriter},
+			expCode: codes.InvalidArgument,
+		},
+		{
+			name:    "fail no VolumeCapabilities",
+			req:     &csi.ValidateVolumeCapabilitiesRequest{VolumeId: "vol-test"},
+			expCode: codes.InvalidArgument,
+		},
+		{
+			name:         "fail volume not found",
+			req:          &csi.ValidateVolumeCapabilitiesRequest{VolumeId: "vol-test", VolumeCapabilities: singleWriter},
+			diskErr:      cloud.ErrNotFound,
+			expectLookup: true,
+			expCode:      codes.NotFound,
+		},
+		{
+			name:         "fail cloud unavailable",
+			req:          &csi.ValidateVolumeCapabilitiesRequest{VolumeId: "vol-test", VolumeCapabilities: singleWriter},
+			diskErr:      cloud.ErrCircuitOpen,
+			expectLookup: true,
+			expCode:      codes.Unavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			if tc.expectLookup {
+				var disk *cloud.Disk
+				if tc.diskErr == nil {
+					disk = &cloud.Disk{VolumeID: tc.req.VolumeId, DiskType: "TIER1", WWN: "600507681081018c9000000000000001", CapacityGiB: 10, Shareable: tc.shareable}
+				}
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(tc.req.VolumeId)).Return(disk, tc.diskErr)
+			}
+			powervsDriver := controllerService{
+				cloud:         mockCloud,
+				driverOptions: &Options{},
+				volumeLocks:   util.NewVolumeLocks(),
+				accessTypes:   newAccessTypes(),
+			}
+			if tc.publishedAs != nil {
+				powervsDriver.accessTypes.record(tc.req.VolumeId, tc.publishedAs)
+			}
+
+			resp, err := powervsDriver.ValidateVolumeCapabilities(context.Background(), tc.req)
+			if status.Code(err) != tc.expCode {
+				t.Fatalf("Expected code %v, got: %v", tc.expCode, err)
+			}
+			if err == nil && (resp.GetConfirmed() != nil) != tc.expConfirmed {
+				t.Fatalf("Expected confirmed %v, got: %+v", tc.expConfirmed, resp.GetConfirmed())
+			}
+			if confirmed := resp.GetConfirmed(); confirmed != nil {
+				// the context of static PVs is completed with the attributes of the volume
+				volumeContext := confirmed.GetVolumeContext()
+				if volumeContext[WWNKey] != "600507681081018c9000000000000001" || volumeContext[IOPSKey] != "100" {
+					t.Fatalf("Expected volume context with WWN and IOPS of the volume, got: %v", volumeContext)
+				}
+			}
+			if err == nil && !tc.expConfirmed && resp.GetMessage() == "" {
+				t.Fatalf("Expected a message why the capabilities aren't confirmed")
+			}
+		})
+	}
+}
+
+func TestListVolumes(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	clouds := map[string]*mocks.MockCloud{
+		"ws-1": mocks.NewMockCloud(mockCtl),
+		"ws-2": mocks.NewMockCloud(mockCtl),
+	}
+	clouds["ws-1"].EXPECT().ListDisks(gomock.Any()).Return([]*cloud.Disk{
+		{VolumeID: "vol-2", CapacityGiB: 10, State: cloud.VolumeInUseState, AttachedTo: []string{"node-1"}},
+		{VolumeID: "vol-1", CapacityGiB: 10, State: cloud.VolumeAvailableState},
+	}, nil).AnyTimes()
+	clouds["ws-2"].EXPECT().ListDisks(gomock.Any()).Return([]*cloud.Disk{
+		{VolumeID: "vol-3", CapacityGiB: 10, State: cloud.VolumeErrorState},
+	}, nil).AnyTimes()
+	powervsDriver := controllerService{
+		cloud:         clouds["ws-1"],
+		driverOptions: &Options{},
+		workspaces: cloud.NewWorkspaces("ws-1", clouds["ws-1"], []string{"ws-2"}, func(id string) (cloud.Cloud, error) {
+			return clouds[id], nil
+		}),
+	}
+
+	var handles []string
+	token := ""
+	for {
+		resp, err := powervsDriver.ListVolumes(context.Background(), &csi.ListVolumesRequest{MaxEntries: 2, StartingToken: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range resp.Entries {
+			handles = append(handles, e.Volume.VolumeId)
+			switch e.Volume.VolumeId {
+			case "vol-2":
+				if !reflect.DeepEqual(e.Status.PublishedNodeIds, []string{"node-1"}) {
+					t.Fatalf("expected vol-2 published to node-1, got %v", e.Status.PublishedNodeIds)
+				}
+			case "ws-2/vol-3":
+				if !e.Status.VolumeCondition.Abnormal {
+					t.Fatalf("expected abnormal condition of a volume in state error")
+				}
+			}
+		}
+		if token = resp.NextToken; token == "" {
+			break
+		}
+	}
+	if expected := []string{"vol-1", "vol-2", "ws-2/vol-3"}; !reflect.DeepEqual(handles, expected) {
+		t.Fatalf("expected volumes %v, got %v", expected, handles)
+	}
+
+	if _, err := powervsDriver.ListVolumes(context.Background(), &csi.ListVolumesRequest{StartingToken: "invalid-token"}); status.Code(err) != codes.Aborted {
+		t.Fatalf("expected Aborted for an invalid token, got: %v", err)
+	}
+}
+
+func TestControllerGetVolume(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	mockCloud := mocks.NewMockCloud(mockCtl)
+	mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq("vol-1")).Return(&cloud.Disk{VolumeID: "vol-1", CapacityGiB: 10, State: cloud.VolumeInUseState, AttachedTo: []string{"node-1"}}, nil)
+	mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq("vol-2")).Return(nil, cloud.ErrNotFound)
+	powervsDriver := controllerService{cloud: mockCloud, driverOptions: &Options{}}
+
+	resp, err := powervsDriver.ControllerGetVolume(context.Background(), &csi.ControllerGetVolumeRequest{VolumeId: "vol-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Volume.VolumeId != "vol-1" || !reflect.DeepEqual(resp.Status.PublishedNodeIds, []string{"node-1"}) || resp.Status.VolumeCondition.Abnormal {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if _, err := powervsDriver.ControllerGetVolume(context.Background(), &csi.ControllerGetVolumeRequest{VolumeId: "vol-2"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got: %v", err)
+	}
+}
+
+func TestControllerUnimplemented(t *testing.T) {
+	powervsDriver := controllerService{driverOptions: &Options{}}
+	ctx := context.Background()
+	calls := map[string]func() error{
+		"CreateSnapshot": func() error {
+			_, err := powervsDriver.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{})
+			return err
+		},
+		"DeleteSnapshot": func() error {
+			_, err := powervsDriver.DeleteSnapshot(ctx, &csi.DeleteSnapshotRequest{})
+			return err
+		},
+		"ListSnapshots": func() error {
+			_, err := powervsDriver.ListSnapshots(ctx, &csi.ListSnapshotsRequest{})
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			if err := call(); status.Code(err) != codes.Unimplemented {
+				t.Fatalf("Expected Unimplemented, got: %v", err)
+			}
+		})
+	}
+}
+
+// TestControllerCloudErrors checks the gRPC codes of the cloud errors returned by the RPCs
+func TestControllerCloudErrors(t *testing.T) {
+	volCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+	cloudErrors := []struct {
+		name    string
+		err     error
+		expCode codes.Code
+	}{
+		{name: "throttled", err: errors.New("[POST /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes][429] too many requests"), expCode: codes.Unavailable},
+		{name: "circuit open", err: cloud.ErrCircuitOpen, expCode: codes.Unavailable},
+		{name: "forbidden", err: errors.New("[POST /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes][403] forbidden"), expCode: codes.PermissionDenied},
+		{name: "bad request", err: errors.New("[POST /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes][400] bad request"), expCode: codes.InvalidArgument},
+		{name: "unclassified", err: errors.New("unexpected response"), expCode: codes.Internal},
+	}
+	rpcs := []struct {
+		name   string
+		expect func(m *mocks.MockCloud, err error)
+		call   func(d *controllerService) error
+	}{
+		{
+			name: "CreateVolume",
+			expect: func(m *mocks.MockCloud, err error) {
+				m.EXPECT().GetDiskByName(gomock.Any(), gomock.Any()).Return(nil, nil)
+				m.EXPECT().CreateDisk(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, err)
+			},
+			call: func(d *controllerService) error {
+				_, err := d.CreateVolume(context.Background(), &csi.CreateVolumeRequest{Name: "vol-test", VolumeCapabilities: []*csi.VolumeCapability{volCap}})
+				return err
+			},
+		},
+		{
+			name: "DeleteVolume",
+			expect: func(m *mocks.MockCloud, err error) {
+				m.EXPECT().GetDiskByID(gomock.Any(), gomock.Any()).Return(&cloud.Disk{}, nil)
+				m.EXPECT().DeleteDisk(gomock.Any(), gomock.Any()).Return(false, err)
+			},
+			call: func(d *controllerService) error {
+				_, err := d.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "vol-test"})
+				return err
+			},
+		},
+		{
+			name: "ControllerPublishVolume",
+			expect: func(m *mocks.MockCloud, err error) {
+				m.EXPECT().GetPVMInstanceByID(gomock.Any(), gomock.Any()).Return(&cloud.PVMInstance{}, nil)
+				m.EXPECT().GetDiskByID(gomock.Any(), gomock.Any()).Return(&cloud.Disk{WWN: "600507681081018c9000000000000001"}, nil)
+				m.EXPECT().IsAttached(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil)
+				m.EXPECT().ListDisks(gomock.Any()).Return(nil, nil)
+				m.EXPECT().AttachDisk(gomock.Any(), gomock.Any(), gomock.Any()).Return(err)
+			},
+			call: func(d *controllerService) error {
+				_, err := d.ControllerPublishVolume(context.Background(), &csi.ControllerPublishVolumeRequest{VolumeId: "vol-test", NodeId: expInstanceID, VolumeCapability: volCap})
+				return err
+			},
+		},
+		{
+			name: "ControllerUnpublishVolume",
+			expect: func(m *mocks.MockCloud, err error) {
+				m.EXPECT().GetDiskByID(gomock.Any(), gomock.Any()).Return(&cloud.Disk{}, nil)
+				m.EXPECT().IsAttached(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+				m.EXPECT().DetachDisk(gomock.Any(), gomock.Any(), gomock.Any()).Return(err)
+			},
+			call: func(d *controllerService) error {
+				_, err := d.ControllerUnpublishVolume(context.Background(), &csi.ControllerUnpublishVolumeRequest{VolumeId: "vol-test", NodeId: expInstanceID})
+				return err
+			},
+		},
+		{
+			name: "ControllerExpandVolume",
+			expect: func(m *mocks.MockCloud, err error) {
+				m.EXPECT().GetDiskByID(gomock.Any(), gomock.Any()).Return(&cloud.Disk{State: cloud.VolumeAvailableState}, nil)
+				m.EXPECT().ResizeDisk(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(0), err)
+			},
+			call: func(d *controllerService) error {
+				_, err := d.ControllerExpandVolume(context.Background(), &csi.ControllerExpandVolumeRequest{VolumeId: "vol-test", CapacityRange: &csi.CapacityRange{RequiredBytes: 5 * util.GiB}})
+				return err
+			},
+		},
+	}
+
+	for _, rpc := range rpcs {
+		for _, ce := range cloudErrors {
+			t.Run(rpc.name+" "+ce.name, func(t *testing.T) {
+				mockCtl := gomock.NewController(t)
+				defer mockCtl.Finish()
+
+				mockCloud := mocks.NewMockCloud(mockCtl)
+				rpc.expect(mockCloud, ce.err)
+				powervsDriver := &controllerService{
+					cloud:         mockCloud,
+					driverOptions: &Options{},
+					volumeLocks:   util.NewVolumeLocks(),
+					nodeQueues:    newNodeQueues(),
+				}
+				if err := rpc.call(powervsDriver); status.Code(err) != ce.expCode {
+					t.Fatalf("Expected code %v, got: %v", ce.expCode, err)
+				}
+			})
+		}
+	}
+}
+
+// TestControllerConcurrentOperations checks that a second operation on a volume is aborted
+// while the first one is in progress
+func TestControllerConcurrentOperations(t *testing.T) {
+	volCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+	ctx := context.Background()
+	calls := map[string]func(d *controllerService) error{
+		"CreateVolume": func(d *controllerService) error {
+			_, err := d.CreateVolume(ctx, &csi.CreateVolumeRequest{Name: "vol-test", VolumeCapabilities: []*csi.VolumeCapability{volCap}})
+			return err
+		},
+		"DeleteVolume": func(d *controllerService) error {
+			_, err := d.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: "vol-test"})
+			return err
+		},
+		"ControllerPublishVolume": func(d *controllerService) error {
+			_, err := d.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{VolumeId: "vol-test", NodeId: expInstanceID, VolumeCapability: volCap})
+			return err
+		},
+		"ControllerUnpublishVolume": func(d *controllerService) error {
+			_, err := d.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{VolumeId: "vol-test", NodeId: expInstanceID})
+			return err
+		},
+		"ControllerExpandVolume": func(d *controllerService) error {
+			_, err := d.ControllerExpandVolume(ctx, &csi.ControllerExpandVolumeRequest{VolumeId: "vol-test", CapacityRange: &csi.CapacityRange{RequiredBytes: util.GiB}})
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			powervsDriver := &controllerService{
+				cloud:         mocks.NewMockCloud(mockCtl),
+				driverOptions: &Options{},
+				volumeLocks:   util.NewVolumeLocks(),
+			}
+			powervsDriver.volumeLocks.TryAcquire("vol-test")
+			if err := call(powervsDriver); status.Code(err) != codes.Aborted {
+				t.Fatalf("Expected Aborted, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateVolumeIncompatibleExisting(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	mockCloud := mocks.NewMockCloud(mockCtl)
+	mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Eq("vol-test")).Return(&cloud.Disk{VolumeID: "vol-test", CapacityGiB: 10}, nil)
+	powervsDriver := controllerService{
+		cloud:         mockCloud,
+		driverOptions: &Options{},
+		volumeLocks:   util.NewVolumeLocks(),
+	}
+	req := &csi.CreateVolumeRequest{
+		Name:          "vol-test",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 5 * util.GiB},
+		VolumeCapabilities: []*csi.VolumeCapability{{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		}},
+	}
+	if _, err := powervsDriver.CreateVolume(context.Background(), req); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("Expected AlreadyExists for an existing volume with a different capacity, got: %v", err)
+	}
+}
+
+func TestVerifyVolumeDetails(t *testing.T) {
+	disk := &cloud.Disk{VolumeID: "vol-test", DiskType: cloud.VolumeTypeTier3, CapacityGiB: 10}
+	testCases := []struct {
+		name      string
+		payload   *cloud.DiskOptions
+		expectErr bool
+	}{
+		{
+			name:    "matching volume",
+			payload: &cloud.DiskOptions{VolumeType: cloud.VolumeTypeTier3, CapacityBytes: 10 * util.GiB},
+		},
+		{
+			name:    "volume of the default type",
+			payload: &cloud.DiskOptions{CapacityBytes: 10 * util.GiB},
+		},
+		{
+			name:      "different type",
+			payload:   &cloud.DiskOptions{VolumeType: cloud.VolumeTypeTier1, CapacityBytes: 10 * util.GiB},
+			expectErr: true,
+		},
+		{
+			name:      "shareable",
+			payload:   &cloud.DiskOptions{Shareable: true, CapacityBytes: 10 * util.GiB},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyVolumeDetails(tc.payload, disk)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("Expected error %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestVolumeSizeBytes(t *testing.T) {
+	testCases := []struct {
+		name        string
+		capRange    *csi.CapacityRange
+		defaultSize int64
+		expSize     int64
+		expCode     codes.Code
+	}{
+		{name: "default size", defaultSize: cloud.DefaultVolumeSize, expSize: cloud.DefaultVolumeSize},
+		{name: "default size capped by limit", capRange: &csi.CapacityRange{LimitBytes: 5*util.GiB + 1}, defaultSize: cloud.DefaultVolumeSize, expSize: 5 * util.GiB},
+		{name: "required size mandatory", capRange: &csi.CapacityRange{LimitBytes: 5 * util.GiB}, expCode: codes.InvalidArgument},
+		{name: "round up", capRange: &csi.CapacityRange{RequiredBytes: 5*util.GiB + 1}, expSize: 6 * util.GiB},
+		{name: "below minimum", capRange: &csi.CapacityRange{RequiredBytes: 1}, expSize: cloud.MinVolumeSize},
+		{name: "maximum", capRange: &csi.CapacityRange{RequiredBytes: cloud.MaxVolumeSize}, expSize: cloud.MaxVolumeSize},
+		{name: "above maximum", capRange: &csi.CapacityRange{RequiredBytes: cloud.MaxVolumeSize + 1}, expCode: codes.OutOfRange},
+		{name: "exceeds limit after round up", capRange: &csi.CapacityRange{RequiredBytes: 5*util.GiB + 1, LimitBytes: 5 * util.GiB}, expCode: codes.OutOfRange},
+		{name: "limit below minimum", capRange: &csi.CapacityRange{LimitBytes: util.GiB - 1}, defaultSize: cloud.DefaultVolumeSize, expCode: codes.OutOfRange},
+		{name: "negative", capRange: &csi.CapacityRange{RequiredBytes: -1}, expCode: codes.InvalidArgument},
+		{name: "overflowing round up", capRange: &csi.CapacityRange{RequiredBytes: math.MaxInt64}, expCode: codes.OutOfRange},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			size, err := volumeSizeBytes(tc.capRange, tc.defaultSize)
+			if status.Code(err) != tc.expCode {
+				t.Fatalf("Expected code %v, got: %v", tc.expCode, err)
+			}
+			if size != tc.expSize {
+				t.Fatalf("Expected size %d, got %d", tc.expSize, size)
+			}
+		})
+	}
+}
+
+func TestCreateVolumeIOPS(t *testing.T) {
+	testCases := []struct {
+		name       string
+		parameters map[string]string
+		sizeGiB    int64
+		expCode    codes.Code
+		expIOPS    string
+	}{
+		{name: "default tier", sizeGiB: 20, expIOPS: "200"},
+		{name: "scaling tier", parameters: map[string]string{VolumeTypeKey: cloud.VolumeTypeTier3, IOPSParameterKey: "300"}, sizeGiB: 100, expIOPS: "300"},
+		{name: "fixed IOPS tier", parameters: map[string]string{VolumeTypeKey: cloud.VolumeTypeTier5k, IOPSParameterKey: "5000"}, sizeGiB: 10, expIOPS: "5000"},
+		{name: "too small for the IOPS", parameters: map[string]string{IOPSParameterKey: "500"}, sizeGiB: 20, expCode: codes.InvalidArgument},
+		{name: "invalid IOPS", parameters: map[string]string{IOPSParameterKey: "-1"}, sizeGiB: 20, expCode: codes.InvalidArgument},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			if tc.expCode == codes.OK {
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Eq("vol-test")).Return(nil, nil)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Eq("vol-test"), gomock.Any()).DoAndReturn(func(ctx context.Context, name string, opts *cloud.DiskOptions) (*cloud.Disk, error) {
+					diskType := opts.VolumeType
+					if diskType == "" {
+						diskType = cloud.DefaultVolumeType
+					}
+					return &cloud.Disk{VolumeID: name, DiskType: diskType, CapacityGiB: util.BytesToGiB(opts.CapacityBytes)}, nil
+				})
+			}
+			powervsDriver := controllerService{
+				cloud:         mockCloud,
+				driverOptions: &Options{},
+				volumeLocks:   util.NewVolumeLocks(),
+			}
+			req := &csi.CreateVolumeRequest{
+				Name:          "vol-test",
+				CapacityRange: &csi.CapacityRange{RequiredBytes: tc.sizeGiB * util.GiB},
+				VolumeCapabilities: []*csi.VolumeCapability{{
+					AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+					AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+				}},
+				Parameters: tc.parameters,
+			}
+			resp, err := powervsDriver.CreateVolume(context.Background(), req)
+			if status.Code(err) != tc.expCode {
+				t.Fatalf("Expected code %v, got: %v", tc.expCode, err)
+			}
+			if err != nil {
+				return
+			}
+			if iops := resp.Volume.VolumeContext[IOPSKey]; iops != tc.expIOPS {
+				t.Fatalf("Expected %s IOPS in the volume context, got %q", tc.expIOPS, iops)
+			}
+		})
+	}
+}
+
+// TestCreateVolumeLookupErrors checks that no volume is created unless the lookup by name
+// found none, a failed or ambiguous lookup could otherwise duplicate the volume
+func TestCreateVolumeLookupErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		err     error
+		expCode codes.Code
+	}{
+		{name: "lookup failed", err: errors.New("[GET /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes][500] internal server error"), expCode: codes.Unavailable},
+		{name: "duplicate name", err: fmt.Errorf("%w %q", cloud.ErrDuplicateName, "vol-test"), expCode: codes.FailedPrecondition},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Eq("vol-test")).Return(nil, tc.err)
+			mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			powervsDriver := controllerService{
+				cloud:         mockCloud,
+				driverOptions: &Options{},
+				volumeLocks:   util.NewVolumeLocks(),
+			}
+			req := &csi.CreateVolumeRequest{
+				Name: "vol-test",
+				VolumeCapabilities: []*csi.VolumeCapability{{
+					AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+					AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+				}},
+			}
+			if _, err := powervsDriver.CreateVolume(context.Background(), req); status.Code(err) != tc.expCode {
+				t.Fatalf("Expected code %v, got: %v", tc.expCode, err)
+			}
+		})
+	}
+}
+
+// TestCreateVolumeFaults runs CreateVolume against the fake cloud provider with injected
+// faults, the retries of the CO must neither fail nor create a second volume
+func TestCreateVolumeFaults(t *testing.T) {
+	req := &csi.CreateVolumeRequest{
+		Name:          "vol-test",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: util.GiB},
+		VolumeCapabilities: []*csi.VolumeCapability{{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		}},
+		// the retries compare the type of the existing volume with the requested one
+		Parameters: map[string]string{VolumeTypeKey: cloud.VolumeTypeTier3},
+	}
+	newController := func(fakeCloud *fake.Cloud) *controllerService {
+		return &controllerService{
+			cloud:         fakeCloud,
+			driverOptions: &Options{},
+			volumeLocks:   util.NewVolumeLocks(),
+		}
+	}
+
+	t.Run("transient failure", func(t *testing.T) {
+		fakeCloud := fake.NewCloud(0)
+		fakeCloud.FailOnCall("CreateDisk", 1, fmt.Errorf("create failed: %w", cloud.ErrCircuitOpen))
+		d := newController(fakeCloud)
+
+		if _, err := d.CreateVolume(context.Background(), req); status.Code(err) != codes.Unavailable {
+			t.Fatalf("Expected Unavailable, got: %v", err)
+		}
+		if _, err := d.CreateVolume(context.Background(), req); err != nil {
+			t.Fatalf("Unexpected error on retry: %v", err)
+		}
+		if fakeCloud.DiskCount() != 1 {
+			t.Fatalf("Expected 1 volume, got %d", fakeCloud.DiskCount())
+		}
+		if n := fakeCloud.CallCount("CreateDisk"); n != 2 {
+			t.Fatalf("Expected 2 calls of CreateDisk, got %d", n)
+		}
+	})
+
+	t.Run("stuck in creating", func(t *testing.T) {
+		fakeCloud := fake.NewCloud(0)
+		fakeCloud.SetStuckCreating(true)
+		d := newController(fakeCloud)
+
+		if _, err := d.CreateVolume(context.Background(), req); status.Code(err) != codes.DeadlineExceeded {
+			t.Fatalf("Expected DeadlineExceeded, got: %v", err)
+		}
+		// the retry finds the volume of the first call and waits for it again
+		if _, err := d.CreateVolume(context.Background(), req); status.Code(err) != codes.DeadlineExceeded {
+			t.Fatalf("Expected DeadlineExceeded for the stuck volume, got: %v", err)
+		}
+		fakeCloud.SetStuckCreating(false)
+		resp, err := d.CreateVolume(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Unexpected error once the volume is available: %v", err)
+		}
+		disk, err := fakeCloud.GetDiskByName(context.Background(), req.Name)
+		if err != nil {
+			t.Fatalf("Unexpected error getting the volume: %v", err)
+		}
+		if resp.Volume.VolumeId != disk.VolumeID {
+			t.Fatalf("Expected volume %q, got %q", disk.VolumeID, resp.Volume.VolumeId)
+		}
+		if n := fakeCloud.CallCount("CreateDisk"); n != 1 {
+			t.Fatalf("Expected 1 call of CreateDisk, got %d", n)
+		}
+	})
+
+	t.Run("slow API", func(t *testing.T) {
+		fakeCloud := fake.NewCloud(0)
+		fakeCloud.AddLatency("CreateDisk", time.Minute)
+		d := newController(fakeCloud)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := d.CreateVolume(ctx, req); status.Code(err) != codes.DeadlineExceeded {
+			t.Fatalf("Expected DeadlineExceeded, got: %v", err)
+		}
+		if fakeCloud.DiskCount() != 0 {
+			t.Fatalf("Expected no volume, got %d", fakeCloud.DiskCount())
+		}
+	})
+}
+
+func TestVolumeLockTimeout(t *testing.T) {
+	req := &csi.DeleteVolumeRequest{VolumeId: "vol-test"}
+	newController := func(fakeCloud *fake.Cloud, timeout time.Duration) *controllerService {
+		return &controllerService{
+			cloud:         fakeCloud,
+			driverOptions: &Options{volumeLockTimeout: timeout},
+			volumeLocks:   util.NewVolumeLocks(),
+		}
+	}
+
+	t.Run("released in time", func(t *testing.T) {
+		d := newController(fake.NewCloud(0), 10*time.Second)
+		d.volumeLocks.TryAcquire(req.VolumeId)
+		time.AfterFunc(10*time.Millisecond, func() {
+			d.volumeLocks.Release(req.VolumeId)
+		})
+		if _, err := d.DeleteVolume(context.Background(), req); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("aborted with retry hint", func(t *testing.T) {
+		d := newController(fake.NewCloud(0), 10*time.Millisecond)
+		d.volumeLocks.TryAcquire(req.VolumeId)
+		_, err := d.DeleteVolume(context.Background(), req)
+		st := status.Convert(err)
+		if st.Code() != codes.Aborted {
+			t.Fatalf("Expected Aborted, got: %v", err)
+		}
+		if len(st.Details()) != 1 {
+			t.Fatalf("Expected a retry hint, got details %v", st.Details())
+		}
+	})
+
+	t.Run("deadline of the RPC", func(t *testing.T) {
+		d := newController(fake.NewCloud(0), time.Minute)
+		d.volumeLocks.TryAcquire(req.VolumeId)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := d.DeleteVolume(ctx, req); status.Code(err) != codes.Aborted {
+			t.Fatalf("Expected Aborted once the deadline expired, got: %v", err)
+		}
+	})
 }
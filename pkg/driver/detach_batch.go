@@ -0,0 +1,65 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// detachDisk detaches the disk of ID diskID from the pvm instance nodeID, in a batch with the
+// disks of workspace detached from the node meanwhile when batching is enabled. Errors of the node queue are
+// status errors, cloud errors are returned as is.
+func (d *controllerService) detachDisk(ctx context.Context, c cloud.Cloud, workspace, diskID, nodeID string) error {
+	if d.detachBatches == nil {
+		return d.detachDisks(ctx, c, []string{diskID}, nodeID)[0]
+	}
+	result := d.detachBatches.add(nodeBatchKey{workspace: workspace, nodeID: nodeID}, diskID, nil, func(diskIDs []string, _ []*cloud.Disk) []error {
+		// the batch outlives the request that started it
+		return d.detachDisks(context.Background(), c, diskIDs, nodeID)
+	})
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// detachDisks detaches the disks of IDs diskIDs from the pvm instance nodeID in its node queue
+// and returns their errors, all at once if the cloud supports it
+func (d *controllerService) detachDisks(ctx context.Context, c cloud.Cloud, diskIDs []string, nodeID string) []error {
+	errs := make([]error, len(diskIDs))
+	if err := d.nodeQueues.acquire(ctx, nodeID, nodeOperationDetach); err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	defer d.nodeQueues.release(nodeID)
+
+	if bulk, ok := c.(cloud.BulkDetacher); ok && len(diskIDs) > 1 {
+		klog.V(4).Infof("Detaching volumes %v from node %s", diskIDs, nodeID)
+		return bulk.DetachDisks(ctx, diskIDs, nodeID)
+	}
+	for i, diskID := range diskIDs {
+		errs[i] = c.DetachDisk(ctx, diskID, nodeID)
+	}
+	return errs
+}
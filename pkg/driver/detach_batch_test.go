@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
+)
+
+func attachFakeDisks(t *testing.T, c *fake.Cloud, n int) []*cloud.Disk {
+	disks := createFakeDisks(t, c, n)
+	for _, disk := range disks {
+		if err := c.AttachDisk(context.Background(), disk.VolumeID, expInstanceID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return disks
+}
+
+func TestControllerUnpublishVolumeBatched(t *testing.T) {
+	fakeCloud := fake.NewCloud(0)
+	d := newBatchingControllerService(fakeCloud, 0)
+	d.detachBatches = newNodeBatches(100 * time.Millisecond)
+	disks := attachFakeDisks(t, fakeCloud, 5)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(disks))
+	for i, disk := range disks {
+		wg.Add(1)
+		go func(i int, volumeID string) {
+			defer wg.Done()
+			_, errs[i] = d.ControllerUnpublishVolume(context.Background(), &csi.ControllerUnpublishVolumeRequest{VolumeId: volumeID, NodeId: expInstanceID})
+		}(i, disk.VolumeID)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Unexpected error unpublishing volume %d: %v", i, err)
+		}
+	}
+	if n := fakeCloud.CallCount("DetachDisks"); n != 1 {
+		t.Fatalf("Expected the volumes to be detached in 1 batch, got %d", n)
+	}
+	if n := fakeCloud.CallCount("DetachDisk"); n != 0 {
+		t.Fatalf("Expected no single detach, got %d", n)
+	}
+	for _, disk := range disks {
+		if attached, _ := fakeCloud.IsAttached(context.Background(), disk.VolumeID, expInstanceID); attached {
+			t.Fatalf("Expected volume %s to be detached", disk.VolumeID)
+		}
+	}
+}
+
+func TestDetachDisks(t *testing.T) {
+	testCases := []struct {
+		name      string
+		disks     int
+		bulkErr   error
+		expBulk   int
+		expSingle int
+	}{
+		{
+			name:      "single disk",
+			disks:     1,
+			expSingle: 1,
+		},
+		{
+			name:    "bulk",
+			disks:   3,
+			expBulk: 1,
+		},
+		{
+			name:    "bulk failure",
+			disks:   3,
+			bulkErr: errors.New("detach failed"),
+			expBulk: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeCloud := fake.NewCloud(0)
+			d := newBatchingControllerService(fakeCloud, 0)
+			disks := attachFakeDisks(t, fakeCloud, tc.disks)
+			if tc.bulkErr != nil {
+				fakeCloud.FailOnCall("DetachDisks", 1, tc.bulkErr)
+			}
+			var diskIDs []string
+			for _, disk := range disks {
+				diskIDs = append(diskIDs, disk.VolumeID)
+			}
+
+			for i, err := range d.detachDisks(context.Background(), fakeCloud, diskIDs, expInstanceID) {
+				if !errors.Is(err, tc.bulkErr) {
+					t.Fatalf("Expected error %v for volume %d, got %v", tc.bulkErr, i, err)
+				}
+				attached, _ := fakeCloud.IsAttached(context.Background(), diskIDs[i], expInstanceID)
+				if attached != (tc.bulkErr != nil) {
+					t.Fatalf("Expected volume %d attached %v, got %v", i, tc.bulkErr != nil, attached)
+				}
+			}
+			if n := fakeCloud.CallCount("DetachDisks"); n != tc.expBulk {
+				t.Fatalf("Expected %d bulk detaches, got %d", tc.expBulk, n)
+			}
+			if n := fakeCloud.CallCount("DetachDisk"); n != tc.expSingle {
+				t.Fatalf("Expected %d single detaches, got %d", tc.expSingle, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package driver implements the CSI services of the IBM PowerVS block storage driver.
+
+The exported API of this package is stable and follows semantic versioning: Driver,
+NewDriver, the Mode constants, the With* options and the exported constants only change in
+backwards compatible ways within a major version. Unexported identifiers and the
+NewPowerVSCloudFunc variable are implementation details.
+
+A Driver is created with NewDriver and either serves the CSI endpoint itself with Run, or
+is registered on an existing gRPC server with Register:
+
+	drv, err := driver.NewDriver(
+		driver.WithMode(driver.ControllerMode),
+		driver.WithCloud(myCloud),
+	)
+	if err != nil {
+		return err
+	}
+	if err := drv.Register(grpcServer); err != nil {
+		return err
+	}
+
+WithCloud replaces the PowerVS client with any cloud.Cloud implementation, e.g. one wrapping
+the client returned by cloud.NewPowerVSCloud.
+*/
+package driver
@@ -22,10 +22,17 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/tracing"
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
 )
 
@@ -42,35 +49,189 @@ const (
 )
 
 const (
-	DriverName  = "powervs.csi.ibm.com"
+	DriverName = "powervs.csi.ibm.com"
+	// DiskTypeKey is the storage tier of the boot image of a node, one of cloud.ValidVolumeTypes
 	DiskTypeKey = "topology." + DriverName + "/disk-type"
 
 	TopologyKey = "topology." + DriverName + "/region"
+	// WorkspaceTopologyKey is the cloud instance ID of the PowerVS workspace of a node
+	WorkspaceTopologyKey = "topology." + DriverName + "/workspace"
+	// StoragePoolTopologyKey is the PowerVS storage pool of a topology segment, GetCapacity
+	// reports the storage available in the pool of the segment
+	StoragePoolTopologyKey = "topology." + DriverName + "/storage-pool"
 )
 
+// Driver implements the CSI identity, controller and node services of the PowerVS block
+// storage driver, depending on its Mode
 type Driver struct {
+	csi.UnimplementedIdentityServer
 	controllerService
 	nodeService
 
 	srv     *grpc.Server
 	options *Options
+	// serving is 1 while the gRPC server serves, read by the health probes
+	serving int32
+	// requestLogLevel is the current klog.Level of the request log lines, see Reconfigure
+	requestLogLevel int32
+	// slowOperationThreshold is the current threshold of the slow request watchdog in
+	// nanoseconds, see Reconfigure
+	slowOperationThreshold int64
+	// leader is 1 while the controller runs the leader loops, read by the health probes
+	leader int32
+	// stopLeaderElection releases the controller lease acquired by Run
+	stopLeaderElection func()
+	// stopInformers stops the informers of the controller started by Run
+	stopInformers func()
+	// draining is 1 once Stop is called, new RPCs are refused from then on
+	draining int32
+	// stopped is closed when Stop has drained the gRPC server, Run returns after it
+	stopped  chan struct{}
+	stopOnce sync.Once
+	// stopTracing flushes and stops the span exporter set up by Run
+	stopTracing func(context.Context) error
 }
 
+var (
+	_ csi.IdentityServer        = &Driver{}
+	_ csi.ControllerServer      = &Driver{}
+	_ csi.NodeServer            = &Driver{}
+	_ csi.GroupControllerServer = &Driver{}
+)
+
+// Options are the settings of the Driver, set through the With* functions passed to NewDriver
 type Options struct {
-	endpoint            string
-	extraTags           map[string]string
-	mode                Mode
-	volumeAttachLimit   int64
+	endpoint string
+	// tlsCertFile and tlsKeyFile enable TLS on a tcp endpoint, tlsClientCAFile additionally
+	// requires client certificates signed by one of its CAs
+	tlsCertFile     string
+	tlsKeyFile      string
+	tlsClientCAFile string
+	// httpEndpoint is the address the metrics and health probes are served on, empty
+	// disables the HTTP server
+	httpEndpoint string
+	// grpcServer tunes the keepalive, concurrency and connection limits of the gRPC server
+	grpcServer GRPCServerOptions
+	// socket sets the permissions and ownership of a unix endpoint
+	socket            SocketOptions
+	extraTags         map[string]string
+	mode              Mode
+	volumeAttachLimit int64
+	// volumeStats enables NodeGetVolumeStats
+	volumeStats bool
+	// volumeStatsCacheTTL is how long the node caches the stats of a volume, 0 disables the cache
+	volumeStatsCacheTTL time.Duration
+	// fsckPolicy is how the node checks filesystems before mounting them, unless the volume
+	// context sets FsckPolicyKey
+	fsckPolicy FsckPolicy
+	// ext4FormatOptions are the mkfs options of ext filesystems, unless the volume context sets
+	// FormatOptionsKey
+	ext4FormatOptions string
+	// storagePool is the storage pool the node has affinity to, reported in its topology with
+	// StoragePoolTopology
+	storagePool string
+	// staleDeviceCleanup unmounts and removes the devices of volumes detached from the node at
+	// startup
+	staleDeviceCleanup  bool
 	kubernetesClusterID string
 	debug               bool
+	// tracing exports spans of the CSI requests to the OTLP collector set in the environment
+	tracing bool
+	// requestLogLevel is the klog verbosity of the CSI request and response log lines
+	requestLogLevel klog.Level
+	// shutdownTimeout is how long Stop waits for in-flight RPCs before canceling them
+	shutdownTimeout time.Duration
+	// slowOperationThreshold is the duration above which CSI requests and cloud calls are
+	// logged and counted as slow, 0 disables the watchdog
+	slowOperationThreshold time.Duration
+	// volumeLockTimeout is how long an RPC waits for the operation in progress on its volume
+	// before it's aborted, 0 aborts it right away
+	volumeLockTimeout time.Duration
+	// tierMigrationInterval is the resync period of the tier migration reconciler, 0 disables it
+	tierMigrationInterval time.Duration
+	// tierMigrationTimeout is the time a tier migration may take before it fails
+	tierMigrationTimeout time.Duration
+	// tagReconcileInterval is the resync period of the tag reconciler, 0 disables it
+	tagReconcileInterval time.Duration
+	// leaderElection runs the background loops of the controller only on the replica holding
+	// the controller lease in leaderElectionNamespace, the namespace of the pod when empty
+	leaderElection          bool
+	leaderElectionNamespace string
+	// legacyVolumeHandles makes the controller accept the volume handles of pre-CSI PVs
+	legacyVolumeHandles bool
+
+	// events makes the controller emit events on the PVCs and PVs of failing volumes
+	events bool
+	// apiEndpoints are the PowerVS API endpoints the cloud client fails over between
+	apiEndpoints []string
+	// serviceEndpoints override the endpoints of IAM and the other IBM Cloud services
+	serviceEndpoints cloud.ServiceEndpoints
+	// apiKeyFile is a file holding the IBM Cloud API key, watched for rotation
+	apiKeyFile string
+	// authType is one of cloud.AuthTypes, trustedProfileID or trustedProfileName select the
+	// trusted profile and crTokenFile its compute resource token for cloud.AuthTypeTrustedProfile
+	authType           string
+	trustedProfileID   string
+	trustedProfileName string
+	crTokenFile        string
+	// volumeStateTimeout and volumeStatePollInterval tune waiting for volume state changes,
+	// the cloud defaults are used when 0
+	volumeStateTimeout      time.Duration
+	volumeStatePollInterval time.Duration
+	// operationTimeouts bound the PowerVS jobs of single operations, the ones without a
+	// timeout wait for the volume state timeout
+	operationTimeouts map[cloud.Operation]time.Duration
+	// apiRetryInitialDelay and apiRetrySteps tune retries of PowerVS API errors, the cloud
+	// defaults are used when 0
+	apiRetryInitialDelay time.Duration
+	apiRetrySteps        int
+	// apiCallTimeout bounds every PowerVS and IAM HTTP request, the cloud defaults for reads
+	// and changes are used when 0
+	apiCallTimeout time.Duration
+	// cloudInstanceIDs are the PowerVS workspaces the controller manages volumes in next to
+	// the one of its node
+	cloudInstanceIDs []string
+	// cloud replaces the PowerVS cloud client created from the node metadata
+	cloud cloud.Cloud
+	// cloudProvider is one of CloudProviders, fakeCloudLatency is the duration of every call
+	// of the fake workspaces in fakeClouds
+	cloudProvider    string
+	fakeCloudLatency time.Duration
+	fakeClouds       *fakeClouds
+	// tuning is shared by the cloud clients of the driver, it's updated by Reconfigure
+	tuning *cloud.Tuning
+	// pollWorkers and pollQueueSize size the pool polling long running PowerVS operations,
+	// shared by the cloud clients of all workspaces, the cloud defaults are used when 0
+	pollWorkers   int
+	pollQueueSize int
+	pollPool      *cloud.PollPool
+	// tierAttachLimits are the volumes of a tier the controller attaches to a node at most
+	tierAttachLimits map[string]int64
+	// attachBatchWindow is how long the controller collects the attachments to a node before
+	// attaching them with a single PowerVS operation, 0 disables batching
+	attachBatchWindow time.Duration
+	// detachBatchWindow is how long the controller collects the detaches from a node before
+	// detaching them together, 0 disables batching
+	detachBatchWindow time.Duration
+	// forceDetachTimeout is how long the detaches of a volume from a node fail before the
+	// controller force detaches it from a node powered off or in error, 0 disables it
+	forceDetachTimeout time.Duration
+	// featureGates enable or disable the features of knownFeatures
+	featureGates FeatureGates
 }
 
+// NewDriver creates the services of the driver for the mode set in options
 func NewDriver(options ...func(*Options)) (*Driver, error) {
-	klog.Infof("Driver: %v Version: %v", DriverName, driverVersion)
+	klog.Infof("Driver: %v Version: %v GitCommit: %v BuildDate: %v", DriverName, driverVersion, gitCommit, buildDate)
 
 	driverOptions := Options{
-		endpoint: DefaultCSIEndpoint,
-		mode:     AllMode,
+		endpoint:               DefaultCSIEndpoint,
+		mode:                   AllMode,
+		requestLogLevel:        DefaultRequestLogLevel,
+		shutdownTimeout:        DefaultShutdownTimeout,
+		volumeLockTimeout:      DefaultVolumeLockTimeout,
+		slowOperationThreshold: DefaultSlowOperationThreshold,
+		volumeStats:            true,
 	}
 	for _, option := range options {
 		option(&driverOptions)
@@ -79,9 +240,29 @@ func NewDriver(options ...func(*Options)) (*Driver, error) {
 	if err := ValidateDriverOptions(&driverOptions); err != nil {
 		return nil, fmt.Errorf("Invalid driver options: %v", err)
 	}
+	klog.Infof("Enabled features: %v", driverOptions.features())
+	if len(driverOptions.featureGates) > 0 {
+		klog.Infof("Feature gates: %v", driverOptions.featureGates)
+	}
 
+	driverOptions.tuning = cloud.NewTuning(driverOptions.cloudOptions()...)
+	if driverOptions.cloudProvider == CloudProviderFake {
+		driverOptions.fakeClouds = &fakeClouds{clouds: map[string]*fake.Cloud{}}
+	}
+	if driverOptions.mode != NodeMode {
+		workers, queueSize := cloud.DefaultPollWorkers, cloud.DefaultPollQueueSize
+		if driverOptions.pollWorkers > 0 {
+			workers = driverOptions.pollWorkers
+		}
+		if driverOptions.pollQueueSize > 0 {
+			queueSize = driverOptions.pollQueueSize
+		}
+		driverOptions.pollPool = cloud.NewPollPool(workers, queueSize)
+	}
 	driver := Driver{
-		options: &driverOptions,
+		options:                &driverOptions,
+		requestLogLevel:        int32(driverOptions.requestLogLevel),
+		slowOperationThreshold: int64(driverOptions.slowOperationThreshold),
 	}
 
 	switch driverOptions.mode {
@@ -99,6 +280,7 @@ func NewDriver(options ...func(*Options)) (*Driver, error) {
 	return &driver, nil
 }
 
+// Run serves the driver services on the endpoint set in the options until Stop is called
 func (d *Driver) Run() error {
 	scheme, addr, err := util.ParseEndpoint(d.options.endpoint)
 	if err != nil {
@@ -109,40 +291,320 @@ func (d *Driver) Run() error {
 	if err != nil {
 		return err
 	}
+	if scheme == "unix" {
+		if err := d.options.socket.apply(addr); err != nil {
+			listener.Close()
+			return err
+		}
+	}
 
-	logErr := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		resp, err := handler(ctx, req)
+	if d.options.tracing {
+		d.stopTracing, err = tracing.Setup(context.Background(), DriverName+"-"+string(d.options.mode), driverVersion)
 		if err != nil {
-			klog.Errorf("GRPC error: %v", err)
+			return err
 		}
-		return resp, err
 	}
+
 	opts := []grpc.ServerOption{
-		grpc.UnaryInterceptor(logErr),
+		grpc.ChainUnaryInterceptor(d.unaryInterceptors()...),
+	}
+	opts = append(opts, d.options.grpcServer.serverOptions()...)
+	if d.options.tlsCertFile != "" {
+		tlsConfig, err := serverTLSConfig(d.options.tlsCertFile, d.options.tlsKeyFile, d.options.tlsClientCAFile)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
 	}
 	d.srv = grpc.NewServer(opts...)
+	d.stopped = make(chan struct{})
+
+	if err := d.Register(d.srv); err != nil {
+		return err
+	}
 
-	csi.RegisterIdentityServer(d.srv, d)
+	if d.options.httpEndpoint != "" {
+		go d.serveHTTP(d.options.httpEndpoint)
+	}
+
+	if d.options.mode != NodeMode {
+		d.startInformers()
+		if err := d.runControllerLoops(); err != nil {
+			return err
+		}
+	}
+	// the volumes of a fake workspace are never attached to the node
+	if d.options.mode != ControllerMode && d.options.cloudProvider != CloudProviderFake && d.options.enabled(Multipath) && d.options.staleDeviceCleanup {
+		ctx, cancel := context.WithTimeout(context.Background(), staleDeviceCleanupTimeout)
+		if err := d.nodeService.cleanupStaleDevices(ctx); err != nil {
+			klog.Warningf("Could not clean up stale devices: %v", err)
+		}
+		cancel()
+	}
+
+	klog.Infof("Listening for connections on address: %#v", listener.Addr())
+	atomic.StoreInt32(&d.serving, 1)
+	defer atomic.StoreInt32(&d.serving, 0)
+	if err := d.srv.Serve(listener); err != nil {
+		return err
+	}
+	// Serve returns as soon as Stop closes the listener, wait for the in-flight RPCs
+	<-d.stopped
+	return nil
+}
+
+// unaryInterceptors returns the interceptors of the CSI requests from the outermost to the
+// innermost, recoverPanics comes first so that it also catches panics of the other interceptors
+func (d *Driver) unaryInterceptors() []grpc.UnaryServerInterceptor {
+	return []grpc.UnaryServerInterceptor{
+		recoverPanics,
+		assignRequestID,
+		traceRequests,
+		recordMetrics,
+		logRequests(&d.requestLogLevel),
+		watchSlowRequests(&d.slowOperationThreshold),
+		rejectWhileDraining(&d.draining),
+		validateRequests,
+	}
+}
+
+// startInformers starts the informers of the controller, they run on every replica until Stop
+// as the requests of all replicas are checked against them
+func (d *Driver) startInformers() {
+	if d.controllerService.informers == nil {
+		return
+	}
+	stopCh := make(chan struct{})
+	d.controllerService.informers.Start(stopCh)
+	d.stopInformers = func() { close(stopCh) }
+}
+
+// runControllerLoops starts the background loops of the controller, with leader election
+// only one of the controller replicas runs them
+func (d *Driver) runControllerLoops() error {
+	tierMigration := d.options.tierMigrationInterval > 0 && d.options.enabled(TierMigration)
+	tagReconciliation := d.options.tagReconcileInterval > 0 && d.options.enabled(TagReconciliation)
+	if !d.options.leaderElection && !tierMigration && !tagReconciliation {
+		d.setLeader(true)
+		return nil
+	}
+	client, err := cloud.DefaultKubernetesAPIClient()
+	if err != nil {
+		return fmt.Errorf("could not create kubernetes client for the controller loops: %v", err)
+	}
+	var loops []leaderLoop
+	if tierMigration {
+		migrator := newTierMigrator(d.controllerService.cloud, d.controllerService.workspaces, client, d.options.tierMigrationTimeout)
+		loops = append(loops, func(stopCh <-chan struct{}) {
+			migrator.run(d.options.tierMigrationInterval, stopCh)
+		})
+	}
+	if tagReconciliation {
+		reconciler := newTagReconciler(d.controllerService.cloud, d.controllerService.workspaces, client, d.options.requiredTags())
+		loops = append(loops, func(stopCh <-chan struct{}) {
+			reconciler.run(d.options.tagReconcileInterval, stopCh)
+		})
+	}
+	return d.runLeaderLoops(client, loops)
+}
+
+// cloudOptions returns the PowerVS cloud client options derived from the driver options
+func (o *Options) cloudOptions() []func(*cloud.Options) {
+	opts := []func(*cloud.Options){cloud.WithAPIEndpoints(o.apiEndpoints), cloud.WithServiceEndpoints(o.serviceEndpoints)}
+	if o.apiKeyFile != "" {
+		opts = append(opts, cloud.WithAPIKeyFile(o.apiKeyFile))
+	}
+	if o.authType == cloud.AuthTypeTrustedProfile {
+		opts = append(opts, cloud.WithTrustedProfile(o.trustedProfileID, o.trustedProfileName, o.crTokenFile))
+	}
+	if o.volumeStateTimeout > 0 {
+		opts = append(opts, cloud.WithVolumeStateTimeout(o.volumeStateTimeout))
+	}
+	if o.volumeStatePollInterval > 0 {
+		opts = append(opts, cloud.WithVolumeStatePollInterval(o.volumeStatePollInterval))
+	}
+	if len(o.operationTimeouts) > 0 {
+		opts = append(opts, cloud.WithOperationTimeouts(o.operationTimeouts))
+	}
+	if o.apiRetryInitialDelay > 0 || o.apiRetrySteps > 0 {
+		delay, steps := cloud.DefaultBackoff.Duration, cloud.DefaultBackoff.Steps
+		if o.apiRetryInitialDelay > 0 {
+			delay = o.apiRetryInitialDelay
+		}
+		if o.apiRetrySteps > 0 {
+			steps = o.apiRetrySteps
+		}
+		opts = append(opts, cloud.WithRetryBackoff(delay, steps))
+	}
+	if o.apiCallTimeout > 0 {
+		opts = append(opts, cloud.WithAPICallTimeout(o.apiCallTimeout))
+	}
+	if o.slowOperationThreshold > 0 {
+		opts = append(opts, cloud.WithSlowCallThreshold(o.slowOperationThreshold))
+	}
+	if o.tuning != nil {
+		opts = append(opts, cloud.WithTuning(o.tuning))
+	}
+	if o.pollPool != nil {
+		opts = append(opts, cloud.WithPollPool(o.pollPool))
+	}
+	return opts
+}
+
+// features returns the optional features enabled by the options, in a fixed order
+func (o *Options) features() []string {
+	var features []string
+	if o.tlsClientCAFile != "" {
+		features = append(features, "mtls")
+	} else if o.tlsCertFile != "" {
+		features = append(features, "tls")
+	}
+	if o.httpEndpoint != "" {
+		features = append(features, "metrics")
+	}
+	if o.tracing {
+		features = append(features, "tracing")
+	}
+	if o.debug {
+		features = append(features, "debug")
+	}
+	if o.authType == cloud.AuthTypeTrustedProfile {
+		features = append(features, "trusted-profile")
+	}
+	if len(o.apiEndpoints) > 1 {
+		features = append(features, "api-failover")
+	}
+	if o.cloudProvider == CloudProviderFake {
+		features = append(features, "fake-cloud")
+	}
+	if o.mode != NodeMode {
+		if len(o.cloudInstanceIDs) > 0 {
+			features = append(features, "multi-workspace")
+		}
+		if o.tierMigrationInterval > 0 && o.enabled(TierMigration) {
+			features = append(features, "tier-migration")
+		}
+		if o.tagReconcileInterval > 0 && o.enabled(TagReconciliation) {
+			features = append(features, "tag-reconciliation")
+		}
+		if o.leaderElection {
+			features = append(features, "leader-election")
+		}
+		if o.legacyVolumeHandles {
+			features = append(features, "legacy-volume-handles")
+		}
+		if o.events {
+			features = append(features, "events")
+		}
+		if o.attachBatchWindow > 0 {
+			features = append(features, "attach-batching")
+		}
+		if o.detachBatchWindow > 0 {
+			features = append(features, "detach-batching")
+		}
+		if o.forceDetachTimeout > 0 {
+			features = append(features, "force-detach")
+		}
+		if o.enabled(NonGracefulNodeShutdown) {
+			features = append(features, "non-graceful-node-shutdown")
+		}
+		if o.enabled(VolumeAttachmentCheck) {
+			features = append(features, "volume-attachment-check")
+		}
+		if o.enabled(VolumeAttributesClass) {
+			features = append(features, "volume-attributes-class")
+		}
+	}
+	if o.enabled(SingleNodeMultiWriter) {
+		features = append(features, "single-node-multi-writer")
+	}
+	if o.mode != ControllerMode && o.volumeStats && o.enabled(NodeVolumeCondition) {
+		features = append(features, "node-volume-condition")
+	}
+	if o.enabled(StoragePoolTopology) {
+		features = append(features, "storage-pool-topology")
+	}
+	return features
+}
+
+// Reconfigure applies the request log level, slow operation threshold, volume state and retry
+// settings of options to the running driver, options that are only used when the driver is created are ignored
+func (d *Driver) Reconfigure(options ...func(*Options)) error {
+	o := *d.options
+	for _, option := range options {
+		option(&o)
+	}
+	if err := ValidateDriverOptions(&o); err != nil {
+		return fmt.Errorf("Invalid driver options: %v", err)
+	}
+	atomic.StoreInt32(&d.requestLogLevel, int32(o.requestLogLevel))
+	atomic.StoreInt64(&d.slowOperationThreshold, int64(o.slowOperationThreshold))
+	d.options.tuning.Update(o.cloudOptions()...)
+	return nil
+}
+
+// Register registers the services of the driver mode on srv, for embedding the driver in
+// an existing gRPC server instead of calling Run
+func (d *Driver) Register(srv *grpc.Server) error {
+	csi.RegisterIdentityServer(srv, d)
 
 	switch d.options.mode {
 	case ControllerMode:
-		csi.RegisterControllerServer(d.srv, d)
+		csi.RegisterControllerServer(srv, d)
+		csi.RegisterGroupControllerServer(srv, d)
 	case NodeMode:
-		csi.RegisterNodeServer(d.srv, d)
+		csi.RegisterNodeServer(srv, d)
 	case AllMode:
-		csi.RegisterControllerServer(d.srv, d)
-		csi.RegisterNodeServer(d.srv, d)
+		csi.RegisterControllerServer(srv, d)
+		csi.RegisterGroupControllerServer(srv, d)
+		csi.RegisterNodeServer(srv, d)
 	default:
 		return fmt.Errorf("unknown mode: %s", d.options.mode)
 	}
-
-	klog.Infof("Listening for connections on address: %#v", listener.Addr())
-	return d.srv.Serve(listener)
+	return nil
 }
 
+// Stop stops the gRPC server started by Run. New RPCs are refused with Unavailable while the
+// in-flight ones get the shutdown timeout to complete, the remaining ones are canceled then.
+// Canceled operations are rolled back or resumed by the retries of the CO, which are
+// idempotent.
 func (d *Driver) Stop() {
-	klog.Infof("Stopping server")
-	d.srv.Stop()
+	d.stopOnce.Do(d.stop)
+}
+
+func (d *Driver) stop() {
+	if d.stopped != nil {
+		defer close(d.stopped)
+	}
+	klog.Infof("Stopping server, waiting up to %v for in-flight requests", d.options.shutdownTimeout)
+	atomic.StoreInt32(&d.draining, 1)
+	drained := make(chan struct{})
+	go func() {
+		d.srv.GracefulStop()
+		close(drained)
+	}()
+	timer := time.NewTimer(d.options.shutdownTimeout)
+	select {
+	case <-drained:
+		timer.Stop()
+	case <-timer.C:
+		klog.Warningf("Shutdown timeout of %v exceeded, canceling in-flight requests", d.options.shutdownTimeout)
+		d.srv.Stop()
+		<-drained
+	}
+	if d.stopLeaderElection != nil {
+		d.stopLeaderElection()
+	}
+	if d.stopInformers != nil {
+		d.stopInformers()
+	}
+	if d.stopTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := d.stopTracing(ctx); err != nil {
+			klog.Errorf("could not flush spans: %v", err)
+		}
+	}
 }
 
 func WithEndpoint(endpoint string) func(*Options) {
@@ -151,20 +613,336 @@ func WithEndpoint(endpoint string) func(*Options) {
 	}
 }
 
+// WithTLS serves the gRPC endpoint over TLS with the certificate and key, clientCAFile
+// enables mutual TLS when set
+func WithTLS(certFile, keyFile, clientCAFile string) func(*Options) {
+	return func(o *Options) {
+		o.tlsCertFile = certFile
+		o.tlsKeyFile = keyFile
+		o.tlsClientCAFile = clientCAFile
+	}
+}
+
+// WithHTTPEndpoint serves the Prometheus metrics on /metrics and the liveness and readiness
+// probes on /healthz and /readyz of the TCP address endpoint, e.g. ":8080"
+func WithHTTPEndpoint(endpoint string) func(*Options) {
+	return func(o *Options) {
+		o.httpEndpoint = endpoint
+	}
+}
+
 func WithMode(mode Mode) func(*Options) {
 	return func(o *Options) {
 		o.mode = mode
 	}
 }
 
+// WithTracing exports OpenTelemetry spans of the CSI requests, PowerVS API calls and node
+// mount steps over OTLP to the collector set in the OTEL_EXPORTER_OTLP_* environment
+func WithTracing(enabled bool) func(*Options) {
+	return func(o *Options) {
+		o.tracing = enabled
+	}
+}
+
+// WithRequestLogLevel sets the klog verbosity at which CSI requests and responses are logged
+func WithRequestLogLevel(level int) func(*Options) {
+	return func(o *Options) {
+		o.requestLogLevel = klog.Level(level)
+	}
+}
+
 func WithDebug(debug bool) func(*Options) {
 	return func(o *Options) {
 		o.debug = debug
 	}
 }
 
+func WithExtraTags(extraTags map[string]string) func(*Options) {
+	return func(o *Options) {
+		o.extraTags = extraTags
+	}
+}
+
+func WithKubernetesClusterID(clusterID string) func(*Options) {
+	return func(o *Options) {
+		o.kubernetesClusterID = clusterID
+	}
+}
+
 func WithVolumeAttachLimit(volumeAttachLimit int64) func(*Options) {
 	return func(o *Options) {
 		o.volumeAttachLimit = volumeAttachLimit
 	}
 }
+
+// WithCloudProvider sets the cloud provider, one of CloudProviders, and the latency of the calls
+// of the fake cloud
+func WithCloudProvider(provider string, fakeLatency time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.cloudProvider = provider
+		o.fakeCloudLatency = fakeLatency
+	}
+}
+
+// WithForceDetachTimeout sets how long the detaches of a volume fail before it's force detached
+// from a node powered off or in error, 0 disables force detaching
+func WithForceDetachTimeout(timeout time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.forceDetachTimeout = timeout
+	}
+}
+
+// WithFeatureGates enables or disables features, gates set by earlier options are kept unless
+// gates set them again
+func WithFeatureGates(gates FeatureGates) func(*Options) {
+	return func(o *Options) {
+		// a copy, the options copied by Reconfigure share the map
+		merged := make(FeatureGates, len(o.featureGates)+len(gates))
+		for feature, enabled := range o.featureGates {
+			merged[feature] = enabled
+		}
+		for feature, enabled := range gates {
+			merged[feature] = enabled
+		}
+		o.featureGates = merged
+	}
+}
+
+// WithVolumeStats enables or disables the volume stats of the node
+func WithVolumeStats(enabled bool) func(*Options) {
+	return func(o *Options) {
+		o.volumeStats = enabled
+	}
+}
+
+// WithVolumeStatsCacheTTL sets how long the node caches the stats of a volume
+func WithVolumeStatsCacheTTL(ttl time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.volumeStatsCacheTTL = ttl
+	}
+}
+
+// WithFsckPolicy sets how the node checks the filesystems of volumes before mounting them
+func WithFsckPolicy(policy FsckPolicy) func(*Options) {
+	return func(o *Options) {
+		o.fsckPolicy = policy
+	}
+}
+
+// WithExt4FormatOptions sets the mkfs options the node formats ext filesystems with
+func WithExt4FormatOptions(formatOptions string) func(*Options) {
+	return func(o *Options) {
+		o.ext4FormatOptions = formatOptions
+	}
+}
+
+// WithStoragePool sets the storage pool the node reports in its topology
+func WithStoragePool(pool string) func(*Options) {
+	return func(o *Options) {
+		o.storagePool = pool
+	}
+}
+
+// WithStaleDeviceCleanup enables the cleanup of the devices of detached volumes at startup
+func WithStaleDeviceCleanup(enabled bool) func(*Options) {
+	return func(o *Options) {
+		o.staleDeviceCleanup = enabled
+	}
+}
+
+// WithTierMigrationTimeout sets the time a tier migration may take before it fails
+func WithTierMigrationTimeout(timeout time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.tierMigrationTimeout = timeout
+	}
+}
+
+func WithTierMigrationInterval(interval time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.tierMigrationInterval = interval
+	}
+}
+
+// WithTagReconcileInterval sets the resync period of the tag reconciler, 0 disables it
+func WithTagReconcileInterval(interval time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.tagReconcileInterval = interval
+	}
+}
+
+// WithShutdownTimeout sets how long Stop waits for in-flight RPCs to complete before it
+// cancels them
+func WithShutdownTimeout(timeout time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.shutdownTimeout = timeout
+	}
+}
+
+// WithSlowOperationThreshold logs and counts the CSI requests and cloud calls taking longer
+// than threshold, 0 disables it
+func WithSlowOperationThreshold(threshold time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.slowOperationThreshold = threshold
+	}
+}
+
+// WithVolumeLockTimeout sets how long an RPC waits for the operation in progress on its volume
+// before it's aborted
+func WithVolumeLockTimeout(timeout time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.volumeLockTimeout = timeout
+	}
+}
+
+// WithLeaderElection runs the background loops of the controller only on the replica
+// holding the controller lease in namespace, the namespace of the pod when empty
+func WithLeaderElection(enabled bool, namespace string) func(*Options) {
+	return func(o *Options) {
+		o.leaderElection = enabled
+		o.leaderElectionNamespace = namespace
+	}
+}
+
+// WithLegacyVolumeHandles makes the controller accept the ibmpowervs:// volume handles of
+// PVs created before the CSI driver
+func WithLegacyVolumeHandles(enabled bool) func(*Options) {
+	return func(o *Options) {
+		o.legacyVolumeHandles = enabled
+	}
+}
+
+// WithEvents makes the controller emit warning events on the PVCs and PVs of volumes that
+// exceed the attach limits, are throttled by PowerVS or fail
+func WithEvents(enabled bool) func(*Options) {
+	return func(o *Options) {
+		o.events = enabled
+	}
+}
+
+// WithPollWorkers sets the number of workers polling long running PowerVS operations and how
+// many operations they accept at a time
+func WithPollWorkers(workers, queueSize int) func(*Options) {
+	return func(o *Options) {
+		o.pollWorkers = workers
+		o.pollQueueSize = queueSize
+	}
+}
+
+// WithTierAttachLimits limits the volumes of a tier attached to a node, on top of the limit
+// of all volumes
+func WithTierAttachLimits(limits map[string]int64) func(*Options) {
+	return func(o *Options) {
+		o.tierAttachLimits = limits
+	}
+}
+
+// WithAttachBatchWindow batches the attachments to a node requested within window
+func WithAttachBatchWindow(window time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.attachBatchWindow = window
+	}
+}
+
+// WithDetachBatchWindow batches the detaches from a node requested within window
+func WithDetachBatchWindow(window time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.detachBatchWindow = window
+	}
+}
+
+func WithAPIEndpoints(endpoints []string) func(*Options) {
+	return func(o *Options) {
+		o.apiEndpoints = endpoints
+	}
+}
+
+func WithServiceEndpoints(endpoints cloud.ServiceEndpoints) func(*Options) {
+	return func(o *Options) {
+		o.serviceEndpoints = endpoints
+	}
+}
+
+func WithAPIKeyFile(file string) func(*Options) {
+	return func(o *Options) {
+		o.apiKeyFile = file
+	}
+}
+
+func WithAuthType(authType string) func(*Options) {
+	return func(o *Options) {
+		o.authType = authType
+	}
+}
+
+func WithTrustedProfile(profileID, profileName, crTokenFile string) func(*Options) {
+	return func(o *Options) {
+		o.trustedProfileID = profileID
+		o.trustedProfileName = profileName
+		o.crTokenFile = crTokenFile
+	}
+}
+
+func WithVolumeStateTimeout(timeout time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.volumeStateTimeout = timeout
+	}
+}
+
+// WithOperationTimeouts bounds the create, delete, attach, detach and expand operations of
+// timeouts, 0 keeps the volume state timeout of an operation
+func WithOperationTimeouts(timeouts map[cloud.Operation]time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.operationTimeouts = timeouts
+	}
+}
+
+func WithVolumeStatePollInterval(interval time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.volumeStatePollInterval = interval
+	}
+}
+
+func WithAPIRetryBackoff(initialDelay time.Duration, steps int) func(*Options) {
+	return func(o *Options) {
+		o.apiRetryInitialDelay = initialDelay
+		o.apiRetrySteps = steps
+	}
+}
+
+// WithAPICallTimeout fails PowerVS and IAM HTTP requests not done within timeout
+func WithAPICallTimeout(timeout time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.apiCallTimeout = timeout
+	}
+}
+
+// WithGRPCServerOptions tunes the keepalive, concurrency and connection limits of the gRPC
+// server
+func WithGRPCServerOptions(grpcServer GRPCServerOptions) func(*Options) {
+	return func(o *Options) {
+		o.grpcServer = grpcServer
+	}
+}
+
+// WithSocketOptions sets the permissions and ownership of a unix endpoint
+func WithSocketOptions(socket SocketOptions) func(*Options) {
+	return func(o *Options) {
+		o.socket = socket
+	}
+}
+
+// WithCloudInstanceIDs makes the controller manage volumes in several PowerVS workspaces
+func WithCloudInstanceIDs(ids []string) func(*Options) {
+	return func(o *Options) {
+		o.cloudInstanceIDs = ids
+	}
+}
+
+// WithCloud makes the driver use c instead of creating a PowerVS cloud client, e.g. to wrap
+// the client or to use a fake one
+func WithCloud(c cloud.Cloud) func(*Options) {
+	return func(o *Options) {
+		o.cloud = c
+	}
+}
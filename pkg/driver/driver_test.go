@@ -14,7 +14,22 @@ limitations under the License.
 package driver
 
 import (
+	"context"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
 )
 
 func TestWithEndpoint(t *testing.T) {
@@ -35,6 +50,57 @@ func TestWithMode(t *testing.T) {
 	}
 }
 
+func TestWithCloudProvider(t *testing.T) {
+	options := &Options{}
+	WithCloudProvider(CloudProviderFake, time.Second)(options)
+	if options.cloudProvider != CloudProviderFake || options.fakeCloudLatency != time.Second {
+		t.Fatalf("expected cloud provider %s with latency %v, got %s with %v", CloudProviderFake, time.Second, options.cloudProvider, options.fakeCloudLatency)
+	}
+}
+
+func TestWithVolumeStats(t *testing.T) {
+	options := &Options{volumeStats: true}
+	WithVolumeStats(false)(options)
+	if options.volumeStats {
+		t.Fatalf("expected volumeStats option got set to false")
+	}
+}
+
+func TestWithVolumeStatsCacheTTL(t *testing.T) {
+	value := time.Minute
+	options := &Options{}
+	WithVolumeStatsCacheTTL(value)(options)
+	if options.volumeStatsCacheTTL != value {
+		t.Fatalf("expected volumeStatsCacheTTL option got set to %v but is set to %v", value, options.volumeStatsCacheTTL)
+	}
+}
+
+func TestWithStaleDeviceCleanup(t *testing.T) {
+	options := &Options{}
+	WithStaleDeviceCleanup(true)(options)
+	if !options.staleDeviceCleanup {
+		t.Fatalf("expected staleDeviceCleanup option got set to true")
+	}
+}
+
+func TestWithFsckPolicy(t *testing.T) {
+	value := FsckPolicyRepair
+	options := &Options{}
+	WithFsckPolicy(value)(options)
+	if options.fsckPolicy != value {
+		t.Fatalf("expected fsckPolicy option got set to %v but is set to %v", value, options.fsckPolicy)
+	}
+}
+
+func TestWithExt4FormatOptions(t *testing.T) {
+	value := "-E lazy_itable_init=1"
+	options := &Options{}
+	WithExt4FormatOptions(value)(options)
+	if options.ext4FormatOptions != value {
+		t.Fatalf("expected ext4FormatOptions option got set to %v but is set to %v", value, options.ext4FormatOptions)
+	}
+}
+
 func TestWithVolumeAttachLimit(t *testing.T) {
 	var value int64 = 42
 	options := &Options{}
@@ -52,3 +118,478 @@ func TestWithDebug(t *testing.T) {
 		t.Fatalf("expected awsSdkDebugLog option got set to %v but is set to %v", enableSdkDebugLog, options.debug)
 	}
 }
+
+func TestWithTierMigrationInterval(t *testing.T) {
+	value := 5 * time.Minute
+	options := &Options{}
+	WithTierMigrationInterval(value)(options)
+	if options.tierMigrationInterval != value {
+		t.Fatalf("expected tierMigrationInterval option got set to %v but is set to %v", value, options.tierMigrationInterval)
+	}
+}
+
+func TestWithTierMigrationTimeout(t *testing.T) {
+	value := time.Hour
+	options := &Options{}
+	WithTierMigrationTimeout(value)(options)
+	if options.tierMigrationTimeout != value {
+		t.Fatalf("expected tierMigrationTimeout option got set to %v but is set to %v", value, options.tierMigrationTimeout)
+	}
+}
+
+func TestWithTagReconcileInterval(t *testing.T) {
+	value := 10 * time.Minute
+	options := &Options{}
+	WithTagReconcileInterval(value)(options)
+	if options.tagReconcileInterval != value {
+		t.Fatalf("expected tagReconcileInterval option got set to %v but is set to %v", value, options.tagReconcileInterval)
+	}
+}
+
+func TestWithLeaderElection(t *testing.T) {
+	options := &Options{}
+	WithLeaderElection(true, "kube-system")(options)
+	if !options.leaderElection || options.leaderElectionNamespace != "kube-system" {
+		t.Fatalf("expected leader election in namespace kube-system, got %v in %q", options.leaderElection, options.leaderElectionNamespace)
+	}
+}
+
+func TestWithLegacyVolumeHandles(t *testing.T) {
+	options := &Options{}
+	WithLegacyVolumeHandles(true)(options)
+	if !options.legacyVolumeHandles {
+		t.Fatalf("expected legacyVolumeHandles option got set to true")
+	}
+}
+
+func TestWithEvents(t *testing.T) {
+	options := &Options{}
+	WithEvents(true)(options)
+	if !options.events {
+		t.Fatalf("expected events option got set to true")
+	}
+}
+
+func TestWithPollWorkers(t *testing.T) {
+	options := &Options{}
+	WithPollWorkers(5, 100)(options)
+	if options.pollWorkers != 5 || options.pollQueueSize != 100 {
+		t.Fatalf("expected poll pool options got set to 5 and 100, got %d and %d", options.pollWorkers, options.pollQueueSize)
+	}
+}
+
+func TestWithTierAttachLimits(t *testing.T) {
+	value := map[string]int64{"tier0": 16}
+	options := &Options{}
+	WithTierAttachLimits(value)(options)
+	if !reflect.DeepEqual(options.tierAttachLimits, value) {
+		t.Fatalf("expected tierAttachLimits option got set to %v but is set to %v", value, options.tierAttachLimits)
+	}
+}
+
+func TestWithAttachBatchWindow(t *testing.T) {
+	value := 100 * time.Millisecond
+	options := &Options{}
+	WithAttachBatchWindow(value)(options)
+	if options.attachBatchWindow != value {
+		t.Fatalf("expected attachBatchWindow option got set to %v but is set to %v", value, options.attachBatchWindow)
+	}
+}
+
+func TestWithDetachBatchWindow(t *testing.T) {
+	value := 100 * time.Millisecond
+	options := &Options{}
+	WithDetachBatchWindow(value)(options)
+	if options.detachBatchWindow != value {
+		t.Fatalf("expected detachBatchWindow option got set to %v but is set to %v", value, options.detachBatchWindow)
+	}
+}
+
+func TestWithFeatureGates(t *testing.T) {
+	options := &Options{}
+	WithFeatureGates(FeatureGates{Multipath: false})(options)
+	copied := *options
+	WithFeatureGates(FeatureGates{TierMigration: false})(&copied)
+	expected := FeatureGates{Multipath: false}
+	if !reflect.DeepEqual(options.featureGates, expected) {
+		t.Fatalf("expected featureGates option got set to %v but is set to %v", expected, options.featureGates)
+	}
+	expected = FeatureGates{Multipath: false, TierMigration: false}
+	if !reflect.DeepEqual(copied.featureGates, expected) {
+		t.Fatalf("expected featureGates option got set to %v but is set to %v", expected, copied.featureGates)
+	}
+}
+
+func TestWithForceDetachTimeout(t *testing.T) {
+	value := 5 * time.Minute
+	options := &Options{}
+	WithForceDetachTimeout(value)(options)
+	if options.forceDetachTimeout != value {
+		t.Fatalf("expected forceDetachTimeout option got set to %v but is set to %v", value, options.forceDetachTimeout)
+	}
+}
+
+func TestWithAPICallTimeout(t *testing.T) {
+	value := 45 * time.Second
+	options := &Options{}
+	WithAPICallTimeout(value)(options)
+	if options.apiCallTimeout != value {
+		t.Fatalf("expected apiCallTimeout option got set to %v but is set to %v", value, options.apiCallTimeout)
+	}
+}
+
+func TestWithOperationTimeouts(t *testing.T) {
+	value := map[cloud.Operation]time.Duration{cloud.OperationCreate: 10 * time.Minute, cloud.OperationAttach: 3 * time.Minute}
+	options := &Options{}
+	WithOperationTimeouts(value)(options)
+	if !reflect.DeepEqual(options.operationTimeouts, value) {
+		t.Fatalf("expected operationTimeouts option got set to %v but is set to %v", value, options.operationTimeouts)
+	}
+}
+
+func TestWithAPIEndpoints(t *testing.T) {
+	value := []string{"us-south.power-iaas.cloud.ibm.com", "dal.power-iaas.cloud.ibm.com"}
+	options := &Options{}
+	WithAPIEndpoints(value)(options)
+	if !reflect.DeepEqual(options.apiEndpoints, value) {
+		t.Fatalf("expected apiEndpoints option got set to %v but is set to %v", value, options.apiEndpoints)
+	}
+}
+
+func TestWithServiceEndpoints(t *testing.T) {
+	value := cloud.ServiceEndpoints{IAM: "https://private.iam.cloud.ibm.com"}
+	options := &Options{}
+	WithServiceEndpoints(value)(options)
+	if options.serviceEndpoints != value {
+		t.Fatalf("expected serviceEndpoints option got set to %v but is set to %v", value, options.serviceEndpoints)
+	}
+}
+
+func TestWithTracing(t *testing.T) {
+	options := &Options{}
+	WithTracing(true)(options)
+	if !options.tracing {
+		t.Fatalf("expected tracing option got set to true")
+	}
+}
+
+func TestWithRequestLogLevel(t *testing.T) {
+	options := &Options{}
+	WithRequestLogLevel(2)(options)
+	if options.requestLogLevel != 2 {
+		t.Fatalf("expected requestLogLevel option got set to 2 but is set to %d", options.requestLogLevel)
+	}
+}
+
+func TestWithShutdownTimeout(t *testing.T) {
+	value := 10 * time.Second
+	options := &Options{}
+	WithShutdownTimeout(value)(options)
+	if options.shutdownTimeout != value {
+		t.Fatalf("expected shutdownTimeout option got set to %v but is set to %v", value, options.shutdownTimeout)
+	}
+}
+
+func TestWithSlowOperationThreshold(t *testing.T) {
+	value := 30 * time.Second
+	options := &Options{}
+	WithSlowOperationThreshold(value)(options)
+	if options.slowOperationThreshold != value {
+		t.Fatalf("expected slowOperationThreshold option got set to %v but is set to %v", value, options.slowOperationThreshold)
+	}
+}
+
+func TestWithVolumeLockTimeout(t *testing.T) {
+	value := 2 * time.Second
+	options := &Options{}
+	WithVolumeLockTimeout(value)(options)
+	if options.volumeLockTimeout != value {
+		t.Fatalf("expected volumeLockTimeout option got set to %v but is set to %v", value, options.volumeLockTimeout)
+	}
+}
+
+func TestWithTLS(t *testing.T) {
+	options := &Options{}
+	WithTLS("tls.crt", "tls.key", "ca.crt")(options)
+	if options.tlsCertFile != "tls.crt" || options.tlsKeyFile != "tls.key" || options.tlsClientCAFile != "ca.crt" {
+		t.Fatalf("expected TLS options got set, got %q, %q and %q", options.tlsCertFile, options.tlsKeyFile, options.tlsClientCAFile)
+	}
+}
+
+func TestWithHTTPEndpoint(t *testing.T) {
+	value := ":8080"
+	options := &Options{}
+	WithHTTPEndpoint(value)(options)
+	if options.httpEndpoint != value {
+		t.Fatalf("expected httpEndpoint option got set to %q but is set to %q", value, options.httpEndpoint)
+	}
+}
+
+func TestWithGRPCServerOptions(t *testing.T) {
+	value := GRPCServerOptions{MaxConcurrentStreams: 100, KeepaliveMinTime: time.Minute}
+	options := &Options{}
+	WithGRPCServerOptions(value)(options)
+	if options.grpcServer != value {
+		t.Fatalf("expected grpcServer option got set to %+v but is set to %+v", value, options.grpcServer)
+	}
+}
+
+func TestWithSocketOptions(t *testing.T) {
+	value := SocketOptions{Mode: 0660, Group: "csi"}
+	options := &Options{}
+	WithSocketOptions(value)(options)
+	if options.socket != value {
+		t.Fatalf("expected socket option got set to %+v but is set to %+v", value, options.socket)
+	}
+}
+
+func TestWithCloudInstanceIDs(t *testing.T) {
+	value := []string{"ws-1", "ws-2"}
+	options := &Options{}
+	WithCloudInstanceIDs(value)(options)
+	if !reflect.DeepEqual(options.cloudInstanceIDs, value) {
+		t.Fatalf("expected cloudInstanceIDs option got set to %v but is set to %v", value, options.cloudInstanceIDs)
+	}
+}
+
+func TestWithAPIKeyFile(t *testing.T) {
+	value := "/etc/secrets/apikey"
+	options := &Options{}
+	WithAPIKeyFile(value)(options)
+	if options.apiKeyFile != value {
+		t.Fatalf("expected apiKeyFile option got set to %q but is set to %q", value, options.apiKeyFile)
+	}
+}
+
+func TestWithTrustedProfile(t *testing.T) {
+	options := &Options{}
+	WithTrustedProfile("Profile-1", "powervs-csi", "/var/run/secrets/tokens/powervs-csi")(options)
+	if options.trustedProfileID != "Profile-1" || options.trustedProfileName != "powervs-csi" || options.crTokenFile != "/var/run/secrets/tokens/powervs-csi" {
+		t.Fatalf("expected trusted profile options got set but are set to %q, %q, %q", options.trustedProfileID, options.trustedProfileName, options.crTokenFile)
+	}
+}
+
+func TestCloudOptions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		options  *Options
+		expected int
+	}{
+		{
+			name:     "defaults",
+			options:  &Options{},
+			expected: 2,
+		},
+		{
+			name: "volume state, operation timeout and retry overrides",
+			options: &Options{
+				volumeStateTimeout:      5 * time.Minute,
+				volumeStatePollInterval: 10 * time.Second,
+				apiRetrySteps:           3,
+				operationTimeouts:       map[cloud.Operation]time.Duration{cloud.OperationCreate: 10 * time.Minute},
+			},
+			expected: 6,
+		},
+		{
+			name:     "trusted profile",
+			options:  &Options{authType: cloud.AuthTypeTrustedProfile, trustedProfileID: "Profile-1"},
+			expected: 3,
+		},
+		{
+			name:     "api key file",
+			options:  &Options{apiKeyFile: "/etc/secrets/apikey"},
+			expected: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if opts := tc.options.cloudOptions(); len(opts) != tc.expected {
+				t.Fatalf("expected %d cloud options, got %d", tc.expected, len(opts))
+			}
+		})
+	}
+}
+
+func TestWithExtraTags(t *testing.T) {
+	value := map[string]string{"team": "storage"}
+	options := &Options{}
+	WithExtraTags(value)(options)
+	if !reflect.DeepEqual(options.extraTags, value) {
+		t.Fatalf("expected extraTags option got set to %v but is set to %v", value, options.extraTags)
+	}
+}
+
+func TestWithKubernetesClusterID(t *testing.T) {
+	value := "cluster-1"
+	options := &Options{}
+	WithKubernetesClusterID(value)(options)
+	if options.kubernetesClusterID != value {
+		t.Fatalf("expected kubernetesClusterID option got set to %q but is set to %q", value, options.kubernetesClusterID)
+	}
+}
+
+func TestWithCloud(t *testing.T) {
+	value := fake.NewCloud(0)
+	options := &Options{}
+	WithCloud(value)(options)
+	if options.cloud != value {
+		t.Fatalf("expected cloud option got set to %v but is set to %v", value, options.cloud)
+	}
+}
+
+func TestNewDriverWithCloud(t *testing.T) {
+	c := fake.NewCloud(0)
+	drv, err := NewDriver(WithMode(ControllerMode), WithCloud(c))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if drv.controllerService.cloud != c {
+		t.Fatalf("expected the controller to use the cloud passed with WithCloud")
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	drv, err := NewDriver(WithMode(ControllerMode), WithCloud(fake.NewCloud(0)), WithAPIRetryBackoff(time.Second, 3))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tuning := drv.options.tuning
+	if err := drv.Reconfigure(WithRequestLogLevel(2), WithSlowOperationThreshold(time.Second), WithVolumeStateTimeout(5*time.Minute), WithEndpoint("tcp://0.0.0.0:10000")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if drv.requestLogLevel != 2 {
+		t.Fatalf("expected request log level 2, got %d", drv.requestLogLevel)
+	}
+	if drv.slowOperationThreshold != int64(time.Second) {
+		t.Fatalf("expected slow operation threshold 1s, got %v", time.Duration(drv.slowOperationThreshold))
+	}
+	if drv.options.endpoint != DefaultCSIEndpoint {
+		t.Fatalf("expected the endpoint not to be changed, got %q", drv.options.endpoint)
+	}
+	if drv.options.tuning != tuning {
+		t.Fatalf("expected the cloud tuning to be updated in place")
+	}
+	if err := drv.Reconfigure(WithTLS("tls.crt", "", "")); err == nil {
+		t.Fatalf("expected error for invalid options")
+	}
+}
+
+// TestStopDrainsRequests stops a driver with a CreateVolume in flight, it completes within the
+// shutdown timeout and is canceled after it
+func TestStopDrainsRequests(t *testing.T) {
+	testCases := []struct {
+		name            string
+		shutdownTimeout time.Duration
+		expected        codes.Code
+	}{
+		{
+			name:            "completed",
+			shutdownTimeout: 10 * time.Second,
+			expected:        codes.OK,
+		},
+		{
+			name:            "canceled",
+			shutdownTimeout: 10 * time.Millisecond,
+			expected:        codes.Unavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			endpoint := "unix://" + filepath.Join(t.TempDir(), "csi.sock")
+			fakeCloud := fake.NewCloud(0)
+			fakeCloud.AddLatency("CreateDisk", time.Second)
+			options := &Options{endpoint: endpoint, mode: ControllerMode, shutdownTimeout: tc.shutdownTimeout}
+			d := &Driver{
+				options: options,
+				controllerService: controllerService{
+					cloud:         fakeCloud,
+					driverOptions: options,
+					volumeLocks:   util.NewVolumeLocks(),
+				},
+			}
+			stopped := make(chan error, 1)
+			go func() {
+				stopped <- d.Run()
+			}()
+
+			conn, err := grpc.Dial(endpoint, grpc.WithInsecure(), grpc.WithBlock())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			client := csi.NewControllerClient(conn)
+			result := make(chan error, 1)
+			go func() {
+				_, err := client.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+					Name: "vol-test",
+					VolumeCapabilities: []*csi.VolumeCapability{{
+						AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+						AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+					}},
+				})
+				result <- err
+			}()
+			for fakeCloud.CallCount("CreateDisk") == 0 {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			d.Stop()
+			if err := <-stopped; err != nil {
+				t.Fatalf("expected Run to return without error, got %v", err)
+			}
+			if err := <-result; status.Code(err) != tc.expected {
+				t.Fatalf("expected the in-flight request to return %v, got %v", tc.expected, err)
+			}
+			if err := d.ready(); err == nil {
+				t.Fatalf("expected the stopped driver not to be ready")
+			}
+		})
+	}
+}
+
+func TestRunStartsInformersUntilStop(t *testing.T) {
+	endpoint := "unix://" + filepath.Join(t.TempDir(), "csi.sock")
+	vscsi := int32(vscsiVolumeLimit - 1)
+	client := kubefake.NewSimpleClientset(limitCSINode("worker-0", DriverName, "pvm-0", &vscsi))
+	factory := informers.NewSharedInformerFactory(client, 0)
+	options := &Options{endpoint: endpoint, mode: ControllerMode, shutdownTimeout: time.Second}
+	d := &Driver{
+		options: options,
+		controllerService: controllerService{
+			cloud:            fake.NewCloud(0),
+			driverOptions:    options,
+			volumeLocks:      util.NewVolumeLocks(),
+			nodeVolumeLimits: newNodeVolumeLimits(factory),
+			informers:        factory,
+		},
+	}
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- d.Run()
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := d.nodeVolumeLimits.limit("pvm-0"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected Run to start the informers")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	d.Stop()
+	if err := <-stopped; err != nil {
+		t.Fatalf("expected Run to return without error, got %v", err)
+	}
+	if _, err := client.StorageV1().CSINodes().Create(context.Background(), limitCSINode("worker-1", DriverName, "pvm-1", &vscsi), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := d.nodeVolumeLimits.limit("pvm-1"); ok {
+		t.Fatalf("expected the informers to stop with the driver")
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// reasons of the warning events emitted on PVCs and PVs
+const (
+	EventAttachLimitExceeded = "AttachLimitExceeded"
+	EventCloudThrottled      = "CloudThrottled"
+	EventDetachRefused       = "DetachRefused"
+	EventForceDetached       = "ForceDetached"
+	EventVolumeFailed        = "VolumeFailed"
+	EventQuotaExceeded       = "QuotaExceeded"
+	EventNoCapacity          = "InsufficientCapacity"
+)
+
+// eventTimeout bounds the lookup of the objects an event is emitted on
+const eventTimeout = 30 * time.Second
+
+// volumeEvents emits warnings on the PVCs and PVs of volumes for failures the user can act
+// on, so that they show up in kubectl describe. A nil volumeEvents emits nothing.
+type volumeEvents struct {
+	client   kubernetes.Interface
+	recorder record.EventRecorder
+}
+
+func newVolumeEvents(client kubernetes.Interface) *volumeEvents {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	return &volumeEvents{
+		client:   client,
+		recorder: broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: DriverName}),
+	}
+}
+
+// claimWarning emits a warning on the PVC namespace/name, the claim of CreateVolume requests
+// of the external-provisioner started with --extra-create-metadata. The claim is looked up
+// in the background, the RPC isn't delayed.
+func (e *volumeEvents) claimWarning(namespace, name, reason, messageFmt string, args ...interface{}) {
+	if e == nil || name == "" {
+		return
+	}
+	message := fmt.Sprintf(messageFmt, args...)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+		defer cancel()
+		pvc, err := e.client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			klog.V(4).Infof("Could not get PVC %s/%s for event %s: %v", namespace, name, reason, err)
+			return
+		}
+		e.recorder.Event(pvc, v1.EventTypeWarning, reason, message)
+	}()
+}
+
+// volumeWarning emits a warning on the PV of the volume handle and on the PVC bound to it,
+// they are looked up in the background
+func (e *volumeEvents) volumeWarning(handle, reason, messageFmt string, args ...interface{}) {
+	if e == nil {
+		return
+	}
+	message := fmt.Sprintf(messageFmt, args...)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+		defer cancel()
+		pvs, err := e.client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			klog.V(4).Infof("Could not list PVs for event %s of volume %s: %v", reason, handle, err)
+			return
+		}
+		for i := range pvs.Items {
+			pv := &pvs.Items[i]
+			if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != DriverName || pv.Spec.CSI.VolumeHandle != handle {
+				continue
+			}
+			e.recorder.Event(pv, v1.EventTypeWarning, reason, message)
+			// the claim reference carries the UID kubectl describe looks the events up by
+			if ref := pv.Spec.ClaimRef; ref != nil {
+				e.recorder.Event(ref, v1.EventTypeWarning, reason, message)
+			}
+			return
+		}
+	}()
+}
+
+// cloudFailureEvent returns the event reason of an error of the cloud and how PowerVS failed
+// the operation, or "" if it's not one the user can act on
+func cloudFailureEvent(err error) (reason, message string) {
+	switch {
+	case cloud.IsThrottlingError(err):
+		return EventCloudThrottled, "throttled by PowerVS, it is retried"
+	case cloud.IsAttachLimitError(err):
+		return EventAttachLimitExceeded, "rejected by PowerVS, the node has the maximum number of volumes attached"
+	case cloud.IsQuotaError(err):
+		return EventQuotaExceeded, "rejected by PowerVS, a quota of the workspace is exhausted"
+	case cloud.IsInsufficientCapacityError(err):
+		return EventNoCapacity, "rejected by PowerVS, the storage pools have no space left"
+	case errors.Is(err, cloud.ErrVolumeFailed):
+		return EventVolumeFailed, "failed, PowerVS reports the volume in state error"
+	}
+	return "", ""
+}
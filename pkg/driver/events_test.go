@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/go-openapi/runtime"
+	"github.com/golang/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	mocks "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/mocks"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func newTestVolumeEvents(objects ...*v1.PersistentVolume) (*volumeEvents, *record.FakeRecorder) {
+	client := fake.NewSimpleClientset(&v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "claim", Namespace: "ns"}})
+	for _, pv := range objects {
+		_, _ = client.CoreV1().PersistentVolumes().Create(context.TODO(), pv, metav1.CreateOptions{})
+	}
+	recorder := record.NewFakeRecorder(10)
+	return &volumeEvents{client: client, recorder: recorder}, recorder
+}
+
+// expectEvents returns the events of recorder, waiting for count of them as they are emitted
+// in the background
+func expectEvents(t *testing.T, recorder *record.FakeRecorder, count int) []string {
+	var events []string
+	for len(events) < count {
+		select {
+		case e := <-recorder.Events:
+			events = append(events, e)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %d events, got %v", count, events)
+		}
+	}
+	select {
+	case e := <-recorder.Events:
+		t.Fatalf("unexpected event %q", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+	return events
+}
+
+func TestVolumeEvents(t *testing.T) {
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv"},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{CSI: &v1.CSIPersistentVolumeSource{Driver: DriverName, VolumeHandle: "vol-1"}},
+			ClaimRef:               &v1.ObjectReference{Kind: "PersistentVolumeClaim", Name: "claim", Namespace: "ns"},
+		},
+	}
+
+	t.Run("claim warning", func(t *testing.T) {
+		e, recorder := newTestVolumeEvents()
+		e.claimWarning("ns", "claim", EventCloudThrottled, "volume %s throttled", "pvc-1")
+		events := expectEvents(t, recorder, 1)
+		if events[0] != "Warning CloudThrottled volume pvc-1 throttled" {
+			t.Fatalf("unexpected event %q", events[0])
+		}
+	})
+
+	t.Run("claim warning of unknown claim", func(t *testing.T) {
+		e, recorder := newTestVolumeEvents()
+		e.claimWarning("ns", "other", EventCloudThrottled, "throttled")
+		e.claimWarning("", "", EventCloudThrottled, "throttled")
+		expectEvents(t, recorder, 0)
+	})
+
+	t.Run("volume warning on PV and claim", func(t *testing.T) {
+		e, recorder := newTestVolumeEvents(pv)
+		e.volumeWarning("vol-1", EventAttachLimitExceeded, "node full")
+		for _, event := range expectEvents(t, recorder, 2) {
+			if event != "Warning AttachLimitExceeded node full" {
+				t.Fatalf("unexpected event %q", event)
+			}
+		}
+	})
+
+	t.Run("volume warning of volume without PV", func(t *testing.T) {
+		e, recorder := newTestVolumeEvents(pv)
+		e.volumeWarning("vol-2", EventVolumeFailed, "failed")
+		expectEvents(t, recorder, 0)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var e *volumeEvents
+		e.claimWarning("ns", "claim", EventCloudThrottled, "throttled")
+		e.volumeWarning("vol-1", EventVolumeFailed, "failed")
+	})
+}
+
+func TestCreateVolumeEvents(t *testing.T) {
+	testCases := []struct {
+		name           string
+		expectMock     func(mockCloud *mocks.MockCloud)
+		expectedReason string
+	}{
+		{
+			name: "throttled creation",
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), "pvc-1").Return(nil, cloud.ErrNotFound)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), "pvc-1", gomock.Any()).Return(nil, runtime.NewAPIError("op", nil, 429))
+			},
+			expectedReason: EventCloudThrottled,
+		},
+		{
+			name: "quota exceeded",
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), "pvc-1").Return(nil, cloud.ErrNotFound)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), "pvc-1", gomock.Any()).Return(nil, errors.New("[POST /pcloud/v1/cloud-instances/{cloud_instance_id}/volumes][403] quota exceeded"))
+			},
+			expectedReason: EventQuotaExceeded,
+		},
+		{
+			name: "existing volume in error state",
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), "pvc-1").Return(&cloud.Disk{VolumeID: "vol-1", CapacityGiB: 10, State: cloud.VolumeErrorState}, nil)
+				mockCloud.EXPECT().WaitForVolumeState(gomock.Any(), "vol-1", cloud.VolumeAvailableState).Return(context.DeadlineExceeded)
+			},
+			expectedReason: EventVolumeFailed,
+		},
+		{
+			name: "created volume",
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByName(gomock.Any(), "pvc-1").Return(nil, cloud.ErrNotFound)
+				mockCloud.EXPECT().CreateDisk(gomock.Any(), "pvc-1", gomock.Any()).Return(&cloud.Disk{VolumeID: "vol-1", CapacityGiB: 1}, nil)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			tc.expectMock(mockCloud)
+			events, recorder := newTestVolumeEvents()
+
+			d := controllerService{
+				cloud:         mockCloud,
+				driverOptions: &Options{},
+				volumeLocks:   util.NewVolumeLocks(),
+				events:        events,
+			}
+			_, _ = d.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name: "pvc-1",
+				VolumeCapabilities: []*csi.VolumeCapability{{
+					AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+					AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+				}},
+				Parameters: map[string]string{PVCNameKey: "claim", PVCNamespaceKey: "ns"},
+			})
+
+			if tc.expectedReason == "" {
+				expectEvents(t, recorder, 0)
+				return
+			}
+			if event := expectEvents(t, recorder, 1)[0]; !strings.HasPrefix(event, "Warning "+tc.expectedReason+" ") {
+				t.Fatalf("expected %s event, got %q", tc.expectedReason, event)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"os"
+	"sync"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
+)
+
+// cloud providers of the driver
+const (
+	// CloudProviderPowerVS manages the volumes through the PowerVS API
+	CloudProviderPowerVS = "powervs"
+	// CloudProviderFake manages the volumes of in-memory workspaces, see fake.Cloud. The
+	// node plugin can't stage their volumes as there is no device behind them.
+	CloudProviderFake = "fake"
+)
+
+// CloudProviders are the supported cloud providers
+var CloudProviders = []string{CloudProviderPowerVS, CloudProviderFake}
+
+// fakeClouds are the fake workspaces of the driver by cloud instance ID, the controller and node
+// services of a driver share them
+type fakeClouds struct {
+	mu     sync.Mutex
+	clouds map[string]*fake.Cloud
+}
+
+func (f *fakeClouds) get(cloudInstanceID string, o *Options) *fake.Cloud {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.clouds[cloudInstanceID]
+	if !ok {
+		klog.Infof("Using fake PowerVS workspace %s with a latency of %v", cloudInstanceID, o.fakeCloudLatency)
+		c = fake.NewCloud(o.fakeCloudLatency)
+		f.clouds[cloudInstanceID] = c
+	}
+	return c
+}
+
+// newCloud returns the client of the workspace cloudInstanceID of the cloud provider
+func (o *Options) newCloud(cloudInstanceID string, options ...func(*cloud.Options)) (cloud.Cloud, error) {
+	if o.cloudProvider == CloudProviderFake {
+		return o.fakeClouds.get(cloudInstanceID, o), nil
+	}
+	return NewPowerVSCloudFunc(cloudInstanceID, o.debug, options...)
+}
+
+// instanceMetadata returns the PowerVS metadata of the node the driver runs on. With the fake
+// cloud, nodes without PowerVS labels are in the fake.CloudInstanceID workspace.
+func (o *Options) instanceMetadata() (cloud.MetadataService, error) {
+	metadata, err := cloud.NewMetadataService(cloud.DefaultKubernetesAPIClient)
+	if err != nil && o.cloudProvider == CloudProviderFake {
+		klog.Infof("Node has no PowerVS metadata, using the fake workspace %s: %v", fake.CloudInstanceID, err)
+		return fake.Metadata(os.Getenv("CSI_NODE_NAME")), nil
+	}
+	return metadata, err
+}
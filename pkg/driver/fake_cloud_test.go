@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func TestFakeCloudProvider(t *testing.T) {
+	options := &Options{cloudProvider: CloudProviderFake, fakeClouds: &fakeClouds{clouds: map[string]*fake.Cloud{}}}
+	c, err := options.newCloud("ws")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if other, _ := options.newCloud("ws"); other != c {
+		t.Fatalf("expected the services to share the fake workspace")
+	}
+
+	ctx := context.Background()
+	d := controllerService{
+		cloud:         c,
+		driverOptions: options,
+		volumeLocks:   util.NewVolumeLocks(),
+		nodeQueues:    newNodeQueues(),
+	}
+	volCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+	created, err := d.CreateVolume(ctx, &csi.CreateVolumeRequest{Name: "pvc-1", VolumeCapabilities: []*csi.VolumeCapability{volCap}})
+	if err != nil {
+		t.Fatalf("could not create volume: %v", err)
+	}
+	volumeID := created.GetVolume().GetVolumeId()
+	if _, err := d.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{VolumeId: volumeID, NodeId: "node-1", VolumeCapability: volCap}); err != nil {
+		t.Fatalf("could not publish volume: %v", err)
+	}
+	if _, err := d.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: volumeID}); err == nil {
+		t.Fatalf("expected the deletion of an attached volume to fail")
+	}
+	if _, err := d.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{VolumeId: volumeID, NodeId: "node-1"}); err != nil {
+		t.Fatalf("could not unpublish volume: %v", err)
+	}
+	if _, err := d.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: volumeID}); err != nil {
+		t.Fatalf("could not delete volume: %v", err)
+	}
+	if disks, _ := c.ListDisks(ctx); len(disks) != 0 {
+		t.Fatalf("expected no volumes left, got %v", disks)
+	}
+}
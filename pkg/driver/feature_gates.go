@@ -0,0 +1,164 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Feature is the name of a feature gate of the driver
+type Feature string
+
+const (
+	// VolumeExpansion advertises and serves the online expansion of volumes by the controller
+	// and the node
+	VolumeExpansion Feature = "VolumeExpansion"
+	// Multipath flushes the multipath devices of unstaged volumes and removes the stale
+	// multipath devices of detached volumes when the node starts
+	Multipath Feature = "Multipath"
+	// TierMigration runs the tier migration reconciler of the controller
+	TierMigration Feature = "TierMigration"
+	// TagReconciliation runs the tag reconciler of the controller
+	TagReconciliation Feature = "TagReconciliation"
+	// NonGracefulNodeShutdown detaches the volumes of nodes tainted out-of-service right away,
+	// without waiting for the detach from the unreachable node to fail
+	NonGracefulNodeShutdown Feature = "NonGracefulNodeShutdown"
+	// VolumeAttachmentCheck refuses to detach volumes from nodes which a VolumeAttachment that
+	// isn't being deleted still references
+	VolumeAttachmentCheck Feature = "VolumeAttachmentCheck"
+	// SingleNodeMultiWriter advertises the SINGLE_NODE_MULTI_WRITER capabilities, which accept
+	// the SINGLE_NODE_SINGLE_WRITER access mode of ReadWriteOncePod volumes and
+	// SINGLE_NODE_MULTI_WRITER
+	SingleNodeMultiWriter Feature = "SingleNodeMultiWriter"
+	// VolumeAttributesClass serves ControllerModifyVolume and the mutable parameters of
+	// CreateVolume, set by the VolumeAttributesClass of a PVC
+	VolumeAttributesClass Feature = "VolumeAttributesClass"
+	// NodeVolumeCondition reports the condition of volumes in NodeGetVolumeStats
+	NodeVolumeCondition Feature = "NodeVolumeCondition"
+	// StoragePoolTopology creates volumes in the storage pool of the topology they are
+	// provisioned for and reports their pool in their topology, nodes report the pool of
+	// --storage-pool
+	StoragePoolTopology Feature = "StoragePoolTopology"
+)
+
+// Stages of the features, alpha features are disabled by default
+const (
+	FeatureAlpha = "ALPHA"
+	FeatureBeta  = "BETA"
+	FeatureGA    = "GA"
+)
+
+// featureSpec is the default and stage of a feature
+type featureSpec struct {
+	Default bool
+	Stage   string
+}
+
+// knownFeatures are the feature gates of the driver, new functionality is added as an alpha
+// feature and enabled by default once it's beta
+var knownFeatures = map[Feature]featureSpec{
+	VolumeExpansion:         {Default: true, Stage: FeatureGA},
+	Multipath:               {Default: true, Stage: FeatureBeta},
+	TierMigration:           {Default: true, Stage: FeatureBeta},
+	TagReconciliation:       {Default: false, Stage: FeatureAlpha},
+	NonGracefulNodeShutdown: {Default: false, Stage: FeatureAlpha},
+	VolumeAttachmentCheck:   {Default: false, Stage: FeatureAlpha},
+	SingleNodeMultiWriter:   {Default: false, Stage: FeatureAlpha},
+	VolumeAttributesClass:   {Default: false, Stage: FeatureAlpha},
+	NodeVolumeCondition:     {Default: false, Stage: FeatureAlpha},
+	StoragePoolTopology:     {Default: false, Stage: FeatureAlpha},
+}
+
+// FeatureGates are the features explicitly enabled or disabled, the other features have
+// their default
+type FeatureGates map[Feature]bool
+
+// ParseFeatureGates parses comma separated <feature>=<true|false> pairs, like
+// "Multipath=false,TierMigration=true"
+func ParseFeatureGates(value string) (FeatureGates, error) {
+	gates := FeatureGates{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid feature gate %q, expected <feature>=<true|false>", item)
+		}
+		feature := Feature(strings.TrimSpace(kv[0]))
+		if _, ok := knownFeatures[feature]; !ok {
+			return nil, fmt.Errorf("unknown feature gate %q (known: %v)", feature, KnownFeatures())
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of feature gate %s: %v", feature, err)
+		}
+		gates[feature] = enabled
+	}
+	return gates, nil
+}
+
+// KnownFeatures returns the feature gates of the driver with their default and stage, sorted
+// by name
+func KnownFeatures() []string {
+	known := make([]string, 0, len(knownFeatures))
+	for feature, spec := range knownFeatures {
+		known = append(known, fmt.Sprintf("%s=true|false (%s - default=%t)", feature, spec.Stage, spec.Default))
+	}
+	sort.Strings(known)
+	return known
+}
+
+// Enabled returns true if feature is enabled, explicitly or by default
+func (g FeatureGates) Enabled(feature Feature) bool {
+	if enabled, ok := g[feature]; ok {
+		return enabled
+	}
+	return knownFeatures[feature].Default
+}
+
+// String returns the gates in the format parsed by ParseFeatureGates, sorted by feature
+func (g FeatureGates) String() string {
+	items := make([]string, 0, len(g))
+	for feature, enabled := range g {
+		items = append(items, fmt.Sprintf("%s=%t", feature, enabled))
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// enabled returns true if feature is enabled by the feature gates of o, nil options have the
+// defaults
+func (o *Options) enabled(feature Feature) bool {
+	if o == nil {
+		return knownFeatures[feature].Default
+	}
+	return o.featureGates.Enabled(feature)
+}
+
+func validateFeatureGates(gates FeatureGates) error {
+	for feature := range gates {
+		if _, ok := knownFeatures[feature]; !ok {
+			return fmt.Errorf("unknown feature gate %q (known: %v)", feature, KnownFeatures())
+		}
+	}
+	return nil
+}
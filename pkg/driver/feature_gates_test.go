@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+)
+
+func TestParseFeatureGates(t *testing.T) {
+	testCases := []struct {
+		name      string
+		value     string
+		expected  FeatureGates
+		expectErr bool
+	}{
+		{
+			name:     "empty",
+			expected: FeatureGates{},
+		},
+		{
+			name:     "gates",
+			value:    "Multipath=false, TierMigration=true,",
+			expected: FeatureGates{Multipath: false, TierMigration: true},
+		},
+		{
+			name:      "unknown feature",
+			value:     "Snapshots=true",
+			expectErr: true,
+		},
+		{
+			name:      "without value",
+			value:     "Multipath",
+			expectErr: true,
+		},
+		{
+			name:      "invalid value",
+			value:     "Multipath=maybe",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gates, err := ParseFeatureGates(tc.value)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.expectErr, err)
+			}
+			if !tc.expectErr && !reflect.DeepEqual(gates, tc.expected) {
+				t.Fatalf("expected gates %v, got %v", tc.expected, gates)
+			}
+		})
+	}
+}
+
+func TestFeatureGatesEnabled(t *testing.T) {
+	gates := FeatureGates{Multipath: false}
+	if gates.Enabled(Multipath) {
+		t.Fatalf("expected %s to be disabled", Multipath)
+	}
+	if !gates.Enabled(VolumeExpansion) {
+		t.Fatalf("expected %s to be enabled by default", VolumeExpansion)
+	}
+	if s := (FeatureGates{TierMigration: true, Multipath: false}).String(); s != "Multipath=false,TierMigration=true" {
+		t.Fatalf("unexpected string %q", s)
+	}
+	var options *Options
+	if !options.enabled(VolumeExpansion) {
+		t.Fatalf("expected nil options to have the default of %s", VolumeExpansion)
+	}
+}
+
+func TestVolumeExpansionFeatureGate(t *testing.T) {
+	options := &Options{featureGates: FeatureGates{VolumeExpansion: false}}
+
+	controller := &controllerService{driverOptions: options}
+	for _, cap := range controller.controllerCapabilities() {
+		if cap == csi.ControllerServiceCapability_RPC_EXPAND_VOLUME {
+			t.Fatalf("expected no controller expansion capability, got %v", controller.controllerCapabilities())
+		}
+	}
+	_, err := controller.ControllerExpandVolume(context.Background(), &csi.ControllerExpandVolumeRequest{VolumeId: "vol-test"})
+	expectErr(t, err, codes.Unimplemented)
+
+	node := &nodeService{driverOptions: options}
+	for _, cap := range node.nodeCapabilities() {
+		if cap == csi.NodeServiceCapability_RPC_EXPAND_VOLUME {
+			t.Fatalf("expected no node expansion capability, got %v", node.nodeCapabilities())
+		}
+	}
+	_, err = node.NodeExpandVolume(context.Background(), &csi.NodeExpandVolumeRequest{VolumeId: "vol-test"})
+	expectErr(t, err, codes.Unimplemented)
+
+	resp, err := (&Driver{options: options}).GetPluginCapabilities(context.Background(), &csi.GetPluginCapabilitiesRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, c := range resp.GetCapabilities() {
+		if c.GetVolumeExpansion() != nil {
+			t.Fatalf("expected no volume expansion capability, got %v", resp.GetCapabilities())
+		}
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// failingDetaches records since when the detaches of volumes from nodes fail, to force them
+// once they fail for longer than the force detach timeout. The records are lost when the
+// controller restarts.
+type failingDetaches struct {
+	timeout time.Duration
+	mu      sync.Mutex
+	since   map[failingDetach]time.Time
+	// now is time.Now, replaced in tests
+	now func() time.Time
+}
+
+type failingDetach struct {
+	volumeID, nodeID string
+}
+
+func newFailingDetaches(timeout time.Duration) *failingDetaches {
+	return &failingDetaches{timeout: timeout, since: map[failingDetach]time.Time{}, now: time.Now}
+}
+
+// failed records a failed detach of volumeID from nodeID and returns true once the detaches
+// fail for longer than the timeout
+func (f *failingDetaches) failed(volumeID, nodeID string) bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := failingDetach{volumeID: volumeID, nodeID: nodeID}
+	since, ok := f.since[key]
+	if !ok {
+		f.since[key] = f.now()
+		return false
+	}
+	return f.now().Sub(since) >= f.timeout
+}
+
+// forget drops the record of volumeID and nodeID once the volume is detached
+func (f *failingDetaches) forget(volumeID, nodeID string) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.since, failingDetach{volumeID: volumeID, nodeID: nodeID})
+}
+
+// forceDetach force detaches the disk diskID of volumeID from nodeID after its detaches failed
+// for longer than the force detach timeout, if the instance of the node is powered off or in
+// error. It returns true if the disk was detached.
+func (d *controllerService) forceDetach(ctx context.Context, c cloud.Cloud, volumeID, diskID, nodeID string) bool {
+	if !d.failingDetaches.failed(volumeID, nodeID) || !forceDetachDisk(ctx, c, volumeID, diskID, nodeID) {
+		return false
+	}
+	klog.Warningf("Force detached volume %s from node %s after its detach failed for %v", volumeID, nodeID, d.failingDetaches.timeout)
+	d.events.volumeWarning(volumeID, EventForceDetached, "Volume was force detached from node %s, whose instance is powered off or in error, after its detach failed for %v", nodeID, d.failingDetaches.timeout)
+	return true
+}
+
+// forceDetachDisk force detaches the disk diskID of volumeID from nodeID if the cloud supports
+// it and the instance of the node is powered off or in error
+func forceDetachDisk(ctx context.Context, c cloud.Cloud, volumeID, diskID, nodeID string) bool {
+	forcer, ok := c.(cloud.ForceDetacher)
+	if !ok {
+		return false
+	}
+	if err := forcer.ForceDetachDisk(ctx, diskID, nodeID); err != nil {
+		if errors.Is(err, cloud.ErrInstanceNotDown) {
+			klog.V(4).Infof("Not forcing the detach of volume %s: %v", volumeID, err)
+		} else {
+			klog.Errorf("Could not force the detach of volume %s from node %s: %v", volumeID, nodeID, err)
+		}
+		return false
+	}
+	return true
+}
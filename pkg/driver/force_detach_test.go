@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
+)
+
+func TestControllerUnpublishVolumeForceDetach(t *testing.T) {
+	testCases := []struct {
+		name          string
+		instanceState string
+		expForced     bool
+	}{
+		{
+			name:          "powered off instance",
+			instanceState: cloud.InstanceShutoffState,
+			expForced:     true,
+		},
+		{
+			name:          "instance in error",
+			instanceState: cloud.InstanceErrorState,
+			expForced:     true,
+		},
+		{
+			name:          "active instance",
+			instanceState: cloud.InstanceActiveState,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeCloud := fake.NewCloud(0)
+			disk := attachFakeDisks(t, fakeCloud, 1)[0]
+			fakeCloud.SetInstanceState(expInstanceID, tc.instanceState)
+			fakeCloud.FailOnCall("DetachDisk", 0, errors.New("instance is not responding"))
+
+			d := newBatchingControllerService(fakeCloud, 0)
+			d.failingDetaches = newFailingDetaches(time.Minute)
+			now := time.Now()
+			d.failingDetaches.now = func() time.Time { return now }
+			req := &csi.ControllerUnpublishVolumeRequest{VolumeId: disk.VolumeID, NodeId: expInstanceID}
+
+			// the detach isn't forced before it failed for the timeout
+			_, err := d.ControllerUnpublishVolume(context.Background(), req)
+			expectErr(t, err, codes.Internal)
+			now = now.Add(time.Minute)
+
+			_, err = d.ControllerUnpublishVolume(context.Background(), req)
+			if !tc.expForced {
+				expectErr(t, err, codes.Internal)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if attached, _ := fakeCloud.IsAttached(context.Background(), disk.VolumeID, expInstanceID); attached {
+				t.Fatalf("Expected volume %s to be force detached", disk.VolumeID)
+			}
+			if _, ok := d.failingDetaches.since[failingDetach{volumeID: disk.VolumeID, nodeID: expInstanceID}]; ok {
+				t.Fatalf("Expected the failing detach to be forgotten")
+			}
+		})
+	}
+}
+
+func TestControllerUnpublishVolumeForceDetachDisabled(t *testing.T) {
+	fakeCloud := fake.NewCloud(0)
+	disk := attachFakeDisks(t, fakeCloud, 1)[0]
+	fakeCloud.SetInstanceState(expInstanceID, cloud.InstanceShutoffState)
+	fakeCloud.FailOnCall("DetachDisk", 0, errors.New("instance is not responding"))
+
+	d := newBatchingControllerService(fakeCloud, 0)
+	req := &csi.ControllerUnpublishVolumeRequest{VolumeId: disk.VolumeID, NodeId: expInstanceID}
+	for i := 0; i < 2; i++ {
+		_, err := d.ControllerUnpublishVolume(context.Background(), req)
+		expectErr(t, err, codes.Internal)
+	}
+	if n := fakeCloud.CallCount("ForceDetachDisk"); n != 0 {
+		t.Fatalf("Expected no force detach, got %d", n)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/klog/v2"
+	"k8s.io/utils/exec"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver/params"
+)
+
+// FsckPolicy is how NodeStageVolume checks the existing filesystem of a volume before mounting it
+type FsckPolicy = params.FsckPolicy
+
+const (
+	// FsckPolicyNone mounts filesystems without checking them
+	FsckPolicyNone = params.FsckPolicyNone
+	// FsckPolicyWarn checks filesystems read-only and mounts them even if they have errors
+	FsckPolicyWarn = params.FsckPolicyWarn
+	// FsckPolicyFail checks filesystems read-only, filesystems with errors aren't mounted
+	FsckPolicyFail = params.FsckPolicyFail
+	// FsckPolicyRepair repairs filesystems, those that can't be repaired aren't mounted
+	FsckPolicyRepair = params.FsckPolicyRepair
+)
+
+// ParseFsckPolicy parses the name of a FsckPolicy
+func ParseFsckPolicy(s string) (FsckPolicy, error) {
+	return params.ParseFsckPolicy(s)
+}
+
+// errFilesystemCorrupted is returned for filesystems with errors the policy doesn't mount
+var errFilesystemCorrupted = errors.New("filesystem has errors")
+
+// checkFilesystem checks the filesystem fsType of source according to policy. The ext
+// filesystems are checked with e2fsck, which skips filesystems unmounted cleanly, and xfs with
+// xfs_repair. Other filesystems aren't checked.
+func checkFilesystem(e exec.Interface, source, fsType string, policy FsckPolicy) error {
+	if policy == FsckPolicyNone || policy == "" {
+		return nil
+	}
+	repair := policy == FsckPolicyRepair
+
+	var cmd string
+	var args []string
+	// repaired is true if the exit status means that no errors remain
+	var repaired func(status int) bool
+	switch fsType {
+	case FSTypeExt2, FSTypeExt3, FSTypeExt4:
+		cmd, args = "e2fsck", []string{"-n", source}
+		if repair {
+			args = []string{"-p", source}
+		}
+		// 1 and 2 are errors that were corrected
+		repaired = func(status int) bool { return status == 0 || repair && status <= 2 }
+	case FSTypeXfs:
+		cmd, args = "xfs_repair", []string{"-n", source}
+		if repair {
+			args = []string{source}
+		}
+		repaired = func(status int) bool { return status == 0 }
+	default:
+		klog.V(4).Infof("Not checking filesystem %s of %s, only ext and xfs filesystems are checked", fsType, source)
+		return nil
+	}
+
+	klog.V(4).Infof("Checking filesystem %s of %s with %s %s", fsType, source, cmd, strings.Join(args, " "))
+	out, err := e.Command(cmd, args...).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	var exitErr exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return fmt.Errorf("could not run %s on %s: %v", cmd, source, err)
+	}
+	if repaired(exitErr.ExitStatus()) {
+		klog.Infof("Repaired filesystem %s of %s: %s", fsType, source, out)
+		return nil
+	}
+	if policy == FsckPolicyWarn {
+		klog.Warningf("Mounting filesystem %s of %s with errors, %s exited with %d: %s", fsType, source, cmd, exitErr.ExitStatus(), out)
+		return nil
+	}
+	return fmt.Errorf("%w, %s exited with %d: %s", errFilesystemCorrupted, cmd, exitErr.ExitStatus(), out)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"k8s.io/utils/exec"
+	testingexec "k8s.io/utils/exec/testing"
+)
+
+// fsckCmd returns a command exiting with status like a filesystem check
+func fsckCmd(status int) exec.Cmd {
+	return &testingexec.FakeCmd{
+		CombinedOutputScript: []testingexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				if status == 0 {
+					return []byte("clean"), nil, nil
+				}
+				return []byte("errors"), nil, &testingexec.FakeExitError{Status: status}
+			},
+		},
+	}
+}
+
+func TestCheckFilesystem(t *testing.T) {
+	testCases := []struct {
+		name   string
+		fsType string
+		policy FsckPolicy
+		status int
+		expCmd []string
+		expErr error
+	}{
+		{
+			name:   "no check",
+			fsType: FSTypeExt4,
+			policy: FsckPolicyNone,
+		},
+		{
+			name:   "clean ext4",
+			fsType: FSTypeExt4,
+			policy: FsckPolicyFail,
+			expCmd: []string{"e2fsck", "-n", "/dev/dm-0"},
+		},
+		{
+			name:   "ext4 with errors",
+			fsType: FSTypeExt4,
+			policy: FsckPolicyFail,
+			status: 4,
+			expCmd: []string{"e2fsck", "-n", "/dev/dm-0"},
+			expErr: errFilesystemCorrupted,
+		},
+		{
+			name:   "ext4 with errors mounted anyway",
+			fsType: FSTypeExt4,
+			policy: FsckPolicyWarn,
+			status: 4,
+			expCmd: []string{"e2fsck", "-n", "/dev/dm-0"},
+		},
+		{
+			name:   "ext4 repaired",
+			fsType: FSTypeExt3,
+			policy: FsckPolicyRepair,
+			status: 1,
+			expCmd: []string{"e2fsck", "-p", "/dev/dm-0"},
+		},
+		{
+			name:   "ext4 not repaired",
+			fsType: FSTypeExt4,
+			policy: FsckPolicyRepair,
+			status: 4,
+			expCmd: []string{"e2fsck", "-p", "/dev/dm-0"},
+			expErr: errFilesystemCorrupted,
+		},
+		{
+			name:   "xfs with errors",
+			fsType: FSTypeXfs,
+			policy: FsckPolicyFail,
+			status: 1,
+			expCmd: []string{"xfs_repair", "-n", "/dev/dm-0"},
+			expErr: errFilesystemCorrupted,
+		},
+		{
+			name:   "xfs repaired",
+			fsType: FSTypeXfs,
+			policy: FsckPolicyRepair,
+			expCmd: []string{"xfs_repair", "/dev/dm-0"},
+		},
+		{
+			name:   "other filesystems aren't checked",
+			fsType: "btrfs",
+			policy: FsckPolicyFail,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var cmd []string
+			fakeExec := &testingexec.FakeExec{
+				CommandScript: []testingexec.FakeCommandAction{
+					func(name string, args ...string) exec.Cmd {
+						cmd = append([]string{name}, args...)
+						return fsckCmd(tc.status)
+					},
+				},
+			}
+
+			err := checkFilesystem(fakeExec, "/dev/dm-0", tc.fsType, tc.policy)
+			if !errors.Is(err, tc.expErr) || (err == nil) != (tc.expErr == nil) {
+				t.Fatalf("expected error %v, got %v", tc.expErr, err)
+			}
+			if !reflect.DeepEqual(cmd, tc.expCmd) {
+				t.Fatalf("expected command %v, got %v", tc.expCmd, cmd)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// The group controller service is served so that COs probing it get an answer, but neither
+// advertised in the plugin capabilities nor given any capability: volume group snapshots
+// need the volume snapshots the PowerVS API used by the driver lacks.
+
+// errSnapshotsUnsupported is returned by the group snapshot RPCs, the PowerVS client of the
+// driver only snapshots whole instances, not single volumes
+var errSnapshotsUnsupported = status.Error(codes.Unimplemented, "volume snapshots are not supported by the PowerVS API used by the driver")
+
+func (d *controllerService) GroupControllerGetCapabilities(ctx context.Context, req *csi.GroupControllerGetCapabilitiesRequest) (*csi.GroupControllerGetCapabilitiesResponse, error) {
+	klog.V(4).Infof("GroupControllerGetCapabilities: called with args %+v", req)
+	return &csi.GroupControllerGetCapabilitiesResponse{}, nil
+}
+
+func (d *controllerService) CreateVolumeGroupSnapshot(ctx context.Context, req *csi.CreateVolumeGroupSnapshotRequest) (*csi.CreateVolumeGroupSnapshotResponse, error) {
+	klog.V(4).Infof("CreateVolumeGroupSnapshot: called with args %s", summarizeRequest(req))
+	return nil, errSnapshotsUnsupported
+}
+
+func (d *controllerService) DeleteVolumeGroupSnapshot(ctx context.Context, req *csi.DeleteVolumeGroupSnapshotRequest) (*csi.DeleteVolumeGroupSnapshotResponse, error) {
+	klog.V(4).Infof("DeleteVolumeGroupSnapshot: called with args %s", summarizeRequest(req))
+	return nil, errSnapshotsUnsupported
+}
+
+func (d *controllerService) GetVolumeGroupSnapshot(ctx context.Context, req *csi.GetVolumeGroupSnapshotRequest) (*csi.GetVolumeGroupSnapshotResponse, error) {
+	klog.V(4).Infof("GetVolumeGroupSnapshot: called with args %s", summarizeRequest(req))
+	return nil, errSnapshotsUnsupported
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// GRPCServerOptions tune the gRPC server of the driver for nodes with many client
+// connections, zero values keep the gRPC defaults
+type GRPCServerOptions struct {
+	// MaxConcurrentStreams limits the concurrent RPCs of a client connection
+	MaxConcurrentStreams uint32
+	// KeepaliveTime is the idle time after which the server pings a client, KeepaliveTimeout
+	// how long it waits for the ack before closing the connection
+	KeepaliveTime    time.Duration
+	KeepaliveTimeout time.Duration
+	// KeepaliveMinTime is the minimum interval between the keepalive pings of a client,
+	// clients pinging more often are disconnected. KeepalivePermitWithoutStream allows
+	// keepalive pings of connections without RPCs.
+	KeepaliveMinTime             time.Duration
+	KeepalivePermitWithoutStream bool
+	// MaxConnectionIdle closes connections without RPCs for that long
+	MaxConnectionIdle time.Duration
+	// ConnectionTimeout bounds the handshake of new connections
+	ConnectionTimeout time.Duration
+}
+
+// Validate returns an error if a duration of o is negative
+func (o GRPCServerOptions) Validate() error {
+	durations := map[string]time.Duration{
+		"keepalive time":      o.KeepaliveTime,
+		"keepalive timeout":   o.KeepaliveTimeout,
+		"keepalive min time":  o.KeepaliveMinTime,
+		"max connection idle": o.MaxConnectionIdle,
+		"connection timeout":  o.ConnectionTimeout,
+	}
+	for name, d := range durations {
+		if d < 0 {
+			return fmt.Errorf("%s %v must not be negative", name, d)
+		}
+	}
+	return nil
+}
+
+// serverOptions returns the gRPC server options of o
+func (o GRPCServerOptions) serverOptions() []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		// gRPC replaces the zero values by its defaults
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:              o.KeepaliveTime,
+			Timeout:           o.KeepaliveTimeout,
+			MaxConnectionIdle: o.MaxConnectionIdle,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             o.KeepaliveMinTime,
+			PermitWithoutStream: o.KeepalivePermitWithoutStream,
+		}),
+	}
+	if o.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(o.MaxConcurrentStreams))
+	}
+	if o.ConnectionTimeout > 0 {
+		opts = append(opts, grpc.ConnectionTimeout(o.ConnectionTimeout))
+	}
+	return opts
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func TestGRPCServerOptions(t *testing.T) {
+	testCases := []struct {
+		name            string
+		options         GRPCServerOptions
+		expectedOptions int
+		expectErr       bool
+	}{
+		{
+			name:            "defaults",
+			expectedOptions: 2,
+		},
+		{
+			name: "tuned",
+			options: GRPCServerOptions{
+				MaxConcurrentStreams:         500,
+				KeepaliveTime:                time.Minute,
+				KeepaliveTimeout:             10 * time.Second,
+				KeepaliveMinTime:             30 * time.Second,
+				KeepalivePermitWithoutStream: true,
+				MaxConnectionIdle:            time.Hour,
+				ConnectionTimeout:            30 * time.Second,
+			},
+			expectedOptions: 4,
+		},
+		{
+			name:      "negative duration",
+			options:   GRPCServerOptions{KeepaliveMinTime: -time.Second},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if n := len(tc.options.serverOptions()); n != tc.expectedOptions {
+				t.Fatalf("expected %d server options, got %d", tc.expectedOptions, n)
+			}
+		})
+	}
+}
+
+func TestRunWithGRPCServerOptions(t *testing.T) {
+	endpoint := "unix://" + filepath.Join(t.TempDir(), "csi.sock")
+	options := &Options{
+		endpoint:   endpoint,
+		mode:       ControllerMode,
+		grpcServer: GRPCServerOptions{MaxConcurrentStreams: 1, KeepaliveMinTime: time.Second, KeepalivePermitWithoutStream: true},
+	}
+	d := &Driver{
+		options: options,
+		controllerService: controllerService{
+			cloud:         fake.NewCloud(0),
+			driverOptions: options,
+			volumeLocks:   util.NewVolumeLocks(),
+		},
+	}
+	go func() {
+		_ = d.Run()
+	}()
+	defer d.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, endpoint, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := csi.NewIdentityClient(conn).GetPluginInfo(ctx, &csi.GetPluginInfoRequest{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
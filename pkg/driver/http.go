@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/metrics"
+)
+
+// serveHTTP serves the metrics and the health probes on endpoint until the server fails
+func (d *Driver) serveHTTP(endpoint string) {
+	klog.Infof("Serving metrics and health probes on address: %s", endpoint)
+	if err := http.ListenAndServe(endpoint, d.httpHandler()); err != nil {
+		klog.Fatalf("could not serve HTTP on %s: %v", endpoint, err)
+	}
+}
+
+func (d *Driver) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", probeHandler(d.healthy))
+	mux.HandleFunc("/readyz", probeHandler(d.ready))
+	mux.HandleFunc("/readyz/leader", probeHandler(d.leading))
+	if d.options.mode != ControllerMode {
+		mux.HandleFunc("/prestop", d.preStop)
+	}
+	return mux
+}
+
+// preStop unstages the volumes of the node for the preStop hook of the node plugin, it
+// responds once they are unstaged
+func (d *Driver) preStop(w http.ResponseWriter, r *http.Request) {
+	if d.nodeService.cloud == nil {
+		http.Error(w, "node cloud client is not initialized", http.StatusServiceUnavailable)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), preStopTimeout)
+	defer cancel()
+	klog.Infof("Unstaging the volumes of the node before the node plugin stops")
+	if err := d.nodeService.unstageAll(ctx); err != nil {
+		klog.Errorf("Could not unstage the volumes of the node: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, "ok")
+}
+
+// healthy returns an error unless the gRPC server is serving
+func (d *Driver) healthy() error {
+	if atomic.LoadInt32(&d.serving) == 0 {
+		return errors.New("gRPC server is not serving")
+	}
+	return nil
+}
+
+// ready returns an error unless the driver is healthy and the cloud clients of its mode have
+// been initialized
+func (d *Driver) ready() error {
+	if err := d.healthy(); err != nil {
+		return err
+	}
+	if atomic.LoadInt32(&d.draining) == 1 {
+		return errors.New("driver is shutting down")
+	}
+	if d.options.mode != NodeMode && d.controllerService.cloud == nil {
+		return errors.New("controller cloud client is not initialized")
+	}
+	if d.options.mode != ControllerMode && d.nodeService.cloud == nil {
+		return errors.New("node cloud client is not initialized")
+	}
+	return nil
+}
+
+func probeHandler(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/mocks"
+	drivermocks "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver/mocks"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func TestHealthProbes(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockCloud := mocks.NewMockCloud(mockCtl)
+
+	testCases := []struct {
+		name           string
+		mode           Mode
+		serving        bool
+		controllerInit bool
+		nodeInit       bool
+		expectHealthz  int
+		expectReadyz   int
+	}{
+		{
+			name:          "not serving",
+			mode:          AllMode,
+			expectHealthz: http.StatusServiceUnavailable,
+			expectReadyz:  http.StatusServiceUnavailable,
+		},
+		{
+			name:           "controller ready",
+			mode:           ControllerMode,
+			serving:        true,
+			controllerInit: true,
+			expectHealthz:  http.StatusOK,
+			expectReadyz:   http.StatusOK,
+		},
+		{
+			name:          "node cloud client missing",
+			mode:          NodeMode,
+			serving:       true,
+			expectHealthz: http.StatusOK,
+			expectReadyz:  http.StatusServiceUnavailable,
+		},
+		{
+			name:           "all mode without node cloud client",
+			mode:           AllMode,
+			serving:        true,
+			controllerInit: true,
+			expectHealthz:  http.StatusOK,
+			expectReadyz:   http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Driver{options: &Options{mode: tc.mode}}
+			if tc.serving {
+				atomic.StoreInt32(&d.serving, 1)
+			}
+			if tc.controllerInit {
+				d.controllerService.cloud = mockCloud
+			}
+			if tc.nodeInit {
+				d.nodeService.cloud = mockCloud
+			}
+			handler := d.httpHandler()
+			for path, expected := range map[string]int{"/healthz": tc.expectHealthz, "/readyz": tc.expectReadyz} {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				if rec.Code != expected {
+					t.Fatalf("expected %s to return %d, got %d: %s", path, expected, rec.Code, rec.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestPreStopHandler(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mode     Mode
+		nodeInit bool
+		expected int
+	}{
+		{
+			name:     "volumes unstaged",
+			mode:     NodeMode,
+			nodeInit: true,
+			expected: http.StatusOK,
+		},
+		{
+			name:     "node cloud client missing",
+			mode:     NodeMode,
+			expected: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "not served by the controller",
+			mode:     ControllerMode,
+			expected: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			d := &Driver{options: &Options{mode: tc.mode}}
+			if tc.nodeInit {
+				mockCloud := mocks.NewMockCloud(mockCtl)
+				mockCloud.EXPECT().ListDisks(gomock.Any()).Return(nil, nil)
+				mockMounter := drivermocks.NewMockMounter(mockCtl)
+				mockMounter.EXPECT().ListMultipathDevices().Return(nil, nil)
+				mockMounter.EXPECT().List().Return(nil, nil)
+				d.nodeService = nodeService{cloud: mockCloud, mounter: mockMounter, volumeLocks: util.NewVolumeLocks()}
+			}
+			rec := httptest.NewRecorder()
+			d.httpHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/prestop", nil))
+			if rec.Code != tc.expected {
+				t.Fatalf("expected /prestop to return %d, got %d: %s", tc.expected, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -18,23 +18,39 @@ package driver
 
 import (
 	"context"
+	"strings"
 
 	csi "github.com/container-storage-interface/spec/lib/go/csi"
 	"k8s.io/klog/v2"
 )
 
 func (d *Driver) GetPluginInfo(ctx context.Context, req *csi.GetPluginInfoRequest) (*csi.GetPluginInfoResponse, error) {
-	klog.V(6).Infof("GetPluginInfo: called with args %+v", *req)
+	klog.V(6).Infof("GetPluginInfo: called with args %+v", req)
 	resp := &csi.GetPluginInfoResponse{
 		Name:          DriverName,
 		VendorVersion: driverVersion,
+		Manifest:      d.manifest(),
 	}
 
 	return resp, nil
 }
 
+// manifest returns the build of the driver and its enabled features as GetPluginInfo manifest
+func (d *Driver) manifest() map[string]string {
+	version := GetVersion()
+	return map[string]string{
+		"gitCommit": version.GitCommit,
+		"buildDate": version.BuildDate,
+		"goVersion": version.GoVersion,
+		"compiler":  version.Compiler,
+		"platform":  version.Platform,
+		"mode":      string(d.options.mode),
+		"features":  strings.Join(d.options.features(), ","),
+	}
+}
+
 func (d *Driver) GetPluginCapabilities(ctx context.Context, req *csi.GetPluginCapabilitiesRequest) (*csi.GetPluginCapabilitiesResponse, error) {
-	klog.V(6).Infof("GetPluginCapabilities: called with args %+v", *req)
+	klog.V(6).Infof("GetPluginCapabilities: called with args %+v", req)
 	resp := &csi.GetPluginCapabilitiesResponse{
 		Capabilities: []*csi.PluginCapability{
 			{
@@ -53,11 +69,20 @@ func (d *Driver) GetPluginCapabilities(ctx context.Context, req *csi.GetPluginCa
 			},
 		},
 	}
+	if d.options.enabled(VolumeExpansion) {
+		resp.Capabilities = append(resp.Capabilities, &csi.PluginCapability{
+			Type: &csi.PluginCapability_VolumeExpansion_{
+				VolumeExpansion: &csi.PluginCapability_VolumeExpansion{
+					Type: csi.PluginCapability_VolumeExpansion_ONLINE,
+				},
+			},
+		})
+	}
 
 	return resp, nil
 }
 
 func (d *Driver) Probe(ctx context.Context, req *csi.ProbeRequest) (*csi.ProbeResponse, error) {
-	klog.V(6).Infof("Probe: called with args %+v", *req)
+	klog.V(6).Infof("Probe: called with args %+v", req)
 	return &csi.ProbeResponse{}, nil
 }
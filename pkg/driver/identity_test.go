@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+func TestGetPluginInfo(t *testing.T) {
+	drv := &Driver{options: &Options{
+		mode:                  ControllerMode,
+		tlsCertFile:           "tls.crt",
+		tlsClientCAFile:       "ca.crt",
+		tracing:               true,
+		tierMigrationInterval: 5 * time.Minute,
+	}}
+	resp, err := drv.GetPluginInfo(context.Background(), &csi.GetPluginInfoRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Name != DriverName {
+		t.Fatalf("expected name %q, got %q", DriverName, resp.Name)
+	}
+	manifest := resp.Manifest
+	if manifest["goVersion"] != runtime.Version() || manifest["mode"] != "controller" {
+		t.Fatalf("unexpected manifest %v", manifest)
+	}
+	if manifest["features"] != "mtls,tracing,tier-migration" {
+		t.Fatalf("expected features mtls,tracing,tier-migration, got %q", manifest["features"])
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	options := &Options{mode: NodeMode, httpEndpoint: ":8080", apiEndpoints: []string{"a", "b"}, cloudInstanceIDs: []string{"ws-1"}}
+	features := options.features()
+	if len(features) != 2 || features[0] != "metrics" || features[1] != "api-failover" {
+		t.Fatalf("expected features metrics and api-failover, got %v", features)
+	}
+}
+
+func TestGetPluginCapabilities(t *testing.T) {
+	drv := &Driver{options: &Options{}}
+	resp, err := drv.GetPluginCapabilities(context.Background(), &csi.GetPluginCapabilitiesRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var online bool
+	for _, c := range resp.GetCapabilities() {
+		if c.GetVolumeExpansion().GetType() == csi.PluginCapability_VolumeExpansion_ONLINE {
+			online = true
+		}
+	}
+	if !online {
+		t.Fatalf("expected online volume expansion capability, got %v", resp.GetCapabilities())
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"path"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/semconv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/metrics"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/tracing"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+// RequestIDMetadataKey is the gRPC metadata key of the request ID, an ID sent by the client
+// is kept and the ID is returned in the response header
+const RequestIDMetadataKey = "x-request-id"
+
+// DefaultRequestLogLevel is the klog verbosity of the request and response log lines
+const DefaultRequestLogLevel = 4
+
+// assignRequestID is a gRPC interceptor adding the request ID to the context of the handler
+func assignRequestID(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDMetadataKey); len(ids) > 0 {
+			id = ids[0]
+		}
+	}
+	if id == "" {
+		id = util.NewRequestID()
+	}
+	// fails only outside of a gRPC server, e.g. when the handler is called directly
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, id))
+	return handler(util.WithRequestID(ctx, id), req)
+}
+
+// logRequests returns a gRPC interceptor logging the method and request summary of every
+// CSI request at the klog.Level in level, and its duration, gRPC code and response summary
+// once handled. Failed requests are always logged.
+func logRequests(level *int32) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		level := klog.Level(atomic.LoadInt32(level))
+		requestID := util.RequestID(ctx)
+		klog.V(level).InfoS("CSI request", "requestID", requestID, "method", info.FullMethod, "request", summarizeRequest(req))
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		duration := time.Since(start)
+		if err != nil {
+			klog.ErrorS(err, "CSI request failed", "requestID", requestID, "method", info.FullMethod, "code", status.Code(err).String(), "duration", duration)
+			return resp, err
+		}
+		klog.V(level).InfoS("CSI response", "requestID", requestID, "method", info.FullMethod, "code", status.Code(err).String(), "duration", duration, "response", summarizeResponse(resp))
+		return resp, err
+	}
+}
+
+// watchSlowRequests returns a gRPC interceptor logging and counting the CSI requests which take
+// longer than the duration in threshold, with the time they spent in each util.Phases. Requests
+// are logged once the threshold is reached as well, so that hanging ones show up. A threshold
+// of 0 disables the watchdog.
+func watchSlowRequests(threshold *int64) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		threshold := time.Duration(atomic.LoadInt64(threshold))
+		if threshold == 0 {
+			return handler(ctx, req)
+		}
+		ctx, phases := util.WithPhases(ctx)
+		requestID := util.RequestID(ctx)
+		start := time.Now()
+		watchdog := time.AfterFunc(threshold, func() {
+			klog.InfoS("CSI request still running", "requestID", requestID, "method", info.FullMethod, "duration", time.Since(start), "threshold", threshold, "phases", phases.String())
+		})
+		resp, err := handler(ctx, req)
+		watchdog.Stop()
+		if elapsed := time.Since(start); elapsed >= threshold {
+			metrics.SlowOperations.WithLabelValues("rpc", path.Base(info.FullMethod)).Inc()
+			klog.InfoS("Slow CSI request", "requestID", requestID, "method", info.FullMethod, "duration", elapsed, "threshold", threshold, "code", status.Code(err).String(), "phases", phases.String())
+		}
+		return resp, err
+	}
+}
+
+// rejectWhileDraining returns a gRPC interceptor failing RPCs with Unavailable once draining
+// is set by Stop, so that the CO retries them on the next instance of the driver
+func rejectWhileDraining(draining *int32) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if atomic.LoadInt32(draining) == 1 {
+			return nil, status.Errorf(codes.Unavailable, "driver is shutting down, %s was not started", info.FullMethod)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// recoverPanics is a gRPC interceptor turning a panic of the handler or of the interceptors
+// chained after it into an Internal error, so a single failing request doesn't take down the
+// driver and all volumes it serves
+func recoverPanics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			klog.ErrorS(nil, "CSI request panicked", "requestID", util.RequestID(ctx), "method", info.FullMethod, "panic", r, "stack", string(debug.Stack()))
+			resp, err = nil, status.Errorf(codes.Internal, "panic in %s: %v", info.FullMethod, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// traceRequests is a gRPC interceptor running the handler in a span per CSI request, the
+// trace context sent by the client is continued
+func traceRequests(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+	service, method := splitFullMethod(info.FullMethod)
+	ctx, span := tracing.StartServer(ctx, strings.TrimPrefix(info.FullMethod, "/"),
+		semconv.RPCSystemGRPC,
+		semconv.RPCServiceKey.String(service),
+		semconv.RPCMethodKey.String(method),
+		attribute.String("csi.request_id", util.RequestID(ctx)),
+	)
+	defer func() {
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", status.Code(err).String()))
+		tracing.End(span, err)
+	}()
+	return handler(ctx, req)
+}
+
+// splitFullMethod splits "/csi.v1.Controller/CreateVolume" into the service and the method
+func splitFullMethod(fullMethod string) (service, method string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "", fullMethod
+}
+
+// metadataCarrier reads and writes the trace context in gRPC metadata
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
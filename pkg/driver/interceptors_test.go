@@ -0,0 +1,258 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/metrics"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/tracing"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func TestAssignRequestID(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Controller/DeleteVolume"}
+	var seen string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		seen = util.RequestID(ctx)
+		return nil, nil
+	}
+
+	if _, err := assignRequestID(context.Background(), nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 16 {
+		t.Fatalf("expected a generated request ID, got %q", seen)
+	}
+	generated := seen
+	if _, err := assignRequestID(context.Background(), nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if seen == generated {
+		t.Fatalf("expected a new request ID per request, got %q twice", seen)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadataKey, "provisioner-1"))
+	if _, err := assignRequestID(ctx, nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if seen != "provisioner-1" {
+		t.Fatalf("expected the request ID of the client, got %q", seen)
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Controller/DeleteVolume"}
+	level := int32(0)
+	interceptor := logRequests(&level)
+	ctx := util.WithRequestID(context.Background(), "req-1")
+	req := &csi.DeleteVolumeRequest{VolumeId: "vol-1"}
+
+	expectedErr := status.Error(grpccodes.Internal, "delete failed")
+	_, err := interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, expectedErr
+	})
+	if err != expectedErr {
+		t.Fatalf("expected error %v, got %v", expectedErr, err)
+	}
+
+	expectedResp := &csi.DeleteVolumeResponse{}
+	resp, err := interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return expectedResp, nil
+	})
+	if err != nil || resp != expectedResp {
+		t.Fatalf("expected response %v, got %v, %v", expectedResp, resp, err)
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Node/NodePublishVolume"}
+	resp, err := recoverPanics(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		var m map[string]string
+		m["target"] = "/var/lib/kubelet/pods"
+		return &csi.NodePublishVolumeResponse{}, nil
+	})
+	if resp != nil {
+		t.Fatalf("expected no response, got %v", resp)
+	}
+	if status.Code(err) != grpccodes.Internal {
+		t.Fatalf("expected Internal error, got %v", err)
+	}
+
+	expectedResp := &csi.NodePublishVolumeResponse{}
+	resp, err = recoverPanics(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return expectedResp, nil
+	})
+	if err != nil || resp != expectedResp {
+		t.Fatalf("expected response %v, got %v, %v", expectedResp, resp, err)
+	}
+}
+
+func TestRecoverPanicsOfInterceptors(t *testing.T) {
+	d := &Driver{options: &Options{}}
+	interceptors := d.unaryInterceptors()
+	if reflect.ValueOf(interceptors[0]).Pointer() != reflect.ValueOf(grpc.UnaryServerInterceptor(recoverPanics)).Pointer() {
+		t.Fatalf("expected recoverPanics to be the outermost interceptor")
+	}
+
+	// an interceptor of the chain, not the handler, panics
+	panicking := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var m map[string]string
+		m["method"] = info.FullMethod
+		return handler(ctx, req)
+	}
+	chain := append([]grpc.UnaryServerInterceptor{}, interceptors[:len(interceptors)-1]...)
+	chain = append(chain, panicking, interceptors[len(interceptors)-1])
+
+	endpoint := filepath.Join(t.TempDir(), "csi.sock")
+	listener, err := net.Listen("unix", endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(chain...))
+	csi.RegisterIdentityServer(srv, d)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	defer srv.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, "unix://"+endpoint, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := csi.NewIdentityClient(conn)
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetPluginInfo(ctx, &csi.GetPluginInfoRequest{}); status.Code(err) != grpccodes.Internal {
+			t.Fatalf("expected Internal error, got %v", err)
+		}
+	}
+}
+
+func TestTraceRequests(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}()
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	md := metadata.Pairs("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	ctx := metadata.NewIncomingContext(util.WithRequestID(context.Background(), "req-1"), md)
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Controller/ControllerPublishVolume"}
+	_, err := traceRequests(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, span := tracing.Start(ctx, "PowerVS AttachVolume")
+		span.End()
+		return nil, status.Error(grpccodes.NotFound, "volume not found")
+	})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	child, server := spans[0], spans[1]
+	if server.Name != "csi.v1.Controller/ControllerPublishVolume" || server.SpanKind != trace.SpanKindServer {
+		t.Fatalf("unexpected server span %s of kind %v", server.Name, server.SpanKind)
+	}
+	if server.SpanContext.TraceID().String() != traceID {
+		t.Fatalf("expected the trace of the client to be continued, got trace %s", server.SpanContext.TraceID())
+	}
+	if server.StatusCode != codes.Error {
+		t.Fatalf("expected error status, got %v", server.StatusCode)
+	}
+	if child.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Fatalf("expected %s to be a child of the request span", child.Name)
+	}
+}
+
+func TestSplitFullMethod(t *testing.T) {
+	service, method := splitFullMethod("/csi.v1.Node/NodeStageVolume")
+	if service != "csi.v1.Node" || method != "NodeStageVolume" {
+		t.Fatalf("expected csi.v1.Node and NodeStageVolume, got %q and %q", service, method)
+	}
+}
+
+func TestRejectWhileDraining(t *testing.T) {
+	var draining int32
+	interceptor := rejectWhileDraining(&draining)
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Controller/CreateVolume"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &csi.CreateVolumeResponse{}, nil
+	}
+	if _, err := interceptor(context.Background(), nil, info, handler); err != nil {
+		t.Fatalf("expected the request to be handled, got %v", err)
+	}
+	draining = 1
+	if _, err := interceptor(context.Background(), nil, info, handler); status.Code(err) != grpccodes.Unavailable {
+		t.Fatalf("expected Unavailable while draining, got %v", err)
+	}
+}
+
+func TestWatchSlowRequests(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Node/NodeStageVolume"}
+	threshold := int64(20 * time.Millisecond)
+	interceptor := watchSlowRequests(&threshold)
+	slow := testutil.ToFloat64(metrics.SlowOperations.WithLabelValues("rpc", "NodeStageVolume"))
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		end := util.StartPhase(ctx, util.PhaseDeviceWait)
+		time.Sleep(req.(time.Duration))
+		end()
+		return &csi.NodeStageVolumeResponse{}, nil
+	}
+	if _, err := interceptor(context.Background(), time.Duration(0), info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if delta := testutil.ToFloat64(metrics.SlowOperations.WithLabelValues("rpc", "NodeStageVolume")) - slow; delta != 0 {
+		t.Fatalf("expected no slow request, got %v", delta)
+	}
+	if _, err := interceptor(context.Background(), 30*time.Millisecond, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if delta := testutil.ToFloat64(metrics.SlowOperations.WithLabelValues("rpc", "NodeStageVolume")) - slow; delta != 1 {
+		t.Fatalf("expected 1 slow request, got %v", delta)
+	}
+
+	threshold = 0
+	if _, err := interceptor(context.Background(), 30*time.Millisecond, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if delta := testutil.ToFloat64(metrics.SlowOperations.WithLabelValues("rpc", "NodeStageVolume")) - slow; delta != 1 {
+		t.Fatalf("expected the disabled watchdog not to count, got %v", delta)
+	}
+}
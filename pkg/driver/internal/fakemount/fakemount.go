@@ -0,0 +1,179 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fakemount is a Mounter of the driver that mounts nothing, for the tests of the
+// driver. Files and directories are created for real, so that the paths of the CSI requests
+// exist, devices are named like the WWN of their volume. Host is a Mounter of scenarios with
+// devices, filesystems and failures set up by the tests.
+package fakemount
+
+import (
+	"os"
+
+	"k8s.io/utils/exec"
+	"k8s.io/utils/mount"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/fibrechannel"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+// StorageAdapter is the storage adapter of the node of Mounter, the NPIV adapter of the driver
+const StorageAdapter = "npiv"
+
+// Stats are the stats of every volume of Mounter
+var Stats = util.VolumeStats{TotalBytes: 1 << 30, AvailableBytes: 1 << 29, UsedBytes: 1 << 29, Inodes: 1000, InodesFree: 500, InodesUsed: 500}
+
+// Mounter implements the Mounter of the driver without mounting anything
+type Mounter struct {
+	mount.SafeFormatAndMount
+	exec.Interface
+}
+
+// New returns a Mounter
+func New() *Mounter {
+	return &Mounter{
+		mount.SafeFormatAndMount{
+			Interface: mount.New(""),
+			Exec:      exec.New(),
+		},
+		exec.New(),
+	}
+}
+
+func (f *Mounter) IsCorruptedMnt(err error) bool {
+	return false
+}
+
+func (f *Mounter) Mount(source string, target string, fstype string, options []string) error {
+	return nil
+}
+
+func (f *Mounter) MountSensitive(source string, target string, fstype string, options []string, sensitiveOptions []string) error {
+	return nil
+}
+
+func (f *Mounter) MountSensitiveWithoutSystemd(source string, target string, fstype string, options []string, sensitiveOptions []string) error {
+	return nil
+}
+
+func (f *Mounter) RescanSCSIBus() error {
+	return nil
+}
+
+func (f *Mounter) Unmount(target string) error {
+	return nil
+}
+
+func (f *Mounter) List() ([]mount.MountPoint, error) {
+	return []mount.MountPoint{}, nil
+}
+
+func (f *Mounter) IsLikelyNotMountPoint(file string) (bool, error) {
+	return false, nil
+}
+
+func (f *Mounter) GetMountRefs(pathname string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (f *Mounter) FormatAndMount(source string, target string, fstype string, options []string) error {
+	return nil
+}
+
+func (f *Mounter) FormatAndMountWithOptions(source string, target string, fstype string, options []string, formatOptions []string) error {
+	return nil
+}
+
+func (f *Mounter) GetDeviceNameFromMount(mountPath string) (string, int, error) {
+	return "", 0, nil
+}
+
+func (f *Mounter) MakeFile(pathname string) error {
+	file, err := os.OpenFile(pathname, os.O_CREATE, os.FileMode(0644))
+	if err != nil {
+		if !os.IsExist(err) {
+			return err
+		}
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (f *Mounter) MakeDir(pathname string) error {
+	err := os.MkdirAll(pathname, os.FileMode(0755))
+	if err != nil {
+		if !os.IsExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Mounter) ExistsPath(filename string) (bool, error) {
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *Mounter) NeedResize(source string, path string) (bool, error) {
+	return false, nil
+}
+
+func (f *Mounter) GetDeviceName(mountPath string) (string, int, error) {
+	return mount.GetDeviceNameFromMount(f, mountPath)
+}
+
+// GetDevicePath returns wwn, the fake has no devices
+func (f *Mounter) GetDevicePath(wwn string) (devicePath string, err error) {
+	return wwn, nil
+}
+
+func (f *Mounter) RescanDevice(devicePath string) error {
+	return nil
+}
+
+func (f *Mounter) ResizeFs(devicePath, deviceMountPath string) error {
+	return nil
+}
+
+func (f *Mounter) ListMultipathDevices() (map[string]fibrechannel.MultipathDevice, error) {
+	return nil, nil
+}
+
+func (f *Mounter) FindMultipathDevice(devicePath string) string {
+	return ""
+}
+
+func (f *Mounter) DetachDevice(devicePath string) error {
+	return nil
+}
+
+func (f *Mounter) RemoveMultipathDevice(devicePath string) error {
+	return nil
+}
+
+func (f *Mounter) GetStorageAdapter() (string, error) {
+	return StorageAdapter, nil
+}
+
+func (f *Mounter) GetVolumeStats(path string) (*util.VolumeStats, error) {
+	stats := Stats
+	return &stats, nil
+}
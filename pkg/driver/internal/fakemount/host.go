@@ -0,0 +1,313 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakemount
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+
+	"k8s.io/utils/exec"
+	testingexec "k8s.io/utils/exec/testing"
+	"k8s.io/utils/mount"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/fibrechannel"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+// Device is a device of the volume of a WWN attached to a Host
+type Device struct {
+	// Path is the path of the device, e.g. /dev/dm-0
+	Path string
+	// Format is the filesystem of the device, "" if it isn't formatted
+	Format string
+	// Corrupted makes the checks of the filesystem of the device find errors
+	Corrupted bool
+	// NeedResize is true if the filesystem of the device is smaller than the device
+	NeedResize bool
+}
+
+// Host implements the Mounter of the driver on a node of scenarios, the tests set up its
+// devices and the failures of its methods. Mounts are kept in memory by mount.FakeMounter,
+// files and directories are created for real like by Mounter.
+type Host struct {
+	*mount.FakeMounter
+
+	mutex    sync.Mutex
+	devices  map[string]*Device
+	failures map[string]error
+	calls    map[string]int
+}
+
+// NewHost returns a Host without devices
+func NewHost() *Host {
+	return &Host{
+		FakeMounter: &mount.FakeMounter{MountCheckErrors: map[string]error{}},
+		devices:     map[string]*Device{},
+		failures:    map[string]error{},
+		calls:       map[string]int{},
+	}
+}
+
+// AddDevice attaches device of the volume of wwn to h
+func (h *Host) AddDevice(wwn string, device Device) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.devices[wwn] = &device
+}
+
+// Device returns the device of the volume of wwn, false if it isn't attached to h
+func (h *Host) Device(wwn string) (Device, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	device, ok := h.devices[wwn]
+	if !ok {
+		return Device{}, false
+	}
+	return *device, true
+}
+
+// Fail makes the calls of method of h fail with err
+func (h *Host) Fail(method string, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.failures[method] = err
+}
+
+// Calls returns the number of calls of method of h
+func (h *Host) Calls(method string) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.calls[method]
+}
+
+// CorruptMount makes the mount at target corrupted, like the mount of a filesystem whose
+// device went away
+func (h *Host) CorruptMount(target string) {
+	h.FakeMounter.MountCheckErrors[target] = &os.PathError{Op: "stat", Path: target, Err: syscall.ENOTCONN}
+}
+
+// call counts a call of method and returns its failure
+func (h *Host) call(method string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.calls[method]++
+	return h.failures[method]
+}
+
+// device returns the device at devicePath
+func (h *Host) device(devicePath string) (string, *Device) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for wwn, device := range h.devices {
+		if device.Path == devicePath {
+			return wwn, device
+		}
+	}
+	return "", nil
+}
+
+func (h *Host) Mount(source string, target string, fstype string, options []string) error {
+	if err := h.call("Mount"); err != nil {
+		return err
+	}
+	return h.FakeMounter.Mount(source, target, fstype, options)
+}
+
+func (h *Host) Unmount(target string) error {
+	if err := h.call("Unmount"); err != nil {
+		return err
+	}
+	return h.FakeMounter.Unmount(target)
+}
+
+func (h *Host) IsCorruptedMnt(err error) bool {
+	return mount.IsCorruptedMnt(err)
+}
+
+func (h *Host) FormatAndMount(source string, target string, fstype string, options []string) error {
+	return h.FormatAndMountWithOptions(source, target, fstype, options, nil)
+}
+
+// FormatAndMountWithOptions formats source with fstype unless it is formatted already, like
+// mount.SafeFormatAndMount
+func (h *Host) FormatAndMountWithOptions(source string, target string, fstype string, options []string, formatOptions []string) error {
+	if err := h.call("FormatAndMount"); err != nil {
+		return err
+	}
+	_, device := h.device(source)
+	if device == nil {
+		return fmt.Errorf("device %s not found", source)
+	}
+	h.mutex.Lock()
+	if device.Format == "" {
+		device.Format = fstype
+	}
+	h.mutex.Unlock()
+	return h.FakeMounter.Mount(source, target, fstype, options)
+}
+
+func (h *Host) GetDiskFormat(disk string) (string, error) {
+	if err := h.call("GetDiskFormat"); err != nil {
+		return "", err
+	}
+	_, device := h.device(disk)
+	if device == nil {
+		return "", fmt.Errorf("device %s not found", disk)
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return device.Format, nil
+}
+
+func (h *Host) GetDeviceName(mountPath string) (string, int, error) {
+	return mount.GetDeviceNameFromMount(h, mountPath)
+}
+
+// GetDevicePath returns the path of the device of wwn, it fails if the volume isn't attached
+func (h *Host) GetDevicePath(wwn string) (string, error) {
+	if err := h.call("GetDevicePath"); err != nil {
+		return "", err
+	}
+	device, ok := h.Device(wwn)
+	if !ok {
+		return "", fmt.Errorf("no device found for wwn %s", wwn)
+	}
+	return device.Path, nil
+}
+
+func (h *Host) NeedResize(devicePath, deviceMountPath string) (bool, error) {
+	_, device := h.device(devicePath)
+	if device == nil {
+		return false, fmt.Errorf("device %s not found", devicePath)
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return device.NeedResize, nil
+}
+
+func (h *Host) ResizeFs(devicePath, deviceMountPath string) error {
+	if err := h.call("ResizeFs"); err != nil {
+		return err
+	}
+	_, device := h.device(devicePath)
+	if device == nil {
+		return fmt.Errorf("device %s not found", devicePath)
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	device.NeedResize = false
+	return nil
+}
+
+// FindMultipathDevice returns devicePath, the devices of Host are all multipath devices
+func (h *Host) FindMultipathDevice(devicePath string) string {
+	if _, device := h.device(devicePath); device == nil {
+		return ""
+	}
+	return devicePath
+}
+
+// DetachDevice removes the device at devicePath from h
+func (h *Host) DetachDevice(devicePath string) error {
+	if err := h.call("DetachDevice"); err != nil {
+		return err
+	}
+	wwn, device := h.device(devicePath)
+	if device == nil {
+		return fmt.Errorf("device %s not found", devicePath)
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.devices, wwn)
+	return nil
+}
+
+func (h *Host) RemoveMultipathDevice(devicePath string) error {
+	return h.DetachDevice(devicePath)
+}
+
+func (h *Host) ListMultipathDevices() (map[string]fibrechannel.MultipathDevice, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	devices := make(map[string]fibrechannel.MultipathDevice, len(h.devices))
+	for wwn, device := range h.devices {
+		devices[wwn] = fibrechannel.MultipathDevice{Path: device.Path}
+	}
+	return devices, nil
+}
+
+func (h *Host) MakeFile(pathname string) error {
+	return (&Mounter{}).MakeFile(pathname)
+}
+
+func (h *Host) MakeDir(pathname string) error {
+	return (&Mounter{}).MakeDir(pathname)
+}
+
+func (h *Host) ExistsPath(filename string) (bool, error) {
+	return (&Mounter{}).ExistsPath(filename)
+}
+
+func (h *Host) RescanSCSIBus() error {
+	return h.call("RescanSCSIBus")
+}
+
+func (h *Host) RescanDevice(devicePath string) error {
+	return h.call("RescanDevice")
+}
+
+func (h *Host) GetStorageAdapter() (string, error) {
+	return StorageAdapter, nil
+}
+
+func (h *Host) GetVolumeStats(path string) (*util.VolumeStats, error) {
+	if err := h.call("GetVolumeStats"); err != nil {
+		return nil, err
+	}
+	stats := Stats
+	return &stats, nil
+}
+
+// Command runs the filesystem checks of the devices of h, they find errors in corrupted
+// devices, other commands succeed without output
+func (h *Host) Command(cmd string, args ...string) exec.Cmd {
+	fake := &testingexec.FakeCmd{}
+	result := func() ([]byte, []byte, error) {
+		if cmd != "e2fsck" && cmd != "xfs_repair" || len(args) == 0 {
+			return nil, nil, nil
+		}
+		if _, device := h.device(args[len(args)-1]); device != nil && device.Corrupted {
+			// 4 is the status of errors left uncorrected of e2fsck, xfs_repair -n exits with 1
+			return []byte("filesystem corrupted"), nil, testingexec.FakeExitError{Status: 4}
+		}
+		return nil, nil, nil
+	}
+	fake.CombinedOutputScript = []testingexec.FakeAction{result}
+	fake.OutputScript = []testingexec.FakeAction{result}
+	fake.RunScript = []testingexec.FakeAction{result}
+	return testingexec.InitFakeCmd(fake, cmd, args...)
+}
+
+func (h *Host) CommandContext(ctx context.Context, cmd string, args ...string) exec.Cmd {
+	return h.Command(cmd, args...)
+}
+
+func (h *Host) LookPath(file string) (string, error) {
+	return "/usr/sbin/" + file, nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/metrics"
+)
+
+// timings of the controller lease, the same as the defaults of the CSI sidecars
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// namespaceFile holds the namespace of the pod, it's used when no lease namespace is set
+const namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// leaseName is the name of the Lease the controller replicas compete for
+var leaseName = strings.ReplaceAll(DriverName, ".", "-") + "-controller"
+
+// leaderLoop is a background loop of the controller that must only run on one replica at
+// a time, it runs until stopCh is closed
+type leaderLoop func(stopCh <-chan struct{})
+
+// runLeaderLoops runs loops while this replica is the leader. Without leader election the
+// replica is considered the leader and the loops run until the driver stops.
+func (d *Driver) runLeaderLoops(client kubernetes.Interface, loops []leaderLoop) error {
+	if !d.options.leaderElection {
+		d.setLeader(true)
+		for _, loop := range loops {
+			go loop(wait.NeverStop)
+		}
+		return nil
+	}
+
+	namespace, err := leaseNamespace(d.options.leaderElectionNamespace)
+	if err != nil {
+		return err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("could not get leader election identity: %v", err)
+	}
+	// a restarted pod must not take over the lease of its previous instance
+	identity := hostname + "_" + string(uuid.NewUUID())
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, namespace, leaseName, client.CoreV1(), client.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: identity})
+	if err != nil {
+		return fmt.Errorf("could not create controller lease lock: %v", err)
+	}
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            leaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.Infof("Became the leader of lease %s/%s", namespace, leaseName)
+				d.setLeader(true)
+				for _, loop := range loops {
+					go loop(ctx.Done())
+				}
+			},
+			OnStoppedLeading: func() {
+				klog.Infof("Stopped leading lease %s/%s", namespace, leaseName)
+				d.setLeader(false)
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					klog.Infof("Standing by, the leader of lease %s/%s is %s", namespace, leaseName, leader)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not create leader elector: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.stopLeaderElection = cancel
+	go func() {
+		// Run returns when the lease is lost, the replica stands by for the next election
+		for ctx.Err() == nil {
+			elector.Run(ctx)
+		}
+	}()
+	return nil
+}
+
+// leaseNamespace returns namespace, or the namespace of the pod if namespace is empty
+func leaseNamespace(namespace string) (string, error) {
+	if namespace != "" {
+		return namespace, nil
+	}
+	b, err := os.ReadFile(namespaceFile)
+	if err != nil {
+		return "", fmt.Errorf("could not get the namespace of the controller lease: %v", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func (d *Driver) setLeader(leader bool) {
+	var value int32
+	if leader {
+		value = 1
+	}
+	atomic.StoreInt32(&d.leader, value)
+	metrics.ControllerLeader.Set(float64(value))
+}
+
+// leading returns an error unless the driver is ready and the leader of the controller replicas
+func (d *Driver) leading() error {
+	if err := d.ready(); err != nil {
+		return err
+	}
+	if d.options.mode != NodeMode && atomic.LoadInt32(&d.leader) == 0 {
+		return errors.New("controller is standing by")
+	}
+	return nil
+}
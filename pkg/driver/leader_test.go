@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/mocks"
+)
+
+func TestRunLeaderLoopsWithoutElection(t *testing.T) {
+	d := &Driver{options: &Options{mode: ControllerMode}}
+	started := make(chan struct{})
+	err := d.runLeaderLoops(nil, []leaderLoop{func(stopCh <-chan struct{}) {
+		close(started)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the loop to start")
+	}
+	if atomic.LoadInt32(&d.leader) != 1 {
+		t.Fatalf("expected the single replica to be the leader")
+	}
+}
+
+// TestLeaderElection runs two controller replicas on the same lease, the standby one takes
+// over the loops once the leader stops
+func TestLeaderElection(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	newReplica := func() (*Driver, chan (<-chan struct{})) {
+		d := &Driver{options: &Options{mode: ControllerMode, leaderElection: true, leaderElectionNamespace: "kube-system"}}
+		running := make(chan (<-chan struct{}), 1)
+		err := d.runLeaderLoops(client, []leaderLoop{func(stopCh <-chan struct{}) {
+			running <- stopCh
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d, running
+	}
+
+	first, firstRunning := newReplica()
+	var stopCh <-chan struct{}
+	select {
+	case stopCh = <-firstRunning:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for the first replica to lead")
+	}
+	second, secondRunning := newReplica()
+	defer second.stopLeaderElection()
+	select {
+	case <-secondRunning:
+		t.Fatalf("expected the second replica to stand by")
+	case <-time.After(3 * time.Second):
+	}
+	if atomic.LoadInt32(&second.leader) != 0 {
+		t.Fatalf("expected the second replica not to be the leader")
+	}
+
+	first.stopLeaderElection()
+	select {
+	case <-stopCh:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for the loop of the first replica to stop")
+	}
+	select {
+	case <-secondRunning:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for the second replica to take over")
+	}
+	if atomic.LoadInt32(&second.leader) != 1 {
+		t.Fatalf("expected the second replica to be the leader")
+	}
+}
+
+func TestLeaderProbe(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	d := &Driver{options: &Options{mode: ControllerMode}}
+	d.controllerService.cloud = mocks.NewMockCloud(mockCtl)
+	atomic.StoreInt32(&d.serving, 1)
+	handler := d.httpHandler()
+	for _, leader := range []bool{false, true} {
+		d.setLeader(leader)
+		expected := http.StatusServiceUnavailable
+		if leader {
+			expected = http.StatusOK
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz/leader", nil))
+		if rec.Code != expected {
+			t.Fatalf("expected /readyz/leader of leader %v to return %d, got %d: %s", leader, expected, rec.Code, rec.Body.String())
+		}
+	}
+}
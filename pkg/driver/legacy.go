@@ -0,0 +1,49 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// translateLegacyHandle returns the volume handle of the driver for handle, which may be a
+// legacy handle of the form ibmpowervs://<region>/<zone>/<cloud instance id>/<volume id>
+// used by pre-CSI PVs, like the provider IDs of the nodes. The volume ID is lower cased like
+// the IDs returned by the PowerVS API, other handles are returned as is.
+func (d *controllerService) translateLegacyHandle(handle string) (string, error) {
+	if !strings.HasPrefix(handle, cloud.ProviderIDPrefix) {
+		return handle, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(handle, cloud.ProviderIDPrefix), "/")
+	if len(parts) != 4 || parts[2] == "" || parts[3] == "" {
+		return "", status.Errorf(codes.InvalidArgument, "Invalid legacy volume handle %q", handle)
+	}
+	cloudInstanceID, volumeID := parts[2], strings.ToLower(parts[3])
+	if d.workspaces == nil {
+		// the workspace of the controller is the only one, a volume of another workspace
+		// would resolve to a volume ID of the wrong workspace
+		if d.cloudInstanceID != "" && !strings.EqualFold(cloudInstanceID, d.cloudInstanceID) {
+			return "", status.Errorf(codes.InvalidArgument, "Legacy volume handle %q is not of the workspace %q of the controller", handle, d.cloudInstanceID)
+		}
+		return volumeID, nil
+	}
+	return d.workspaces.VolumeHandle(cloudInstanceID, volumeID), nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	mocks "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/mocks"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func TestTranslateLegacyHandle(t *testing.T) {
+	workspaces := cloud.NewWorkspaces("ws-1", nil, []string{"ws-2"}, nil)
+	testCases := []struct {
+		name            string
+		handle          string
+		cloudInstanceID string
+		workspaces      *cloud.Workspaces
+		expected        string
+		expectErr       bool
+	}{
+		{name: "volume ID", handle: "vol-1", expected: "vol-1"},
+		{name: "single workspace", handle: "ibmpowervs://us-south/dal12/ws-1/VOL-1", cloudInstanceID: "ws-1", expected: "vol-1"},
+		{name: "single workspace without ID", handle: "ibmpowervs://us-south/dal12/ws-1/vol-1", expected: "vol-1"},
+		{name: "single workspace other workspace", handle: "ibmpowervs://us-south/dal12/ws-2/vol-1", cloudInstanceID: "ws-1", expectErr: true},
+		{name: "default workspace", handle: "ibmpowervs://us-south/dal12/ws-1/vol-1", workspaces: workspaces, expected: "vol-1"},
+		{name: "other workspace", handle: "ibmpowervs://us-south/dal12/ws-2/vol-1", workspaces: workspaces, expected: "ws-2/vol-1"},
+		{name: "missing volume ID", handle: "ibmpowervs://us-south/dal12/ws-1/", expectErr: true},
+		{name: "missing zone", handle: "ibmpowervs://us-south/ws-1/vol-1", expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &controllerService{cloudInstanceID: tc.cloudInstanceID, workspaces: tc.workspaces}
+			handle, err := d.translateLegacyHandle(tc.handle)
+			if tc.expectErr {
+				if status.Code(err) != codes.InvalidArgument {
+					t.Fatalf("expected InvalidArgument, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if handle != tc.expected {
+				t.Fatalf("expected handle %q, got %q", tc.expected, handle)
+			}
+		})
+	}
+}
+
+func TestDeleteVolumeLegacyHandle(t *testing.T) {
+	handle := "ibmpowervs://us-south/dal12/ws-1/vol-1"
+	for _, legacy := range []bool{true, false} {
+		mockCtl := gomock.NewController(t)
+		mockCloud := mocks.NewMockCloud(mockCtl)
+		volumeID := handle
+		if legacy {
+			volumeID = "vol-1"
+		}
+		mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeID)).Return(&cloud.Disk{VolumeID: volumeID}, nil)
+		mockCloud.EXPECT().DeleteDisk(gomock.Any(), gomock.Eq(volumeID)).Return(true, nil)
+		powervsDriver := controllerService{
+			cloud:         mockCloud,
+			driverOptions: &Options{legacyVolumeHandles: legacy},
+			volumeLocks:   util.NewVolumeLocks(),
+		}
+		if _, err := powervsDriver.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: handle}); err != nil {
+			t.Fatalf("unexpected error with legacyVolumeHandles %v: %v", legacy, err)
+		}
+		mockCtl.Finish()
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+// volumeLockRetryDelay is the delay hinted to the CO for retrying an RPC aborted because of
+// an operation in progress on its volume
+const volumeLockRetryDelay = 5 * time.Second
+
+// acquireVolumeLock acquires the lock of key, waiting up to the volume lock timeout of options
+// or until ctx is done for the operation in progress on it. Once the wait is over, Aborted is
+// returned with a RetryInfo detail so that the CO retries the RPC later.
+func acquireVolumeLock(ctx context.Context, locks *util.VolumeLocks, key string, options *Options) error {
+	var timeout time.Duration
+	if options != nil {
+		timeout = options.volumeLockTimeout
+	}
+	if err := locks.AcquireWithTimeout(ctx, key, timeout); err != nil {
+		st := status.Newf(codes.Aborted, util.VolumeOperationAlreadyExistsFmt, key)
+		if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(volumeLockRetryDelay)}); err == nil {
+			st = detailed
+		}
+		return st.Err()
+	}
+	return nil
+}
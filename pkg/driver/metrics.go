@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/metrics"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+// operations of metrics.VolumeOperationDuration
+const (
+	volumeOperationProvision = "provision"
+	volumeOperationAttach    = "attach"
+	volumeOperationDetach    = "detach"
+)
+
+// phaseTotal is the phase of metrics.VolumeOperationDuration covering the whole operation
+const phaseTotal = "total"
+
+// recordMetrics is a gRPC interceptor recording the count, latency and in-flight number of
+// the CSI RPCs by method, e.g. CreateVolume
+func recordMetrics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	method := path.Base(info.FullMethod)
+	inFlight := metrics.OperationsInFlight.WithLabelValues(method)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	metrics.OperationDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	metrics.Operations.WithLabelValues(method, status.Code(err).String()).Inc()
+	return resp, err
+}
+
+// observeVolumeOperation returns a copy of ctx recording the phases of the volume operation,
+// the returned function observes its total, PowerVS API and volume wait durations by the
+// gRPC code of err
+func observeVolumeOperation(ctx context.Context, operation string) (context.Context, func(err error)) {
+	ctx, phases := util.WithPhases(ctx)
+	start := time.Now()
+	return ctx, func(err error) {
+		code := status.Code(err).String()
+		metrics.VolumeOperationDuration.WithLabelValues(operation, phaseTotal, code).Observe(time.Since(start).Seconds())
+		durations := phases.Durations()
+		for _, phase := range []string{util.PhaseAPI, util.PhaseVolumeWait} {
+			metrics.VolumeOperationDuration.WithLabelValues(operation, phase, code).Observe(durations[phase].Seconds())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/metrics"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func TestRecordMetrics(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Controller/CreateVolume"}
+	succeeded := testutil.ToFloat64(metrics.Operations.WithLabelValues("CreateVolume", "OK"))
+	failed := testutil.ToFloat64(metrics.Operations.WithLabelValues("CreateVolume", "InvalidArgument"))
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		if inFlight := testutil.ToFloat64(metrics.OperationsInFlight.WithLabelValues("CreateVolume")); inFlight != 1 {
+			t.Fatalf("expected 1 operation in flight, got %v", inFlight)
+		}
+		if req == nil {
+			return nil, status.Error(codes.InvalidArgument, "missing request")
+		}
+		return req, nil
+	}
+	if _, err := recordMetrics(context.Background(), "req", info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recordMetrics(context.Background(), nil, info, handler); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if delta := testutil.ToFloat64(metrics.Operations.WithLabelValues("CreateVolume", "OK")) - succeeded; delta != 1 {
+		t.Fatalf("expected 1 succeeded operation, got %v", delta)
+	}
+	if delta := testutil.ToFloat64(metrics.Operations.WithLabelValues("CreateVolume", "InvalidArgument")) - failed; delta != 1 {
+		t.Fatalf("expected 1 failed operation, got %v", delta)
+	}
+	if inFlight := testutil.ToFloat64(metrics.OperationsInFlight.WithLabelValues("CreateVolume")); inFlight != 0 {
+		t.Fatalf("expected no operation in flight, got %v", inFlight)
+	}
+}
+
+// volumeOperationSamples returns the number of observations of the volume operation phase
+// with the gRPC code
+func volumeOperationSamples(t *testing.T, operation, phase, code string) uint64 {
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("could not gather metrics: %v", err)
+	}
+	labels := map[string]string{"operation": operation, "phase": phase, "grpc_code": code}
+	for _, family := range families {
+		if family.GetName() != "powervs_csi_volume_operation_duration_seconds" {
+			continue
+		}
+	metrics:
+		for _, m := range family.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if labels[l.GetName()] != l.GetValue() {
+					continue metrics
+				}
+			}
+			return m.GetHistogram().GetSampleCount()
+		}
+	}
+	return 0
+}
+
+func TestObserveVolumeOperation(t *testing.T) {
+	phases := []string{phaseTotal, util.PhaseAPI, util.PhaseVolumeWait}
+	before := map[string]uint64{}
+	for _, phase := range phases {
+		before[phase] = volumeOperationSamples(t, volumeOperationAttach, phase, "Aborted")
+	}
+
+	ctx, outer := util.WithPhases(context.Background())
+	ctx, observe := observeVolumeOperation(ctx, volumeOperationAttach)
+	util.StartPhase(ctx, util.PhaseAPI)()
+	observe(status.Error(codes.Aborted, "in progress"))
+
+	for _, phase := range phases {
+		if delta := volumeOperationSamples(t, volumeOperationAttach, phase, "Aborted") - before[phase]; delta != 1 {
+			t.Fatalf("expected 1 observation of phase %s, got %d", phase, delta)
+		}
+	}
+	// the phases of the operation are still recorded for the slow request watchdog
+	if _, ok := outer.Durations()[util.PhaseAPI]; !ok {
+		t.Fatalf("expected the api phase in the phases of the request, got %v", outer.Durations())
+	}
+}
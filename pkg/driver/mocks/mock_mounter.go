@@ -11,6 +11,8 @@ import (
 	gomock "github.com/golang/mock/gomock"
 	exec "k8s.io/utils/exec"
 	mount "k8s.io/utils/mount"
+	fibrechannel "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/fibrechannel"
+	util "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
 )
 
 // MockMounter is a mock of Mounter interface.
@@ -74,6 +76,20 @@ func (mr *MockMounterMockRecorder) CommandContext(ctx, cmd interface{}, args ...
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommandContext", reflect.TypeOf((*MockMounter)(nil).CommandContext), varargs...)
 }
 
+// DetachDevice mocks base method.
+func (m *MockMounter) DetachDevice(devicePath string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DetachDevice", devicePath)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DetachDevice indicates an expected call of DetachDevice.
+func (mr *MockMounterMockRecorder) DetachDevice(devicePath interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DetachDevice", reflect.TypeOf((*MockMounter)(nil).DetachDevice), devicePath)
+}
+
 // ExistsPath mocks base method.
 func (m *MockMounter) ExistsPath(filename string) (bool, error) {
 	m.ctrl.T.Helper()
@@ -89,6 +105,20 @@ func (mr *MockMounterMockRecorder) ExistsPath(filename interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExistsPath", reflect.TypeOf((*MockMounter)(nil).ExistsPath), filename)
 }
 
+// FindMultipathDevice mocks base method.
+func (m *MockMounter) FindMultipathDevice(devicePath string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindMultipathDevice", devicePath)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// FindMultipathDevice indicates an expected call of FindMultipathDevice.
+func (mr *MockMounterMockRecorder) FindMultipathDevice(devicePath interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindMultipathDevice", reflect.TypeOf((*MockMounter)(nil).FindMultipathDevice), devicePath)
+}
+
 // FormatAndMount mocks base method.
 func (m *MockMounter) FormatAndMount(source, target, fstype string, options []string) error {
 	m.ctrl.T.Helper()
@@ -103,6 +133,20 @@ func (mr *MockMounterMockRecorder) FormatAndMount(source, target, fstype, option
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FormatAndMount", reflect.TypeOf((*MockMounter)(nil).FormatAndMount), source, target, fstype, options)
 }
 
+// FormatAndMountWithOptions mocks base method.
+func (m *MockMounter) FormatAndMountWithOptions(source, target, fstype string, options, formatOptions []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FormatAndMountWithOptions", source, target, fstype, options, formatOptions)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FormatAndMountWithOptions indicates an expected call of FormatAndMountWithOptions.
+func (mr *MockMounterMockRecorder) FormatAndMountWithOptions(source, target, fstype, options, formatOptions interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FormatAndMountWithOptions", reflect.TypeOf((*MockMounter)(nil).FormatAndMountWithOptions), source, target, fstype, options, formatOptions)
+}
+
 // GetDeviceName mocks base method.
 func (m *MockMounter) GetDeviceName(mountPath string) (string, int, error) {
 	m.ctrl.T.Helper()
@@ -134,6 +178,21 @@ func (mr *MockMounterMockRecorder) GetDevicePath(wwn interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDevicePath", reflect.TypeOf((*MockMounter)(nil).GetDevicePath), wwn)
 }
 
+// GetDiskFormat mocks base method.
+func (m *MockMounter) GetDiskFormat(disk string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDiskFormat", disk)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDiskFormat indicates an expected call of GetDiskFormat.
+func (mr *MockMounterMockRecorder) GetDiskFormat(disk interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiskFormat", reflect.TypeOf((*MockMounter)(nil).GetDiskFormat), disk)
+}
+
 // GetMountRefs mocks base method.
 func (m *MockMounter) GetMountRefs(pathname string) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -149,6 +208,36 @@ func (mr *MockMounterMockRecorder) GetMountRefs(pathname interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMountRefs", reflect.TypeOf((*MockMounter)(nil).GetMountRefs), pathname)
 }
 
+// GetStorageAdapter mocks base method.
+func (m *MockMounter) GetStorageAdapter() (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStorageAdapter")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStorageAdapter indicates an expected call of GetStorageAdapter.
+func (mr *MockMounterMockRecorder) GetStorageAdapter() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStorageAdapter", reflect.TypeOf((*MockMounter)(nil).GetStorageAdapter))
+}
+
+// GetVolumeStats mocks base method.
+func (m *MockMounter) GetVolumeStats(path string) (*util.VolumeStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVolumeStats", path)
+	ret0, _ := ret[0].(*util.VolumeStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVolumeStats indicates an expected call of GetVolumeStats.
+func (mr *MockMounterMockRecorder) GetVolumeStats(path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVolumeStats", reflect.TypeOf((*MockMounter)(nil).GetVolumeStats), path)
+}
+
 // IsLikelyNotMountPoint mocks base method.
 func (m *MockMounter) IsLikelyNotMountPoint(file string) (bool, error) {
 	m.ctrl.T.Helper()
@@ -179,6 +268,21 @@ func (mr *MockMounterMockRecorder) List() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockMounter)(nil).List))
 }
 
+// ListMultipathDevices mocks base method.
+func (m *MockMounter) ListMultipathDevices() (map[string]fibrechannel.MultipathDevice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMultipathDevices")
+	ret0, _ := ret[0].(map[string]fibrechannel.MultipathDevice)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMultipathDevices indicates an expected call of ListMultipathDevices.
+func (mr *MockMounterMockRecorder) ListMultipathDevices() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMultipathDevices", reflect.TypeOf((*MockMounter)(nil).ListMultipathDevices))
+}
+
 // LookPath mocks base method.
 func (m *MockMounter) LookPath(file string) (string, error) {
 	m.ctrl.T.Helper()
@@ -250,6 +354,49 @@ func (mr *MockMounterMockRecorder) MountSensitive(source, target, fstype, option
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MountSensitive", reflect.TypeOf((*MockMounter)(nil).MountSensitive), source, target, fstype, options, sensitiveOptions)
 }
 
+// NeedResize mocks base method.
+func (m *MockMounter) NeedResize(devicePath, deviceMountPath string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NeedResize", devicePath, deviceMountPath)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NeedResize indicates an expected call of NeedResize.
+func (mr *MockMounterMockRecorder) NeedResize(devicePath, deviceMountPath interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NeedResize", reflect.TypeOf((*MockMounter)(nil).NeedResize), devicePath, deviceMountPath)
+}
+
+// RemoveMultipathDevice mocks base method.
+func (m *MockMounter) RemoveMultipathDevice(devicePath string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMultipathDevice", devicePath)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMultipathDevice indicates an expected call of RemoveMultipathDevice.
+func (mr *MockMounterMockRecorder) RemoveMultipathDevice(devicePath interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMultipathDevice", reflect.TypeOf((*MockMounter)(nil).RemoveMultipathDevice), devicePath)
+}
+
+// RescanDevice mocks base method.
+func (m *MockMounter) RescanDevice(devicePath string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RescanDevice", devicePath)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RescanDevice indicates an expected call of RescanDevice.
+func (mr *MockMounterMockRecorder) RescanDevice(devicePath interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RescanDevice", reflect.TypeOf((*MockMounter)(nil).RescanDevice), devicePath)
+}
+
 // RescanSCSIBus mocks base method.
 func (m *MockMounter) RescanSCSIBus() error {
 	m.ctrl.T.Helper()
@@ -264,6 +411,20 @@ func (mr *MockMounterMockRecorder) RescanSCSIBus() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RescanSCSIBus", reflect.TypeOf((*MockMounter)(nil).RescanSCSIBus))
 }
 
+// ResizeFs mocks base method.
+func (m *MockMounter) ResizeFs(devicePath, deviceMountPath string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResizeFs", devicePath, deviceMountPath)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResizeFs indicates an expected call of ResizeFs.
+func (mr *MockMounterMockRecorder) ResizeFs(devicePath, deviceMountPath interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResizeFs", reflect.TypeOf((*MockMounter)(nil).ResizeFs), devicePath, deviceMountPath)
+}
+
 // Unmount mocks base method.
 func (m *MockMounter) Unmount(target string) error {
 	m.ctrl.T.Helper()
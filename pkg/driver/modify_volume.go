@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver/params"
+)
+
+// mutableParameters are the StorageClass parameters a VolumeAttributesClass may set, PowerVS
+// only changes the tier of existing volumes
+var mutableParameters = map[string]bool{
+	VolumeTypeKey: true,
+}
+
+// validateMutableParameters checks that parameters only holds mutable parameters with valid
+// values
+func validateMutableParameters(parameters map[string]string) error {
+	var unknown []string
+	for key := range parameters {
+		if !mutableParameters[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return status.Errorf(codes.InvalidArgument, "Parameters %s can't be modified, only %s can", strings.Join(unknown, ", "), VolumeTypeKey)
+	}
+	if tier, ok := parameters[VolumeTypeKey]; ok && !params.IsValidVolumeType(tier) {
+		return status.Errorf(codes.InvalidArgument, "Invalid %s %q, valid values: %v", VolumeTypeKey, tier, cloud.ValidVolumeTypes)
+	}
+	return nil
+}
+
+// createParameters returns the parameters of a CreateVolume request, the mutable parameters
+// of its VolumeAttributesClass take precedence over those of the StorageClass
+func (d *controllerService) createParameters(req *csi.CreateVolumeRequest) (map[string]string, error) {
+	mutable := req.GetMutableParameters()
+	if len(mutable) == 0 {
+		return req.GetParameters(), nil
+	}
+	if !d.driverOptions.enabled(VolumeAttributesClass) {
+		return nil, status.Errorf(codes.InvalidArgument, "Mutable parameters are disabled by the %s feature gate", VolumeAttributesClass)
+	}
+	if err := validateMutableParameters(mutable); err != nil {
+		return nil, err
+	}
+	parameters := make(map[string]string, len(req.GetParameters())+len(mutable))
+	for k, v := range req.GetParameters() {
+		parameters[k] = v
+	}
+	for k, v := range mutable {
+		parameters[k] = v
+	}
+	return parameters, nil
+}
+
+// ControllerModifyVolume applies the mutable parameters of a VolumeAttributesClass to a
+// volume. A new type starts the tier migration of the volume in PowerVS, which moves the data
+// in the background while the volume stays usable.
+func (d *controllerService) ControllerModifyVolume(ctx context.Context, req *csi.ControllerModifyVolumeRequest) (*csi.ControllerModifyVolumeResponse, error) {
+	klog.V(4).Infof("ControllerModifyVolume: called with args %s", summarizeRequest(req))
+	if !d.driverOptions.enabled(VolumeAttributesClass) {
+		return nil, status.Errorf(codes.Unimplemented, "ControllerModifyVolume is disabled by the %s feature gate", VolumeAttributesClass)
+	}
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+	if err := validateMutableParameters(req.GetMutableParameters()); err != nil {
+		return nil, err
+	}
+
+	if err := acquireVolumeLock(ctx, d.volumeLocks, volumeID, d.driverOptions); err != nil {
+		return nil, err
+	}
+	defer d.volumeLocks.Release(volumeID)
+
+	c, diskID, err := d.cloudForVolume(volumeID, req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+	disk, err := c.GetDiskByID(ctx, diskID)
+	if err != nil {
+		if err == cloud.ErrNotFound {
+			return nil, status.Errorf(codes.NotFound, "Volume %q not found", volumeID)
+		}
+		return nil, cloudError(err, "Could not get volume %q: %v", volumeID, err)
+	}
+
+	tier, ok := req.GetMutableParameters()[VolumeTypeKey]
+	if !ok || disk.DiskType == tier {
+		return &csi.ControllerModifyVolumeResponse{}, nil
+	}
+	if err := c.UpdateDiskTier(ctx, diskID, tier); err != nil {
+		return nil, cloudError(err, "Could not change the type of volume %q from %s to %s: %v", volumeID, disk.DiskType, tier, err)
+	}
+	klog.V(4).Infof("ControllerModifyVolume: migrating volume %s from %s to %s", volumeID, disk.DiskType, tier)
+	return &csi.ControllerModifyVolumeResponse{}, nil
+}
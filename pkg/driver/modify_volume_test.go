@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func TestControllerModifyVolume(t *testing.T) {
+	testCases := []struct {
+		name       string
+		gates      FeatureGates
+		volumeID   string
+		parameters map[string]string
+		expCode    codes.Code
+		expTier    string
+	}{
+		{
+			name:       "new type",
+			parameters: map[string]string{VolumeTypeKey: cloud.VolumeTypeTier1},
+			expTier:    cloud.VolumeTypeTier1,
+		},
+		{
+			name:       "same type",
+			parameters: map[string]string{VolumeTypeKey: cloud.VolumeTypeTier3},
+			expTier:    cloud.VolumeTypeTier3,
+		},
+		{
+			name:       "disabled",
+			gates:      FeatureGates{VolumeAttributesClass: false},
+			parameters: map[string]string{VolumeTypeKey: cloud.VolumeTypeTier1},
+			expCode:    codes.Unimplemented,
+		},
+		{
+			name:       "immutable parameter",
+			parameters: map[string]string{IOPSParameterKey: "1000"},
+			expCode:    codes.InvalidArgument,
+		},
+		{
+			name:       "invalid type",
+			parameters: map[string]string{VolumeTypeKey: "tier9"},
+			expCode:    codes.InvalidArgument,
+		},
+		{
+			name:       "unknown volume",
+			volumeID:   "vol-unknown",
+			parameters: map[string]string{VolumeTypeKey: cloud.VolumeTypeTier1},
+			expCode:    codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeCloud := fake.NewCloud(0)
+			disk := createFakeDisks(t, fakeCloud, 1)[0]
+
+			d := newBatchingControllerService(fakeCloud, 0)
+			d.driverOptions.featureGates = FeatureGates{VolumeAttributesClass: true}
+			if tc.gates != nil {
+				d.driverOptions.featureGates = tc.gates
+			}
+			volumeID := disk.VolumeID
+			if tc.volumeID != "" {
+				volumeID = tc.volumeID
+			}
+			_, err := d.ControllerModifyVolume(context.Background(), &csi.ControllerModifyVolumeRequest{VolumeId: volumeID, MutableParameters: tc.parameters})
+			if code := status.Code(err); code != tc.expCode {
+				t.Fatalf("Expected code %v, got %v", tc.expCode, err)
+			}
+			if tc.expCode != codes.OK {
+				return
+			}
+			modified, err := fakeCloud.GetDiskByID(context.Background(), disk.VolumeID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if modified.DiskType != tc.expTier {
+				t.Fatalf("Expected volume of type %s, got %s", tc.expTier, modified.DiskType)
+			}
+		})
+	}
+}
+
+func TestCreateVolumeMutableParameters(t *testing.T) {
+	testCases := []struct {
+		name    string
+		gates   FeatureGates
+		expCode codes.Code
+		expTier string
+	}{
+		{
+			name:    "enabled",
+			gates:   FeatureGates{VolumeAttributesClass: true},
+			expTier: cloud.VolumeTypeTier1,
+		},
+		{
+			name:    "disabled",
+			expCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeCloud := fake.NewCloud(0)
+			d := newBatchingControllerService(fakeCloud, 0)
+			d.driverOptions.featureGates = tc.gates
+			resp, err := d.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name:               "vol-mutable",
+				CapacityRange:      &csi.CapacityRange{RequiredBytes: util.GiB},
+				VolumeCapabilities: []*csi.VolumeCapability{mountCapability("")},
+				Parameters:         map[string]string{VolumeTypeKey: cloud.VolumeTypeTier3},
+				MutableParameters:  map[string]string{VolumeTypeKey: cloud.VolumeTypeTier1},
+			})
+			if code := status.Code(err); code != tc.expCode {
+				t.Fatalf("Expected code %v, got %v", tc.expCode, err)
+			}
+			if tc.expCode != codes.OK {
+				return
+			}
+			disk, err := fakeCloud.GetDiskByID(context.Background(), resp.GetVolume().GetVolumeId())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if disk.DiskType != tc.expTier {
+				t.Fatalf("Expected volume of type %s, got %s", tc.expTier, disk.DiskType)
+			}
+		})
+	}
+}
+
+func TestSingleNodeAccessModes(t *testing.T) {
+	capability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER},
+	}
+	for _, enabled := range []bool{false, true} {
+		o := &Options{featureGates: FeatureGates{SingleNodeMultiWriter: enabled}}
+		if valid := isValidVolumeCapabilities(o.accessModes(), []*csi.VolumeCapability{capability}); valid != enabled {
+			t.Errorf("Expected SINGLE_NODE_SINGLE_WRITER valid %v with SingleNodeMultiWriter %v, got %v", enabled, enabled, valid)
+		}
+	}
+}
@@ -20,11 +20,16 @@ import (
 	"fmt"
 	"os"
 	goexec "os/exec"
+	"strconv"
+	"strings"
+	"syscall"
 
 	"k8s.io/klog/v2"
+	mountutils "k8s.io/mount-utils"
 	"k8s.io/utils/exec"
 	"k8s.io/utils/mount"
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/fibrechannel"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
 )
 
 // Mounter is an interface for mount operations
@@ -32,12 +37,23 @@ type Mounter interface {
 	mount.Interface
 	exec.Interface
 	FormatAndMount(source string, target string, fstype string, options []string) error
+	FormatAndMountWithOptions(source string, target string, fstype string, options []string, formatOptions []string) error
+	GetDiskFormat(disk string) (string, error)
 	GetDeviceName(mountPath string) (string, int, error)
 	MakeFile(pathname string) error
 	MakeDir(pathname string) error
 	ExistsPath(filename string) (bool, error)
 	RescanSCSIBus() error
 	GetDevicePath(wwn string) (string, error)
+	RescanDevice(devicePath string) error
+	ResizeFs(devicePath, deviceMountPath string) error
+	NeedResize(devicePath, deviceMountPath string) (bool, error)
+	ListMultipathDevices() (map[string]fibrechannel.MultipathDevice, error)
+	FindMultipathDevice(devicePath string) string
+	DetachDevice(devicePath string) error
+	RemoveMultipathDevice(devicePath string) error
+	GetStorageAdapter() (string, error)
+	GetVolumeStats(path string) (*util.VolumeStats, error)
 }
 
 type NodeMounter struct {
@@ -55,6 +71,31 @@ func newNodeMounter() Mounter {
 	}
 }
 
+// FormatAndMountWithOptions is FormatAndMount formatting an unformatted source with the mkfs
+// options formatOptions
+func (m *NodeMounter) FormatAndMountWithOptions(source string, target string, fstype string, options []string, formatOptions []string) error {
+	if len(formatOptions) == 0 {
+		return m.FormatAndMount(source, target, fstype, options)
+	}
+	existingFormat, err := m.GetDiskFormat(source)
+	if err != nil {
+		return fmt.Errorf("failed to get disk format of disk %s: %v", source, err)
+	}
+	if existingFormat == "" {
+		// the options of FormatAndMount come first, formatOptions may override them
+		var args []string
+		if fstype == FSTypeExt3 || fstype == FSTypeExt4 {
+			args = []string{"-F", "-m0"}
+		}
+		args = append(append(args, formatOptions...), source)
+		klog.Infof("Formatting %s as %s with options %v", source, fstype, args)
+		if out, err := m.Exec.Command("mkfs."+fstype, args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("format of disk %s as %s with options %v failed: %v: %s", source, fstype, args, err, out)
+		}
+	}
+	return m.Mount(source, target, fstype, append(options, "defaults"))
+}
+
 func (m *NodeMounter) RescanSCSIBus() error {
 	cmd := goexec.Command("/usr/bin/rescan-scsi-bus.sh")
 	stdoutStderr, err := cmd.CombinedOutput()
@@ -73,6 +114,76 @@ func (m *NodeMounter) GetDevicePath(wwn string) (devicePath string, err error) {
 	return fibrechannel.Attach(c, &fibrechannel.OSioHandler{})
 }
 
+// RescanDevice makes the node pick up the new size of the grown volume at devicePath
+func (m *NodeMounter) RescanDevice(devicePath string) error {
+	return fibrechannel.Rescan(devicePath, &fibrechannel.OSioHandler{})
+}
+
+// ResizeFs grows the filesystem of devicePath mounted at deviceMountPath to the size of the device
+func (m *NodeMounter) ResizeFs(devicePath, deviceMountPath string) error {
+	_, err := mountutils.NewResizeFs(m.Exec).Resize(devicePath, deviceMountPath)
+	return err
+}
+
+// NeedResize returns true if the filesystem of devicePath mounted at deviceMountPath is smaller
+// than the device
+func (m *NodeMounter) NeedResize(devicePath, deviceMountPath string) (bool, error) {
+	return mountutils.NewResizeFs(m.Exec).NeedResize(devicePath, deviceMountPath)
+}
+
+// ListMultipathDevices returns the multipath devices of the node by the WWN of their volume
+func (m *NodeMounter) ListMultipathDevices() (map[string]fibrechannel.MultipathDevice, error) {
+	devices, err := fibrechannel.ListMultipathDevices(&fibrechannel.OSioHandler{})
+	if err != nil {
+		return nil, err
+	}
+	byWWN := make(map[string]fibrechannel.MultipathDevice, len(devices))
+	for wwid, device := range devices {
+		// the WWIDs of PowerVS volumes are their WWN prepended with a 3
+		byWWN[strings.ToLower(strings.TrimPrefix(wwid, "3"))] = device
+	}
+	return byWWN, nil
+}
+
+// FindMultipathDevice returns the multipath device of the scsi device at devicePath, or "" if
+// it is not part of one
+func (m *NodeMounter) FindMultipathDevice(devicePath string) string {
+	mdev, _ := fibrechannel.FindMultipathDeviceForDevice(devicePath, &fibrechannel.OSioHandler{})
+	return mdev
+}
+
+// DetachDevice removes the scsi device at devicePath from the node
+func (m *NodeMounter) DetachDevice(devicePath string) error {
+	return fibrechannel.Detach(devicePath, &fibrechannel.OSioHandler{})
+}
+
+// RemoveMultipathDevice removes the multipath device at devicePath and its scsi devices from the node
+func (m *NodeMounter) RemoveMultipathDevice(devicePath string) error {
+	if err := fibrechannel.Detach(devicePath, &fibrechannel.OSioHandler{}); err != nil {
+		return err
+	}
+	return fibrechannel.RemoveMultipathDevice(devicePath)
+}
+
+// GetStorageAdapter returns the adapter type the volumes of the node are attached through,
+// adapterNPIV or adapterVSCSI, or "" if neither is found
+func (m *NodeMounter) GetStorageAdapter() (string, error) {
+	drivers, err := fibrechannel.ScsiHostDrivers(&fibrechannel.OSioHandler{})
+	if err != nil {
+		return "", err
+	}
+	adapter := ""
+	for _, driver := range drivers {
+		switch driver {
+		case "ibmvfc":
+			return adapterNPIV, nil
+		case "ibmvscsi":
+			adapter = adapterVSCSI
+		}
+	}
+	return adapter, nil
+}
+
 func (m *NodeMounter) GetDeviceName(mountPath string) (string, int, error) {
 	return mount.GetDeviceNameFromMount(m, mountPath)
 }
@@ -108,3 +219,37 @@ func (m *NodeMounter) ExistsPath(filename string) (bool, error) {
 	}
 	return true, nil
 }
+
+// GetVolumeStats returns the stats of the filesystem mounted at path, or the size of the block
+// device published at path
+func (m *NodeMounter) GetVolumeStats(path string) (*util.VolumeStats, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeDevice != 0 {
+		out, err := m.Exec.Command("blockdev", "--getsize64", path).CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get size of block device %s: %v: %s", path, err, out)
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse size %q of block device %s: %v", out, path, err)
+		}
+		return &util.VolumeStats{Block: true, TotalBytes: size}, nil
+	}
+
+	var statfs syscall.Statfs_t
+	if err := syscall.Statfs(path, &statfs); err != nil {
+		return nil, fmt.Errorf("failed to statfs %s: %v", path, err)
+	}
+	bsize := int64(statfs.Bsize)
+	return &util.VolumeStats{
+		TotalBytes:     int64(statfs.Blocks) * bsize,
+		AvailableBytes: int64(statfs.Bavail) * bsize,
+		UsedBytes:      int64(statfs.Blocks-statfs.Bfree) * bsize,
+		Inodes:         int64(statfs.Files),
+		InodesFree:     int64(statfs.Ffree),
+		InodesUsed:     int64(statfs.Files - statfs.Ffree),
+	}, nil
+}
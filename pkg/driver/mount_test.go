@@ -17,7 +17,12 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+
+	"k8s.io/utils/exec"
+	testingexec "k8s.io/utils/exec/testing"
+	"k8s.io/utils/mount"
 )
 
 func TestMakeDir(t *testing.T) {
@@ -112,3 +117,33 @@ func TestGetDeviceName(t *testing.T) {
 	}
 
 }
+
+func TestFormatAndMountWithOptions(t *testing.T) {
+	var commands [][]string
+	record := func(out []byte, err error) testingexec.FakeCommandAction {
+		return func(cmd string, args ...string) exec.Cmd {
+			commands = append(commands, append([]string{cmd}, args...))
+			return &testingexec.FakeCmd{CombinedOutputScript: []testingexec.FakeAction{
+				func() ([]byte, []byte, error) { return out, nil, err },
+			}}
+		}
+	}
+	fakeExec := &testingexec.FakeExec{CommandScript: []testingexec.FakeCommandAction{
+		// blkid finds no filesystem
+		record(nil, &testingexec.FakeExitError{Status: 2}),
+		record(nil, nil),
+	}}
+	fakeMounter := mount.NewFakeMounter(nil)
+	m := &NodeMounter{mount.SafeFormatAndMount{Interface: fakeMounter, Exec: fakeExec}, fakeExec}
+
+	if err := m.FormatAndMountWithOptions("/dev/dm-0", "/mnt", FSTypeExt4, nil, []string{"-E", "nodiscard"}); err != nil {
+		t.Fatalf("Expect no error but got: %v", err)
+	}
+	expMkfs := []string{"mkfs.ext4", "-F", "-m0", "-E", "nodiscard", "/dev/dm-0"}
+	if len(commands) != 2 || !reflect.DeepEqual(commands[1], expMkfs) {
+		t.Fatalf("Expected mkfs command %v, got commands %v", expMkfs, commands)
+	}
+	if mounts, _ := fakeMounter.List(); len(mounts) != 1 || mounts[0].Path != "/mnt" {
+		t.Fatalf("Expected the volume mounted at /mnt, got %v", mounts)
+	}
+}
@@ -18,18 +18,20 @@ package driver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"k8s.io/klog/v2"
-	mountutils "k8s.io/mount-utils"
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
-	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/fibrechannel"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/tracing"
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
 )
 
@@ -46,53 +48,70 @@ const (
 	// default file system type to be used when it is not provided
 	defaultFsType = "ext4"
 
-	// defaultMaxVolumesPerInstance is the limit of volumes can be attached in the PowerVS environment
-	// TODO: rightnow 99 is just a placeholder, this needs to be changed post discussion with PowerVS team
-	defaultMaxVolumesPerInstance = 127 - 1
-)
-
-var (
-	// nodeCaps represents the capability of node service.
-	nodeCaps = []csi.NodeServiceCapability_RPC_Type{
-		csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
-		csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
-	}
+	// defaultMaxVolumesPerInstance is the limit of data volumes can be attached in the PowerVS
+	// environment, the boot volume takes one of the LUNs of the adapter
+	defaultMaxVolumesPerInstance = npivVolumeLimit - 1
 )
 
 // nodeService represents the node service of CSI driver
 type nodeService struct {
+	// the RPCs of newer CSI specs fail with Unimplemented until they are implemented
+	csi.UnimplementedNodeServer
+
 	cloud         cloud.Cloud
 	mounter       Mounter
 	driverOptions *Options
 	pvmInstanceId string
-	volumeLocks   *util.VolumeLocks
+	// cloudInstanceID is the workspace of the node, reported in its topology
+	cloudInstanceID string
+	volumeLocks     *util.VolumeLocks
+	// volumeStats caches the stats of the published volumes
+	volumeStats *statsCache
+	// host are the tools found on the node
+	host *hostFeatures
 }
 
 // newNodeService creates a new node service
 // it panics if failed to create the service
 func newNodeService(driverOptions *Options) nodeService {
 	klog.V(4).Infof("retrieving node info from metadata service")
-	metadata, err := cloud.NewMetadataService(cloud.DefaultKubernetesAPIClient)
+	metadata, err := driverOptions.instanceMetadata()
 	if err != nil {
 		panic(err)
 	}
 
-	pvsCloud, err := NewPowerVSCloudFunc(metadata.GetCloudInstanceId(), driverOptions.debug)
-	if err != nil {
-		panic(err)
+	pvsCloud := driverOptions.cloud
+	if pvsCloud == nil {
+		pvsCloud, err = driverOptions.newCloud(metadata.GetCloudInstanceId(), driverOptions.cloudOptions()...)
+		if err != nil {
+			panic(err)
+		}
 	}
 
+	pvmInstanceId := metadata.GetPvmInstanceId()
+	if pvmInstanceId == "" {
+		klog.Infof("%s node label is not set, discovering the pvm instance", cloud.PvmInstanceIdLabel)
+		pvmInstanceId, err = cloud.DiscoverPvmInstanceID(context.TODO(), pvsCloud, os.Getenv("CSI_NODE_NAME"))
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	mounter := newNodeMounter()
 	return nodeService{
-		cloud:         pvsCloud,
-		mounter:       newNodeMounter(),
-		driverOptions: driverOptions,
-		pvmInstanceId: metadata.GetPvmInstanceId(),
-		volumeLocks:   util.NewVolumeLocks(),
+		cloud:           pvsCloud,
+		mounter:         mounter,
+		host:            probeHostFeatures(mounter),
+		driverOptions:   driverOptions,
+		pvmInstanceId:   pvmInstanceId,
+		cloudInstanceID: metadata.GetCloudInstanceId(),
+		volumeLocks:     util.NewVolumeLocks(),
+		volumeStats:     newStatsCache(driverOptions.volumeStatsCacheTTL),
 	}
 }
 
 func (d *nodeService) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
-	klog.V(4).Infof("NodeStageVolume: called with args %+v", *req)
+	klog.V(4).Infof("NodeStageVolume: called with args %s", summarizeRequest(req))
 
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
@@ -104,8 +123,8 @@ func (d *nodeService) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 		return nil, status.Error(codes.InvalidArgument, "Volume capability not provided")
 	}
 
-	if acquired := d.volumeLocks.TryAcquire(volumeID); !acquired {
-		return nil, status.Errorf(codes.Aborted, util.VolumeOperationAlreadyExistsFmt, volumeID)
+	if err := acquireVolumeLock(ctx, d.volumeLocks, volumeID, d.driverOptions); err != nil {
+		return nil, err
 	}
 	defer d.volumeLocks.Release(volumeID)
 
@@ -126,7 +145,7 @@ func (d *nodeService) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 		if os.IsNotExist(err) {
 			needsCreateDir = true
 		} else {
-			return nil, err
+			return nil, status.Errorf(codes.Internal, "could not check if %q is mounted: %v", target, err)
 		}
 	}
 
@@ -137,7 +156,7 @@ func (d *nodeService) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 		}
 	}
 
-	if !isValidVolumeCapabilities([]*csi.VolumeCapability{volCap}) {
+	if !isValidVolumeCapabilities(d.driverOptions.accessModes(), []*csi.VolumeCapability{volCap}) {
 		return nil, status.Error(codes.InvalidArgument, "Volume capability not supported")
 	}
 
@@ -169,7 +188,11 @@ func (d *nodeService) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 		return nil, status.Error(codes.InvalidArgument, "WWN ID is not provided or empty")
 	}
 
+	_, span := tracing.Start(ctx, "GetDevicePath", attribute.String("wwn", wwn))
+	endPhase := util.StartPhase(ctx, util.PhaseDeviceWait)
 	source, err := d.mounter.GetDevicePath(wwn)
+	endPhase()
+	tracing.End(span, err)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to find device path %s. %v", wwn, err)
 	}
@@ -191,9 +214,84 @@ func (d *nodeService) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 		return &csi.NodeStageVolumeResponse{}, nil
 	}
 
-	// FormatAndMount will format only if needed
-	klog.V(5).Infof("NodeStageVolume: formatting %s and mounting at %s with fstype %s", source, target, fsType)
-	err = d.mounter.FormatAndMount(source, target, fsType, mountOptions)
+	preFormatted, err := isPreFormatted(req.GetVolumeContext())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	fsckPolicy := d.driverOptions.fsckPolicy
+	if value, ok := req.GetVolumeContext()[FsckPolicyKey]; ok {
+		if fsckPolicy, err = ParseFsckPolicy(value); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if preFormatted && fsckPolicy == FsckPolicyRepair {
+		// the filesystem of a pre-formatted volume is mounted as is, it is only checked read-only
+		fsckPolicy = FsckPolicyFail
+	}
+
+	// probe the device with blkid first, existing data must never be formatted
+	existingFormat, err := d.mounter.GetDiskFormat(source)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not determine the filesystem of %q: %v", source, err)
+	}
+	if existingFormat == "" && preFormatted {
+		return nil, status.Errorf(codes.FailedPrecondition, "volume %s is %s but %q has no filesystem", volumeID, PreFormattedKey, source)
+	}
+	if existingFormat != "" && existingFormat != fsType {
+		return nil, status.Errorf(codes.FailedPrecondition, "volume %s has filesystem %s, expected %s", volumeID, existingFormat, fsType)
+	}
+	if existingFormat != "" {
+		// the filesystem may not have been unmounted cleanly, e.g. when its last node crashed
+		_, span := tracing.Start(ctx, "CheckFilesystem", attribute.String("source", source), attribute.String("policy", string(fsckPolicy)))
+		err = checkFilesystem(d.mounter, source, fsType, fsckPolicy)
+		tracing.End(span, err)
+		if errors.Is(err, errFilesystemCorrupted) {
+			return nil, status.Errorf(codes.FailedPrecondition, "volume %s isn't mounted with fsck policy %s: %v", volumeID, fsckPolicy, err)
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "could not check the filesystem of %q: %v", source, err)
+		}
+
+		klog.V(5).Infof("NodeStageVolume: mounting %s with existing filesystem at %s with fstype %s", source, target, fsType)
+		_, span = tracing.Start(ctx, "Mount", attribute.String("source", source), attribute.String("fsType", fsType))
+		endPhase := util.StartPhase(ctx, util.PhaseMount)
+		err = d.mounter.Mount(source, target, fsType, mountOptions)
+		endPhase()
+		tracing.End(span, err)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "could not mount %q at %q: %v", source, target, err)
+		}
+		if preFormatted {
+			return &csi.NodeStageVolumeResponse{}, nil
+		}
+		// the filesystem of a volume restored from a snapshot or cloned is the size of the source
+		needResize, err := d.mounter.NeedResize(source, target)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "could not determine if volume %s needs resizing: %v", volumeID, err)
+		}
+		if needResize {
+			klog.V(4).Infof("NodeStageVolume: growing the filesystem of %s mounted at %s to the size of the volume", source, target)
+			if err := d.mounter.ResizeFs(source, target); err != nil {
+				return nil, status.Errorf(codes.Internal, "could not resize volume %q (%q): %v", volumeID, source, err)
+			}
+		}
+		return &csi.NodeStageVolumeResponse{}, nil
+	}
+
+	if !d.host.canFormat(fsType) {
+		return nil, status.Errorf(codes.FailedPrecondition, "volume %s can't be formatted, mkfs.%s isn't installed on the node", volumeID, fsType)
+	}
+	formatOptions := d.formatOptions(fsType, req.GetVolumeContext())
+	klog.V(5).Infof("NodeStageVolume: formatting %s with options %v and mounting at %s with fstype %s", source, formatOptions, target, fsType)
+	_, span = tracing.Start(ctx, "FormatAndMount", attribute.String("source", source), attribute.String("fsType", fsType))
+	endPhase = util.StartPhase(ctx, util.PhaseMount)
+	if len(formatOptions) > 0 {
+		err = d.mounter.FormatAndMountWithOptions(source, target, fsType, mountOptions, formatOptions)
+	} else {
+		err = d.mounter.FormatAndMount(source, target, fsType, mountOptions)
+	}
+	endPhase()
+	tracing.End(span, err)
 	if err != nil {
 		msg := fmt.Sprintf("could not format %q and mnt it at %q", source, target)
 		return nil, status.Error(codes.Internal, msg)
@@ -203,14 +301,14 @@ func (d *nodeService) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 }
 
 func (d *nodeService) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
-	klog.V(4).Infof("NodeUnstageVolume: called with args %+v", *req)
+	klog.V(4).Infof("NodeUnstageVolume: called with args %+v", req)
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
-	if acquired := d.volumeLocks.TryAcquire(volumeID); !acquired {
-		return nil, status.Errorf(codes.Aborted, util.VolumeOperationAlreadyExistsFmt, volumeID)
+	if err := acquireVolumeLock(ctx, d.volumeLocks, volumeID, d.driverOptions); err != nil {
+		return nil, err
 	}
 	defer d.volumeLocks.Release(volumeID)
 
@@ -241,43 +339,64 @@ func (d *nodeService) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstag
 	}
 
 	klog.V(5).Infof("NodeUnstageVolume: unmounting %s", target)
+	_, span := tracing.Start(ctx, "Unmount")
+	endPhase := util.StartPhase(ctx, util.PhaseMount)
 	err = d.mounter.Unmount(target)
+	endPhase()
+	tracing.End(span, err)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Could not unmount target %q: %v", target, err)
 	}
-	handler := &fibrechannel.OSioHandler{}
 	var mpath bool
-	if mdev, _ := fibrechannel.FindMultipathDeviceForDevice(dev, handler); mdev != "" {
+	if !d.driverOptions.enabled(Multipath) {
+		klog.V(5).Infof("Multipath is disabled, not looking up the multipath device of %s", dev)
+	} else if mdev := d.mounter.FindMultipathDevice(dev); mdev != "" {
 		klog.V(5).Infof("Multipath device found: %s for %s", mdev, dev)
 		mpath = true
 		dev = mdev
 	}
 	klog.Infof("Detaching: %s", dev)
-	err = fibrechannel.Detach(dev, handler)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to detach %s: %v", dev, err)
-	}
+	_, span = tracing.Start(ctx, "DetachDevice", attribute.String("device", dev))
+	endPhase = util.StartPhase(ctx, util.PhaseDetachDevice)
 	if mpath {
 		klog.Infof("Deleting the multipath device: %s", dev)
-		if err := fibrechannel.RemoveMultipathDevice(dev); err != nil {
-			return nil, err
-		}
+		err = d.mounter.RemoveMultipathDevice(dev)
+	} else {
+		err = d.mounter.DetachDevice(dev)
+	}
+	endPhase()
+	tracing.End(span, err)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to detach %s: %v", dev, err)
 	}
 	return &csi.NodeUnstageVolumeResponse{}, nil
 }
 
 func (d *nodeService) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
-	klog.V(4).Infof("NodeExpandVolume: called with args %+v", *req)
+	klog.V(4).Infof("NodeExpandVolume: called with args %+v", req)
+	if !d.driverOptions.enabled(VolumeExpansion) {
+		return nil, status.Errorf(codes.Unimplemented, "NodeExpandVolume is disabled by the %s feature gate", VolumeExpansion)
+	}
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
 	}
 
-	if acquired := d.volumeLocks.TryAcquire(volumeID); !acquired {
-		return nil, status.Errorf(codes.Aborted, util.VolumeOperationAlreadyExistsFmt, volumeID)
+	if err := acquireVolumeLock(ctx, d.volumeLocks, volumeID, d.driverOptions); err != nil {
+		return nil, err
 	}
 	defer d.volumeLocks.Release(volumeID)
 
+	// the expansion of a shareable volume is repeated on every node it's published to, and
+	// retried after a node unpublished it
+	exists, err := d.mounter.ExistsPath(req.GetVolumePath())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not check volume path %q: %v", req.GetVolumePath(), err)
+	}
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "Volume path %q of volume %q not found", req.GetVolumePath(), volumeID)
+	}
+
 	args := []string{"-o", "source", "--noheadings", "--target", req.GetVolumePath()}
 	output, err := d.mounter.Command("findmnt", args...).Output()
 	if err != nil {
@@ -286,23 +405,42 @@ func (d *nodeService) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandV
 	}
 
 	devicePath := strings.TrimSpace(string(output))
+	block := req.GetVolumeCapability().GetBlock() != nil
+	if block {
+		devicePath = blockDevicePath(devicePath)
+	}
 	if len(devicePath) == 0 {
 		return nil, status.Errorf(codes.Internal, "Could not get valid device for mount path: %q", req.GetVolumePath())
 	}
 
-	// TODO: refactor Mounter to expose a mount.SafeFormatAndMount object
-	r := mountutils.NewResizeFs(d.mounter.(*NodeMounter).Exec)
-
-	// TODO: lock per volume ID to have some idempotency
-	if _, err := r.Resize(devicePath, req.GetVolumePath()); err != nil {
+	// the volume stays attached while it's grown, the node only sees the new size after a rescan
+	if err := d.mounter.RescanDevice(devicePath); err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not rescan volume %q (%q): %v", volumeID, devicePath, err)
+	}
+	if block {
+		d.volumeStats.invalidate(req.GetVolumePath())
+		return &csi.NodeExpandVolumeResponse{}, nil
+	}
+	// growing a filesystem that already has the size of its device is a no-op
+	if err := d.mounter.ResizeFs(devicePath, req.GetVolumePath()); err != nil {
 		return nil, status.Errorf(codes.Internal, "Could not resize volume %q (%q):  %v", volumeID, devicePath, err)
 	}
+	d.volumeStats.invalidate(req.GetVolumePath())
 
 	return &csi.NodeExpandVolumeResponse{}, nil
 }
 
+// blockDevicePath returns the device of the findmnt source of a published raw block volume,
+// the device file bind mounted from devtmpfs like "devtmpfs[/dm-3]"
+func blockDevicePath(source string) string {
+	if i := strings.Index(source, "["); i >= 0 && strings.HasSuffix(source, "]") {
+		return "/dev" + source[i+1:len(source)-1]
+	}
+	return source
+}
+
 func (d *nodeService) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
-	klog.V(4).Infof("NodePublishVolume: called with args %+v", *req)
+	klog.V(4).Infof("NodePublishVolume: called with args %s", summarizeRequest(req))
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
@@ -319,8 +457,8 @@ func (d *nodeService) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 	}
 
 	// Acquire a lock on the target path instead of volumeID, since we do not want to serialize multiple node publish calls on the same volume.
-	if acquired := d.volumeLocks.TryAcquire(target); !acquired {
-		return nil, status.Errorf(codes.Aborted, util.VolumeOperationAlreadyExistsFmt, target)
+	if err := acquireVolumeLock(ctx, d.volumeLocks, target, d.driverOptions); err != nil {
+		return nil, err
 	}
 	defer d.volumeLocks.Release(target)
 
@@ -329,7 +467,7 @@ func (d *nodeService) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 		return nil, status.Error(codes.InvalidArgument, "Volume capability not provided")
 	}
 
-	if !isValidVolumeCapabilities([]*csi.VolumeCapability{volCap}) {
+	if !isValidVolumeCapabilities(d.driverOptions.accessModes(), []*csi.VolumeCapability{volCap}) {
 		return nil, status.Error(codes.InvalidArgument, "Volume capability not supported")
 	}
 
@@ -338,22 +476,25 @@ func (d *nodeService) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 		mountOptions = append(mountOptions, "ro")
 	}
 
+	_, span := tracing.Start(ctx, "BindMount")
+	defer util.StartPhase(ctx, util.PhaseMount)()
+	var err error
 	switch mode := volCap.GetAccessType().(type) {
 	case *csi.VolumeCapability_Block:
-		if err := d.nodePublishVolumeForBlock(req, mountOptions); err != nil {
-			return nil, err
-		}
+		err = d.nodePublishVolumeForBlock(req, mountOptions)
 	case *csi.VolumeCapability_Mount:
-		if err := d.nodePublishVolumeForFileSystem(req, mountOptions, mode); err != nil {
-			return nil, err
-		}
+		err = d.nodePublishVolumeForFileSystem(req, mountOptions, mode)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		return nil, err
 	}
 
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
 func (d *nodeService) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
-	klog.V(4).Infof("NodeUnpublishVolume: called with args %+v", *req)
+	klog.V(4).Infof("NodeUnpublishVolume: called with args %+v", req)
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
@@ -365,8 +506,8 @@ func (d *nodeService) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 	}
 
 	// Acquire a lock on the target path instead of volumeID, since we do not want to serialize multiple node publish calls on the same volume.
-	if acquired := d.volumeLocks.TryAcquire(target); !acquired {
-		return nil, status.Errorf(codes.Aborted, util.VolumeOperationAlreadyExistsFmt, target)
+	if err := acquireVolumeLock(ctx, d.volumeLocks, target, d.driverOptions); err != nil {
+		return nil, err
 	}
 	defer d.volumeLocks.Release(target)
 
@@ -375,18 +516,86 @@ func (d *nodeService) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Could not unmount %q: %v", target, err)
 	}
+	d.volumeStats.invalidate(target)
 
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
+// NodeGetVolumeStats returns the capacity and usage of the filesystem of a volume, or the size
+// of a raw block volume. The stats are cached for the --volume-stats-cache-ttl.
 func (d *nodeService) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "NodeGetVolumeStats is not implemented yet")
+	klog.V(4).Infof("NodeGetVolumeStats: called with args %+v", req)
+	if !d.driverOptions.volumeStats {
+		return nil, status.Error(codes.Unimplemented, "Volume stats are disabled on the node")
+	}
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+	volumePath := req.GetVolumePath()
+	if len(volumePath) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume path not provided")
+	}
+
+	exists, err := d.mounter.ExistsPath(volumePath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not check volume path %q: %v", volumePath, err)
+	}
+	if !exists {
+		d.volumeStats.invalidate(volumePath)
+		return nil, status.Errorf(codes.NotFound, "Volume path %q of volume %q not found", volumePath, volumeID)
+	}
+
+	condition := d.driverOptions.enabled(NodeVolumeCondition)
+	stats, err := d.volumeStats.get(volumePath, d.mounter.GetVolumeStats)
+	if err != nil {
+		if condition {
+			// the volume is there but unusable, like a filesystem whose device is gone
+			return &csi.NodeGetVolumeStatsResponse{
+				VolumeCondition: &csi.VolumeCondition{Abnormal: true, Message: fmt.Sprintf("Could not get stats of volume at %s: %v", volumePath, err)},
+			}, nil
+		}
+		return nil, status.Errorf(codes.Internal, "Could not get stats of volume %q at %q: %v", volumeID, volumePath, err)
+	}
+	var resp *csi.NodeGetVolumeStatsResponse
+	if stats.Block {
+		resp = &csi.NodeGetVolumeStatsResponse{
+			Usage: []*csi.VolumeUsage{{Unit: csi.VolumeUsage_BYTES, Total: stats.TotalBytes}},
+		}
+	} else {
+		resp = &csi.NodeGetVolumeStatsResponse{
+			Usage: []*csi.VolumeUsage{
+				{Unit: csi.VolumeUsage_BYTES, Total: stats.TotalBytes, Available: stats.AvailableBytes, Used: stats.UsedBytes},
+				{Unit: csi.VolumeUsage_INODES, Total: stats.Inodes, Available: stats.InodesFree, Used: stats.InodesUsed},
+			},
+		}
+	}
+	if condition {
+		resp.VolumeCondition = d.volumeCondition(volumePath, stats.Block)
+	}
+	return resp, nil
+}
+
+// volumeCondition returns the condition of the volume published or staged at volumePath, a
+// filesystem volume whose path is no longer a mount point is abnormal
+func (d *nodeService) volumeCondition(volumePath string, block bool) *csi.VolumeCondition {
+	if block {
+		return &csi.VolumeCondition{Message: "Volume is healthy"}
+	}
+	notMnt, err := d.mounter.IsLikelyNotMountPoint(volumePath)
+	if err != nil {
+		return &csi.VolumeCondition{Abnormal: true, Message: fmt.Sprintf("Could not check the mount point %s: %v", volumePath, err)}
+	}
+	if notMnt {
+		return &csi.VolumeCondition{Abnormal: true, Message: fmt.Sprintf("Volume path %s is not mounted", volumePath)}
+	}
+	return &csi.VolumeCondition{Message: "Volume is healthy"}
 }
 
 func (d *nodeService) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
-	klog.V(4).Infof("NodeGetCapabilities: called with args %+v", *req)
+	klog.V(4).Infof("NodeGetCapabilities: called with args %+v", req)
 	var caps []*csi.NodeServiceCapability
-	for _, cap := range nodeCaps {
+	for _, cap := range d.nodeCapabilities() {
 		c := &csi.NodeServiceCapability{
 			Type: &csi.NodeServiceCapability_Rpc{
 				Rpc: &csi.NodeServiceCapability_RPC{
@@ -400,20 +609,27 @@ func (d *nodeService) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetC
 }
 
 func (d *nodeService) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
-	klog.V(4).Infof("NodeGetInfo: called with args %+v", *req)
+	klog.V(4).Infof("NodeGetInfo: called with args %+v", req)
 
-	in, err := d.cloud.GetPVMInstanceByID(d.pvmInstanceId)
+	in, err := d.cloud.GetPVMInstanceByID(ctx, d.pvmInstanceId)
 	if err != nil {
 		klog.Errorf("failed to get the instance for pvmInstanceId %s, err: %s", d.pvmInstanceId, err)
-		return nil, fmt.Errorf("failed to get the instance for pvmInstanceId %s, err: %s", d.pvmInstanceId, err)
+		return nil, cloudError(err, "failed to get the instance for pvmInstanceId %s, err: %s", d.pvmInstanceId, err)
 	}
-	image, err := d.cloud.GetImageByID(in.ImageID)
+	image, err := d.cloud.GetImageByID(ctx, in.ImageID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get the image details for %s, err: %s", in.ImageID, err)
+		return nil, cloudError(err, "failed to get the image details for %s, err: %s", in.ImageID, err)
 	}
 
 	segments := map[string]string{
-		DiskTypeKey: image.DiskType,
+		// report the tier in the form of the type parameter of volumes
+		DiskTypeKey: strings.ToLower(image.DiskType),
+	}
+	if d.cloudInstanceID != "" {
+		segments[WorkspaceTopologyKey] = d.cloudInstanceID
+	}
+	if d.driverOptions.enabled(StoragePoolTopology) && d.driverOptions.storagePool != "" {
+		segments[StoragePoolTopologyKey] = d.driverOptions.storagePool
 	}
 
 	topology := &csi.Topology{Segments: segments}
@@ -508,11 +724,19 @@ func (d *nodeService) nodePublishVolumeForFileSystem(req *csi.NodePublishVolumeR
 	return nil
 }
 
-// getVolumesLimit returns the limit of volumes that the node supports
+// getVolumesLimit returns the limit of volumes that the node supports, which depends on the
+// adapter its volumes are attached through
 func (d *nodeService) getVolumesLimit() int64 {
 	if d.driverOptions.volumeAttachLimit >= 0 {
 		return d.driverOptions.volumeAttachLimit
 	}
+	adapter, err := d.mounter.GetStorageAdapter()
+	if err != nil {
+		klog.Warningf("could not detect the storage adapter of the node, assuming %s: %v", adapterNPIV, err)
+	}
+	if limit, ok := adapterVolumeLimits[adapter]; ok {
+		return limit - 1
+	}
 	return defaultMaxVolumesPerInstance
 }
 
@@ -543,3 +767,29 @@ func (d *nodeService) isDirMounted(target string) (bool, error) {
 	}
 	return false, nil
 }
+
+// formatOptions returns the mkfs options of a volume of filesystem fsType, the ones of the volume
+// context or else the ext4 format options of the node for ext filesystems
+func (d *nodeService) formatOptions(fsType string, volumeContext map[string]string) []string {
+	if value, ok := volumeContext[FormatOptionsKey]; ok {
+		return strings.Fields(value)
+	}
+	switch fsType {
+	case FSTypeExt2, FSTypeExt3, FSTypeExt4:
+		return strings.Fields(d.driverOptions.ext4FormatOptions)
+	}
+	return nil
+}
+
+// isPreFormatted returns the value of PreFormattedKey in the volume context
+func isPreFormatted(volumeContext map[string]string) (bool, error) {
+	value, ok := volumeContext[PreFormattedKey]
+	if !ok {
+		return false, nil
+	}
+	preFormatted, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value %q: %v", PreFormattedKey, value, err)
+	}
+	return preFormatted, nil
+}
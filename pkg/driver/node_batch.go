@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"sync"
+	"time"
+
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// nodeBatches collects the attachments or detaches of volumes of a node requested within a
+// window, so that the controller handles them together instead of one after the other in the
+// node queue. Pods with many volumes get all of them published at once, and drained nodes get
+// their volumes detached at once.
+type nodeBatches struct {
+	window time.Duration
+	mu     sync.Mutex
+	// pending are the batches still collecting volumes
+	pending map[nodeBatchKey]*nodeBatch
+}
+
+// nodeBatchKey identifies the batches of a node, the volumes of different workspaces and
+// credentials are batched separately
+type nodeBatchKey struct {
+	// workspace is the cloud instance ID of the volumes, the key of the client for the
+	// credentials of the secrets of a request
+	workspace string
+	nodeID    string
+}
+
+type nodeBatch struct {
+	// diskIDs are the PowerVS IDs of the disks, disks are only set for attachments
+	diskIDs []string
+	disks   []*cloud.Disk
+	// results receive the error of the disk of the same index
+	results []chan error
+}
+
+// batchWorkspace returns the workspace of the batches of the volume handle published or
+// unpublished with secrets
+func (d *controllerService) batchWorkspace(handle string, secrets map[string]string) string {
+	if apikey := secrets[SecretAPIKeyKey]; apikey != "" {
+		return secretCloudKey(apikey, secrets[SecretCloudInstanceIDKey])
+	}
+	if d.driverOptions.legacyVolumeHandles {
+		if translated, err := d.translateLegacyHandle(handle); err == nil {
+			handle = translated
+		}
+	}
+	if cloudInstanceID, _ := cloud.SplitVolumeHandle(handle); cloudInstanceID != "" {
+		return cloudInstanceID
+	}
+	return d.cloudInstanceID
+}
+
+func newNodeBatches(window time.Duration) *nodeBatches {
+	return &nodeBatches{window: window, pending: map[nodeBatchKey]*nodeBatch{}}
+}
+
+// add adds disk to the pending batch of the node of key, the first disk of a batch starts its
+// window. Once the window is over run is called with the disks of the batch and must return
+// their errors. The returned channel receives the error of disk.
+func (b *nodeBatches) add(key nodeBatchKey, diskID string, disk *cloud.Disk, run func(diskIDs []string, disks []*cloud.Disk) []error) <-chan error {
+	result := make(chan error, 1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	batch, ok := b.pending[key]
+	if !ok {
+		batch = &nodeBatch{}
+		b.pending[key] = batch
+		time.AfterFunc(b.window, func() {
+			b.mu.Lock()
+			delete(b.pending, key)
+			b.mu.Unlock()
+			// no disks are added to the batch once it's no longer pending
+			for i, err := range run(batch.diskIDs, batch.disks) {
+				batch.results[i] <- err
+			}
+		})
+	}
+	batch.diskIDs = append(batch.diskIDs, diskID)
+	batch.disks = append(batch.disks, disk)
+	batch.results = append(batch.results, result)
+	return result
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+	"k8s.io/utils/mount"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/fibrechannel"
+)
+
+// staleDeviceCleanupTimeout bounds the cleanup of stale devices at startup
+const staleDeviceCleanupTimeout = 5 * time.Minute
+
+// preStopTimeout bounds the unstaging of the volumes by the preStop hook of the node plugin
+const preStopTimeout = 2 * time.Minute
+
+// cleanupStaleDevices unmounts the volumes of the workspace which are no longer attached to
+// the node and removes their multipath and scsi devices. They are left behind when volumes
+// are detached while the node plugin is down, e.g. after a node crash, and block staging the
+// volumes again. Devices of volumes PowerVS doesn't list, like the boot volume, are kept. The
+// disk of a device is read again under its volume lock before the device is removed, so that
+// a volume attached to the node since the volumes were listed keeps its device.
+func (d *nodeService) cleanupStaleDevices(ctx context.Context) error {
+	disks, err := d.cloud.ListDisks(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list volumes: %v", err)
+	}
+	detached := make(map[string]*cloud.Disk)
+	for _, disk := range disks {
+		if disk.WWN != "" && !attachedTo(disk, d.pvmInstanceId) {
+			detached[disk.WWN] = disk
+		}
+	}
+	devices, err := d.mounter.ListMultipathDevices()
+	if err != nil {
+		return fmt.Errorf("could not list multipath devices: %v", err)
+	}
+	mounts, err := d.mounter.List()
+	if err != nil {
+		return fmt.Errorf("could not list mounts: %v", err)
+	}
+
+	var failed int
+	for wwn, device := range devices {
+		disk, ok := detached[wwn]
+		if !ok {
+			continue
+		}
+		if !d.volumeLocks.TryAcquire(disk.VolumeID) {
+			klog.Infof("Skipping the cleanup of stale device %s, an operation on volume %s is in progress", device.Path, disk.VolumeID)
+			continue
+		}
+		err := d.removeDetachedDevice(ctx, disk, device, mounts)
+		d.volumeLocks.Release(disk.VolumeID)
+		if err != nil {
+			klog.Errorf("Could not clean up stale device %s of volume %s: %v", device.Path, disk.VolumeID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("could not clean up %d stale devices", failed)
+	}
+	return nil
+}
+
+// removeDetachedDevice removes device of disk with removeStaleDevice unless disk is attached
+// to the node again, a deleted disk is no longer attached
+func (d *nodeService) removeDetachedDevice(ctx context.Context, disk *cloud.Disk, device fibrechannel.MultipathDevice, mounts []mount.MountPoint) error {
+	current, err := d.cloud.GetDiskByID(ctx, disk.VolumeID)
+	if err != nil && !errors.Is(err, cloud.ErrNotFound) {
+		return fmt.Errorf("could not get volume: %v", err)
+	}
+	if err == nil && attachedTo(current, d.pvmInstanceId) {
+		klog.Infof("Keeping device %s, volume %s was attached to the node meanwhile", device.Path, disk.VolumeID)
+		return nil
+	}
+	return d.removeStaleDevice(disk, device, mounts)
+}
+
+// removeStaleDevice unmounts all mounts of device, the multipath device of the detached disk,
+// and removes it
+func (d *nodeService) removeStaleDevice(disk *cloud.Disk, device fibrechannel.MultipathDevice, mounts []mount.MountPoint) error {
+	for _, mp := range mounts {
+		if !mountsDevice(mp, device) {
+			continue
+		}
+		klog.Infof("Unmounting %s, volume %s is no longer attached", mp.Path, disk.VolumeID)
+		if err := d.mounter.Unmount(mp.Path); err != nil {
+			return fmt.Errorf("could not unmount %s: %v", mp.Path, err)
+		}
+	}
+	klog.Infof("Removing stale multipath device %s of volume %s", device.Path, disk.VolumeID)
+	return d.mounter.RemoveMultipathDevice(device.Path)
+}
+
+// unstageAll unmounts the volumes of the workspace staged on the node and removes their
+// multipath and scsi devices, it's called by the preStop hook of the node plugin so that the
+// devices aren't yanked from under mounted filesystems when the node is drained. Volumes still
+// published to a pod are kept, removing their devices would lose the writes of the pod.
+func (d *nodeService) unstageAll(ctx context.Context) error {
+	disks, err := d.cloud.ListDisks(ctx)
+	if err != nil {
+		return fmt.Errorf("could not list volumes: %v", err)
+	}
+	attached := make(map[string]*cloud.Disk)
+	for _, disk := range disks {
+		if disk.WWN != "" && attachedTo(disk, d.pvmInstanceId) {
+			attached[disk.WWN] = disk
+		}
+	}
+	devices, err := d.mounter.ListMultipathDevices()
+	if err != nil {
+		return fmt.Errorf("could not list multipath devices: %v", err)
+	}
+	mounts, err := d.mounter.List()
+	if err != nil {
+		return fmt.Errorf("could not list mounts: %v", err)
+	}
+
+	var failed int
+	for wwn, device := range devices {
+		disk, ok := attached[wwn]
+		if !ok {
+			continue
+		}
+		var staged []string
+		var published bool
+		for _, mp := range mounts {
+			switch {
+			case !mountsDevice(mp, device):
+			case isStagingPath(mp.Path):
+				staged = append(staged, mp.Path)
+			default:
+				published = true
+			}
+		}
+		if len(staged) == 0 {
+			continue
+		}
+		if published {
+			klog.Infof("Keeping volume %s staged at %v, it is published to a pod", disk.VolumeID, staged)
+			continue
+		}
+		if !d.volumeLocks.TryAcquire(disk.VolumeID) {
+			klog.Infof("Skipping the unstaging of volume %s, an operation on it is in progress", disk.VolumeID)
+			continue
+		}
+		err := d.unstageDevice(disk, device, staged)
+		d.volumeLocks.Release(disk.VolumeID)
+		if err != nil {
+			klog.Errorf("Could not unstage volume %s: %v", disk.VolumeID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("could not unstage %d volumes", failed)
+	}
+	return nil
+}
+
+// unstageDevice unmounts the staging paths of the volume and removes its multipath device
+func (d *nodeService) unstageDevice(disk *cloud.Disk, device fibrechannel.MultipathDevice, staged []string) error {
+	for _, path := range staged {
+		klog.Infof("Unmounting %s of volume %s", path, disk.VolumeID)
+		if err := d.mounter.Unmount(path); err != nil {
+			return fmt.Errorf("could not unmount %s: %v", path, err)
+		}
+	}
+	klog.Infof("Removing multipath device %s of volume %s", device.Path, disk.VolumeID)
+	return d.mounter.RemoveMultipathDevice(device.Path)
+}
+
+// mountsDevice returns true if mp is a mount of the multipath device
+func mountsDevice(mp mount.MountPoint, device fibrechannel.MultipathDevice) bool {
+	return mp.Device == device.Path || mp.Device == "/dev/mapper/"+device.Name
+}
+
+// isStagingPath returns true for the staging target paths kubelet passes to NodeStageVolume,
+// .../plugins/kubernetes.io/csi/pv/<pv>/globalmount or .../plugins/kubernetes.io/csi/<driver>/<hash>/globalmount
+func isStagingPath(path string) bool {
+	return strings.Contains(path, "/plugins/kubernetes.io/csi/") && filepath.Base(path) == "globalmount"
+}
+
+// attachedTo returns true if disk is attached to the pvm instance instanceID
+func attachedTo(disk *cloud.Disk, instanceID string) bool {
+	for _, id := range disk.AttachedTo {
+		if id == instanceID {
+			return true
+		}
+	}
+	return false
+}
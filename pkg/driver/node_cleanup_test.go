@@ -0,0 +1,200 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"k8s.io/utils/mount"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	cloudmocks "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/mocks"
+	mocks "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver/mocks"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/fibrechannel"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func TestCleanupStaleDevices(t *testing.T) {
+	const (
+		stagingPath = "/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pvc-1/globalmount"
+		publishPath = "/var/lib/kubelet/pods/pod-1/volumes/kubernetes.io~csi/pvc-1/mount"
+		attachedWWN = "600507681082018bc800000000000a01"
+		staleWWN    = "600507681082018bc800000000000a02"
+	)
+	disks := []*cloud.Disk{
+		{VolumeID: "vol-attached", WWN: attachedWWN, AttachedTo: []string{"node-1"}},
+		{VolumeID: "vol-stale", WWN: staleWWN, AttachedTo: []string{"node-2"}},
+	}
+	devices := map[string]fibrechannel.MultipathDevice{
+		attachedWWN: {Path: "/dev/dm-0", Name: "mpatha"},
+		staleWWN:    {Path: "/dev/dm-1", Name: "mpathb"},
+		// the boot volume isn't listed
+		"600507681082018bc800000000000000": {Path: "/dev/dm-2", Name: "mpathc"},
+	}
+	mounts := []mount.MountPoint{
+		{Device: "/dev/mapper/mpatha", Path: "/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pvc-0/globalmount"},
+		{Device: "/dev/mapper/mpathb", Path: stagingPath},
+		{Device: "/dev/dm-1", Path: publishPath},
+		{Device: "/dev/mapper/mpathc", Path: "/"},
+	}
+
+	testCases := []struct {
+		name        string
+		lockVolume  bool
+		current     *cloud.Disk
+		getErr      error
+		removeErr   error
+		expectClean bool
+		expectErr   bool
+	}{
+		{
+			name:        "stale device is unmounted and removed",
+			current:     disks[1],
+			expectClean: true,
+		},
+		{
+			name:        "device of a deleted volume is removed",
+			getErr:      cloud.ErrNotFound,
+			expectClean: true,
+		},
+		{
+			name:       "volume with an operation in progress is skipped",
+			lockVolume: true,
+		},
+		{
+			name:    "volume attached meanwhile is kept",
+			current: &cloud.Disk{VolumeID: "vol-stale", WWN: staleWWN, AttachedTo: []string{"node-1"}},
+		},
+		{
+			name:      "volume that can't be read again is kept",
+			getErr:    errors.New("service unavailable"),
+			expectErr: true,
+		},
+		{
+			name:        "failure is reported",
+			current:     disks[1],
+			removeErr:   errors.New("map in use"),
+			expectClean: true,
+			expectErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+			mockCloud := cloudmocks.NewMockCloud(mockCtl)
+			mockMounter := mocks.NewMockMounter(mockCtl)
+
+			mockCloud.EXPECT().ListDisks(gomock.Any()).Return(disks, nil)
+			mockMounter.EXPECT().ListMultipathDevices().Return(devices, nil)
+			mockMounter.EXPECT().List().Return(mounts, nil)
+			if !tc.lockVolume {
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), "vol-stale").Return(tc.current, tc.getErr)
+			}
+			if tc.expectClean {
+				mockMounter.EXPECT().Unmount(stagingPath).Return(nil)
+				mockMounter.EXPECT().Unmount(publishPath).Return(nil)
+				mockMounter.EXPECT().RemoveMultipathDevice("/dev/dm-1").Return(tc.removeErr)
+			}
+
+			d := &nodeService{
+				cloud:         mockCloud,
+				mounter:       mockMounter,
+				pvmInstanceId: "node-1",
+				volumeLocks:   util.NewVolumeLocks(),
+			}
+			if tc.lockVolume {
+				d.volumeLocks.TryAcquire("vol-stale")
+			}
+			err := d.cleanupStaleDevices(context.Background())
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestUnstageAll(t *testing.T) {
+	const (
+		unusedWWN    = "600507681082018bc800000000000a01"
+		publishedWWN = "600507681082018bc800000000000a02"
+		otherWWN     = "600507681082018bc800000000000a03"
+		unusedPath   = "/var/lib/kubelet/plugins/kubernetes.io/csi/powervs.csi.ibm.com/0a1b/globalmount"
+	)
+	disks := []*cloud.Disk{
+		{VolumeID: "vol-unused", WWN: unusedWWN, AttachedTo: []string{"node-1"}},
+		{VolumeID: "vol-published", WWN: publishedWWN, AttachedTo: []string{"node-1"}},
+		{VolumeID: "vol-other", WWN: otherWWN, AttachedTo: []string{"node-2"}},
+	}
+	devices := map[string]fibrechannel.MultipathDevice{
+		unusedWWN:    {Path: "/dev/dm-0", Name: "mpatha"},
+		publishedWWN: {Path: "/dev/dm-1", Name: "mpathb"},
+		otherWWN:     {Path: "/dev/dm-2", Name: "mpathc"},
+		// the boot volume isn't listed
+		"600507681082018bc800000000000000": {Path: "/dev/dm-3", Name: "mpathd"},
+	}
+	mounts := []mount.MountPoint{
+		{Device: "/dev/mapper/mpatha", Path: unusedPath},
+		{Device: "/dev/mapper/mpathb", Path: "/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pvc-1/globalmount"},
+		{Device: "/dev/mapper/mpathb", Path: "/var/lib/kubelet/pods/pod-1/volumes/kubernetes.io~csi/pvc-1/mount"},
+		{Device: "/dev/mapper/mpathc", Path: "/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pvc-2/globalmount"},
+		{Device: "/dev/mapper/mpathd", Path: "/"},
+	}
+
+	testCases := []struct {
+		name      string
+		unmount   error
+		expectErr bool
+	}{
+		{
+			name: "unused volume is unstaged",
+		},
+		{
+			name:      "failure is reported",
+			unmount:   errors.New("target is busy"),
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+			mockCloud := cloudmocks.NewMockCloud(mockCtl)
+			mockMounter := mocks.NewMockMounter(mockCtl)
+
+			mockCloud.EXPECT().ListDisks(gomock.Any()).Return(disks, nil)
+			mockMounter.EXPECT().ListMultipathDevices().Return(devices, nil)
+			mockMounter.EXPECT().List().Return(mounts, nil)
+			mockMounter.EXPECT().Unmount(unusedPath).Return(tc.unmount)
+			if tc.unmount == nil {
+				mockMounter.EXPECT().RemoveMultipathDevice("/dev/dm-0").Return(nil)
+			}
+
+			d := &nodeService{
+				cloud:         mockCloud,
+				mounter:       mockMounter,
+				pvmInstanceId: "node-1",
+				volumeLocks:   util.NewVolumeLocks(),
+			}
+			err := d.unstageAll(context.Background())
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
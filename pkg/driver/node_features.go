@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/exec"
+)
+
+const (
+	multipathTool = "multipath"
+	resize2fsTool = "resize2fs"
+	xfsGrowfsTool = "xfs_growfs"
+)
+
+// hostTools are the tools the node probes for when it starts
+var hostTools = []string{
+	multipathTool,
+	"mkfs." + FSTypeExt2,
+	"mkfs." + FSTypeExt3,
+	"mkfs." + FSTypeExt4,
+	"mkfs." + FSTypeXfs,
+	resize2fsTool,
+	xfsGrowfsTool,
+}
+
+// hostFeatures are the tools found on the node, the capabilities it advertises depend on them.
+// A nil hostFeatures has every tool.
+type hostFeatures struct {
+	tools map[string]bool
+}
+
+// probeHostFeatures looks up the hostTools in the PATH of e
+func probeHostFeatures(e exec.Interface) *hostFeatures {
+	h := &hostFeatures{tools: map[string]bool{}}
+	for _, tool := range hostTools {
+		if _, err := e.LookPath(tool); err != nil {
+			klog.Warningf("%s is not installed on the node, the features depending on it are disabled: %v", tool, err)
+			continue
+		}
+		h.tools[tool] = true
+	}
+	if !h.has(multipathTool) {
+		klog.Warningf("Without %s the node can't flush the multipath devices of unstaged volumes", multipathTool)
+	}
+	return h
+}
+
+// has returns true if tool was found on the node
+func (h *hostFeatures) has(tool string) bool {
+	return h == nil || h.tools[tool]
+}
+
+// canFormat returns true if the node can create filesystems of fsType
+func (h *hostFeatures) canFormat(fsType string) bool {
+	return h.has("mkfs." + fsType)
+}
+
+// nodeCapabilities returns the capabilities of the node. Volumes are only expanded with the
+// VolumeExpansion feature and the tools to grow their filesystems, volume stats are reported
+// unless disabled.
+func (d *nodeService) nodeCapabilities() []csi.NodeServiceCapability_RPC_Type {
+	caps := []csi.NodeServiceCapability_RPC_Type{csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME}
+	if d.driverOptions.enabled(VolumeExpansion) && (d.host.has(resize2fsTool) || d.host.has(xfsGrowfsTool)) {
+		caps = append(caps, csi.NodeServiceCapability_RPC_EXPAND_VOLUME)
+	}
+	if d.driverOptions.volumeStats {
+		caps = append(caps, csi.NodeServiceCapability_RPC_GET_VOLUME_STATS)
+		if d.driverOptions.enabled(NodeVolumeCondition) {
+			caps = append(caps, csi.NodeServiceCapability_RPC_VOLUME_CONDITION)
+		}
+	}
+	if d.driverOptions.enabled(SingleNodeMultiWriter) {
+		caps = append(caps, csi.NodeServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER)
+	}
+	return caps
+}
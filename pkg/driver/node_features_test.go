@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	testingexec "k8s.io/utils/exec/testing"
+)
+
+func TestProbeHostFeatures(t *testing.T) {
+	installed := map[string]bool{"mkfs.ext4": true, resize2fsTool: true}
+	fakeExec := &testingexec.FakeExec{
+		LookPathFunc: func(file string) (string, error) {
+			if installed[file] {
+				return "/usr/sbin/" + file, nil
+			}
+			return "", errors.New("not found")
+		},
+	}
+
+	h := probeHostFeatures(fakeExec)
+	for _, tool := range hostTools {
+		if h.has(tool) != installed[tool] {
+			t.Fatalf("expected %s to be found %v", tool, installed[tool])
+		}
+	}
+	if !h.canFormat(FSTypeExt4) || h.canFormat(FSTypeXfs) {
+		t.Fatalf("expected the node to only format %s, got tools %v", FSTypeExt4, h.tools)
+	}
+}
+
+func TestNodeCapabilities(t *testing.T) {
+	testCases := []struct {
+		name        string
+		host        *hostFeatures
+		volumeStats bool
+		gates       FeatureGates
+		expected    []csi.NodeServiceCapability_RPC_Type
+	}{
+		{
+			name:        "every tool",
+			volumeStats: true,
+			expected: []csi.NodeServiceCapability_RPC_Type{
+				csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
+				csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
+				csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+			},
+		},
+		{
+			name:        "xfs_growfs only",
+			host:        &hostFeatures{tools: map[string]bool{xfsGrowfsTool: true}},
+			volumeStats: true,
+			expected: []csi.NodeServiceCapability_RPC_Type{
+				csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
+				csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
+				csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+			},
+		},
+		{
+			name:        "alpha features",
+			volumeStats: true,
+			gates:       FeatureGates{NodeVolumeCondition: true, SingleNodeMultiWriter: true},
+			expected: []csi.NodeServiceCapability_RPC_Type{
+				csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
+				csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
+				csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+				csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+				csi.NodeServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER,
+			},
+		},
+		{
+			name:     "without resize tools and volume stats",
+			host:     &hostFeatures{tools: map[string]bool{multipathTool: true}},
+			expected: []csi.NodeServiceCapability_RPC_Type{csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &nodeService{host: tc.host, driverOptions: &Options{volumeStats: tc.volumeStats, featureGates: tc.gates}}
+			if caps := d.nodeCapabilities(); !reflect.DeepEqual(caps, tc.expected) {
+				t.Fatalf("expected capabilities %v, got %v", tc.expected, caps)
+			}
+		})
+	}
+}
+
+func TestNodeGetVolumeStatsDisabled(t *testing.T) {
+	d := &nodeService{driverOptions: &Options{}}
+	_, err := d.NodeGetVolumeStats(context.TODO(), &csi.NodeGetVolumeStatsRequest{VolumeId: "vol-test", VolumePath: "/test/path"})
+	expectErr(t, err, codes.Unimplemented)
+}
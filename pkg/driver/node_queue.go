@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/metrics"
+)
+
+// operations serialized by the node queues
+const (
+	nodeOperationAttach = "attach"
+	nodeOperationDetach = "detach"
+)
+
+// nodeQueues serializes the attach and detach operations of the controller per node, PowerVS
+// rejects an operation on an instance while another one is in progress on it. The operations
+// of a node run one at a time in the order they were queued.
+type nodeQueues struct {
+	mu sync.Mutex
+	// queues holds the turns of the operations of each node, the first one is running
+	queues map[string][]chan struct{}
+}
+
+func newNodeQueues() *nodeQueues {
+	return &nodeQueues{queues: map[string][]chan struct{}{}}
+}
+
+// acquire queues an operation on nodeID and waits for its turn, or until ctx is done. The
+// node must be released once the operation is complete.
+func (q *nodeQueues) acquire(ctx context.Context, nodeID, operation string) error {
+	start := time.Now()
+	turn := make(chan struct{})
+	q.mu.Lock()
+	q.queues[nodeID] = append(q.queues[nodeID], turn)
+	if len(q.queues[nodeID]) == 1 {
+		close(turn)
+	}
+	q.mu.Unlock()
+
+	waiting := metrics.NodeQueueWaiting.WithLabelValues(operation)
+	waiting.Inc()
+	defer waiting.Dec()
+	select {
+	case <-turn:
+		metrics.NodeQueueWaitDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		// the turn may have come in the meantime, it is passed on to the next operation
+		for i, t := range q.queues[nodeID] {
+			if t == turn {
+				q.remove(nodeID, i)
+				break
+			}
+		}
+		return status.Errorf(codes.Aborted, "Timed out waiting for the operations in progress on node %q: %v", nodeID, ctx.Err())
+	}
+}
+
+// release ends the running operation on nodeID and starts the next one
+func (q *nodeQueues) release(nodeID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.remove(nodeID, 0)
+}
+
+// remove removes the i-th operation of the queue of nodeID, the next operation starts if it
+// was the running one. q.mu must be held.
+func (q *nodeQueues) remove(nodeID string, i int) {
+	queue := q.queues[nodeID]
+	if i >= len(queue) {
+		return
+	}
+	queue = append(queue[:i:i], queue[i+1:]...)
+	if len(queue) == 0 {
+		delete(q.queues, nodeID)
+		return
+	}
+	q.queues[nodeID] = queue
+	if i == 0 {
+		close(queue[0])
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNodeQueuesOrder(t *testing.T) {
+	q := newNodeQueues()
+	if err := q.acquire(context.Background(), "node-1", nodeOperationAttach); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	order := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		i := i
+		go func() {
+			if err := q.acquire(context.Background(), "node-1", nodeOperationAttach); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			order <- i
+			q.release("node-1")
+		}()
+		// the operations are queued one after the other
+		waitForQueue(t, q, "node-1", i+2)
+	}
+
+	// other nodes aren't blocked
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := q.acquire(ctx, "node-2", nodeOperationDetach); err != nil {
+		t.Fatalf("unexpected error on another node: %v", err)
+	}
+	q.release("node-2")
+
+	q.release("node-1")
+	for i := 0; i < 3; i++ {
+		if got := <-order; got != i {
+			t.Fatalf("expected operation %d, got %d", i, got)
+		}
+	}
+	waitForQueue(t, q, "node-1", 0)
+}
+
+func TestNodeQueuesTimeout(t *testing.T) {
+	q := newNodeQueues()
+	if err := q.acquire(context.Background(), "node-1", nodeOperationAttach); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.acquire(ctx, "node-1", nodeOperationDetach); status.Code(err) != codes.Aborted {
+		t.Fatalf("expected Aborted, got %v", err)
+	}
+	waitForQueue(t, q, "node-1", 1)
+
+	// the timed out operation doesn't keep the next one waiting
+	q.release("node-1")
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := q.acquire(ctx, "node-1", nodeOperationDetach); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func waitForQueue(t *testing.T, q *nodeQueues, nodeID string, length int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		q.mu.Lock()
+		n := len(q.queues[nodeID])
+		q.mu.Unlock()
+		if n == length {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d operations on %s, got %d", length, nodeID, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
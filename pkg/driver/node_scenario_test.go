@@ -0,0 +1,404 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver/internal/fakemount"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+const (
+	scenarioWWN    = "600507681081818d8000000000000100"
+	scenarioDevice = "/dev/dm-0"
+)
+
+// scenarioNode is the node service of a scenario on a fake host
+type scenarioNode struct {
+	d           *nodeService
+	host        *fakemount.Host
+	stagingPath string
+	targetPath  string
+}
+
+func (n *scenarioNode) stage(capability *csi.VolumeCapability, volumeContext map[string]string) error {
+	_, err := n.d.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+		VolumeId:          "vol-test",
+		PublishContext:    map[string]string{WWNKey: scenarioWWN},
+		StagingTargetPath: n.stagingPath,
+		VolumeCapability:  capability,
+		VolumeContext:     volumeContext,
+	})
+	return err
+}
+
+func (n *scenarioNode) publish(capability *csi.VolumeCapability) error {
+	_, err := n.d.NodePublishVolume(context.Background(), &csi.NodePublishVolumeRequest{
+		VolumeId:          "vol-test",
+		PublishContext:    map[string]string{WWNKey: scenarioWWN},
+		StagingTargetPath: n.stagingPath,
+		TargetPath:        n.targetPath,
+		VolumeCapability:  capability,
+	})
+	return err
+}
+
+func (n *scenarioNode) unpublish() error {
+	_, err := n.d.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{VolumeId: "vol-test", TargetPath: n.targetPath})
+	return err
+}
+
+func (n *scenarioNode) unstage() error {
+	_, err := n.d.NodeUnstageVolume(context.Background(), &csi.NodeUnstageVolumeRequest{VolumeId: "vol-test", StagingTargetPath: n.stagingPath})
+	return err
+}
+
+// mountedDevice returns the device mounted at path and its number of mounts
+func (n *scenarioNode) mountedDevice(t *testing.T, path string) (string, int) {
+	device, refCount, err := n.host.GetDeviceName(path)
+	if err != nil {
+		t.Fatalf("could not get the device mounted at %s: %v", path, err)
+	}
+	return device, refCount
+}
+
+func TestNodeScenarios(t *testing.T) {
+	errMount := errors.New("mount failed")
+
+	testCases := []struct {
+		name    string
+		options *Options
+		// device is the device of the volume attached to the host, nil if it isn't attached
+		device *fakemount.Device
+		// setup prepares the host before the scenario
+		setup func(n *scenarioNode)
+		// run runs the RPCs of the scenario and returns the error of the last one
+		run        func(n *scenarioNode) error
+		expectCode codes.Code
+		// check verifies the host after the scenario
+		check func(t *testing.T, n *scenarioNode)
+	}{
+		{
+			name:   "stage formats a new volume",
+			device: &fakemount.Device{Path: scenarioDevice},
+			run:    func(n *scenarioNode) error { return n.stage(mountCapability(""), nil) },
+			check: func(t *testing.T, n *scenarioNode) {
+				if device, _ := n.host.Device(scenarioWWN); device.Format != defaultFsType {
+					t.Fatalf("expected the volume to be formatted with %s, got %q", defaultFsType, device.Format)
+				}
+				if device, _ := n.mountedDevice(t, n.stagingPath); device != scenarioDevice {
+					t.Fatalf("expected %s to be mounted at the staging path, got %q", scenarioDevice, device)
+				}
+			},
+		},
+		{
+			name:   "stage mounts an existing filesystem",
+			device: &fakemount.Device{Path: scenarioDevice, Format: FSTypeXfs},
+			run:    func(n *scenarioNode) error { return n.stage(mountCapability(FSTypeXfs), nil) },
+			check: func(t *testing.T, n *scenarioNode) {
+				if calls := n.host.Calls("FormatAndMount"); calls != 0 {
+					t.Fatalf("expected the existing filesystem not to be formatted, got %d formats", calls)
+				}
+				if device, _ := n.mountedDevice(t, n.stagingPath); device != scenarioDevice {
+					t.Fatalf("expected %s to be mounted at the staging path, got %q", scenarioDevice, device)
+				}
+			},
+		},
+		{
+			name:   "stage grows the filesystem of a restored volume",
+			device: &fakemount.Device{Path: scenarioDevice, Format: FSTypeExt4, NeedResize: true},
+			run:    func(n *scenarioNode) error { return n.stage(mountCapability(FSTypeExt4), nil) },
+			check: func(t *testing.T, n *scenarioNode) {
+				if calls := n.host.Calls("ResizeFs"); calls != 1 {
+					t.Fatalf("expected the filesystem to be resized once, got %d resizes", calls)
+				}
+			},
+		},
+		{
+			name:       "stage refuses a volume of another filesystem",
+			device:     &fakemount.Device{Path: scenarioDevice, Format: FSTypeXfs},
+			run:        func(n *scenarioNode) error { return n.stage(mountCapability(FSTypeExt4), nil) },
+			expectCode: codes.FailedPrecondition,
+		},
+		{
+			name:   "stage refuses a pre-formatted volume without filesystem",
+			device: &fakemount.Device{Path: scenarioDevice},
+			run: func(n *scenarioNode) error {
+				return n.stage(mountCapability(""), map[string]string{PreFormattedKey: "true"})
+			},
+			expectCode: codes.FailedPrecondition,
+			check: func(t *testing.T, n *scenarioNode) {
+				if device, _ := n.host.Device(scenarioWWN); device.Format != "" {
+					t.Fatalf("expected the pre-formatted volume not to be formatted, got %s", device.Format)
+				}
+			},
+		},
+		{
+			name:   "stage doesn't grow a pre-formatted volume",
+			device: &fakemount.Device{Path: scenarioDevice, Format: FSTypeExt4, NeedResize: true},
+			run: func(n *scenarioNode) error {
+				return n.stage(mountCapability(FSTypeExt4), map[string]string{PreFormattedKey: "true"})
+			},
+			check: func(t *testing.T, n *scenarioNode) {
+				if calls := n.host.Calls("ResizeFs"); calls != 0 {
+					t.Fatalf("expected the pre-formatted filesystem not to be resized, got %d resizes", calls)
+				}
+			},
+		},
+		{
+			name:    "stage doesn't repair a pre-formatted volume",
+			options: &Options{fsckPolicy: FsckPolicyRepair},
+			device:  &fakemount.Device{Path: scenarioDevice, Format: FSTypeExt4, Corrupted: true},
+			run: func(n *scenarioNode) error {
+				return n.stage(mountCapability(FSTypeExt4), map[string]string{PreFormattedKey: "true"})
+			},
+			expectCode: codes.FailedPrecondition,
+		},
+		{
+			name:       "stage fails without the device",
+			run:        func(n *scenarioNode) error { return n.stage(mountCapability(""), nil) },
+			expectCode: codes.Internal,
+		},
+		{
+			name:       "stage refuses a corrupted filesystem",
+			options:    &Options{fsckPolicy: FsckPolicyFail},
+			device:     &fakemount.Device{Path: scenarioDevice, Format: FSTypeExt4, Corrupted: true},
+			run:        func(n *scenarioNode) error { return n.stage(mountCapability(FSTypeExt4), nil) },
+			expectCode: codes.FailedPrecondition,
+		},
+		{
+			name:   "stage mounts a corrupted filesystem without fsck",
+			device: &fakemount.Device{Path: scenarioDevice, Format: FSTypeExt4, Corrupted: true},
+			run:    func(n *scenarioNode) error { return n.stage(mountCapability(FSTypeExt4), nil) },
+			check: func(t *testing.T, n *scenarioNode) {
+				if device, _ := n.mountedDevice(t, n.stagingPath); device != scenarioDevice {
+					t.Fatalf("expected %s to be mounted at the staging path, got %q", scenarioDevice, device)
+				}
+			},
+		},
+		{
+			name:       "stage fails to mount",
+			device:     &fakemount.Device{Path: scenarioDevice, Format: FSTypeExt4},
+			setup:      func(n *scenarioNode) { n.host.Fail("Mount", errMount) },
+			run:        func(n *scenarioNode) error { return n.stage(mountCapability(FSTypeExt4), nil) },
+			expectCode: codes.Internal,
+		},
+		{
+			name:   "stage of a staged volume",
+			device: &fakemount.Device{Path: scenarioDevice},
+			run: func(n *scenarioNode) error {
+				if err := n.stage(mountCapability(""), nil); err != nil {
+					return err
+				}
+				return n.stage(mountCapability(""), nil)
+			},
+			check: func(t *testing.T, n *scenarioNode) {
+				if calls := n.host.Calls("FormatAndMount"); calls != 1 {
+					t.Fatalf("expected the volume to be formatted once, got %d formats", calls)
+				}
+				if _, refCount := n.mountedDevice(t, n.stagingPath); refCount != 1 {
+					t.Fatalf("expected the volume to be mounted once, got %d mounts", refCount)
+				}
+			},
+		},
+		{
+			name:       "stage at a corrupted mount",
+			device:     &fakemount.Device{Path: scenarioDevice},
+			setup:      func(n *scenarioNode) { n.host.CorruptMount(n.stagingPath) },
+			run:        func(n *scenarioNode) error { return n.stage(mountCapability(""), nil) },
+			expectCode: codes.Internal,
+		},
+		{
+			name:   "stage of a block volume",
+			device: &fakemount.Device{Path: scenarioDevice},
+			run:    func(n *scenarioNode) error { return n.stage(blockCapability(), nil) },
+			check: func(t *testing.T, n *scenarioNode) {
+				if calls := n.host.Calls("GetDevicePath"); calls != 0 {
+					t.Fatalf("expected block volumes not to be staged, got %d device lookups", calls)
+				}
+			},
+		},
+		{
+			name:   "publish bind mounts the staged volume",
+			device: &fakemount.Device{Path: scenarioDevice},
+			run: func(n *scenarioNode) error {
+				if err := n.stage(mountCapability(""), nil); err != nil {
+					return err
+				}
+				return n.publish(mountCapability(""))
+			},
+			check: func(t *testing.T, n *scenarioNode) {
+				device, refCount := n.mountedDevice(t, n.targetPath)
+				if device != scenarioDevice || refCount != 2 {
+					t.Fatalf("expected %s to be mounted at the staging and target paths, got %q with %d mounts", scenarioDevice, device, refCount)
+				}
+			},
+		},
+		{
+			name:   "publish a block volume",
+			device: &fakemount.Device{Path: scenarioDevice},
+			run:    func(n *scenarioNode) error { return n.publish(blockCapability()) },
+			check: func(t *testing.T, n *scenarioNode) {
+				if info, err := os.Stat(n.targetPath); err != nil || info.IsDir() {
+					t.Fatalf("expected the target path to be a file, got %v", err)
+				}
+				if device, _ := n.mountedDevice(t, n.targetPath); device != scenarioDevice {
+					t.Fatalf("expected %s to be mounted at the target path, got %q", scenarioDevice, device)
+				}
+			},
+		},
+		{
+			name:   "publish fails to mount",
+			device: &fakemount.Device{Path: scenarioDevice},
+			run: func(n *scenarioNode) error {
+				if err := n.stage(mountCapability(""), nil); err != nil {
+					return err
+				}
+				n.host.Fail("Mount", errMount)
+				return n.publish(mountCapability(""))
+			},
+			expectCode: codes.Internal,
+			check: func(t *testing.T, n *scenarioNode) {
+				if _, err := os.Stat(n.targetPath); !os.IsNotExist(err) {
+					t.Fatalf("expected the target path to be removed, got %v", err)
+				}
+			},
+		},
+		{
+			name:   "unstage of a volume that isn't staged",
+			device: &fakemount.Device{Path: scenarioDevice},
+			run:    func(n *scenarioNode) error { return n.unstage() },
+			check: func(t *testing.T, n *scenarioNode) {
+				if _, ok := n.host.Device(scenarioWWN); !ok {
+					t.Fatalf("expected the device of a volume that isn't staged to be kept")
+				}
+			},
+		},
+		{
+			name:   "unstage removes the device",
+			device: &fakemount.Device{Path: scenarioDevice},
+			run: func(n *scenarioNode) error {
+				if err := n.stage(mountCapability(""), nil); err != nil {
+					return err
+				}
+				if err := n.publish(mountCapability("")); err != nil {
+					return err
+				}
+				if err := n.unpublish(); err != nil {
+					return err
+				}
+				return n.unstage()
+			},
+			check: func(t *testing.T, n *scenarioNode) {
+				if _, ok := n.host.Device(scenarioWWN); ok {
+					t.Fatalf("expected the device to be removed")
+				}
+				if mounts, _ := n.host.List(); len(mounts) != 0 {
+					t.Fatalf("expected no mounts, got %v", mounts)
+				}
+			},
+		},
+		{
+			name:    "unstage without multipath",
+			options: &Options{featureGates: FeatureGates{Multipath: false}},
+			device:  &fakemount.Device{Path: scenarioDevice},
+			run: func(n *scenarioNode) error {
+				if err := n.stage(mountCapability(""), nil); err != nil {
+					return err
+				}
+				return n.unstage()
+			},
+			check: func(t *testing.T, n *scenarioNode) {
+				if _, ok := n.host.Device(scenarioWWN); ok {
+					t.Fatalf("expected the device to be removed")
+				}
+			},
+		},
+		{
+			name:   "unstage fails to unmount",
+			device: &fakemount.Device{Path: scenarioDevice},
+			run: func(n *scenarioNode) error {
+				if err := n.stage(mountCapability(""), nil); err != nil {
+					return err
+				}
+				n.host.Fail("Unmount", errMount)
+				return n.unstage()
+			},
+			expectCode: codes.Internal,
+			check: func(t *testing.T, n *scenarioNode) {
+				if _, ok := n.host.Device(scenarioWWN); !ok {
+					t.Fatalf("expected the device of a mounted volume to be kept")
+				}
+			},
+		},
+		{
+			name:   "unstage fails to detach the device",
+			device: &fakemount.Device{Path: scenarioDevice},
+			run: func(n *scenarioNode) error {
+				if err := n.stage(mountCapability(""), nil); err != nil {
+					return err
+				}
+				n.host.Fail("DetachDevice", errors.New("device busy"))
+				return n.unstage()
+			},
+			expectCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			host := fakemount.NewHost()
+			if tc.device != nil {
+				host.AddDevice(scenarioWWN, *tc.device)
+			}
+			options := tc.options
+			if options == nil {
+				options = &Options{}
+			}
+			n := &scenarioNode{
+				d: &nodeService{
+					mounter:       host,
+					driverOptions: options,
+					volumeLocks:   util.NewVolumeLocks(),
+				},
+				host:        host,
+				stagingPath: filepath.Join(dir, "staging"),
+				targetPath:  filepath.Join(dir, "target"),
+			}
+			if tc.setup != nil {
+				tc.setup(n)
+			}
+
+			err := tc.run(n)
+			if tc.expectCode == codes.OK {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			} else {
+				expectErr(t, err, tc.expectCode)
+			}
+			if tc.check != nil {
+				tc.check(t, n)
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/golang/mock/gomock"
@@ -28,6 +29,8 @@ import (
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"k8s.io/utils/exec"
+	testingexec "k8s.io/utils/exec/testing"
 )
 
 var (
@@ -62,10 +65,11 @@ func TestNodeStageVolume(t *testing.T) {
 		}
 	)
 	testCases := []struct {
-		name         string
-		request      *csi.NodeStageVolumeRequest
-		expectMock   func(mockMounter mocks.MockMounter)
-		expectedCode codes.Code
+		name              string
+		request           *csi.NodeStageVolumeRequest
+		ext4FormatOptions string
+		expectMock        func(mockMounter mocks.MockMounter)
+		expectedCode      codes.Code
 	}{
 
 		{
@@ -78,6 +82,7 @@ func TestNodeStageVolume(t *testing.T) {
 			},
 			expectMock: func(mockMounter mocks.MockMounter) {
 				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return("", nil)
 				mockMounter.EXPECT().FormatAndMount(gomock.Eq(devicePath), gomock.Eq(targetPath), gomock.Any(), gomock.Any()).Return(nil)
 			},
 		},
@@ -122,10 +127,47 @@ func TestNodeStageVolume(t *testing.T) {
 			},
 			expectMock: func(mockMounter mocks.MockMounter) {
 				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return("", nil)
 				mockMounter.EXPECT().FormatAndMount(gomock.Eq(devicePath), gomock.Eq(targetPath), gomock.Eq(FSTypeExt4), gomock.Eq([]string{"dirsync", "noexec"}))
 			},
 		},
 
+		{
+			name: "success with ext4 format options",
+			request: &csi.NodeStageVolumeRequest{
+				PublishContext:    map[string]string{WWNKey: devicePath},
+				StagingTargetPath: targetPath,
+				VolumeCapability:  stdVolCap,
+				VolumeId:          volumeID,
+			},
+			ext4FormatOptions: DefaultExt4FormatOptions,
+			expectMock: func(mockMounter mocks.MockMounter) {
+				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return("", nil)
+				mockMounter.EXPECT().FormatAndMountWithOptions(gomock.Eq(devicePath), gomock.Eq(targetPath), gomock.Eq(FSTypeExt4), gomock.Any(), gomock.Eq([]string{"-E", "lazy_itable_init=1,nodiscard"}))
+			},
+		},
+
+		{
+			name: "success with format options of the volume",
+			request: &csi.NodeStageVolumeRequest{
+				PublishContext:    map[string]string{WWNKey: devicePath},
+				StagingTargetPath: targetPath,
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{FsType: FSTypeXfs}},
+					AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+				},
+				VolumeContext: map[string]string{FormatOptionsKey: "-K  -i maxpct=5"},
+				VolumeId:      volumeID,
+			},
+			ext4FormatOptions: DefaultExt4FormatOptions,
+			expectMock: func(mockMounter mocks.MockMounter) {
+				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return("", nil)
+				mockMounter.EXPECT().FormatAndMountWithOptions(gomock.Eq(devicePath), gomock.Eq(targetPath), gomock.Eq(FSTypeXfs), gomock.Any(), gomock.Eq([]string{"-K", "-i", "maxpct=5"}))
+			},
+		},
+
 		{
 			name: "success fsType ext3",
 			request: &csi.NodeStageVolumeRequest{
@@ -145,6 +187,7 @@ func TestNodeStageVolume(t *testing.T) {
 			},
 			expectMock: func(mockMounter mocks.MockMounter) {
 				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return("", nil)
 				mockMounter.EXPECT().FormatAndMount(gomock.Eq(devicePath), gomock.Eq(targetPath), gomock.Eq(FSTypeExt3), gomock.Any())
 			},
 		},
@@ -168,6 +211,7 @@ func TestNodeStageVolume(t *testing.T) {
 			},
 			expectMock: func(mockMounter mocks.MockMounter) {
 				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return("", nil)
 				mockMounter.EXPECT().FormatAndMount(gomock.Eq(devicePath), gomock.Eq(targetPath), gomock.Eq(FSTypeExt4), gomock.Any())
 			},
 		},
@@ -188,6 +232,158 @@ func TestNodeStageVolume(t *testing.T) {
 			},
 		},
 
+		{
+			name: "success pre-formatted volume is mounted without formatting",
+			request: &csi.NodeStageVolumeRequest{
+				PublishContext:    map[string]string{WWNKey: devicePath},
+				StagingTargetPath: targetPath,
+				VolumeCapability:  stdVolCap,
+				VolumeContext:     map[string]string{PreFormattedKey: "true"},
+				VolumeId:          volumeID,
+			},
+			expectMock: func(mockMounter mocks.MockMounter) {
+				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return(FSTypeExt4, nil)
+				mockMounter.EXPECT().Mount(gomock.Eq(devicePath), gomock.Eq(targetPath), gomock.Eq(FSTypeExt4), gomock.Any()).Return(nil)
+				mockMounter.EXPECT().NeedResize(gomock.Any(), gomock.Any()).Times(0)
+				mockMounter.EXPECT().ResizeFs(gomock.Any(), gomock.Any()).Times(0)
+				mockMounter.EXPECT().FormatAndMount(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+
+		{
+			name: "fail pre-formatted volume without filesystem",
+			request: &csi.NodeStageVolumeRequest{
+				PublishContext:    map[string]string{WWNKey: devicePath},
+				StagingTargetPath: targetPath,
+				VolumeCapability:  stdVolCap,
+				VolumeContext:     map[string]string{PreFormattedKey: "true"},
+				VolumeId:          volumeID,
+			},
+			expectMock: func(mockMounter mocks.MockMounter) {
+				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return("", nil)
+				mockMounter.EXPECT().FormatAndMount(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: codes.FailedPrecondition,
+		},
+
+		{
+			name: "fail pre-formatted volume with filesystem mismatch",
+			request: &csi.NodeStageVolumeRequest{
+				PublishContext:    map[string]string{WWNKey: devicePath},
+				StagingTargetPath: targetPath,
+				VolumeCapability:  stdVolCap,
+				VolumeContext:     map[string]string{PreFormattedKey: "true"},
+				VolumeId:          volumeID,
+			},
+			expectMock: func(mockMounter mocks.MockMounter) {
+				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return(FSTypeXfs, nil)
+				mockMounter.EXPECT().Mount(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: codes.FailedPrecondition,
+		},
+
+		{
+			name: "success existing filesystem is mounted without formatting",
+			request: &csi.NodeStageVolumeRequest{
+				PublishContext:    map[string]string{WWNKey: devicePath},
+				StagingTargetPath: targetPath,
+				VolumeCapability:  stdVolCap,
+				VolumeId:          volumeID,
+			},
+			expectMock: func(mockMounter mocks.MockMounter) {
+				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return(FSTypeExt4, nil)
+				mockMounter.EXPECT().Mount(gomock.Eq(devicePath), gomock.Eq(targetPath), gomock.Eq(FSTypeExt4), gomock.Any()).Return(nil)
+				mockMounter.EXPECT().NeedResize(gomock.Eq(devicePath), gomock.Eq(targetPath)).Return(false, nil)
+				mockMounter.EXPECT().FormatAndMount(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+
+		{
+			name: "success filesystem smaller than the volume is grown",
+			request: &csi.NodeStageVolumeRequest{
+				PublishContext:    map[string]string{WWNKey: devicePath},
+				StagingTargetPath: targetPath,
+				VolumeCapability:  stdVolCap,
+				VolumeId:          volumeID,
+			},
+			expectMock: func(mockMounter mocks.MockMounter) {
+				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return(FSTypeExt4, nil)
+				mockMounter.EXPECT().Mount(gomock.Eq(devicePath), gomock.Eq(targetPath), gomock.Eq(FSTypeExt4), gomock.Any()).Return(nil)
+				mockMounter.EXPECT().NeedResize(gomock.Eq(devicePath), gomock.Eq(targetPath)).Return(true, nil)
+				mockMounter.EXPECT().ResizeFs(gomock.Eq(devicePath), gomock.Eq(targetPath)).Return(nil)
+			},
+		},
+
+		{
+			name: "fail growing the filesystem",
+			request: &csi.NodeStageVolumeRequest{
+				PublishContext:    map[string]string{WWNKey: devicePath},
+				StagingTargetPath: targetPath,
+				VolumeCapability:  stdVolCap,
+				VolumeId:          volumeID,
+			},
+			expectMock: func(mockMounter mocks.MockMounter) {
+				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return(FSTypeExt4, nil)
+				mockMounter.EXPECT().Mount(gomock.Eq(devicePath), gomock.Eq(targetPath), gomock.Eq(FSTypeExt4), gomock.Any()).Return(nil)
+				mockMounter.EXPECT().NeedResize(gomock.Eq(devicePath), gomock.Eq(targetPath)).Return(true, nil)
+				mockMounter.EXPECT().ResizeFs(gomock.Eq(devicePath), gomock.Eq(targetPath)).Return(errors.New("resize2fs failed"))
+			},
+			expectedCode: codes.Internal,
+		},
+
+		{
+			name: "fail existing filesystem mismatch",
+			request: &csi.NodeStageVolumeRequest{
+				PublishContext:    map[string]string{WWNKey: devicePath},
+				StagingTargetPath: targetPath,
+				VolumeCapability:  stdVolCap,
+				VolumeId:          volumeID,
+			},
+			expectMock: func(mockMounter mocks.MockMounter) {
+				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return(FSTypeXfs, nil)
+				mockMounter.EXPECT().FormatAndMount(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: codes.FailedPrecondition,
+		},
+
+		{
+			name: "fail filesystem probe",
+			request: &csi.NodeStageVolumeRequest{
+				PublishContext:    map[string]string{WWNKey: devicePath},
+				StagingTargetPath: targetPath,
+				VolumeCapability:  stdVolCap,
+				VolumeId:          volumeID,
+			},
+			expectMock: func(mockMounter mocks.MockMounter) {
+				successExpectMock(mockMounter)
+				mockMounter.EXPECT().GetDiskFormat(gomock.Eq(devicePath)).Return("", errors.New("blkid failed"))
+				mockMounter.EXPECT().FormatAndMount(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: codes.Internal,
+		},
+
+		{
+			name: "fail invalid preFormatted value",
+			request: &csi.NodeStageVolumeRequest{
+				PublishContext:    map[string]string{WWNKey: devicePath},
+				StagingTargetPath: targetPath,
+				VolumeCapability:  stdVolCap,
+				VolumeContext:     map[string]string{PreFormattedKey: "maybe"},
+				VolumeId:          volumeID,
+			},
+			expectMock: func(mockMounter mocks.MockMounter) {
+				successExpectMock(mockMounter)
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+
 		{
 			name: "fail no VolumeId",
 			request: &csi.NodeStageVolumeRequest{
@@ -266,8 +462,9 @@ func TestNodeStageVolume(t *testing.T) {
 			mockMounter := mocks.NewMockMounter(mockCtl)
 
 			powervsDriver := &nodeService{
-				mounter:     mockMounter,
-				volumeLocks: util.NewVolumeLocks(),
+				mounter:       mockMounter,
+				driverOptions: &Options{ext4FormatOptions: tc.ext4FormatOptions},
+				volumeLocks:   util.NewVolumeLocks(),
 			}
 
 			if tc.expectMock != nil {
@@ -291,20 +488,63 @@ func TestNodeExpandVolume(t *testing.T) {
 	mockMounter := mocks.NewMockMounter(mockCtl)
 
 	powervsDriver := &nodeService{
-		mounter: mockMounter,
+		mounter:       mockMounter,
+		driverOptions: &Options{},
+		volumeLocks:   util.NewVolumeLocks(),
 	}
 
 	tests := []struct {
 		name               string
-		request            csi.NodeExpandVolumeRequest
+		request            *csi.NodeExpandVolumeRequest
 		expectResponseCode codes.Code
 		expectMock         func(mockMounter mocks.MockMounter)
 	}{
 		{
 			name:               "fail missing volumeId",
-			request:            csi.NodeExpandVolumeRequest{},
+			request:            &csi.NodeExpandVolumeRequest{},
 			expectResponseCode: codes.InvalidArgument,
 		},
+		{
+			name:    "success mounted volume",
+			request: &csi.NodeExpandVolumeRequest{VolumeId: "vol-test", VolumePath: "/test/path"},
+			expectMock: func(mockMounter mocks.MockMounter) {
+				mockMounter.EXPECT().ExistsPath("/test/path").Return(true, nil)
+				mockMounter.EXPECT().Command("findmnt", "-o", "source", "--noheadings", "--target", "/test/path").Return(findmntCmd("/dev/dm-1\n"))
+				mockMounter.EXPECT().RescanDevice("/dev/dm-1").Return(nil)
+				mockMounter.EXPECT().ResizeFs("/dev/dm-1", "/test/path").Return(nil)
+			},
+		},
+		{
+			name: "success raw block volume",
+			request: &csi.NodeExpandVolumeRequest{
+				VolumeId:         "vol-test",
+				VolumePath:       "/test/path",
+				VolumeCapability: &csi.VolumeCapability{AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}}},
+			},
+			expectMock: func(mockMounter mocks.MockMounter) {
+				mockMounter.EXPECT().ExistsPath("/test/path").Return(true, nil)
+				mockMounter.EXPECT().Command("findmnt", "-o", "source", "--noheadings", "--target", "/test/path").Return(findmntCmd("devtmpfs[/dm-1]\n"))
+				mockMounter.EXPECT().RescanDevice("/dev/dm-1").Return(nil)
+			},
+		},
+		{
+			name:    "fail unpublished volume",
+			request: &csi.NodeExpandVolumeRequest{VolumeId: "vol-test", VolumePath: "/test/path"},
+			expectMock: func(mockMounter mocks.MockMounter) {
+				mockMounter.EXPECT().ExistsPath("/test/path").Return(false, nil)
+			},
+			expectResponseCode: codes.NotFound,
+		},
+		{
+			name:    "fail rescan",
+			request: &csi.NodeExpandVolumeRequest{VolumeId: "vol-test", VolumePath: "/test/path"},
+			expectMock: func(mockMounter mocks.MockMounter) {
+				mockMounter.EXPECT().ExistsPath("/test/path").Return(true, nil)
+				mockMounter.EXPECT().Command("findmnt", "-o", "source", "--noheadings", "--target", "/test/path").Return(findmntCmd("/dev/dm-1\n"))
+				mockMounter.EXPECT().RescanDevice("/dev/dm-1").Return(errors.New("rescan failed"))
+			},
+			expectResponseCode: codes.Internal,
+		},
 	}
 
 	for _, test := range tests {
@@ -312,7 +552,7 @@ func TestNodeExpandVolume(t *testing.T) {
 			if test.expectMock != nil {
 				test.expectMock(*mockMounter)
 			}
-			_, err := powervsDriver.NodeExpandVolume(context.Background(), &test.request)
+			_, err := powervsDriver.NodeExpandVolume(context.Background(), test.request)
 			if err != nil {
 				if test.expectResponseCode != codes.OK {
 					expectErr(t, err, test.expectResponseCode)
@@ -428,6 +668,191 @@ func TestNodeUnpublishVolume(t *testing.T) {
 	}
 }
 
+func TestNodeGetVolumeStats(t *testing.T) {
+	volumePath := "/test/path"
+	fsStats := &util.VolumeStats{TotalBytes: 100, AvailableBytes: 60, UsedBytes: 40, Inodes: 10, InodesFree: 7, InodesUsed: 3}
+
+	testCases := []struct {
+		name       string
+		req        *csi.NodeGetVolumeStatsRequest
+		expectMock func(mockMounter *mocks.MockMounter)
+		expCode    codes.Code
+		expUsage   []*csi.VolumeUsage
+	}{
+		{
+			name: "filesystem volume",
+			req:  &csi.NodeGetVolumeStatsRequest{VolumeId: "vol-test", VolumePath: volumePath},
+			expectMock: func(mockMounter *mocks.MockMounter) {
+				mockMounter.EXPECT().ExistsPath(volumePath).Return(true, nil)
+				mockMounter.EXPECT().GetVolumeStats(volumePath).Return(fsStats, nil)
+			},
+			expUsage: []*csi.VolumeUsage{
+				{Unit: csi.VolumeUsage_BYTES, Total: 100, Available: 60, Used: 40},
+				{Unit: csi.VolumeUsage_INODES, Total: 10, Available: 7, Used: 3},
+			},
+		},
+		{
+			name: "block volume",
+			req:  &csi.NodeGetVolumeStatsRequest{VolumeId: "vol-test", VolumePath: volumePath},
+			expectMock: func(mockMounter *mocks.MockMounter) {
+				mockMounter.EXPECT().ExistsPath(volumePath).Return(true, nil)
+				mockMounter.EXPECT().GetVolumeStats(volumePath).Return(&util.VolumeStats{Block: true, TotalBytes: 100}, nil)
+			},
+			expUsage: []*csi.VolumeUsage{{Unit: csi.VolumeUsage_BYTES, Total: 100}},
+		},
+		{
+			name:    "fail no VolumeId",
+			req:     &csi.NodeGetVolumeStatsRequest{VolumePath: volumePath},
+			expCode: codes.InvalidArgument,
+		},
+		{
+			name:    "fail no VolumePath",
+			req:     &csi.NodeGetVolumeStatsRequest{VolumeId: "vol-test"},
+			expCode: codes.InvalidArgument,
+		},
+		{
+			name: "fail path not found",
+			req:  &csi.NodeGetVolumeStatsRequest{VolumeId: "vol-test", VolumePath: volumePath},
+			expectMock: func(mockMounter *mocks.MockMounter) {
+				mockMounter.EXPECT().ExistsPath(volumePath).Return(false, nil)
+			},
+			expCode: codes.NotFound,
+		},
+		{
+			name: "fail stats error",
+			req:  &csi.NodeGetVolumeStatsRequest{VolumeId: "vol-test", VolumePath: volumePath},
+			expectMock: func(mockMounter *mocks.MockMounter) {
+				mockMounter.EXPECT().ExistsPath(volumePath).Return(true, nil)
+				mockMounter.EXPECT().GetVolumeStats(volumePath).Return(nil, errors.New("statfs failed"))
+			},
+			expCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockMounter := mocks.NewMockMounter(mockCtl)
+			if tc.expectMock != nil {
+				tc.expectMock(mockMounter)
+			}
+			powervsDriver := &nodeService{
+				mounter:       mockMounter,
+				driverOptions: &Options{volumeStats: true},
+				volumeStats:   newStatsCache(time.Minute),
+			}
+
+			resp, err := powervsDriver.NodeGetVolumeStats(context.TODO(), tc.req)
+			if tc.expCode != codes.OK {
+				expectErr(t, err, tc.expCode)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expect no error but got: %v", err)
+			}
+			if !reflect.DeepEqual(resp.GetUsage(), tc.expUsage) {
+				t.Fatalf("Expected usage %+v, got %+v", tc.expUsage, resp.GetUsage())
+			}
+		})
+	}
+}
+
+func TestNodeGetVolumeStatsCondition(t *testing.T) {
+	volumePath := "/test/path"
+	testCases := []struct {
+		name        string
+		expectMock  func(mockMounter *mocks.MockMounter)
+		expAbnormal bool
+	}{
+		{
+			name: "mounted filesystem",
+			expectMock: func(mockMounter *mocks.MockMounter) {
+				mockMounter.EXPECT().GetVolumeStats(volumePath).Return(&util.VolumeStats{TotalBytes: 100}, nil)
+				mockMounter.EXPECT().IsLikelyNotMountPoint(volumePath).Return(false, nil)
+			},
+		},
+		{
+			name: "unmounted filesystem",
+			expectMock: func(mockMounter *mocks.MockMounter) {
+				mockMounter.EXPECT().GetVolumeStats(volumePath).Return(&util.VolumeStats{TotalBytes: 100}, nil)
+				mockMounter.EXPECT().IsLikelyNotMountPoint(volumePath).Return(true, nil)
+			},
+			expAbnormal: true,
+		},
+		{
+			name: "block volume",
+			expectMock: func(mockMounter *mocks.MockMounter) {
+				mockMounter.EXPECT().GetVolumeStats(volumePath).Return(&util.VolumeStats{Block: true, TotalBytes: 100}, nil)
+			},
+		},
+		{
+			name: "stats error",
+			expectMock: func(mockMounter *mocks.MockMounter) {
+				mockMounter.EXPECT().GetVolumeStats(volumePath).Return(nil, errors.New("input/output error"))
+			},
+			expAbnormal: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockMounter := mocks.NewMockMounter(mockCtl)
+			mockMounter.EXPECT().ExistsPath(volumePath).Return(true, nil)
+			tc.expectMock(mockMounter)
+			powervsDriver := &nodeService{
+				mounter:       mockMounter,
+				driverOptions: &Options{volumeStats: true, featureGates: FeatureGates{NodeVolumeCondition: true}},
+				volumeStats:   newStatsCache(time.Minute),
+			}
+
+			resp, err := powervsDriver.NodeGetVolumeStats(context.TODO(), &csi.NodeGetVolumeStatsRequest{VolumeId: "vol-test", VolumePath: volumePath})
+			if err != nil {
+				t.Fatalf("Expect no error but got: %v", err)
+			}
+			condition := resp.GetVolumeCondition()
+			if condition == nil || condition.GetAbnormal() != tc.expAbnormal {
+				t.Fatalf("Expected abnormal condition %v, got %v", tc.expAbnormal, condition)
+			}
+		})
+	}
+}
+
+func TestNodeGetVolumeStatsCache(t *testing.T) {
+	volumePath := "/test/path"
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	mockMounter := mocks.NewMockMounter(mockCtl)
+	powervsDriver := &nodeService{
+		mounter:       mockMounter,
+		driverOptions: &Options{volumeStats: true},
+		volumeLocks:   util.NewVolumeLocks(),
+		volumeStats:   newStatsCache(time.Minute),
+	}
+	req := &csi.NodeGetVolumeStatsRequest{VolumeId: "vol-test", VolumePath: volumePath}
+
+	// the second call is served from the cache, the stats are read again after unpublishing
+	mockMounter.EXPECT().ExistsPath(volumePath).Return(true, nil).Times(3)
+	mockMounter.EXPECT().GetVolumeStats(volumePath).Return(&util.VolumeStats{TotalBytes: 100}, nil).Times(2)
+	mockMounter.EXPECT().Unmount(volumePath).Return(nil)
+	for i := 0; i < 2; i++ {
+		if _, err := powervsDriver.NodeGetVolumeStats(context.TODO(), req); err != nil {
+			t.Fatalf("Expect no error but got: %v", err)
+		}
+	}
+	if _, err := powervsDriver.NodeUnpublishVolume(context.TODO(), &csi.NodeUnpublishVolumeRequest{VolumeId: "vol-test", TargetPath: volumePath}); err != nil {
+		t.Fatalf("Expect no error but got: %v", err)
+	}
+	if _, err := powervsDriver.NodeGetVolumeStats(context.TODO(), req); err != nil {
+		t.Fatalf("Expect no error but got: %v", err)
+	}
+}
+
 func TestNodeGetCapabilities(t *testing.T) {
 	mockCtl := gomock.NewController(t)
 	defer mockCtl.Finish()
@@ -435,7 +860,8 @@ func TestNodeGetCapabilities(t *testing.T) {
 	mockMounter := mocks.NewMockMounter(mockCtl)
 
 	powervsDriver := nodeService{
-		mounter: mockMounter,
+		mounter:       mockMounter,
+		driverOptions: &Options{volumeStats: true},
 	}
 
 	caps := []*csi.NodeServiceCapability{
@@ -453,6 +879,13 @@ func TestNodeGetCapabilities(t *testing.T) {
 				},
 			},
 		},
+		{
+			Type: &csi.NodeServiceCapability_Rpc{
+				Rpc: &csi.NodeServiceCapability_RPC{
+					Type: csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+				},
+			},
+		},
 	}
 	expResp := &csi.NodeGetCapabilitiesResponse{Capabilities: caps}
 
@@ -477,7 +910,12 @@ func TestNodeGetInfo(t *testing.T) {
 		instanceType      string
 		availabilityZone  string
 		volumeAttachLimit int64
+		storageAdapter    string
+		adapterErr        error
+		storagePool       string
+		gates             FeatureGates
 		expMaxVolumes     int64
+		expStoragePool    string
 	}{
 		{
 			name:              "success normal",
@@ -487,6 +925,43 @@ func TestNodeGetInfo(t *testing.T) {
 			volumeAttachLimit: 30,
 			expMaxVolumes:     30,
 		},
+		{
+			name:              "NPIV adapter",
+			instanceID:        "i-123456789abcdef01",
+			volumeAttachLimit: -1,
+			storageAdapter:    adapterNPIV,
+			expMaxVolumes:     npivVolumeLimit - 1,
+		},
+		{
+			name:              "vSCSI adapter",
+			instanceID:        "i-123456789abcdef01",
+			volumeAttachLimit: -1,
+			storageAdapter:    adapterVSCSI,
+			expMaxVolumes:     vscsiVolumeLimit - 1,
+		},
+		{
+			name:              "adapter not detected",
+			instanceID:        "i-123456789abcdef01",
+			volumeAttachLimit: -1,
+			adapterErr:        errors.New("no such file or directory"),
+			expMaxVolumes:     defaultMaxVolumesPerInstance,
+		},
+		{
+			name:              "storage pool topology",
+			instanceID:        "i-123456789abcdef01",
+			volumeAttachLimit: 30,
+			storagePool:       "Tier3-Flash-1",
+			gates:             FeatureGates{StoragePoolTopology: true},
+			expMaxVolumes:     30,
+			expStoragePool:    "Tier3-Flash-1",
+		},
+		{
+			name:              "storage pool without feature gate",
+			instanceID:        "i-123456789abcdef01",
+			volumeAttachLimit: 30,
+			storagePool:       "Tier3-Flash-1",
+			expMaxVolumes:     30,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -495,18 +970,23 @@ func TestNodeGetInfo(t *testing.T) {
 
 			driverOptions := &Options{
 				volumeAttachLimit: tc.volumeAttachLimit,
+				storagePool:       tc.storagePool,
+				featureGates:      tc.gates,
 			}
 
 			mockMounter := mocks.NewMockMounter(mockCtl)
 			mockCloud := cloudmocks.NewMockCloud(mockCtl)
+			if tc.volumeAttachLimit < 0 {
+				mockMounter.EXPECT().GetStorageAdapter().Return(tc.storageAdapter, tc.adapterErr)
+			}
 
-			mockCloud.EXPECT().GetPVMInstanceByID(tc.instanceID).Return(&cloud.PVMInstance{
+			mockCloud.EXPECT().GetPVMInstanceByID(gomock.Any(), tc.instanceID).Return(&cloud.PVMInstance{
 				ID:      tc.instanceID,
 				Name:    tc.name,
 				ImageID: "test-image",
 			}, nil)
 
-			mockCloud.EXPECT().GetImageByID(gomock.Eq("test-image")).Return(&cloud.PVMImage{
+			mockCloud.EXPECT().GetImageByID(gomock.Any(), gomock.Eq("test-image")).Return(&cloud.PVMImage{
 				ID:       "test-image",
 				Name:     "test-image",
 				DiskType: "tier3",
@@ -535,7 +1015,9 @@ func TestNodeGetInfo(t *testing.T) {
 			if resp.GetMaxVolumesPerNode() != tc.expMaxVolumes {
 				t.Fatalf("Expected %d max volumes per node, got %d", tc.expMaxVolumes, resp.GetMaxVolumesPerNode())
 			}
-
+			if pool := resp.GetAccessibleTopology().GetSegments()[StoragePoolTopologyKey]; pool != tc.expStoragePool {
+				t.Fatalf("Expected storage pool %q in the topology, got %q", tc.expStoragePool, pool)
+			}
 		})
 	}
 }
@@ -554,3 +1036,12 @@ func expectErr(t *testing.T, actualErr error, expectedCode codes.Code) {
 		t.Fatalf("Expected error code %d, got %d message %s", codes.InvalidArgument, status.Code(), status.Message())
 	}
 }
+
+// findmntCmd returns a command printing the source of a mount like findmnt
+func findmntCmd(source string) exec.Cmd {
+	return &testingexec.FakeCmd{
+		OutputScript: []testingexec.FakeAction{
+			func() ([]byte, []byte, error) { return []byte(source), nil, nil },
+		},
+	}
+}
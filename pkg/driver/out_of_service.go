@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// OutOfServiceTaintKey is the taint of the nodes which an administrator declared shut down,
+// the attach detach controller then detaches their volumes without waiting for the unmounts
+const OutOfServiceTaintKey = "node.kubernetes.io/out-of-service"
+
+// outOfServiceNodes looks up the out-of-service taint of the nodes of pvm instances, watched
+// by the node informer of factory
+type outOfServiceNodes struct {
+	nodes  corelisters.NodeLister
+	synced cache.InformerSynced
+}
+
+// newOutOfServiceNodes registers the node informer in factory, which shares it with the
+// VolumeAttachment check
+func newOutOfServiceNodes(factory informers.SharedInformerFactory) *outOfServiceNodes {
+	nodes := factory.Core().V1().Nodes()
+	return &outOfServiceNodes{nodes: nodes.Lister(), synced: nodes.Informer().HasSynced}
+}
+
+// outOfService returns true if the Kubernetes node of the pvm instance nodeID is tainted
+// out-of-service, a failed lookup is logged and treated as a node in service, like every
+// node until the informer synced
+func (n *outOfServiceNodes) outOfService(nodeID string) bool {
+	if n == nil {
+		return false
+	}
+	if !n.synced() {
+		klog.V(4).Infof("Nodes not synced yet, not checking if node %s is out of service", nodeID)
+		return false
+	}
+	// the node name isn't known, the node is found by its pvm instance label or provider ID
+	nodes, err := n.nodes.List(labels.Everything())
+	if err != nil {
+		klog.Warningf("Could not list the nodes to check if node %s is out of service: %v", nodeID, err)
+		return false
+	}
+	for _, node := range nodes {
+		if cloud.NodePvmInstanceID(node) != nodeID {
+			continue
+		}
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == OutOfServiceTaintKey && taint.Effect == v1.TaintEffectNoExecute {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// detachOutOfService force detaches the disk diskID of volumeID from nodeID without trying a
+// regular detach first when the node is out of service. The instance of the node must still
+// be powered off or in error, the taint alone isn't trusted. It returns true if the disk was
+// detached.
+func (d *controllerService) detachOutOfService(ctx context.Context, c cloud.Cloud, volumeID, diskID, nodeID string) bool {
+	if !d.outOfServiceNodes.outOfService(nodeID) {
+		return false
+	}
+	if !forceDetachDisk(ctx, c, volumeID, diskID, nodeID) {
+		klog.Warningf("Node %s is out of service but its instance isn't down, detaching volume %s normally", nodeID, volumeID)
+		return false
+	}
+	klog.Warningf("Force detached volume %s from out of service node %s", volumeID, nodeID)
+	d.events.volumeWarning(volumeID, EventForceDetached, "Volume was force detached from node %s, which is tainted %s and whose instance is powered off or in error", nodeID, OutOfServiceTaintKey)
+	return true
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
+)
+
+func outOfServiceNode(name string, labels map[string]string, providerID string, effect v1.TaintEffect) *v1.Node {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec:       v1.NodeSpec{ProviderID: providerID},
+	}
+	if effect != "" {
+		node.Spec.Taints = []v1.Taint{{Key: OutOfServiceTaintKey, Value: "nodeshutdown", Effect: effect}}
+	}
+	return node
+}
+
+// newSyncedOutOfServiceNodes returns the out of service lookup of nodes once their informer synced
+func newSyncedOutOfServiceNodes(t *testing.T, nodes ...runtime.Object) *outOfServiceNodes {
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory := informers.NewSharedInformerFactory(kubefake.NewSimpleClientset(nodes...), 0)
+	n := newOutOfServiceNodes(factory)
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, n.synced) {
+		t.Fatalf("node informer did not sync")
+	}
+	return n
+}
+
+func TestOutOfService(t *testing.T) {
+	nodes := newSyncedOutOfServiceNodes(t,
+		outOfServiceNode("labeled", map[string]string{cloud.PvmInstanceIdLabel: "pvm-1"}, "", v1.TaintEffectNoExecute),
+		outOfServiceNode("provider", nil, "ibmpowervs://us-south/dal12/ws/pvm-2", v1.TaintEffectNoExecute),
+		outOfServiceNode("no-schedule", map[string]string{cloud.PvmInstanceIdLabel: "pvm-3"}, "", v1.TaintEffectNoSchedule),
+		outOfServiceNode("in-service", map[string]string{cloud.PvmInstanceIdLabel: "pvm-4"}, "", ""),
+	)
+
+	expected := map[string]bool{"pvm-1": true, "pvm-2": true, "pvm-3": false, "pvm-4": false, "pvm-5": false}
+	for nodeID, exp := range expected {
+		if got := nodes.outOfService(nodeID); got != exp {
+			t.Errorf("expected node %s out of service %v, got %v", nodeID, exp, got)
+		}
+	}
+	if (*outOfServiceNodes)(nil).outOfService("pvm-1") {
+		t.Errorf("expected no out of service nodes when disabled")
+	}
+}
+
+func TestControllerUnpublishVolumeOutOfService(t *testing.T) {
+	testCases := []struct {
+		name          string
+		taint         v1.TaintEffect
+		instanceState string
+		expForced     bool
+	}{
+		{
+			name:          "out of service powered off node",
+			taint:         v1.TaintEffectNoExecute,
+			instanceState: cloud.InstanceShutoffState,
+			expForced:     true,
+		},
+		{
+			name:          "out of service node in error",
+			taint:         v1.TaintEffectNoExecute,
+			instanceState: cloud.InstanceErrorState,
+			expForced:     true,
+		},
+		{
+			name:          "out of service active node",
+			taint:         v1.TaintEffectNoExecute,
+			instanceState: cloud.InstanceActiveState,
+		},
+		{
+			name:          "powered off node in service",
+			instanceState: cloud.InstanceShutoffState,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeCloud := fake.NewCloud(0)
+			disk := attachFakeDisks(t, fakeCloud, 1)[0]
+			fakeCloud.SetInstanceState(expInstanceID, tc.instanceState)
+
+			d := newBatchingControllerService(fakeCloud, 0)
+			d.outOfServiceNodes = newSyncedOutOfServiceNodes(t,
+				outOfServiceNode("worker-0", map[string]string{cloud.PvmInstanceIdLabel: expInstanceID}, "", tc.taint),
+			)
+			req := &csi.ControllerUnpublishVolumeRequest{VolumeId: disk.VolumeID, NodeId: expInstanceID}
+			if _, err := d.ControllerUnpublishVolume(context.Background(), req); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if attached, _ := fakeCloud.IsAttached(context.Background(), disk.VolumeID, expInstanceID); attached {
+				t.Fatalf("Expected volume %s to be detached", disk.VolumeID)
+			}
+			// a forced detach skips the regular detach of the unreachable node
+			expDetaches := 1
+			if tc.expForced {
+				expDetaches = 0
+			}
+			if n := fakeCloud.CallCount("DetachDisk"); n != expDetaches {
+				t.Fatalf("Expected %d regular detaches, got %d", expDetaches, n)
+			}
+		})
+	}
+}
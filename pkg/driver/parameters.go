@@ -0,0 +1,33 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver/params"
+)
+
+// parseVolumeParameters parses the StorageClass parameters of CreateVolume and GetCapacity,
+// invalid parameters fail with InvalidArgument listing all problems of params.Parse
+func parseVolumeParameters(parameters map[string]string) (*params.Parameters, error) {
+	p, err := params.Parse(parameters)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid StorageClass parameters: %v", err)
+	}
+	return p, nil
+}
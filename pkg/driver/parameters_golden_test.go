@@ -0,0 +1,195 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/mock/gomock"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	mocks "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/mocks"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+	"sigs.k8s.io/yaml"
+)
+
+// updateGolden rewrites the golden files with the results of the tests instead of comparing
+// them: go test ./pkg/driver -run Golden -update-golden
+var updateGolden = flag.Bool("update-golden", false, "update the golden files of the tests")
+
+// parametersGolden is the content of a golden file of TestCreateVolumeParametersGolden, the
+// request is included so that reviews of a changed file see what it was created from
+type parametersGolden struct {
+	Parameters    map[string]string  `json:"parameters,omitempty"`
+	RequiredBytes int64              `json:"requiredBytes,omitempty"`
+	LimitBytes    int64              `json:"limitBytes,omitempty"`
+	DiskOptions   *cloud.DiskOptions `json:"diskOptions,omitempty"`
+	Error         string             `json:"error,omitempty"`
+}
+
+// TestCreateVolumeParametersGolden checks the DiskOptions of the volumes of representative
+// StorageClasses against testdata/parameters, changes of the interpretation of the parameters
+// show up as changes of the golden files
+func TestCreateVolumeParametersGolden(t *testing.T) {
+	options := &Options{
+		kubernetesClusterID: "cluster-1",
+		extraTags:           map[string]string{"owner": "platform"},
+	}
+
+	testCases := []struct {
+		name          string
+		parameters    map[string]string
+		requiredBytes int64
+		limitBytes    int64
+	}{
+		{
+			name:          "defaults",
+			requiredBytes: util.GiB,
+		},
+		{
+			name: "default-size",
+		},
+		{
+			name:          "size-rounding",
+			parameters:    map[string]string{VolumeTypeKey: cloud.VolumeTypeTier1},
+			requiredBytes: 10*util.GiB + 1,
+		},
+		{
+			name:          "size-limit",
+			requiredBytes: 2*util.GiB + 1,
+			limitBytes:    3*util.GiB + util.GiB/2,
+		},
+		{
+			name:          "below-minimum-size",
+			requiredBytes: 1,
+		},
+		{
+			name: "pool-replication",
+			parameters: map[string]string{
+				VolumeTypeKey:         cloud.VolumeTypeTier3,
+				StoragePoolKey:        "Tier3-Flash-1",
+				ReplicationEnabledKey: "true",
+			},
+			requiredBytes: 20 * util.GiB,
+		},
+		{
+			name: "tags",
+			parameters: map[string]string{
+				TagKeyPrefix + "_1": "team=storage",
+				TagKeyPrefix + "_2": "env=prod",
+				// the cluster tag of the driver wins over the tags of the StorageClass
+				TagKeyPrefix + "_3": ClusterIDTagKey + "=other-cluster",
+				PVCNameKey:          "data",
+				PVCNamespaceKey:     "default",
+				PVNameKey:           "pvc-1234",
+			},
+			requiredBytes: util.GiB,
+		},
+		{
+			name: "case-insensitive-keys",
+			parameters: map[string]string{
+				"Type":        cloud.VolumeTypeTier0,
+				"StoragePool": "Tier0-Flash-2",
+			},
+			requiredBytes: util.GiB,
+		},
+		{
+			name: "node-parameters",
+			parameters: map[string]string{
+				FsckPolicyKey:    "fail",
+				FormatOptionsKey: "-E nodiscard",
+			},
+			requiredBytes: util.GiB,
+		},
+		{
+			name:          "iops",
+			parameters:    map[string]string{VolumeTypeKey: cloud.VolumeTypeTier3, IOPSKey: "100000"},
+			requiredBytes: 10 * util.GiB,
+		},
+		{
+			name:          "invalid-type",
+			parameters:    map[string]string{VolumeTypeKey: "tier9"},
+			requiredBytes: util.GiB,
+		},
+		{
+			name:          "unknown-parameter",
+			parameters:    map[string]string{"volumeSize": "10"},
+			requiredBytes: util.GiB,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			result := &parametersGolden{Parameters: tc.parameters, RequiredBytes: tc.requiredBytes, LimitBytes: tc.limitBytes}
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			mockCloud.EXPECT().GetDiskByName(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+			mockCloud.EXPECT().CreateDisk(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, name string, opts *cloud.DiskOptions) (*cloud.Disk, error) {
+				result.DiskOptions = opts
+				return &cloud.Disk{VolumeID: "vol-test", DiskType: opts.VolumeType, CapacityGiB: util.BytesToGiB(opts.CapacityBytes)}, nil
+			}).AnyTimes()
+
+			powervsDriver := controllerService{
+				cloud:         mockCloud,
+				driverOptions: options,
+				volumeLocks:   util.NewVolumeLocks(),
+			}
+			req := &csi.CreateVolumeRequest{
+				Name:               "pvc-1234",
+				VolumeCapabilities: []*csi.VolumeCapability{mountCapability("")},
+				Parameters:         tc.parameters,
+			}
+			if tc.requiredBytes != 0 || tc.limitBytes != 0 {
+				req.CapacityRange = &csi.CapacityRange{RequiredBytes: tc.requiredBytes, LimitBytes: tc.limitBytes}
+			}
+			if _, err := powervsDriver.CreateVolume(context.Background(), req); err != nil {
+				s, _ := status.FromError(err)
+				result.Error = fmt.Sprintf("%s: %s", s.Code(), s.Message())
+			}
+
+			actual, err := yaml.Marshal(result)
+			if err != nil {
+				t.Fatalf("could not marshal the result: %v", err)
+			}
+			expectGolden(t, filepath.Join("testdata", "parameters", tc.name+".golden"), actual)
+		})
+	}
+}
+
+// expectGolden compares actual with the golden file path, or writes it with -update-golden
+func expectGolden(t *testing.T, path string, actual []byte) {
+	t.Helper()
+	if *updateGolden {
+		if err := ioutil.WriteFile(path, actual, 0644); err != nil {
+			t.Fatalf("could not update golden file %s: %v", path, err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read golden file %s, run the test with -update-golden to create it: %v", path, err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Fatalf("result differs from golden file %s, run the test with -update-golden if the change is expected:\n--- expected\n%s\n--- actual\n%s", path, expected, actual)
+	}
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseVolumeParameters(t *testing.T) {
+	p, err := parseVolumeParameters(map[string]string{VolumeTypeKey: "tier3", PVCNameKey: "claim"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.VolumeType != "tier3" || p.MetadataTags[PVCNameTagKey] != "claim" {
+		t.Fatalf("unexpected parameters %+v", p)
+	}
+
+	_, err = parseVolumeParameters(map[string]string{VolumeTypeKey: "tier2", "tpye": "tier1"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got: %v", err)
+	}
+	for _, s := range []string{"Invalid StorageClass parameters", `"tier2" of parameter type`, "unknown parameters tpye"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("expected %q in error, got: %v", s, err)
+		}
+	}
+}
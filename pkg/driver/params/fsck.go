@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package params
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FsckPolicy is how NodeStageVolume checks the existing filesystem of a volume before mounting it
+type FsckPolicy string
+
+const (
+	// FsckPolicyNone mounts filesystems without checking them
+	FsckPolicyNone FsckPolicy = "none"
+	// FsckPolicyWarn checks filesystems read-only and mounts them even if they have errors
+	FsckPolicyWarn FsckPolicy = "warn"
+	// FsckPolicyFail checks filesystems read-only, filesystems with errors aren't mounted
+	FsckPolicyFail FsckPolicy = "fail"
+	// FsckPolicyRepair repairs filesystems, those that can't be repaired aren't mounted
+	FsckPolicyRepair FsckPolicy = "repair"
+)
+
+var fsckPolicies = []FsckPolicy{FsckPolicyNone, FsckPolicyWarn, FsckPolicyFail, FsckPolicyRepair}
+
+// ParseFsckPolicy parses the name of a FsckPolicy
+func ParseFsckPolicy(s string) (FsckPolicy, error) {
+	for _, policy := range fsckPolicies {
+		if s == string(policy) {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("invalid fsck policy %q, valid values: %s", s, strings.Join(fsckPolicyNames(), ", "))
+}
+
+func fsckPolicyNames() []string {
+	names := make([]string, len(fsckPolicies))
+	for i, policy := range fsckPolicies {
+		names[i] = string(policy)
+	}
+	return names
+}
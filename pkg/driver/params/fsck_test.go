@@ -0,0 +1,28 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package params
+
+import "testing"
+
+func TestParseFsckPolicy(t *testing.T) {
+	for _, policy := range fsckPolicies {
+		parsed, err := ParseFsckPolicy(string(policy))
+		if err != nil || parsed != policy {
+			t.Fatalf("expected policy %s, got %s: %v", policy, parsed, err)
+		}
+	}
+	if _, err := ParseFsckPolicy("always"); err == nil {
+		t.Fatalf("expected an error for an invalid policy")
+	}
+}
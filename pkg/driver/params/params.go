@@ -0,0 +1,257 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package params parses and validates the StorageClass parameters of the IBM PowerVS block
+storage driver, the parameters of CreateVolume and GetCapacity.
+
+It is the source of truth of the supported parameter keys for the driver and for tools
+checking StorageClasses before the driver sees them, e.g. admission webhooks, documentation
+generators or the audit CLI:
+
+	p, err := params.Parse(storageClass.Parameters)
+	var invalid *params.ValidationError
+	if errors.As(err, &invalid) {
+		for _, problem := range invalid.Problems {
+			fmt.Printf("%s: %s\n", problem.Key, problem.Message)
+		}
+	}
+
+Parse only depends on the parameters, it doesn't check that the workspace or storage pool
+exist.
+*/
+package params
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// keys of the StorageClass parameters
+const (
+	// VolumeTypeKey is the PowerVS storage tier of the volume, one of cloud.ValidVolumeTypes
+	VolumeTypeKey = "type"
+
+	// IOPSKey requires the tier of the volume to provide at least the given IOPS at the
+	// requested size, PowerVS volumes can't be created with custom IOPS
+	IOPSKey = "iops"
+
+	// WorkspaceKey selects the cloud instance ID of the PowerVS workspace volumes are created in,
+	// it must be one of the --cloud-instance-ids managed by the controller
+	WorkspaceKey = "workspace"
+
+	// ReplicationEnabledKey creates volumes replicated by the PowerVS Global Replication
+	// Service, the value is parsed as bool
+	ReplicationEnabledKey = "replicationEnabled"
+
+	// StoragePoolKey places volumes in the PowerVS storage pool of the given name, e.g. to
+	// keep them on the same backend as other volumes of the workload
+	StoragePoolKey = "storagePool"
+
+	// FsckPolicyKey is the FsckPolicy NodeStage checks the filesystem of the volume with, it's
+	// passed on in the volume context
+	FsckPolicyKey = "fsckPolicy"
+
+	// FormatOptionsKey are the space separated mkfs options NodeStage formats the volume with,
+	// they are passed on in the volume context
+	FormatOptionsKey = "formatOptions"
+
+	// TagKeyPrefix is the prefix of the keys of tag parameters, the values have the form
+	// "<key>=<value>", e.g. tagSpecification_1: "team=storage"
+	TagKeyPrefix = "tagSpecification"
+
+	// PVCNameKey, PVCNamespaceKey and PVNameKey are passed by the external-provisioner when it
+	// runs with --extra-create-metadata
+	PVCNameKey      = "csi.storage.k8s.io/pvc/name"
+	PVCNamespaceKey = "csi.storage.k8s.io/pvc/namespace"
+	PVNameKey       = "csi.storage.k8s.io/pv/name"
+)
+
+// keys of the metadata tags of the volumes of the parameters passed by the external-provisioner
+const (
+	PVCNameTagKey      = "kubernetes-pvc-name"
+	PVCNamespaceTagKey = "kubernetes-pvc-namespace"
+	PVNameTagKey       = "kubernetes-pv-name"
+)
+
+// Key describes a StorageClass parameter
+type Key struct {
+	// Name is the key of the parameter, keys are case insensitive
+	Name string
+	// Values describes the valid values
+	Values string
+	// Description is a one sentence description of the parameter
+	Description string
+}
+
+// Keys are the parameters a StorageClass may set, the keys passed by the external-provisioner
+// aren't included
+var Keys = []Key{
+	{Name: VolumeTypeKey, Values: strings.Join(cloud.ValidVolumeTypes, ", "), Description: "PowerVS storage tier of the volume."},
+	{Name: IOPSKey, Values: "positive integer", Description: "Minimum IOPS the tier of the volume must provide at the requested size."},
+	{Name: WorkspaceKey, Values: "cloud instance ID", Description: "PowerVS workspace the volume is created in, one of the workspaces managed by the controller."},
+	{Name: ReplicationEnabledKey, Values: "true, false", Description: "Replicate the volume with the Global Replication Service."},
+	{Name: StoragePoolKey, Values: "storage pool name", Description: "PowerVS storage pool the volume is created in."},
+	{Name: FsckPolicyKey, Values: strings.Join(fsckPolicyNames(), ", "), Description: "How the existing filesystem of the volume is checked before it is mounted."},
+	{Name: FormatOptionsKey, Values: "mkfs options", Description: "Space separated options the volume is formatted with."},
+	{Name: TagKeyPrefix + "<suffix>", Values: "key=value", Description: "Tag attached to the volume."},
+}
+
+// Parameters are the parsed StorageClass parameters
+type Parameters struct {
+	VolumeType         string
+	Workspace          string
+	StoragePool        string
+	ReplicationEnabled bool
+	IOPS               int64
+	// Tags are the TagKeyPrefix tags of the StorageClass
+	Tags map[string]string
+	// MetadataTags are the tags of the PVC and PV names passed by the external-provisioner
+	MetadataTags map[string]string
+	PVCName      string
+	PVCNamespace string
+	// VolumeContext are the parameters of the node, passed on in the volume context
+	VolumeContext map[string]string
+}
+
+// Problem is an invalid StorageClass parameter
+type Problem struct {
+	// Key is the key of the parameter as set in the StorageClass
+	Key string
+	// Message describes the problem, it names the key
+	Message string
+}
+
+// ValidationError is the error of invalid parameters, it lists all problems at once so that
+// a StorageClass can be fixed in one go
+type ValidationError struct {
+	Problems []Problem
+	// Unknown are the keys that aren't parameters of the driver
+	Unknown []string
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Problems)+1)
+	for _, p := range e.Problems {
+		messages = append(messages, p.Message)
+	}
+	if len(e.Unknown) > 0 {
+		supported := make([]string, len(Keys))
+		for i, k := range Keys {
+			supported[i] = k.Name
+		}
+		messages = append(messages, fmt.Sprintf("unknown parameters %s, supported parameters: %s", strings.Join(e.Unknown, ", "), strings.Join(supported, ", ")))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Parse parses and validates the StorageClass parameters params, keys are case insensitive.
+// Unknown keys, keys set twice and invalid values fail with a *ValidationError.
+func Parse(params map[string]string) (*Parameters, error) {
+	p := &Parameters{Tags: map[string]string{}, MetadataTags: map[string]string{}, VolumeContext: map[string]string{}}
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	invalid := &ValidationError{}
+	problem := func(key, format string, args ...interface{}) {
+		invalid.Problems = append(invalid.Problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+	seen := map[string]string{}
+	for _, key := range keys {
+		value := params[key]
+		lower := strings.ToLower(key)
+		if prev, ok := seen[lower]; ok {
+			problem(key, "parameter %s is set twice, as %s and %s", key, prev, key)
+			continue
+		}
+		seen[lower] = key
+
+		switch lower {
+		case VolumeTypeKey:
+			if !IsValidVolumeType(value) {
+				problem(key, "invalid value %q of parameter %s, valid values: %s", value, key, strings.Join(cloud.ValidVolumeTypes, ", "))
+			}
+			p.VolumeType = value
+		case WorkspaceKey:
+			p.Workspace = value
+		case IOPSKey:
+			iops, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || iops <= 0 {
+				problem(key, "invalid value %q of parameter %s, it must be a positive integer", value, key)
+			}
+			p.IOPS = iops
+		case strings.ToLower(ReplicationEnabledKey):
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				problem(key, "invalid value %q of parameter %s, it must be true or false", value, key)
+			}
+			p.ReplicationEnabled = enabled
+		case strings.ToLower(StoragePoolKey):
+			p.StoragePool = value
+		case strings.ToLower(FsckPolicyKey):
+			if _, err := ParseFsckPolicy(value); err != nil {
+				problem(key, "invalid value of parameter %s: %v", key, err)
+			}
+			p.VolumeContext[FsckPolicyKey] = value
+		case strings.ToLower(FormatOptionsKey):
+			p.VolumeContext[FormatOptionsKey] = value
+		case PVCNameKey:
+			p.MetadataTags[PVCNameTagKey] = value
+			p.PVCName = value
+		case PVCNamespaceKey:
+			p.MetadataTags[PVCNamespaceTagKey] = value
+			p.PVCNamespace = value
+		case PVNameKey:
+			p.MetadataTags[PVNameTagKey] = value
+		default:
+			if !strings.HasPrefix(lower, strings.ToLower(TagKeyPrefix)) {
+				invalid.Unknown = append(invalid.Unknown, key)
+				continue
+			}
+			k, v, err := ParseTagParameter(value)
+			if err == nil {
+				err = ValidateTag(k, v)
+			}
+			if err != nil {
+				problem(key, "invalid tag parameter %s: %v", key, err)
+				continue
+			}
+			p.Tags[k] = v
+		}
+	}
+
+	if len(invalid.Problems) > 0 || len(invalid.Unknown) > 0 {
+		return nil, invalid
+	}
+	return p, nil
+}
+
+// IsValidVolumeType returns true if volumeType is one of cloud.ValidVolumeTypes
+func IsValidVolumeType(volumeType string) bool {
+	for _, t := range cloud.ValidVolumeTypes {
+		if t == volumeType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package params
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func FuzzParse(f *testing.F) {
+	f.Add(VolumeTypeKey, "tier1", IOPSKey, "100")
+	f.Add("TYPE", "tier3", VolumeTypeKey, "tier1")
+	f.Add(TagKeyPrefix+"team", "team=storage", TagKeyPrefix+"env", " env ")
+	f.Add(ReplicationEnabledKey, "true", StoragePoolKey, "Tier1-Flash-1")
+	f.Add(IOPSKey, "99999999999999999999", "unknown", "")
+	f.Add(PVCNameKey, "claim", PVCNamespaceKey, "ns")
+	f.Fuzz(func(t *testing.T, key1, value1, key2, value2 string) {
+		p, err := Parse(map[string]string{key1: value1, key2: value2})
+		if err != nil {
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("expected a ValidationError, got %v", err)
+			}
+			return
+		}
+		if p.VolumeType != "" && !IsValidVolumeType(p.VolumeType) {
+			t.Fatalf("accepted invalid volume type %q", p.VolumeType)
+		}
+		if p.IOPS < 0 {
+			t.Fatalf("accepted negative IOPS %d", p.IOPS)
+		}
+		for k, v := range p.Tags {
+			if err := ValidateTag(k, v); err != nil {
+				t.Fatalf("accepted invalid tag %q:%q: %v", k, v, err)
+			}
+			if strings.Contains(k, ":") {
+				t.Fatalf("accepted tag key %q with the key value separator", k)
+			}
+		}
+	})
+}
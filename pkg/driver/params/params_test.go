@@ -0,0 +1,136 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package params
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name        string
+		params      map[string]string
+		expected    *Parameters
+		expectedErr []string
+	}{
+		{
+			name: "all parameters",
+			params: map[string]string{
+				"Type":               "tier3",
+				WorkspaceKey:         "ws",
+				IOPSKey:              "300",
+				"replicationenabled": "true",
+				StoragePoolKey:       "pool",
+				TagKeyPrefix + "_1":  "Team=storage",
+				TagKeyPrefix + "_2":  "backup",
+				PVCNameKey:           "claim",
+				PVCNamespaceKey:      "ns",
+				PVNameKey:            "pvc-1",
+				"FsckPolicy":         "repair",
+				FormatOptionsKey:     "-E nodiscard",
+			},
+			expected: &Parameters{
+				VolumeType:         "tier3",
+				Workspace:          "ws",
+				StoragePool:        "pool",
+				ReplicationEnabled: true,
+				IOPS:               300,
+				Tags:               map[string]string{"Team": "storage", "backup": ""},
+				MetadataTags:       map[string]string{PVCNameTagKey: "claim", PVCNamespaceTagKey: "ns", PVNameTagKey: "pvc-1"},
+				PVCName:            "claim",
+				PVCNamespace:       "ns",
+				VolumeContext:      map[string]string{FsckPolicyKey: "repair", FormatOptionsKey: "-E nodiscard"},
+			},
+		},
+		{
+			name:     "no parameters",
+			expected: &Parameters{Tags: map[string]string{}, MetadataTags: map[string]string{}, VolumeContext: map[string]string{}},
+		},
+		{
+			name:        "unknown parameters",
+			params:      map[string]string{"tpye": "tier1", "fsType": "ext4"},
+			expectedErr: []string{"unknown parameters fsType, tpye", "supported parameters: type, iops"},
+		},
+		{
+			name: "invalid values",
+			params: map[string]string{
+				VolumeTypeKey:         "tier2",
+				IOPSKey:               "-1",
+				ReplicationEnabledKey: "yes",
+				FsckPolicyKey:         "always",
+			},
+			expectedErr: []string{`"tier2" of parameter type`, `"-1" of parameter iops`, `"yes" of parameter replicationEnabled`, "parameter fsckPolicy"},
+		},
+		{
+			name: "invalid tags",
+			params: map[string]string{
+				TagKeyPrefix + "_1": "=storage",
+				TagKeyPrefix + "_2": "team=a/b",
+				TagKeyPrefix + "_3": "team=" + strings.Repeat("a", MaxTagLength),
+			},
+			expectedErr: []string{TagKeyPrefix + "_1", TagKeyPrefix + "_2", TagKeyPrefix + "_3"},
+		},
+		{
+			name:        "parameter set twice",
+			params:      map[string]string{"type": "tier1", "Type": "tier3"},
+			expectedErr: []string{"parameter type is set twice"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := Parse(tc.params)
+			if len(tc.expectedErr) > 0 {
+				var invalid *ValidationError
+				if !errors.As(err, &invalid) {
+					t.Fatalf("expected a ValidationError, got: %v", err)
+				}
+				for _, s := range tc.expectedErr {
+					if !strings.Contains(err.Error(), s) {
+						t.Fatalf("expected %q in error, got: %v", s, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(p, tc.expected) {
+				t.Fatalf("expected parameters %+v, got %+v", tc.expected, p)
+			}
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	_, err := Parse(map[string]string{VolumeTypeKey: "tier2", IOPSKey: "300", "tpye": "tier1"})
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected a ValidationError, got: %v", err)
+	}
+	if len(invalid.Problems) != 1 || invalid.Problems[0].Key != VolumeTypeKey {
+		t.Fatalf("expected a problem of parameter %s, got %+v", VolumeTypeKey, invalid.Problems)
+	}
+	if !reflect.DeepEqual(invalid.Unknown, []string{"tpye"}) {
+		t.Fatalf("expected unknown parameter tpye, got %v", invalid.Unknown)
+	}
+	for _, key := range Keys {
+		if !strings.Contains(err.Error(), key.Name) {
+			t.Fatalf("expected supported parameter %s in error, got: %v", key.Name, err)
+		}
+	}
+}
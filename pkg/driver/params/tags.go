@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package params
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxTagLength is the maximum length of an IBM Cloud tag
+const MaxTagLength = 128
+
+// ValidateTag returns an error if the StorageClass tag key:value isn't a valid IBM Cloud tag,
+// unlike in --extra-tags invalid characters are rejected instead of replaced
+func ValidateTag(key, value string) error {
+	for _, s := range []string{key, value} {
+		if SanitizeTag(s) != strings.ToLower(s) {
+			return fmt.Errorf("tag %q may only contain letters, digits, spaces, '_', '-' and '.'", s)
+		}
+	}
+	tag := key
+	if value != "" {
+		tag = key + ":" + value
+	}
+	if len(tag) > MaxTagLength {
+		return fmt.Errorf("tag %q is longer than %d characters", tag, MaxTagLength)
+	}
+	return nil
+}
+
+// SanitizeTag lower cases s and replaces the characters IBM Cloud tags don't allow, only
+// letters, digits, spaces, '_', '-' and '.' are kept. ':' separates key and value so it is
+// replaced as well.
+func SanitizeTag(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == ' ', r == '_', r == '-', r == '.':
+			return r
+		default:
+			return '-'
+		}
+	}, s)
+}
+
+// ParseTagParameter parses the value of a TagKeyPrefix StorageClass parameter
+func ParseTagParameter(value string) (string, string, error) {
+	kv := strings.SplitN(value, "=", 2)
+	key := strings.TrimSpace(kv[0])
+	if key == "" {
+		return "", "", fmt.Errorf("tag %q has an empty key", value)
+	}
+	if len(kv) == 1 {
+		return key, "", nil
+	}
+	return key, strings.TrimSpace(kv[1]), nil
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package params
+
+import "testing"
+
+func TestParseTagParameter(t *testing.T) {
+	testCases := []struct {
+		value     string
+		key       string
+		val       string
+		expectErr bool
+	}{
+		{value: "team=storage", key: "team", val: "storage"},
+		{value: " team = a=b ", key: "team", val: "a=b"},
+		{value: "label", key: "label"},
+		{value: "=value", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			key, val, err := ParseTagParameter(tc.value)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.expectErr, err)
+			}
+			if key != tc.key || val != tc.val {
+				t.Fatalf("expected %q=%q, got %q=%q", tc.key, tc.val, key, val)
+			}
+		})
+	}
+}
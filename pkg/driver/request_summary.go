@@ -0,0 +1,185 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+// summarizeRequest returns a compact, secret free description of a CSI request
+func summarizeRequest(req interface{}) string {
+	s := &summary{}
+	switch r := req.(type) {
+	case *csi.CreateVolumeRequest:
+		s.add("name", r.GetName())
+		s.addCapacityRange(r.GetCapacityRange())
+		s.addCapabilities(r.GetVolumeCapabilities()...)
+		s.addKeys("parameters", r.GetParameters())
+		s.addKeys("mutableParameters", r.GetMutableParameters())
+		if src := r.GetVolumeContentSource(); src != nil {
+			s.add("contentSource", fmt.Sprintf("%T", src.GetType()))
+		}
+	case *csi.DeleteVolumeRequest:
+		s.add("volumeID", r.GetVolumeId())
+	case *csi.ControllerPublishVolumeRequest:
+		s.add("volumeID", r.GetVolumeId())
+		s.add("nodeID", r.GetNodeId())
+		s.add("readonly", r.GetReadonly())
+		s.addCapabilities(r.GetVolumeCapability())
+	case *csi.ControllerUnpublishVolumeRequest:
+		s.add("volumeID", r.GetVolumeId())
+		s.add("nodeID", r.GetNodeId())
+	case *csi.ValidateVolumeCapabilitiesRequest:
+		s.add("volumeID", r.GetVolumeId())
+		s.addCapabilities(r.GetVolumeCapabilities()...)
+	case *csi.ControllerExpandVolumeRequest:
+		s.add("volumeID", r.GetVolumeId())
+		s.addCapacityRange(r.GetCapacityRange())
+	case *csi.ControllerModifyVolumeRequest:
+		s.add("volumeID", r.GetVolumeId())
+		s.addKeys("mutableParameters", r.GetMutableParameters())
+	case *csi.CreateVolumeGroupSnapshotRequest:
+		s.add("name", r.GetName())
+		s.add("sourceVolumeIDs", r.GetSourceVolumeIds())
+	case *csi.DeleteVolumeGroupSnapshotRequest:
+		s.add("groupSnapshotID", r.GetGroupSnapshotId())
+	case *csi.GetVolumeGroupSnapshotRequest:
+		s.add("groupSnapshotID", r.GetGroupSnapshotId())
+	case *csi.NodeStageVolumeRequest:
+		s.add("volumeID", r.GetVolumeId())
+		s.add("stagingTargetPath", r.GetStagingTargetPath())
+		s.addCapabilities(r.GetVolumeCapability())
+		s.addKeys("publishContext", r.GetPublishContext())
+	case *csi.NodeUnstageVolumeRequest:
+		s.add("volumeID", r.GetVolumeId())
+		s.add("stagingTargetPath", r.GetStagingTargetPath())
+	case *csi.NodePublishVolumeRequest:
+		s.add("volumeID", r.GetVolumeId())
+		s.add("stagingTargetPath", r.GetStagingTargetPath())
+		s.add("targetPath", r.GetTargetPath())
+		s.add("readonly", r.GetReadonly())
+		s.addCapabilities(r.GetVolumeCapability())
+	case *csi.NodeUnpublishVolumeRequest:
+		s.add("volumeID", r.GetVolumeId())
+		s.add("targetPath", r.GetTargetPath())
+	case *csi.NodeGetVolumeStatsRequest:
+		s.add("volumeID", r.GetVolumeId())
+		s.add("volumePath", r.GetVolumePath())
+	case *csi.NodeExpandVolumeRequest:
+		s.add("volumeID", r.GetVolumeId())
+		s.add("volumePath", r.GetVolumePath())
+		s.addCapacityRange(r.GetCapacityRange())
+	}
+	return s.String()
+}
+
+// summarizeResponse returns a compact description of a CSI response
+func summarizeResponse(resp interface{}) string {
+	s := &summary{}
+	switch r := resp.(type) {
+	case *csi.CreateVolumeResponse:
+		if vol := r.GetVolume(); vol != nil {
+			s.add("volumeID", vol.GetVolumeId())
+			s.add("capacityBytes", vol.GetCapacityBytes())
+			for _, t := range vol.GetAccessibleTopology() {
+				s.add("topology", t.GetSegments())
+			}
+		}
+	case *csi.ControllerPublishVolumeResponse:
+		s.add("publishContext", r.GetPublishContext())
+	case *csi.ValidateVolumeCapabilitiesResponse:
+		s.add("confirmed", r.GetConfirmed() != nil)
+	case *csi.ControllerExpandVolumeResponse:
+		s.add("capacityBytes", r.GetCapacityBytes())
+		s.add("nodeExpansionRequired", r.GetNodeExpansionRequired())
+	case *csi.NodeExpandVolumeResponse:
+		s.add("capacityBytes", r.GetCapacityBytes())
+	case *csi.NodeGetVolumeStatsResponse:
+		for _, u := range r.GetUsage() {
+			s.add(strings.ToLower(u.GetUnit().String()), fmt.Sprintf("%d/%d", u.GetUsed(), u.GetTotal()))
+		}
+	case *csi.NodeGetInfoResponse:
+		s.add("nodeID", r.GetNodeId())
+		s.add("maxVolumesPerNode", r.GetMaxVolumesPerNode())
+		if t := r.GetAccessibleTopology(); t != nil {
+			s.add("topology", t.GetSegments())
+		}
+	}
+	return s.String()
+}
+
+type summary struct {
+	fields []string
+}
+
+func (s *summary) add(key string, value interface{}) {
+	s.fields = append(s.fields, fmt.Sprintf("%s=%v", key, value))
+}
+
+func (s *summary) addCapacityRange(cr *csi.CapacityRange) {
+	if cr == nil {
+		return
+	}
+	s.add("requiredBytes", cr.GetRequiredBytes())
+	if cr.GetLimitBytes() != 0 {
+		s.add("limitBytes", cr.GetLimitBytes())
+	}
+}
+
+func (s *summary) addCapabilities(caps ...*csi.VolumeCapability) {
+	var descs []string
+	for _, c := range caps {
+		if c == nil {
+			continue
+		}
+		desc := c.GetAccessMode().GetMode().String()
+		switch t := c.GetAccessType().(type) {
+		case *csi.VolumeCapability_Block:
+			desc += "/block"
+		case *csi.VolumeCapability_Mount:
+			desc += "/mount"
+			if fsType := t.Mount.GetFsType(); fsType != "" {
+				desc += ":" + fsType
+			}
+		}
+		descs = append(descs, desc)
+	}
+	if len(descs) > 0 {
+		s.add("capabilities", "["+strings.Join(descs, ",")+"]")
+	}
+}
+
+// addKeys only records the keys of m, values may carry user provided data
+func (s *summary) addKeys(key string, m map[string]string) {
+	if len(m) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	s.add(key, "["+strings.Join(keys, ",")+"]")
+}
+
+func (s *summary) String() string {
+	return strings.Join(s.fields, " ")
+}
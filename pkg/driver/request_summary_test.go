@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+func TestSummarizeRequest(t *testing.T) {
+	mountCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{FsType: FSTypeXfs}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+	testCases := []struct {
+		name     string
+		req      interface{}
+		expected string
+	}{
+		{
+			name: "create volume omits secrets and parameter values",
+			req: &csi.CreateVolumeRequest{
+				Name:               "pvc-1",
+				CapacityRange:      &csi.CapacityRange{RequiredBytes: 1073741824},
+				VolumeCapabilities: []*csi.VolumeCapability{mountCap},
+				Parameters:         map[string]string{"type": "tier3", "csi.storage.k8s.io/fstype": "xfs"},
+				Secrets:            map[string]string{"apikey": "s3cr3t"},
+			},
+			expected: "name=pvc-1 requiredBytes=1073741824 capabilities=[SINGLE_NODE_WRITER/mount:xfs] parameters=[csi.storage.k8s.io/fstype,type]",
+		},
+		{
+			name: "node stage",
+			req: &csi.NodeStageVolumeRequest{
+				VolumeId:          volumeID,
+				StagingTargetPath: "/staging",
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+					AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+				},
+				PublishContext: map[string]string{WWNKey: "60050768"},
+				Secrets:        map[string]string{"apikey": "s3cr3t"},
+			},
+			expected: "volumeID=" + volumeID + " stagingTargetPath=/staging capabilities=[SINGLE_NODE_WRITER/block] publishContext=[" + WWNKey + "]",
+		},
+		{
+			name:     "unknown request",
+			req:      &csi.GetPluginInfoRequest{},
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if summary := summarizeRequest(tc.req); summary != tc.expected {
+				t.Fatalf("expected summary %q, got %q", tc.expected, summary)
+			}
+		})
+	}
+}
+
+func TestSummarizeResponse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		resp     interface{}
+		expected string
+	}{
+		{
+			name: "create volume",
+			resp: &csi.CreateVolumeResponse{
+				Volume: &csi.Volume{VolumeId: volumeID, CapacityBytes: 1073741824},
+			},
+			expected: "volumeID=" + volumeID + " capacityBytes=1073741824",
+		},
+		{
+			name:     "controller expand",
+			resp:     &csi.ControllerExpandVolumeResponse{CapacityBytes: 2147483648, NodeExpansionRequired: true},
+			expected: "capacityBytes=2147483648 nodeExpansionRequired=true",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if summary := summarizeResponse(tc.resp); summary != tc.expected {
+				t.Fatalf("expected summary %q, got %q", tc.expected, summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// validateRequests is a gRPC interceptor failing CSI requests with missing or malformed IDs,
+// capabilities or paths with InvalidArgument before their handler takes locks or calls the
+// cloud or the mounter
+func validateRequests(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := validateRequest(req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// validateRequest checks the fields of the controller and node requests that the handlers
+// rely on, the other requests are valid
+func validateRequest(req interface{}) error {
+	switch r := req.(type) {
+	case *csi.CreateVolumeRequest:
+		if r.GetName() == "" {
+			return status.Error(codes.InvalidArgument, "Volume name not provided")
+		}
+		return validateCapabilities(r.GetVolumeCapabilities())
+	case *csi.DeleteVolumeRequest:
+		return validateVolumeID(r.GetVolumeId())
+	case *csi.ControllerPublishVolumeRequest:
+		return firstError(validateVolumeID(r.GetVolumeId()), validateNodeID(r.GetNodeId()), validateCapability(r.GetVolumeCapability()))
+	case *csi.ControllerUnpublishVolumeRequest:
+		return firstError(validateVolumeID(r.GetVolumeId()), validateNodeID(r.GetNodeId()))
+	case *csi.ValidateVolumeCapabilitiesRequest:
+		return firstError(validateVolumeID(r.GetVolumeId()), validateCapabilities(r.GetVolumeCapabilities()))
+	case *csi.ControllerExpandVolumeRequest:
+		if err := validateVolumeID(r.GetVolumeId()); err != nil {
+			return err
+		}
+		if r.GetCapacityRange() == nil {
+			return status.Error(codes.InvalidArgument, "Capacity range not provided")
+		}
+		return nil
+	case *csi.ControllerGetVolumeRequest:
+		return validateVolumeID(r.GetVolumeId())
+	case *csi.ControllerModifyVolumeRequest:
+		return validateVolumeID(r.GetVolumeId())
+	case *csi.NodeStageVolumeRequest:
+		return firstError(validateVolumeID(r.GetVolumeId()), validateCapability(r.GetVolumeCapability()), validatePath("Staging target", r.GetStagingTargetPath()))
+	case *csi.NodeUnstageVolumeRequest:
+		return firstError(validateVolumeID(r.GetVolumeId()), validatePath("Staging target", r.GetStagingTargetPath()))
+	case *csi.NodePublishVolumeRequest:
+		return firstError(validateVolumeID(r.GetVolumeId()), validatePath("Staging target", r.GetStagingTargetPath()), validatePath("Target path", r.GetTargetPath()), validateCapability(r.GetVolumeCapability()))
+	case *csi.NodeUnpublishVolumeRequest:
+		return firstError(validateVolumeID(r.GetVolumeId()), validatePath("Target path", r.GetTargetPath()))
+	case *csi.NodeGetVolumeStatsRequest:
+		return firstError(validateVolumeID(r.GetVolumeId()), validateVolumePath(r.GetVolumePath()))
+	case *csi.NodeExpandVolumeRequest:
+		return firstError(validateVolumeID(r.GetVolumeId()), validateVolumePath(r.GetVolumePath()))
+	}
+	return nil
+}
+
+// firstError returns the first non nil error of errs
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateVolumeID checks that a volume handle is set and made of the characters of PowerVS
+// IDs, workspace prefixes and legacy handles
+func validateVolumeID(volumeID string) error {
+	if volumeID == "" {
+		return status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+	if !isValidID(volumeID) {
+		return status.Errorf(codes.InvalidArgument, "Volume ID %q is invalid, expected letters, digits and any of %q", volumeID, idPunctuation)
+	}
+	return nil
+}
+
+// validateNodeID checks that a node ID, the ID of a pvm instance, is set and well formed
+func validateNodeID(nodeID string) error {
+	if nodeID == "" {
+		return status.Error(codes.InvalidArgument, "Node ID not provided")
+	}
+	if !isValidID(nodeID) {
+		return status.Errorf(codes.InvalidArgument, "Node ID %q is invalid, expected letters, digits and any of %q", nodeID, idPunctuation)
+	}
+	return nil
+}
+
+// idPunctuation are the characters of IDs besides letters and digits
+const idPunctuation = "-_.:/"
+
+func isValidID(id string) bool {
+	for _, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.ContainsRune(idPunctuation, c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateCapabilities checks that capabilities are set and each of them is complete
+func validateCapabilities(capabilities []*csi.VolumeCapability) error {
+	if len(capabilities) == 0 {
+		return status.Error(codes.InvalidArgument, "Volume capabilities not provided")
+	}
+	for _, capability := range capabilities {
+		if err := validateCapability(capability); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateCapability checks that a capability has an access type and mode, whether they are
+// supported is up to the handler
+func validateCapability(capability *csi.VolumeCapability) error {
+	if capability == nil {
+		return status.Error(codes.InvalidArgument, "Volume capability not provided")
+	}
+	if capability.GetBlock() == nil && capability.GetMount() == nil {
+		return status.Error(codes.InvalidArgument, "Volume capability has no access type")
+	}
+	if capability.GetAccessMode() == nil {
+		return status.Error(codes.InvalidArgument, "Volume capability has no access mode")
+	}
+	return nil
+}
+
+// validateVolumePath checks that the path of a staged or published volume is set, a path
+// without volume is NotFound for the handler
+func validateVolumePath(path string) error {
+	if path == "" {
+		return status.Error(codes.InvalidArgument, "Volume path not provided")
+	}
+	return nil
+}
+
+// validatePath checks that the staging or target path of name is set, absolute and without parent directory
+// elements
+func validatePath(name, path string) error {
+	if path == "" {
+		return status.Errorf(codes.InvalidArgument, "%s not provided", name)
+	}
+	if !filepath.IsAbs(path) || strings.ContainsRune(path, 0) {
+		return status.Errorf(codes.InvalidArgument, "%s %q is not an absolute path", name, path)
+	}
+	for _, element := range strings.Split(path, "/") {
+		if element == ".." {
+			return status.Errorf(codes.InvalidArgument, "%s %q must not contain %q", name, path, "..")
+		}
+	}
+	return nil
+}
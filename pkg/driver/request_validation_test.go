@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateRequest(t *testing.T) {
+	capability := mountCapability("")
+	testCases := []struct {
+		name      string
+		req       interface{}
+		expectErr bool
+	}{
+		{
+			name: "valid create",
+			req:  &csi.CreateVolumeRequest{Name: "pvc-1", VolumeCapabilities: []*csi.VolumeCapability{capability}},
+		},
+		{
+			name:      "create without name",
+			req:       &csi.CreateVolumeRequest{VolumeCapabilities: []*csi.VolumeCapability{capability}},
+			expectErr: true,
+		},
+		{
+			name:      "create with capability without access type",
+			req:       &csi.CreateVolumeRequest{Name: "pvc-1", VolumeCapabilities: []*csi.VolumeCapability{{AccessMode: capability.AccessMode}}},
+			expectErr: true,
+		},
+		{
+			name: "valid publish with workspace handle",
+			req:  &csi.ControllerPublishVolumeRequest{VolumeId: "ws-1/vol-1", NodeId: "pvm-1", VolumeCapability: capability},
+		},
+		{
+			name: "valid legacy handle",
+			req:  &csi.DeleteVolumeRequest{VolumeId: "ibmpowervs://us-south/dal12/ws-1/VOL-1"},
+		},
+		{
+			name:      "volume ID with whitespace",
+			req:       &csi.DeleteVolumeRequest{VolumeId: "vol 1"},
+			expectErr: true,
+		},
+		{
+			name:      "unpublish without node ID",
+			req:       &csi.ControllerUnpublishVolumeRequest{VolumeId: "vol-1"},
+			expectErr: true,
+		},
+		{
+			name:      "publish with invalid node ID",
+			req:       &csi.ControllerPublishVolumeRequest{VolumeId: "vol-1", NodeId: "pvm-1;", VolumeCapability: capability},
+			expectErr: true,
+		},
+		{
+			name:      "expand without capacity range",
+			req:       &csi.ControllerExpandVolumeRequest{VolumeId: "vol-1"},
+			expectErr: true,
+		},
+		{
+			name: "valid stage",
+			req:  &csi.NodeStageVolumeRequest{VolumeId: "vol-1", StagingTargetPath: "/var/lib/kubelet/staging", VolumeCapability: capability},
+		},
+		{
+			name:      "stage to relative path",
+			req:       &csi.NodeStageVolumeRequest{VolumeId: "vol-1", StagingTargetPath: "staging", VolumeCapability: capability},
+			expectErr: true,
+		},
+		{
+			name:      "publish to path with parent directory",
+			req:       &csi.NodePublishVolumeRequest{VolumeId: "vol-1", StagingTargetPath: "/staging", TargetPath: "/pods/../etc", VolumeCapability: capability},
+			expectErr: true,
+		},
+		{
+			name:      "unpublish without target path",
+			req:       &csi.NodeUnpublishVolumeRequest{VolumeId: "vol-1"},
+			expectErr: true,
+		},
+		{
+			name: "stats of a relative path",
+			req:  &csi.NodeGetVolumeStatsRequest{VolumeId: "vol-1", VolumePath: "some/path"},
+		},
+		{
+			name: "identity request",
+			req:  &csi.ProbeRequest{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRequest(tc.req)
+			if !tc.expectErr {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("expected InvalidArgument, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateRequestsInterceptor(t *testing.T) {
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Controller/DeleteVolume"}
+	_, err := validateRequests(context.Background(), &csi.DeleteVolumeRequest{}, info, handler)
+	if status.Code(err) != codes.InvalidArgument || called {
+		t.Fatalf("expected InvalidArgument without calling the handler, got %v and called %v", err, called)
+	}
+	if _, err := validateRequests(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "vol-1"}, info, handler); err != nil || !called {
+		t.Fatalf("expected the handler to be called, got %v and called %v", err, called)
+	}
+}
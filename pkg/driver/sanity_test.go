@@ -1,23 +1,101 @@
 package driver
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"io/ioutil"
-	"math/rand"
 	"os"
 	"path/filepath"
-	"strings"
+	"reflect"
 	"testing"
-	"time"
 
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/kubernetes-csi/csi-test/pkg/sanity"
-	"k8s.io/utils/exec"
-	"k8s.io/utils/mount"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver/internal/fakemount"
 	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
 )
 
+var (
+	_ Mounter = &fakemount.Mounter{}
+	_ Mounter = &fakemount.Host{}
+)
+
+// sanityConfig is a configuration of the sanity test matrix, each one runs csi-sanity and
+// the idempotency checks against a driver of its own
+type sanityConfig struct {
+	name string
+	// setup customizes the csi-sanity config
+	setup func(config *sanity.Config)
+	// split runs the controller and the node services in drivers of their own, in
+	// ControllerMode and NodeMode, like the deployments of the driver
+	split bool
+	// capability is the capability of the volume of the idempotency checks, csi-sanity v2
+	// only requests mount volumes
+	capability *csi.VolumeCapability
+}
+
+var sanityConfigs = []sanityConfig{
+	{
+		name:       "filesystem",
+		capability: mountCapability(""),
+	},
+	{
+		name:       "block",
+		capability: blockCapability(),
+	},
+	{
+		name: "expansion",
+		setup: func(config *sanity.Config) {
+			config.TestVolumeSize = 10 * util.GiB
+			config.TestVolumeExpandSize = 20 * util.GiB
+		},
+		capability: mountCapability(FSTypeXfs),
+	},
+	{
+		name: "parameters",
+		setup: func(config *sanity.Config) {
+			// the retries of CreateVolume compare the parameters with the existing volume
+			config.TestVolumeParameters = map[string]string{VolumeTypeKey: cloud.VolumeTypeTier3}
+		},
+		capability: mountCapability(""),
+	},
+	{
+		name:       "split",
+		split:      true,
+		capability: mountCapability(""),
+	},
+	{
+		name:  "split expansion",
+		split: true,
+		setup: func(config *sanity.Config) {
+			config.TestVolumeSize = 10 * util.GiB
+			config.TestVolumeExpandSize = 20 * util.GiB
+		},
+		capability: mountCapability(FSTypeXfs),
+	},
+}
+
+func mountCapability(fsType string) *csi.VolumeCapability {
+	return &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{FsType: fsType}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+}
+
+func blockCapability() *csi.VolumeCapability {
+	return &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}
+}
+
 func TestSanity(t *testing.T) {
 	// Setup the full driver and its environment
 	dir, err := ioutil.TempDir("", "sanity-ebs-csi")
@@ -26,293 +104,263 @@ func TestSanity(t *testing.T) {
 	}
 	defer os.RemoveAll(dir)
 
-	targetPath := filepath.Join(dir, "mount")
-	stagingPath := filepath.Join(dir, "staging")
-	endpoint := "unix://" + filepath.Join(dir, "csi.sock")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("recover: %v", r)
+		}
+	}()
+	configs := make([]*sanity.Config, len(sanityConfigs))
+	for i, c := range sanityConfigs {
+		configs[i] = newSanityConfig(filepath.Join(dir, c.name), c.split)
+		if c.setup != nil {
+			c.setup(configs[i])
+		}
+		if c.split {
+			runSanityDriver(configs[i].ControllerAddress, ControllerMode)
+			runSanityDriver(configs[i].Address, NodeMode)
+		} else {
+			runSanityDriver(configs[i].Address, AllMode)
+		}
+		// csi-sanity runs its specs in the global ginkgo suite, which can only run once, so
+		// the specs of every config are registered before running them
+		sanity.GinkgoTest(configs[i])
+	}
+
+	// Now call the test suite
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "CSI Driver Test Suite")
+
+	for i, c := range sanityConfigs {
+		config, c := configs[i], c
+		t.Run(c.name+" idempotency", func(t *testing.T) {
+			testSanityIdempotency(t, config, c.capability)
+		})
+		if c.split {
+			t.Run(c.name+" services", func(t *testing.T) {
+				testSanityServices(t, config)
+			})
+		}
+	}
+}
 
+// newSanityConfig returns the config of a sanity run in dir, with the controller on an
+// endpoint of its own if split
+func newSanityConfig(dir string, split bool) *sanity.Config {
 	config := &sanity.Config{
-		TargetPath:       targetPath,
-		StagingPath:      stagingPath,
-		Address:          endpoint,
+		TargetPath:       filepath.Join(dir, "mount"),
+		StagingPath:      filepath.Join(dir, "staging"),
+		Address:          "unix://" + filepath.Join(dir, "csi.sock"),
 		CreateTargetDir:  createDir,
 		CreateStagingDir: createDir,
+		// set by sanity.Test, but not by sanity.GinkgoTest
+		IDGen: &sanity.DefaultIDGenerator{},
+	}
+	if split {
+		config.ControllerAddress = "unix://" + filepath.Join(dir, "controller.sock")
 	}
+	return config
+}
 
+// runSanityDriver runs a driver of mode with the fake cloud and mounter on endpoint, only the
+// services of mode are set up like by NewDriver
+func runSanityDriver(endpoint string, mode Mode) {
+	if err := os.MkdirAll(filepath.Dir(endpoint[len("unix://"):]), 0755); err != nil {
+		panic(fmt.Sprintf("%v", err))
+	}
 	driverOptions := &Options{
 		endpoint: endpoint,
-		mode:     AllMode,
+		mode:     mode,
 	}
 
-	drv := &Driver{
-		options: driverOptions,
-		controllerService: controllerService{
-			cloud:         newFakeCloudProvider(),
+	drv := &Driver{options: driverOptions}
+	if mode != NodeMode {
+		drv.controllerService = controllerService{
+			cloud:         fake.NewCloud(0),
 			driverOptions: driverOptions,
 			volumeLocks:   util.NewVolumeLocks(),
-		},
-		nodeService: nodeService{
-			mounter:       newFakeMounter(),
-			cloud:         newFakeCloudProvider(),
-			driverOptions: &Options{},
+			nodeQueues:    newNodeQueues(),
+		}
+	}
+	if mode != ControllerMode {
+		drv.nodeService = nodeService{
+			mounter:       fakemount.New(),
+			cloud:         fake.NewCloud(0),
+			driverOptions: &Options{volumeStats: true},
 			pvmInstanceId: "test1234",
 			volumeLocks:   util.NewVolumeLocks(),
-		},
-	}
-	defer func() {
-		if r := recover(); r != nil {
-			t.Fatalf("recover: %v", r)
 		}
-	}()
+	}
 	go func() {
 		if err := drv.Run(); err != nil {
 			panic(fmt.Sprintf("%v", err))
 		}
 	}()
-
-	// Now call the test suite
-	sanity.Test(t, config)
 }
 
-func createDir(targetPath string) (string, error) {
-	if err := os.MkdirAll(targetPath, 0300); err != nil {
-		if os.IsNotExist(err) {
-			return "", err
+// testSanityIdempotency runs the lifecycle of a volume with capability, calling every RPC
+// twice like a CO retrying after a lost response. The retries must succeed and, for the
+// controller, return the response of the first call.
+func testSanityIdempotency(t *testing.T, config *sanity.Config, capability *csi.VolumeCapability) {
+	ctx := context.Background()
+	conn := dialSanityDriver(t, config.Address)
+	controllerConn := conn
+	if config.ControllerAddress != "" {
+		controllerConn = dialSanityDriver(t, config.ControllerAddress)
+	}
+	controller := csi.NewControllerClient(controllerConn)
+	node := csi.NewNodeClient(conn)
+
+	twice := func(name string, call func() (interface{}, error)) {
+		t.Helper()
+		first, err := call()
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		retry, err := call()
+		if err != nil {
+			t.Fatalf("retry of %s failed: %v", name, err)
+		}
+		if !reflect.DeepEqual(first, retry) {
+			t.Fatalf("expected the retry of %s to return %v, got %v", name, first, retry)
 		}
 	}
-	return targetPath, nil
-}
 
-type fakeCloudProvider struct {
-	disks  map[string]*fakeDisk
-	pub    map[string]string
-	tokens map[string]int64
-}
-
-type fakeDisk struct {
-	*cloud.Disk
-}
-
-func newFakeCloudProvider() *fakeCloudProvider {
-	return &fakeCloudProvider{
-		disks:  make(map[string]*fakeDisk),
-		pub:    make(map[string]string),
-		tokens: make(map[string]int64),
+	size := config.TestVolumeSize
+	if size == 0 {
+		size = util.GiB
 	}
-}
-
-func (p *fakeCloudProvider) GetPVMInstanceByName(name string) (*cloud.PVMInstance, error) {
-
-	return &cloud.PVMInstance{
-		ID:      name + "-" + "id",
-		ImageID: name + "-" + "image",
-		Name:    name,
-	}, nil
-
-}
-
-func (p *fakeCloudProvider) GetPVMInstanceByID(instanceID string) (*cloud.PVMInstance, error) {
-
-	return &cloud.PVMInstance{
-		ID:      instanceID,
-		ImageID: strings.Split(instanceID, "-")[0] + "-" + "image",
-		Name:    strings.Split(instanceID, "-")[0],
-	}, nil
-}
-
-func (p *fakeCloudProvider) GetImageByID(imageID string) (*cloud.PVMImage, error) {
-
-	return &cloud.PVMImage{
-		ID:       imageID,
-		Name:     strings.Split(imageID, "-")[0] + "-" + "image",
-		DiskType: "tier3",
-	}, nil
-}
-
-func (c *fakeCloudProvider) CreateDisk(volumeName string, diskOptions *cloud.DiskOptions) (*cloud.Disk, error) {
-	r1 := rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	if existingDisk, ok := c.disks[volumeName]; ok {
-		//Already Created volume
-		if existingDisk.Disk.CapacityGiB != util.BytesToGiB(diskOptions.CapacityBytes) {
-			return nil, errors.New("disk Already exists")
-		} else {
-			return existingDisk.Disk, nil
+	var volumeID string
+	twice("CreateVolume", func() (interface{}, error) {
+		resp, err := controller.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:               "idempotency",
+			CapacityRange:      &csi.CapacityRange{RequiredBytes: size},
+			VolumeCapabilities: []*csi.VolumeCapability{capability},
+			Parameters:         config.TestVolumeParameters,
+		})
+		if err == nil {
+			volumeID = resp.Volume.VolumeId
 		}
-	}
-	d := &fakeDisk{
-		Disk: &cloud.Disk{
-			VolumeID:    fmt.Sprintf("vol-%d", r1.Uint64()),
-			CapacityGiB: util.BytesToGiB(diskOptions.CapacityBytes),
-			WWN:         "/fake-path",
-		},
-	}
-	c.disks[volumeName] = d
-	return d.Disk, nil
-}
+		return resp, err
+	})
 
-func (c *fakeCloudProvider) DeleteDisk(volumeID string) (bool, error) {
-	for volName, f := range c.disks {
-		if f.Disk.VolumeID == volumeID {
-			delete(c.disks, volName)
-		}
+	info, err := node.NodeGetInfo(ctx, &csi.NodeGetInfoRequest{})
+	if err != nil {
+		t.Fatalf("NodeGetInfo failed: %v", err)
 	}
-	return true, nil
-}
+	var publishContext map[string]string
+	twice("ControllerPublishVolume", func() (interface{}, error) {
+		resp, err := controller.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{
+			VolumeId:         volumeID,
+			NodeId:           info.NodeId,
+			VolumeCapability: capability,
+		})
+		if err == nil {
+			publishContext = resp.PublishContext
+		}
+		return resp, err
+	})
 
-func (c *fakeCloudProvider) AttachDisk(volumeID, nodeID string) error {
-	if _, ok := c.pub[volumeID]; ok {
-		return cloud.ErrAlreadyExists
+	stagingPath, err := createDir(filepath.Join(config.StagingPath, "idempotency"))
+	if err != nil {
+		t.Fatal(err)
 	}
-	c.pub[volumeID] = nodeID
-	return nil
-}
-
-func (c *fakeCloudProvider) DetachDisk(volumeID, nodeID string) error {
-	return nil
-}
-
-func (c *fakeCloudProvider) IsAttached(volumeID string, nodeID string) (attached bool, err error) {
-	return true, nil
-}
-
-func (c *fakeCloudProvider) WaitForVolumeState(volumeID, expectedState string) error {
-	return nil
-}
-
-func (c *fakeCloudProvider) GetDiskByName(name string) (*cloud.Disk, error) {
-	var disks []*fakeDisk
-	for _, d := range c.disks {
-		disks = append(disks, d)
+	targetPath := filepath.Join(config.TargetPath, "idempotency")
+	if _, err := createDir(config.TargetPath); err != nil {
+		t.Fatal(err)
 	}
-	if len(disks) > 1 {
-		return nil, cloud.ErrAlreadyExists
-	} else if len(disks) == 1 {
-
-		return disks[0].Disk, nil
+	twice("NodeStageVolume", func() (interface{}, error) {
+		return node.NodeStageVolume(ctx, &csi.NodeStageVolumeRequest{
+			VolumeId:          volumeID,
+			PublishContext:    publishContext,
+			StagingTargetPath: stagingPath,
+			VolumeCapability:  capability,
+		})
+	})
+	twice("NodePublishVolume", func() (interface{}, error) {
+		return node.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+			VolumeId:          volumeID,
+			PublishContext:    publishContext,
+			StagingTargetPath: stagingPath,
+			TargetPath:        targetPath,
+			VolumeCapability:  capability,
+		})
+	})
+
+	if config.TestVolumeExpandSize > 0 {
+		twice("ControllerExpandVolume", func() (interface{}, error) {
+			return controller.ControllerExpandVolume(ctx, &csi.ControllerExpandVolumeRequest{
+				VolumeId:         volumeID,
+				CapacityRange:    &csi.CapacityRange{RequiredBytes: config.TestVolumeExpandSize},
+				VolumeCapability: capability,
+			})
+		})
 	}
-	return nil, nil
-}
 
-func (c *fakeCloudProvider) GetDiskByID(volumeID string) (*cloud.Disk, error) {
-	for _, f := range c.disks {
-		if f.Disk.VolumeID == volumeID {
-			return f.Disk, nil
-		}
+	twice("NodeUnpublishVolume", func() (interface{}, error) {
+		return node.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{VolumeId: volumeID, TargetPath: targetPath})
+	})
+	twice("NodeUnstageVolume", func() (interface{}, error) {
+		return node.NodeUnstageVolume(ctx, &csi.NodeUnstageVolumeRequest{VolumeId: volumeID, StagingTargetPath: stagingPath})
+	})
+	twice("ControllerUnpublishVolume", func() (interface{}, error) {
+		return controller.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{VolumeId: volumeID, NodeId: info.NodeId})
+	})
+	twice("DeleteVolume", func() (interface{}, error) {
+		return controller.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: volumeID})
+	})
+}
+
+// testSanityServices checks that the drivers of a split config only serve the services of
+// their mode, and advertise the same plugin capabilities
+func testSanityServices(t *testing.T, config *sanity.Config) {
+	ctx := context.Background()
+	nodeConn := dialSanityDriver(t, config.Address)
+	controllerConn := dialSanityDriver(t, config.ControllerAddress)
+
+	if _, err := csi.NewControllerClient(nodeConn).ControllerGetCapabilities(ctx, &csi.ControllerGetCapabilitiesRequest{}); status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected the node driver not to serve the controller service, got %v", err)
 	}
-	return nil, cloud.ErrNotFound
-}
-
-func (c *fakeCloudProvider) IsExistInstance(nodeID string) bool {
-	return nodeID == "instanceID"
-}
-
-func (c *fakeCloudProvider) ResizeDisk(volumeID string, newSize int64) (int64, error) {
-	for volName, f := range c.disks {
-		if f.Disk.VolumeID == volumeID {
-			c.disks[volName].CapacityGiB = newSize
-			return newSize, nil
-		}
+	if _, err := csi.NewGroupControllerClient(nodeConn).GroupControllerGetCapabilities(ctx, &csi.GroupControllerGetCapabilitiesRequest{}); status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected the node driver not to serve the group controller service, got %v", err)
 	}
-	return 0, cloud.ErrNotFound
-}
-
-type fakeMounter struct {
-	mount.SafeFormatAndMount
-	exec.Interface
-}
-
-func newFakeMounter() *fakeMounter {
-	return &fakeMounter{
-		mount.SafeFormatAndMount{
-			Interface: mount.New(""),
-			Exec:      exec.New(),
-		},
-		exec.New(),
+	if _, err := csi.NewNodeClient(controllerConn).NodeGetCapabilities(ctx, &csi.NodeGetCapabilitiesRequest{}); status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected the controller driver not to serve the node service, got %v", err)
+	}
+	if _, err := csi.NewGroupControllerClient(controllerConn).GroupControllerGetCapabilities(ctx, &csi.GroupControllerGetCapabilitiesRequest{}); err != nil {
+		t.Fatalf("GroupControllerGetCapabilities of the controller driver failed: %v", err)
 	}
-}
-
-func (f *fakeMounter) IsCorruptedMnt(err error) bool {
-	return false
-}
-
-func (f *fakeMounter) Mount(source string, target string, fstype string, options []string) error {
-	return nil
-}
-
-func (f *fakeMounter) MountSensitive(source string, target string, fstype string, options []string, sensitiveOptions []string) error {
-	return nil
-}
-
-func (f *fakeMounter) MountSensitiveWithoutSystemd(source string, target string, fstype string, options []string, sensitiveOptions []string) error {
-	return nil
-}
-func (f *fakeMounter) RescanSCSIBus() error {
-	return nil
-}
-func (f *fakeMounter) Unmount(target string) error {
-	return nil
-}
-
-func (f *fakeMounter) List() ([]mount.MountPoint, error) {
-	return []mount.MountPoint{}, nil
-}
-
-func (f *fakeMounter) IsLikelyNotMountPoint(file string) (bool, error) {
-	return false, nil
-}
-
-func (f *fakeMounter) GetMountRefs(pathname string) ([]string, error) {
-	return []string{}, nil
-}
-
-func (f *fakeMounter) FormatAndMount(source string, target string, fstype string, options []string) error {
-	return nil
-}
-
-func (f *fakeMounter) GetDeviceNameFromMount(mountPath string) (string, int, error) {
-	return "", 0, nil
-}
 
-func (f *fakeMounter) MakeFile(pathname string) error {
-	file, err := os.OpenFile(pathname, os.O_CREATE, os.FileMode(0644))
-	if err != nil {
-		if !os.IsExist(err) {
-			return err
+	var capabilities []*csi.GetPluginCapabilitiesResponse
+	for _, conn := range []*grpc.ClientConn{nodeConn, controllerConn} {
+		resp, err := csi.NewIdentityClient(conn).GetPluginCapabilities(ctx, &csi.GetPluginCapabilitiesRequest{})
+		if err != nil {
+			t.Fatalf("GetPluginCapabilities failed: %v", err)
 		}
+		capabilities = append(capabilities, resp)
 	}
-	if err = file.Close(); err != nil {
-		return err
+	// the CO reads the capabilities of the plugin from any of its drivers
+	if !reflect.DeepEqual(capabilities[0], capabilities[1]) {
+		t.Fatalf("expected the node and controller drivers to advertise the same plugin capabilities, got %v and %v", capabilities[0], capabilities[1])
 	}
-	return nil
 }
 
-func (f *fakeMounter) MakeDir(pathname string) error {
-	err := os.MkdirAll(pathname, os.FileMode(0755))
+// dialSanityDriver connects to the driver at address, the connection is closed at the end
+// of the test
+func dialSanityDriver(t *testing.T, address string) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithBlock())
 	if err != nil {
-		if !os.IsExist(err) {
-			return err
-		}
+		t.Fatal(err)
 	}
-	return nil
+	t.Cleanup(func() { conn.Close() })
+	return conn
 }
 
-func (f *fakeMounter) ExistsPath(filename string) (bool, error) {
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return false, nil
-	} else if err != nil {
-		return false, err
+func createDir(targetPath string) (string, error) {
+	if err := os.MkdirAll(targetPath, 0300); err != nil {
+		if os.IsNotExist(err) {
+			return "", err
+		}
 	}
-	return true, nil
-}
-
-func (f *fakeMounter) NeedResize(source string, path string) (bool, error) {
-	return false, nil
-}
-
-func (f *fakeMounter) GetDeviceName(mountPath string) (string, int, error) {
-	return mount.GetDeviceNameFromMount(f, mountPath)
-}
-
-func (f *fakeMounter) GetDevicePath(wwn string) (devicePath string, err error) {
-	return wwn, nil
+	return targetPath, nil
 }
@@ -0,0 +1,180 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// keys of the secrets referenced by the csi.storage.k8s.io/*-secret-name StorageClass
+// parameters, the API key has the key of the secret of the driver
+const (
+	SecretAPIKeyKey          = cloud.APIKeyEnv
+	SecretCloudInstanceIDKey = "cloudInstanceID"
+)
+
+const (
+	// secretCloudIdleTimeout is how long the client of a secret is kept without requests, the
+	// client of a rotated API key is dropped after it
+	secretCloudIdleTimeout = time.Hour
+	// maxSecretClouds bounds the clients of secrets, the least recently used one is dropped
+	maxSecretClouds = 64
+)
+
+// secretClouds holds a client per IBM Cloud API key and workspace of the secrets of the
+// requests, which lets StorageClasses provision volumes in the PowerVS accounts of other tenants
+type secretClouds struct {
+	newCloud func(apikey, cloudInstanceID string) (cloud.Cloud, error)
+	now      func() time.Time
+
+	mu sync.Mutex
+	// clients are keyed by the hash of the API key, a rotated key gets a new client
+	clients map[string]*secretCloud
+}
+
+// secretCloud is the client of a secret and the time of its last request
+type secretCloud struct {
+	cloud    cloud.Cloud
+	lastUsed time.Time
+}
+
+func newSecretClouds(newCloud func(apikey, cloudInstanceID string) (cloud.Cloud, error)) *secretClouds {
+	return &secretClouds{newCloud: newCloud, now: time.Now, clients: map[string]*secretCloud{}}
+}
+
+// get returns the client of the workspace with apikey, creating it if needed. The client is
+// created without holding the lock, the requests of other secrets don't wait for it.
+func (s *secretClouds) get(apikey, cloudInstanceID string) (cloud.Cloud, error) {
+	key := secretCloudKey(apikey, cloudInstanceID)
+	if c := s.cached(key); c != nil {
+		return c, nil
+	}
+
+	c, err := s.newCloud(apikey, cloudInstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	if e, ok := s.clients[key]; ok {
+		// another request of the secret created its client first
+		e.lastUsed = now
+		s.mu.Unlock()
+		closeCloud(c)
+		return e.cloud, nil
+	}
+	s.clients[key] = &secretCloud{cloud: c, lastUsed: now}
+	stale := s.evictLocked(now)
+	s.mu.Unlock()
+
+	for _, c := range stale {
+		closeCloud(c)
+	}
+	return c, nil
+}
+
+// cached returns the client of key, nil if there is none
+func (s *secretClouds) cached(key string) cloud.Cloud {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.clients[key]
+	if !ok {
+		return nil
+	}
+	e.lastUsed = s.now()
+	return e.cloud
+}
+
+// evictLocked removes the clients idle for secretCloudIdleTimeout and the least recently used
+// ones beyond maxSecretClouds, and returns them to be closed. s.mu must be held.
+func (s *secretClouds) evictLocked(now time.Time) []cloud.Cloud {
+	var stale []cloud.Cloud
+	for key, e := range s.clients {
+		if now.Sub(e.lastUsed) > secretCloudIdleTimeout {
+			stale = append(stale, e.cloud)
+			delete(s.clients, key)
+		}
+	}
+	for len(s.clients) > maxSecretClouds {
+		var oldest string
+		for key, e := range s.clients {
+			if oldest == "" || e.lastUsed.Before(s.clients[oldest].lastUsed) {
+				oldest = key
+			}
+		}
+		stale = append(stale, s.clients[oldest].cloud)
+		delete(s.clients, oldest)
+	}
+	return stale
+}
+
+// closeCloud stops the background work of a client which is no longer used
+func closeCloud(c cloud.Cloud) {
+	closer, ok := c.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		klog.Warningf("Could not close client: %v", err)
+	}
+}
+
+// secretCloudKey returns the key of the client of apikey and cloudInstanceID, the API key is
+// hashed so that it isn't kept in memory beyond the client
+func secretCloudKey(apikey, cloudInstanceID string) string {
+	sum := sha256.Sum256([]byte(apikey))
+	return hex.EncodeToString(sum[:]) + "/" + cloudInstanceID
+}
+
+// secretCloud returns the client and the cloud instance ID of the workspace of the secrets of
+// a request, or a nil client when the secrets hold no API key and the credentials of the
+// driver are used
+func (d *controllerService) secretCloud(secrets map[string]string) (cloud.Cloud, string, error) {
+	apikey := secrets[SecretAPIKeyKey]
+	if apikey == "" {
+		return nil, "", nil
+	}
+	cloudInstanceID := secrets[SecretCloudInstanceIDKey]
+	if cloudInstanceID == "" {
+		return nil, "", status.Errorf(codes.InvalidArgument, "Secret with %s has no %s", SecretAPIKeyKey, SecretCloudInstanceIDKey)
+	}
+	c, err := d.secretClouds.get(apikey, cloudInstanceID)
+	if err != nil {
+		return nil, "", cloudError(err, "Could not create client for workspace %q of the secret: %v", cloudInstanceID, err)
+	}
+	return c, cloudInstanceID, nil
+}
+
+// secretVolumeCloud returns c and the PowerVS volume ID of a volume handle in the workspace
+// cloudInstanceID of the secrets, handles of other workspaces are rejected
+func secretVolumeCloud(c cloud.Cloud, cloudInstanceID, handle string) (cloud.Cloud, string, error) {
+	id, volumeID := cloud.SplitVolumeHandle(handle)
+	if id != "" && id != cloudInstanceID {
+		return nil, "", status.Errorf(codes.InvalidArgument, "Volume %q is not in workspace %q of the secret", handle, cloudInstanceID)
+	}
+	return c, volumeID, nil
+}
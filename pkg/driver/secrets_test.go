@@ -0,0 +1,309 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	mocks "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/mocks"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func TestCreateVolumeWithSecrets(t *testing.T) {
+	volCaps := []*csi.VolumeCapability{
+		{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		},
+	}
+	secrets := map[string]string{SecretAPIKeyKey: "tenant-key", SecretCloudInstanceIDKey: "tenant-ws"}
+
+	testCases := []struct {
+		name             string
+		secrets          map[string]string
+		parameters       map[string]string
+		expectedHandle   string
+		expectedTopology map[string]string
+		expectedCode     codes.Code
+	}{
+		{
+			name:           "no secrets use the driver credentials",
+			expectedHandle: "vol-1",
+		},
+		{
+			name:             "secrets create the volume in their workspace",
+			secrets:          secrets,
+			expectedHandle:   "tenant-ws/vol-1",
+			expectedTopology: map[string]string{WorkspaceTopologyKey: "tenant-ws"},
+		},
+		{
+			name:             "matching workspace parameter",
+			secrets:          secrets,
+			parameters:       map[string]string{WorkspaceKey: "tenant-ws"},
+			expectedHandle:   "tenant-ws/vol-1",
+			expectedTopology: map[string]string{WorkspaceTopologyKey: "tenant-ws"},
+		},
+		{
+			name:         "other workspace parameter",
+			secrets:      secrets,
+			parameters:   map[string]string{WorkspaceKey: "other-ws"},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "secrets without cloud instance ID",
+			secrets:      map[string]string{SecretAPIKeyKey: "tenant-key"},
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			driverCloud := mocks.NewMockCloud(mockCtl)
+			tenantCloud := mocks.NewMockCloud(mockCtl)
+			c := driverCloud
+			if tc.secrets != nil {
+				c = tenantCloud
+			}
+			if tc.expectedCode == codes.OK {
+				c.EXPECT().GetDiskByName(gomock.Any(), "pvc-1").Return(nil, cloud.ErrNotFound)
+				c.EXPECT().CreateDisk(gomock.Any(), "pvc-1", gomock.Any()).Return(&cloud.Disk{VolumeID: "vol-1", CapacityGiB: 1}, nil)
+			}
+
+			d := controllerService{
+				cloud: driverCloud,
+				secretClouds: newSecretClouds(func(apikey, cloudInstanceID string) (cloud.Cloud, error) {
+					if apikey != "tenant-key" || cloudInstanceID != "tenant-ws" {
+						t.Fatalf("unexpected client of workspace %q", cloudInstanceID)
+					}
+					return tenantCloud, nil
+				}),
+				driverOptions: &Options{},
+				volumeLocks:   util.NewVolumeLocks(),
+			}
+			resp, err := d.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+				Name:               "pvc-1",
+				VolumeCapabilities: volCaps,
+				Parameters:         tc.parameters,
+				Secrets:            tc.secrets,
+			})
+			if code := status.Code(err); code != tc.expectedCode {
+				t.Fatalf("expected code %v, got %v", tc.expectedCode, err)
+			}
+			if err != nil {
+				return
+			}
+			if handle := resp.GetVolume().GetVolumeId(); handle != tc.expectedHandle {
+				t.Fatalf("expected handle %q, got %q", tc.expectedHandle, handle)
+			}
+			var topology map[string]string
+			if len(resp.GetVolume().GetAccessibleTopology()) > 0 {
+				topology = resp.GetVolume().GetAccessibleTopology()[0].GetSegments()
+			}
+			if len(topology) != len(tc.expectedTopology) || topology[WorkspaceTopologyKey] != tc.expectedTopology[WorkspaceTopologyKey] {
+				t.Fatalf("expected topology %v, got %v", tc.expectedTopology, topology)
+			}
+		})
+	}
+}
+
+func TestCloudForVolumeWithSecrets(t *testing.T) {
+	secrets := map[string]string{SecretAPIKeyKey: "tenant-key", SecretCloudInstanceIDKey: "tenant-ws"}
+	testCases := []struct {
+		name             string
+		handle           string
+		secrets          map[string]string
+		expectedTenant   bool
+		expectedVolumeID string
+		expectedCode     codes.Code
+	}{
+		{
+			name:             "no secrets",
+			handle:           "vol-1",
+			expectedVolumeID: "vol-1",
+		},
+		{
+			name:             "handle of the workspace of the secrets",
+			handle:           "tenant-ws/vol-1",
+			secrets:          secrets,
+			expectedTenant:   true,
+			expectedVolumeID: "vol-1",
+		},
+		{
+			name:             "plain handle",
+			handle:           "vol-1",
+			secrets:          secrets,
+			expectedTenant:   true,
+			expectedVolumeID: "vol-1",
+		},
+		{
+			name:         "handle of another workspace",
+			handle:       "other-ws/vol-1",
+			secrets:      secrets,
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			driverCloud := mocks.NewMockCloud(mockCtl)
+			tenantCloud := mocks.NewMockCloud(mockCtl)
+			d := controllerService{
+				cloud: driverCloud,
+				secretClouds: newSecretClouds(func(apikey, cloudInstanceID string) (cloud.Cloud, error) {
+					return tenantCloud, nil
+				}),
+				driverOptions: &Options{},
+			}
+			c, volumeID, err := d.cloudForVolume(tc.handle, tc.secrets)
+			if code := status.Code(err); code != tc.expectedCode {
+				t.Fatalf("expected code %v, got %v", tc.expectedCode, err)
+			}
+			if err != nil {
+				return
+			}
+			if volumeID != tc.expectedVolumeID {
+				t.Fatalf("expected volume ID %q, got %q", tc.expectedVolumeID, volumeID)
+			}
+			if tenant := c == tenantCloud; tenant != tc.expectedTenant {
+				t.Fatalf("expected client of the secrets %v, got %v", tc.expectedTenant, tenant)
+			}
+		})
+	}
+}
+
+func TestSecretCloudsCache(t *testing.T) {
+	var created int
+	s := newSecretClouds(func(apikey, cloudInstanceID string) (cloud.Cloud, error) {
+		if apikey == "bad" {
+			return nil, errors.New("invalid API key")
+		}
+		created++
+		return &mocks.MockCloud{}, nil
+	})
+
+	first, err := s.get("key", "ws")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again, _ := s.get("key", "ws"); again != first {
+		t.Fatalf("expected the cached client")
+	}
+	if _, err := s.get("rotated-key", "ws"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.get("key", "other-ws"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created != 3 {
+		t.Fatalf("expected 3 clients, got %d", created)
+	}
+	if _, err := s.get("bad", "ws"); err == nil {
+		t.Fatalf("expected error of an invalid API key")
+	}
+	if _, err := s.get("bad", "ws"); err == nil {
+		t.Fatalf("expected failed clients not to be cached")
+	}
+}
+
+// closingCloud records that the client of a secret was closed
+type closingCloud struct {
+	*mocks.MockCloud
+	closed bool
+}
+
+func (c *closingCloud) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestSecretCloudsEviction(t *testing.T) {
+	now := time.Now()
+	s := newSecretClouds(func(apikey, cloudInstanceID string) (cloud.Cloud, error) {
+		return &closingCloud{}, nil
+	})
+	s.now = func() time.Time { return now }
+
+	old, _ := s.get("key", "ws")
+	now = now.Add(secretCloudIdleTimeout + time.Minute)
+	if _, err := s.get("rotated-key", "ws"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !old.(*closingCloud).closed || len(s.clients) != 1 {
+		t.Fatalf("expected the idle client of the old key to be closed, got %d clients", len(s.clients))
+	}
+
+	var first cloud.Cloud
+	for i := 0; i < maxSecretClouds+1; i++ {
+		now = now.Add(time.Second)
+		c, _ := s.get(fmt.Sprintf("key-%d", i), "ws")
+		if i == 0 {
+			first = c
+		}
+	}
+	if len(s.clients) != maxSecretClouds {
+		t.Fatalf("expected %d clients, got %d", maxSecretClouds, len(s.clients))
+	}
+	if !first.(*closingCloud).closed {
+		t.Fatalf("expected the least recently used client to be closed")
+	}
+}
+
+func TestSecretCloudsConcurrentCreation(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	calls := 0
+	s := newSecretClouds(func(apikey, cloudInstanceID string) (cloud.Cloud, error) {
+		calls++
+		if calls == 1 {
+			close(entered)
+			<-release
+		}
+		return &closingCloud{}, nil
+	})
+
+	slow := make(chan cloud.Cloud)
+	go func() {
+		c, _ := s.get("key", "ws")
+		slow <- c
+	}()
+	<-entered
+
+	// the first client is still being created, the lock isn't held meanwhile
+	fast, err := s.get("key", "ws")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(release)
+	if c := <-slow; c != fast {
+		t.Fatalf("expected the client stored first")
+	}
+	if len(s.clients) != 1 {
+		t.Fatalf("expected 1 client, got %d", len(s.clients))
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// SocketOptions set the permissions and ownership of the unix socket of the driver, so that
+// sidecars running as another user can connect to it. Zero values keep the defaults of
+// net.Listen: permissions from the umask and the user and group of the driver.
+type SocketOptions struct {
+	// Mode are the permission bits of the socket
+	Mode os.FileMode
+	// User and Group own the socket, names or numeric IDs
+	User  string
+	Group string
+}
+
+// Validate returns an error if o has other bits than permission bits
+func (o SocketOptions) Validate() error {
+	if o.Mode&^os.ModePerm != 0 {
+		return fmt.Errorf("socket mode %#o has other bits than permission bits", uint32(o.Mode))
+	}
+	return nil
+}
+
+// apply sets the permissions and ownership of o on the socket at path
+func (o SocketOptions) apply(path string) error {
+	if o.Mode != 0 {
+		if err := os.Chmod(path, o.Mode); err != nil {
+			return fmt.Errorf("could not set the mode of socket %q: %v", path, err)
+		}
+	}
+	if o.User == "" && o.Group == "" {
+		return nil
+	}
+	// -1 keeps the user or group unchanged
+	uid, gid := -1, -1
+	var err error
+	if o.User != "" {
+		if uid, err = lookupID(o.User, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		}); err != nil {
+			return fmt.Errorf("could not look up socket user %q: %v", o.User, err)
+		}
+	}
+	if o.Group != "" {
+		if gid, err = lookupID(o.Group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		}); err != nil {
+			return fmt.Errorf("could not look up socket group %q: %v", o.Group, err)
+		}
+	}
+	if err := os.Chown(path, uid, gid); err != nil {
+		return fmt.Errorf("could not set the owner of socket %q: %v", path, err)
+	}
+	return nil
+}
+
+// lookupID returns the numeric ID of nameOrID, looking names up with lookup
+func lookupID(nameOrID string, lookup func(name string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+	id, err := lookup(nameOrID)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestSocketOptions(t *testing.T) {
+	uid, gid := strconv.Itoa(os.Getuid()), strconv.Itoa(os.Getgid())
+	testCases := []struct {
+		name         string
+		options      SocketOptions
+		expectedMode os.FileMode
+		expectErr    bool
+	}{
+		{
+			name:         "defaults",
+			expectedMode: 0600,
+		},
+		{
+			name:         "mode and owner",
+			options:      SocketOptions{Mode: 0660, User: uid, Group: gid},
+			expectedMode: 0660,
+		},
+		{
+			name:      "not permission bits",
+			options:   SocketOptions{Mode: os.ModeSetuid | 0660},
+			expectErr: true,
+		},
+		{
+			name:      "unknown group",
+			options:   SocketOptions{Group: "powervs-csi-unknown-group"},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// a regular file stands in for the socket, chmod and chown behave the same
+			path := filepath.Join(t.TempDir(), "csi.sock")
+			if err := os.WriteFile(path, nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+			err := tc.options.Validate()
+			if err == nil {
+				err = tc.options.apply(path)
+			}
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != tc.expectedMode {
+				t.Fatalf("expected mode %v, got %v", tc.expectedMode, fi.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestLookupID(t *testing.T) {
+	lookup := func(name string) (string, error) {
+		if name == "csi" {
+			return "2000", nil
+		}
+		return "", errors.New("unknown")
+	}
+	testCases := []struct {
+		nameOrID   string
+		expectedID int
+		expectErr  bool
+	}{
+		{nameOrID: "1000", expectedID: 1000},
+		{nameOrID: "csi", expectedID: 2000},
+		{nameOrID: "other", expectErr: true},
+	}
+	for _, tc := range testCases {
+		id, err := lookupID(tc.nameOrID, lookup)
+		if tc.expectErr != (err != nil) {
+			t.Fatalf("%s: expected error %v, got: %v", tc.nameOrID, tc.expectErr, err)
+		}
+		if id != tc.expectedID {
+			t.Fatalf("%s: expected ID %d, got %d", tc.nameOrID, tc.expectedID, id)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// diskMismatch returns why disk doesn't have the attributes expected by volumeContext or the
+// StorageClass parameters, or "" if it has them. Statically provisioned PVs reference
+// existing volumes which may not have been created with the attributes their author set.
+func diskMismatch(disk *cloud.Disk, volumeContext, parameters map[string]string) string {
+	if s, ok := volumeContext[ShareableKey]; ok {
+		shareable, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Sprintf("invalid %s %q in the volume context", ShareableKey, s)
+		}
+		if shareable != disk.Shareable {
+			return fmt.Sprintf("volume %s has %s %v, expected %v", disk.VolumeID, ShareableKey, disk.Shareable, shareable)
+		}
+	}
+	for _, tier := range []string{volumeContext[TierKey], parameters[VolumeTypeKey]} {
+		// PowerVS reports the tier of some volumes in upper case
+		if tier != "" && !strings.EqualFold(tier, disk.DiskType) {
+			return fmt.Sprintf("volume %s has %s %q, expected %q", disk.VolumeID, TierKey, disk.DiskType, tier)
+		}
+	}
+	return ""
+}
+
+// StaticVolumeAttributes returns the volume attributes of a static PV of disk, which are
+// verified against the volume when it is attached
+func StaticVolumeAttributes(disk *cloud.Disk) map[string]string {
+	attributes := map[string]string{ShareableKey: strconv.FormatBool(disk.Shareable)}
+	if disk.DiskType != "" {
+		attributes[TierKey] = strings.ToLower(disk.DiskType)
+	}
+	return attributes
+}
+
+// diskVolumeContext returns volumeContext completed with the attributes of disk it doesn't set,
+// the volume context of static PVs only holds what their author set
+func diskVolumeContext(disk *cloud.Disk, volumeContext map[string]string) map[string]string {
+	completed := publishContext(disk)
+	if iops := cloud.ProvisionedIOPS(strings.ToLower(disk.DiskType), disk.CapacityGiB); iops > 0 {
+		completed[IOPSKey] = strconv.FormatInt(iops, 10)
+	}
+	for k, v := range volumeContext {
+		completed[k] = v
+	}
+	return completed
+}
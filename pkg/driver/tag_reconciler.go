@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// tagReconciler repairs the tags of the volumes of the PVs provisioned by this driver, so that
+// volumes created by older driver versions or retagged by hand carry the cluster ID and extra
+// tags the driver tags new volumes with
+type tagReconciler struct {
+	cloud      cloud.Cloud
+	workspaces *cloud.Workspaces
+	client     kubernetes.Interface
+	// required are the "key:value" tags every volume carries
+	required []string
+}
+
+func newTagReconciler(c cloud.Cloud, workspaces *cloud.Workspaces, client kubernetes.Interface, required []string) *tagReconciler {
+	return &tagReconciler{
+		cloud:      c,
+		workspaces: workspaces,
+		client:     client,
+		required:   required,
+	}
+}
+
+// requiredTags returns the tags the options make the controller tag every volume with
+func (o *Options) requiredTags() []string {
+	clusterTags := map[string]string{}
+	if o.kubernetesClusterID != "" {
+		clusterTags[ClusterIDTagKey] = o.kubernetesClusterID
+	}
+	return mergeTags(clusterTags, o.extraTags)
+}
+
+// run reconciles all PVs every interval until stopCh is closed
+func (r *tagReconciler) run(interval time.Duration, stopCh <-chan struct{}) {
+	klog.Infof("Starting tag reconciler with interval %v and tags %v", interval, r.required)
+	wait.Until(r.reconcileAll, interval, stopCh)
+}
+
+func (r *tagReconciler) reconcileAll() {
+	pvs, err := r.client.CoreV1().PersistentVolumes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		klog.Errorf("tag reconciler: failed to list persistent volumes: %v", err)
+		return
+	}
+	for i := range pvs.Items {
+		if err := r.reconcile(context.TODO(), &pvs.Items[i]); err != nil {
+			klog.Errorf("tag reconciler: failed to reconcile PV %s: %v", pvs.Items[i].Name, err)
+		}
+	}
+}
+
+func (r *tagReconciler) reconcile(ctx context.Context, pv *v1.PersistentVolume) error {
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != DriverName || len(r.required) == 0 {
+		return nil
+	}
+	c, volumeID, err := volumeCloud(r.cloud, r.workspaces, pv.Spec.CSI.VolumeHandle)
+	if err != nil {
+		return err
+	}
+	lister, canList := c.(cloud.TagLister)
+	updater, canUpdate := c.(cloud.TagUpdater)
+	if !canList || !canUpdate {
+		return fmt.Errorf("the cloud of volume %s doesn't support tags", volumeID)
+	}
+
+	tags, err := lister.GetDiskTags(ctx, volumeID)
+	if err != nil {
+		return fmt.Errorf("could not get tags of volume %s: %v", volumeID, err)
+	}
+	attach, detach := tagDrift(tags, r.required)
+	if len(attach) == 0 && len(detach) == 0 {
+		return nil
+	}
+	klog.Infof("tag reconciler: repairing tags of volume %s of PV %s, attaching %v and detaching %v", volumeID, pv.Name, attach, detach)
+	if err := updater.UpdateDiskTags(ctx, volumeID, attach, detach); err != nil {
+		return fmt.Errorf("could not update tags of volume %s: %v", volumeID, err)
+	}
+	return nil
+}
+
+// tagDrift returns the required tags missing from tags and the tags with the key of a required
+// tag but another value. Tags are compared case insensitively like IBM Cloud does.
+func tagDrift(tags, required []string) (attach, detach []string) {
+	existing := map[string]bool{}
+	for _, tag := range tags {
+		existing[strings.ToLower(tag)] = true
+	}
+	requiredKeys := map[string]string{}
+	for _, tag := range required {
+		requiredKeys[tagKey(tag)] = tag
+		if !existing[tag] {
+			attach = append(attach, tag)
+		}
+	}
+	for _, tag := range tags {
+		if want, ok := requiredKeys[tagKey(tag)]; ok && want != strings.ToLower(tag) {
+			detach = append(detach, tag)
+		}
+	}
+	return attach, detach
+}
+
+// tagKey returns the lower cased key of a "key:value" tag
+func tagKey(tag string) string {
+	return strings.ToLower(strings.SplitN(tag, ":", 2)[0])
+}
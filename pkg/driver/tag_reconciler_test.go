@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/fake"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func TestTagDrift(t *testing.T) {
+	testCases := []struct {
+		name           string
+		tags           []string
+		expectedAttach []string
+		expectedDetach []string
+	}{
+		{
+			name: "in sync",
+			tags: []string{"kubernetes-cluster-id:cluster", "team:a", "other"},
+		},
+		{
+			name:           "untagged",
+			expectedAttach: []string{"kubernetes-cluster-id:cluster", "team:a"},
+		},
+		{
+			name:           "other cluster ID",
+			tags:           []string{"Kubernetes-Cluster-ID:old", "team:a"},
+			expectedAttach: []string{"kubernetes-cluster-id:cluster"},
+			expectedDetach: []string{"Kubernetes-Cluster-ID:old"},
+		},
+		{
+			name: "upper cased",
+			tags: []string{"Kubernetes-Cluster-ID:Cluster", "TEAM:A"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			attach, detach := tagDrift(tc.tags, []string{"kubernetes-cluster-id:cluster", "team:a"})
+			if !reflect.DeepEqual(attach, tc.expectedAttach) || !reflect.DeepEqual(detach, tc.expectedDetach) {
+				t.Fatalf("expected to attach %v and detach %v, got %v and %v", tc.expectedAttach, tc.expectedDetach, attach, detach)
+			}
+		})
+	}
+}
+
+func TestTagReconcilerReconcileAll(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewCloud(0)
+	newPV := func(name, driver string, tags []string) *v1.PersistentVolume {
+		disk, err := c.CreateDisk(ctx, name, &cloud.DiskOptions{CapacityBytes: util.GiB, Tags: tags})
+		if err != nil {
+			t.Fatalf("could not create volume: %v", err)
+		}
+		return &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1.PersistentVolumeSpec{
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{Driver: driver, VolumeHandle: disk.VolumeID},
+				},
+			},
+		}
+	}
+	drifted := newPV("pv-1", DriverName, []string{"kubernetes-cluster-id:old", "team:a"})
+	tagged := newPV("pv-2", DriverName, []string{"kubernetes-cluster-id:cluster"})
+	other := newPV("pv-3", "other.csi.driver", nil)
+
+	options := &Options{kubernetesClusterID: "cluster"}
+	r := newTagReconciler(c, nil, kubefake.NewSimpleClientset(drifted, tagged, other), options.requiredTags())
+	r.reconcileAll()
+
+	expected := map[*v1.PersistentVolume][]string{
+		drifted: {"kubernetes-cluster-id:cluster", "team:a"},
+		tagged:  {"kubernetes-cluster-id:cluster"},
+		other:   nil,
+	}
+	for pv, expectedTags := range expected {
+		tags, err := c.GetDiskTags(ctx, pv.Spec.CSI.VolumeHandle)
+		if err != nil {
+			t.Fatalf("could not get tags: %v", err)
+		}
+		if !reflect.DeepEqual(tags, expectedTags) {
+			t.Fatalf("expected tags %v of PV %s, got %v", expectedTags, pv.Name, tags)
+		}
+	}
+	if calls := c.CallCount("UpdateDiskTags"); calls != 1 {
+		t.Fatalf("expected the tags of one volume to be updated, got %d updates", calls)
+	}
+}
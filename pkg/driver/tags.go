@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"sort"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver/params"
+)
+
+// maxVolumeTags is the number of tags IBM Cloud global tagging allows on a resource
+const maxVolumeTags = 1000
+
+// mergeTags merges tag maps in decreasing priority into IBM Cloud "key:value" tags. A key
+// already set by a higher priority source is not overridden, keys are compared case
+// insensitively since IBM Cloud stores tags lower cased. Within a source tags are ordered by
+// key, so the result only depends on the content of the sources. Tags exceeding
+// maxVolumeTags are dropped from the lowest priority sources.
+func mergeTags(sources ...map[string]string) []string {
+	var tags []string
+	seen := map[string]string{}
+	for _, source := range sources {
+		keys := make([]string, 0, len(source))
+		for k := range source {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			key := params.SanitizeTag(k)
+			if key == "" {
+				continue
+			}
+			tag := key
+			if v := params.SanitizeTag(source[k]); v != "" {
+				tag = key + ":" + v
+			}
+			if prev, ok := seen[key]; ok {
+				if prev != tag {
+					klog.V(4).Infof("tag %q conflicts with higher priority tag %q, ignoring it", tag, prev)
+				}
+				continue
+			}
+			if len(tag) > params.MaxTagLength {
+				klog.Warningf("tag %q is longer than %d characters, ignoring it", tag, params.MaxTagLength)
+				continue
+			}
+			if len(tags) == maxVolumeTags {
+				klog.Warningf("volumes can't have more than %d tags, ignoring tag %q", maxVolumeTags, tag)
+				continue
+			}
+			seen[key] = tag
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMergeTags(t *testing.T) {
+	testCases := []struct {
+		name     string
+		sources  []map[string]string
+		expected []string
+	}{
+		{
+			name:     "no tags",
+			sources:  []map[string]string{nil, {}},
+			expected: nil,
+		},
+		{
+			name: "ordered by priority then key",
+			sources: []map[string]string{
+				{"b": "1", "a": "1"},
+				{"d": "2", "c": "2"},
+			},
+			expected: []string{"a:1", "b:1", "c:2", "d:2"},
+		},
+		{
+			name: "higher priority source wins conflicts",
+			sources: []map[string]string{
+				{"team": "storage"},
+				{"Team": "platform", "env": "prod"},
+			},
+			expected: []string{"team:storage", "env:prod"},
+		},
+		{
+			name: "duplicates are dropped",
+			sources: []map[string]string{
+				{"env": "prod"},
+				{"ENV": "Prod"},
+			},
+			expected: []string{"env:prod"},
+		},
+		{
+			name: "invalid characters are replaced",
+			sources: []map[string]string{
+				{"kubernetes.io/owner": "a:b", "label": ""},
+			},
+			expected: []string{"kubernetes.io-owner:a-b", "label"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tags := mergeTags(tc.sources...)
+			if !reflect.DeepEqual(tags, tc.expected) {
+				t.Fatalf("expected tags %v, got %v", tc.expected, tags)
+			}
+		})
+	}
+}
+
+func TestMergeTagsLimit(t *testing.T) {
+	manyTags := map[string]string{}
+	for i := 0; i < maxVolumeTags+10; i++ {
+		manyTags[fmt.Sprintf("key-%04d", i)] = "value"
+	}
+
+	tags := mergeTags(map[string]string{"cluster": "c1"}, manyTags)
+	if len(tags) != maxVolumeTags {
+		t.Fatalf("expected %d tags, got %d", maxVolumeTags, len(tags))
+	}
+	if tags[0] != "cluster:c1" {
+		t.Fatalf("expected highest priority tag to be kept, got %q", tags[0])
+	}
+}
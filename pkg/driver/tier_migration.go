@@ -0,0 +1,248 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver/params"
+)
+
+const (
+	// TargetTierAnnotation is set by users on a PV, or on the PVC bound to it, to request
+	// that the backing PowerVS volume is moved to another storage tier.
+	TargetTierAnnotation = DriverName + "/target-tier"
+	// TierMigrationStatusAnnotation is maintained by the driver on the PV and reports the
+	// progress of the requested tier migration.
+	TierMigrationStatusAnnotation = DriverName + "/tier-migration-status"
+	// TierMigrationTargetAnnotation is maintained by the driver on the PV and holds the tier
+	// requested from PowerVS by the migration in progress.
+	TierMigrationTargetAnnotation = DriverName + "/tier-migration-target"
+	// TierMigrationStartedAnnotation is maintained by the driver on the PV and holds the time
+	// the migration in progress was requested, in RFC 3339.
+	TierMigrationStartedAnnotation = DriverName + "/tier-migration-started"
+)
+
+// DefaultTierMigrationTimeout is the time a tier migration may take before it fails
+const DefaultTierMigrationTimeout = 12 * time.Hour
+
+// tier migration phases reported in TierMigrationStatusAnnotation
+const (
+	TierMigrationInProgress = "InProgress"
+	TierMigrationCompleted  = "Completed"
+	TierMigrationFailed     = "Failed"
+)
+
+// tierMigrator reconciles TargetTierAnnotation on the PVs provisioned by this driver
+type tierMigrator struct {
+	cloud      cloud.Cloud
+	workspaces *cloud.Workspaces
+	client     kubernetes.Interface
+	// pvcs are the PVCs carrying the target tier of their PV, watched while run runs
+	pvcs     corelisters.PersistentVolumeClaimLister
+	recorder record.EventRecorder
+	// timeout is the time a migration may take before it fails, 0 waits forever
+	timeout time.Duration
+	now     func() time.Time
+}
+
+func newTierMigrator(c cloud.Cloud, workspaces *cloud.Workspaces, client kubernetes.Interface, timeout time.Duration) *tierMigrator {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	return &tierMigrator{
+		cloud:      c,
+		workspaces: workspaces,
+		client:     client,
+		recorder:   broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: DriverName}),
+		timeout:    timeout,
+		now:        time.Now,
+	}
+}
+
+// run reconciles all PVs every interval until stopCh is closed. The PVCs are watched for as
+// long, an informer stopped at the end of a leadership term can't be started again.
+func (m *tierMigrator) run(interval time.Duration, stopCh <-chan struct{}) {
+	klog.Infof("Starting tier migration reconciler with interval %v", interval)
+	factory := informers.NewSharedInformerFactory(m.client, 0)
+	pvcs := factory.Core().V1().PersistentVolumeClaims()
+	m.pvcs = pvcs.Lister()
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, pvcs.Informer().HasSynced) {
+		return
+	}
+	wait.Until(m.reconcileAll, interval, stopCh)
+}
+
+func (m *tierMigrator) reconcileAll() {
+	pvs, err := m.client.CoreV1().PersistentVolumes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		klog.Errorf("tier migration: failed to list persistent volumes: %v", err)
+		return
+	}
+	for i := range pvs.Items {
+		if err := m.reconcile(&pvs.Items[i]); err != nil {
+			klog.Errorf("tier migration: failed to reconcile PV %s: %v", pvs.Items[i].Name, err)
+		}
+	}
+}
+
+func (m *tierMigrator) reconcile(pv *v1.PersistentVolume) error {
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != DriverName {
+		return nil
+	}
+
+	target, obj := m.targetTier(pv)
+	if target == "" {
+		return nil
+	}
+	volumeID := pv.Spec.CSI.VolumeHandle
+	phase := pv.Annotations[TierMigrationStatusAnnotation]
+	// the tier requested from PowerVS, which the annotation may no longer ask for
+	requested := pv.Annotations[TierMigrationTargetAnnotation]
+
+	if !params.IsValidVolumeType(target) {
+		if phase != TierMigrationFailed {
+			m.recorder.Eventf(obj, v1.EventTypeWarning, "InvalidTargetTier", "Target tier %q is not supported, valid values: %v", target, cloud.ValidVolumeTypes)
+		}
+		return m.setStatus(pv, TierMigrationFailed, "", "")
+	}
+
+	c, diskID, err := volumeCloud(m.cloud, m.workspaces, volumeID)
+	if err != nil {
+		return err
+	}
+	disk, err := c.GetDiskByID(context.TODO(), diskID)
+	if err != nil {
+		return fmt.Errorf("could not get volume %q: %v", volumeID, err)
+	}
+
+	if disk.DiskType == target {
+		if phase != TierMigrationCompleted {
+			m.recorder.Eventf(obj, v1.EventTypeNormal, "TierMigrationCompleted", "Volume %s is now on tier %s", volumeID, target)
+		}
+		return m.setStatus(pv, TierMigrationCompleted, "", "")
+	}
+
+	switch {
+	case phase == TierMigrationInProgress && requested == "":
+		// started by a driver that didn't record the migration, its deadline starts now
+		return m.setStatus(pv, TierMigrationInProgress, target, m.now().UTC().Format(time.RFC3339))
+	case phase == TierMigrationInProgress && requested == target:
+		if started, err := time.Parse(time.RFC3339, pv.Annotations[TierMigrationStartedAnnotation]); err == nil && m.timeout > 0 && m.now().Sub(started) > m.timeout {
+			m.recorder.Eventf(obj, v1.EventTypeWarning, "TierMigrationFailed", "Volume %s is still on tier %s, the migration to %s didn't complete within %v", volumeID, disk.DiskType, target, m.timeout)
+			return m.setStatus(pv, TierMigrationFailed, requested, pv.Annotations[TierMigrationStartedAnnotation])
+		}
+		klog.V(5).Infof("tier migration: volume %s is still moving from %s to %s", volumeID, disk.DiskType, target)
+		return nil
+	case phase == TierMigrationInProgress:
+		m.recorder.Eventf(obj, v1.EventTypeNormal, "TierMigrationRetargeted", "Target tier of volume %s changed from %s to %s", volumeID, requested, target)
+	case phase == TierMigrationFailed && requested == target:
+		// timed out or rejected, it is requested again once the status annotation is removed
+		klog.V(5).Infof("tier migration: migration of volume %s to %s failed", volumeID, target)
+		return nil
+	}
+
+	if err := c.UpdateDiskTier(context.TODO(), diskID, target); err != nil {
+		m.recorder.Eventf(obj, v1.EventTypeWarning, "TierMigrationFailed", "Could not migrate volume %s from %s to %s: %v", volumeID, disk.DiskType, target, err)
+		// transient errors are retried at the next interval, a rejected request isn't sent again
+		// for the same target
+		var phaseErr error
+		if cloud.IsRetryableError(err) {
+			phaseErr = m.setStatus(pv, TierMigrationFailed, "", "")
+		} else {
+			phaseErr = m.setStatus(pv, TierMigrationFailed, target, m.now().UTC().Format(time.RFC3339))
+		}
+		if phaseErr != nil {
+			klog.Errorf("tier migration: %v", phaseErr)
+		}
+		return err
+	}
+	m.recorder.Eventf(obj, v1.EventTypeNormal, "TierMigrationStarted", "Migrating volume %s from %s to %s", volumeID, disk.DiskType, target)
+	return m.setStatus(pv, TierMigrationInProgress, target, m.now().UTC().Format(time.RFC3339))
+}
+
+// targetTier returns the requested tier and the object carrying the request, the
+// annotation on the PV takes precedence over the one on the bound PVC
+func (m *tierMigrator) targetTier(pv *v1.PersistentVolume) (string, runtime.Object) {
+	if tier, ok := pv.Annotations[TargetTierAnnotation]; ok {
+		return tier, pv
+	}
+	ref := pv.Spec.ClaimRef
+	if ref == nil {
+		return "", nil
+	}
+	pvc, err := m.pvcs.PersistentVolumeClaims(ref.Namespace).Get(ref.Name)
+	if err != nil {
+		klog.V(5).Infof("tier migration: could not get PVC %s/%s: %v", ref.Namespace, ref.Name, err)
+		return "", nil
+	}
+	if tier, ok := pvc.Annotations[TargetTierAnnotation]; ok {
+		return tier, pvc
+	}
+	return "", nil
+}
+
+// setStatus sets the phase of the migration of pv and the tier and time it was requested
+// at, empty values remove the annotations
+func (m *tierMigrator) setStatus(pv *v1.PersistentVolume, phase, requested, started string) error {
+	status := map[string]string{
+		TierMigrationStatusAnnotation:  phase,
+		TierMigrationTargetAnnotation:  requested,
+		TierMigrationStartedAnnotation: started,
+	}
+	annotations := map[string]interface{}{}
+	for key, value := range status {
+		if pv.Annotations[key] == value {
+			continue
+		}
+		if value == "" {
+			annotations[key] = nil
+		} else {
+			annotations[key] = value
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = m.client.CoreV1().PersistentVolumes().Patch(context.TODO(), pv.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
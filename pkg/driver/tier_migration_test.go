@@ -0,0 +1,287 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	mocks "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud/mocks"
+)
+
+func TestTierMigrationReconcile(t *testing.T) {
+	newPV := func(pvAnnotations map[string]string) *v1.PersistentVolume {
+		return &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "pv-1",
+				Annotations: pvAnnotations,
+			},
+			Spec: v1.PersistentVolumeSpec{
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{
+						Driver:       DriverName,
+						VolumeHandle: volumeID,
+					},
+				},
+				ClaimRef: &v1.ObjectReference{Namespace: "default", Name: "pvc-1"},
+			},
+		}
+	}
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pvc-1",
+			Namespace:   "default",
+			Annotations: map[string]string{TargetTierAnnotation: cloud.VolumeTypeTier1},
+		},
+	}
+
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	started := now.Add(-time.Hour).Format(time.RFC3339)
+
+	testCases := []struct {
+		name           string
+		pv             *v1.PersistentVolume
+		objs           []*v1.PersistentVolumeClaim
+		expectMock     func(mockCloud *mocks.MockCloud)
+		expectedPhase  string
+		expectedTarget string
+		expectErr      bool
+	}{
+		{
+			name: "start migration from PV annotation",
+			pv:   newPV(map[string]string{TargetTierAnnotation: cloud.VolumeTypeTier1}),
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeID)).Return(&cloud.Disk{VolumeID: volumeID, DiskType: cloud.VolumeTypeTier3}, nil)
+				mockCloud.EXPECT().UpdateDiskTier(gomock.Any(), gomock.Eq(volumeID), gomock.Eq(cloud.VolumeTypeTier1)).Return(nil)
+			},
+			expectedPhase:  TierMigrationInProgress,
+			expectedTarget: cloud.VolumeTypeTier1,
+		},
+		{
+			name: "start migration from PVC annotation",
+			pv:   newPV(nil),
+			objs: []*v1.PersistentVolumeClaim{pvc},
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeID)).Return(&cloud.Disk{VolumeID: volumeID, DiskType: cloud.VolumeTypeTier3}, nil)
+				mockCloud.EXPECT().UpdateDiskTier(gomock.Any(), gomock.Eq(volumeID), gomock.Eq(cloud.VolumeTypeTier1)).Return(nil)
+			},
+			expectedPhase:  TierMigrationInProgress,
+			expectedTarget: cloud.VolumeTypeTier1,
+		},
+		{
+			name: "migration in progress is not requested again",
+			pv: newPV(map[string]string{
+				TargetTierAnnotation:           cloud.VolumeTypeTier1,
+				TierMigrationStatusAnnotation:  TierMigrationInProgress,
+				TierMigrationTargetAnnotation:  cloud.VolumeTypeTier1,
+				TierMigrationStartedAnnotation: started,
+			}),
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeID)).Return(&cloud.Disk{VolumeID: volumeID, DiskType: cloud.VolumeTypeTier3}, nil)
+				mockCloud.EXPECT().UpdateDiskTier(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedPhase:  TierMigrationInProgress,
+			expectedTarget: cloud.VolumeTypeTier1,
+		},
+		{
+			name: "unrecorded migration in progress is recorded",
+			pv: newPV(map[string]string{
+				TargetTierAnnotation:          cloud.VolumeTypeTier1,
+				TierMigrationStatusAnnotation: TierMigrationInProgress,
+			}),
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeID)).Return(&cloud.Disk{VolumeID: volumeID, DiskType: cloud.VolumeTypeTier3}, nil)
+				mockCloud.EXPECT().UpdateDiskTier(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedPhase:  TierMigrationInProgress,
+			expectedTarget: cloud.VolumeTypeTier1,
+		},
+		{
+			name: "target changed during migration is requested",
+			pv: newPV(map[string]string{
+				TargetTierAnnotation:           cloud.VolumeTypeTier1,
+				TierMigrationStatusAnnotation:  TierMigrationInProgress,
+				TierMigrationTargetAnnotation:  cloud.VolumeTypeTier0,
+				TierMigrationStartedAnnotation: started,
+			}),
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeID)).Return(&cloud.Disk{VolumeID: volumeID, DiskType: cloud.VolumeTypeTier3}, nil)
+				mockCloud.EXPECT().UpdateDiskTier(gomock.Any(), gomock.Eq(volumeID), gomock.Eq(cloud.VolumeTypeTier1)).Return(nil)
+			},
+			expectedPhase:  TierMigrationInProgress,
+			expectedTarget: cloud.VolumeTypeTier1,
+		},
+		{
+			name: "fail migration exceeding the timeout",
+			pv: newPV(map[string]string{
+				TargetTierAnnotation:           cloud.VolumeTypeTier1,
+				TierMigrationStatusAnnotation:  TierMigrationInProgress,
+				TierMigrationTargetAnnotation:  cloud.VolumeTypeTier1,
+				TierMigrationStartedAnnotation: now.Add(-3 * time.Hour).Format(time.RFC3339),
+			}),
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeID)).Return(&cloud.Disk{VolumeID: volumeID, DiskType: cloud.VolumeTypeTier3}, nil)
+			},
+			expectedPhase:  TierMigrationFailed,
+			expectedTarget: cloud.VolumeTypeTier1,
+		},
+		{
+			name: "timed out migration is not requested again",
+			pv: newPV(map[string]string{
+				TargetTierAnnotation:           cloud.VolumeTypeTier1,
+				TierMigrationStatusAnnotation:  TierMigrationFailed,
+				TierMigrationTargetAnnotation:  cloud.VolumeTypeTier1,
+				TierMigrationStartedAnnotation: now.Add(-3 * time.Hour).Format(time.RFC3339),
+			}),
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeID)).Return(&cloud.Disk{VolumeID: volumeID, DiskType: cloud.VolumeTypeTier3}, nil)
+				mockCloud.EXPECT().UpdateDiskTier(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedPhase:  TierMigrationFailed,
+			expectedTarget: cloud.VolumeTypeTier1,
+		},
+		{
+			name: "migration completed",
+			pv: newPV(map[string]string{
+				TargetTierAnnotation:          cloud.VolumeTypeTier1,
+				TierMigrationStatusAnnotation: TierMigrationInProgress,
+			}),
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeID)).Return(&cloud.Disk{VolumeID: volumeID, DiskType: cloud.VolumeTypeTier1}, nil)
+			},
+			expectedPhase: TierMigrationCompleted,
+		},
+		{
+			name:          "fail invalid tier",
+			pv:            newPV(map[string]string{TargetTierAnnotation: "tier42"}),
+			expectMock:    func(mockCloud *mocks.MockCloud) {},
+			expectedPhase: TierMigrationFailed,
+		},
+		{
+			name: "fail cloud error",
+			pv:   newPV(map[string]string{TargetTierAnnotation: cloud.VolumeTypeTier1}),
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeID)).Return(&cloud.Disk{VolumeID: volumeID, DiskType: cloud.VolumeTypeTier3}, nil)
+				mockCloud.EXPECT().UpdateDiskTier(gomock.Any(), gomock.Eq(volumeID), gomock.Eq(cloud.VolumeTypeTier1)).Return(errors.New("tier change rejected"))
+			},
+			expectedPhase:  TierMigrationFailed,
+			expectedTarget: cloud.VolumeTypeTier1,
+			expectErr:      true,
+		},
+		{
+			name: "transient cloud error is retried",
+			pv:   newPV(map[string]string{TargetTierAnnotation: cloud.VolumeTypeTier1}),
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeID)).Return(&cloud.Disk{VolumeID: volumeID, DiskType: cloud.VolumeTypeTier3}, nil)
+				mockCloud.EXPECT().UpdateDiskTier(gomock.Any(), gomock.Eq(volumeID), gomock.Eq(cloud.VolumeTypeTier1)).Return(fmt.Errorf("update volume: %w", syscall.ECONNRESET))
+			},
+			expectedPhase: TierMigrationFailed,
+			expectErr:     true,
+		},
+		{
+			name: "rejected migration is not requested again",
+			pv: newPV(map[string]string{
+				TargetTierAnnotation:           cloud.VolumeTypeTier1,
+				TierMigrationStatusAnnotation:  TierMigrationFailed,
+				TierMigrationTargetAnnotation:  cloud.VolumeTypeTier1,
+				TierMigrationStartedAnnotation: started,
+			}),
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeID)).Return(&cloud.Disk{VolumeID: volumeID, DiskType: cloud.VolumeTypeTier3}, nil)
+				mockCloud.EXPECT().UpdateDiskTier(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedPhase:  TierMigrationFailed,
+			expectedTarget: cloud.VolumeTypeTier1,
+		},
+		{
+			name: "failed migration to another target is requested",
+			pv: newPV(map[string]string{
+				TargetTierAnnotation:           cloud.VolumeTypeTier0,
+				TierMigrationStatusAnnotation:  TierMigrationFailed,
+				TierMigrationTargetAnnotation:  cloud.VolumeTypeTier1,
+				TierMigrationStartedAnnotation: started,
+			}),
+			expectMock: func(mockCloud *mocks.MockCloud) {
+				mockCloud.EXPECT().GetDiskByID(gomock.Any(), gomock.Eq(volumeID)).Return(&cloud.Disk{VolumeID: volumeID, DiskType: cloud.VolumeTypeTier3}, nil)
+				mockCloud.EXPECT().UpdateDiskTier(gomock.Any(), gomock.Eq(volumeID), gomock.Eq(cloud.VolumeTypeTier0)).Return(nil)
+			},
+			expectedPhase:  TierMigrationInProgress,
+			expectedTarget: cloud.VolumeTypeTier0,
+		},
+		{
+			name:          "skip volumes without annotation",
+			pv:            newPV(nil),
+			expectMock:    func(mockCloud *mocks.MockCloud) {},
+			expectedPhase: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockCloud := mocks.NewMockCloud(mockCtl)
+			tc.expectMock(mockCloud)
+
+			client := fake.NewSimpleClientset(tc.pv)
+			pvcs := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			for _, obj := range tc.objs {
+				if err := pvcs.Add(obj); err != nil {
+					t.Fatalf("could not add PVC: %v", err)
+				}
+			}
+
+			m := &tierMigrator{
+				cloud:    mockCloud,
+				client:   client,
+				pvcs:     corelisters.NewPersistentVolumeClaimLister(pvcs),
+				recorder: record.NewFakeRecorder(10),
+				timeout:  2 * time.Hour,
+				now:      func() time.Time { return now },
+			}
+
+			err := m.reconcile(tc.pv)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.expectErr, err)
+			}
+
+			pv, err := client.CoreV1().PersistentVolumes().Get(context.TODO(), tc.pv.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("could not get PV: %v", err)
+			}
+			if phase := pv.Annotations[TierMigrationStatusAnnotation]; phase != tc.expectedPhase {
+				t.Fatalf("expected phase %q, got %q", tc.expectedPhase, phase)
+			}
+			if target := pv.Annotations[TierMigrationTargetAnnotation]; target != tc.expectedTarget {
+				t.Fatalf("expected requested tier %q, got %q", tc.expectedTarget, target)
+			}
+			if _, ok := pv.Annotations[TierMigrationStartedAnnotation]; ok != (tc.expectedTarget != "") {
+				t.Fatalf("expected start time %v, got annotations %v", tc.expectedTarget != "", pv.Annotations)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// serverTLSConfig returns the TLS configuration of the gRPC server, client certificates are
+// required and verified against the clientCAFile when it is set
+func serverTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	kp, err := newKeyPairReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: kp.getCertificate,
+	}
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read client CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in client CA file %s", clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// keyPairReloader serves the server certificate, reloading it when the certificate file has
+// been modified so that rotated certificates are used without a restart
+type keyPairReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newKeyPairReloader(certFile, keyFile string) (*keyPairReloader, error) {
+	kp := &keyPairReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := kp.getCertificate(nil); err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+func (kp *keyPairReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	info, err := os.Stat(kp.certFile)
+	if err != nil {
+		if kp.cert != nil {
+			return kp.cert, nil
+		}
+		return nil, fmt.Errorf("could not read TLS certificate: %v", err)
+	}
+	if kp.cert != nil && info.ModTime().Equal(kp.modTime) {
+		return kp.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(kp.certFile, kp.keyFile)
+	if err != nil {
+		if kp.cert != nil {
+			// the certificate and key files are not written at once, keep the previous pair
+			return kp.cert, nil
+		}
+		return nil, fmt.Errorf("could not load TLS key pair: %v", err)
+	}
+	kp.cert, kp.modTime = &cert, info.ModTime()
+	return kp.cert, nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, "powervs-csi-controller", ca).write(t, dir, "server")
+	client := newTestCert(t, "csi-provisioner", ca)
+	untrusted := newTestCert(t, "csi-provisioner", newTestCert(t, "other-ca", nil))
+
+	config, err := serverTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	handshake := func(clientCert *testCert) error {
+		serverConn, clientConn := net.Pipe()
+		defer serverConn.Close()
+		defer clientConn.Close()
+		clientConfig := &tls.Config{RootCAs: roots, ServerName: "powervs-csi-controller"}
+		if clientCert != nil {
+			clientConfig.Certificates = []tls.Certificate{{Certificate: [][]byte{clientCert.der}, PrivateKey: clientCert.key}}
+		}
+		errs := make(chan error, 1)
+		go func() {
+			errs <- tls.Server(serverConn, config).Handshake()
+			serverConn.Close()
+		}()
+		// the client is closed right away, otherwise the server blocks on the alert it sends
+		// after rejecting the client certificate
+		tls.Client(clientConn, clientConfig).Handshake()
+		clientConn.Close()
+		return <-errs
+	}
+
+	if err := handshake(client); err != nil {
+		t.Fatalf("expected handshake with a trusted client certificate to succeed, got: %v", err)
+	}
+	if err := handshake(nil); err == nil {
+		t.Fatalf("expected handshake without client certificate to fail")
+	}
+	if err := handshake(untrusted); err == nil {
+		t.Fatalf("expected handshake with an untrusted client certificate to fail")
+	}
+
+	if _, err := serverTLSConfig(certFile, keyFile, filepath.Join(dir, "missing.crt")); err == nil {
+		t.Fatalf("expected error for a missing client CA file")
+	}
+}
+
+func TestKeyPairReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	certFile, keyFile := newTestCert(t, "server-1", ca).write(t, dir, "server")
+	kp, err := newKeyPairReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newTestCert(t, "server-2", ca).write(t, dir, "server")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := kp.getCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.Subject.CommonName != "server-2" {
+		t.Fatalf("expected the rotated certificate, got %s", leaf.Subject.CommonName)
+	}
+}
@@ -18,12 +18,43 @@ package driver
 
 import (
 	"fmt"
+	"strings"
+
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/driver/params"
 )
 
 func ValidateDriverOptions(options *Options) error {
 	if err := validateMode(options.mode); err != nil {
 		return fmt.Errorf("Invalid mode: %v", err)
 	}
+	if err := validateCloudProvider(options.cloudProvider); err != nil {
+		return fmt.Errorf("Invalid cloud provider: %v", err)
+	}
+	if err := validateAuth(options); err != nil {
+		return fmt.Errorf("Invalid authentication: %v", err)
+	}
+	if err := validateEndpoints(options); err != nil {
+		return fmt.Errorf("Invalid endpoints: %v", err)
+	}
+	if err := validateTLS(options); err != nil {
+		return fmt.Errorf("Invalid TLS options: %v", err)
+	}
+	if err := options.grpcServer.Validate(); err != nil {
+		return fmt.Errorf("Invalid gRPC server options: %v", err)
+	}
+	if err := options.socket.Validate(); err != nil {
+		return fmt.Errorf("Invalid socket options: %v", err)
+	}
+	if err := validateTierAttachLimits(options.tierAttachLimits); err != nil {
+		return fmt.Errorf("Invalid tier attach limits: %v", err)
+	}
+	if err := validateFeatureGates(options.featureGates); err != nil {
+		return fmt.Errorf("Invalid feature gates: %v", err)
+	}
+	if err := validateTagReconciliation(options); err != nil {
+		return fmt.Errorf("Invalid tag reconciliation: %v", err)
+	}
 	return nil
 }
 
@@ -34,3 +65,71 @@ func validateMode(mode Mode) error {
 
 	return nil
 }
+
+func validateCloudProvider(provider string) error {
+	if provider != "" && provider != CloudProviderPowerVS && provider != CloudProviderFake {
+		return fmt.Errorf("Cloud provider is not supported (actual: %s, supported: %v)", provider, CloudProviders)
+	}
+	return nil
+}
+
+func validateAuth(options *Options) error {
+	switch options.authType {
+	case "", cloud.AuthTypeAPIKey:
+		return nil
+	case cloud.AuthTypeTrustedProfile:
+		if options.trustedProfileID == "" && options.trustedProfileName == "" {
+			return fmt.Errorf("trusted profile ID or name is required")
+		}
+		if options.apiKeyFile != "" {
+			return fmt.Errorf("API key file can't be used with a trusted profile")
+		}
+		return nil
+	default:
+		return fmt.Errorf("Authentication type is not supported (actual: %s, supported: %v)", options.authType, cloud.AuthTypes)
+	}
+}
+
+func validateEndpoints(options *Options) error {
+	for _, endpoint := range options.apiEndpoints {
+		if err := cloud.ValidateAPIEndpoint(endpoint); err != nil {
+			return fmt.Errorf("invalid PowerVS API endpoint: %v", err)
+		}
+	}
+	return options.serviceEndpoints.Validate()
+}
+
+func validateTLS(options *Options) error {
+	if options.tlsCertFile == "" && options.tlsKeyFile == "" {
+		if options.tlsClientCAFile != "" {
+			return fmt.Errorf("client CA file requires a TLS certificate and key")
+		}
+		return nil
+	}
+	if options.tlsCertFile == "" || options.tlsKeyFile == "" {
+		return fmt.Errorf("both TLS certificate and key files are required")
+	}
+	if !strings.HasPrefix(strings.ToLower(options.endpoint), "tcp://") {
+		return fmt.Errorf("TLS requires a tcp:// endpoint (actual: %s)", options.endpoint)
+	}
+	return nil
+}
+
+func validateTagReconciliation(options *Options) error {
+	if options.tagReconcileInterval > 0 && len(options.requiredTags()) == 0 {
+		return fmt.Errorf("the volumes have no tags to reconcile without a kubernetes cluster ID or extra tags")
+	}
+	return nil
+}
+
+func validateTierAttachLimits(limits map[string]int64) error {
+	for tier, limit := range limits {
+		if !params.IsValidVolumeType(tier) {
+			return fmt.Errorf("tier %q is not supported (supported: %v)", tier, cloud.ValidVolumeTypes)
+		}
+		if limit < 0 {
+			return fmt.Errorf("limit of tier %s must not be negative (actual: %d)", tier, limit)
+		}
+	}
+	return nil
+}
@@ -17,6 +17,9 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
+
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
 )
 
 func TestValidateMode(t *testing.T) {
@@ -57,6 +60,106 @@ func TestValidateMode(t *testing.T) {
 	}
 }
 
+func TestValidateCloudProvider(t *testing.T) {
+	for _, provider := range []string{"", CloudProviderPowerVS, CloudProviderFake} {
+		if err := validateCloudProvider(provider); err != nil {
+			t.Fatalf("expected cloud provider %q to be valid, got: %v", provider, err)
+		}
+	}
+	if err := validateCloudProvider("aws"); err == nil {
+		t.Fatalf("expected unknown cloud provider to be invalid")
+	}
+}
+
+func TestValidateAuth(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options *Options
+		expErr  error
+	}{
+		{
+			name:    "default api key",
+			options: &Options{},
+			expErr:  nil,
+		},
+		{
+			name:    "trusted profile",
+			options: &Options{authType: cloud.AuthTypeTrustedProfile, trustedProfileName: "powervs-csi"},
+			expErr:  nil,
+		},
+		{
+			name:    "trusted profile without profile",
+			options: &Options{authType: cloud.AuthTypeTrustedProfile},
+			expErr:  fmt.Errorf("trusted profile ID or name is required"),
+		},
+		{
+			name:    "trusted profile with api key file",
+			options: &Options{authType: cloud.AuthTypeTrustedProfile, trustedProfileID: "Profile-1", apiKeyFile: "/etc/apikey"},
+			expErr:  fmt.Errorf("API key file can't be used with a trusted profile"),
+		},
+		{
+			name:    "unknown",
+			options: &Options{authType: "password"},
+			expErr:  fmt.Errorf("Authentication type is not supported (actual: password, supported: %v)", cloud.AuthTypes),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateAuth(tc.options)
+			if !reflect.DeepEqual(err, tc.expErr) {
+				t.Fatalf("error not equal\ngot:\n%s\nexpected:\n%s", err, tc.expErr)
+			}
+		})
+	}
+}
+
+func TestValidateEndpoints(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options *Options
+		expErr  error
+	}{
+		{
+			name:    "defaults",
+			options: &Options{},
+			expErr:  nil,
+		},
+		{
+			name: "private endpoints",
+			options: &Options{
+				apiEndpoints:     []string{"private.us-south.power-iaas.cloud.ibm.com", "https://private.dal.power-iaas.cloud.ibm.com/"},
+				serviceEndpoints: cloud.ServiceEndpoints{IAM: "https://private.iam.cloud.ibm.com", GlobalTagging: "https://tags.private.global-search-tagging.cloud.ibm.com"},
+			},
+			expErr: nil,
+		},
+		{
+			name:    "plain http PowerVS API endpoint",
+			options: &Options{apiEndpoints: []string{"http://private.us-south.power-iaas.cloud.ibm.com"}},
+			expErr:  fmt.Errorf("invalid PowerVS API endpoint: %q must use https", "http://private.us-south.power-iaas.cloud.ibm.com"),
+		},
+		{
+			name:    "PowerVS API endpoint with path",
+			options: &Options{apiEndpoints: []string{"private.us-south.power-iaas.cloud.ibm.com/pcloud"}},
+			expErr:  fmt.Errorf("invalid PowerVS API endpoint: %q is not a host name", "private.us-south.power-iaas.cloud.ibm.com/pcloud"),
+		},
+		{
+			name:    "IAM endpoint without scheme",
+			options: &Options{serviceEndpoints: cloud.ServiceEndpoints{IAM: "private.iam.cloud.ibm.com"}},
+			expErr:  fmt.Errorf("invalid IAM endpoint: %q is not a https URL", "private.iam.cloud.ibm.com"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateEndpoints(tc.options)
+			if !reflect.DeepEqual(err, tc.expErr) {
+				t.Fatalf("error not equal\ngot:\n%s\nexpected:\n%s", err, tc.expErr)
+			}
+		})
+	}
+}
+
 func TestValidateDriverOptions(t *testing.T) {
 	testCases := []struct {
 		name            string
@@ -88,3 +191,113 @@ func TestValidateDriverOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateTLS(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options *Options
+		expErr  error
+	}{
+		{
+			name:    "plaintext",
+			options: &Options{endpoint: DefaultCSIEndpoint},
+			expErr:  nil,
+		},
+		{
+			name:    "mutual TLS",
+			options: &Options{endpoint: "tcp://0.0.0.0:10000", tlsCertFile: "tls.crt", tlsKeyFile: "tls.key", tlsClientCAFile: "ca.crt"},
+			expErr:  nil,
+		},
+		{
+			name:    "certificate without key",
+			options: &Options{endpoint: "tcp://0.0.0.0:10000", tlsCertFile: "tls.crt"},
+			expErr:  fmt.Errorf("both TLS certificate and key files are required"),
+		},
+		{
+			name:    "client CA without certificate",
+			options: &Options{endpoint: "tcp://0.0.0.0:10000", tlsClientCAFile: "ca.crt"},
+			expErr:  fmt.Errorf("client CA file requires a TLS certificate and key"),
+		},
+		{
+			name:    "unix endpoint",
+			options: &Options{endpoint: DefaultCSIEndpoint, tlsCertFile: "tls.crt", tlsKeyFile: "tls.key"},
+			expErr:  fmt.Errorf("TLS requires a tcp:// endpoint (actual: %s)", DefaultCSIEndpoint),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateTLS(tc.options)
+			if !reflect.DeepEqual(err, tc.expErr) {
+				t.Fatalf("error not equal\ngot:\n%s\nexpected:\n%s", err, tc.expErr)
+			}
+		})
+	}
+}
+
+func TestValidateTierAttachLimits(t *testing.T) {
+	testCases := []struct {
+		name   string
+		limits map[string]int64
+		expErr error
+	}{
+		{
+			name:   "valid",
+			limits: map[string]int64{cloud.VolumeTypeTier0: 16},
+		},
+		{
+			name:   "unknown tier",
+			limits: map[string]int64{"tier2": 16},
+			expErr: fmt.Errorf("tier %q is not supported (supported: %v)", "tier2", cloud.ValidVolumeTypes),
+		},
+		{
+			name:   "negative limit",
+			limits: map[string]int64{cloud.VolumeTypeTier0: -1},
+			expErr: fmt.Errorf("limit of tier tier0 must not be negative (actual: -1)"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateTierAttachLimits(tc.limits)
+			if !reflect.DeepEqual(err, tc.expErr) {
+				t.Fatalf("error not equal\ngot:\n%s\nexpected:\n%s", err, tc.expErr)
+			}
+		})
+	}
+}
+
+func TestValidateTagReconciliation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options *Options
+		expErr  error
+	}{
+		{
+			name:    "disabled",
+			options: &Options{},
+		},
+		{
+			name:    "cluster ID",
+			options: &Options{tagReconcileInterval: time.Hour, kubernetesClusterID: "cluster"},
+		},
+		{
+			name:    "extra tags",
+			options: &Options{tagReconcileInterval: time.Hour, extraTags: map[string]string{"team": "a"}},
+		},
+		{
+			name:    "without tags",
+			options: &Options{tagReconcileInterval: time.Hour},
+			expErr:  fmt.Errorf("the volumes have no tags to reconcile without a kubernetes cluster ID or extra tags"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateTagReconciliation(tc.options)
+			if !reflect.DeepEqual(err, tc.expErr) {
+				t.Fatalf("error not equal\ngot:\n%s\nexpected:\n%s", err, tc.expErr)
+			}
+		})
+	}
+}
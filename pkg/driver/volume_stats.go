@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"sync"
+	"time"
+
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+// statsCache caches the stats of volume paths for ttl, kubelet asks for the stats of every
+// volume of a node periodically. A ttl of 0 disables the cache.
+type statsCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]statsEntry
+}
+
+type statsEntry struct {
+	stats   *util.VolumeStats
+	expires time.Time
+}
+
+func newStatsCache(ttl time.Duration) *statsCache {
+	return &statsCache{ttl: ttl, entries: map[string]statsEntry{}}
+}
+
+// get returns the cached stats of path, or the stats returned by fn which are then cached.
+// Failures aren't cached.
+func (c *statsCache) get(path string, fn func(path string) (*util.VolumeStats, error)) (*util.VolumeStats, error) {
+	if c == nil || c.ttl <= 0 {
+		return fn(path)
+	}
+	now := time.Now()
+	c.mu.Lock()
+	if e, ok := c.entries[path]; ok && now.Before(e.expires) {
+		c.mu.Unlock()
+		return e.stats, nil
+	}
+	c.mu.Unlock()
+
+	// fn runs without the lock, a slow statfs doesn't block the stats of other volumes
+	stats, err := fn(path)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// drop the expired entries of volumes that are gone
+	for p, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, p)
+		}
+	}
+	c.entries[path] = statsEntry{stats: stats, expires: now.Add(c.ttl)}
+	return stats, nil
+}
+
+// invalidate drops the stats of path, e.g. after the volume was resized or unpublished
+func (c *statsCache) invalidate(path string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, path)
+}
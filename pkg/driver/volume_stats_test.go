@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+func TestStatsCache(t *testing.T) {
+	calls := 0
+	fn := func(path string) (*util.VolumeStats, error) {
+		calls++
+		return &util.VolumeStats{TotalBytes: int64(calls)}, nil
+	}
+	failing := func(path string) (*util.VolumeStats, error) {
+		calls++
+		return nil, errors.New("statfs failed")
+	}
+
+	testCases := []struct {
+		name          string
+		cache         *statsCache
+		run           func(c *statsCache)
+		expectedCalls int
+	}{
+		{
+			name:  "cached",
+			cache: newStatsCache(time.Minute),
+			run: func(c *statsCache) {
+				_, _ = c.get("/a", fn)
+				_, _ = c.get("/a", fn)
+				_, _ = c.get("/b", fn)
+			},
+			expectedCalls: 2,
+		},
+		{
+			name:  "expired",
+			cache: newStatsCache(time.Millisecond),
+			run: func(c *statsCache) {
+				_, _ = c.get("/a", fn)
+				time.Sleep(5 * time.Millisecond)
+				_, _ = c.get("/a", fn)
+			},
+			expectedCalls: 2,
+		},
+		{
+			name:  "invalidated",
+			cache: newStatsCache(time.Minute),
+			run: func(c *statsCache) {
+				_, _ = c.get("/a", fn)
+				c.invalidate("/a")
+				_, _ = c.get("/a", fn)
+			},
+			expectedCalls: 2,
+		},
+		{
+			name:  "failures aren't cached",
+			cache: newStatsCache(time.Minute),
+			run: func(c *statsCache) {
+				_, _ = c.get("/a", failing)
+				_, _ = c.get("/a", failing)
+			},
+			expectedCalls: 2,
+		},
+		{
+			name:  "disabled",
+			cache: newStatsCache(0),
+			run: func(c *statsCache) {
+				_, _ = c.get("/a", fn)
+				_, _ = c.get("/a", fn)
+			},
+			expectedCalls: 2,
+		},
+		{
+			name: "nil",
+			run: func(c *statsCache) {
+				c.invalidate("/a")
+				_, _ = c.get("/a", fn)
+			},
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls = 0
+			tc.run(tc.cache)
+			if calls != tc.expectedCalls {
+				t.Fatalf("expected %d stats calls, got %d", tc.expectedCalls, calls)
+			}
+		})
+	}
+}
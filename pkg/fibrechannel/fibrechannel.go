@@ -35,6 +35,7 @@ type ioHandler interface {
 	Lstat(name string) (os.FileInfo, error)
 	EvalSymlinks(path string) (string, error)
 	WriteFile(filename string, data []byte, perm os.FileMode) error
+	ReadFile(filename string) ([]byte, error)
 }
 
 //Connector provides a struct to hold all of the needed parameters to make our Fibre Channel connection
@@ -69,6 +70,51 @@ func (handler *OSioHandler) WriteFile(filename string, data []byte, perm os.File
 	return ioutil.WriteFile(filename, data, perm)
 }
 
+//ReadFile calls ReadFile from ioutil package
+func (handler *OSioHandler) ReadFile(filename string) ([]byte, error) {
+	return ioutil.ReadFile(filename)
+}
+
+// MultipathDevice is a multipath device mapper device of the node
+type MultipathDevice struct {
+	// Path is the device node, like /dev/dm-0
+	Path string
+	// Name is the name of the map, the device is also found at /dev/mapper/<name>
+	Name string
+}
+
+// ListMultipathDevices returns the multipath devices of the node by the WWID of their volume,
+// other device mapper devices are skipped
+func ListMultipathDevices(io ioHandler) (map[string]MultipathDevice, error) {
+	if io == nil {
+		io = &OSioHandler{}
+	}
+	sysPath := "/sys/block/"
+	dirs, err := io.ReadDir(sysPath)
+	if err != nil {
+		return nil, err
+	}
+	devices := make(map[string]MultipathDevice)
+	for _, f := range dirs {
+		dm := f.Name()
+		if !strings.HasPrefix(dm, "dm-") {
+			continue
+		}
+		// the uuid of a multipath device is mpath-<wwid>
+		uuid, err := io.ReadFile(sysPath + dm + "/dm/uuid")
+		if err != nil || !strings.HasPrefix(string(uuid), "mpath-") {
+			continue
+		}
+		name, err := io.ReadFile(sysPath + dm + "/dm/name")
+		if err != nil {
+			continue
+		}
+		wwid := strings.TrimSpace(strings.TrimPrefix(string(uuid), "mpath-"))
+		devices[wwid] = MultipathDevice{Path: "/dev/" + dm, Name: strings.TrimSpace(string(name))}
+	}
+	return devices, nil
+}
+
 // FindMultipathDeviceForDevice given a device name like /dev/sdx, find the devicemapper parent
 func FindMultipathDeviceForDevice(device string, io ioHandler) (string, error) {
 	disk, err := findDeviceForPath(device, io)
@@ -119,6 +165,26 @@ func scsiHostRescan(io ioHandler) {
 	}
 }
 
+// ScsiHostDrivers returns the drivers of the scsi hosts of the node, like ibmvfc for NPIV
+// adapters and ibmvscsi for vSCSI adapters
+func ScsiHostDrivers(io ioHandler) ([]string, error) {
+	if io == nil {
+		io = &OSioHandler{}
+	}
+	scsiPath := "/sys/class/scsi_host/"
+	dirs, err := io.ReadDir(scsiPath)
+	if err != nil {
+		return nil, err
+	}
+	var drivers []string
+	for _, f := range dirs {
+		if name, err := io.ReadFile(scsiPath + f.Name() + "/proc_name"); err == nil {
+			drivers = append(drivers, strings.TrimSpace(string(name)))
+		}
+	}
+	return drivers, nil
+}
+
 //func fcHostIssueLip(io ioHandler) {
 //	fcPath := "/sys/class/fc_host/"
 //	if dirs, err := io.ReadDir(fcPath); err == nil {
@@ -294,6 +360,49 @@ func Detach(devicePath string, io ioHandler) error {
 	return nil
 }
 
+// Rescan makes the scsi devices of devicePath reread their size after the volume was grown,
+// a multipath device on top of them is resized as well
+func Rescan(devicePath string, io ioHandler) error {
+	if io == nil {
+		io = &OSioHandler{}
+	}
+
+	dstPath, err := io.EvalSymlinks(devicePath)
+	if err != nil {
+		return err
+	}
+
+	multipath := strings.HasPrefix(dstPath, "/dev/dm-")
+	devices := []string{dstPath}
+	if multipath {
+		devices = FindSlaveDevicesOnMultipath(dstPath, io)
+	}
+
+	glog.Infof("fc: rescan devicePath: %v, dstPath: %v, devices: %v", devicePath, dstPath, devices)
+	for _, device := range devices {
+		fileName := "/sys/block/" + path.Base(device) + "/device/rescan"
+		if err := io.WriteFile(fileName, []byte("1"), 0666); err != nil {
+			return fmt.Errorf("fc: could not rescan device %s: %v", device, err)
+		}
+	}
+
+	if multipath {
+		return resizeMultipathDevice(dstPath)
+	}
+	return nil
+}
+
+// resizeMultipathDevice makes multipathd pick up the new size of the paths of dm
+func resizeMultipathDevice(dm string) error {
+	cmd := exec.Command("multipathd", "resize", "map", path.Base(dm))
+	stdoutStderr, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to resize multipath device: %s err: %v, output: %s", dm, err, stdoutStderr)
+	}
+	glog.Infof("output of multipath device resize command: %s", stdoutStderr)
+	return nil
+}
+
 //FindSlaveDevicesOnMultipath returns all slaves on the multipath device given the device path
 func FindSlaveDevicesOnMultipath(dm string, io ioHandler) []string {
 	var devices []string
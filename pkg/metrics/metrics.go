@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics holds the Prometheus metrics exported by the driver
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "powervs_csi"
+
+var (
+	// Registry is the registry all driver metrics are registered in
+	Registry = prometheus.NewRegistry()
+
+	// CloudCircuitBreakerOpen is 1 while the PowerVS API circuit breaker of a workspace is open
+	CloudCircuitBreakerOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cloud_circuit_breaker_open",
+		Help:      "Whether the PowerVS API circuit breaker is open (1) and calls fail fast, or closed (0), by cloud instance ID of the workspace.",
+	}, []string{"cloud_instance_id"})
+
+	// ControllerLeader is 1 while the controller replica runs the leader loops
+	ControllerLeader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "controller_leader",
+		Help:      "Whether the controller replica is the leader (1) running the background loops, or standing by (0).",
+	})
+
+	// Operations counts the CSI RPCs by method and gRPC status code
+	Operations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operations_total",
+		Help:      "Number of CSI RPCs handled, by method and gRPC status code.",
+	}, []string{"method", "grpc_code"})
+
+	// OperationDuration observes the latency of the CSI RPCs by method
+	OperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of the CSI RPCs, by method.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"method"})
+
+	// VolumeOperationDuration observes the duration of provisioning, attaching and detaching
+	// volumes, split into the time spent in PowerVS API calls and waiting for the volume state
+	VolumeOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "volume_operation_duration_seconds",
+		Help:      "Duration of the volume operations provision, attach and detach, by operation, phase (\"total\", \"api\" for the PowerVS API calls and \"volume-wait\" for waiting for the volume state) and gRPC status code.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"operation", "phase", "grpc_code"})
+
+	// OperationsInFlight is the number of CSI RPCs being handled by method
+	OperationsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "operations_in_flight",
+		Help:      "Number of CSI RPCs currently being handled, by method.",
+	}, []string{"method"})
+
+	// SlowOperations counts the CSI RPCs and cloud calls exceeding the slow operation threshold
+	SlowOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slow_operations_total",
+		Help:      "Number of CSI RPCs (kind \"rpc\") and PowerVS cloud calls (kind \"cloud\") which took longer than the slow operation threshold, by operation.",
+	}, []string{"kind", "operation"})
+
+	// CloudAPIRequests counts the attempts of PowerVS API calls by operation and HTTP status
+	CloudAPIRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cloud_api_requests_total",
+		Help:      "Number of PowerVS API requests including retries, by operation and HTTP status code. The status is \"error\" for requests failing without a response.",
+	}, []string{"operation", "status"})
+
+	// CloudAPIRequestDuration observes the latency of the attempts of PowerVS API calls
+	CloudAPIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cloud_api_request_duration_seconds",
+		Help:      "Latency of the PowerVS API requests, by operation.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"operation"})
+
+	// NodeQueueWaiting is the number of attach and detach operations waiting for another
+	// operation on their node
+	NodeQueueWaiting = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "node_queue_waiting",
+		Help:      "Number of attach and detach operations waiting for the operation in progress on their node, by operation.",
+	}, []string{"operation"})
+
+	// NodeQueueWaitDuration observes the time attach and detach operations waited for their node
+	NodeQueueWaitDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "node_queue_wait_duration_seconds",
+		Help:      "Time attach and detach operations waited for the operations in progress on their node, by operation.",
+		Buckets:   []float64{0.01, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		CloudCircuitBreakerOpen,
+		ControllerLeader,
+		Operations,
+		OperationDuration,
+		VolumeOperationDuration,
+		OperationsInFlight,
+		SlowOperations,
+		CloudAPIRequests,
+		CloudAPIRequestDuration,
+		NodeQueueWaiting,
+		NodeQueueWaitDuration,
+	)
+}
+
+// Handler returns the HTTP handler serving the metrics in Registry
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveCloudAPIRequest records a PowerVS API request of operation which took the time since
+// start and ended with the HTTP status, or "ok" or "error" if there was no response code
+func ObserveCloudAPIRequest(operation, status string, start time.Time) {
+	CloudAPIRequests.WithLabelValues(operation, status).Inc()
+	CloudAPIRequestDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// phases of a request broken down by the slow operation watchdog
+const (
+	// PhaseAPI is spent in PowerVS API calls, including their retries
+	PhaseAPI = "api"
+	// PhaseVolumeWait is spent waiting for a volume to reach a state
+	PhaseVolumeWait = "volume-wait"
+	// PhaseDeviceWait is spent waiting for the device of a volume to appear on the node
+	PhaseDeviceWait = "device-wait"
+	// PhaseMount is spent formatting, mounting and unmounting
+	PhaseMount = "mount"
+	// PhaseDetachDevice is spent removing the device of a volume from the node
+	PhaseDetachDevice = "detach-device"
+)
+
+type phasesKey struct{}
+
+// Phases accumulates the time a request spends in each phase, it's safe for concurrent use
+type Phases struct {
+	// parent are the Phases of ctx when these were created, they record the phases too
+	parent *Phases
+
+	mu        sync.Mutex
+	durations map[string]time.Duration
+}
+
+// WithPhases returns a copy of ctx recording the phases of a request in the returned Phases,
+// and in the Phases ctx already records them in
+func WithPhases(ctx context.Context) (context.Context, *Phases) {
+	parent, _ := ctx.Value(phasesKey{}).(*Phases)
+	p := &Phases{parent: parent, durations: map[string]time.Duration{}}
+	return context.WithValue(ctx, phasesKey{}, p), p
+}
+
+// StartPhase starts a phase of the request of ctx, the returned function ends it. Phases
+// are only recorded if ctx was returned by WithPhases.
+func StartPhase(ctx context.Context, phase string) func() {
+	p, _ := ctx.Value(phasesKey{}).(*Phases)
+	if p == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		d := time.Since(start)
+		for ; p != nil; p = p.parent {
+			p.add(phase, d)
+		}
+	}
+}
+
+func (p *Phases) add(phase string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.durations[phase] += d
+}
+
+// Durations returns the time spent in each phase so far
+func (p *Phases) Durations() map[string]time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	durations := make(map[string]time.Duration, len(p.durations))
+	for phase, d := range p.durations {
+		durations[phase] = d
+	}
+	return durations
+}
+
+// String returns the phases sorted by name, e.g. "api=1.2s volume-wait=30s"
+func (p *Phases) String() string {
+	durations := p.Durations()
+	phases := make([]string, 0, len(durations))
+	for phase := range durations {
+		phases = append(phases, phase)
+	}
+	sort.Strings(phases)
+	for i, phase := range phases {
+		phases[i] = fmt.Sprintf("%s=%v", phase, durations[phase].Round(time.Millisecond))
+	}
+	return strings.Join(phases, " ")
+}
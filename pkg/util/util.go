@@ -20,13 +20,17 @@ package util
 
 import (
 	"fmt"
+	"math"
+	"net"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"k8s.io/klog/v2"
 )
 
 const (
@@ -34,9 +38,9 @@ const (
 )
 
 // RoundUpBytes rounds up the volume size in bytes upto multiplications of GiB
-// in the unit of Bytes
+// in the unit of Bytes, sizes too large to round up return math.MaxInt64
 func RoundUpBytes(volumeSizeBytes int64) int64 {
-	return roundUpSize(volumeSizeBytes, GiB) * GiB
+	return GiBToBytes(roundUpSize(volumeSizeBytes, GiB))
 }
 
 // RoundUpGiB rounds up the volume size in bytes upto multiplications of GiB
@@ -50,12 +54,60 @@ func BytesToGiB(volumeSizeBytes int64) int64 {
 	return volumeSizeBytes / GiB
 }
 
-// GiBToBytes converts GiB to Bytes
+// GiBToBytes converts GiB to Bytes, saturating at math.MaxInt64 and math.MinInt64
 func GiBToBytes(volumeSizeGiB int64) int64 {
+	if volumeSizeGiB > math.MaxInt64/GiB {
+		return math.MaxInt64
+	}
+	if volumeSizeGiB < math.MinInt64/GiB {
+		return math.MinInt64
+	}
 	return volumeSizeGiB * GiB
 }
 
 func ParseEndpoint(endpoint string) (string, string, error) {
+	scheme, addr, err := splitEndpoint(endpoint)
+	if err != nil {
+		return "", "", err
+	}
+	if scheme == "unix" {
+		if err := removeStaleSocket(addr); err != nil {
+			return "", "", err
+		}
+	}
+	return scheme, addr, nil
+}
+
+// staleSocketDialTimeout is how long removeStaleSocket waits for a process serving the socket
+const staleSocketDialTimeout = time.Second
+
+// removeStaleSocket removes the socket at addr left by a crashed process, it fails when addr
+// isn't a socket or another process still serves it instead of stealing its endpoint
+func removeStaleSocket(addr string) error {
+	fi, err := os.Lstat(addr)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not stat unix domain socket %q: %v", addr, err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("could not remove unix domain socket %q: not a socket", addr)
+	}
+	if conn, err := net.DialTimeout("unix", addr, staleSocketDialTimeout); err == nil {
+		conn.Close()
+		return fmt.Errorf("unix domain socket %q is in use by another process", addr)
+	}
+	if err := os.Remove(addr); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not remove unix domain socket %q: %v", addr, err)
+	}
+	klog.Infof("Removed stale unix domain socket %q", addr)
+	return nil
+}
+
+// splitEndpoint returns the scheme and address of endpoint, unlike ParseEndpoint it doesn't
+// remove the socket of unix endpoints
+func splitEndpoint(endpoint string) (string, string, error) {
 	u, err := url.Parse(endpoint)
 	if err != nil {
 		return "", "", fmt.Errorf("could not parse endpoint: %v", err)
@@ -68,9 +120,6 @@ func ParseEndpoint(endpoint string) (string, string, error) {
 	case "tcp":
 	case "unix":
 		addr = path.Join("/", addr)
-		if err := os.Remove(addr); err != nil && !os.IsNotExist(err) {
-			return "", "", fmt.Errorf("could not remove unix domain socket %q: %v", addr, err)
-		}
 	default:
 		return "", "", fmt.Errorf("unsupported protocol: %s", scheme)
 	}
@@ -78,9 +127,14 @@ func ParseEndpoint(endpoint string) (string, string, error) {
 	return scheme, addr, nil
 }
 
-// TODO: check division by zero and int overflow
+// roundUpSize returns the number of allocation units of volumeSizeBytes rounded up, adding
+// allocationUnitBytes-1 before dividing would overflow for sizes close to math.MaxInt64
 func roundUpSize(volumeSizeBytes int64, allocationUnitBytes int64) int64 {
-	return (volumeSizeBytes + allocationUnitBytes - 1) / allocationUnitBytes
+	units := volumeSizeBytes / allocationUnitBytes
+	if volumeSizeBytes%allocationUnitBytes > 0 {
+		units++
+	}
+	return units
 }
 
 // GetAccessModes returns a slice containing all of the access modes defined
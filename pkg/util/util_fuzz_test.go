@@ -0,0 +1,81 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+ Copyright 2021 The Kubernetes Authors.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package util
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func FuzzSplitEndpoint(f *testing.F) {
+	for _, endpoint := range []string{"unix:///csi/csi.sock", "unix://csi.sock", "tcp://127.0.0.1:10000", "TCP://[::1]:80", "http://host", "unix:", "://", "%zz"} {
+		f.Add(endpoint)
+	}
+	f.Fuzz(func(t *testing.T, endpoint string) {
+		scheme, addr, err := splitEndpoint(endpoint)
+		if err != nil {
+			return
+		}
+		if scheme != "tcp" && scheme != "unix" {
+			t.Fatalf("unexpected scheme %q of %q", scheme, endpoint)
+		}
+		if scheme == "unix" && !strings.HasPrefix(addr, "/") {
+			t.Fatalf("expected an absolute socket path for %q, got %q", endpoint, addr)
+		}
+	})
+}
+
+func FuzzRoundUpBytes(f *testing.F) {
+	for _, size := range []int64{0, 1, GiB - 1, GiB, GiB + 1, -1, math.MaxInt64, math.MaxInt64 - GiB, math.MinInt64} {
+		f.Add(size)
+	}
+	f.Fuzz(func(t *testing.T, size int64) {
+		rounded := RoundUpBytes(size)
+		gib := RoundUpGiB(size)
+		if size <= 0 {
+			return
+		}
+		if rounded < size {
+			t.Fatalf("RoundUpBytes(%d) = %d is smaller than the size", size, rounded)
+		}
+		if rounded != math.MaxInt64 && (rounded%GiB != 0 || rounded-size >= GiB) {
+			t.Fatalf("RoundUpBytes(%d) = %d is not the next multiple of GiB", size, rounded)
+		}
+		if gib <= 0 || gib > math.MaxInt64/GiB+1 {
+			t.Fatalf("RoundUpGiB(%d) = %d is out of range", size, gib)
+		}
+		if BytesToGiB(rounded) != gib && rounded != math.MaxInt64 {
+			t.Fatalf("RoundUpGiB(%d) = %d doesn't match RoundUpBytes %d", size, gib, rounded)
+		}
+	})
+}
+
+func FuzzGiBToBytes(f *testing.F) {
+	for _, size := range []int64{0, 1, -1, math.MaxInt64 / GiB, math.MaxInt64/GiB + 1, math.MaxInt64, math.MinInt64} {
+		f.Add(size)
+	}
+	f.Fuzz(func(t *testing.T, size int64) {
+		bytes := GiBToBytes(size)
+		if (size > 0 && bytes <= 0) || (size < 0 && bytes >= 0) {
+			t.Fatalf("GiBToBytes(%d) = %d overflowed", size, bytes)
+		}
+		if bytes != math.MaxInt64 && bytes != math.MinInt64 && BytesToGiB(bytes) != size {
+			t.Fatalf("BytesToGiB(GiBToBytes(%d)) = %d", size, BytesToGiB(bytes))
+		}
+	})
+}
@@ -14,9 +14,16 @@
 package util
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	csi "github.com/container-storage-interface/spec/lib/go/csi"
 )
@@ -27,6 +34,9 @@ func TestRoundUpBytes(t *testing.T) {
 	if actual != 1*GiB {
 		t.Fatalf("Wrong result for RoundUpBytes. Got: %d", actual)
 	}
+	if actual := RoundUpBytes(math.MaxInt64); actual != math.MaxInt64 {
+		t.Fatalf("Wrong result for RoundUpBytes of math.MaxInt64. Got: %d", actual)
+	}
 }
 
 func TestRoundUpGiB(t *testing.T) {
@@ -35,6 +45,9 @@ func TestRoundUpGiB(t *testing.T) {
 	if actual != 1 {
 		t.Fatalf("Wrong result for RoundUpGiB. Got: %d", actual)
 	}
+	if actual := RoundUpGiB(math.MaxInt64); actual != math.MaxInt64/GiB+1 {
+		t.Fatalf("Wrong result for RoundUpGiB of math.MaxInt64. Got: %d", actual)
+	}
 }
 
 func TestBytesToGiB(t *testing.T) {
@@ -53,6 +66,9 @@ func TestGiBToBytes(t *testing.T) {
 	if actual != 3*GiB {
 		t.Fatalf("Wrong result for GiBToBytes. Got: %d", actual)
 	}
+	if actual := GiBToBytes(math.MaxInt64 / GiB * 2); actual != math.MaxInt64 {
+		t.Fatalf("Wrong result for GiBToBytes of an overflowing size. Got: %d", actual)
+	}
 }
 
 func TestParseEndpoint(t *testing.T) {
@@ -126,6 +142,49 @@ func TestParseEndpoint(t *testing.T) {
 
 }
 
+func TestRemoveStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+	listen := func(name string) *net.UnixListener {
+		l, err := net.ListenUnix("unix", &net.UnixAddr{Name: filepath.Join(dir, name), Net: "unix"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+
+	// the socket of a crashed process stays on disk
+	stale := listen("stale.sock")
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+	live := listen("live.sock")
+	defer live.Close()
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name      string
+		expectErr bool
+	}{
+		{name: "missing"},
+		{name: "stale.sock"},
+		{name: "live.sock", expectErr: true},
+		{name: "file", expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := filepath.Join(dir, tc.name)
+			err := removeStaleSocket(addr)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %v, got: %v", tc.expectErr, err)
+			}
+			if _, statErr := os.Lstat(addr); tc.expectErr == os.IsNotExist(statErr) {
+				t.Fatalf("expected %s to be removed %v", addr, !tc.expectErr)
+			}
+		})
+	}
+}
+
 func TestGetAccessModes(t *testing.T) {
 	testVolCap := []*csi.VolumeCapability{
 		{
@@ -148,3 +207,84 @@ func TestGetAccessModes(t *testing.T) {
 		t.Fatalf("Wrong values returned for volume capabilities. Expected %v, got %v", expectedModes, actualModes)
 	}
 }
+
+func TestRequestID(t *testing.T) {
+	ctx := context.Background()
+	if id := RequestID(ctx); id != "" {
+		t.Fatalf("expected no request ID, got %q", id)
+	}
+	id := NewRequestID()
+	if id == NewRequestID() {
+		t.Fatalf("expected unique request IDs")
+	}
+	if got := RequestID(WithRequestID(ctx, id)); got != id {
+		t.Fatalf("expected request ID %q, got %q", id, got)
+	}
+}
+
+func TestVolumeLocks(t *testing.T) {
+	locks := NewVolumeLocks()
+	if !locks.TryAcquire("vol-1") {
+		t.Fatalf("expected to acquire a free lock")
+	}
+	if locks.TryAcquire("vol-1") {
+		t.Fatalf("expected not to acquire a held lock")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := locks.Acquire(ctx, "vol-1"); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v once the context is done, got %v", context.DeadlineExceeded, err)
+	}
+	if err := locks.AcquireWithTimeout(context.Background(), "vol-1", 0); err == nil {
+		t.Fatalf("expected no wait for a held lock without timeout")
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- locks.AcquireWithTimeout(context.Background(), "vol-1", 10*time.Second)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	locks.Release("vol-1")
+	if err := <-acquired; err != nil {
+		t.Fatalf("expected the waiter to acquire the released lock, got %v", err)
+	}
+	if locks.TryAcquire("vol-1") {
+		t.Fatalf("expected the lock to be held by the waiter")
+	}
+	if err := locks.AcquireWithTimeout(context.Background(), "vol-2", 0); err != nil {
+		t.Fatalf("expected to acquire a free lock without timeout, got %v", err)
+	}
+}
+
+func TestPhases(t *testing.T) {
+	StartPhase(context.Background(), PhaseAPI)()
+
+	ctx, phases := WithPhases(context.Background())
+	end := StartPhase(ctx, PhaseMount)
+	time.Sleep(10 * time.Millisecond)
+	end()
+	StartPhase(ctx, PhaseAPI)()
+	StartPhase(ctx, PhaseAPI)()
+
+	durations := phases.Durations()
+	if len(durations) != 2 {
+		t.Fatalf("expected 2 phases, got %v", durations)
+	}
+	if durations[PhaseMount] < 10*time.Millisecond {
+		t.Fatalf("expected mount phase of at least 10ms, got %v", durations[PhaseMount])
+	}
+	if s := phases.String(); !strings.HasPrefix(s, "api=") || !strings.Contains(s, " mount=") {
+		t.Fatalf("unexpected phases %q", s)
+	}
+
+	// nested phases are recorded in the outer phases as well
+	nestedCtx, nested := WithPhases(ctx)
+	StartPhase(nestedCtx, PhaseVolumeWait)()
+	if _, ok := nested.Durations()[PhaseVolumeWait]; !ok || len(nested.Durations()) != 1 {
+		t.Fatalf("expected only the volume-wait phase in the nested phases, got %v", nested.Durations())
+	}
+	if _, ok := phases.Durations()[PhaseVolumeWait]; !ok {
+		t.Fatalf("expected the nested volume-wait phase in the outer phases, got %v", phases.Durations())
+	}
+}
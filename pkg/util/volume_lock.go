@@ -17,9 +17,9 @@ limitations under the License.
 package util
 
 import (
+	"context"
 	"sync"
-
-	"k8s.io/apimachinery/pkg/util/sets"
+	"time"
 )
 
 const (
@@ -29,30 +29,65 @@ const (
 // VolumeLocks implements a map with atomic operations. It stores a set of all volume IDs
 // with an ongoing operation.
 type VolumeLocks struct {
-	locks sets.String
+	// locks holds a channel per locked volume ID, it's closed on Release to wake up waiters
+	locks map[string]chan struct{}
 	mux   sync.Mutex
 }
 
 func NewVolumeLocks() *VolumeLocks {
 	return &VolumeLocks{
-		locks: sets.NewString(),
+		locks: make(map[string]chan struct{}),
 	}
 }
 
 // TryAcquire tries to acquire the lock for operating on volumeID and returns true if successful.
 // If another operation is already using volumeID, returns false.
 func (vl *VolumeLocks) TryAcquire(volumeID string) bool {
+	_, acquired := vl.tryAcquire(volumeID)
+	return acquired
+}
+
+// tryAcquire acquires the lock of volumeID if it's free, else it returns the channel closed
+// when the lock is released
+func (vl *VolumeLocks) tryAcquire(volumeID string) (<-chan struct{}, bool) {
 	vl.mux.Lock()
 	defer vl.mux.Unlock()
-	if vl.locks.Has(volumeID) {
-		return false
+	if released, ok := vl.locks[volumeID]; ok {
+		return released, false
 	}
-	vl.locks.Insert(volumeID)
-	return true
+	vl.locks[volumeID] = make(chan struct{})
+	return nil, true
+}
+
+// Acquire waits until the lock for operating on volumeID is acquired, or returns ctx.Err()
+// if ctx is done first
+func (vl *VolumeLocks) Acquire(ctx context.Context, volumeID string) error {
+	for {
+		released, acquired := vl.tryAcquire(volumeID)
+		if acquired {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+// AcquireWithTimeout is Acquire waiting at most timeout, a timeout of 0 acquires the lock only
+// if it's free like TryAcquire
+func (vl *VolumeLocks) AcquireWithTimeout(ctx context.Context, volumeID string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return vl.Acquire(ctx, volumeID)
 }
 
 func (vl *VolumeLocks) Release(volumeID string) {
 	vl.mux.Lock()
 	defer vl.mux.Unlock()
-	vl.locks.Delete(volumeID)
+	if released, ok := vl.locks[volumeID]; ok {
+		close(released)
+		delete(vl.locks, volumeID)
+	}
 }
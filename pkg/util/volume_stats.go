@@ -0,0 +1,29 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+// VolumeStats are the capacity and usage of the filesystem of a volume, raw block volumes
+// only have their size in TotalBytes
+type VolumeStats struct {
+	Block          bool
+	TotalBytes     int64
+	AvailableBytes int64
+	UsedBytes      int64
+	Inodes         int64
+	InodesFree     int64
+	InodesUsed     int64
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	powervscloud "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+)
+
+// environment variables selecting the PowerVS workspace the tests run against
+const (
+	cloudInstanceIDEnv = "POWERVS_CLOUD_INSTANCE_ID"
+	pvmInstanceIDEnv   = "POWERVS_PVM_INSTANCE_ID"
+)
+
+var (
+	cloudInstanceID string
+	pvmInstanceID   string
+	cloud           powervscloud.Cloud
+)
+
+func TestCloud(t *testing.T) {
+	cloudInstanceID = os.Getenv(cloudInstanceIDEnv)
+	pvmInstanceID = os.Getenv(pvmInstanceIDEnv)
+	if os.Getenv(powervscloud.APIKeyEnv) == "" || cloudInstanceID == "" {
+		t.Skip("env " + powervscloud.APIKeyEnv + " and " + cloudInstanceIDEnv + " must be set to run against PowerVS")
+	}
+
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "IBM PowerVS Block CSI Driver Cloud Tests")
+}
+
+var _ = BeforeSuite(func() {
+	var options []func(*powervscloud.Options)
+	if endpoints := os.Getenv(powervscloud.PowerVSEndpointEnv); endpoints != "" {
+		options = append(options, powervscloud.WithAPIEndpoints(strings.Split(endpoints, ",")))
+	}
+	var err error
+	cloud, err = powervscloud.NewPowerVSCloud(cloudInstanceID, false, options...)
+	Expect(err).NotTo(HaveOccurred(), "could not create the PowerVS client")
+})
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	powervscloud "sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/cloud"
+	"sigs.k8s.io/ibm-powervs-block-csi-driver/pkg/util"
+)
+
+var _ = Describe("[powervs-cloud]Volume", func() {
+	var (
+		ctx      context.Context
+		volumeID string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		name := fmt.Sprintf("csi-e2e-%d", time.Now().UnixNano())
+		disk, err := cloud.CreateDisk(ctx, name, &powervscloud.DiskOptions{
+			CapacityBytes: 1 * util.GiB,
+			VolumeType:    powervscloud.VolumeTypeTier3,
+			Tags:          []string{"csi-e2e:true"},
+		})
+		Expect(err).NotTo(HaveOccurred(), "could not create volume %s", name)
+		volumeID = disk.VolumeID
+
+		byName, err := cloud.GetDiskByName(ctx, name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(byName.VolumeID).To(Equal(volumeID))
+	})
+
+	AfterEach(func() {
+		if volumeID == "" {
+			return
+		}
+		if pvmInstanceID != "" {
+			if attached, _ := cloud.IsAttached(ctx, volumeID, pvmInstanceID); attached {
+				Expect(cloud.DetachDisk(ctx, volumeID, pvmInstanceID)).To(Succeed())
+			}
+		}
+		_, err := cloud.DeleteDisk(ctx, volumeID)
+		Expect(err).NotTo(HaveOccurred(), "could not delete volume %s", volumeID)
+		Eventually(func() error {
+			_, err := cloud.GetDiskByID(ctx, volumeID)
+			return err
+		}, 5*time.Minute, 10*time.Second).Should(MatchError(powervscloud.ErrNotFound))
+	})
+
+	It("should create an available volume of the requested size and tier", func() {
+		disk, err := cloud.GetDiskByID(ctx, volumeID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(disk.CapacityGiB).To(Equal(int64(1)))
+		Expect(disk.DiskType).To(Equal(powervscloud.VolumeTypeTier3))
+		Expect(disk.WWN).NotTo(BeEmpty())
+	})
+
+	It("should expand a volume", func() {
+		size, err := cloud.ResizeDisk(ctx, volumeID, 2*util.GiB)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(int64(2)))
+
+		disk, err := cloud.GetDiskByID(ctx, volumeID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(disk.CapacityGiB).To(Equal(int64(2)))
+	})
+
+	It("should change the tier of a volume", func() {
+		Expect(cloud.UpdateDiskTier(ctx, volumeID, powervscloud.VolumeTypeTier1)).To(Succeed())
+		Eventually(func() (string, error) {
+			disk, err := cloud.GetDiskByID(ctx, volumeID)
+			if err != nil {
+				return "", err
+			}
+			return disk.DiskType, nil
+		}, 10*time.Minute, 15*time.Second).Should(Equal(powervscloud.VolumeTypeTier1))
+	})
+
+	It("should attach and detach a volume", func() {
+		if pvmInstanceID == "" {
+			Skip("env " + pvmInstanceIDEnv + " not set")
+		}
+		_, err := cloud.GetPVMInstanceByID(ctx, pvmInstanceID)
+		Expect(err).NotTo(HaveOccurred(), "could not get PVM instance %s", pvmInstanceID)
+
+		Expect(cloud.AttachDisk(ctx, volumeID, pvmInstanceID)).To(Succeed())
+		attached, err := cloud.IsAttached(ctx, volumeID, pvmInstanceID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(attached).To(BeTrue())
+
+		Expect(cloud.DetachDisk(ctx, volumeID, pvmInstanceID)).To(Succeed())
+		// PowerVS reports a detached volume with an error of the attachment check
+		attached, _ = cloud.IsAttached(ctx, volumeID, pvmInstanceID)
+		Expect(attached).To(BeFalse())
+	})
+
+	It("should report a missing volume as not found", func() {
+		_, err := cloud.GetDiskByID(ctx, "00000000-0000-0000-0000-000000000000")
+		Expect(err).To(MatchError(powervscloud.ErrNotFound))
+	})
+})
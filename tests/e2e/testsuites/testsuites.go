@@ -288,7 +288,7 @@ func (t *TestPersistentVolumeClaim) DeleteBoundPersistentVolume() {
 func (t *TestPersistentVolumeClaim) DeleteBackingVolume(cloud powervscloud.Cloud) {
 	volumeID := t.persistentVolume.Spec.CSI.VolumeHandle
 	By(fmt.Sprintf("deleting PowerVS volume %q", volumeID))
-	ok, err := cloud.DeleteDisk(volumeID)
+	ok, err := cloud.DeleteDisk(context.Background(), volumeID)
 	if err != nil || !ok {
 		Fail(fmt.Sprintf("could not delete volume %q: %v", volumeID, err))
 	}